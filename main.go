@@ -1,228 +1,229 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
-	"strconv"
+	"os"
+	"os/signal"
 	"strings"
+	"syscall"
+	"time"
+
+	"schema2api/pkg/server"
 )
 
-// Schema defines the JSON schema structure.
-type Schema struct {
-	Title      string              `json:"title"`
-	Type       string              `json:"type"`
-	Properties map[string]Property `json:"properties"`
-	Required   []string            `json:"required"`
-}
+// shutdownTimeout bounds how long in-flight requests may take to finish
+// once the server is asked to stop.
+const shutdownTimeout = 10 * time.Second
 
-// Property defines each property's type.
-type Property struct {
-	Type string `json:"type"`
+// envOr returns the value of the environment variable key, or fallback
+// when it is unset, so every flag below can also be set from the
+// environment.
+func envOr(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return fallback
 }
 
-// currentSchema holds the uploaded JSON schema.
-var currentSchema *Schema
-
-// dummyData generates a dummy data object based on the schema.
-func dummyData() map[string]interface{} {
-	data := make(map[string]interface{})
-	if currentSchema == nil {
-		return data
-	}
-	for key, prop := range currentSchema.Properties {
-		switch prop.Type {
-		case "string":
-			data[key] = "example"
-		case "integer":
-			data[key] = 1
-		case "number":
-			data[key] = 0.0
-		case "boolean":
-			data[key] = false
-		default:
-			data[key] = nil
+// splitList returns the non-empty, trimmed items of a comma-separated flag.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
 		}
 	}
-	return data
+	return items
 }
 
-// uploadHandler handles uploading and parsing JSON schema.
-func uploadHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Only POST allowed", http.StatusMethodNotAllowed)
-		return
+func main() {
+	host := flag.String("host", envOr("SCHEMA2API_HOST", ""), "interface to listen on; empty listens on all (env SCHEMA2API_HOST)")
+	port := flag.String("port", envOr("SCHEMA2API_PORT", "8081"), "port to listen on (env SCHEMA2API_PORT)")
+	schemaFiles := flag.String("schema", envOr("SCHEMA2API_SCHEMA", ""), "comma-separated schema files to upload at startup (env SCHEMA2API_SCHEMA)")
+	sqlFiles := flag.String("sql", envOr("SCHEMA2API_SQL", ""), "comma-separated SQL files whose CREATE TABLE statements become entities at startup (env SCHEMA2API_SQL)")
+	fixtureFiles := flag.String("fixtures", envOr("SCHEMA2API_FIXTURES", ""), "comma-separated fixtures files of records per entity to load after the schemas (env SCHEMA2API_FIXTURES)")
+	openAPIFiles := flag.String("openapi", envOr("SCHEMA2API_OPENAPI", ""), "comma-separated OpenAPI 2/3 JSON documents whose operations are mocked (env SCHEMA2API_OPENAPI)")
+	postmanFiles := flag.String("postman", envOr("SCHEMA2API_POSTMAN", ""), "comma-separated Postman collections whose requests are mocked with their saved examples (env SCHEMA2API_POSTMAN)")
+	exportPostman := flag.String("export-postman", "", "write a Postman collection of the generated routes to this file, - for stdout, and exit instead of serving")
+	overrideFiles := flag.String("overrides", envOr("SCHEMA2API_OVERRIDES", ""), "comma-separated JSON or YAML files of scripted responses that take precedence over generated ones (env SCHEMA2API_OVERRIDES)")
+	dataDir := flag.String("data-dir", envOr("SCHEMA2API_DATA_DIR", ""), "directory to snapshot schemas and records to after every write and restore them from at startup (env SCHEMA2API_DATA_DIR)")
+	basePath := flag.String("base-path", envOr("SCHEMA2API_BASE_PATH", ""), "serve every route under this prefix, e.g. /api/v1 (env SCHEMA2API_BASE_PATH)")
+	debug := flag.Bool("debug", false, "include a _meta block in list responses")
+	warnUnknownParams := flag.Bool("warn-unknown-params", false, "name query parameters matching no property in an X-Unknown-Params header on list responses")
+	strictAccept := flag.Bool("strict-accept", false, "respond 406 to requests whose Accept header does not allow JSON")
+	welcome := flag.String("welcome", server.DefaultWelcome, "usage hint returned by the index at GET /")
+	noSchemaStatus := flag.Int("no-schema-status", http.StatusServiceUnavailable, "status returned by entity routes before a schema is uploaded")
+	looseRoutes := flag.Bool("loose-routes", false, "also match entity routes by the singular form of the title, e.g. /user/1")
+	strictGet := flag.Bool("strict-get", false, "respond 404 to GET on ids that were never created instead of fabricating them")
+	strictPut := flag.Bool("strict-put", false, "respond 404 to PUT on ids that are not stored instead of creating them")
+	softDelete := flag.Bool("soft-delete", false, "make DELETE set deletedAt on stored records instead of removing them; POST /{entity}/{id}/restore undeletes")
+	rejectIDMismatch := flag.Bool("reject-id-mismatch", false, "respond 422 when a PUT or PATCH body id differs from the URL id instead of ignoring it")
+	genMode := flag.String("gen-mode", "constant", "how generated values vary across objects: constant, sequential or random")
+	listSize := flag.Int("list-size", 3, "number of generated records a list holds before any record is written")
+	arrayLength := flag.Int("array-length", 2, "number of elements generated for array properties, within their minItems and maxItems")
+	seed := flag.Int64("seed", 0, "seed for generated data, so random values repeat across runs; 0 picks a new seed on every start")
+	faker := flag.Bool("faker", false, "generate realistic values for string properties named like name, email, phone or city")
+	asyncCreate := flag.Duration("async-create", 0, "respond 202 to POST and complete the create after this delay, e.g. 2s")
+	validate := flag.String("validate", "reject", "how to handle request bodies that do not match the schema: reject or warn")
+	optionalFields := flag.String("optional-fields", "fill", "how generated objects represent optional properties: fill, null or omit")
+	idStart := flag.Int("id-start", 1, "first auto-assigned id for schemas without x-id-start")
+	idStep := flag.Int("id-step", 1, "auto-increment step for schemas without x-id-step")
+	latency := flag.Duration("latency", 0, "artificial delay added to every response, e.g. 200ms")
+	latencyJitter := flag.Duration("latency-jitter", 0, "random variation applied to -latency in either direction, e.g. 100ms")
+	htmlErrors := flag.Bool("html-errors", false, "render error responses as HTML pages for browsers (Accept: text/html)")
+	envelope := flag.String("envelope", "", "key to wrap successful entity responses under, e.g. data; empty sends records as they are")
+	envelopeMeta := flag.String("envelope-meta", "meta", "key of the envelope's metadata object")
+	paginationMeta := flag.String("pagination-meta", "headers", "where list pagination metadata lives: headers, body (the envelope's meta) or both")
+	legacyErrors := flag.Bool("legacy-errors", false, "send error responses as plain {code, message, details} JSON instead of application/problem+json")
+	jsonAPI := flag.Bool("jsonapi", false, "serve entity routes as JSON:API documents even without Accept: application/vnd.api+json")
+	compress := flag.Bool("compress", false, "compress responses with gzip or deflate as the Accept-Encoding header allows")
+	compressMinSize := flag.Int("compress-min-size", 1024, "body size in bytes from which -compress compresses responses")
+	errorRate := flag.Float64("error-rate", 0, "share of requests, from 0 to 1, answered with a random 500, 502, 503 or 504")
+	dropRate := flag.Float64("drop-rate", 0, "share of requests, from 0 to 1, whose connection is closed without a response")
+	rateLimit := flag.Int("rate-limit", 0, "requests each client may make per -rate-window before getting 429; 0 disables rate limiting")
+	rateWindow := flag.Duration("rate-window", time.Minute, "window -rate-limit counts requests in, e.g. 10s")
+	rateLimitBy := flag.String("rate-limit-by", "key", "how -rate-limit tells clients apart: key (the API key or token, else the address) or ip")
+	drip := flag.Duration("drip", 0, "write response bodies in 64-byte chunks with this pause between them, e.g. 100ms")
+	corsOrigins := flag.String("cors-origins", envOr("SCHEMA2API_CORS_ORIGINS", "*"), "comma-separated origins browsers may call from, * for any, https://*.example.com for subdomains; empty disables CORS (env SCHEMA2API_CORS_ORIGINS)")
+	corsMethods := flag.String("cors-methods", "", "comma-separated methods CORS preflights allow; empty allows every entity method")
+	corsHeaders := flag.String("cors-headers", "", "comma-separated request headers CORS preflights allow; empty allows any")
+	corsCredentials := flag.Bool("cors-credentials", false, "let cross-origin requests carry cookies and HTTP authentication")
+	requestTimeout := flag.Duration("request-timeout", 0, "respond 503 to requests that have not started their response within this, e.g. 5s")
+	auth := flag.Bool("auth", false, "respond 401 to requests without a valid X-API-Key, or a key or /oauth/token JWT as Authorization: Bearer, and 403 to writes with a read-only key")
+	authKeys := flag.String("auth-keys", envOr("SCHEMA2API_AUTH_KEYS", ""), "comma-separated API keys -auth accepts, key:read for read-only ones and key:write:admin|editor to give roles (env SCHEMA2API_AUTH_KEYS)")
+	accessRules := flag.String("access-rules", "", "comma-separated rules requiring roles under -auth, e.g. \"DELETE /users=admin,POST /orders=admin|editor\"")
+	passthrough := flag.String("passthrough", envOr("SCHEMA2API_PASSTHROUGH", ""), "upstream URL requests to unmocked routes are proxied to, e.g. https://api.example.com (env SCHEMA2API_PASSTHROUGH)")
+	recordPath := flag.String("record", "", "append every request and response to this JSONL file")
+	flag.Parse()
+
+	opts := []server.Option{
+		server.WithDebug(*debug),
+		server.WithWarnUnknownParams(*warnUnknownParams),
+		server.WithStrictAccept(*strictAccept),
+		server.WithWelcome(*welcome),
+		server.WithNoSchemaStatus(*noSchemaStatus),
+		server.WithLooseRoutes(*looseRoutes),
+		server.WithStrictGet(*strictGet),
+		server.WithStrictPut(*strictPut),
+		server.WithSoftDelete(*softDelete),
+		server.WithRejectIDMismatch(*rejectIDMismatch),
+		server.WithGenMode(*genMode),
+		server.WithListSize(*listSize),
+		server.WithArrayLength(*arrayLength),
+		server.WithFaker(*faker),
+		server.WithAsyncCreate(*asyncCreate),
+		server.WithValidation(*validate),
+		server.WithOptionalFields(*optionalFields),
+		server.WithIDSequence(*idStart, *idStep),
+		server.WithLatency(*latency, *latencyJitter),
+		server.WithHTMLErrors(*htmlErrors),
+		server.WithEnvelope(*envelope, *envelopeMeta),
+		server.WithPaginationMeta(*paginationMeta),
+		server.WithLegacyErrors(*legacyErrors),
+		server.WithJSONAPI(*jsonAPI),
+		server.WithCompression(*compress),
+		server.WithCompressionMinSize(*compressMinSize),
+		server.WithRequestTimeout(*requestTimeout),
+		server.WithErrorRate(*errorRate),
+		server.WithDropRate(*dropRate),
+		server.WithSlowDrip(*drip),
+		server.WithRateLimit(*rateLimit, *rateWindow),
+		server.WithRateLimitBy(*rateLimitBy),
+		server.WithCORSOrigins(splitList(*corsOrigins)...),
+		server.WithCORSMethods(splitList(*corsMethods)...),
+		server.WithCORSHeaders(splitList(*corsHeaders)...),
+		server.WithCORSCredentials(*corsCredentials),
+		server.WithBasePath(*basePath),
 	}
-	defer r.Body.Close()
-	var schema Schema
-	if err := json.NewDecoder(r.Body).Decode(&schema); err != nil {
-		http.Error(w, "Invalid JSON schema: "+err.Error(), http.StatusBadRequest)
-		return
+	if *seed != 0 {
+		opts = append(opts, server.WithSeed(*seed))
 	}
-	currentSchema = &schema
-	w.Header().Set("Content-Type", "application/json")
-	response := map[string]string{
-		"message": "Schema uploaded successfully",
-		"title":   schema.Title,
+	for _, path := range splitList(*schemaFiles) {
+		opts = append(opts, server.WithSchemaFile(path))
 	}
-	json.NewEncoder(w).Encode(response)
-}
-
-// catchAllHandler handles all other routes.
-func catchAllHandler(w http.ResponseWriter, r *http.Request) {
-	// Ensure a schema is loaded.
-	if currentSchema == nil {
-		http.Error(w, "No schema uploaded. Please POST your JSON schema to /upload", http.StatusBadRequest)
-		return
+	for _, path := range splitList(*sqlFiles) {
+		opts = append(opts, server.WithSQLFile(path))
 	}
-
-	path := strings.Trim(r.URL.Path, "/")
-	segments := strings.Split(path, "/")
-	entity := strings.ToLower(currentSchema.Title) + "s" // simple pluralization
-	var responseObj interface{}
-
-	switch r.Method {
-	case http.MethodGet:
-		if len(segments) == 1 && segments[0] == entity {
-			// Return a list of dummy objects
-			var list []map[string]interface{}
-			for i := 1; i <= 3; i++ {
-				obj := dummyData()
-				obj["id"] = i
-				list = append(list, obj)
-			}
-			responseObj = list
-		} else if len(segments) == 2 && segments[0] == entity {
-			         // Return single dummy object reflecting the requested ID
-			         requestedID := segments[1]
-			         obj := dummyData()
-
-			         // Check schema for expected ID type (simple check for "id" property)
-			         idProp, hasIntegerId := currentSchema.Properties["id"]
-			         isIntegerExpected := hasIntegerId && idProp.Type == "integer"
-
-			         if isIntegerExpected {
-			             // Expecting an integer ID
-			             id, err := strconv.Atoi(requestedID)
-			             if err != nil {
-			                 http.Error(w, "Invalid ID format: expected integer", http.StatusBadRequest)
-			                 return
-			             }
-			             obj["id"] = id
-			         } else {
-			             // Expecting a string ID (or no specific "id" field)
-			             // Find the first string property to use as key, or default to "id"
-			             stringKey := "id" // Default key
-			             foundKey := false
-			             for key, prop := range currentSchema.Properties {
-			                  // Use explicit "id" if string, or first string property otherwise
-			                 if key == "id" && prop.Type == "string" {
-			                      stringKey = key
-			                      foundKey = true
-			                      break
-			                 }
-			                 if prop.Type == "string" && !foundKey {
-			                     stringKey = key
-			                     // Don't break, prefer "id" if found later
-			                 }
-			             }
-			              obj[stringKey] = requestedID
-			         }
-			         responseObj = obj
-		} else {
-			http.NotFound(w, r)
-			return
+	for _, path := range splitList(*fixtureFiles) {
+		opts = append(opts, server.WithFixturesFile(path))
+	}
+	for _, path := range splitList(*openAPIFiles) {
+		opts = append(opts, server.WithOpenAPIFile(path))
+	}
+	for _, path := range splitList(*postmanFiles) {
+		opts = append(opts, server.WithPostmanFile(path))
+	}
+	if *auth {
+		opts = append(opts, server.WithAuth(splitList(*authKeys)...), server.WithAccessRules(splitList(*accessRules)...))
+	}
+	for _, path := range splitList(*overrideFiles) {
+		opts = append(opts, server.WithOverridesFile(path))
+	}
+	if *passthrough != "" {
+		opts = append(opts, server.WithPassthrough(*passthrough))
+	}
+	if *dataDir != "" {
+		opts = append(opts, server.WithDataDir(*dataDir))
+	}
+	if *recordPath != "" {
+		file, err := os.OpenFile(*recordPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+		if err != nil {
+			log.Fatal("Could not open recording file: ", err)
 		}
-	case http.MethodPost:
-		// Simulate creation and echo back dummy object
-		obj := dummyData()
-		obj["id"] = 1 // simulate new id
-		responseObj = obj
-	case http.MethodPut:
-		      // Simulate update and return updated dummy object reflecting the ID
-		      if len(segments) == 2 && segments[0] == entity {
-		          requestedID := segments[1]
-		          obj := dummyData()
+		defer file.Close()
+		opts = append(opts, server.WithRecorder(file))
+	}
 
-		           // Check schema for expected ID type
-		          idProp, hasIntegerId := currentSchema.Properties["id"]
-		          isIntegerExpected := hasIntegerId && idProp.Type == "integer"
+	srv, err := server.New(opts...)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-		          if isIntegerExpected {
-		               // Expecting an integer ID
-		              id, err := strconv.Atoi(requestedID)
-		              if err != nil {
-		                  http.Error(w, "Invalid ID format: expected integer", http.StatusBadRequest)
-		                  return
-		              }
-		              obj["id"] = id
-		          } else {
-		              // Expecting a string ID
-		               stringKey := "id"
-		               foundKey := false
-		               for key, prop := range currentSchema.Properties {
-		                   if key == "id" && prop.Type == "string" {
-		                       stringKey = key
-		                       foundKey = true
-		                       break
-		                   }
-		                   if prop.Type == "string" && !foundKey {
-		                       stringKey = key
-		                   }
-		               }
-		               obj[stringKey] = requestedID
-		          }
-		          responseObj = obj
-		      } else {
-		          http.NotFound(w, r)
-			return
+	addr := net.JoinHostPort(*host, *port)
+	if *exportPostman != "" {
+		exportHost := *host
+		if exportHost == "" {
+			exportHost = "localhost"
 		}
-	case http.MethodDelete:
-		// Simulate deletion by returning a success message.
-		if len(segments) == 2 && segments[0] == entity {
-			// Validate ID format based on schema expectation
-			requestedID := segments[1]
-			idProp, hasIntegerId := currentSchema.Properties["id"]
-			isIntegerExpected := hasIntegerId && idProp.Type == "integer"
-
-			if isIntegerExpected {
-			     // Expecting an integer ID
-			    _, err := strconv.Atoi(requestedID)
-			    if err != nil {
-			        http.Error(w, "Invalid ID format: expected integer", http.StatusBadRequest)
-			        return
-			    }
+		out := os.Stdout
+		if *exportPostman != "-" {
+			if out, err = os.Create(*exportPostman); err != nil {
+				log.Fatal("Could not create Postman collection: ", err)
 			}
-			// If not expecting integer, any string is considered valid for DELETE
-
-			// Validation passed
-			// In a real scenario, might check against schema type here
-
-			responseObj = map[string]string{"message": "Deleted successfully"}
-		} else {
-			http.NotFound(w, r)
-			return
+			defer out.Close()
+		}
+		if err := srv.ExportPostman(out, "http://"+net.JoinHostPort(exportHost, *port)); err != nil {
+			log.Fatal("Could not write Postman collection: ", err)
 		}
-	default:
-		http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		srv.Close()
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(responseObj); err != nil {
-		log.Println("Error encoding response:", err)
-	}
-}
-
-func main() {
-	// Endpoint to upload JSON schema.
-	http.HandleFunc("/upload", uploadHandler)
-	// Catch-all route handler.
-	http.HandleFunc("/", catchAllHandler)
-
-	fmt.Println("Server started on port :8081")
-	if err := http.ListenAndServe(":8081", nil); err != nil {
+	// On SIGINT or SIGTERM, let in-flight requests finish and then close
+	// the server, so the recording and the data-dir snapshot are flushed.
+	httpServer := &http.Server{Addr: addr, Handler: srv.Handler()}
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		<-signals
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := httpServer.Shutdown(ctx); err != nil {
+			log.Println("Shutdown:", err)
+		}
+	}()
+	fmt.Println("Server started on " + addr)
+	if err := httpServer.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
 		log.Fatal("ListenAndServe: ", err)
 	}
+	<-stopped
+	srv.Close()
 }
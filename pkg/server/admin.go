@@ -0,0 +1,258 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// adminPrefix is the reserved namespace of the admin API. It is served
+// before the entity routes, so no entity can be routed there.
+const adminPrefix = "/__admin"
+
+// isAdminPath reports whether a request path, including basePath, is in
+// the admin namespace.
+func (s *Server) isAdminPath(path string) bool {
+	rest, ok := strings.CutPrefix(path, s.route(adminPrefix))
+	return ok && (rest == "" || rest[0] == '/')
+}
+
+// adminHandler serves the admin API:
+//
+//	GET    /__admin                   index of the admin endpoints
+//	GET    /__admin/schemas           registered entities, as at /entities
+//	DELETE /__admin/schemas           forget every schema, record and import
+//	GET    /__admin/schemas/{entity}  the schema as registered, refs inlined
+//	DELETE /__admin/schemas/{entity}  unregister one entity and its records
+//	GET    /__admin/data              stored records of every entity
+//	DELETE /__admin/data              empty every store, keeping the schemas
+//	GET    /__admin/data/{entity}     stored records of one entity
+//	DELETE /__admin/data/{entity}     empty one store
+//	GET    /__admin/config            the server's settings
+//	       /__admin/requests[/...]    the request journal; see adminRequests
+//	       /__admin/keys[/{key}]      the valid API keys; see adminKeys
+//	       /__admin/rules[/{id}]      the access rules; see adminRules
+//	       /__admin/overrides[/{id}]  the response overrides; see adminOverrides
+//	       /__admin/scenarios[/...]   scenario states; see adminScenarios
+//	POST   /__admin/import/har        stub captured traffic; see adminImport
+//
+// Entities are named by title or collection segment, as in
+// /schemas/{entity}/diff. Emptying a store restarts its id counter.
+func (s *Server) adminHandler(w http.ResponseWriter, r *http.Request) {
+	rest, _ := strings.CutPrefix(r.URL.Path, adminPrefix)
+	segments := strings.Split(strings.Trim(rest, "/"), "/")
+	if len(segments) > 2 {
+		writeNotFound(w, r)
+		return
+	}
+	resource, entity := segments[0], ""
+	if len(segments) == 2 {
+		entity = segments[1]
+	}
+
+	switch {
+	case resource == "":
+		if r.Method != http.MethodGet {
+			writeMethodNotAllowed(w, "Only GET allowed", http.MethodGet)
+			return
+		}
+		writeAdminJSON(w, map[string]string{
+			"schemas":   s.route(adminPrefix + "/schemas"),
+			"data":      s.route(adminPrefix + "/data"),
+			"config":    s.route(adminPrefix + "/config"),
+			"requests":  s.route(adminPrefix + "/requests"),
+			"keys":      s.route(adminPrefix + "/keys"),
+			"rules":     s.route(adminPrefix + "/rules"),
+			"overrides": s.route(adminPrefix + "/overrides"),
+			"scenarios": s.route(adminPrefix + "/scenarios"),
+			"importHar": s.route(adminPrefix + "/import/har"),
+		})
+	case resource == "config" && entity == "":
+		if r.Method != http.MethodGet {
+			writeMethodNotAllowed(w, "Only GET allowed", http.MethodGet)
+			return
+		}
+		writeAdminJSON(w, s.config())
+	case resource == "schemas":
+		s.adminSchemas(w, r, entity)
+	case resource == "data":
+		s.adminData(w, r, entity)
+	case resource == "requests":
+		s.adminRequests(w, r, entity)
+	case resource == "keys":
+		s.adminKeys(w, r, entity)
+	case resource == "rules":
+		s.adminRules(w, r, entity)
+	case resource == "overrides":
+		s.adminOverrides(w, r, entity)
+	case resource == "scenarios":
+		s.adminScenarios(w, r, entity)
+	case resource == "import":
+		s.adminImport(w, r, entity)
+	default:
+		writeNotFound(w, r)
+	}
+}
+
+// adminSchemas serves /__admin/schemas and /__admin/schemas/{entity}.
+func (s *Server) adminSchemas(w http.ResponseWriter, r *http.Request, entity string) {
+	switch r.Method {
+	case http.MethodGet:
+		if entity == "" {
+			writeAdminJSON(w, s.registeredEntities())
+			return
+		}
+		s.stateMu.RLock()
+		schema, ok := s.lookupSchema(entity)
+		s.stateMu.RUnlock()
+		if !ok {
+			writeError(w, http.StatusNotFound, fmt.Sprintf("No schema registered for %q", entity))
+			return
+		}
+		writeAdminJSON(w, schema)
+	case http.MethodDelete:
+		if entity == "" {
+			s.resetState()
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		s.stateMu.Lock()
+		schema, ok := s.lookupSchema(entity)
+		if ok {
+			key := strings.ToLower(schema.Title)
+			delete(s.schemas, key)
+			delete(s.stores, key)
+			if schema == s.currentSchema {
+				s.currentSchema = nil
+				s.store = newRecordStore()
+			}
+		}
+		s.stateMu.Unlock()
+		if !ok {
+			writeError(w, http.StatusNotFound, fmt.Sprintf("No schema registered for %q", entity))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeMethodNotAllowed(w, "Only GET and DELETE allowed", http.MethodGet, http.MethodDelete)
+	}
+}
+
+// adminData serves /__admin/data and /__admin/data/{entity}. Listings hold
+// only the stored records, never generated examples.
+func (s *Server) adminData(w http.ResponseWriter, r *http.Request, entity string) {
+	if r.Method != http.MethodGet && r.Method != http.MethodDelete {
+		writeMethodNotAllowed(w, "Only GET and DELETE allowed", http.MethodGet, http.MethodDelete)
+		return
+	}
+	s.stateMu.Lock()
+	var keys []string
+	if entity == "" {
+		keys = s.sortedSchemaKeys()
+	} else if schema, ok := s.lookupSchema(entity); ok {
+		keys = []string{strings.ToLower(schema.Title)}
+	}
+	data := make(map[string][]map[string]interface{}, len(keys))
+	var records []map[string]interface{}
+	for _, key := range keys {
+		schema := s.schemas[key]
+		if r.Method == http.MethodDelete {
+			s.stores[key] = s.newStoreForSchema(schema)
+			if schema == s.currentSchema {
+				s.store = s.stores[key]
+			}
+			continue
+		}
+		records = []map[string]interface{}{}
+		if store, ok := s.stores[key]; ok {
+			records = store.list()
+		}
+		data[entityName(schema)] = records
+	}
+	s.stateMu.Unlock()
+
+	switch {
+	case entity != "" && len(keys) == 0:
+		writeError(w, http.StatusNotFound, fmt.Sprintf("No schema registered for %q", entity))
+	case r.Method == http.MethodDelete:
+		w.WriteHeader(http.StatusNoContent)
+	case entity != "":
+		writeAdminJSON(w, records)
+	default:
+		writeAdminJSON(w, data)
+	}
+}
+
+// config describes the server's settings with the names of the flags that
+// set them.
+func (s *Server) config() map[string]interface{} {
+	c := s.settings
+	var seedValue interface{}
+	if c.seeded {
+		seedValue = c.seed
+	}
+	passthrough := ""
+	if s.upstream != nil {
+		passthrough = s.upstream.String()
+	}
+	return map[string]interface{}{
+		"base-path":           c.basePath,
+		"debug":               c.debugMode,
+		"warn-unknown-params": c.warnUnknownParams,
+		"strict-accept":       c.strictAccept,
+		"legacy-errors":       c.legacyErrors,
+		"welcome":             c.welcomeMessage,
+		"no-schema-status":    c.noSchemaStatus,
+		"loose-routes":        c.looseRoutes,
+		"strict-get":          c.strictGet,
+		"strict-put":          c.strictPut,
+		"soft-delete":         c.softDelete,
+		"reject-id-mismatch":  c.rejectIDMismatch,
+		"gen-mode":            c.genMode,
+		"list-size":           c.listSize,
+		"auth":                c.authRequired,
+		"array-length":        c.arrayLength,
+		"faker":               c.fakerMode,
+		"seed":                seedValue,
+		"async-create":        c.asyncCreateDelay.String(),
+		"validate":            c.validationMode,
+		"optional-fields":     c.optionalFields,
+		"id-start":            c.idStart,
+		"id-step":             c.idStep,
+		"latency":             s.latency.String(),
+		"latency-jitter":      s.latencyJitter.String(),
+		"html-errors":         s.htmlErrors,
+		"jsonapi":             s.jsonAPI,
+		"envelope":            s.envelope.data,
+		"envelope-meta":       s.envelope.meta,
+		"pagination-meta":     s.envelope.pagination,
+		"compress":            s.compression.enabled,
+		"compress-min-size":   s.compression.minSize,
+		"request-timeout":     s.requestTimeout.String(),
+		"error-rate":          s.chaos.errorRate,
+		"drop-rate":           s.chaos.dropRate,
+		"drip":                s.chaos.drip.String(),
+		"rate-limit":          s.rateLimit.limit,
+		"rate-window":         s.rateLimit.window.String(),
+		"rate-limit-by":       s.rateLimit.by,
+		"cors-origins":        s.cors.origins,
+		"cors-methods":        s.cors.methods,
+		"cors-headers":        s.cors.headers,
+		"cors-credentials":    s.cors.credentials,
+		"record":              s.recorder != nil,
+		"schema":              s.schemaFiles,
+		"sql":                 s.sqlFiles,
+		"openapi":             s.openAPIFiles,
+		"postman":             s.postmanFiles,
+		"fixtures":            s.fixtureFiles,
+		"data-dir":            s.dataDir,
+		"passthrough":         passthrough,
+	}
+}
+
+// writeAdminJSON writes v as the JSON body of an admin response.
+func writeAdminJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
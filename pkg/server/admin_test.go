@@ -0,0 +1,75 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAdminAPI(t *testing.T) {
+	s := NewServer(WithStrictGet(true), WithBasePath("/api"))
+	handler := s.Handler()
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rr
+	}
+	serve(http.MethodPost, "/api/upload", `{"title": "User", "properties": {"id": {"type": "integer"}, "name": {"type": "string"}}}`)
+	serve(http.MethodPost, "/api/upload", `{"title": "Tag", "properties": {"label": {"type": "string"}}}`)
+	serve(http.MethodPost, "/api/users", `{"name": "alice"}`)
+	serve(http.MethodPost, "/api/tags", `{"label": "go"}`)
+
+	rr := serve(http.MethodGet, "/api/__admin/data", "")
+	var data map[string][]map[string]interface{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &data); err != nil {
+		t.Fatalf("could not decode response: %v", err)
+	}
+	if len(data["users"]) != 1 || data["users"][0]["name"] != "alice" || len(data["tags"]) != 1 {
+		t.Errorf("handler returned unexpected data: got %v", rr.Body.String())
+	}
+
+	rr = serve(http.MethodGet, "/api/__admin/config", "")
+	var config map[string]interface{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &config); err != nil {
+		t.Fatalf("could not decode response: %v", err)
+	}
+	if config["strict-get"] != true || config["base-path"] != "/api" {
+		t.Errorf("handler returned unexpected config: got %v", rr.Body.String())
+	}
+
+	rr = serve(http.MethodGet, "/api/__admin/schemas/user", "")
+	if !strings.Contains(rr.Body.String(), `"title":"User"`) {
+		t.Errorf("handler returned unexpected schema: got %v", rr.Body.String())
+	}
+
+	cases := []struct {
+		method, path string
+		status       int
+	}{
+		{http.MethodDelete, "/api/__admin/data/users", http.StatusNoContent},
+		{http.MethodGet, "/api/users/1", http.StatusNotFound},
+		{http.MethodGet, "/api/tags/1", http.StatusOK},
+		{http.MethodDelete, "/api/__admin/schemas/tags", http.StatusNoContent},
+		{http.MethodGet, "/api/__admin/schemas/tags", http.StatusNotFound},
+		{http.MethodDelete, "/api/__admin/data/tags", http.StatusNotFound},
+		{http.MethodPost, "/api/__admin/data", http.StatusMethodNotAllowed},
+		{http.MethodGet, "/api/__admin/unknown", http.StatusNotFound},
+		{http.MethodGet, "/api/__admin", http.StatusOK},
+	}
+	for _, tc := range cases {
+		if rr := serve(tc.method, tc.path, ""); rr.Code != tc.status {
+			t.Errorf("%s %s: handler returned wrong status code: got %v want %v", tc.method, tc.path, rr.Code, tc.status)
+		}
+	}
+	if rr := serve(http.MethodPost, "/api/users", `{"name": "bob"}`); !strings.Contains(rr.Body.String(), `"id":1`) {
+		t.Errorf("emptying a store did not restart its ids: got %v", rr.Body.String())
+	}
+
+	serve(http.MethodDelete, "/api/__admin/schemas", "")
+	if entities := s.registeredEntities(); len(entities) != 0 {
+		t.Errorf("schemas remain after a reset: got %v", entities)
+	}
+}
@@ -0,0 +1,210 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// countSegment and aggregateSegment are the last path segments of the
+// aggregation routes, as in GET /users/count and GET /orders/aggregate.
+const (
+	countSegment     = "count"
+	aggregateSegment = "aggregate"
+)
+
+// groupByParam names the fields GET /{entity}/aggregate groups records by,
+// and metricParam the metrics it computes for each group.
+const (
+	groupByParam = "groupBy"
+	metricParam  = "metric"
+)
+
+// metricPattern matches a metric other than count, as in sum(total).
+var metricPattern = regexp.MustCompile(`^(sum|avg|min|max)\(([^()]+)\)$`)
+
+// metric is one value computed over each group: count, or op applied to
+// field.
+type metric struct {
+	name  string
+	op    string
+	field string
+}
+
+// aggregateGroup is one group of records and the metrics computed over it,
+// keyed by the metric names as requested.
+type aggregateGroup struct {
+	Group   map[string]interface{} `json:"group"`
+	Metrics map[string]interface{} `json:"metrics"`
+}
+
+// parseMetrics reads the metrics of an aggregate request: count, or
+// sum, avg, min or max of a property. sum and avg need a numeric one.
+// Without any, the request counts.
+func parseMetrics(values []string, schema *Schema) ([]metric, error) {
+	var metrics []metric
+	for _, value := range values {
+		for _, name := range strings.Split(value, ",") {
+			name = strings.TrimSpace(name)
+			if name == "count" {
+				metrics = append(metrics, metric{name: name, op: name})
+				continue
+			}
+			m := metricPattern.FindStringSubmatch(name)
+			if m == nil {
+				return nil, fmt.Errorf("unknown metric %q, expected count, sum(field), avg(field), min(field) or max(field)", name)
+			}
+			prop, ok := lookupField(schema, m[2])
+			if !ok {
+				return nil, fmt.Errorf("%s: unknown property %q", name, m[2])
+			}
+			if (m[1] == "sum" || m[1] == "avg") && prop.Type != "integer" && prop.Type != "number" {
+				return nil, fmt.Errorf("%s: %s is not numeric", name, m[2])
+			}
+			metrics = append(metrics, metric{name: name, op: m[1], field: m[2]})
+		}
+	}
+	if len(metrics) == 0 {
+		metrics = []metric{{name: "count", op: "count"}}
+	}
+	return metrics, nil
+}
+
+// compute returns the metric over list. Records missing the field are
+// skipped; with none left, min, max and avg are null.
+func (m metric) compute(list []map[string]interface{}) interface{} {
+	if m.op == "count" {
+		return len(list)
+	}
+	var sum float64
+	var n int
+	var best interface{}
+	for _, obj := range list {
+		value := fieldValue(obj, m.field)
+		if value == nil {
+			continue
+		}
+		switch m.op {
+		case "sum", "avg":
+			if x, ok := toFloat(value); ok {
+				sum += x
+				n++
+			}
+		case "min":
+			if best == nil || compareFields(value, best) < 0 {
+				best = value
+			}
+		case "max":
+			if best == nil || compareFields(value, best) > 0 {
+				best = value
+			}
+		}
+	}
+	switch m.op {
+	case "sum":
+		return sum
+	case "avg":
+		if n == 0 {
+			return nil
+		}
+		return sum / float64(n)
+	}
+	return best
+}
+
+// aggregate groups list by the values of the groupBy fields, ordered by
+// those values, and computes the metrics over each group. Without groupBy
+// the whole list is one group.
+func aggregate(list []map[string]interface{}, groupBy []string, metrics []metric) []aggregateGroup {
+	var keys [][]interface{}
+	members := make(map[string][]map[string]interface{})
+	for _, obj := range list {
+		key := make([]interface{}, len(groupBy))
+		for i, field := range groupBy {
+			key[i] = fieldValue(obj, field)
+		}
+		id, _ := json.Marshal(key)
+		if _, seen := members[string(id)]; !seen {
+			keys = append(keys, key)
+		}
+		members[string(id)] = append(members[string(id)], obj)
+	}
+	if len(groupBy) == 0 && len(keys) == 0 {
+		keys = append(keys, []interface{}{})
+	}
+	sort.SliceStable(keys, func(i, j int) bool {
+		for k := range groupBy {
+			if c := compareFields(keys[i][k], keys[j][k]); c != 0 {
+				return c < 0
+			}
+		}
+		return false
+	})
+
+	groups := make([]aggregateGroup, 0, len(keys))
+	for _, key := range keys {
+		id, _ := json.Marshal(key)
+		group := aggregateGroup{Group: map[string]interface{}{}, Metrics: map[string]interface{}{}}
+		for i, field := range groupBy {
+			group.Group[field] = key[i]
+		}
+		for _, m := range metrics {
+			group.Metrics[m.name] = m.compute(members[string(id)])
+		}
+		groups = append(groups, group)
+	}
+	return groups
+}
+
+// serveAggregate answers GET /{entity}/count with the number of records
+// and GET /{entity}/aggregate?groupBy=&metric= with metrics per group,
+// both over the records the list would return for the same filters and
+// search.
+func (s *Server) serveAggregate(w http.ResponseWriter, r *http.Request, schema *Schema, records *recordStore, route string) {
+	// groupBy and metric are not filters, even on a schema with such
+	// properties.
+	params := url.Values{}
+	for key, values := range r.URL.Query() {
+		if key != groupByParam && key != metricParam {
+			params[key] = values
+		}
+	}
+	query, err := parseListQuery(params, schema)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid query: "+err.Error())
+		return
+	}
+
+	var responseObj interface{}
+	list, total := s.listRecords(r, schema, records, pagination{}, query)
+	if route == countSegment {
+		responseObj = map[string]int{"count": total}
+	} else {
+		var groupBy []string
+		for _, value := range r.URL.Query()[groupByParam] {
+			for _, field := range strings.Split(value, ",") {
+				field = strings.TrimSpace(field)
+				if _, ok := lookupField(schema, field); !ok {
+					writeError(w, http.StatusBadRequest, fmt.Sprintf("Invalid query: %s: unknown property %q", groupByParam, field))
+					return
+				}
+				groupBy = append(groupBy, field)
+			}
+		}
+		metrics, err := parseMetrics(r.URL.Query()[metricParam], schema)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid query: "+metricParam+": "+err.Error())
+			return
+		}
+		responseObj = aggregate(list, groupBy, metrics)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(responseObj); err != nil {
+		log.Println("Error encoding response:", err)
+	}
+}
@@ -0,0 +1,56 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAggregate(t *testing.T) {
+	srv := NewServer()
+	schema, err := srv.loadSchema(strings.NewReader(`{"title": "Order", "properties": {"id": {"type": "integer"}, "status": {"type": "string"}, "total": {"type": "number"}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.currentSchema = schema
+	srv.store = srv.newStoreForSchema(schema)
+	srv.registerSchema(schema)
+	srv.stores["order"] = srv.store
+	for _, body := range []string{
+		`{"status": "paid", "total": 10}`,
+		`{"status": "open", "total": 5}`,
+		`{"status": "paid", "total": 30}`,
+		`{"status": "open", "total": 15}`,
+	} {
+		performRequest(t, srv.catchAllHandler, http.MethodPost, "/orders", []byte(body))
+	}
+
+	rr := performRequest(t, srv.catchAllHandler, http.MethodGet, "/orders/count?status=paid", nil)
+	if body := strings.TrimSpace(rr.Body.String()); body != `{"count":2}` {
+		t.Errorf("count returned wrong body: got %v", body)
+	}
+
+	rr = performRequest(t, srv.catchAllHandler, http.MethodGet, "/orders/aggregate?groupBy=status&metric=count,sum(total),avg(total),max(total)", nil)
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+	var groups []aggregateGroup
+	if err := json.Unmarshal(rr.Body.Bytes(), &groups); err != nil {
+		t.Fatalf("could not decode response: %v", err)
+	}
+	want := []aggregateGroup{
+		{Group: map[string]interface{}{"status": "open"}, Metrics: map[string]interface{}{"count": 2.0, "sum(total)": 20.0, "avg(total)": 10.0, "max(total)": 15.0}},
+		{Group: map[string]interface{}{"status": "paid"}, Metrics: map[string]interface{}{"count": 2.0, "sum(total)": 40.0, "avg(total)": 20.0, "max(total)": 30.0}},
+	}
+	if !reflect.DeepEqual(groups, want) {
+		t.Errorf("aggregate returned wrong groups: got %v want %v", groups, want)
+	}
+
+	for _, path := range []string{"/orders/aggregate?metric=sum(status)", "/orders/aggregate?metric=median(total)", "/orders/aggregate?groupBy=nothing"} {
+		if rr := performRequest(t, srv.catchAllHandler, http.MethodGet, path, nil); rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: handler returned wrong status code: got %v want %v", path, rr.Code, http.StatusBadRequest)
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Access levels of API keys: read keys may only make safe requests, write
+// keys any.
+const (
+	accessRead  = "read"
+	accessWrite = "write"
+)
+
+// apiKeyHeader carries an API key; Authorization: Bearer carries the same
+// keys as tokens.
+const apiKeyHeader = "X-API-Key"
+
+// apiKey is a key clients may authenticate with, as listed at
+// GET /__admin/keys. Its roles are checked against the access rules.
+type apiKey struct {
+	Key    string   `json:"key"`
+	Access string   `json:"access"`
+	Roles  []string `json:"roles,omitempty"`
+}
+
+// keyring holds the valid API keys. It is safe for concurrent use.
+type keyring struct {
+	mu   sync.Mutex
+	keys map[string]apiKey
+}
+
+// parseAPIKey reads a key as the -auth-keys flag gives it: the key, with
+// :read appended for a read-only one and a further :role|role for its
+// roles, e.g. root:write:admin.
+func parseAPIKey(raw string) (apiKey, error) {
+	key := apiKey{Key: strings.TrimSpace(raw), Access: accessWrite}
+	if parts := strings.SplitN(key.Key, ":", 3); len(parts) > 1 && (parts[1] == accessRead || parts[1] == accessWrite) {
+		key.Key, key.Access = parts[0], parts[1]
+		if len(parts) == 3 {
+			key.Roles = splitRoles(parts[2], "|")
+		}
+	}
+	if key.Key == "" {
+		return key, fmt.Errorf("API keys must not be empty")
+	}
+	return key, nil
+}
+
+// reset forgets every key.
+func (k *keyring) reset() {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.keys = make(map[string]apiKey)
+}
+
+// add stores key, reporting false if it is already known.
+func (k *keyring) add(key apiKey) bool {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if _, ok := k.keys[key.Key]; ok {
+		return false
+	}
+	k.keys[key.Key] = key
+	return true
+}
+
+// remove revokes key, reporting whether it was known.
+func (k *keyring) remove(key string) bool {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	_, ok := k.keys[key]
+	delete(k.keys, key)
+	return ok
+}
+
+// get returns the stored key, or false if it is not known.
+func (k *keyring) get(key string) (apiKey, bool) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	stored, ok := k.keys[key]
+	return stored, ok
+}
+
+// empty reports whether there are no keys.
+func (k *keyring) empty() bool {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return len(k.keys) == 0
+}
+
+// list returns the keys in order.
+func (k *keyring) list() []apiKey {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	list := make([]apiKey, 0, len(k.keys))
+	for _, key := range k.keys {
+		list = append(list, key)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Key < list[j].Key })
+	return list
+}
+
+// requestKey returns the key a request authenticates with, from X-API-Key
+// or an Authorization: Bearer header, and false if it sends neither. An
+// Authorization header of another scheme yields an error.
+func requestKey(r *http.Request) (string, bool, error) {
+	if key := r.Header.Get(apiKeyHeader); key != "" {
+		return key, true, nil
+	}
+	header := r.Header.Get("Authorization")
+	if header == "" {
+		return "", false, nil
+	}
+	scheme, token, _ := strings.Cut(header, " ")
+	if !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+		return "", true, fmt.Errorf("expected Authorization: Bearer {token}")
+	}
+	return strings.TrimSpace(token), true, nil
+}
+
+// rolesKey is the context key under which requireAuth passes on the roles
+// of an authenticated request, for handlers that apply the access rules
+// themselves.
+type rolesKey struct{}
+
+// requireAuth answers requests without a known key or a valid token from
+// /oauth/token 401, with a WWW-Authenticate challenge, and unsafe requests
+// made with read access 403, so clients' handling of both can be tested.
+// The admin API, the identity provider and /healthz stay open, as do CORS
+// preflights.
+func (s *Server) requireAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.isAdminPath(r.URL.Path) || s.isIdentityPath(r.URL.Path) || r.URL.Path == s.route("/healthz") || r.Method == http.MethodOptions {
+			next.ServeHTTP(w, r)
+			return
+		}
+		key, sent, err := requestKey(r)
+		switch {
+		case err != nil:
+			w.Header().Set("WWW-Authenticate", `Bearer realm="schema2api", error="invalid_request"`)
+			writeError(w, http.StatusUnauthorized, "Invalid credentials: "+err.Error())
+			return
+		case !sent:
+			w.Header().Set("WWW-Authenticate", `Bearer realm="schema2api"`)
+			writeError(w, http.StatusUnauthorized, "Missing credentials: send "+apiKeyHeader+" or Authorization: Bearer")
+			return
+		}
+		client, ok := s.apiKeys.get(key)
+		if !ok && strings.Count(key, ".") == 2 {
+			claims, err := s.verifyJWT(r, key)
+			if err != nil {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="schema2api", error="invalid_token"`)
+				writeError(w, http.StatusUnauthorized, "Invalid token: "+err.Error())
+				return
+			}
+			client, ok = apiKey{Access: tokenAccess(claims), Roles: tokenRoles(claims)}, true
+		}
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="schema2api", error="invalid_token"`)
+			writeError(w, http.StatusUnauthorized, "Invalid API key")
+			return
+		}
+		if client.Access == accessRead && r.Method != http.MethodGet && r.Method != http.MethodHead {
+			writeError(w, http.StatusForbidden, "The API key is read-only")
+			return
+		}
+		if rule, denied := s.deniedRule(r, client.Roles); denied {
+			writeError(w, http.StatusForbidden, rule.requirement())
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), rolesKey{}, client.Roles)))
+	})
+}
+
+// adminKeys serves /__admin/keys: GET lists the valid keys, POST adds one
+// from a {"key", "access", "roles"} body, generating the key when it is left out,
+// and DELETE revokes every key, or the one named by /__admin/keys/{key}.
+func (s *Server) adminKeys(w http.ResponseWriter, r *http.Request, key string) {
+	switch {
+	case r.Method == http.MethodGet && key == "":
+		writeAdminJSON(w, s.apiKeys.list())
+	case r.Method == http.MethodPost && key == "":
+		var body apiKey
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid JSON body: "+err.Error())
+			return
+		}
+		if body.Access == "" {
+			body.Access = accessWrite
+		}
+		if body.Access != accessRead && body.Access != accessWrite {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("access must be %s or %s, got %q", accessRead, accessWrite, body.Access))
+			return
+		}
+		if body.Key == "" {
+			random := make([]byte, 16)
+			rand.Read(random)
+			body.Key = hex.EncodeToString(random)
+		}
+		if !s.apiKeys.add(body) {
+			writeError(w, http.StatusConflict, "The API key already exists")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(body)
+	case r.Method == http.MethodDelete:
+		if key == "" {
+			s.apiKeys.reset()
+		} else if !s.apiKeys.remove(key) {
+			writeError(w, http.StatusNotFound, "No such API key")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case key == "":
+		writeMethodNotAllowed(w, "Only GET, POST and DELETE allowed", http.MethodGet, http.MethodPost, http.MethodDelete)
+	default:
+		writeMethodNotAllowed(w, "Only DELETE allowed", http.MethodDelete)
+	}
+}
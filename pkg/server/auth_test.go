@@ -0,0 +1,94 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAuth(t *testing.T) {
+	s := NewServer(WithAuth("secret", "viewer:read"))
+	handler := s.Handler()
+	s.currentSchema = createSampleSchema()
+	s.store = newRecordStore()
+	s.registerSchema(s.currentSchema)
+	s.stores["user"] = s.store
+
+	serve := func(method, path string, headers map[string]string, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+	user := `{"name": "Ada", "email": "ada@example.com"}`
+
+	tests := []struct {
+		name    string
+		method  string
+		headers map[string]string
+		want    int
+	}{
+		{"Missing", http.MethodGet, nil, http.StatusUnauthorized},
+		{"Unknown Key", http.MethodGet, map[string]string{"X-API-Key": "guess"}, http.StatusUnauthorized},
+		{"Other Scheme", http.MethodGet, map[string]string{"Authorization": "Basic c2VjcmV0"}, http.StatusUnauthorized},
+		{"API Key", http.MethodGet, map[string]string{"X-API-Key": "secret"}, http.StatusOK},
+		{"Bearer", http.MethodGet, map[string]string{"Authorization": "Bearer viewer"}, http.StatusOK},
+		{"Read Key Write", http.MethodPost, map[string]string{"X-API-Key": "viewer"}, http.StatusForbidden},
+		{"Write Key Write", http.MethodPost, map[string]string{"Authorization": "Bearer secret"}, http.StatusCreated},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rr := serve(tc.method, "/users", tc.headers, user)
+			if rr.Code != tc.want {
+				t.Errorf("handler returned wrong status code: got %v want %v: %s", rr.Code, tc.want, rr.Body.String())
+			}
+			if tc.want == http.StatusUnauthorized && !strings.HasPrefix(rr.Header().Get("WWW-Authenticate"), "Bearer") {
+				t.Errorf("401 lacks a Bearer challenge: got %q", rr.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+
+	for _, path := range []string{"/healthz", "/__admin/config"} {
+		if rr := serve(http.MethodGet, path, nil, ""); rr.Code != http.StatusOK {
+			t.Errorf("%s: handler returned wrong status code: got %v want %v", path, rr.Code, http.StatusOK)
+		}
+	}
+
+	rr := serve(http.MethodPost, "/__admin/keys", nil, `{"access": "read"}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("handler returned wrong status code: got %v want %v: %s", rr.Code, http.StatusCreated, rr.Body.String())
+	}
+	var added apiKey
+	if err := json.Unmarshal(rr.Body.Bytes(), &added); err != nil || added.Key == "" || added.Access != accessRead {
+		t.Fatalf("no key was generated: got %s", rr.Body.String())
+	}
+	if rr := serve(http.MethodGet, "/users", map[string]string{"X-API-Key": added.Key}, ""); rr.Code != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	if rr := serve(http.MethodPost, "/__admin/keys", nil, `{"key": "secret"}`); rr.Code != http.StatusConflict {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusConflict)
+	}
+
+	rr = serve(http.MethodGet, "/__admin/keys", nil, "")
+	var keys []apiKey
+	if err := json.Unmarshal(rr.Body.Bytes(), &keys); err != nil || len(keys) != 3 {
+		t.Errorf("wrong keys listed: got %s", rr.Body.String())
+	}
+
+	if rr := serve(http.MethodDelete, "/__admin/keys/secret", nil, ""); rr.Code != http.StatusNoContent {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusNoContent)
+	}
+	if rr := serve(http.MethodGet, "/users", map[string]string{"X-API-Key": "secret"}, ""); rr.Code != http.StatusUnauthorized {
+		t.Errorf("revoked key: handler returned wrong status code: got %v want %v", rr.Code, http.StatusUnauthorized)
+	}
+	if rr := serve(http.MethodDelete, "/__admin/keys/secret", nil, ""); rr.Code != http.StatusNotFound {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusNotFound)
+	}
+}
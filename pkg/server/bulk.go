@@ -0,0 +1,228 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// bulkSegment is the last path segment of the bulk create and update
+// route, as in POST /users/bulk.
+const bulkSegment = "bulk"
+
+// maxBulkItems caps how many records one bulk request may carry.
+const maxBulkItems = 1000
+
+// bulkResult reports the outcome of one item of a bulk request: the status
+// it would have answered on its own, with the record or the error.
+type bulkResult struct {
+	Index  int                    `json:"index"`
+	ID     interface{}            `json:"id,omitempty"`
+	Status int                    `json:"status"`
+	Record map[string]interface{} `json:"record,omitempty"`
+	Error  *Error                 `json:"error,omitempty"`
+}
+
+// bulkResponse is the body of a bulk request's response.
+type bulkResponse struct {
+	Succeeded int          `json:"succeeded"`
+	Failed    int          `json:"failed"`
+	Results   []bulkResult `json:"results"`
+}
+
+// bulkFailure returns the result of an item that failed with status.
+func bulkFailure(index int, id interface{}, status int, message string) bulkResult {
+	return bulkResult{Index: index, ID: id, Status: status, Error: &Error{Code: errorCode(status), Message: message}}
+}
+
+// bulkInvalid returns the result of an item that failed validation, unless
+// the validation mode only warns about errs.
+func (s *Server) bulkInvalid(index int, id interface{}, errs []fieldError) (bulkResult, bool) {
+	if len(errs) == 0 || s.validationMode == validateWarn {
+		return bulkResult{}, false
+	}
+	return bulkResult{Index: index, ID: id, Status: http.StatusBadRequest,
+		Error: &Error{Code: "validation_failed", Message: "Validation failed", Details: errs}}, true
+}
+
+// serveBulk answers POST /{entity}/bulk, creating each object of an array,
+// and PATCH /{entity}/bulk, setting the fields of each object on the record
+// its id names. Items succeed or fail on their own; see writeBulk.
+func (s *Server) serveBulk(w http.ResponseWriter, r *http.Request, schema *Schema, records *recordStore) {
+	var allowed []string
+	for _, method := range []string{http.MethodPost, http.MethodPatch} {
+		if schema.allowsMethod(method) {
+			allowed = append(allowed, method)
+		}
+	}
+	if len(allowed) == 0 {
+		writeNotFound(w, r)
+		return
+	}
+	if !containsString(allowed, r.Method) {
+		writeMethodNotAllowed(w, "Method not allowed for this route", allowed...)
+		return
+	}
+	var items []json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&items); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid JSON body: expected an array of objects: "+err.Error())
+		return
+	}
+	if len(items) > maxBulkItems {
+		writeError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("A bulk request carries at most %d items, got %d", maxBulkItems, len(items)))
+		return
+	}
+
+	results := make([]bulkResult, 0, len(items))
+	for i, item := range items {
+		var body map[string]interface{}
+		if err := json.Unmarshal(item, &body); err != nil || body == nil {
+			results = append(results, bulkFailure(i, nil, http.StatusBadRequest, "Invalid item: expected an object"))
+			continue
+		}
+		if r.Method == http.MethodPost {
+			results = append(results, s.bulkCreate(r, schema, records, i, body))
+		} else {
+			results = append(results, s.bulkUpdate(schema, records, i, body))
+		}
+	}
+	success := http.StatusOK
+	if r.Method == http.MethodPost {
+		success = http.StatusCreated
+	}
+	writeBulk(w, results, success)
+}
+
+// bulkCreate creates one item of POST /{entity}/bulk as POST /{entity}
+// would, except that creates are never asynchronous.
+func (s *Server) bulkCreate(r *http.Request, schema *Schema, records *recordStore, index int, body map[string]interface{}) bulkResult {
+	dropReadOnly(schema.Properties, body)
+	if result, failed := s.bulkInvalid(index, body[schema.idKey()], validateRecord(schema, schema.bodyRequired(), body, "", true)); failed {
+		return result
+	}
+	obj := s.dummyData(schema, s.newGenerator(r))
+	delete(obj, schema.idKey())
+	for key, value := range body {
+		obj[key] = value
+	}
+	if err := records.create(obj, schema.integerIDs()); err != nil {
+		status := http.StatusBadRequest
+		if err == errDuplicateID {
+			status = http.StatusConflict
+		}
+		return bulkFailure(index, body[schema.idKey()], status, err.Error())
+	}
+	return bulkResult{Index: index, ID: obj[schema.idKey()], Status: http.StatusCreated, Record: hideWriteOnly(schema, obj)}
+}
+
+// bulkUpdate changes the stored record named by the item's id as a plain
+// JSON PATCH would, $inc included.
+func (s *Server) bulkUpdate(schema *Schema, records *recordStore, index int, body map[string]interface{}) bulkResult {
+	raw, ok := body[schema.idKey()]
+	if !ok || raw == nil {
+		return bulkFailure(index, nil, http.StatusBadRequest, "Invalid item: missing "+schema.idKey())
+	}
+	delete(body, schema.idKey())
+	dropReadOnly(schema.Properties, body)
+	rawID := fmt.Sprint(raw)
+	if n, ok := raw.(float64); ok {
+		rawID = strconv.FormatFloat(n, 'f', -1, 64)
+	}
+	id, err := recordID(schema, rawID)
+	if err != nil {
+		return bulkFailure(index, raw, http.StatusBadRequest, err.Error())
+	}
+	increments, errs := splitIncrements(schema.Properties, body)
+	if len(errs) > 0 {
+		return bulkResult{Index: index, ID: id, Status: http.StatusUnprocessableEntity,
+			Error: &Error{Code: "invalid_increment", Message: "Invalid " + incOperator + " operation", Details: errs}}
+	}
+	if result, failed := s.bulkInvalid(index, id, validateRecord(schema, nil, body, "", false)); failed {
+		return result
+	}
+	obj, found, err := records.update(fmt.Sprint(id), func(obj map[string]interface{}) error {
+		if s.softDeleted(obj) {
+			return errSoftDeleted
+		}
+		for key, value := range body {
+			obj[key] = value
+		}
+		return applyIncrements(schema.Properties, obj, increments)
+	})
+	if !found || err == errSoftDeleted {
+		return bulkFailure(index, id, http.StatusNotFound, fmt.Sprintf("%s %v not found", schema.Title, id))
+	}
+	if err != nil {
+		return bulkFailure(index, id, http.StatusUnprocessableEntity, err.Error())
+	}
+	return bulkResult{Index: index, ID: id, Status: http.StatusOK, Record: hideWriteOnly(schema, obj)}
+}
+
+// bulkDeleteIDs returns the ids DELETE /{entity} deletes: the values of its
+// id query parameter, comma-separated or repeated.
+func bulkDeleteIDs(r *http.Request, schema *Schema) []string {
+	var ids []string
+	for _, value := range r.URL.Query()[schema.idKey()] {
+		for _, id := range strings.Split(value, ",") {
+			if id = strings.TrimSpace(id); id != "" {
+				ids = append(ids, id)
+			}
+		}
+	}
+	return ids
+}
+
+// serveBulkDelete answers DELETE /{entity}?id=1,2,3, deleting each record
+// as DELETE /{entity}/{id} would.
+func (s *Server) serveBulkDelete(w http.ResponseWriter, r *http.Request, schema *Schema, records *recordStore, ids []string) {
+	if len(ids) > maxBulkItems {
+		writeError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("A bulk request carries at most %d items, got %d", maxBulkItems, len(ids)))
+		return
+	}
+	results := make([]bulkResult, 0, len(ids))
+	for i, raw := range ids {
+		id, err := recordID(schema, raw)
+		if err != nil {
+			results = append(results, bulkFailure(i, raw, http.StatusBadRequest, err.Error()))
+			continue
+		}
+		key := fmt.Sprint(id)
+		var deleted bool
+		if _, stored := records.get(key); s.softDelete && stored {
+			deleted = s.softDeleteRecord(records, key)
+		} else {
+			deleted = records.delete(key)
+		}
+		if !deleted {
+			results = append(results, bulkFailure(i, id, http.StatusNotFound, fmt.Sprintf("%s %v not found", schema.Title, id)))
+			continue
+		}
+		results = append(results, bulkResult{Index: i, ID: id, Status: http.StatusNoContent})
+	}
+	writeBulk(w, results, http.StatusOK)
+}
+
+// writeBulk responds with the results of a bulk request: success when
+// every item succeeded, or 207 Multi-Status when some failed.
+func writeBulk(w http.ResponseWriter, results []bulkResult, success int) {
+	resp := bulkResponse{Results: results}
+	for _, result := range results {
+		if result.Error != nil {
+			resp.Failed++
+		} else {
+			resp.Succeeded++
+		}
+	}
+	status := success
+	if resp.Failed > 0 {
+		status = http.StatusMultiStatus
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Println("Error encoding response:", err)
+	}
+}
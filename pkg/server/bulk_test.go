@@ -0,0 +1,75 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestBulkOperations(t *testing.T) {
+	srv := NewServer()
+	srv.currentSchema = createSampleSchema()
+	srv.store = newRecordStore()
+	srv.registerSchema(srv.currentSchema)
+	srv.stores["user"] = srv.store
+	decode := func(t *testing.T, body []byte) bulkResponse {
+		t.Helper()
+		var resp bulkResponse
+		if err := json.Unmarshal(body, &resp); err != nil {
+			t.Fatalf("could not decode response: %v", err)
+		}
+		return resp
+	}
+
+	rr := performRequest(t, srv.catchAllHandler, http.MethodPost, "/users/bulk",
+		[]byte(`[{"name": "alice", "email": "a@example.com"}, {"name": "bob", "email": "b@example.com"}]`))
+	if status := rr.Code; status != http.StatusCreated {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusCreated)
+	}
+	if resp := decode(t, rr.Body.Bytes()); resp.Succeeded != 2 || resp.Results[1].Record["name"] != "bob" {
+		t.Errorf("bulk create returned wrong results: got %+v", resp)
+	}
+
+	cases := []struct {
+		name, method, path, body string
+		status                   int
+		statuses                 []int
+	}{
+		{"Create Partial", http.MethodPost, "/users/bulk", `[{"id": 1, "name": "carol", "email": "c@example.com"}, {"name": 7, "email": "d@example.com"}, "dave", {"name": "erin", "email": "e@example.com"}]`,
+			http.StatusMultiStatus, []int{http.StatusConflict, http.StatusBadRequest, http.StatusBadRequest, http.StatusCreated}},
+		{"Update", http.MethodPatch, "/users/bulk", `[{"id": 1, "name": "amy"}, {"id": 2, "email": "bo@example.com"}]`,
+			http.StatusOK, []int{http.StatusOK, http.StatusOK}},
+		{"Update Partial", http.MethodPatch, "/users/bulk", `[{"name": "x"}, {"id": "one"}, {"id": 99, "name": "x"}, {"id": 2, "name": "bo"}]`,
+			http.StatusMultiStatus, []int{http.StatusBadRequest, http.StatusBadRequest, http.StatusNotFound, http.StatusOK}},
+		{"Delete", http.MethodDelete, "/users?id=1,x&id=2", "",
+			http.StatusMultiStatus, []int{http.StatusNoContent, http.StatusBadRequest, http.StatusNoContent}},
+		{"Delete Again", http.MethodDelete, "/users?id=1", "", http.StatusMultiStatus, []int{http.StatusNotFound}},
+		{"Not An Array", http.MethodPost, "/users/bulk", `{"name": "x"}`, http.StatusBadRequest, nil},
+		{"Wrong Method", http.MethodGet, "/users/bulk", "", http.StatusMethodNotAllowed, nil},
+		{"Delete Without Ids", http.MethodDelete, "/users", "", http.StatusMethodNotAllowed, nil},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rr := performRequest(t, srv.catchAllHandler, tc.method, tc.path, []byte(tc.body))
+			if status := rr.Code; status != tc.status {
+				t.Fatalf("handler returned wrong status code: got %v want %v", status, tc.status)
+			}
+			if tc.statuses == nil {
+				return
+			}
+			resp := decode(t, rr.Body.Bytes())
+			if len(resp.Results) != len(tc.statuses) {
+				t.Fatalf("wrong number of results: got %d want %d", len(resp.Results), len(tc.statuses))
+			}
+			for i, result := range resp.Results {
+				if result.Index != i || result.Status != tc.statuses[i] {
+					t.Errorf("result %d: got status %v want %v", i, result.Status, tc.statuses[i])
+				}
+			}
+		})
+	}
+
+	if got := len(srv.store.list()); got != 1 {
+		t.Errorf("store kept wrong number of records: got %d want 1", got)
+	}
+}
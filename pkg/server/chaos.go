@@ -0,0 +1,170 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// chaosStatuses are the server errors -error-rate picks from.
+var chaosStatuses = []int{
+	http.StatusInternalServerError,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// dripChunkSize is how many bytes of a slow-drip response are written at a
+// time.
+const dripChunkSize = 64
+
+// defaultDripInterval paces "X-Mock-Fault: drip" when -drip is not set.
+const defaultDripInterval = 100 * time.Millisecond
+
+// chaosConfig holds the faults injected into a share of all requests.
+type chaosConfig struct {
+	// errorRate and dropRate are the probabilities, from 0 to 1, that a
+	// request is answered with a random 5xx error or that its connection
+	// is dropped without a response.
+	errorRate float64
+	dropRate  float64
+	// drip, when set, writes every response body dripChunkSize bytes at a
+	// time, waiting this long between chunks.
+	drip time.Duration
+}
+
+// withChaos injects faults so clients' timeout and retry logic can be
+// exercised. Besides the configured rates, each request can ask for a fault
+// with headers:
+//
+//	X-Mock-Delay: 500ms   wait before handling (a duration or milliseconds)
+//	X-Mock-Status: 503    answer with this status and an Error body
+//	X-Mock-Fault: drop    close the connection without a response
+//	X-Mock-Fault: drip    write the response slowly, chunk by chunk
+//
+// The admin API is never affected.
+func (s *Server) withChaos(cfg chaosConfig, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.isAdminPath(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if raw := r.Header.Get("X-Mock-Delay"); raw != "" {
+			delay, err := parseMockDelay(raw)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "Invalid X-Mock-Delay: "+err.Error())
+				return
+			}
+			timer := time.NewTimer(delay)
+			select {
+			case <-timer.C:
+			case <-r.Context().Done():
+				timer.Stop()
+				return
+			}
+		}
+
+		fault := strings.ToLower(strings.TrimSpace(r.Header.Get("X-Mock-Fault")))
+		if fault != "" && fault != "drop" && fault != "drip" {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("Invalid X-Mock-Fault %q: expected drop or drip", fault))
+			return
+		}
+		if fault == "drop" || (cfg.dropRate > 0 && rand.Float64() < cfg.dropRate) {
+			// The server closes the connection of a handler that aborts,
+			// without writing a response.
+			panic(http.ErrAbortHandler)
+		}
+
+		if raw := r.Header.Get("X-Mock-Status"); raw != "" {
+			status, err := strconv.Atoi(strings.TrimSpace(raw))
+			if err != nil || status < 200 || status > 599 {
+				writeError(w, http.StatusBadRequest, fmt.Sprintf("Invalid X-Mock-Status %q: expected a status code from 200 to 599", raw))
+				return
+			}
+			writeError(w, status, "Injected by X-Mock-Status")
+			return
+		}
+		if cfg.errorRate > 0 && rand.Float64() < cfg.errorRate {
+			writeError(w, chaosStatuses[rand.Intn(len(chaosStatuses))], "Injected by -error-rate")
+			return
+		}
+
+		interval := cfg.drip
+		if fault == "drip" && interval == 0 {
+			interval = defaultDripInterval
+		}
+		if interval > 0 {
+			w = &dripWriter{ResponseWriter: w, interval: interval, ctx: r.Context()}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// parseMockDelay reads an X-Mock-Delay value: a Go duration such as
+// "1.5s", or a bare number of milliseconds.
+func parseMockDelay(raw string) (time.Duration, error) {
+	raw = strings.TrimSpace(raw)
+	if _, err := strconv.Atoi(raw); err == nil {
+		raw += "ms"
+	}
+	delay, err := time.ParseDuration(raw)
+	if err != nil {
+		return 0, err
+	}
+	if delay < 0 {
+		return 0, fmt.Errorf("delay must not be negative")
+	}
+	return delay, nil
+}
+
+// dripWriter writes a response body dripChunkSize bytes at a time, flushing
+// each chunk and pausing between them. It gives up once the client goes
+// away.
+type dripWriter struct {
+	http.ResponseWriter
+	interval time.Duration
+	ctx      context.Context
+	started  bool
+}
+
+func (d *dripWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		if d.started {
+			timer := time.NewTimer(d.interval)
+			select {
+			case <-timer.C:
+			case <-d.ctx.Done():
+				timer.Stop()
+				return written, d.ctx.Err()
+			}
+		}
+		d.started = true
+		chunk := p
+		if len(chunk) > dripChunkSize {
+			chunk = chunk[:dripChunkSize]
+		}
+		n, err := d.ResponseWriter.Write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		if f, ok := d.ResponseWriter.(http.Flusher); ok {
+			f.Flush()
+		}
+		p = p[len(chunk):]
+	}
+	return written, nil
+}
+
+// Flush lets streaming handlers flush through the drip.
+func (d *dripWriter) Flush() {
+	if f, ok := d.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,98 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChaosHeaders(t *testing.T) {
+	srv := NewServer()
+	handler := srv.withChaos(chaosConfig{}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Repeat("x", 3*dripChunkSize)))
+	}))
+
+	cases := []struct {
+		name   string
+		header string
+		value  string
+		status int
+	}{
+		{"Status", "X-Mock-Status", "503", http.StatusServiceUnavailable},
+		{"Bad Status", "X-Mock-Status", "99", http.StatusBadRequest},
+		{"Bad Delay", "X-Mock-Delay", "soon", http.StatusBadRequest},
+		{"Bad Fault", "X-Mock-Fault", "explode", http.StatusBadRequest},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/users", nil)
+			req.Header.Set(tc.header, tc.value)
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+			if status := rr.Code; status != tc.status {
+				t.Errorf("handler returned wrong status code: got %v want %v", status, tc.status)
+			}
+		})
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/users", nil)
+	req.Header.Set("X-Mock-Delay", "20")
+	start := time.Now()
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("X-Mock-Delay was not applied: responded after %v", elapsed)
+	}
+
+	// The admin API ignores the headers.
+	req = httptest.NewRequest(http.MethodGet, "/__admin/requests", nil)
+	req.Header.Set("X-Mock-Status", "500")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+}
+
+func TestChaosRates(t *testing.T) {
+	srv := NewServer()
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	handler := srv.withChaos(chaosConfig{errorRate: 1}, ok)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/users", nil))
+	if status := rr.Code; status < 500 || !strings.Contains(rr.Body.String(), "-error-rate") {
+		t.Errorf("handler did not inject an error: got %v %v", status, rr.Body.String())
+	}
+
+	ts := httptest.NewServer(srv.withChaos(chaosConfig{dropRate: 1}, ok))
+	defer ts.Close()
+	if resp, err := http.Get(ts.URL + "/users"); err == nil {
+		resp.Body.Close()
+		t.Errorf("connection was not dropped: got status %v", resp.StatusCode)
+	}
+}
+
+func TestSlowDrip(t *testing.T) {
+	srv := NewServer()
+	body := strings.Repeat("x", 3*dripChunkSize)
+	ts := httptest.NewServer(srv.withChaos(chaosConfig{drip: 20 * time.Millisecond}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	})))
+	defer ts.Close()
+
+	start := time.Now()
+	resp, err := http.Get(ts.URL + "/users")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	got, _ := io.ReadAll(resp.Body)
+	if string(got) != body {
+		t.Errorf("drip changed the body: got %d bytes want %d", len(got), len(body))
+	}
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("response was not dripped: arrived after %v", elapsed)
+	}
+}
@@ -0,0 +1,185 @@
+package server
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// compressionEncodings are the content codings responses can be compressed
+// with, in the order ties between equally preferred codings are broken.
+var compressionEncodings = []string{"gzip", "deflate"}
+
+// compressionConfig controls response compression.
+type compressionConfig struct {
+	enabled bool
+	// minSize is the body size, in bytes, from which responses are
+	// compressed. Smaller bodies are sent as they are.
+	minSize int
+}
+
+// defaultCompression leaves responses uncompressed, compressing bodies of
+// 1 KiB or more once enabled.
+var defaultCompression = compressionConfig{minSize: 1024}
+
+// preferredEncoding returns the coding of compressionEncodings an
+// Accept-Encoding header prefers, or "" if it accepts none of them. A "*"
+// stands for every coding the header does not name.
+func preferredEncoding(accept string) string {
+	quality := make(map[string]float64)
+	wildcard := -1.0
+	for _, part := range strings.Split(accept, ",") {
+		coding, params, _ := strings.Cut(part, ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		q := 1.0
+		if name, value, ok := strings.Cut(strings.TrimSpace(params), "="); ok && strings.TrimSpace(name) == "q" {
+			parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if coding == "*" {
+			wildcard = q
+		} else if coding != "" {
+			quality[coding] = q
+		}
+	}
+
+	best, bestQ := "", 0.0
+	for _, coding := range compressionEncodings {
+		q, ok := quality[coding]
+		if !ok {
+			q = wildcard
+		}
+		if q > bestQ {
+			best, bestQ = coding, q
+		}
+	}
+	return best
+}
+
+// withCompression compresses responses with the coding the request's
+// Accept-Encoding prefers: gzip or deflate. Bodies under cfg.minSize,
+// responses to HEAD and responses already carrying a Content-Encoding are
+// sent as they are. Streams are compressed as they are flushed.
+func withCompression(cfg compressionConfig, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		encoding := preferredEncoding(r.Header.Get("Accept-Encoding"))
+		if encoding == "" || r.Method == http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+		cw := &compressWriter{ResponseWriter: w, encoding: encoding, minSize: cfg.minSize}
+		defer cw.finish()
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// flushWriteCloser is a compressing writer that can flush what it holds.
+type flushWriteCloser interface {
+	io.WriteCloser
+	Flush() error
+}
+
+// compressWriter holds the start of a response body until it reaches the
+// size threshold, then compresses the rest on the fly.
+type compressWriter struct {
+	http.ResponseWriter
+	encoding string
+	minSize  int
+	status   int
+	held     bytes.Buffer
+	// encoder compresses the body once started; plain marks a response
+	// sent uncompressed.
+	encoder flushWriteCloser
+	plain   bool
+}
+
+func (c *compressWriter) WriteHeader(status int) {
+	if c.status == 0 {
+		c.status = status
+	}
+}
+
+func (c *compressWriter) Write(p []byte) (int, error) {
+	if c.status == 0 {
+		c.status = http.StatusOK
+	}
+	switch {
+	case c.plain:
+		return c.ResponseWriter.Write(p)
+	case c.encoder != nil:
+		return c.encoder.Write(p)
+	}
+	c.held.Write(p)
+	if c.held.Len() >= c.minSize {
+		if err := c.start(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Flush starts compressing a streamed body however short it is so far,
+// and flushes the compressed bytes to the client.
+func (c *compressWriter) Flush() {
+	if c.encoder == nil && !c.plain {
+		if c.status == 0 {
+			c.status = http.StatusOK
+		}
+		c.start(true)
+	}
+	if c.encoder != nil {
+		c.encoder.Flush()
+	}
+	if f, ok := c.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// start writes the status and the held body, compressed if compress is set
+// and the response can have a compressed body.
+func (c *compressWriter) start(compress bool) error {
+	header := c.Header()
+	if header.Get("Content-Encoding") != "" || c.status < 200 || c.status == http.StatusNoContent || c.status == http.StatusNotModified {
+		compress = false
+	}
+	if !compress {
+		c.plain = true
+		c.ResponseWriter.WriteHeader(c.status)
+		_, err := c.ResponseWriter.Write(c.held.Bytes())
+		return err
+	}
+
+	header.Set("Content-Encoding", c.encoding)
+	header.Del("Content-Length")
+	c.ResponseWriter.WriteHeader(c.status)
+	switch c.encoding {
+	case "gzip":
+		c.encoder = gzip.NewWriter(c.ResponseWriter)
+	default:
+		c.encoder, _ = flate.NewWriter(c.ResponseWriter, flate.DefaultCompression)
+	}
+	_, err := c.encoder.Write(c.held.Bytes())
+	return err
+}
+
+// finish sends a response that stayed under the threshold as it is, or
+// ends the compressed body.
+func (c *compressWriter) finish() {
+	switch {
+	case c.encoder != nil:
+		c.encoder.Close()
+	case !c.plain:
+		if c.status == 0 {
+			c.status = http.StatusOK
+		}
+		c.start(false)
+	}
+}
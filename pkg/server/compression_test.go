@@ -0,0 +1,80 @@
+package server
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPreferredEncoding(t *testing.T) {
+	cases := []struct {
+		accept, want string
+	}{
+		{"", ""},
+		{"identity", ""},
+		{"gzip, deflate, br", "gzip"},
+		{"deflate;q=1.0, gzip;q=0.8", "deflate"},
+		{"br", ""},
+		{"deflate", "deflate"},
+		{"gzip;q=0, *", "deflate"},
+		{"*;q=0", ""},
+	}
+	for _, tc := range cases {
+		if got := preferredEncoding(tc.accept); got != tc.want {
+			t.Errorf("preferredEncoding(%q) = %q, want %q", tc.accept, got, tc.want)
+		}
+	}
+}
+
+func TestCompression(t *testing.T) {
+	serve := func(s *Server, path, encoding string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Accept-Encoding", encoding)
+		rr := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rr, req)
+		return rr
+	}
+	s := NewServer(WithCompression(true), WithCompressionMinSize(256), WithListSize(20))
+	s.currentSchema = createSampleSchema()
+	s.store = newRecordStore()
+	s.registerSchema(s.currentSchema)
+	s.stores["user"] = s.store
+
+	rr := serve(s, "/users", "gzip")
+	if rr.Header().Get("Content-Encoding") != "gzip" || rr.Header().Get("Vary") == "" {
+		t.Fatalf("response was not gzipped: got %v", rr.Header())
+	}
+	zr, err := gzip.NewReader(rr.Body)
+	if err != nil {
+		t.Fatalf("could not read gzip body: %v", err)
+	}
+	var list []map[string]interface{}
+	if err := json.NewDecoder(zr).Decode(&list); err != nil || len(list) != 20 {
+		t.Errorf("gzip body did not decode to the list: %v", err)
+	}
+
+	plain := serve(s, "/users", "").Body.Bytes()
+	rr = serve(s, "/users", "deflate")
+	body, err := io.ReadAll(flate.NewReader(rr.Body))
+	if rr.Header().Get("Content-Encoding") != "deflate" || err != nil || !bytes.Equal(body, plain) {
+		t.Errorf("deflate body did not decode to the plain body: %v", err)
+	}
+
+	// Bodies under the threshold are sent as they are.
+	if rr := serve(s, "/users/1", "gzip"); rr.Header().Get("Content-Encoding") != "" || !strings.HasPrefix(rr.Body.String(), "{") {
+		t.Errorf("small response was compressed: got %v", rr.Header())
+	}
+	if rr := serve(NewServer(WithListSize(20)), "/users", "gzip"); rr.Header().Get("Content-Encoding") != "" {
+		t.Errorf("response was compressed without -compress: got %v", rr.Header())
+	}
+	if _, err := New(WithCompressionMinSize(-1)); err == nil {
+		t.Errorf("New accepted a negative compression minimum size")
+	}
+}
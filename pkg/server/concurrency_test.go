@@ -0,0 +1,164 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// TestConcurrentAccess exercises the handlers from many goroutines at once.
+// Run it with -race to catch unsynchronized access to the shared state.
+func TestConcurrentAccess(t *testing.T) {
+	srv := NewServer()
+	schemaJSON, _ := json.Marshal(createSampleSchema())
+
+	t.Run("Uploads During Requests", func(t *testing.T) {
+		if rr := performRequest(t, srv.uploadHandler, http.MethodPost, "/upload", schemaJSON); rr.Code != http.StatusOK {
+			t.Fatalf("initial upload failed: %v", rr.Body.String())
+		}
+
+		var wg sync.WaitGroup
+		errs := make(chan string, 1000)
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				for j := 0; j < 20; j++ {
+					var rr *httptest.ResponseRecorder
+					switch (i + j) % 5 {
+					case 0:
+						rr = performRequest(t, srv.uploadHandler, http.MethodPost, "/upload", schemaJSON)
+					case 1:
+						rr = performRequest(t, srv.catchAllHandler, http.MethodPost, "/users", []byte(`{"name":"c","email":"c@example.com"}`))
+					case 2:
+						rr = performRequest(t, srv.catchAllHandler, http.MethodGet, "/users", nil)
+					case 3:
+						rr = performRequest(t, srv.catchAllHandler, http.MethodGet, fmt.Sprintf("/users/%d", j), nil)
+					case 4:
+						rr = performRequest(t, srv.catchAllHandler, http.MethodDelete, fmt.Sprintf("/users/%d", j), nil)
+					}
+					// A record deleted by another worker is gone for
+					// later reads and deletes.
+					status := rr.Code
+					if status == http.StatusNotFound && (i+j)%5 >= 3 {
+						continue
+					}
+					if status >= http.StatusMultipleChoices {
+						errs <- fmt.Sprintf("request %d/%d returned status %d", i, j, status)
+					}
+				}
+			}(i)
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			t.Error(err)
+		}
+	})
+
+	t.Run("Concurrent Creates And Deletes", func(t *testing.T) {
+		if rr := performRequest(t, srv.uploadHandler, http.MethodPost, "/upload", schemaJSON); rr.Code != http.StatusOK {
+			t.Fatalf("upload failed: %v", rr.Body.String())
+		}
+
+		const workers, perWorker = 25, 40
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		ids := make(map[int]bool)
+		for i := 0; i < workers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < perWorker; j++ {
+					rr := performRequest(t, srv.catchAllHandler, http.MethodPost, "/users", []byte(`{"name":"c","email":"c@example.com"}`))
+					var obj struct {
+						ID int `json:"id"`
+					}
+					if err := json.Unmarshal(rr.Body.Bytes(), &obj); err != nil {
+						t.Errorf("could not decode create response: %v", err)
+						return
+					}
+					mu.Lock()
+					if ids[obj.ID] {
+						t.Errorf("id %d was assigned twice", obj.ID)
+					}
+					ids[obj.ID] = true
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+
+		_, records := srv.activeState()
+		if got, want := records.len(), workers*perWorker; got != want {
+			t.Fatalf("store lost writes: got %v records want %v", got, want)
+		}
+
+		// Delete the even ids while reading the odd ones.
+		for id := range ids {
+			wg.Add(1)
+			go func(id int) {
+				defer wg.Done()
+				if id%2 == 0 {
+					performRequest(t, srv.catchAllHandler, http.MethodDelete, fmt.Sprintf("/users/%d", id), nil)
+					return
+				}
+				rr := performRequest(t, srv.catchAllHandler, http.MethodGet, fmt.Sprintf("/users/%d", id), nil)
+				if rr.Code != http.StatusOK {
+					t.Errorf("GET /users/%d returned status %d", id, rr.Code)
+				}
+			}(id)
+		}
+		wg.Wait()
+
+		if got, want := records.len(), workers*perWorker/2; got != want {
+			t.Errorf("wrong number of records after deletes: got %v want %v", got, want)
+		}
+	})
+
+	t.Run("Registry Readers During Uploads", func(t *testing.T) {
+		product := []byte(`{"title":"Product","type":"object","properties":{"id":{"type":"integer"},"price":{"type":"number"}}}`)
+		if rr := performRequest(t, srv.uploadHandler, http.MethodPost, "/upload", product); rr.Code != http.StatusOK {
+			t.Fatalf("upload failed: %v", rr.Body.String())
+		}
+		readers := []struct {
+			handler http.HandlerFunc
+			path    string
+		}{
+			{srv.entitiesHandler, "/entities"},
+			{srv.openAPIHandler, "/openapi.json"},
+			{srv.openAPIHandler, "/openapi.yaml"},
+			{srv.healthHandler, "/healthz"},
+			{srv.catchAllHandler, "/"},
+			{srv.catchAllHandler, "/products"},
+		}
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				for j := 0; j < 20; j++ {
+					if (i+j)%4 == 0 {
+						body := schemaJSON
+						if j%2 == 0 {
+							body = product
+						}
+						performRequest(t, srv.uploadHandler, http.MethodPost, "/upload", body)
+						continue
+					}
+					reader := readers[(i+j)%len(readers)]
+					if rr := performRequest(t, reader.handler, http.MethodGet, reader.path, nil); rr.Code != http.StatusOK {
+						t.Errorf("GET %s returned status %d", reader.path, rr.Code)
+					}
+				}
+			}(i)
+		}
+		wg.Wait()
+	})
+
+	srv.resetState()
+}
@@ -0,0 +1,324 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"regexp/syntax"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// patterns caches compiled pattern keywords, since the same schema's
+// patterns are matched and generated on every request.
+var patterns sync.Map
+
+// compilePattern compiles a pattern keyword. Patterns use Go's RE2 syntax,
+// which covers the ECMA-262 subset schemas typically use but has no
+// lookaround or backreferences.
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	if re, ok := patterns.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	patterns.Store(pattern, re)
+	return re, nil
+}
+
+// checkNumber reports how n violates the numeric constraints of prop.
+func checkNumber(prop Property, n float64) []string {
+	format := func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+	var problems []string
+	if prop.Minimum != nil && n < *prop.Minimum {
+		problems = append(problems, fmt.Sprintf("value %s is less than the minimum %s", format(n), format(*prop.Minimum)))
+	}
+	if prop.ExclusiveMinimum != nil && n <= *prop.ExclusiveMinimum {
+		problems = append(problems, fmt.Sprintf("value %s must be greater than %s", format(n), format(*prop.ExclusiveMinimum)))
+	}
+	if prop.Maximum != nil && n > *prop.Maximum {
+		problems = append(problems, fmt.Sprintf("value %s is greater than the maximum %s", format(n), format(*prop.Maximum)))
+	}
+	if prop.ExclusiveMaximum != nil && n >= *prop.ExclusiveMaximum {
+		problems = append(problems, fmt.Sprintf("value %s must be less than %s", format(n), format(*prop.ExclusiveMaximum)))
+	}
+	if prop.MultipleOf != nil {
+		// Allow for binary rounding, e.g. 0.3 is not exactly 3 * 0.1.
+		q := n / *prop.MultipleOf
+		if math.Abs(q-math.Round(q)) > 1e-9 {
+			problems = append(problems, fmt.Sprintf("value %s is not a multiple of %s", format(n), format(*prop.MultipleOf)))
+		}
+	}
+	return problems
+}
+
+// checkString reports how s violates the length and pattern constraints
+// of prop.
+func checkString(prop Property, s string) []string {
+	var problems []string
+	length := utf8.RuneCountInString(s)
+	if prop.MinLength != nil && length < *prop.MinLength {
+		problems = append(problems, fmt.Sprintf("expected at least %d characters, got %d", *prop.MinLength, length))
+	}
+	if prop.MaxLength != nil && length > *prop.MaxLength {
+		problems = append(problems, fmt.Sprintf("expected at most %d characters, got %d", *prop.MaxLength, length))
+	}
+	if prop.Pattern != "" {
+		if re, err := compilePattern(prop.Pattern); err == nil && !re.MatchString(s) {
+			problems = append(problems, fmt.Sprintf("value does not match the pattern %s", prop.Pattern))
+		}
+	}
+	return problems
+}
+
+// hasNumberConstraints reports whether prop restricts numeric values.
+func hasNumberConstraints(prop Property) bool {
+	return prop.Minimum != nil || prop.Maximum != nil || prop.ExclusiveMinimum != nil || prop.ExclusiveMaximum != nil || prop.MultipleOf != nil
+}
+
+// hasStringConstraints reports whether prop restricts string values.
+func hasStringConstraints(prop Property) bool {
+	return prop.MinLength != nil || prop.MaxLength != nil || prop.Pattern != ""
+}
+
+// numberValue generates a value for an integer or number property within
+// its bounds and multipleOf. n selects the value like it does for other
+// types; when the unconstrained value falls outside the range, values
+// count up from the lower bound (or down from the upper one).
+func numberValue(prop Property, n int) interface{} {
+	integer := prop.Type == "integer"
+	i := n
+	if i < 1 {
+		i = 1
+	}
+
+	step := 0.0
+	if prop.MultipleOf != nil {
+		step = *prop.MultipleOf
+	} else if integer {
+		step = 1
+	}
+	if step > 0 {
+		// Work in multiples of step: k*step for whole k in [lo, hi].
+		lo, hi := multipleRange(prop, step)
+		k := float64(i)
+		switch {
+		case k >= lo && k <= hi:
+		case !math.IsInf(lo, -1) && !math.IsInf(hi, 1):
+			width := hi - lo + 1
+			k = lo + math.Mod(math.Mod(k-lo, width)+width, width)
+		case !math.IsInf(lo, -1):
+			k = lo + float64(i-1)
+		default:
+			k = hi - float64(i-1)
+		}
+		if integer {
+			return int(k * step)
+		}
+		return tidyFloat(k * step)
+	}
+
+	// Floating-point formats get a fractional value so clients see a
+	// real decimal.
+	fraction := 0.0
+	if prop.Format == "float" || prop.Format == "double" {
+		fraction = 0.5
+	}
+	v := float64(n) + fraction
+	if len(checkNumber(prop, v)) == 0 {
+		return v
+	}
+	lo, hasLo := lowerBound(prop)
+	hi, hasHi := upperBound(prop)
+	switch {
+	case hasLo && hasHi:
+		// Strictly inside the range, so exclusive bounds hold too.
+		return tidyFloat(lo + (hi-lo)*float64(i%10+1)/11)
+	case hasLo:
+		return lo + float64(i) + fraction
+	default:
+		return hi - float64(i) - fraction
+	}
+}
+
+// lowerBound returns the tighter of minimum and exclusiveMinimum.
+func lowerBound(prop Property) (float64, bool) {
+	switch {
+	case prop.Minimum != nil && prop.ExclusiveMinimum != nil:
+		return math.Max(*prop.Minimum, *prop.ExclusiveMinimum), true
+	case prop.Minimum != nil:
+		return *prop.Minimum, true
+	case prop.ExclusiveMinimum != nil:
+		return *prop.ExclusiveMinimum, true
+	}
+	return 0, false
+}
+
+// upperBound returns the tighter of maximum and exclusiveMaximum.
+func upperBound(prop Property) (float64, bool) {
+	switch {
+	case prop.Maximum != nil && prop.ExclusiveMaximum != nil:
+		return math.Min(*prop.Maximum, *prop.ExclusiveMaximum), true
+	case prop.Maximum != nil:
+		return *prop.Maximum, true
+	case prop.ExclusiveMaximum != nil:
+		return *prop.ExclusiveMaximum, true
+	}
+	return 0, false
+}
+
+// multipleRange returns the smallest and largest k for which k*step is
+// within prop's bounds, infinite when unbounded.
+func multipleRange(prop Property, step float64) (lo, hi float64) {
+	lo, hi = math.Inf(-1), math.Inf(1)
+	if prop.Minimum != nil {
+		lo = math.Ceil(*prop.Minimum / step)
+	}
+	if prop.ExclusiveMinimum != nil {
+		k := math.Ceil(*prop.ExclusiveMinimum / step)
+		if k*step <= *prop.ExclusiveMinimum {
+			k++
+		}
+		lo = math.Max(lo, k)
+	}
+	if prop.Maximum != nil {
+		hi = math.Floor(*prop.Maximum / step)
+	}
+	if prop.ExclusiveMaximum != nil {
+		k := math.Floor(*prop.ExclusiveMaximum / step)
+		if k*step >= *prop.ExclusiveMaximum {
+			k--
+		}
+		hi = math.Min(hi, k)
+	}
+	return lo, hi
+}
+
+// tidyFloat rounds away binary noise such as 0.30000000000000004.
+func tidyFloat(v float64) float64 {
+	tidy, _ := strconv.ParseFloat(strconv.FormatFloat(v, 'g', 12, 64), 64)
+	return tidy
+}
+
+// fitString adjusts a generated string to prop's length and pattern. s is
+// kept when it already fits; otherwise it is padded or cut to length, or
+// replaced by a string generated from the pattern. Strings with a format
+// are returned as-is, since cutting them would break the format.
+func fitString(prop Property, s string, n int) string {
+	if prop.Format != "" || len(checkString(prop, s)) == 0 {
+		return s
+	}
+	if prop.Pattern != "" {
+		return patternString(prop, n)
+	}
+	if prop.MinLength != nil {
+		if short := *prop.MinLength - utf8.RuneCountInString(s); short > 0 {
+			s += strings.Repeat("x", short)
+		}
+	}
+	if prop.MaxLength != nil && utf8.RuneCountInString(s) > *prop.MaxLength {
+		s = string([]rune(s)[:*prop.MaxLength])
+	}
+	return s
+}
+
+// patternString generates a string matching prop's pattern and length.
+// Repetitions are tried from the fewest allowed upwards until the length
+// fits; n picks among alternatives and character classes, falling back to
+// the first choice. It returns "" when nothing fits, which upload
+// validation rules out.
+func patternString(prop Property, n int) string {
+	parsed, err := syntax.Parse(prop.Pattern, syntax.Perl)
+	if err != nil {
+		return ""
+	}
+	limit := 32
+	if prop.MinLength != nil && *prop.MinLength >= limit {
+		limit = *prop.MinLength + 1
+	}
+	for _, choice := range []int{n, 0} {
+		for reps := 0; reps <= limit; reps++ {
+			var b strings.Builder
+			writePattern(&b, parsed, choice, reps)
+			s := b.String()
+			if len(checkString(prop, s)) == 0 {
+				return s
+			}
+			// An unanchored pattern still matches with padding after it.
+			if prop.MinLength != nil {
+				if short := *prop.MinLength - utf8.RuneCountInString(s); short > 0 {
+					if padded := s + strings.Repeat("x", short); len(checkString(prop, padded)) == 0 {
+						return padded
+					}
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// writePattern writes a string matching re, repeating each unbounded
+// subexpression reps times within its limits.
+func writePattern(b *strings.Builder, re *syntax.Regexp, choice, reps int) {
+	repeat := func(sub *syntax.Regexp, min, max int) {
+		count := reps
+		if count < min {
+			count = min
+		}
+		if max >= 0 && count > max {
+			count = max
+		}
+		for i := 0; i < count; i++ {
+			writePattern(b, sub, choice+i, reps)
+		}
+	}
+	switch re.Op {
+	case syntax.OpLiteral:
+		b.WriteString(string(re.Rune))
+	case syntax.OpCharClass:
+		b.WriteRune(classRune(re.Rune, choice))
+	case syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		b.WriteRune(rune('a' + choice%26))
+	case syntax.OpCapture:
+		writePattern(b, re.Sub[0], choice, reps)
+	case syntax.OpStar:
+		repeat(re.Sub[0], 0, -1)
+	case syntax.OpPlus:
+		repeat(re.Sub[0], 1, -1)
+	case syntax.OpQuest:
+		repeat(re.Sub[0], 0, 1)
+	case syntax.OpRepeat:
+		repeat(re.Sub[0], re.Min, re.Max)
+	case syntax.OpConcat:
+		for _, sub := range re.Sub {
+			writePattern(b, sub, choice, reps)
+		}
+	case syntax.OpAlternate:
+		writePattern(b, re.Sub[choice%len(re.Sub)], choice, reps)
+	}
+	// Anchors, boundaries and empty matches produce no text.
+}
+
+// classRune picks a rune from a character class given as ranges, preferring
+// printable ASCII so generated values stay readable.
+func classRune(ranges []rune, choice int) rune {
+	var printable []rune
+	for i := 0; i+1 < len(ranges); i += 2 {
+		for r := ranges[i]; r <= ranges[i+1] && r <= '~'; r++ {
+			if r >= ' ' {
+				printable = append(printable, r)
+			}
+		}
+	}
+	if len(printable) > 0 {
+		return printable[choice%len(printable)]
+	}
+	if len(ranges) == 0 {
+		return 'x'
+	}
+	return ranges[0]
+}
@@ -0,0 +1,104 @@
+package server
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// parseProperty decodes a property definition written as JSON.
+func parseProperty(t *testing.T, definition string) Property {
+	t.Helper()
+	var prop Property
+	if err := json.Unmarshal([]byte(definition), &prop); err != nil {
+		t.Fatalf("invalid property %s: %v", definition, err)
+	}
+	return prop
+}
+
+func TestGenerateConstraints(t *testing.T) {
+	srv := NewServer()
+	definitions := []string{
+		`{"type": "integer", "minimum": 18, "maximum": 65}`,
+		`{"type": "integer", "exclusiveMinimum": 0, "exclusiveMaximum": 3}`,
+		`{"type": "integer", "maximum": -10}`,
+		`{"type": "integer", "minimum": 7, "multipleOf": 5}`,
+		`{"type": "integer", "minimum": -20, "maximum": -10, "multipleOf": 3}`,
+		`{"type": "number", "minimum": 0.25, "maximum": 0.75}`,
+		`{"type": "number", "format": "double", "exclusiveMinimum": 100}`,
+		`{"type": "number", "multipleOf": 0.1, "minimum": 0.3, "maximum": 0.6}`,
+		`{"type": "string", "minLength": 12}`,
+		`{"type": "string", "maxLength": 3}`,
+		`{"type": "string", "pattern": "^[A-Z]{3}-\\d{4}$"}`,
+		`{"type": "string", "pattern": "^(red|green|blue)$"}`,
+		`{"type": "string", "pattern": "^[a-z]+$", "minLength": 10, "maxLength": 12}`,
+		`{"type": "string", "pattern": "^SKU", "minLength": 8}`,
+		`{"type": "string", "const": "v1"}`,
+		`{"type": "integer", "const": 3, "enum": [1, 2, 3]}`,
+	}
+	for _, definition := range definitions {
+		prop := parseProperty(t, definition)
+		if err := srv.validateProperty("field", prop); err != nil {
+			t.Errorf("%s: rejected at upload: %v", definition, err)
+			continue
+		}
+		for _, mode := range genModes {
+			prop.GenMode = mode
+			g := srv.newGenerator(nil)
+			for i := 0; i < 20; i++ {
+				value := g.value("field", prop)
+				// Round-trip through JSON, as a client would see it.
+				encoded, _ := json.Marshal(value)
+				var decoded interface{}
+				json.Unmarshal(encoded, &decoded)
+				if errs := validateValue("field", prop, decoded, ""); len(errs) > 0 {
+					t.Errorf("%s in %s mode: generated %s: %s", definition, mode, encoded, errs[0].Message)
+					break
+				}
+			}
+		}
+	}
+}
+
+func TestGenerateConstraintsKeepDefaults(t *testing.T) {
+	srv := NewServer()
+	g := srv.newGenerator(nil)
+	if got := g.value("age", parseProperty(t, `{"type": "integer", "minimum": 0, "maximum": 10}`)); got != 1 {
+		t.Errorf("value in range was changed: got %v want 1", got)
+	}
+	if got := g.value("name", parseProperty(t, `{"type": "string", "maxLength": 20}`)); got != "example" {
+		t.Errorf("value in range was changed: got %v want example", got)
+	}
+	if got := g.value("age", parseProperty(t, `{"type": "integer", "minimum": 18}`)); got != 18 {
+		t.Errorf("value below the minimum: got %v want 18", got)
+	}
+	if got := g.value("code", parseProperty(t, `{"type": "string", "pattern": "^[A-Z]{3}$"}`)); got != "ABC" {
+		t.Errorf("pattern value: got %v want ABC", got)
+	}
+}
+
+func TestValidateConstraints(t *testing.T) {
+	tests := []struct {
+		definition string
+		value      interface{}
+		ok         bool
+	}{
+		{`{"type": "integer", "minimum": 1}`, 1.0, true},
+		{`{"type": "integer", "minimum": 1}`, 0.0, false},
+		{`{"type": "integer", "exclusiveMaximum": 10}`, 10.0, false},
+		{`{"type": "number", "multipleOf": 0.01}`, 19.99, true},
+		{`{"type": "number", "multipleOf": 0.01}`, 19.999, false},
+		{`{"type": "string", "minLength": 2, "maxLength": 3}`, "héé", true},
+		{`{"type": "string", "maxLength": 3}`, "four", false},
+		{`{"type": "string", "pattern": "^\\d+$"}`, "123", true},
+		{`{"type": "string", "pattern": "^\\d+$"}`, "12a", false},
+		{`{"type": "string", "pattern": "b"}`, "abc", true},
+		{`{"type": "string", "const": "v1"}`, "v2", false},
+		{`{"type": "boolean", "const": false}`, false, true},
+	}
+	for _, tt := range tests {
+		errs := validateValue("field", parseProperty(t, tt.definition), tt.value, "/field")
+		if (len(errs) == 0) != tt.ok {
+			t.Errorf("%s with %v: got errors %v", tt.definition, tt.value, errs)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// corsExposedHeaders are the response headers browsers let cross-origin
+// scripts read, besides the CORS-safelisted ones.
+var corsExposedHeaders = []string{
+	"Location", "ETag", "Allow", "Accept-Patch", "Link", "Retry-After",
+	"X-Total-Count", "X-Unknown-Params", validationWarningsHeader,
+	rateLimitHeader, rateRemainingHeader, rateResetHeader,
+}
+
+// corsMaxAge is how long, in seconds, browsers may cache a preflight
+// response.
+const corsMaxAge = "600"
+
+// corsConfig controls which cross-origin browser requests are allowed.
+type corsConfig struct {
+	// origins lists the allowed origins, such as "https://app.example.com".
+	// "*" allows any origin and "https://*.example.com" any subdomain.
+	// When empty, no CORS headers are sent at all.
+	origins []string
+	// methods lists the methods preflights allow; empty allows the
+	// supported entity methods.
+	methods []string
+	// headers lists the request headers preflights allow; empty allows
+	// whatever the preflight asks for.
+	headers []string
+	// credentials lets requests carry cookies and HTTP authentication.
+	credentials bool
+}
+
+// defaultCORS is the permissive development setting every Server starts
+// with: any origin, method and header, without credentials.
+var defaultCORS = corsConfig{origins: []string{"*"}}
+
+// allows reports whether origin may call the server cross-origin.
+func (c corsConfig) allows(origin string) bool {
+	for _, allowed := range c.origins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+		if scheme, host, ok := strings.Cut(strings.ToLower(allowed), "://*."); ok {
+			rest, ok := strings.CutPrefix(strings.ToLower(origin), scheme+"://")
+			if ok && strings.HasSuffix(rest, "."+host) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// anyOrigin reports whether every origin is allowed.
+func (c corsConfig) anyOrigin() bool {
+	for _, allowed := range c.origins {
+		if allowed == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// withCORS adds CORS headers to responses for allowed origins and answers
+// their preflight OPTIONS requests with 204. Preflights from other origins
+// are refused with 403; their other requests are served without CORS
+// headers, so the browser withholds the response.
+func withCORS(cfg corsConfig, next http.Handler) http.Handler {
+	methods := cfg.methods
+	if len(methods) == 0 {
+		methods = supportedMethods
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		header := w.Header()
+		header.Add("Vary", "Origin")
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+		if !cfg.allows(origin) {
+			if preflight {
+				writeError(w, http.StatusForbidden, fmt.Sprintf("Origin %s is not allowed", origin))
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if cfg.anyOrigin() && !cfg.credentials {
+			header.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			// Credentialed requests need the origin spelled out.
+			header.Set("Access-Control-Allow-Origin", origin)
+		}
+		if cfg.credentials {
+			header.Set("Access-Control-Allow-Credentials", "true")
+		}
+		if !preflight {
+			header.Set("Access-Control-Expose-Headers", strings.Join(corsExposedHeaders, ", "))
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		header.Add("Vary", "Access-Control-Request-Method")
+		header.Add("Vary", "Access-Control-Request-Headers")
+		header.Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+		if len(cfg.headers) > 0 {
+			header.Set("Access-Control-Allow-Headers", strings.Join(cfg.headers, ", "))
+		} else if requested := r.Header.Get("Access-Control-Request-Headers"); requested != "" {
+			header.Set("Access-Control-Allow-Headers", requested)
+		}
+		header.Set("Access-Control-Max-Age", corsMaxAge)
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
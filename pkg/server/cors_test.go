@@ -0,0 +1,66 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCORS(t *testing.T) {
+	serve := func(s *Server, method, origin string, preflight bool) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, "/entities", nil)
+		req.Header.Set("Origin", origin)
+		if preflight {
+			req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+			req.Header.Set("Access-Control-Request-Headers", "content-type, x-mock-delay")
+		}
+		rr := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("Permissive Default", func(t *testing.T) {
+		s := NewServer()
+		rr := serve(s, http.MethodOptions, "http://localhost:3000", true)
+		if status := rr.Code; status != http.StatusNoContent {
+			t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusNoContent)
+		}
+		header := rr.Header()
+		if header.Get("Access-Control-Allow-Origin") != "*" || header.Get("Access-Control-Allow-Headers") != "content-type, x-mock-delay" ||
+			header.Get("Access-Control-Allow-Methods") != "GET, POST, PUT, PATCH, DELETE" {
+			t.Errorf("preflight returned unexpected headers: got %v", header)
+		}
+		rr = serve(s, http.MethodGet, "http://localhost:3000", false)
+		if rr.Code != http.StatusOK || rr.Header().Get("Access-Control-Allow-Origin") != "*" || rr.Header().Get("Access-Control-Expose-Headers") == "" {
+			t.Errorf("request returned unexpected headers: got %v %v", rr.Code, rr.Header())
+		}
+	})
+
+	t.Run("Restricted", func(t *testing.T) {
+		s := NewServer(WithCORSOrigins("https://app.example.com", "https://*.example.org"), WithCORSMethods("get"), WithCORSHeaders("Content-Type"), WithCORSCredentials(true))
+		rr := serve(s, http.MethodOptions, "https://preview.example.org", true)
+		header := rr.Header()
+		if header.Get("Access-Control-Allow-Origin") != "https://preview.example.org" || header.Get("Access-Control-Allow-Credentials") != "true" ||
+			header.Get("Access-Control-Allow-Methods") != "GET" || header.Get("Access-Control-Allow-Headers") != "Content-Type" {
+			t.Errorf("preflight returned unexpected headers: got %v", header)
+		}
+		if rr := serve(s, http.MethodOptions, "https://evil.example.com", true); rr.Code != http.StatusForbidden {
+			t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusForbidden)
+		}
+		if rr := serve(s, http.MethodGet, "https://evil.example.com", false); rr.Header().Get("Access-Control-Allow-Origin") != "" {
+			t.Errorf("disallowed origin was granted access: got %v", rr.Header())
+		}
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		s := NewServer(WithCORSOrigins())
+		if rr := serve(s, http.MethodGet, "http://localhost:3000", false); rr.Header().Get("Access-Control-Allow-Origin") != "" {
+			t.Errorf("CORS headers were sent with CORS disabled: got %v", rr.Header())
+		}
+	})
+
+	if _, err := New(WithCORSOrigins("example.com")); err == nil {
+		t.Errorf("New accepted an origin without a scheme")
+	}
+}
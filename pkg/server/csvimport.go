@@ -0,0 +1,152 @@
+package server
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// parseCSV reads a CSV document whose header row names the properties
+// into records. Each column's cells are typed together; see
+// csvColumnType. Empty cells are left out of their record.
+func parseCSV(r io.Reader, delimiter rune) ([]string, []interface{}, error) {
+	reader := csv.NewReader(r)
+	reader.Comma = delimiter
+	reader.TrimLeadingSpace = true
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(rows) < 2 {
+		return nil, nil, errors.New("expected a header row and at least one row of data")
+	}
+	header := rows[0]
+	seen := make(map[string]bool)
+	for i, name := range header {
+		name = strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))
+		if name == "" {
+			return nil, nil, fmt.Errorf("column %d has no name", i+1)
+		}
+		if seen[name] {
+			return nil, nil, fmt.Errorf("column %q appears twice", name)
+		}
+		seen[name], header[i] = true, name
+	}
+
+	rows = rows[1:]
+	columns := make([]func(string) interface{}, len(header))
+	for i := range header {
+		columns[i] = csvColumnType(rows, i)
+	}
+	records := make([]interface{}, len(rows))
+	for j, row := range rows {
+		record := make(map[string]interface{})
+		for i, cell := range row {
+			if cell = strings.TrimSpace(cell); cell != "" {
+				record[header[i]] = columns[i](cell)
+			}
+		}
+		records[j] = record
+	}
+	return header, records, nil
+}
+
+// csvNumber matches the cells taken for numbers: JSON number literals,
+// so codes with leading zeros such as 02134 stay strings.
+var csvNumber = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][+-]?[0-9]+)?$`)
+
+// csvColumnType returns the conversion of the cells of column i: numbers
+// when every filled cell is one, booleans when every one is true or
+// false, and strings otherwise.
+func csvColumnType(rows [][]string, i int) func(string) interface{} {
+	numbers, booleans := true, true
+	for _, row := range rows {
+		cell := strings.TrimSpace(row[i])
+		if cell == "" {
+			continue
+		}
+		numbers = numbers && csvNumber.MatchString(cell)
+		booleans = booleans && (cell == "true" || cell == "false")
+	}
+	switch {
+	case numbers:
+		// json.Number keeps integers apart from fractions for inference.
+		return func(cell string) interface{} { return json.Number(cell) }
+	case booleans:
+		return func(cell string) interface{} { return cell == "true" }
+	default:
+		return func(cell string) interface{} { return cell }
+	}
+}
+
+// csvHandler turns a CSV document POSTed to /upload/csv into an entity:
+// it infers a schema from the rows, as /upload/example does, uploads it
+// and loads the rows as its records. The title query parameter names the
+// entity, Item unless it is given, and delimiter the separator, a comma
+// unless it is given.
+func (s *Server) csvHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w, "Only POST allowed", http.MethodPost)
+		return
+	}
+	defer r.Body.Close()
+	title := strings.TrimSpace(r.URL.Query().Get("title"))
+	if title == "" {
+		title = "Item"
+	}
+	delimiter := ','
+	if d := r.URL.Query().Get("delimiter"); d != "" {
+		if utf8.RuneCountInString(d) != 1 {
+			writeError(w, http.StatusBadRequest, "delimiter must be a single character, got "+strconv.Quote(d))
+			return
+		}
+		delimiter, _ = utf8.DecodeRuneInString(d)
+	}
+	header, records, err := parseCSV(r.Body, delimiter)
+	var inferred map[string]interface{}
+	if err == nil {
+		inferred, err = inferSchema(records, title)
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid CSV: "+err.Error())
+		return
+	}
+	// Columns that are empty in every row still become properties.
+	properties := inferred["properties"].(map[string]interface{})
+	for _, name := range header {
+		if properties[name] == nil {
+			properties[name] = map[string]interface{}{"type": "string"}
+		}
+	}
+	data, _ := json.Marshal(inferred)
+	schema, err := s.loadSchema(bytes.NewReader(data))
+	if err != nil {
+		writeSchemaError(w, err)
+		return
+	}
+	fixtures, _ := json.Marshal(map[string]interface{}{strings.ToLower(schema.Title): records})
+	loaded, errs, err := s.loadFixtures(bytes.NewReader(fixtures))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid CSV: "+err.Error())
+		return
+	}
+	if len(errs) > 0 {
+		writeValidationErrors(w, errs)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "CSV imported successfully",
+		"title":   schema.Title,
+		"schema":  inferred,
+		"records": loaded[entityName(schema)],
+	})
+}
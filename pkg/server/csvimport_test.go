@@ -0,0 +1,71 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestCSVImport(t *testing.T) {
+	srv := NewServer()
+
+	csv := "\ufeffsku,name,price,stock,active,zip,launched,notes\n" +
+		"1,Lamp,19.99,4,true,02134,2024-01-02,\n" +
+		"2,\"Desk, oak\",250,0,false,10001,2024-02-03,\n" +
+		"3,Chair,45.5,,true,94103,2024-03-04,\n"
+	rr := performRequest(t, srv.csvHandler, http.MethodPost, "/upload/csv?title=Product", []byte(csv))
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v (%v)", status, http.StatusOK, rr.Body.String())
+	}
+	var imported struct {
+		Title   string                 `json:"title"`
+		Schema  map[string]interface{} `json:"schema"`
+		Records int                    `json:"records"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &imported); err != nil {
+		t.Fatalf("could not decode response: %v", err)
+	}
+	if imported.Title != "Product" || imported.Records != 3 {
+		t.Errorf("got title %v and %d records, want Product and 3", imported.Title, imported.Records)
+	}
+	properties := imported.Schema["properties"].(map[string]interface{})
+	want := map[string]string{
+		"sku":      `{"type":"integer"}`,
+		"price":    `{"type":"number"}`,
+		"stock":    `{"type":"integer"}`,
+		"active":   `{"type":"boolean"}`,
+		"zip":      `{"type":"string"}`,
+		"launched": `{"format":"date","type":"string"}`,
+		"notes":    `{"type":"string"}`,
+	}
+	for name, schema := range want {
+		got, _ := json.Marshal(properties[name])
+		if string(got) != schema {
+			t.Errorf("property %s: got %s want %s", name, got, schema)
+		}
+	}
+
+	rr = performRequest(t, srv.catchAllHandler, http.MethodGet, "/products", nil)
+	var list []map[string]interface{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &list); err != nil {
+		t.Fatalf("could not decode response: %v", err)
+	}
+	if len(list) != 3 || list[1]["name"] != "Desk, oak" || list[0]["zip"] != "02134" || list[0]["price"] != 19.99 {
+		t.Errorf("rows were not loaded as records: %v", list)
+	}
+	if _, ok := list[2]["stock"]; ok {
+		t.Errorf("empty cell was stored: %v", list[2])
+	}
+
+	rr = performRequest(t, srv.csvHandler, http.MethodPost, "/upload/csv?title=Tally&delimiter=;", []byte("a;b\n1;x\n"))
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v (%v)", status, http.StatusOK, rr.Body.String())
+	}
+	for _, body := range []string{"a,b\n", "a,a\n1,2\n", "a,b\n1\n", ",b\n1,2\n"} {
+		rr := performRequest(t, srv.csvHandler, http.MethodPost, "/upload/csv", []byte(body))
+		if status := rr.Code; status != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "Invalid CSV") {
+			t.Errorf("%q: handler returned %v %s, want %v", body, status, rr.Body.String(), http.StatusBadRequest)
+		}
+	}
+}
@@ -0,0 +1,622 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// sqlToken is a token of SQL DDL. Kind is 'w' for words, 'q' for quoted
+// identifiers, 's' for string literals, 'n' for numbers and 'p' for
+// punctuation.
+type sqlToken struct {
+	kind byte
+	text string
+}
+
+// is reports whether t is the keyword or punctuation word, ignoring case.
+func (t sqlToken) is(word string) bool {
+	return (t.kind == 'w' || t.kind == 'p') && strings.EqualFold(t.text, word)
+}
+
+// name reports whether t can name a table or column.
+func (t sqlToken) name() bool {
+	return t.kind == 'w' || t.kind == 'q'
+}
+
+// tokenizeSQL splits DDL into tokens, dropping whitespace and -- and /* */
+// comments. Identifiers may be quoted "like this", `like this` or
+// [like this], while int[] stays an array type; string literals double a
+// quote to escape it.
+func tokenizeSQL(src string) ([]sqlToken, error) {
+	var tokens []sqlToken
+	runes := []rune(src)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '-' && i+1 < len(runes) && runes[i+1] == '-':
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+		case r == '/' && i+1 < len(runes) && runes[i+1] == '*':
+			j := i + 2
+			for j+1 < len(runes) && !(runes[j] == '*' && runes[j+1] == '/') {
+				j++
+			}
+			if j+1 >= len(runes) {
+				return nil, errors.New("unterminated /* comment")
+			}
+			i = j + 2
+		case r == '\'' || r == '"' || r == '`' || (r == '[' && i+1 < len(runes) && (unicode.IsLetter(runes[i+1]) || runes[i+1] == '_')):
+			closing, kind := r, byte('q')
+			if r == '[' {
+				closing = ']'
+			} else if r == '\'' {
+				kind = 's'
+			}
+			var text strings.Builder
+			j := i + 1
+			for ; j < len(runes); j++ {
+				if runes[j] == closing {
+					if j+1 < len(runes) && runes[j+1] == closing && closing != ']' {
+						text.WriteRune(closing)
+						j++
+						continue
+					}
+					break
+				}
+				text.WriteRune(runes[j])
+			}
+			if j >= len(runes) {
+				return nil, fmt.Errorf("unterminated %c", r)
+			}
+			tokens = append(tokens, sqlToken{kind, text.String()})
+			i = j + 1
+		case unicode.IsDigit(r) || (r == '.' && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			j := i
+			for j < len(runes) && (unicode.IsDigit(runes[j]) || runes[j] == '.' ||
+				runes[j] == 'e' || runes[j] == 'E' ||
+				((runes[j] == '+' || runes[j] == '-') && (runes[j-1] == 'e' || runes[j-1] == 'E'))) {
+				j++
+			}
+			tokens = append(tokens, sqlToken{'n', string(runes[i:j])})
+			i = j
+		case unicode.IsLetter(r) || r == '_':
+			j := i
+			for j < len(runes) && (unicode.IsLetter(runes[j]) || unicode.IsDigit(runes[j]) || runes[j] == '_' || runes[j] == '$') {
+				j++
+			}
+			tokens = append(tokens, sqlToken{'w', string(runes[i:j])})
+			i = j
+		default:
+			tokens = append(tokens, sqlToken{'p', string(r)})
+			i++
+		}
+	}
+	return tokens, nil
+}
+
+// splitSQL splits tokens at the separator sep where they are not nested
+// in parentheses, dropping empty parts.
+func splitSQL(tokens []sqlToken, sep string) [][]sqlToken {
+	var parts [][]sqlToken
+	depth, start := 0, 0
+	for i, t := range tokens {
+		switch {
+		case t.is("("):
+			depth++
+		case t.is(")"):
+			depth--
+		case depth == 0 && t.is(sep):
+			if i > start {
+				parts = append(parts, tokens[start:i])
+			}
+			start = i + 1
+		}
+	}
+	if start < len(tokens) {
+		parts = append(parts, tokens[start:])
+	}
+	return parts
+}
+
+// sqlGroup returns the tokens inside the parentheses opening at
+// tokens[i] and the index after the closing one, or nil when tokens[i]
+// opens none or they are never closed.
+func sqlGroup(tokens []sqlToken, i int) ([]sqlToken, int) {
+	if i >= len(tokens) || !tokens[i].is("(") {
+		return nil, i
+	}
+	depth := 0
+	for j := i; j < len(tokens); j++ {
+		switch {
+		case tokens[j].is("("):
+			depth++
+		case tokens[j].is(")"):
+			if depth--; depth == 0 {
+				return tokens[i+1 : j], j + 1
+			}
+		}
+	}
+	return nil, len(tokens)
+}
+
+// sqlNames returns the column names listed in a parenthesized group.
+func sqlNames(group []sqlToken) []string {
+	var names []string
+	for _, part := range splitSQL(group, ",") {
+		if part[0].name() {
+			names = append(names, part[0].text)
+		}
+	}
+	return names
+}
+
+// sqlQualifiedName reads a possibly schema-qualified name such as
+// public.users at tokens[i], returning its last part and the index after
+// it.
+func sqlQualifiedName(tokens []sqlToken, i int) (string, int) {
+	name := ""
+	for i < len(tokens) && tokens[i].name() {
+		name = tokens[i].text
+		i++
+		if i+1 < len(tokens) && tokens[i].is(".") {
+			i++
+			continue
+		}
+		break
+	}
+	return name, i
+}
+
+// sqlColumn is a column of a CREATE TABLE statement.
+type sqlColumn struct {
+	name string
+	// typ is the lower-cased type name without its arguments, such as
+	// "character varying".
+	typ     string
+	length  int
+	enum    []string
+	array   bool
+	notNull bool
+	primary bool
+	filled  bool // has a default or is generated
+	def     *sqlToken
+	ref     string
+}
+
+// sqlTable is a table and the constraints given on it.
+type sqlTable struct {
+	name    string
+	columns []*sqlColumn
+	primary []string
+}
+
+// column returns the column called name, ignoring case, or nil.
+func (t *sqlTable) column(name string) *sqlColumn {
+	for _, c := range t.columns {
+		if strings.EqualFold(c.name, name) {
+			return c
+		}
+	}
+	return nil
+}
+
+// sqlColumnKeywords end a column's type and start its constraints.
+var sqlColumnKeywords = map[string]bool{
+	"NOT": true, "NULL": true, "PRIMARY": true, "UNIQUE": true, "DEFAULT": true,
+	"REFERENCES": true, "CHECK": true, "CONSTRAINT": true, "AUTO_INCREMENT": true,
+	"AUTOINCREMENT": true, "IDENTITY": true, "GENERATED": true, "COLLATE": true,
+	"COMMENT": true, "ON": true,
+}
+
+// sqlTableModifiers may come between CREATE and TABLE.
+var sqlTableModifiers = map[string]bool{
+	"OR": true, "REPLACE": true, "GLOBAL": true, "LOCAL": true, "TEMP": true,
+	"TEMPORARY": true, "UNLOGGED": true,
+}
+
+// sqlTableConstraints start a table constraint rather than a column.
+var sqlTableConstraints = map[string]bool{
+	"CONSTRAINT": true, "PRIMARY": true, "FOREIGN": true, "UNIQUE": true, "CHECK": true,
+	"KEY": true, "INDEX": true, "EXCLUDE": true, "FULLTEXT": true, "SPATIAL": true,
+}
+
+// parseDDL reads the tables of SQL DDL: CREATE TABLE statements, and the
+// columns and primary and foreign keys ALTER TABLE ... ADD statements give
+// them, as database dumps and migrations do. Other statements are ignored.
+func parseDDL(src string) ([]*sqlTable, error) {
+	tokens, err := tokenizeSQL(src)
+	if err != nil {
+		return nil, err
+	}
+	var tables []*sqlTable
+	find := func(name string) *sqlTable {
+		for _, t := range tables {
+			if strings.EqualFold(t.name, name) {
+				return t
+			}
+		}
+		return nil
+	}
+	for _, stmt := range splitSQL(tokens, ";") {
+		switch {
+		case stmt[0].is("CREATE"):
+			i := 1
+			for i < len(stmt) && stmt[i].kind == 'w' && sqlTableModifiers[strings.ToUpper(stmt[i].text)] {
+				i++
+			}
+			if i == len(stmt) || !stmt[i].is("TABLE") {
+				continue // an index, view, type or sequence
+			}
+			i++
+			if i+2 < len(stmt) && stmt[i].is("IF") && stmt[i+1].is("NOT") && stmt[i+2].is("EXISTS") {
+				i += 3
+			}
+			name, next := sqlQualifiedName(stmt, i)
+			body, _ := sqlGroup(stmt, next)
+			if name == "" {
+				return nil, errors.New("CREATE TABLE without a table name")
+			}
+			if body == nil && next < len(stmt) && stmt[next].is("(") {
+				return nil, fmt.Errorf("CREATE TABLE %s: unclosed parenthesis", name)
+			}
+			if body == nil {
+				continue // CREATE TABLE ... AS SELECT lists no columns
+			}
+			if find(name) != nil {
+				return nil, fmt.Errorf("table %s is created twice", name)
+			}
+			table := &sqlTable{name: name}
+			for _, def := range splitSQL(body, ",") {
+				if def[0].kind == 'w' && sqlTableConstraints[strings.ToUpper(def[0].text)] {
+					table.constraint(def)
+					continue
+				}
+				if !def[0].name() {
+					return nil, fmt.Errorf("table %s: unexpected %q", name, def[0].text)
+				}
+				table.columns = append(table.columns, parseSQLColumn(def))
+			}
+			if len(table.columns) == 0 {
+				return nil, fmt.Errorf("table %s has no columns", name)
+			}
+			tables = append(tables, table)
+		case stmt[0].is("ALTER") && len(stmt) > 1 && stmt[1].is("TABLE"):
+			i := 2
+			for i < len(stmt) && (stmt[i].is("ONLY") || stmt[i].is("IF") || stmt[i].is("EXISTS")) {
+				i++
+			}
+			name, next := sqlQualifiedName(stmt, i)
+			table := find(name)
+			if table == nil {
+				continue
+			}
+			for _, action := range splitSQL(stmt[next:], ",") {
+				if !action[0].is("ADD") || len(action) < 2 {
+					continue
+				}
+				def := action[1:]
+				if def[0].is("COLUMN") && len(def) > 1 {
+					def = def[1:]
+				} else if def[0].kind == 'w' && sqlTableConstraints[strings.ToUpper(def[0].text)] {
+					table.constraint(def)
+					continue
+				}
+				if def[0].name() && table.column(def[0].text) == nil {
+					table.columns = append(table.columns, parseSQLColumn(def))
+				}
+			}
+		}
+	}
+	if len(tables) == 0 {
+		return nil, errors.New("no CREATE TABLE statements found")
+	}
+	return tables, nil
+}
+
+// constraint applies a table constraint: a primary key, or a foreign key
+// of one column. Others, such as unique keys and checks, are ignored.
+func (t *sqlTable) constraint(def []sqlToken) {
+	i := 0
+	if def[0].is("CONSTRAINT") {
+		i = 2
+	}
+	switch {
+	case i+1 < len(def) && def[i].is("PRIMARY") && def[i+1].is("KEY"):
+		group, _ := sqlGroup(def, i+2)
+		t.primary = sqlNames(group)
+	case i+1 < len(def) && def[i].is("FOREIGN") && def[i+1].is("KEY"):
+		group, next := sqlGroup(def, i+2)
+		columns := sqlNames(group)
+		if next < len(def) && def[next].is("REFERENCES") && len(columns) == 1 {
+			if c := t.column(columns[0]); c != nil {
+				c.ref, _ = sqlQualifiedName(def, next+1)
+			}
+		}
+	}
+}
+
+// parseSQLColumn reads a column definition: its name, type and
+// constraints.
+func parseSQLColumn(def []sqlToken) *sqlColumn {
+	c := &sqlColumn{name: def[0].text}
+	i := 1
+	var words []string
+	for i < len(def) && !(def[i].kind == 'w' && sqlColumnKeywords[strings.ToUpper(def[i].text)]) {
+		switch t := def[i]; {
+		case t.is("("):
+			var args []sqlToken
+			args, i = sqlGroup(def, i)
+			for _, arg := range args {
+				if arg.kind == 's' {
+					c.enum = append(c.enum, arg.text)
+				}
+			}
+			if len(args) > 0 && args[0].kind == 'n' && c.length == 0 {
+				c.length, _ = strconv.Atoi(args[0].text)
+			}
+			continue
+		case t.is("["):
+			c.array = true
+		case t.kind == 'w' || t.kind == 'q':
+			words = append(words, strings.ToLower(t.text))
+		}
+		i++
+	}
+	c.typ = strings.Join(words, " ")
+	if strings.HasSuffix(c.typ, " array") {
+		c.typ, c.array = strings.TrimSuffix(c.typ, " array"), true
+	}
+	if base, _, _ := strings.Cut(c.typ, " "); strings.Contains(base, "serial") {
+		c.filled = true
+	}
+	for ; i < len(def); i++ {
+		switch t := def[i]; {
+		case t.is("NOT") && i+1 < len(def) && def[i+1].is("NULL"):
+			c.notNull = true
+			i++
+		case t.is("PRIMARY"):
+			c.primary = true
+		case t.is("DEFAULT"):
+			c.filled = true
+			if i+1 < len(def) {
+				if value := def[i+1]; value.kind == 's' || value.kind == 'n' || value.is("TRUE") || value.is("FALSE") {
+					c.def = &value
+				} else if value.is("-") && i+2 < len(def) && def[i+2].kind == 'n' {
+					c.def = &sqlToken{'n', "-" + def[i+2].text}
+				}
+			}
+		case t.is("AUTO_INCREMENT"), t.is("AUTOINCREMENT"), t.is("IDENTITY"), t.is("GENERATED"):
+			c.filled = true
+		case t.is("REFERENCES"):
+			c.ref, i = sqlQualifiedName(def, i+1)
+			i--
+		case t.is("("):
+			_, next := sqlGroup(def, i)
+			i = next - 1
+		}
+	}
+	return c
+}
+
+// Column types by the first word of their name, apart from those sqlProperty
+// matches itself.
+var (
+	sqlBooleanTypes = map[string]bool{"bool": true, "boolean": true}
+	sqlIntegerTypes = map[string]bool{
+		"int": true, "integer": true, "smallint": true, "bigint": true, "tinyint": true, "mediumint": true,
+		"int2": true, "int4": true, "int8": true, "serial": true, "smallserial": true, "bigserial": true,
+		"serial2": true, "serial4": true, "serial8": true,
+	}
+	sqlNumberTypes = map[string]bool{
+		"decimal": true, "numeric": true, "real": true, "float": true, "float4": true, "float8": true,
+		"double": true, "money": true, "number": true, "smallmoney": true,
+	}
+)
+
+// sqlProperty maps a column type to a property schema.
+func sqlProperty(c *sqlColumn) map[string]interface{} {
+	prop := make(map[string]interface{})
+	base, _, _ := strings.Cut(c.typ, " ")
+	switch {
+	case base == "tinyint" && c.length == 1, base == "bit" && c.length <= 1, sqlBooleanTypes[base]:
+		prop["type"] = "boolean"
+	case sqlIntegerTypes[base]:
+		prop["type"] = "integer"
+	case sqlNumberTypes[base]:
+		prop["type"] = "number"
+	case base == "uuid", base == "uniqueidentifier":
+		prop["type"], prop["format"] = "string", "uuid"
+	case base == "date":
+		prop["type"], prop["format"] = "string", "date"
+	case base == "timestamp", base == "timestamptz", base == "datetime", base == "datetime2", base == "datetimeoffset":
+		prop["type"], prop["format"] = "string", "date-time"
+	case base == "json", base == "jsonb":
+		prop["type"] = "object"
+	case base == "bytea", strings.HasSuffix(base, "blob"), strings.HasSuffix(base, "binary"):
+		prop["type"], prop["format"] = "string", "byte"
+	case base == "enum" || base == "set":
+		prop["type"] = "string"
+		if len(c.enum) > 0 {
+			values := make([]interface{}, len(c.enum))
+			for i, v := range c.enum {
+				values[i] = v
+			}
+			prop["enum"] = values
+		}
+	default:
+		prop["type"] = "string"
+		if c.length > 0 && (strings.Contains(base, "char") || strings.Contains(base, "text")) {
+			prop["maxLength"] = c.length
+		}
+	}
+	if c.def != nil {
+		switch d := *c.def; {
+		case prop["type"] == "string" && d.kind == 's' && prop["format"] == nil:
+			prop["default"] = d.text
+		case (prop["type"] == "integer" || prop["type"] == "number") && d.kind == 'n':
+			if n, err := strconv.ParseFloat(d.text, 64); err == nil {
+				prop["default"] = n
+			}
+		case prop["type"] == "boolean" && (d.is("TRUE") || d.is("FALSE")):
+			prop["default"] = d.is("TRUE")
+		}
+	}
+	if c.array {
+		prop = map[string]interface{}{"type": "array", "items": prop}
+	}
+	return prop
+}
+
+// ddlSchemas turns tables into schema documents, one per table, served
+// under the table's name. A single-column primary key holds the ids, uuid
+// ones generated as UUIDs; NOT NULL columns are required unless they are
+// filled in by a default or the database; other columns are nullable; and
+// single-column foreign keys become x-ref relations.
+func ddlSchemas(tables []*sqlTable) ([]map[string]interface{}, []string) {
+	titles := make(map[string]string, len(tables))
+	for _, t := range tables {
+		titles[strings.ToLower(t.name)] = singularize(t.name)
+	}
+	var warnings []string
+	docs := make([]map[string]interface{}, len(tables))
+	for i, t := range tables {
+		primary := t.primary
+		for _, c := range t.columns {
+			if c.primary && len(primary) == 0 {
+				primary = []string{c.name}
+			}
+		}
+		doc := map[string]interface{}{
+			"title":           titles[strings.ToLower(t.name)],
+			"type":            "object",
+			"x-resource-name": t.name,
+		}
+		switch {
+		case len(primary) == 1 && idPropertyPattern.MatchString(primary[0]):
+			if c := t.column(primary[0]); c != nil {
+				c.primary = true
+				if c.name != "id" {
+					doc["x-id-property"] = c.name
+				}
+				if sqlProperty(c)["format"] == "uuid" {
+					doc["x-id-strategy"] = idUUID
+				}
+			}
+		case len(primary) > 1:
+			for _, name := range primary {
+				if c := t.column(name); c != nil {
+					c.notNull = true
+				}
+			}
+			warnings = append(warnings, fmt.Sprintf("table %s has a composite primary key (%s); its records get an id instead", t.name, strings.Join(primary, ", ")))
+		}
+		properties := make(map[string]interface{})
+		var required []string
+		for _, c := range t.columns {
+			prop := sqlProperty(c)
+			if c.notNull && !c.primary && !c.filled {
+				required = append(required, c.name)
+			}
+			if !c.notNull && !c.primary {
+				prop["nullable"] = true
+			}
+			if c.ref != "" {
+				if title, ok := titles[strings.ToLower(c.ref)]; ok && (prop["type"] == "integer" || prop["type"] == "string") {
+					prop["x-ref"] = title
+				} else if !ok {
+					warnings = append(warnings, fmt.Sprintf("%s.%s references %s, which the DDL does not create", t.name, c.name, c.ref))
+				}
+			}
+			properties[c.name] = prop
+		}
+		doc["properties"] = properties
+		if len(required) > 0 {
+			doc["required"] = required
+		}
+		docs[i] = doc
+	}
+	return docs, warnings
+}
+
+// loadDDL registers a schema for every table the SQL DDL in r creates,
+// returning their titles and any warnings. The DDL is read entirely before
+// anything is registered, so a syntax error registers nothing.
+func (s *Server) loadDDL(r io.Reader) ([]string, []string, error) {
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	tables, err := parseDDL(string(src))
+	if err != nil {
+		return nil, nil, err
+	}
+	docs, warnings := ddlSchemas(tables)
+	titles := make([]string, len(docs))
+	for i, doc := range docs {
+		data, _ := json.Marshal(doc)
+		schema, err := s.loadSchema(bytes.NewReader(data))
+		if err != nil {
+			return nil, nil, fmt.Errorf("table %s: %w", tables[i].name, err)
+		}
+		titles[i] = schema.Title
+	}
+	return titles, warnings, nil
+}
+
+// loadDDLFile registers the tables of the SQL DDL in the named file.
+func (s *Server) loadDDLFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, warnings, err := s.loadDDL(file)
+	if err != nil {
+		return fmt.Errorf("SQL file %s: %w", path, err)
+	}
+	for _, warning := range warnings {
+		log.Printf("SQL file %s: %s", path, warning)
+	}
+	return nil
+}
+
+// ddlHandler registers an entity for every table created by SQL DDL
+// POSTed to /upload/sql.
+func (s *Server) ddlHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w, "Only POST allowed", http.MethodPost)
+		return
+	}
+	defer r.Body.Close()
+	titles, warnings, err := s.loadDDL(r.Body)
+	if err != nil {
+		var violations schemaErrors
+		if errors.As(err, &violations) {
+			writeSchemaError(w, err)
+			return
+		}
+		writeError(w, http.StatusBadRequest, "Invalid SQL: "+err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	response := map[string]interface{}{
+		"message":  "SQL tables imported successfully",
+		"entities": titles,
+	}
+	if len(warnings) > 0 {
+		response["warnings"] = warnings
+	}
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,125 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+const shopDDL = `
+-- pg_dump style
+CREATE TABLE public.customers (
+    id integer NOT NULL,
+    email character varying(120) NOT NULL,
+    name text,
+    vip boolean DEFAULT false NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL
+);
+CREATE SEQUENCE public.customers_id_seq START WITH 1;
+ALTER TABLE ONLY public.customers ADD CONSTRAINT customers_pkey PRIMARY KEY (id);
+
+/* MySQL style */
+CREATE TABLE IF NOT EXISTS ` + "`orders`" + ` (
+  ` + "`order_no`" + ` int NOT NULL AUTO_INCREMENT,
+  ` + "`customer_id`" + ` int NOT NULL,
+  ` + "`status`" + ` enum('open','paid','shipped') NOT NULL DEFAULT 'open',
+  ` + "`total`" + ` decimal(10,2) NOT NULL,
+  ` + "`tags`" + ` text[],
+  PRIMARY KEY (` + "`order_no`" + `),
+  KEY ` + "`idx_customer`" + ` (` + "`customer_id`" + `),
+  CONSTRAINT ` + "`fk_customer`" + ` FOREIGN KEY (` + "`customer_id`" + `) REFERENCES ` + "`customers`" + ` (` + "`id`" + `) ON DELETE CASCADE
+) ENGINE=InnoDB;
+
+CREATE TABLE order_lines (
+    order_no int REFERENCES orders(order_no),
+    sku uuid NOT NULL,
+    PRIMARY KEY (order_no, sku)
+);
+CREATE INDEX order_lines_sku ON order_lines (sku);
+ALTER TABLE order_lines ADD COLUMN quantity smallint DEFAULT 1 NOT NULL;
+`
+
+func TestImportDDL(t *testing.T) {
+	srv := NewServer()
+
+	rr := performRequest(t, srv.ddlHandler, http.MethodPost, "/upload/sql", []byte(shopDDL))
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v (%v)", status, http.StatusOK, rr.Body.String())
+	}
+	var imported struct {
+		Entities []string `json:"entities"`
+		Warnings []string `json:"warnings"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &imported); err != nil {
+		t.Fatalf("could not decode response: %v", err)
+	}
+	if strings.Join(imported.Entities, ",") != "customer,order,order_line" {
+		t.Errorf("handler returned unexpected entities: got %v", imported.Entities)
+	}
+	if len(imported.Warnings) != 1 || !strings.Contains(imported.Warnings[0], "composite primary key") {
+		t.Errorf("handler returned unexpected warnings: got %v", imported.Warnings)
+	}
+
+	customer, order := srv.schemas["customer"], srv.schemas["order"]
+	if customer == nil || order == nil {
+		t.Fatalf("tables were not registered: %v", srv.sortedSchemaKeys())
+	}
+	if got := strings.Join(customer.Required, ","); got != "email" {
+		t.Errorf("customer: got required %v want email", got)
+	}
+	if p := customer.Properties["email"]; p.Type != "string" || p.MaxLength == nil || *p.MaxLength != 120 || p.Nullable {
+		t.Errorf("customer email: got %+v", p)
+	}
+	if p := customer.Properties["name"]; !p.Nullable {
+		t.Errorf("customer name should be nullable: got %+v", p)
+	}
+	if p := customer.Properties["created_at"]; p.Format != "date-time" {
+		t.Errorf("customer created_at: got %+v", p)
+	}
+	if order.IDProperty != "order_no" || order.ResourceName != "orders" {
+		t.Errorf("order: got id property %q and resource %q", order.IDProperty, order.ResourceName)
+	}
+	if got := strings.Join(order.Required, ","); got != "customer_id,total" {
+		t.Errorf("order: got required %v want customer_id,total", got)
+	}
+	if p := order.Properties["customer_id"]; p.XRef != "customer" || p.Type != "integer" {
+		t.Errorf("order customer_id: got %+v", p)
+	}
+	if p := order.Properties["status"]; len(p.Enum) != 3 || p.Default != "open" {
+		t.Errorf("order status: got %+v", p)
+	}
+	if p := order.Properties["total"]; p.Type != "number" {
+		t.Errorf("order total: got %+v", p)
+	}
+	if p := order.Properties["tags"]; p.Type != "array" || p.Items == nil || p.Items.Type != "string" {
+		t.Errorf("order tags: got %+v", p)
+	}
+	if p := srv.schemas["order_line"].Properties["sku"]; p.Format != "uuid" {
+		t.Errorf("order_line sku: got %+v", p)
+	}
+	if p := srv.schemas["order_line"].Properties["quantity"]; p.Type != "integer" || p.Default != 1.0 {
+		t.Errorf("order_line quantity: got %+v", p)
+	}
+
+	// Foreign keys are served as relations.
+	rr = performRequest(t, srv.catchAllHandler, http.MethodPost, "/customers", []byte(`{"email": "ada@example.com"}`))
+	if status := rr.Code; status != http.StatusCreated {
+		t.Fatalf("handler returned wrong status code: got %v want %v (%v)", status, http.StatusCreated, rr.Body.String())
+	}
+	rr = performRequest(t, srv.catchAllHandler, http.MethodPost, "/orders", []byte(`{"customer_id": 1, "total": 12.5}`))
+	if status := rr.Code; status != http.StatusCreated {
+		t.Fatalf("handler returned wrong status code: got %v want %v (%v)", status, http.StatusCreated, rr.Body.String())
+	}
+	rr = performRequest(t, srv.catchAllHandler, http.MethodGet, "/customers/1/orders", nil)
+	if status := rr.Code; status != http.StatusOK || !strings.Contains(rr.Body.String(), `"total":12.5`) {
+		t.Errorf("relation route: got %v %s", status, rr.Body.String())
+	}
+
+	for _, ddl := range []string{"SELECT 1;", "CREATE TABLE t (a int", "CREATE TABLE t ('x' int);", "/* open"} {
+		rr := performRequest(t, srv.ddlHandler, http.MethodPost, "/upload/sql", []byte(ddl))
+		if status := rr.Code; status != http.StatusBadRequest {
+			t.Errorf("%q: handler returned wrong status code: got %v want %v", ddl, status, http.StatusBadRequest)
+		}
+	}
+}
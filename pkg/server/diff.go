@@ -0,0 +1,212 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// schemaChange is one difference between the registered and candidate
+// versions of a schema. Path names the property, using dots for nested
+// object fields and [] for array elements.
+type schemaChange struct {
+	Kind     string `json:"kind"`
+	Path     string `json:"path"`
+	Breaking bool   `json:"breaking"`
+	Message  string `json:"message"`
+}
+
+// schemaDiff is the response of POST /schemas/{entity}/diff.
+type schemaDiff struct {
+	Entity   string         `json:"entity"`
+	Breaking bool           `json:"breaking"`
+	Changes  []schemaChange `json:"changes"`
+}
+
+// diffSchemas compares a candidate schema against the active one. Changes
+// that can break existing clients, such as removing a property, changing
+// its type, or making a field required, are flagged as breaking.
+func diffSchemas(active, candidate *Schema) []schemaChange {
+	changes := diffProperties("", active.Properties, candidate.Properties, candidate.Required)
+
+	for _, name := range candidate.Required {
+		if containsString(active.Required, name) {
+			continue
+		}
+		if _, existed := active.Properties[name]; !existed {
+			continue // reported as a required property_added
+		}
+		changes = append(changes, schemaChange{
+			Kind: "required_added", Path: name, Breaking: true,
+			Message: fmt.Sprintf("%s became required", name),
+		})
+	}
+	for _, name := range active.Required {
+		if containsString(candidate.Required, name) {
+			continue
+		}
+		if _, kept := candidate.Properties[name]; !kept {
+			continue // reported as property_removed
+		}
+		changes = append(changes, schemaChange{
+			Kind: "required_removed", Path: name, Breaking: false,
+			Message: fmt.Sprintf("%s is no longer required", name),
+		})
+	}
+	return changes
+}
+
+// diffProperties compares two property sets, recursing into nested objects
+// and array items. required lists the candidate's required names at this
+// level; adding a required property is breaking, adding an optional one is
+// not.
+func diffProperties(prefix string, active, candidate map[string]Property, required []string) []schemaChange {
+	names := make(map[string]bool)
+	for name := range active {
+		names[name] = true
+	}
+	for name := range candidate {
+		names[name] = true
+	}
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	var changes []schemaChange
+	for _, name := range sorted {
+		path := prefix + name
+		before, existed := active[name]
+		after, kept := candidate[name]
+		switch {
+		case !kept:
+			changes = append(changes, schemaChange{
+				Kind: "property_removed", Path: path, Breaking: true,
+				Message: fmt.Sprintf("%s was removed", path),
+			})
+		case !existed:
+			isRequired := containsString(required, name)
+			message := fmt.Sprintf("%s was added", path)
+			if isRequired {
+				message = fmt.Sprintf("required property %s was added", path)
+			}
+			changes = append(changes, schemaChange{
+				Kind: "property_added", Path: path, Breaking: isRequired,
+				Message: message,
+			})
+		default:
+			changes = append(changes, diffProperty(path, before, after)...)
+		}
+	}
+	return changes
+}
+
+// diffProperty compares two definitions of the same property.
+func diffProperty(path string, before, after Property) []schemaChange {
+	if before.Type != after.Type {
+		return []schemaChange{{
+			Kind: "type_changed", Path: path, Breaking: true,
+			Message: fmt.Sprintf("%s changed type from %q to %q", path, before.Type, after.Type),
+		}}
+	}
+	var changes []schemaChange
+	if before.Format != after.Format {
+		changes = append(changes, schemaChange{
+			Kind: "format_changed", Path: path, Breaking: true,
+			Message: fmt.Sprintf("%s changed format from %q to %q", path, before.Format, after.Format),
+		})
+	}
+	switch after.Type {
+	case "object":
+		changes = append(changes, diffProperties(path+".", before.Properties, after.Properties, nil)...)
+	case "array":
+		if before.Items != nil && after.Items != nil {
+			changes = append(changes, diffProperty(path+"[]", *before.Items, *after.Items)...)
+		} else if before.Items != nil || after.Items != nil {
+			changes = append(changes, schemaChange{
+				Kind: "items_changed", Path: path + "[]", Breaking: before.Items != nil,
+				Message: fmt.Sprintf("%s changed its items definition", path),
+			})
+		}
+	}
+	return changes
+}
+
+// lookupSchema finds a registered schema by lower-cased title or by its
+// route name. The caller must hold stateMu.
+func (s *Server) lookupSchema(name string) (*Schema, bool) {
+	name = strings.ToLower(name)
+	if schema, ok := s.schemas[name]; ok {
+		return schema, true
+	}
+	for _, schema := range s.schemas {
+		if entityName(schema) == name {
+			return schema, true
+		}
+	}
+	return nil, false
+}
+
+// schemaDiffHandler serves POST /schemas/{entity}/diff, comparing the
+// candidate schema in the body with the registered one without changing
+// anything.
+func (s *Server) schemaDiffHandler(w http.ResponseWriter, r *http.Request) {
+	segments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(segments) != 3 || segments[0] != "schemas" || segments[2] != "diff" {
+		writeNotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w, "Only POST allowed", http.MethodPost)
+		return
+	}
+
+	data, err := io.ReadAll(r.Body)
+	if err == nil {
+		err = checkMetaSchema(data)
+	}
+	if err != nil {
+		writeSchemaError(w, err)
+		return
+	}
+	var candidate Schema
+	if err := json.Unmarshal(data, &candidate); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid JSON schema: "+err.Error())
+		return
+	}
+
+	err = resolveRefs(&candidate)
+	s.stateMu.RLock()
+	active, ok := s.lookupSchema(segments[1])
+	if err == nil {
+		err = s.resolveExtends(&candidate)
+	}
+	s.stateMu.RUnlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("No schema registered for %q", segments[1]))
+		return
+	}
+	if err == nil {
+		err = s.validateSchema(&candidate)
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid JSON schema: "+err.Error())
+		return
+	}
+
+	diff := schemaDiff{Entity: entityName(active), Changes: diffSchemas(active, &candidate)}
+	if diff.Changes == nil {
+		diff.Changes = []schemaChange{}
+	}
+	for _, change := range diff.Changes {
+		if change.Breaking {
+			diff.Breaking = true
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(diff)
+}
@@ -0,0 +1,106 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestDiffSchemas(t *testing.T) {
+	active := &Schema{
+		Title: "User",
+		Properties: map[string]Property{
+			"id":    {Type: "integer"},
+			"name":  {Type: "string"},
+			"email": {Type: "string"},
+			"age":   {Type: "integer"},
+			"address": {Type: "object", Properties: map[string]Property{
+				"zip": {Type: "string"},
+			}},
+		},
+		Required: []string{"id", "name", "email"},
+	}
+	candidate := &Schema{
+		Title: "User",
+		Properties: map[string]Property{
+			"id":       {Type: "integer"},
+			"name":     {Type: "string"},
+			"age":      {Type: "string"},
+			"nickname": {Type: "string"},
+			"phone":    {Type: "string"},
+			"address": {Type: "object", Properties: map[string]Property{
+				"zip": {Type: "integer"},
+			}},
+		},
+		Required: []string{"id", "phone", "age"},
+	}
+
+	want := map[string]schemaChange{
+		"address.zip": {Kind: "type_changed", Breaking: true},
+		"age":         {Kind: "type_changed", Breaking: true},
+		"email":       {Kind: "property_removed", Breaking: true},
+		"nickname":    {Kind: "property_added", Breaking: false},
+		"phone":       {Kind: "property_added", Breaking: true},
+		"name":        {Kind: "required_removed", Breaking: false},
+	}
+	changes := diffSchemas(active, candidate)
+	// age also becomes required.
+	if len(changes) != len(want)+1 {
+		t.Errorf("diffSchemas returned wrong number of changes: got %+v", changes)
+	}
+	for _, change := range changes {
+		if change.Kind == "required_added" {
+			if change.Path != "age" || !change.Breaking {
+				t.Errorf("unexpected required_added change: %+v", change)
+			}
+			continue
+		}
+		expected, ok := want[change.Path]
+		if !ok {
+			t.Errorf("unexpected change: %+v", change)
+			continue
+		}
+		if change.Kind != expected.Kind || change.Breaking != expected.Breaking {
+			t.Errorf("change at %v: got %v/%v want %v/%v", change.Path, change.Kind, change.Breaking, expected.Kind, expected.Breaking)
+		}
+	}
+
+	if changes := diffSchemas(active, active); len(changes) != 0 {
+		t.Errorf("diffSchemas reported changes for identical schemas: %+v", changes)
+	}
+}
+
+func TestSchemaDiffHandler(t *testing.T) {
+	srv := NewServer()
+	srv.schemas = make(map[string]*Schema)
+	srv.registerSchema(createSampleSchema())
+
+	t.Run("Breaking Change", func(t *testing.T) {
+		body := []byte(`{"title":"User","properties":{"id":{"type":"integer"},"name":{"type":"string"}},"required":["id","name"]}`)
+		rr := performRequest(t, srv.schemaDiffHandler, http.MethodPost, "/schemas/users/diff", body)
+		if status := rr.Code; status != http.StatusOK {
+			t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+		}
+		var diff schemaDiff
+		if err := json.Unmarshal(rr.Body.Bytes(), &diff); err != nil {
+			t.Fatalf("could not decode response: %v", err)
+		}
+		if !diff.Breaking || len(diff.Changes) != 1 || diff.Changes[0].Path != "email" {
+			t.Errorf("handler returned unexpected diff: %+v", diff)
+		}
+	})
+
+	t.Run("Unknown Entity", func(t *testing.T) {
+		rr := performRequest(t, srv.schemaDiffHandler, http.MethodPost, "/schemas/widgets/diff", []byte(`{"title":"Widget"}`))
+		if status := rr.Code; status != http.StatusNotFound {
+			t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusNotFound)
+		}
+	})
+
+	t.Run("Invalid Method", func(t *testing.T) {
+		rr := performRequest(t, srv.schemaDiffHandler, http.MethodGet, "/schemas/users/diff", nil)
+		if status := rr.Code; status != http.StatusMethodNotAllowed {
+			t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusMethodNotAllowed)
+		}
+	})
+}
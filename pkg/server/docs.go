@@ -0,0 +1,37 @@
+package server
+
+import (
+	"html/template"
+	"net/http"
+)
+
+// docsPage renders Swagger UI for the spec URL it is executed with. The
+// UI's assets are loaded from a CDN so the binary stays dependency-free.
+var docsPage = template.Must(template.New("docs").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>schema2api docs</title>
+<link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+<div id="swagger-ui"></div>
+<script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js" crossorigin></script>
+<script>
+window.onload = function () {
+  window.ui = SwaggerUIBundle({ url: {{.}}, dom_id: "#swagger-ui" });
+};
+</script>
+</body>
+</html>
+`))
+
+// docsHandler serves the interactive API explorer at GET /docs.
+func (s *Server) docsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w, "Only GET allowed", http.MethodGet)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	docsPage.Execute(w, s.route("/openapi.json"))
+}
@@ -0,0 +1,98 @@
+package server
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// paginationMetaModes are the places list pagination metadata can live:
+// the X-Total-Count and Link headers, the envelope's meta object, or both.
+var paginationMetaModes = []string{"headers", "body", "both"}
+
+// envelopeConfig shapes successful entity responses.
+type envelopeConfig struct {
+	// data is the key responses are wrapped under, or "" to send records
+	// as they are.
+	data string
+	// meta is the key of the envelope's metadata object.
+	meta string
+	// pagination is one of paginationMetaModes.
+	pagination string
+}
+
+// defaultEnvelope sends raw records with pagination metadata in headers.
+var defaultEnvelope = envelopeConfig{meta: "meta", pagination: "headers"}
+
+// withEnvelope wraps the successful JSON responses of entity routes as
+// {cfg.data: body, cfg.meta: {...}}. Unless pagination metadata stays in
+// headers only, the meta object of lists carries the total, the page and
+// limit asked for and the pagination links.
+func (s *Server) withEnvelope(cfg envelopeConfig, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		segments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+		// Streams have no single document to wrap.
+		if _, _, onEntity := s.entityState(segments[0]); !onEntity || wantsNDJSON(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		hw := &heldWriter{ResponseWriter: w}
+		next.ServeHTTP(hw, r)
+		if hw.status == 0 {
+			hw.status = http.StatusOK
+		}
+
+		header := w.Header()
+		mediaType, _, _ := mime.ParseMediaType(header.Get("Content-Type"))
+		var decoded interface{}
+		if hw.status >= 300 || mediaType != "application/json" || json.Unmarshal(hw.body.Bytes(), &decoded) != nil {
+			w.WriteHeader(hw.status)
+			w.Write(hw.body.Bytes())
+			return
+		}
+
+		meta := make(map[string]interface{})
+		// Debug lists carry their records under data already.
+		if wrapped, ok := decoded.(map[string]interface{}); ok && wrapped["_meta"] != nil {
+			decoded, meta["debug"] = wrapped["data"], wrapped["_meta"]
+		}
+		if cfg.pagination != "headers" {
+			for key, value := range paginationMeta(r, header) {
+				meta[key] = value
+			}
+			if cfg.pagination == "body" {
+				header.Del("X-Total-Count")
+				header.Del("Link")
+			}
+		}
+		doc := map[string]interface{}{cfg.data: decoded}
+		if len(meta) > 0 {
+			doc[cfg.meta] = meta
+		}
+		header.Del("Content-Length")
+		w.WriteHeader(hw.status)
+		json.NewEncoder(w).Encode(doc)
+	})
+}
+
+// paginationMeta describes the page of a list response from its headers
+// and query, or returns nil for other responses.
+func paginationMeta(r *http.Request, header http.Header) map[string]interface{} {
+	total, err := strconv.Atoi(header.Get("X-Total-Count"))
+	if err != nil {
+		return nil
+	}
+	meta := map[string]interface{}{"total": total}
+	query := r.URL.Query()
+	for _, param := range []string{"page", "limit"} {
+		if n, err := strconv.Atoi(query.Get(param)); err == nil {
+			meta[param] = n
+		}
+	}
+	if links := parseLinks(header.Get("Link")); len(links) > 0 {
+		meta["links"] = links
+	}
+	return meta
+}
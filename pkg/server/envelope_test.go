@@ -0,0 +1,65 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestEnvelope(t *testing.T) {
+	serve := func(s *Server, method, path, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		rr := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rr, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rr
+	}
+	upload := func(s *Server) {
+		t.Helper()
+		s.currentSchema = createSampleSchema()
+		s.store = newRecordStore()
+		s.registerSchema(s.currentSchema)
+		s.stores["user"] = s.store
+	}
+
+	s := NewServer(WithEnvelope("data", ""), WithPaginationMeta("body"))
+	upload(s)
+	rr := serve(s, http.MethodGet, "/users?page=2&limit=1", "")
+	var doc struct {
+		Data []map[string]interface{} `json:"data"`
+		Meta map[string]interface{}   `json:"meta"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("could not decode response: %v", err)
+	}
+	if len(doc.Data) != 1 || doc.Meta["total"] != float64(s.listSize) || doc.Meta["page"] != float64(2) || doc.Meta["links"] == nil {
+		t.Errorf("handler returned unexpected envelope: got %v", rr.Body.String())
+	}
+	if rr.Header().Get("X-Total-Count") != "" || rr.Header().Get("Link") != "" {
+		t.Errorf("pagination headers were kept: got %v", rr.Header())
+	}
+
+	rr = serve(s, http.MethodPost, "/users", `{"name": "alice", "email": "a@example.com"}`)
+	if rr.Code != http.StatusCreated || !strings.HasPrefix(rr.Body.String(), `{"data":{`) || strings.Contains(rr.Body.String(), `"meta"`) {
+		t.Errorf("handler returned unexpected envelope: got %v %v", rr.Code, rr.Body.String())
+	}
+	// Errors are not wrapped.
+	if rr := serve(s, http.MethodGet, "/users/abc", ""); strings.Contains(rr.Body.String(), `"data"`) {
+		t.Errorf("error response was wrapped: got %v", rr.Body.String())
+	}
+
+	s = NewServer(WithEnvelope("result", "info"), WithPaginationMeta("both"))
+	upload(s)
+	rr = serve(s, http.MethodGet, "/users?limit=2", "")
+	if rr.Header().Get("X-Total-Count") == "" || !strings.Contains(rr.Body.String(), `"info":{`) || !strings.HasPrefix(rr.Body.String(), `{"info"`) {
+		t.Errorf("handler returned unexpected envelope: got %v %v", rr.Header(), rr.Body.String())
+	}
+
+	if _, err := New(WithPaginationMeta("body")); err == nil {
+		t.Errorf("New accepted body pagination meta without an envelope")
+	}
+	if _, err := New(WithPaginationMeta("footer")); err == nil {
+		t.Errorf("New accepted an unknown pagination meta mode")
+	}
+}
@@ -0,0 +1,196 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// Error is the body of every error response. Code is a stable,
+// machine-readable identifier, Message is meant for humans and Details
+// carries structured context such as per-field validation failures.
+type Error struct {
+	Code    string      `json:"code"`
+	Message string      `json:"message"`
+	Details interface{} `json:"details,omitempty"`
+}
+
+// problemContentType is the media type of RFC 7807 problem details.
+const problemContentType = "application/problem+json"
+
+// Problem is the RFC 7807 body error responses carry unless legacyErrors
+// is set. Type is always about:blank, so Title is the status text; Code and
+// Details are those of the Error it was made from, as extension members.
+type Problem struct {
+	Type     string      `json:"type"`
+	Title    string      `json:"title"`
+	Status   int         `json:"status"`
+	Detail   string      `json:"detail,omitempty"`
+	Instance string      `json:"instance,omitempty"`
+	Code     string      `json:"code"`
+	Details  interface{} `json:"details,omitempty"`
+}
+
+// errorCode derives the default code for a status, e.g. 404 -> "not_found".
+func errorCode(status int) string {
+	text := strings.ToLower(http.StatusText(status))
+	if text == "" {
+		return "error"
+	}
+	return strings.NewReplacer(" ", "_", "-", "_", "'", "").Replace(text)
+}
+
+// writeError responds with status and an Error body whose code is derived
+// from the status.
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeErrorDetails(w, status, errorCode(status), message, nil)
+}
+
+// writeErrorDetails responds with status and a fully specified Error body.
+func writeErrorDetails(w http.ResponseWriter, status int, code, message string, details interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(Error{Code: code, Message: message, Details: details})
+}
+
+// writeMethodNotAllowed responds 405 with an Allow header listing the
+// methods the resource does serve.
+func writeMethodNotAllowed(w http.ResponseWriter, message string, allowed ...string) {
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	writeError(w, http.StatusMethodNotAllowed, message)
+}
+
+// writeNotFound responds 404 for a path that matches no resource.
+func writeNotFound(w http.ResponseWriter, r *http.Request) {
+	writeError(w, http.StatusNotFound, "No resource found at "+r.URL.Path)
+}
+
+// errorComponent is the JSON Schema of Error, for use as a reusable
+// component that every operation's error responses reference.
+func errorComponent() map[string]interface{} {
+	return map[string]interface{}{
+		"type":     "object",
+		"required": []string{"code", "message"},
+		"properties": map[string]interface{}{
+			"code": map[string]interface{}{
+				"type":        "string",
+				"description": "Stable machine-readable error identifier, e.g. not_found or validation_failed.",
+			},
+			"message": map[string]interface{}{
+				"type":        "string",
+				"description": "Human-readable description of the error.",
+			},
+			"details": map[string]interface{}{
+				"description": "Structured context; for validation_failed, a list of field errors with JSON Pointer locations.",
+			},
+		},
+	}
+}
+
+// problemComponent is the JSON Schema of Problem, which takes the place of
+// errorComponent unless legacyErrors is set.
+func problemComponent() map[string]interface{} {
+	str := func(description string) map[string]interface{} {
+		return map[string]interface{}{"type": "string", "description": description}
+	}
+	return map[string]interface{}{
+		"type":     "object",
+		"required": []string{"type", "title", "status", "code"},
+		"properties": map[string]interface{}{
+			"type":     str("Problem type URI; always about:blank."),
+			"title":    str("Status text of the response."),
+			"status":   map[string]interface{}{"type": "integer", "description": "HTTP status code of the response."},
+			"detail":   str("Human-readable description of this occurrence of the error."),
+			"instance": str("Path of the request that failed."),
+			"code":     str("Stable machine-readable error identifier, e.g. not_found or validation_failed."),
+			"details": map[string]interface{}{
+				"description": "Structured context; for validation_failed, a list of field errors with JSON Pointer locations.",
+			},
+		},
+	}
+}
+
+// errorContent describes the body of error responses in OpenAPI.
+func (s *Server) errorContent() map[string]interface{} {
+	mediaType := problemContentType
+	if s.legacyErrors {
+		mediaType = "application/json"
+	}
+	return map[string]interface{}{
+		mediaType: map[string]interface{}{"schema": schemaRef("Error")},
+	}
+}
+
+// withProblems converts the Error bodies of error responses to problem
+// details, with the request path as their instance. Other responses pass
+// through untouched.
+func withProblems(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pw := &problemWriter{ResponseWriter: w}
+		next.ServeHTTP(pw, r)
+		if !pw.held {
+			return
+		}
+
+		var e Error
+		header := w.Header()
+		if err := json.Unmarshal(pw.body.Bytes(), &e); err != nil || e.Code == "" {
+			w.WriteHeader(pw.status)
+			w.Write(pw.body.Bytes())
+			return
+		}
+		header.Del("Content-Length")
+		header.Set("Content-Type", problemContentType)
+		w.WriteHeader(pw.status)
+		json.NewEncoder(w).Encode(Problem{
+			Type:     "about:blank",
+			Title:    http.StatusText(pw.status),
+			Status:   pw.status,
+			Detail:   e.Message,
+			Instance: r.URL.Path,
+			Code:     e.Code,
+			Details:  e.Details,
+		})
+	})
+}
+
+// problemWriter holds back the body of JSON error responses so
+// withProblems can replace it.
+type problemWriter struct {
+	http.ResponseWriter
+	status int
+	held   bool
+	body   bytes.Buffer
+}
+
+func (p *problemWriter) WriteHeader(status int) {
+	if p.status != 0 {
+		return
+	}
+	p.status = status
+	mediaType, _, _ := mime.ParseMediaType(p.Header().Get("Content-Type"))
+	if status >= 400 && mediaType == "application/json" {
+		p.held = true
+		return
+	}
+	p.ResponseWriter.WriteHeader(status)
+}
+
+func (p *problemWriter) Write(b []byte) (int, error) {
+	if p.status == 0 {
+		p.WriteHeader(http.StatusOK)
+	}
+	if p.held {
+		return p.body.Write(b)
+	}
+	return p.ResponseWriter.Write(b)
+}
+
+// Flush lets streaming handlers flush successful responses.
+func (p *problemWriter) Flush() {
+	if f, ok := p.ResponseWriter.(http.Flusher); ok && !p.held {
+		f.Flush()
+	}
+}
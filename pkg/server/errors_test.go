@@ -0,0 +1,118 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestErrorCode(t *testing.T) {
+	tests := map[int]string{
+		http.StatusNotFound:            "not_found",
+		http.StatusUnprocessableEntity: "unprocessable_entity",
+		http.StatusServiceUnavailable:  "service_unavailable",
+		http.StatusTeapot:              "im_a_teapot",
+		599:                            "error",
+	}
+	for status, want := range tests {
+		if got := errorCode(status); got != want {
+			t.Errorf("errorCode(%d) = %v, want %v", status, got, want)
+		}
+	}
+}
+
+func TestErrorComponentMatchesStruct(t *testing.T) {
+	properties := errorComponent()["properties"].(map[string]interface{})
+	typ := reflect.TypeOf(Error{})
+	if len(properties) != typ.NumField() {
+		t.Errorf("component has %d properties, Error has %d fields", len(properties), typ.NumField())
+	}
+	for i := 0; i < typ.NumField(); i++ {
+		name := strings.Split(typ.Field(i).Tag.Get("json"), ",")[0]
+		if _, ok := properties[name]; !ok {
+			t.Errorf("component is missing property %v", name)
+		}
+	}
+}
+
+func TestProblemComponentMatchesStruct(t *testing.T) {
+	properties := problemComponent()["properties"].(map[string]interface{})
+	typ := reflect.TypeOf(Problem{})
+	if len(properties) != typ.NumField() {
+		t.Errorf("component has %d properties, Problem has %d fields", len(properties), typ.NumField())
+	}
+	for i := 0; i < typ.NumField(); i++ {
+		name := strings.Split(typ.Field(i).Tag.Get("json"), ",")[0]
+		if _, ok := properties[name]; !ok {
+			t.Errorf("component is missing property %v", name)
+		}
+	}
+}
+
+func TestProblemDetails(t *testing.T) {
+	serve := func(s *Server) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/products/1", nil))
+		return rr
+	}
+
+	rr := serve(NewServer())
+	var problem Problem
+	if err := json.Unmarshal(rr.Body.Bytes(), &problem); err != nil {
+		t.Fatalf("could not decode problem: %v", err)
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != problemContentType {
+		t.Errorf("got content type %v want %v", ct, problemContentType)
+	}
+	want := Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(http.StatusServiceUnavailable),
+		Status:   http.StatusServiceUnavailable,
+		Detail:   "No schema uploaded. Please POST your JSON schema to /upload",
+		Instance: "/products/1",
+		Code:     "service_unavailable",
+	}
+	if problem != want {
+		t.Errorf("got problem %+v want %+v", problem, want)
+	}
+
+	rr = serve(NewServer(WithLegacyErrors(true)))
+	if ct := rr.Header().Get("Content-Type"); ct != "application/json" || !strings.Contains(rr.Body.String(), `"message":`) {
+		t.Errorf("legacy error was converted: got %v %v", ct, rr.Body.String())
+	}
+}
+
+func TestHandlersReturnErrorBodies(t *testing.T) {
+	srv := NewServer()
+	srv.currentSchema = createSampleSchema()
+	srv.store = newRecordStore()
+
+	tests := []struct {
+		method, path string
+		status       int
+		code         string
+	}{
+		{http.MethodGet, "/products", http.StatusNotFound, "not_found"},
+		{http.MethodGet, "/users/abc", http.StatusBadRequest, "bad_request"},
+		{http.MethodOptions, "/users/1", http.StatusMethodNotAllowed, "method_not_allowed"},
+	}
+	for _, tt := range tests {
+		rr := performRequest(t, srv.catchAllHandler, tt.method, tt.path, nil)
+		if rr.Code != tt.status {
+			t.Errorf("%s %s: got status %v want %v", tt.method, tt.path, rr.Code, tt.status)
+		}
+		if ct := rr.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("%s %s: got content type %v", tt.method, tt.path, ct)
+		}
+		var body Error
+		if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+			t.Fatalf("%s %s: could not decode error body: %v", tt.method, tt.path, err)
+		}
+		if body.Code != tt.code || body.Message == "" {
+			t.Errorf("%s %s: got error %+v, want code %v", tt.method, tt.path, body, tt.code)
+		}
+	}
+}
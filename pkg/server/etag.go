@@ -0,0 +1,96 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// entityTag returns the weak ETag of a record: a hash of its JSON encoding,
+// so equal records always share a tag. It is weak because the same tag is
+// sent whatever format or content coding the body ends up in.
+func entityTag(v interface{}) string {
+	data, _ := json.Marshal(v)
+	sum := sha256.Sum256(data)
+	return `W/"` + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// etagMatches reports whether an If-Match or If-None-Match header is "*"
+// or lists etag. Both compare weakly, ignoring the W/ prefix on either
+// side: every tag is weak, and names the record's state rather than the
+// bytes of one representation of it.
+func etagMatches(header, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// checkIfMatch answers 412 Precondition Failed unless the record at rawID
+// is current per the request's If-Match header, reporting whether the
+// write may go ahead. A record never stored is compared as GET would
+// generate it for PUT, except under -strict-put or where GET would answer
+// 404; PATCH and DELETE have no current record for any tag to match.
+func (s *Server) checkIfMatch(w http.ResponseWriter, r *http.Request, schema *Schema, records *recordStore, rawID string) bool {
+	id, ok := parseRecordID(w, schema, rawID)
+	if !ok {
+		return false
+	}
+	key := fmt.Sprint(id)
+	current, stored := records.get(key)
+	if stored && s.softDeleted(current) {
+		current = nil
+	}
+	generates := r.Method == http.MethodPut && !s.strictPut
+	if !stored && generates && !s.strictGet && !records.wasDeleted(key) {
+		current = s.generatedRecord(r, schema, key, id)
+	}
+	if current != nil && etagMatches(r.Header.Get("If-Match"), entityTag(hideWriteOnly(schema, current))) {
+		return true
+	}
+	writeError(w, http.StatusPreconditionFailed, "If-Match does not match the current ETag of the record")
+	return false
+}
+
+// generatedRecord returns the record GET /{entity}/{id} fabricates for an
+// id that was never stored.
+func (s *Server) generatedRecord(r *http.Request, schema *Schema, key string, id interface{}) map[string]interface{} {
+	obj := s.dummyData(schema, s.newRecordGenerator(r, schema, key))
+	if prop, ok := schema.Properties[schema.idKey()]; ok && prop.Type == "integer" {
+		obj[schema.idKey()] = id
+	} else {
+		obj[stringIDKey(schema)] = fmt.Sprint(id)
+	}
+	return obj
+}
+
+// stringIDKey returns the property holding the id of a schema without an
+// integer id: x-id-property, id when it is a string, or else a string
+// property.
+func stringIDKey(schema *Schema) string {
+	if schema.IDProperty != "" {
+		return schema.IDProperty
+	}
+	stringKey := "id"
+	foundKey := false
+	for key, prop := range schema.Properties {
+		// Use explicit "id" if string, or first string property otherwise
+		if key == "id" && prop.Type == "string" {
+			stringKey = key
+			foundKey = true
+			break
+		}
+		if prop.Type == "string" && !foundKey {
+			stringKey = key
+			// Don't break, prefer "id" if found later
+		}
+	}
+	return stringKey
+}
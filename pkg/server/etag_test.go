@@ -0,0 +1,61 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestConditionalRequests(t *testing.T) {
+	srv := NewServer()
+	srv.currentSchema = createSampleSchema()
+	srv.store = newRecordStore()
+	srv.registerSchema(srv.currentSchema)
+	srv.stores["user"] = srv.store
+	serve := func(method, path, body string, header ...string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(srv.catchAllHandler).ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := serve(http.MethodPost, "/users", `{"id": 1, "name": "alice", "email": "a@example.com"}`)
+	etag := rr.Header().Get("ETag")
+	if !strings.HasPrefix(etag, `W/"`) || serve(http.MethodGet, "/users/1", "").Header().Get("ETag") != etag {
+		t.Fatalf("GET did not return the ETag of the created record: got %q", etag)
+	}
+	if rr := serve(http.MethodGet, "/users/1", "", "If-None-Match", strings.TrimPrefix(etag, "W/")); rr.Code != http.StatusNotModified || rr.Body.Len() != 0 {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusNotModified)
+	}
+
+	cases := []struct {
+		name, method, path, body, ifMatch string
+		status                            int
+	}{
+		{"Stale PUT", http.MethodPut, "/users/1", `{"name": "bob", "email": "b@example.com"}`, `"stale"`, http.StatusPreconditionFailed},
+		{"Current PUT", http.MethodPut, "/users/1", `{"name": "bob", "email": "b@example.com"}`, etag, http.StatusOK},
+		// The PUT changed the record, so its old tag is stale.
+		{"Stale DELETE", http.MethodDelete, "/users/1", "", etag, http.StatusPreconditionFailed},
+		{"Any DELETE", http.MethodDelete, "/users/1", "", "*", http.StatusNoContent},
+		{"Deleted", http.MethodPut, "/users/1", `{"name": "carol", "email": "c@example.com"}`, "*", http.StatusPreconditionFailed},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rr := serve(tc.method, tc.path, tc.body, "If-Match", tc.ifMatch)
+			if status := rr.Code; status != tc.status {
+				t.Errorf("handler returned wrong status code: got %v want %v", status, tc.status)
+			}
+		})
+	}
+
+	// Records never stored match the tag GET generated them with.
+	generated := serve(http.MethodGet, "/users/9", "").Header().Get("ETag")
+	if rr := serve(http.MethodPut, "/users/9", `{"name": "dave", "email": "d@example.com"}`, "If-Match", generated); rr.Code != http.StatusCreated {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusCreated)
+	}
+}
@@ -0,0 +1,89 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Word lists for fakeString. They are kept short; values repeat once a
+// list is exhausted.
+var (
+	fakeFirstNames = []string{"Alice", "Bruno", "Chloe", "Dmitri", "Elena", "Farid", "Grace", "Hiro", "Isabel", "Jonas"}
+	fakeLastNames  = []string{"Johnson", "Silva", "Martin", "Ivanov", "Rossi", "Haddad", "Kim", "Tanaka", "Garcia", "Weber"}
+	fakeStreets    = []string{"Maple Street", "Oak Avenue", "Elm Road", "Harbor Lane", "Hillside Drive", "Park Place", "Mill Way"}
+	fakeCities     = []string{"Springfield", "Lisbon", "Toronto", "Osaka", "Melbourne", "Hamburg", "Austin", "Lyon"}
+	fakeCountries  = []string{"United States", "Portugal", "Canada", "Japan", "Australia", "Germany", "France", "Brazil"}
+	fakeCompanies  = []string{"Acme Corp", "Globex", "Initech", "Umbrella Labs", "Stark Industries", "Wayne Enterprises"}
+)
+
+// fakeNameFormats maps property names to the format whose placeholder suits
+// them, for schemas that name a field uuid or createdAt without a format.
+var fakeNameFormats = map[string]string{
+	"uuid":        "uuid",
+	"guid":        "uuid",
+	"createdat":   "date-time",
+	"updatedat":   "date-time",
+	"timestamp":   "date-time",
+	"birthdate":   "date",
+	"dateofbirth": "date",
+	"dob":         "date",
+	"url":         "uri",
+	"website":     "uri",
+	"homepage":    "uri",
+	"ip":          "ipv4",
+	"ipaddress":   "ipv4",
+}
+
+// fakeString returns a realistic value for a string property called name,
+// or false when neither the name nor format suggests one. n selects the
+// value like the generation modes do for enums: 0 picks the first, n > 0
+// the n-th, wrapping around each list.
+func fakeString(name, format string, n int) (string, bool) {
+	i := 0
+	if n > 0 {
+		i = n - 1
+	}
+	first := fakeFirstNames[i%len(fakeFirstNames)]
+	last := fakeLastNames[i%len(fakeLastNames)]
+	pick := func(list []string) string { return list[i%len(list)] }
+
+	if format == "email" {
+		return strings.ToLower(first + "." + last + "@example.com"), true
+	}
+	if format != "" {
+		// Other formats have placeholders that satisfy them.
+		return "", false
+	}
+
+	key := strings.NewReplacer("_", "", "-", "").Replace(strings.ToLower(name))
+	if format, ok := fakeNameFormats[key]; ok {
+		return formatString(format, n)
+	}
+	switch key {
+	case "name", "fullname", "displayname":
+		return first + " " + last, true
+	case "firstname", "givenname":
+		return first, true
+	case "lastname", "surname", "familyname":
+		return last, true
+	case "username", "login", "handle":
+		return strings.ToLower(first + last[:1]), true
+	case "email", "emailaddress":
+		return strings.ToLower(first + "." + last + "@example.com"), true
+	case "phone", "phonenumber", "mobile", "telephone":
+		return fmt.Sprintf("+1-555-01%02d", i%100), true
+	case "street", "streetaddress", "address", "address1":
+		return fmt.Sprintf("%d %s", 10+i*7%90, pick(fakeStreets)), true
+	case "city", "town":
+		return pick(fakeCities), true
+	case "country":
+		return pick(fakeCountries), true
+	case "zip", "zipcode", "postcode", "postalcode":
+		return fmt.Sprintf("%05d", 10001+i*137%89999), true
+	case "company", "companyname", "organization", "employer":
+		return pick(fakeCompanies), true
+	case "avatar", "avatarurl", "photo", "image":
+		return fmt.Sprintf("https://example.com/avatars/%s.png", strings.ToLower(first)), true
+	}
+	return "", false
+}
@@ -0,0 +1,54 @@
+package server
+
+import (
+	"testing"
+)
+
+func TestFakeString(t *testing.T) {
+	tests := []struct {
+		name, format string
+		n            int
+		want         string
+		ok           bool
+	}{
+		{"name", "", 0, "Alice Johnson", true},
+		{"first_name", "", 2, "Bruno", true},
+		{"lastName", "", 0, "Johnson", true},
+		{"contact", "email", 0, "alice.johnson@example.com", true},
+		{"email", "", 3, "chloe.martin@example.com", true},
+		{"City", "", 0, "Springfield", true},
+		{"phone", "", 0, "+1-555-0100", true},
+		{"zip", "", 0, "10001", true},
+		{"created_at", "", 0, "2024-01-01T09:30:00Z", true},
+		{"website", "", 2, "https://example.com/2", true},
+		{"ipAddress", "", 0, "192.0.2.1", true},
+		{"avatar", "", 0, "https://example.com/avatars/alice.png", true},
+		{"city", "uuid", 0, "", false},
+		{"title", "", 0, "", false},
+	}
+	for _, tt := range tests {
+		got, ok := fakeString(tt.name, tt.format, tt.n)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("fakeString(%q, %q, %d) = %q, %v, want %q, %v", tt.name, tt.format, tt.n, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestGenerateFaker(t *testing.T) {
+	srv := NewServer()
+	srv.fakerMode = true
+
+	schema := &Schema{Properties: map[string]Property{
+		"name":  {Type: "string"},
+		"title": {Type: "string"},
+		"tags":  {Type: "string", Enum: []interface{}{"vip"}},
+	}}
+	obj := srv.dummyData(schema, srv.newGenerator(nil))
+	if obj["name"] != "Alice Johnson" || obj["title"] != "example" || obj["tags"] != "vip" {
+		t.Errorf("faker generated unexpected values: got %v", obj)
+	}
+	again := srv.dummyData(schema, srv.newGenerator(nil))
+	if again["name"] != obj["name"] {
+		t.Errorf("faker is not deterministic: got %v then %v", obj["name"], again["name"])
+	}
+}
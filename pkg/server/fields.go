@@ -0,0 +1,77 @@
+package server
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// fieldsParam is the query parameter listing the fields GET responses
+// keep, as in ?fields=id,name.
+const fieldsParam = "fields"
+
+// parseFields reads the sparse fieldset a request asked for, or nil when
+// it wants every field. Names are properties of schema, dotted paths into
+// nested objects such as address.city, or references named in ?expand=.
+func parseFields(query url.Values, schema *Schema, expansions []expansion) ([]string, error) {
+	var fields []string
+	for _, raw := range query[fieldsParam] {
+		for _, field := range strings.Split(raw, ",") {
+			field = strings.TrimSpace(field)
+			if field == "" {
+				continue
+			}
+			if _, ok := lookupField(schema, field); !ok && !expandedField(field, expansions) {
+				return nil, fmt.Errorf("unknown property %q", field)
+			}
+			fields = append(fields, field)
+		}
+	}
+	return fields, nil
+}
+
+// expandedField reports whether field is, or lies within, one of the
+// embedded references.
+func expandedField(field string, expansions []expansion) bool {
+	name, _, _ := strings.Cut(field, ".")
+	for _, e := range expansions {
+		if e.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// selectFields returns a copy of obj holding only fields, or obj itself
+// when fields is nil. Naming an object keeps all of it, whatever paths
+// into it are also named.
+func selectFields(obj map[string]interface{}, fields []string) map[string]interface{} {
+	if fields == nil {
+		return obj
+	}
+	whole := make(map[string]bool)
+	nested := make(map[string][]string)
+	for _, field := range fields {
+		if name, rest, ok := strings.Cut(field, "."); ok {
+			nested[name] = append(nested[name], rest)
+		} else {
+			whole[field] = true
+		}
+	}
+	kept := make(map[string]interface{}, len(fields))
+	for name, value := range obj {
+		if whole[name] {
+			kept[name] = value
+		} else if child, ok := value.(map[string]interface{}); ok && nested[name] != nil {
+			kept[name] = selectFields(child, nested[name])
+		}
+	}
+	return kept
+}
+
+// selectListFields applies selectFields to every record of list.
+func selectListFields(list []map[string]interface{}, fields []string) {
+	for i, obj := range list {
+		list[i] = selectFields(obj, fields)
+	}
+}
@@ -0,0 +1,55 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestSparseFieldsets(t *testing.T) {
+	srv := NewServer()
+	srv.currentSchema = createSampleSchema()
+	srv.store = newRecordStore()
+	srv.registerSchema(srv.currentSchema)
+	srv.stores["user"] = srv.store
+
+	rr := performRequest(t, srv.catchAllHandler, http.MethodGet, "/users?fields=id,name", nil)
+	var list []map[string]interface{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &list); err != nil {
+		t.Fatalf("could not decode response: %v", err)
+	}
+	if len(list) != srv.listSize || len(list[0]) != 2 || list[0]["email"] != nil {
+		t.Errorf("handler returned unexpected fields: got %v", rr.Body.String())
+	}
+
+	rr = performRequest(t, srv.catchAllHandler, http.MethodGet, "/users/4?fields=email", nil)
+	var obj map[string]interface{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &obj); err != nil {
+		t.Fatalf("could not decode response: %v", err)
+	}
+	if len(obj) != 1 || obj["email"] == nil {
+		t.Errorf("handler returned unexpected fields: got %v", rr.Body.String())
+	}
+
+	if rr := performRequest(t, srv.catchAllHandler, http.MethodGet, "/users?fields=id,secret", nil); rr.Code != http.StatusBadRequest {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusBadRequest)
+	}
+}
+
+func TestSelectFields(t *testing.T) {
+	obj := map[string]interface{}{
+		"id":      1,
+		"name":    "alice",
+		"address": map[string]interface{}{"city": "Oslo", "zip": "0150"},
+		"tags":    []interface{}{"a"},
+	}
+	got := selectFields(obj, []string{"id", "address.city", "tags.x"})
+	want := map[string]interface{}{"id": 1, "address": map[string]interface{}{"city": "Oslo"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("selectFields returned %v, want %v", got, want)
+	}
+	if got := selectFields(obj, []string{"address.zip", "address"}); !reflect.DeepEqual(got["address"], obj["address"]) {
+		t.Errorf("naming an object did not keep all of it: got %v", got)
+	}
+}
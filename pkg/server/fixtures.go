@@ -0,0 +1,124 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+)
+
+// loadFixtures fills the stores of registered entities with the records in
+// a fixtures document: an object mapping entity names (titles or
+// collection segments, as in /schemas/{entity}/diff) to arrays of records.
+// Each named entity's store is replaced, so loading the same document
+// twice leaves the same records. Records are stored as given, apart from
+// ids assigned to records without one, and must match their schema; if any
+// record is invalid, nothing is loaded and the field errors are returned,
+// with pointers into the document such as /users/0/email.
+//
+// It returns the number of records loaded per collection.
+func (s *Server) loadFixtures(r io.Reader) (map[string]int, []fieldError, error) {
+	var fixtures map[string][]map[string]interface{}
+	decoder := json.NewDecoder(r)
+	if err := decoder.Decode(&fixtures); err != nil {
+		return nil, nil, err
+	}
+	if decoder.More() {
+		return nil, nil, errors.New("unexpected data after the fixtures object")
+	}
+	names := make([]string, 0, len(fixtures))
+	for name := range fixtures {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+	var errs []fieldError
+	entities := make([]*Schema, len(names))
+	for i, name := range names {
+		schema, ok := s.lookupSchema(name)
+		if !ok {
+			return nil, nil, fmt.Errorf("no schema registered for %q", name)
+		}
+		entities[i] = schema
+		for j, record := range fixtures[name] {
+			if record == nil {
+				return nil, nil, fmt.Errorf("%s[%d]: expected an object", name, j)
+			}
+			pointer := fmt.Sprintf("/%s/%d", escapePointerToken(name), j)
+			errs = append(errs, validateRecord(schema, schema.bodyRequired(), record, pointer, true)...)
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errs, nil
+	}
+
+	filled := make([]*recordStore, len(names))
+	for i, name := range names {
+		schema := entities[i]
+		filled[i] = s.newStoreForSchema(schema)
+		for j, record := range fixtures[name] {
+			if err := filled[i].create(record, schema.integerIDs()); err != nil {
+				return nil, nil, fmt.Errorf("%s[%d]: %v", name, j, err)
+			}
+		}
+	}
+	loaded := make(map[string]int, len(names))
+	for i, schema := range entities {
+		s.stores[strings.ToLower(schema.Title)] = filled[i]
+		if schema == s.currentSchema {
+			s.store = filled[i]
+		}
+		loaded[entityName(schema)] = filled[i].len()
+	}
+	return loaded, nil, nil
+}
+
+// loadFixturesFile loads the fixtures document in the named file.
+func (s *Server) loadFixturesFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, errs, err := s.loadFixtures(file)
+	if err != nil {
+		return fmt.Errorf("fixtures %s: %w", path, err)
+	}
+	if len(errs) > 0 {
+		messages := make([]string, len(errs))
+		for i, e := range errs {
+			messages[i] = e.Pointer + ": " + e.Message
+		}
+		return fmt.Errorf("fixtures %s: %s", path, strings.Join(messages, "; "))
+	}
+	return nil
+}
+
+// fixturesHandler loads a fixtures document POSTed to /upload/fixtures.
+func (s *Server) fixturesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w, "Only POST allowed", http.MethodPost)
+		return
+	}
+	defer r.Body.Close()
+	loaded, errs, err := s.loadFixtures(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid fixtures: "+err.Error())
+		return
+	}
+	if len(errs) > 0 {
+		writeValidationErrors(w, errs)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Fixtures loaded successfully",
+		"records": loaded,
+	})
+}
@@ -0,0 +1,79 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFixturesHandler(t *testing.T) {
+	srv := NewServer()
+	srv.currentSchema = createSampleSchema()
+	srv.store = newRecordStore()
+	srv.registerSchema(srv.currentSchema)
+	srv.stores["user"] = srv.store
+
+	fixtures := `{"users": [{"id": 10, "name": "alice", "email": "a@example.com"}, {"name": "bob", "email": "b@example.com"}]}`
+	rr := performRequest(t, srv.fixturesHandler, http.MethodPost, "/upload/fixtures", []byte(fixtures))
+	if status := rr.Code; status != http.StatusOK || !strings.Contains(rr.Body.String(), `"users":2`) {
+		t.Fatalf("handler returned unexpected response: got %v %v", status, rr.Body.String())
+	}
+	// Loading again replaces the records instead of adding to them.
+	performRequest(t, srv.fixturesHandler, http.MethodPost, "/upload/fixtures", []byte(fixtures))
+
+	rr = performRequest(t, srv.catchAllHandler, http.MethodGet, "/users", nil)
+	var list []map[string]interface{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &list); err != nil {
+		t.Fatalf("could not decode response: %v", err)
+	}
+	if len(list) != 2 || list[0]["name"] != "alice" || list[1]["id"] != float64(11) || list[1]["phone"] != nil {
+		t.Errorf("handler returned unexpected records: got %v", rr.Body.String())
+	}
+
+	cases := []struct {
+		name, body string
+		status     int
+		want       string
+	}{
+		{"Invalid Record", `{"users": [{"name": "carol", "email": 5}]}`, http.StatusBadRequest, "/users/0/email"},
+		{"Unknown Entity", `{"widgets": []}`, http.StatusBadRequest, "widgets"},
+		{"Duplicate ID", `{"user": [{"id": 1, "name": "a", "email": "a"}, {"id": 1, "name": "b", "email": "b"}]}`, http.StatusBadRequest, "user[1]"},
+		{"Not An Array", `{"users": {"name": "dave"}}`, http.StatusBadRequest, "Invalid fixtures"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rr := performRequest(t, srv.fixturesHandler, http.MethodPost, "/upload/fixtures", []byte(tc.body))
+			if rr.Code != tc.status || !strings.Contains(rr.Body.String(), tc.want) {
+				t.Errorf("handler returned unexpected response: got %v %v want %v containing %q", rr.Code, rr.Body.String(), tc.status, tc.want)
+			}
+		})
+	}
+	if n := srv.store.len(); n != 2 {
+		t.Errorf("a rejected fixtures document changed the store: got %v records want 2", n)
+	}
+}
+
+func TestWithFixturesFile(t *testing.T) {
+	dir := t.TempDir()
+	schemaPath := filepath.Join(dir, "person.json")
+	fixturesPath := filepath.Join(dir, "fixtures.json")
+	os.WriteFile(schemaPath, []byte(`{"title": "Person", "properties": {"id": {"type": "string"}, "name": {"type": "string"}}}`), 0o644)
+	os.WriteFile(fixturesPath, []byte(`{"people": [{"id": "ada", "name": "Ada"}]}`), 0o644)
+
+	srv, err := New(WithSchemaFile(schemaPath), WithFixturesFile(fixturesPath))
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	rr := performRequest(t, srv.catchAllHandler, http.MethodGet, "/people/ada", nil)
+	if !strings.Contains(rr.Body.String(), `"name":"Ada"`) {
+		t.Errorf("fixture record is not served: got %v", rr.Body.String())
+	}
+
+	os.WriteFile(fixturesPath, []byte(`{"people": [{"name": 7}]}`), 0o644)
+	if _, err := New(WithSchemaFile(schemaPath), WithFixturesFile(fixturesPath)); err == nil || !strings.Contains(err.Error(), "/people/0/name") {
+		t.Errorf("New returned wrong error for an invalid fixture: %v", err)
+	}
+}
@@ -0,0 +1,421 @@
+package server
+
+import (
+	"encoding/base64"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Generation modes control how scalar values vary across generated objects.
+const (
+	// genConstant repeats the same placeholder in every object.
+	genConstant = "constant"
+	// genSequential numbers values by their position: example-1, example-2, ...
+	genSequential = "sequential"
+	// genRandom picks a random value for every object.
+	genRandom = "random"
+)
+
+// genModes lists the valid generation modes.
+var genModes = []string{genConstant, genSequential, genRandom}
+
+// generator holds the per-response state used while generating dummy data.
+type generator struct {
+	// settings are those of the Server the response is for.
+	settings *settings
+	// lastID is the last id handed out, so that every object in a response,
+	// including ones nested inside arrays or other objects, gets a distinct id.
+	lastID int
+	// languages lists the client's preferred languages from Accept-Language,
+	// most preferred first.
+	languages []string
+	// sequence counts how many values have been generated per property
+	// name, numbering sequential values.
+	sequence map[string]int
+	rng      *rand.Rand
+}
+
+// newGenerator returns a generator for a response to r, whose first id is 1.
+// r may be nil when no request is involved.
+func (s *Server) newGenerator(r *http.Request) *generator {
+	source := time.Now().UnixNano()
+	if s.seeded {
+		source = s.seed
+	}
+	g := &generator{
+		settings: &s.settings,
+		sequence: make(map[string]int),
+		rng:      rand.New(rand.NewSource(source)),
+	}
+	if r != nil {
+		g.languages = parseAcceptLanguage(r.Header.Get("Accept-Language"))
+	}
+	return g
+}
+
+// newRecordGenerator returns a generator for the record of schema stored
+// under key, seeded from the seed and the key, so the record's random
+// values are the same whenever it is generated: on every request and, with
+// a seed, across restarts.
+func (s *Server) newRecordGenerator(r *http.Request, schema *Schema, key string) *generator {
+	source := s.runSeed
+	if s.seeded {
+		source = s.seed
+	}
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%d/%s/%s", source, strings.ToLower(schema.Title), key)
+	g := s.newGenerator(r)
+	g.rng = rand.New(rand.NewSource(int64(h.Sum64())))
+	return g
+}
+
+// nextID returns the next unused id.
+func (g *generator) nextID() int {
+	g.lastID++
+	return g.lastID
+}
+
+// Optional field modes control how generated objects represent properties
+// that are not required.
+const (
+	// optionalFill generates a value like for any other property.
+	optionalFill = "fill"
+	// optionalNull sets optional properties to null.
+	optionalNull = "null"
+	// optionalOmit leaves optional properties out.
+	optionalOmit = "omit"
+)
+
+// optionalFieldModes lists the valid optional field modes.
+var optionalFieldModes = []string{optionalFill, optionalNull, optionalOmit}
+
+// dummyData generates a dummy data object based on the schema.
+func (s *Server) dummyData(schema *Schema, g *generator) map[string]interface{} {
+	if schema == nil {
+		return make(map[string]interface{})
+	}
+	// A top-level anyOf or oneOf contributes the fields of its first
+	// alternative; allOf was merged in when the schema was uploaded.
+	properties := schema.Properties
+	if len(schema.AnyOf) > 0 || len(schema.OneOf) > 0 {
+		properties = generationProperty(schema.recordProperty(schema.Required, true)).Properties
+	}
+	var data map[string]interface{}
+	if key := schema.idKey(); key == "id" && schema.idStrategy() == idIncrement {
+		data = g.object(properties, true)
+	} else {
+		// The record's own id comes first, as in object.
+		var id interface{}
+		if schema.idStrategy() != idIncrement {
+			id = newRecordID(schema.idStrategy(), g.rng, formatEpoch)
+		} else if n := g.nextID(); schema.integerIDs() {
+			id = n
+		} else {
+			id = strconv.Itoa(n)
+		}
+		data = g.object(properties, false)
+		data[key] = id
+	}
+
+	mode := s.optionalFields
+	if schema.OptionalFields != "" {
+		mode = schema.OptionalFields
+	}
+	if mode != optionalFill {
+		for key := range schema.Properties {
+			if key == schema.idKey() || containsString(schema.Required, key) {
+				continue
+			}
+			if mode == optionalNull {
+				data[key] = nil
+			} else {
+				delete(data, key)
+			}
+		}
+	}
+	return data
+}
+
+// formatEpoch is the first timestamp generated for date and date-time
+// properties; later objects advance a day at a time.
+var formatEpoch = time.Date(2024, time.January, 1, 9, 30, 0, 0, time.UTC)
+
+// formatString returns a placeholder satisfying format, or false when the
+// format is unrecognized or needs none. n selects the value like it does
+// for the "example" placeholder, so sequential output stays distinct.
+func formatString(format string, n int) (string, bool) {
+	i := 0
+	if n > 0 {
+		i = n - 1
+	}
+	switch format {
+	case "email":
+		return fmt.Sprintf("user%d@example.com", i+1), true
+	case "uuid":
+		return fmt.Sprintf("00000000-0000-4000-8000-%012x", i+1), true
+	case "date-time":
+		return formatEpoch.AddDate(0, 0, i).Format(time.RFC3339), true
+	case "date":
+		return formatEpoch.AddDate(0, 0, i).Format("2006-01-02"), true
+	case "uri":
+		return fmt.Sprintf("https://example.com/%d", i+1), true
+	case "ipv4":
+		// 192.0.2.0/24 is reserved for documentation.
+		return fmt.Sprintf("192.0.2.%d", i%254+1), true
+	case "ipv6":
+		return fmt.Sprintf("2001:db8::%x", i+1), true
+	}
+	return "", false
+}
+
+// object builds a dummy object for the given properties. Records (top-level
+// objects and elements of nested collections) get an id unless they declare
+// a non-integer one, other objects only when they declare an integer id.
+// The object's own id is drawn before its children's and properties are
+// visited in name order, so the ids in a response are stable from one
+// request to the next.
+func (g *generator) object(properties map[string]Property, record bool) map[string]interface{} {
+	data := make(map[string]interface{})
+	if prop, declared := properties["id"]; (record && !declared) || (declared && prop.Type == "integer") {
+		data["id"] = g.nextID()
+	}
+	keys := make([]string, 0, len(properties))
+	for key := range properties {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if _, done := data[key]; done {
+			continue
+		}
+		data[key] = g.value(key, properties[key])
+	}
+	return data
+}
+
+// value builds a dummy value for the property called name.
+func (g *generator) value(name string, prop Property) interface{} {
+	if len(prop.Localized) > 0 {
+		return g.localized(prop.Localized)
+	}
+	prop = generationProperty(prop)
+	if prop.If != nil {
+		return g.conditional(name, prop)
+	}
+
+	mode := g.settings.genMode
+	if prop.GenMode != "" {
+		mode = prop.GenMode
+	}
+	n := 0
+	switch mode {
+	case genSequential:
+		g.sequence[name]++
+		n = g.sequence[name]
+	case genRandom:
+		n = g.rng.Intn(1000) + 1
+	}
+
+	// Const, default, example and enum values are assumed to satisfy the
+	// type, format and other constraints, which upload validation checks,
+	// so they take precedence over generated values.
+	if prop.Const != nil {
+		return prop.Const
+	}
+	// The values the schema documents are what its readers expect: the
+	// default in constant mode, and the examples in turn or at random in
+	// the other modes. Each stands in for the other when missing.
+	if len(prop.Examples) > 0 && (mode != genConstant || prop.Default == nil) {
+		switch mode {
+		case genSequential:
+			return prop.Examples[(n-1)%len(prop.Examples)]
+		case genRandom:
+			return prop.Examples[g.rng.Intn(len(prop.Examples))]
+		}
+		return prop.Examples[0]
+	}
+	if prop.Default != nil {
+		return prop.Default
+	}
+	if len(prop.Enum) > 0 {
+		switch mode {
+		case genSequential:
+			return prop.Enum[(n-1)%len(prop.Enum)]
+		case genRandom:
+			return prop.Enum[g.rng.Intn(len(prop.Enum))]
+		}
+		return prop.Enum[0]
+	}
+
+	switch prop.Type {
+	case "string":
+		if g.settings.fakerMode {
+			if fake, ok := fakeString(name, prop.Format, n); ok {
+				return fitString(prop, fake, n)
+			}
+		}
+		if formatted, ok := formatString(prop.Format, n); ok {
+			return formatted
+		}
+		s := "example"
+		if n > 0 {
+			s = fmt.Sprintf("example-%d", n)
+		}
+		// Binary content travels as base64 in JSON, so the placeholder
+		// must decode cleanly.
+		if prop.Format == "byte" || prop.Format == "binary" {
+			return base64.StdEncoding.EncodeToString([]byte(s))
+		}
+		return fitString(prop, s, n)
+	case "integer", "number":
+		// Numbers are always encoded with '.' by encoding/json,
+		// regardless of the server's locale.
+		return numberValue(prop, n)
+	case "boolean":
+		return n%2 == 1
+	case "object":
+		return g.object(prop.Properties, false)
+	case "array":
+		list := []interface{}{}
+		if len(prop.PrefixItems) > 0 {
+			for i, item := range prop.PrefixItems {
+				list = append(list, g.value(name+"["+strconv.Itoa(i)+"]", item))
+			}
+			return list
+		}
+		if prop.Items == nil {
+			return list
+		}
+		length := g.settings.arrayLength
+		if prop.MinItems != nil && length < *prop.MinItems {
+			length = *prop.MinItems
+		}
+		if prop.MaxItems != nil && length > *prop.MaxItems {
+			length = *prop.MaxItems
+		}
+		items := generationProperty(*prop.Items)
+		// Numbering the items keeps them apart.
+		if prop.UniqueItems && items.GenMode == "" {
+			items.GenMode = genSequential
+		}
+		for i := 0; i < length; i++ {
+			// Elements of a nested collection are identified like
+			// top-level records even if their schema omits an id.
+			if items.Type == "object" {
+				list = append(list, g.object(items.Properties, true))
+				continue
+			}
+			list = append(list, g.value(name+"[]", items))
+		}
+		if prop.Contains != nil && len(validateArrayKeywords(name, Property{Contains: prop.Contains}, asJSON(list).([]interface{}), "")) > 0 {
+			item := g.value(name+"[]", mergeProperty(*prop.Contains, items))
+			if len(list) > 0 {
+				list[0] = item
+			} else {
+				list = append(list, item)
+			}
+		}
+		return list
+	default:
+		return nil
+	}
+}
+
+// conditional generates a value for a property with if/then/else. The
+// value generated without them decides the branch, which is then merged in
+// and the value generated again in its place.
+func (g *generator) conditional(name string, prop Property) interface{} {
+	cond, then, otherwise := *prop.If, prop.Then, prop.Else
+	prop.If, prop.Then, prop.Else = nil, nil, nil
+	lastID, sequence := g.lastID, make(map[string]int, len(g.sequence))
+	for key, n := range g.sequence {
+		sequence[key] = n
+	}
+	value := g.value(name, prop)
+	branch := otherwise
+	if len(validateValue(name, cond, asJSON(value), "")) == 0 {
+		branch = then
+	}
+	if branch == nil {
+		return value
+	}
+	g.lastID, g.sequence = lastID, sequence
+	return g.value(name, mergeProperty(*branch, prop))
+}
+
+// localized picks the value for the client's most preferred language,
+// matching either the full tag ("en-GB") or its primary subtag ("en"). Tags
+// are compared case-insensitively, so a "pt-BR" key serves "pt-br". It
+// falls back to the "default" entry, then to the first language in
+// alphabetical order.
+func (g *generator) localized(values map[string]interface{}) interface{} {
+	lookup := func(tag string) (interface{}, bool) {
+		for key, value := range values {
+			if strings.EqualFold(key, tag) {
+				return value, true
+			}
+		}
+		return nil, false
+	}
+	for _, lang := range g.languages {
+		if value, ok := lookup(lang); ok {
+			return value
+		}
+		if primary, _, found := strings.Cut(lang, "-"); found {
+			if value, ok := lookup(primary); ok {
+				return value
+			}
+		}
+	}
+	if value, ok := values["default"]; ok {
+		return value
+	}
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return values[keys[0]]
+}
+
+// parseAcceptLanguage returns the language tags of an Accept-Language header
+// in lower case, ordered by quality. Wildcards and q=0 entries are dropped.
+func parseAcceptLanguage(header string) []string {
+	type weighted struct {
+		tag string
+		q   float64
+	}
+	var tags []weighted
+	for _, part := range strings.Split(header, ",") {
+		params := strings.Split(part, ";")
+		tag := strings.ToLower(strings.TrimSpace(params[0]))
+		if tag == "" || tag == "*" {
+			continue
+		}
+		q := 1.0
+		for _, param := range params[1:] {
+			name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if strings.EqualFold(name, "q") {
+				if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		if q > 0 {
+			tags = append(tags, weighted{tag, q})
+		}
+	}
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].q > tags[j].q })
+
+	languages := make([]string, len(tags))
+	for i, t := range tags {
+		languages[i] = t.tag
+	}
+	return languages
+}
@@ -0,0 +1,392 @@
+package server
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// collectIDs walks a generated value and records every object id it finds.
+func collectIDs(t *testing.T, value interface{}, seen map[int]bool) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if id, ok := v["id"].(int); ok {
+			if seen[id] {
+				t.Errorf("id %v was generated more than once", id)
+			}
+			seen[id] = true
+		}
+		for _, child := range v {
+			collectIDs(t, child, seen)
+		}
+	case []interface{}:
+		for _, child := range v {
+			collectIDs(t, child, seen)
+		}
+	}
+}
+
+func TestGenerateNestedIDs(t *testing.T) {
+	srv := NewServer()
+	properties := map[string]Property{
+		"id":   {Type: "integer"},
+		"name": {Type: "string"},
+		"orders": {Type: "array", Items: &Property{
+			Type: "object",
+			Properties: map[string]Property{
+				"total": {Type: "number"},
+				"lines": {Type: "array", Items: &Property{
+					Type:       "object",
+					Properties: map[string]Property{"sku": {Type: "string"}},
+				}},
+			},
+		}},
+	}
+
+	gen := srv.newGenerator(nil)
+	seen := make(map[int]bool)
+	var first map[string]interface{}
+	for i := 0; i < 3; i++ {
+		obj := gen.object(properties, true)
+		if i == 0 {
+			first = obj
+		}
+		collectIDs(t, obj, seen)
+	}
+
+	// 3 records, each with 2 orders, each with 2 lines.
+	if want := 3 * (1 + 2*(1+2)); len(seen) != want {
+		t.Errorf("generated wrong number of distinct ids: got %v want %v", len(seen), want)
+	}
+	if first["id"] != 1 {
+		t.Errorf("first record has wrong id: got %v want %v", first["id"], 1)
+	}
+	orders := first["orders"].([]interface{})
+	if got := orders[0].(map[string]interface{})["id"]; got != 2 {
+		t.Errorf("first nested order has wrong id: got %v want %v", got, 2)
+	}
+
+	// Generation is stable across responses.
+	again := srv.newGenerator(nil).object(properties, true)
+	if again["orders"].([]interface{})[1].(map[string]interface{})["id"] != orders[1].(map[string]interface{})["id"] {
+		t.Errorf("nested ids differ between responses")
+	}
+}
+
+func TestGenerateStringIDRecord(t *testing.T) {
+	srv := NewServer()
+	obj := srv.newGenerator(nil).object(map[string]Property{"id": {Type: "string"}}, true)
+	if obj["id"] != "example" {
+		t.Errorf("string id was replaced with generated integer: got %v", obj["id"])
+	}
+}
+
+func TestGenerateNumbersLocaleIndependent(t *testing.T) {
+	srv := NewServer()
+	// encoding/json never consults the locale; guard against a future
+	// switch to locale-aware formatting.
+	t.Setenv("LC_ALL", "de_DE.UTF-8")
+	t.Setenv("LANG", "de_DE.UTF-8")
+
+	properties := map[string]Property{
+		"ratio": {Type: "number", Format: "float"},
+		"score": {Type: "number", Format: "double"},
+	}
+	encoded, err := json.Marshal(srv.newGenerator(nil).object(properties, false))
+	if err != nil {
+		t.Fatalf("could not encode generated object: %v", err)
+	}
+	if got := string(encoded); !strings.Contains(got, `"ratio":0.5`) || strings.Contains(got, "0,5") {
+		t.Errorf("generated numbers were not encoded with a '.' separator: got %v", got)
+	}
+}
+
+func TestParseAcceptLanguage(t *testing.T) {
+	got := strings.Join(parseAcceptLanguage("fr;q=0.5, es-MX, en;q=0.8, *;q=0.1, de;q=0"), ",")
+	if want := "es-mx,en,fr"; got != want {
+		t.Errorf("parseAcceptLanguage returned %v, want %v", got, want)
+	}
+}
+
+func TestGenerateLocalized(t *testing.T) {
+	srv := NewServer()
+	properties := map[string]Property{
+		"greeting": {Type: "string", Localized: map[string]interface{}{
+			"en":      "Hello",
+			"es":      "Hola",
+			"pt-BR":   "Olá",
+			"default": "Hi",
+		}},
+	}
+	tests := []struct {
+		acceptLanguage string
+		want           string
+	}{
+		{"es", "Hola"},
+		{"es-MX,en;q=0.5", "Hola"},
+		{"de, en;q=0.8", "Hello"},
+		{"pt-BR", "Olá"},
+		{"pt-br, en;q=0.5", "Olá"},
+		{"pt-PT", "Hi"},
+		{"ja", "Hi"},
+		{"", "Hi"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/greetings", nil)
+		req.Header.Set("Accept-Language", tt.acceptLanguage)
+		obj := srv.newGenerator(req).object(properties, false)
+		if obj["greeting"] != tt.want {
+			t.Errorf("Accept-Language %q: got %v want %v", tt.acceptLanguage, obj["greeting"], tt.want)
+		}
+	}
+
+	// Without a default entry the first language alphabetically is used.
+	delete(properties["greeting"].Localized, "default")
+	if obj := srv.newGenerator(nil).object(properties, false); obj["greeting"] != "Hello" {
+		t.Errorf("fallback without default: got %v want %v", obj["greeting"], "Hello")
+	}
+}
+
+func TestGenerateBase64(t *testing.T) {
+	srv := NewServer()
+	for _, format := range []string{"byte", "binary"} {
+		value := srv.newGenerator(nil).value("blob", Property{Type: "string", Format: format})
+		s, ok := value.(string)
+		if !ok {
+			t.Fatalf("format %v: generated non-string %v", format, value)
+		}
+		if _, err := base64.StdEncoding.DecodeString(s); err != nil {
+			t.Errorf("format %v: generated invalid base64 %q: %v", format, s, err)
+		}
+	}
+}
+
+func TestGenerateFormats(t *testing.T) {
+	srv := NewServer()
+	for _, format := range []string{"email", "uuid", "date-time", "date", "uri", "ipv4", "ipv6"} {
+		g := srv.newGenerator(nil)
+		seen := map[interface{}]bool{}
+		for _, mode := range []string{genConstant, genSequential, genSequential, genRandom} {
+			value := g.value("field", Property{Type: "string", Format: format, GenMode: mode})
+			s, ok := value.(string)
+			if !ok {
+				t.Fatalf("format %v: generated non-string %v", format, value)
+			}
+			if err := checkStringFormat(format, s); err != nil {
+				t.Errorf("format %v: generated %q: %v", format, s, err)
+			}
+			seen[value] = true
+		}
+		if len(seen) < 2 {
+			t.Errorf("format %v: generated the same value in every mode: %v", format, seen)
+		}
+	}
+}
+
+func TestGenerateModes(t *testing.T) {
+	srv := NewServer()
+	properties := map[string]Property{
+		"name":   {Type: "string"},
+		"rank":   {Type: "integer", GenMode: genSequential},
+		"active": {Type: "boolean", GenMode: genSequential},
+	}
+
+	gen := srv.newGenerator(nil)
+	var names, ranks []interface{}
+	for i := 0; i < 3; i++ {
+		obj := gen.object(properties, true)
+		names = append(names, obj["name"])
+		ranks = append(ranks, obj["rank"])
+		if want := i%2 == 0; obj["active"] != want {
+			t.Errorf("row %d: sequential boolean = %v, want %v", i, obj["active"], want)
+		}
+	}
+	for i, name := range names {
+		if name != "example" {
+			t.Errorf("row %d: constant string = %v, want example", i, name)
+		}
+		if ranks[i] != i+1 {
+			t.Errorf("row %d: sequential integer = %v, want %v", i, ranks[i], i+1)
+		}
+	}
+
+	srv.genMode = genSequential
+	gen = srv.newGenerator(nil)
+	first, second := gen.object(properties, true), gen.object(properties, true)
+	if first["name"] != "example-1" || second["name"] != "example-2" {
+		t.Errorf("global sequential mode produced %v, %v", first["name"], second["name"])
+	}
+}
+
+func TestGenerateRandomMode(t *testing.T) {
+	srv := NewServer()
+	prop := Property{Type: "integer", GenMode: genRandom}
+	gen := srv.newGenerator(nil)
+	seen := make(map[interface{}]bool)
+	for i := 0; i < 20; i++ {
+		seen[gen.value("n", prop)] = true
+	}
+	if len(seen) < 2 {
+		t.Errorf("random mode produced a single value: %v", seen)
+	}
+}
+
+func TestOptionalFields(t *testing.T) {
+	srv := NewServer()
+	schema := &Schema{
+		Title: "User",
+		Properties: map[string]Property{
+			"id":       {Type: "integer"},
+			"name":     {Type: "string"},
+			"nickname": {Type: "string"},
+		},
+		Required: []string{"name"},
+	}
+
+	obj := srv.dummyData(schema, srv.newGenerator(nil))
+	if obj["nickname"] != "example" {
+		t.Errorf("fill mode: nickname = %v, want example", obj["nickname"])
+	}
+
+	schema.OptionalFields = optionalNull
+	obj = srv.dummyData(schema, srv.newGenerator(nil))
+	if value, ok := obj["nickname"]; !ok || value != nil {
+		t.Errorf("null mode: nickname = %v (present %v), want null", value, ok)
+	}
+	if obj["name"] != "example" || obj["id"] != 1 {
+		t.Errorf("null mode changed required fields or id: got %v", obj)
+	}
+
+	schema.OptionalFields = ""
+	srv.optionalFields = optionalOmit
+	obj = srv.dummyData(schema, srv.newGenerator(nil))
+	if _, ok := obj["nickname"]; ok {
+		t.Errorf("omit mode: nickname is present")
+	}
+	if _, ok := obj["id"]; !ok {
+		t.Errorf("omit mode dropped the id")
+	}
+}
+
+func TestGenerateEnum(t *testing.T) {
+	srv := NewServer()
+	prop := Property{Type: "string", Format: "email", Enum: []interface{}{"admin@example.com", "ops@example.com"}}
+	if got := srv.newGenerator(nil).value("contact", prop); got != "admin@example.com" {
+		t.Errorf("constant mode picked %v, want the first enum value", got)
+	}
+
+	prop.GenMode = genSequential
+	gen := srv.newGenerator(nil)
+	for i, want := range []string{"admin@example.com", "ops@example.com", "admin@example.com"} {
+		if got := gen.value("contact", prop); got != want {
+			t.Errorf("sequential value %d = %v, want %v", i, got, want)
+		}
+	}
+}
+
+func TestGenerateDocumentedValues(t *testing.T) {
+	srv := NewServer()
+	prop := Property{Type: "string", Default: "draft", Examples: []interface{}{"draft", "published"}}
+	if got := srv.newGenerator(nil).value("status", prop); got != "draft" {
+		t.Errorf("constant mode picked %v, want the default", got)
+	}
+
+	prop.GenMode = genSequential
+	gen := srv.newGenerator(nil)
+	for i, want := range []string{"draft", "published", "draft"} {
+		if got := gen.value("status", prop); got != want {
+			t.Errorf("sequential value %d = %v, want %v", i, got, want)
+		}
+	}
+
+	prop = Property{Type: "integer", Examples: []interface{}{42.0}}
+	if got := srv.newGenerator(nil).value("answer", prop); got != 42.0 {
+		t.Errorf("constant mode without a default picked %v, want the first example", got)
+	}
+	zero := 0.0
+	if err := srv.validateProperty("age", Property{Type: "integer", Minimum: &zero, Default: -1.0}); err == nil || !strings.Contains(err.Error(), "default value") {
+		t.Errorf("invalid default was accepted: got %v", err)
+	}
+}
+
+func TestGenerateTuple(t *testing.T) {
+	srv := NewServer()
+	prop := Property{Type: "array", PrefixItems: []Property{{Type: "string"}, {Type: "integer"}, {Type: "number", Format: "double"}}}
+	got, ok := srv.newGenerator(nil).value("pair", prop).([]interface{})
+	if !ok {
+		t.Fatalf("tuple was not generated as an array")
+	}
+	want := []interface{}{"example", 1, 0.5}
+	if len(got) != len(want) {
+		t.Fatalf("tuple has wrong length: got %v want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("tuple element %d = %#v, want %#v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestGenerateArrayLength(t *testing.T) {
+	srv := NewServer()
+	one, five := 1, 5
+	properties := map[string]Property{
+		"tags":   {Type: "array", Items: &Property{Type: "string"}},
+		"single": {Type: "array", Items: &Property{Type: "string"}, MaxItems: &one},
+		"many":   {Type: "array", Items: &Property{Type: "object"}, MinItems: &five},
+	}
+
+	srv.arrayLength = 3
+	obj := srv.newGenerator(nil).object(properties, true)
+	for name, want := range map[string]int{"tags": 3, "single": 1, "many": 5} {
+		if got := len(obj[name].([]interface{})); got != want {
+			t.Errorf("%s has wrong length: got %v want %v", name, got, want)
+		}
+	}
+}
+
+func TestSeededGeneration(t *testing.T) {
+	var srv *Server
+	schema := `{"title": "User", "properties": {"id": {"type": "integer"}, "score": {"type": "integer", "x-gen-mode": "random"}, "tags": {"type": "array", "items": {"type": "string", "enum": ["a", "b", "c", "d"], "x-gen-mode": "random"}}}}`
+	get := func(path string) string {
+		t.Helper()
+		rr := performRequest(t, srv.catchAllHandler, http.MethodGet, path, nil)
+		if status := rr.Code; status != http.StatusOK {
+			t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+		}
+		return rr.Body.String()
+	}
+	run := func(opts ...Option) (string, string, string) {
+		t.Helper()
+		srv = NewServer(opts...)
+		if rr := performRequest(t, srv.uploadHandler, http.MethodPost, "/upload", []byte(schema)); rr.Code != http.StatusOK {
+			t.Fatalf("upload failed: %v", rr.Body.String())
+		}
+		return get("/users/7"), get("/users/8"), get("/users")
+	}
+
+	first7, first8, firstList := run(WithSeed(42))
+	if again := get("/users/7"); again != first7 {
+		t.Errorf("the same id generated different objects: %v and %v", first7, again)
+	}
+	if first7 == strings.Replace(first8, `"id":8`, `"id":7`, 1) {
+		t.Errorf("different ids generated the same object: %v", first7)
+	}
+	// A restart with the same seed reproduces everything.
+	second7, second8, secondList := run(WithSeed(42))
+	if second7 != first7 || second8 != first8 || secondList != firstList {
+		t.Errorf("seeded runs differ:\n%v %v %v\n%v %v %v", first7, first8, firstList, second7, second8, secondList)
+	}
+	if other7, _, _ := run(WithSeed(43)); other7 == first7 {
+		t.Errorf("a different seed generated the same object: %v", other7)
+	}
+
+	// Without a seed, ids still agree within a run.
+	unseeded, _, _ := run()
+	if again := get("/users/7"); again != unseeded {
+		t.Errorf("the same id generated different objects without a seed: %v and %v", unseeded, again)
+	}
+}
@@ -0,0 +1,992 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"mime"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// graphQLContentType is the media type of a POST /graphql body holding the
+// bare query rather than a JSON request.
+const graphQLContentType = "application/graphql"
+
+// Operations a root field runs against its entity's store.
+const (
+	gqlList   = "list"
+	gqlGet    = "get"
+	gqlCreate = "create"
+	gqlUpdate = "update"
+	gqlDelete = "delete"
+)
+
+// gqlActionMethods maps each operation to the method of the REST request
+// doing the same, whose access rules it is subject to.
+var gqlActionMethods = map[string]string{
+	gqlList:   http.MethodGet,
+	gqlGet:    http.MethodGet,
+	gqlCreate: http.MethodPost,
+	gqlUpdate: http.MethodPut,
+	gqlDelete: http.MethodDelete,
+}
+
+// gqlEntity is an uploaded schema as the GraphQL API exposes it.
+type gqlEntity struct {
+	schema   *Schema
+	records  *recordStore
+	typeName string
+}
+
+// gqlRootField is a Query or Mutation field: the entity and operation it
+// runs.
+type gqlRootField struct {
+	name   string
+	entity *gqlEntity
+	action string
+}
+
+// gqlAPI is the GraphQL schema derived from every uploaded JSON schema at
+// the time of a request.
+type gqlAPI struct {
+	entities  []*gqlEntity
+	queries   []gqlRootField
+	mutations []gqlRootField
+}
+
+// gqlObjectType is an output object type: an entity, or a nested object of
+// one. Records (entities and elements of nested collections) always carry
+// an id, so their types have one too.
+type gqlObjectType struct {
+	name       string
+	properties map[string]Property
+	record     bool
+	// idKey names the id of an entity's records when it is not id.
+	idKey string
+	// input marks input types, which leave out readOnly properties where
+	// result types leave out writeOnly ones.
+	input bool
+}
+
+// graphQLAPI derives the GraphQL schema of the registered entities. Each
+// gets a type named after its title, list and get queries, and create,
+// update and delete mutations, as far as its methods allow.
+func (s *Server) graphQLAPI() *gqlAPI {
+	s.stateMu.RLock()
+	var entities []*gqlEntity
+	if s.currentSchema != nil {
+		entities = append(entities, &gqlEntity{schema: s.currentSchema, records: s.store})
+	}
+	for _, key := range s.sortedSchemaKeys() {
+		if s.currentSchema != nil && key == strings.ToLower(s.currentSchema.Title) {
+			continue
+		}
+		entities = append(entities, &gqlEntity{schema: s.schemas[key], records: s.stores[key]})
+	}
+	s.stateMu.RUnlock()
+
+	api := &gqlAPI{}
+	for _, entity := range entities {
+		if entity.records == nil {
+			continue
+		}
+		entity.typeName = graphQLName(entity.schema.Title, true)
+		api.entities = append(api.entities, entity)
+	}
+	sort.Slice(api.entities, func(i, j int) bool { return api.entities[i].typeName < api.entities[j].typeName })
+	for _, entity := range api.entities {
+		schema, name := entity.schema, entity.typeName
+		single := graphQLName(entity.schema.Title, false)
+		plural := pluralize(single)
+		if plural == single {
+			plural += "List" // uncountable, e.g. equipment
+		}
+		if schema.allowsMethod(http.MethodGet) {
+			api.queries = append(api.queries,
+				gqlRootField{name: plural, entity: entity, action: gqlList},
+				gqlRootField{name: single, entity: entity, action: gqlGet})
+		}
+		if schema.allowsMethod(http.MethodPost) {
+			api.mutations = append(api.mutations, gqlRootField{name: "create" + name, entity: entity, action: gqlCreate})
+		}
+		if schema.allowsMethod(http.MethodPut) {
+			api.mutations = append(api.mutations, gqlRootField{name: "update" + name, entity: entity, action: gqlUpdate})
+		}
+		if schema.allowsMethod(http.MethodDelete) {
+			api.mutations = append(api.mutations, gqlRootField{name: "delete" + name, entity: entity, action: gqlDelete})
+		}
+	}
+	return api
+}
+
+// graphQLName turns a title or property name into a GraphQL name: its
+// words run together in camel case, starting upper case for type names.
+func graphQLName(text string, upper bool) string {
+	var b strings.Builder
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return r > unicode.MaxASCII || !(r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r))
+	})
+	for i, word := range words {
+		if i > 0 || upper {
+			word = strings.ToUpper(word[:1]) + word[1:]
+		} else {
+			word = strings.ToLower(word[:1]) + word[1:]
+		}
+		b.WriteString(word)
+	}
+	name := b.String()
+	if name == "" || name[0] >= '0' && name[0] <= '9' {
+		name = "_" + name
+	}
+	return name
+}
+
+// validGraphQLName reports whether a property name can be a GraphQL field
+// name as it is. Other properties are left out of the GraphQL schema.
+func validGraphQLName(name string) bool {
+	if name == "" || strings.HasPrefix(name, "__") || name[0] >= '0' && name[0] <= '9' {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		if name[i] != '_' && !isAlnum(name[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// fields returns the properties of an object type, adding the id records
+// are given when their schema leaves it out.
+func (t gqlObjectType) fields() map[string]Property {
+	fields := make(map[string]Property, len(t.properties)+1)
+	for name, prop := range t.properties {
+		if t.input && prop.ReadOnly || !t.input && prop.WriteOnly {
+			continue
+		}
+		fields[name] = prop
+	}
+	if _, declared := t.properties[t.id()]; !declared && t.record {
+		fields[t.id()] = Property{Type: "integer"}
+	}
+	return fields
+}
+
+// id returns the name of the id of t's records.
+func (t gqlObjectType) id() string {
+	if t.idKey != "" {
+		return t.idKey
+	}
+	return "id"
+}
+
+// child returns the type of the object property name of t.
+func (t gqlObjectType) child(name string, prop Property, record bool) gqlObjectType {
+	return gqlObjectType{name: t.name + graphQLName(name, true), properties: prop.Properties, record: record, input: t.input}
+}
+
+// gqlScalar returns the scalar type of a property, or "" for objects and
+// lists, which have types of their own. Ids are IDs, and anything without
+// a more precise type is the JSON scalar.
+func gqlScalar(name string, prop Property) string {
+	switch {
+	case name == "id" && (prop.Type == "integer" || prop.Type == "string"):
+		return "ID"
+	case prop.Type == "object" && len(prop.Properties) > 0,
+		prop.Type == "array" && prop.Items != nil && len(prop.PrefixItems) == 0:
+		return ""
+	case prop.Type == "string":
+		return "String"
+	case prop.Type == "integer":
+		return "Int"
+	case prop.Type == "number":
+		return "Float"
+	case prop.Type == "boolean":
+		return "Boolean"
+	}
+	return "JSON"
+}
+
+// sortedFieldNames returns the names of properties usable as GraphQL
+// fields, in order.
+func sortedFieldNames(properties map[string]Property) []string {
+	names := make([]string, 0, len(properties))
+	for name := range properties {
+		if validGraphQLName(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// sdl renders the API in the GraphQL schema definition language.
+func (api *gqlAPI) sdl() string {
+	var types []string
+	seen := make(map[string]bool)
+	var object func(t gqlObjectType)
+	var input func(name string, properties map[string]Property, record bool)
+
+	// typeRef returns the type of a field, declaring the types it needs.
+	var typeRef func(parent gqlObjectType, name string, prop Property, record, isInput bool) string
+	typeRef = func(parent gqlObjectType, name string, prop Property, record, isInput bool) string {
+		if scalar := gqlScalar(name, prop); scalar != "" {
+			return scalar
+		}
+		if prop.Type == "array" {
+			return "[" + typeRef(parent, name, *prop.Items, true, isInput) + "]"
+		}
+		child := parent.child(name, prop, record)
+		if isInput {
+			input(child.name+"Input", child.properties, record)
+			return child.name + "Input"
+		}
+		object(child)
+		return child.name
+	}
+	object = func(t gqlObjectType) {
+		if seen[t.name] {
+			return
+		}
+		seen[t.name] = true
+		var b strings.Builder
+		fmt.Fprintf(&b, "type %s {\n", t.name)
+		at := len(types)
+		types = append(types, "")
+		fields := t.fields()
+		for _, name := range sortedFieldNames(fields) {
+			ref := typeRef(t, name, fields[name], false, false)
+			if name == t.id() && t.record {
+				ref += "!"
+			}
+			fmt.Fprintf(&b, "  %s: %s\n", name, ref)
+		}
+		b.WriteString("}\n")
+		types[at] = b.String()
+	}
+	input = func(name string, properties map[string]Property, record bool) {
+		if seen[name] {
+			return
+		}
+		seen[name] = true
+		var b strings.Builder
+		fmt.Fprintf(&b, "input %s {\n", name)
+		at := len(types)
+		types = append(types, "")
+		t := gqlObjectType{name: strings.TrimSuffix(name, "Input"), properties: properties, record: record, input: true}
+		fields := t.fields()
+		for _, field := range sortedFieldNames(fields) {
+			fmt.Fprintf(&b, "  %s: %s\n", field, typeRef(t, field, fields[field], false, true))
+		}
+		b.WriteString("}\n")
+		types[at] = b.String()
+	}
+
+	for _, entity := range api.entities {
+		object(gqlObjectType{name: entity.typeName, properties: entity.schema.Properties, record: true, idKey: entity.schema.IDProperty})
+	}
+	root := func(kind string, fields []gqlRootField) {
+		var b strings.Builder
+		fmt.Fprintf(&b, "type %s {\n", kind)
+		for _, field := range fields {
+			name := field.entity.typeName
+			switch field.action {
+			case gqlList:
+				fmt.Fprintf(&b, "  %s(page: Int, limit: Int, sort: String): [%s!]!\n", field.name, name)
+			case gqlGet:
+				fmt.Fprintf(&b, "  %s(id: ID!): %s\n", field.name, name)
+			case gqlCreate:
+				input(name+"Input", field.entity.schema.Properties, true)
+				fmt.Fprintf(&b, "  %s(input: %sInput!): %s!\n", field.name, name, name)
+			case gqlUpdate:
+				input(name+"Input", field.entity.schema.Properties, true)
+				fmt.Fprintf(&b, "  %s(id: ID!, input: %sInput!): %s!\n", field.name, name, name)
+			case gqlDelete:
+				fmt.Fprintf(&b, "  %s(id: ID!): Boolean!\n", field.name)
+			}
+		}
+		if len(fields) == 0 {
+			// A schema must have a Query type, and types must have fields.
+			b.WriteString("  _empty: Boolean\n")
+		}
+		b.WriteString("}\n")
+		types = append(types, b.String())
+	}
+	root("Query", api.queries)
+	if len(api.mutations) > 0 {
+		root("Mutation", api.mutations)
+	}
+	return "scalar JSON\n\n" + strings.Join(types, "\n")
+}
+
+// gqlObject is a result object. Its fields keep the order of the
+// selection, as GraphQL requires.
+type gqlObject struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func (o *gqlObject) set(key string, value interface{}) {
+	if _, ok := o.values[key]; !ok {
+		o.keys = append(o.keys, key)
+	}
+	o.values[key] = value
+}
+
+// MarshalJSON writes the fields in selection order.
+func (o *gqlObject) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, key := range o.keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		name, _ := json.Marshal(key)
+		value, err := json.Marshal(o.values[key])
+		if err != nil {
+			return nil, err
+		}
+		b.Write(name)
+		b.WriteByte(':')
+		b.Write(value)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+// gqlExecutor runs one operation of a request.
+type gqlExecutor struct {
+	server *Server
+	api    *gqlAPI
+	doc    *gqlDocument
+	vars   map[string]interface{}
+	w      http.ResponseWriter
+	r      *http.Request
+	errors []*gqlError
+}
+
+// fail records a field error; the field's value becomes null.
+func (e *gqlExecutor) fail(field gqlSelection, path []interface{}, err error) {
+	gerr, ok := err.(*gqlError)
+	if !ok {
+		gerr = &gqlError{Message: err.Error()}
+	}
+	gerr.Locations = []gqlLocation{field.loc}
+	gerr.Path = append([]interface{}(nil), path...)
+	e.errors = append(e.errors, gerr)
+}
+
+// included applies the @skip and @include directives.
+func (e *gqlExecutor) included(directives []gqlArgumentList) bool {
+	for _, d := range directives {
+		for _, arg := range d.arguments {
+			if arg.name != "if" {
+				continue
+			}
+			on := arg.value.resolve(e.vars) == true
+			if d.name == "skip" && on || d.name == "include" && !on {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// collectFields groups the fields selected on an object of the named type
+// by response key, in order, expanding fragments that apply to it.
+func (e *gqlExecutor) collectFields(typeName string, selections []gqlSelection, keys *[]string, groups map[string][]gqlSelection, visited map[string]bool) {
+	for _, sel := range selections {
+		if !e.included(sel.directives) {
+			continue
+		}
+		switch sel.kind {
+		case selectField:
+			key := sel.responseKey()
+			if _, ok := groups[key]; !ok {
+				*keys = append(*keys, key)
+			}
+			groups[key] = append(groups[key], sel)
+		case selectSpread:
+			if visited[sel.name] {
+				continue
+			}
+			visited[sel.name] = true
+			if fragment, ok := e.doc.fragments[sel.name]; ok && fragment.typeCondition == typeName {
+				e.collectFields(typeName, fragment.selections, keys, groups, visited)
+			}
+		case selectInline:
+			if sel.typeCondition == "" || sel.typeCondition == typeName {
+				e.collectFields(typeName, sel.selections, keys, groups, visited)
+			}
+		}
+	}
+}
+
+// fieldGroups collects the fields of a selection set; see collectFields.
+func (e *gqlExecutor) fieldGroups(typeName string, selections []gqlSelection) ([]string, map[string][]gqlSelection) {
+	var keys []string
+	groups := make(map[string][]gqlSelection)
+	e.collectFields(typeName, selections, &keys, groups, make(map[string]bool))
+	return keys, groups
+}
+
+// subselections merges the selection sets of fields sharing a response key.
+func subselections(fields []gqlSelection) []gqlSelection {
+	var merged []gqlSelection
+	for _, field := range fields {
+		merged = append(merged, field.selections...)
+	}
+	return merged
+}
+
+// execute runs an operation's root fields, one after another.
+func (e *gqlExecutor) execute(op *gqlOperation) *gqlObject {
+	rootType, roots := "Query", e.api.queries
+	if op.kind == "mutation" {
+		rootType, roots = "Mutation", e.api.mutations
+	}
+	out := &gqlObject{values: make(map[string]interface{})}
+	keys, groups := e.fieldGroups(rootType, op.selections)
+	for _, key := range keys {
+		fields := groups[key]
+		field, path := fields[0], []interface{}{key}
+		if field.name == "__typename" {
+			out.set(key, rootType)
+			continue
+		}
+		var root *gqlRootField
+		for i := range roots {
+			if roots[i].name == field.name {
+				root = &roots[i]
+			}
+		}
+		if root == nil {
+			out.set(key, nil)
+			e.fail(field, path, fmt.Errorf("Cannot query field %q on type %q.", field.name, rootType))
+			continue
+		}
+		value, err := e.resolveRoot(*root, field)
+		if err != nil {
+			out.set(key, nil)
+			e.fail(field, path, err)
+			continue
+		}
+		out.set(key, e.completeRoot(root.entity, value, field, subselections(fields), path))
+	}
+	return out
+}
+
+// completeRoot shapes what a root field resolved to: a record, a list of
+// records or the result of a delete.
+func (e *gqlExecutor) completeRoot(entity *gqlEntity, value interface{}, field gqlSelection, selections []gqlSelection, path []interface{}) interface{} {
+	t := gqlObjectType{name: entity.typeName, properties: entity.schema.Properties, record: true, idKey: entity.schema.IDProperty}
+	record := func(obj map[string]interface{}, path []interface{}) interface{} {
+		if obj == nil {
+			return nil
+		}
+		return e.selectFields(t, asJSON(obj).(map[string]interface{}), selections, path)
+	}
+	switch v := value.(type) {
+	case bool:
+		if len(selections) > 0 {
+			e.fail(field, path, fmt.Errorf("Field %q must not have a selection since type \"Boolean\" has no subfields.", field.name))
+			return nil
+		}
+		return v
+	case []map[string]interface{}:
+		if len(selections) == 0 {
+			e.fail(field, path, fmt.Errorf("Field %q of type %q must have a selection of subfields.", field.name, "["+t.name+"!]!"))
+			return nil
+		}
+		list := make([]interface{}, len(v))
+		for i, obj := range v {
+			list[i] = record(obj, append(path, i))
+		}
+		return list
+	case map[string]interface{}:
+		if len(selections) == 0 {
+			e.fail(field, path, fmt.Errorf("Field %q of type %q must have a selection of subfields.", field.name, t.name))
+			return nil
+		}
+		return record(v, path)
+	}
+	if len(selections) == 0 {
+		e.fail(field, path, fmt.Errorf("Field %q of type %q must have a selection of subfields.", field.name, t.name))
+	}
+	return nil
+}
+
+// selectFields resolves a selection set on an object of type t.
+func (e *gqlExecutor) selectFields(t gqlObjectType, obj map[string]interface{}, selections []gqlSelection, path []interface{}) *gqlObject {
+	out := &gqlObject{values: make(map[string]interface{})}
+	properties := t.fields()
+	keys, groups := e.fieldGroups(t.name, selections)
+	for _, key := range keys {
+		fields := groups[key]
+		field := fields[0]
+		fieldPath := append(append([]interface{}(nil), path...), key)
+		if field.name == "__typename" {
+			out.set(key, t.name)
+			continue
+		}
+		prop, ok := properties[field.name]
+		if !ok || !validGraphQLName(field.name) {
+			out.set(key, nil)
+			e.fail(field, fieldPath, fmt.Errorf("Cannot query field %q on type %q.", field.name, t.name))
+			continue
+		}
+		out.set(key, e.complete(t, field, field.name, prop, false, obj[field.name], subselections(fields), fieldPath))
+	}
+	return out
+}
+
+// complete shapes the value of the property name of an object of type
+// parent: scalars are coerced, objects resolve their selection and lists
+// complete each element.
+func (e *gqlExecutor) complete(parent gqlObjectType, field gqlSelection, name string, prop Property, record bool, value interface{}, selections []gqlSelection, path []interface{}) interface{} {
+	if value == nil {
+		return nil
+	}
+	if scalar := gqlScalar(name, prop); scalar != "" {
+		if len(selections) > 0 {
+			e.fail(field, path, fmt.Errorf("Field %q must not have a selection since type %q has no subfields.", field.name, scalar))
+			return nil
+		}
+		return coerceScalar(scalar, value)
+	}
+	if prop.Type == "array" {
+		items, ok := value.([]interface{})
+		if !ok {
+			return nil
+		}
+		list := make([]interface{}, len(items))
+		for i, item := range items {
+			list[i] = e.complete(parent, field, name, *prop.Items, true, item, selections, append(append([]interface{}(nil), path...), i))
+		}
+		return list
+	}
+	t := parent.child(name, prop, record)
+	if len(selections) == 0 {
+		e.fail(field, path, fmt.Errorf("Field %q of type %q must have a selection of subfields.", field.name, t.name))
+		return nil
+	}
+	obj, ok := value.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	return e.selectFields(t, obj, selections, path)
+}
+
+// coerceScalar serializes a JSON value as the scalar type: IDs as strings
+// and whole numbers as Int.
+func coerceScalar(scalar string, value interface{}) interface{} {
+	switch scalar {
+	case "ID":
+		if n, ok := value.(float64); ok {
+			return strconv.FormatFloat(n, 'f', -1, 64)
+		}
+		return fmt.Sprint(value)
+	case "Int":
+		if n, ok := value.(float64); ok && n == math.Trunc(n) {
+			return int64(n)
+		}
+	}
+	return value
+}
+
+// arguments resolves a field's arguments, which must be among names.
+func (e *gqlExecutor) arguments(field gqlSelection, typeName string, names ...string) (map[string]interface{}, error) {
+	args := make(map[string]interface{})
+	for _, arg := range field.arguments {
+		if !containsString(names, arg.name) {
+			return nil, fmt.Errorf("Unknown argument %q on field \"%s.%s\".", arg.name, typeName, field.name)
+		}
+		if value := arg.value.resolve(e.vars); value != nil {
+			args[arg.name] = value
+		}
+	}
+	return args, nil
+}
+
+// requiredArgument returns an argument that must be given.
+func requiredArgument(args map[string]interface{}, field gqlSelection, name, typ string) (interface{}, error) {
+	value, ok := args[name]
+	if !ok {
+		return nil, fmt.Errorf("Field %q argument %q of type %q is required, but it was not provided.", field.name, name, typ)
+	}
+	return value, nil
+}
+
+// graphQLRecordID converts an ID argument into the entity's id type,
+// returning the id and the key it is stored under.
+func graphQLRecordID(schema *Schema, value interface{}) (interface{}, string, error) {
+	if !schema.integerIDs() {
+		key := fmt.Sprint(value)
+		if err := checkRecordID(schema.idStrategy(), key); err != nil {
+			return nil, "", err
+		}
+		return key, key, nil
+	}
+	var n int
+	switch v := value.(type) {
+	case float64:
+		if v != math.Trunc(v) {
+			return nil, "", errInvalidID
+		}
+		n = int(v)
+	case string:
+		var err error
+		if n, err = strconv.Atoi(v); err != nil {
+			return nil, "", errInvalidID
+		}
+	default:
+		return nil, "", errInvalidID
+	}
+	return n, strconv.Itoa(n), nil
+}
+
+// graphQLInput returns the input argument of a create or update, checked
+// against the schema as the matching POST or PUT body would be: as a whole
+// record.
+func (e *gqlExecutor) graphQLInput(args map[string]interface{}, field gqlSelection, entity *gqlEntity) (map[string]interface{}, error) {
+	raw, err := requiredArgument(args, field, "input", entity.typeName+"Input!")
+	if err != nil {
+		return nil, err
+	}
+	input, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("Argument \"input\" of field %q must be an input object.", field.name)
+	}
+	input = deepCopy(input).(map[string]interface{})
+	schema := entity.schema
+	dropReadOnly(schema.Properties, input)
+	if id, ok := input[schema.idKey()]; ok && id != nil {
+		// The ID scalar arrives as a string; store integer ids as numbers,
+		// as in a JSON body.
+		parsed, _, err := graphQLRecordID(schema, id)
+		if err != nil {
+			return nil, err
+		}
+		if n, ok := parsed.(int); ok {
+			input[schema.idKey()] = float64(n)
+		} else {
+			input[schema.idKey()] = parsed
+		}
+	}
+	errs := validateRecord(schema, schema.bodyRequired(), input, "", true)
+	if len(errs) > 0 {
+		if e.server.validationMode != validateWarn {
+			return nil, &gqlError{Message: "Validation failed", Extensions: map[string]interface{}{
+				"code":    "validation_failed",
+				"details": errs,
+			}}
+		}
+		e.server.reportFieldErrors(e.w, errs)
+	}
+	return input, nil
+}
+
+// resolveRoot runs a root field against its entity's store, with the same
+// semantics as the matching REST route.
+func (e *gqlExecutor) resolveRoot(root gqlRootField, field gqlSelection) (interface{}, error) {
+	entity := root.entity
+	schema, records := entity.schema, entity.records
+	typeName := "Query"
+	if root.action != gqlList && root.action != gqlGet {
+		typeName = "Mutation"
+	}
+	// Under -auth, a field is subject to the access rules of its REST
+	// counterpart.
+	if roles, ok := e.r.Context().Value(rolesKey{}).([]string); ok {
+		if rule, denied := e.server.deniedAccess(gqlActionMethods[root.action], entityName(schema), roles); denied {
+			return nil, &gqlError{Message: rule.requirement(), Extensions: map[string]interface{}{"code": errorCode(http.StatusForbidden)}}
+		}
+	}
+
+	switch root.action {
+	case gqlList:
+		args, err := e.arguments(field, typeName, "page", "limit", "sort")
+		if err != nil {
+			return nil, err
+		}
+		query := url.Values{}
+		for name, value := range args {
+			query.Set(name, fmt.Sprint(value))
+		}
+		page, err := parsePagination(query)
+		if err != nil {
+			return nil, errors.New("Invalid pagination: " + err.Error())
+		}
+		listQuery, err := parseListQuery(query, schema)
+		if err != nil {
+			return nil, errors.New("Invalid query: " + err.Error())
+		}
+		list, _ := e.server.listRecords(e.r, schema, records, page, listQuery)
+		return list, nil
+
+	case gqlGet, gqlDelete:
+		args, err := e.arguments(field, typeName, "id")
+		if err != nil {
+			return nil, err
+		}
+		raw, err := requiredArgument(args, field, "id", "ID!")
+		if err != nil {
+			return nil, err
+		}
+		id, key, err := graphQLRecordID(schema, raw)
+		if err != nil {
+			return nil, err
+		}
+		stored, ok := records.get(key)
+		if root.action == gqlDelete {
+			// As with DELETE, only a stored record can be deleted.
+			if e.server.softDelete && ok {
+				return e.server.softDeleteRecord(records, key), nil
+			}
+			return records.delete(key), nil
+		}
+		if ok && !e.server.softDeleted(stored) {
+			return stored, nil
+		}
+		if ok || e.server.strictGet || records.wasDeleted(key) {
+			return nil, nil
+		}
+		obj := e.server.dummyData(schema, e.server.newRecordGenerator(e.r, schema, key))
+		obj[schema.idKey()] = id
+		return obj, nil
+
+	case gqlCreate:
+		args, err := e.arguments(field, typeName, "input")
+		if err != nil {
+			return nil, err
+		}
+		input, err := e.graphQLInput(args, field, entity)
+		if err != nil {
+			return nil, err
+		}
+		obj := e.server.dummyData(schema, e.server.newGenerator(e.r))
+		delete(obj, schema.idKey()) // assigned by the store unless the input supplies one
+		for key, value := range input {
+			obj[key] = value
+		}
+		if err := records.create(obj, schema.integerIDs()); err != nil {
+			code := errorCode(http.StatusBadRequest)
+			if err == errDuplicateID {
+				code = errorCode(http.StatusConflict)
+			}
+			return nil, &gqlError{Message: err.Error(), Extensions: map[string]interface{}{"code": code}}
+		}
+		return obj, nil
+
+	case gqlUpdate:
+		args, err := e.arguments(field, typeName, "id", "input")
+		if err != nil {
+			return nil, err
+		}
+		raw, err := requiredArgument(args, field, "id", "ID!")
+		if err != nil {
+			return nil, err
+		}
+		id, key, err := graphQLRecordID(schema, raw)
+		if err != nil {
+			return nil, err
+		}
+		input, err := e.graphQLInput(args, field, entity)
+		if err != nil {
+			return nil, err
+		}
+		// The id argument is authoritative, as the URL is for PUT.
+		if inputID, ok := input[schema.idKey()]; ok {
+			if !sameID(inputID, id) && e.server.rejectIDMismatch {
+				return nil, fmt.Errorf("Input id %v does not match id %v", inputID, id)
+			}
+			delete(input, schema.idKey())
+		}
+		// As with PUT, a record that is not stored is created unless
+		// -strict-put is set.
+		obj := e.server.dummyData(schema, e.server.newRecordGenerator(e.r, schema, key))
+		if stored, ok := records.get(key); ok && !e.server.softDeleted(stored) {
+			obj = stored
+		} else if e.server.strictPut {
+			return nil, &gqlError{Message: fmt.Sprintf("%s %v not found", schema.Title, id), Extensions: map[string]interface{}{"code": errorCode(http.StatusNotFound)}}
+		}
+		for name, value := range input {
+			obj[name] = value
+		}
+		obj[schema.idKey()] = id
+		records.put(id, obj)
+		return obj, nil
+	}
+	return nil, fmt.Errorf("unknown operation %q", root.action)
+}
+
+// gqlRequest is a GraphQL request as sent in a JSON body or the query
+// string.
+type gqlRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// gqlResponse is the body of every GraphQL response. Data is left out when
+// the request could not be executed at all.
+type gqlResponse struct {
+	Data   interface{} `json:"data,omitempty"`
+	Errors []*gqlError `json:"errors,omitempty"`
+}
+
+// writeGraphQL responds with a GraphQL result.
+func writeGraphQL(w http.ResponseWriter, status int, response gqlResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Println("Error encoding response:", err)
+	}
+}
+
+// writeGraphQLError rejects a request that cannot be executed.
+func writeGraphQLError(w http.ResponseWriter, err error) {
+	gerr, ok := err.(*gqlError)
+	if !ok {
+		gerr = &gqlError{Message: err.Error()}
+	}
+	writeGraphQL(w, http.StatusBadRequest, gqlResponse{Errors: []*gqlError{gerr}})
+}
+
+// readGraphQLRequest reads the query, operation name and variables from
+// the query string of a GET or the body of a POST.
+func readGraphQLRequest(r *http.Request) (gqlRequest, error) {
+	var req gqlRequest
+	if r.Method == http.MethodGet {
+		query := r.URL.Query()
+		req.Query, req.OperationName = query.Get("query"), query.Get("operationName")
+		if raw := query.Get("variables"); raw != "" {
+			if err := json.Unmarshal([]byte(raw), &req.Variables); err != nil {
+				return req, errors.New("Variables are invalid JSON: " + err.Error())
+			}
+		}
+		return req, nil
+	}
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == graphQLContentType {
+		body, err := io.ReadAll(r.Body)
+		req.Query = string(body)
+		return req, err
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return req, errors.New("Invalid JSON body: " + err.Error())
+	}
+	return req, nil
+}
+
+// selectOperation picks the operation a request runs.
+func (doc *gqlDocument) selectOperation(name string) (*gqlOperation, error) {
+	if name == "" {
+		if len(doc.operations) > 1 {
+			return nil, errors.New("Must provide operation name if query contains multiple operations.")
+		}
+		return doc.operations[0], nil
+	}
+	for _, op := range doc.operations {
+		if op.name == name {
+			return op, nil
+		}
+	}
+	return nil, fmt.Errorf("Unknown operation named %q.", name)
+}
+
+// coerceVariables returns the operation's variables, filling in defaults.
+// Required variables must be given.
+func coerceVariables(op *gqlOperation, given map[string]interface{}) (map[string]interface{}, error) {
+	vars := make(map[string]interface{})
+	for _, def := range op.variables {
+		value, ok := given[def.name]
+		if !ok && def.defaultValue != nil {
+			value, ok = def.defaultValue.resolve(nil), true
+		}
+		if (!ok || value == nil) && strings.HasSuffix(def.typ, "!") {
+			return nil, &gqlError{
+				Message:   fmt.Sprintf("Variable \"$%s\" of required type %q was not provided.", def.name, def.typ),
+				Locations: []gqlLocation{def.loc},
+			}
+		}
+		vars[def.name] = value
+	}
+	return vars, nil
+}
+
+// graphQLHandler serves GraphQL queries and mutations over every uploaded
+// schema at /graphql, backed by the same stores as the REST routes.
+// Queries may be sent with GET or POST, mutations only with POST.
+func (s *Server) graphQLHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		writeMethodNotAllowed(w, "Only GET and POST allowed", http.MethodGet, http.MethodPost)
+		return
+	}
+	req, err := readGraphQLRequest(r)
+	if err != nil {
+		writeGraphQLError(w, err)
+		return
+	}
+	if strings.TrimSpace(req.Query) == "" {
+		writeGraphQLError(w, errors.New("Must provide query string."))
+		return
+	}
+	api := s.graphQLAPI()
+	if len(api.entities) == 0 {
+		s.writeNoSchema(w)
+		return
+	}
+	doc, err := parseGraphQL(req.Query)
+	if err != nil {
+		writeGraphQLError(w, err)
+		return
+	}
+	op, err := doc.selectOperation(req.OperationName)
+	if err != nil {
+		writeGraphQLError(w, err)
+		return
+	}
+	switch op.kind {
+	case "subscription":
+		writeGraphQLError(w, errors.New("Subscriptions are not supported."))
+		return
+	case "mutation":
+		if r.Method == http.MethodGet {
+			writeMethodNotAllowed(w, "Mutations must be sent with POST", http.MethodPost)
+			return
+		}
+	}
+	vars, err := coerceVariables(op, req.Variables)
+	if err != nil {
+		writeGraphQLError(w, err)
+		return
+	}
+
+	e := &gqlExecutor{server: s, api: api, doc: doc, vars: vars, w: w, r: r}
+	data := e.execute(op)
+	writeGraphQL(w, http.StatusOK, gqlResponse{Data: data, Errors: e.errors})
+}
+
+// graphQLSchemaHandler serves the schema of /graphql in SDL at
+// GET /schema.graphql.
+func (s *Server) graphQLSchemaHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w, "Only GET allowed", http.MethodGet)
+		return
+	}
+	api := s.graphQLAPI()
+	if len(api.entities) == 0 {
+		s.writeNoSchema(w)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	io.WriteString(w, api.sdl())
+}
@@ -0,0 +1,168 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// performGraphQL posts a GraphQL request to graphQLHandler.
+func performGraphQL(t *testing.T, srv *Server, query string, variables map[string]interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	body, err := json.Marshal(gqlRequest{Query: query, Variables: variables})
+	if err != nil {
+		t.Fatalf("could not encode request: %v", err)
+	}
+	return performRequest(t, srv.graphQLHandler, http.MethodPost, "/graphql", body)
+}
+
+func TestGraphQLQueries(t *testing.T) {
+	srv := NewServer()
+	srv.currentSchema = createSampleSchema()
+	srv.store = newRecordStore()
+	for _, body := range []string{`{"name":"alice","email":"a@example.com"}`, `{"name":"bob","email":"b@example.com"}`} {
+		if rr := performRequest(t, srv.catchAllHandler, http.MethodPost, "/users", []byte(body)); rr.Code != http.StatusCreated {
+			t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusCreated)
+		}
+	}
+
+	rr := performGraphQL(t, srv, `query Users {
+		users(sort: "-name") { id name }
+		first: user(id: 1) { __typename ...contact }
+	}
+	fragment contact on User { email name }`, nil)
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+	expected := `{"data":{"users":[{"id":"2","name":"bob"},{"id":"1","name":"alice"}],"first":{"__typename":"User","email":"a@example.com","name":"alice"}}}`
+	if got := strings.TrimSpace(rr.Body.String()); got != expected {
+		t.Errorf("handler returned unexpected body: got %v want %v", got, expected)
+	}
+
+	t.Run("GET", func(t *testing.T) {
+		query := url.Values{"query": {`query($id: ID!) { user(id: $id) { name @include(if: false) email } }`}, "variables": {`{"id":"2"}`}}
+		rr := performRequest(t, srv.graphQLHandler, http.MethodGet, "/graphql?"+query.Encode(), nil)
+		expected := `{"data":{"user":{"email":"b@example.com"}}}`
+		if got := strings.TrimSpace(rr.Body.String()); got != expected {
+			t.Errorf("handler returned unexpected body: got %v want %v", got, expected)
+		}
+	})
+
+	t.Run("Field Errors", func(t *testing.T) {
+		rr := performGraphQL(t, srv, `{ user(id: 1) { name phone } users(limit: 0) { id } }`, nil)
+		if status := rr.Code; status != http.StatusOK {
+			t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+		}
+		var response struct {
+			Data   map[string]interface{} `json:"data"`
+			Errors []gqlError             `json:"errors"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+			t.Fatalf("could not decode response: %v", err)
+		}
+		if len(response.Errors) != 2 || response.Errors[0].Message != `Cannot query field "phone" on type "User".` ||
+			!strings.Contains(response.Errors[1].Message, "limit") {
+			t.Errorf("handler returned unexpected errors: got %+v", response.Errors)
+		}
+		if user := response.Data["user"].(map[string]interface{}); user["name"] != "alice" || user["phone"] != nil {
+			t.Errorf("handler returned unexpected partial data: got %v", response.Data)
+		}
+	})
+}
+
+func TestGraphQLMutations(t *testing.T) {
+	srv := NewServer()
+	srv.currentSchema = createSampleSchema()
+	srv.store = newRecordStore()
+
+	rr := performGraphQL(t, srv, `mutation($input: UserInput!) { createUser(input: $input) { id name } }`,
+		map[string]interface{}{"input": map[string]interface{}{"name": "carol", "email": "c@example.com"}})
+	if got := strings.TrimSpace(rr.Body.String()); got != `{"data":{"createUser":{"id":"1","name":"carol"}}}` {
+		t.Fatalf("handler returned unexpected body for create: got %v", got)
+	}
+	rr = performRequest(t, srv.catchAllHandler, http.MethodGet, "/users/1", nil)
+	if !strings.Contains(rr.Body.String(), `"name":"carol"`) {
+		t.Errorf("created record is not served by the REST route: got %v", rr.Body.String())
+	}
+
+	rr = performGraphQL(t, srv, `mutation { updateUser(id: "1", input: {name: "caroline", email: "caroline@example.com"}) { name email } }`, nil)
+	if got := strings.TrimSpace(rr.Body.String()); got != `{"data":{"updateUser":{"name":"caroline","email":"caroline@example.com"}}}` {
+		t.Errorf("handler returned unexpected body for update: got %v", got)
+	}
+
+	rr = performGraphQL(t, srv, `mutation { createUser(input: {name: 5}) { id } }`, nil)
+	var response gqlResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("could not decode response: %v", err)
+	}
+	if len(response.Errors) != 1 || response.Errors[0].Extensions["code"] != "validation_failed" {
+		t.Errorf("handler did not reject an invalid input: got %v", rr.Body.String())
+	}
+
+	rr = performGraphQL(t, srv, `mutation { gone: deleteUser(id: 1) again: deleteUser(id: 1) }`, nil)
+	if got := strings.TrimSpace(rr.Body.String()); got != `{"data":{"gone":true,"again":false}}` {
+		t.Errorf("handler returned unexpected body for delete: got %v", got)
+	}
+	rr = performRequest(t, srv.catchAllHandler, http.MethodGet, "/users/1", nil)
+	if status := rr.Code; status != http.StatusNotFound {
+		t.Errorf("deleted record is still served: got %v want %v", status, http.StatusNotFound)
+	}
+}
+
+func TestGraphQLRequestErrors(t *testing.T) {
+	srv := NewServer()
+	srv.currentSchema = createSampleSchema()
+	srv.store = newRecordStore()
+
+	cases := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{"Syntax Error", http.MethodPost, "/graphql", `{"query":"{ users { id }"}`, http.StatusBadRequest},
+		{"Missing Variable", http.MethodPost, "/graphql", `{"query":"query($id: ID!) { user(id: $id) { id } }"}`, http.StatusBadRequest},
+		{"Ambiguous Operation", http.MethodPost, "/graphql", `{"query":"query A { users { id } } query B { users { id } }"}`, http.StatusBadRequest},
+		{"Mutation Over GET", http.MethodGet, "/graphql?query=" + url.QueryEscape(`mutation { deleteUser(id: 1) }`), "", http.StatusMethodNotAllowed},
+		{"Wrong Method", http.MethodPut, "/graphql", "", http.StatusMethodNotAllowed},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rr := performRequest(t, srv.graphQLHandler, tc.method, tc.path, []byte(tc.body))
+			if status := rr.Code; status != tc.status {
+				t.Errorf("handler returned wrong status code: got %v want %v (%v)", status, tc.status, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestGraphQLSchema(t *testing.T) {
+	srv := NewServer()
+	srv.currentSchema = createSampleSchema()
+	srv.currentSchema.Properties["address"] = Property{Type: "object", Properties: map[string]Property{"city": {Type: "string"}}}
+	srv.currentSchema.Properties["tags"] = Property{Type: "array", Items: &Property{Type: "string"}}
+	srv.currentSchema.Methods = []string{"GET", "POST"}
+	srv.store = newRecordStore()
+
+	rr := performRequest(t, srv.graphQLSchemaHandler, http.MethodGet, "/schema.graphql", nil)
+	sdl := rr.Body.String()
+	for _, want := range []string{
+		"type User {\n  address: UserAddress\n  email: String\n  id: ID!\n  name: String\n  tags: [String]\n}",
+		"type UserAddress {\n  city: String\n}",
+		"input UserAddressInput {\n  city: String\n}",
+		"users(page: Int, limit: Int, sort: String): [User!]!",
+		"user(id: ID!): User",
+		"createUser(input: UserInput!): User!",
+	} {
+		if !strings.Contains(sdl, want) {
+			t.Errorf("schema lacks %q: got\n%v", want, sdl)
+		}
+	}
+	if strings.Contains(sdl, "deleteUser") {
+		t.Errorf("schema exposes a method the entity does not allow:\n%v", sdl)
+	}
+}
@@ -0,0 +1,619 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// gqlLocation is a position in a GraphQL document, counted from 1.
+type gqlLocation struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// gqlError is an entry of the errors list of a GraphQL response.
+type gqlError struct {
+	Message    string                 `json:"message"`
+	Locations  []gqlLocation          `json:"locations,omitempty"`
+	Path       []interface{}          `json:"path,omitempty"`
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+}
+
+func (e *gqlError) Error() string { return e.Message }
+
+// gqlDocument is a parsed GraphQL request document.
+type gqlDocument struct {
+	operations []*gqlOperation
+	fragments  map[string]*gqlFragment
+}
+
+// gqlOperation is a query, mutation or subscription definition.
+type gqlOperation struct {
+	kind       string
+	name       string
+	variables  []gqlVariable
+	selections []gqlSelection
+	loc        gqlLocation
+}
+
+// gqlVariable is a variable definition of an operation. typ is the type as
+// written, such as "ID!".
+type gqlVariable struct {
+	name         string
+	typ          string
+	defaultValue *gqlValue
+	loc          gqlLocation
+}
+
+// gqlFragment is a named fragment definition.
+type gqlFragment struct {
+	typeCondition string
+	selections    []gqlSelection
+}
+
+// Kinds of gqlSelection.
+const (
+	selectField = iota
+	selectSpread
+	selectInline
+)
+
+// gqlSelection is one entry of a selection set: a field, a fragment spread
+// (name is the fragment's) or an inline fragment.
+type gqlSelection struct {
+	kind          int
+	alias         string
+	name          string
+	arguments     []gqlArgument
+	directives    []gqlArgumentList
+	typeCondition string
+	selections    []gqlSelection
+	loc           gqlLocation
+}
+
+// responseKey returns the key a field is reported under.
+func (s gqlSelection) responseKey() string {
+	if s.alias != "" {
+		return s.alias
+	}
+	return s.name
+}
+
+// gqlArgument is a named value: a field or directive argument, or a field
+// of an input object.
+type gqlArgument struct {
+	name  string
+	value gqlValue
+}
+
+// gqlArgumentList is a directive with its arguments.
+type gqlArgumentList struct {
+	name      string
+	arguments []gqlArgument
+}
+
+// gqlValue is a literal or variable in a document. Lists and input objects
+// keep their elements, other literals are already in their JSON form.
+type gqlValue struct {
+	variable string
+	literal  interface{}
+	list     []gqlValue
+	object   []gqlArgument
+	isList   bool
+	isObject bool
+}
+
+// resolve returns the JSON form of the value with variables substituted,
+// so arguments look the same as request bodies.
+func (v gqlValue) resolve(vars map[string]interface{}) interface{} {
+	switch {
+	case v.variable != "":
+		return vars[v.variable]
+	case v.isList:
+		list := make([]interface{}, len(v.list))
+		for i, item := range v.list {
+			list[i] = item.resolve(vars)
+		}
+		return list
+	case v.isObject:
+		obj := make(map[string]interface{}, len(v.object))
+		for _, field := range v.object {
+			obj[field.name] = field.value.resolve(vars)
+		}
+		return obj
+	}
+	return v.literal
+}
+
+// Kinds of gqlToken.
+const (
+	tokenEOF = iota
+	tokenPunct
+	tokenName
+	tokenNumber
+	tokenString
+)
+
+// gqlToken is a lexical token of a GraphQL document.
+type gqlToken struct {
+	kind int
+	text string
+	loc  gqlLocation
+}
+
+// describe names the token in syntax errors.
+func (t gqlToken) describe() string {
+	switch t.kind {
+	case tokenEOF:
+		return "<EOF>"
+	case tokenName:
+		return "Name \"" + t.text + "\""
+	case tokenString:
+		return "String"
+	case tokenNumber:
+		return "Number " + t.text
+	}
+	return "\"" + t.text + "\""
+}
+
+// lexGraphQL splits a document into tokens, dropping whitespace, commas and
+// comments.
+func lexGraphQL(src string) ([]gqlToken, error) {
+	var tokens []gqlToken
+	line, lineStart := 1, 0
+	for i := 0; i < len(src); {
+		c := src[i]
+		loc := gqlLocation{Line: line, Column: i - lineStart + 1}
+		switch {
+		case c == '\n':
+			line, lineStart = line+1, i+1
+			i++
+		case c == ' ' || c == '\t' || c == '\r' || c == ',':
+			i++
+		case c == '#':
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case c == '.':
+			if !strings.HasPrefix(src[i:], "...") {
+				return nil, syntaxError(loc, "Unexpected \".\"")
+			}
+			tokens = append(tokens, gqlToken{tokenPunct, "...", loc})
+			i += 3
+		case strings.IndexByte("!$&()[]{}:=@|", c) >= 0:
+			tokens = append(tokens, gqlToken{tokenPunct, string(c), loc})
+			i++
+		case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+			start := i
+			for i < len(src) && (src[i] == '_' || isAlnum(src[i])) {
+				i++
+			}
+			tokens = append(tokens, gqlToken{tokenName, src[start:i], loc})
+		case c == '-' || c >= '0' && c <= '9':
+			start := i
+			i++
+			for i < len(src) && (isAlnum(src[i]) || src[i] == '.' || src[i] == '+' || src[i] == '-' && (src[i-1] == 'e' || src[i-1] == 'E')) {
+				i++
+			}
+			text := src[start:i]
+			if _, err := strconv.ParseFloat(text, 64); err != nil {
+				return nil, syntaxError(loc, "Invalid number "+text)
+			}
+			tokens = append(tokens, gqlToken{tokenNumber, text, loc})
+		case strings.HasPrefix(src[i:], `"""`):
+			end := strings.Index(src[i+3:], `"""`)
+			if end < 0 {
+				return nil, syntaxError(loc, "Unterminated string")
+			}
+			raw := src[i+3 : i+3+end]
+			tokens = append(tokens, gqlToken{tokenString, strings.TrimSpace(raw), loc})
+			line += strings.Count(raw, "\n")
+			if n := strings.LastIndexByte(raw, '\n'); n >= 0 {
+				lineStart = i + 3 + n + 1
+			}
+			i += end + 6
+		case c == '"':
+			end := i + 1
+			for end < len(src) && src[end] != '"' && src[end] != '\n' {
+				if src[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(src) || src[end] != '"' {
+				return nil, syntaxError(loc, "Unterminated string")
+			}
+			// GraphQL string escapes are those of JSON.
+			var s string
+			if err := json.Unmarshal([]byte(src[i:end+1]), &s); err != nil {
+				return nil, syntaxError(loc, "Invalid string")
+			}
+			tokens = append(tokens, gqlToken{tokenString, s, loc})
+			i = end + 1
+		default:
+			return nil, syntaxError(loc, fmt.Sprintf("Unexpected character %q", c))
+		}
+	}
+	loc := gqlLocation{Line: line, Column: len(src) - lineStart + 1}
+	return append(tokens, gqlToken{kind: tokenEOF, loc: loc}), nil
+}
+
+// isAlnum reports whether c is an ASCII letter or digit.
+func isAlnum(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// syntaxError returns a GraphQL error for a malformed document.
+func syntaxError(loc gqlLocation, message string) *gqlError {
+	return &gqlError{Message: "Syntax Error: " + message, Locations: []gqlLocation{loc}}
+}
+
+// gqlParser is a recursive-descent parser over the tokens of a document.
+type gqlParser struct {
+	tokens []gqlToken
+	pos    int
+}
+
+// parseGraphQL parses an executable GraphQL document. Type system
+// definitions are not accepted.
+func parseGraphQL(src string) (*gqlDocument, error) {
+	tokens, err := lexGraphQL(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &gqlParser{tokens: tokens}
+	doc := &gqlDocument{fragments: make(map[string]*gqlFragment)}
+	for p.peek().kind != tokenEOF {
+		t := p.peek()
+		switch {
+		case t.kind == tokenPunct && t.text == "{":
+			selections, err := p.selectionSet()
+			if err != nil {
+				return nil, err
+			}
+			doc.operations = append(doc.operations, &gqlOperation{kind: "query", selections: selections, loc: t.loc})
+		case t.kind == tokenName && (t.text == "query" || t.text == "mutation" || t.text == "subscription"):
+			op, err := p.operation()
+			if err != nil {
+				return nil, err
+			}
+			doc.operations = append(doc.operations, op)
+		case t.kind == tokenName && t.text == "fragment":
+			p.next()
+			name, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.keyword("on"); err != nil {
+				return nil, err
+			}
+			typeCondition, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			if _, err := p.directives(); err != nil {
+				return nil, err
+			}
+			selections, err := p.selectionSet()
+			if err != nil {
+				return nil, err
+			}
+			doc.fragments[name] = &gqlFragment{typeCondition: typeCondition, selections: selections}
+		default:
+			return nil, p.unexpected()
+		}
+	}
+	if len(doc.operations) == 0 {
+		return nil, &gqlError{Message: "Document contains no operation"}
+	}
+	return doc, nil
+}
+
+func (p *gqlParser) peek() gqlToken { return p.tokens[p.pos] }
+
+func (p *gqlParser) next() gqlToken {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+// unexpected reports the current token as out of place.
+func (p *gqlParser) unexpected() error {
+	t := p.peek()
+	return syntaxError(t.loc, "Unexpected "+t.describe())
+}
+
+// punct consumes the punctuator text, or fails.
+func (p *gqlParser) punct(text string) error {
+	if t := p.peek(); t.kind != tokenPunct || t.text != text {
+		return syntaxError(t.loc, fmt.Sprintf("Expected %q, found %s", text, t.describe()))
+	}
+	p.next()
+	return nil
+}
+
+// skip consumes the punctuator text if it is next.
+func (p *gqlParser) skip(text string) bool {
+	if t := p.peek(); t.kind == tokenPunct && t.text == text {
+		p.next()
+		return true
+	}
+	return false
+}
+
+// keyword consumes the name word, or fails.
+func (p *gqlParser) keyword(word string) error {
+	if t := p.peek(); t.kind != tokenName || t.text != word {
+		return syntaxError(t.loc, fmt.Sprintf("Expected %q, found %s", word, t.describe()))
+	}
+	p.next()
+	return nil
+}
+
+// name consumes a name, or fails.
+func (p *gqlParser) name() (string, error) {
+	t := p.peek()
+	if t.kind != tokenName {
+		return "", syntaxError(t.loc, "Expected Name, found "+t.describe())
+	}
+	p.next()
+	return t.text, nil
+}
+
+// operation parses an operation definition after its keyword is seen.
+func (p *gqlParser) operation() (*gqlOperation, error) {
+	t := p.next()
+	op := &gqlOperation{kind: t.text, loc: t.loc}
+	if p.peek().kind == tokenName {
+		op.name = p.next().text
+	}
+	if p.skip("(") {
+		for !p.skip(")") {
+			loc := p.peek().loc
+			if err := p.punct("$"); err != nil {
+				return nil, err
+			}
+			name, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.punct(":"); err != nil {
+				return nil, err
+			}
+			typ, err := p.typeRef()
+			if err != nil {
+				return nil, err
+			}
+			variable := gqlVariable{name: name, typ: typ, loc: loc}
+			if p.skip("=") {
+				value, err := p.value(true)
+				if err != nil {
+					return nil, err
+				}
+				variable.defaultValue = &value
+			}
+			if _, err := p.directives(); err != nil {
+				return nil, err
+			}
+			op.variables = append(op.variables, variable)
+		}
+	}
+	if _, err := p.directives(); err != nil {
+		return nil, err
+	}
+	selections, err := p.selectionSet()
+	if err != nil {
+		return nil, err
+	}
+	op.selections = selections
+	return op, nil
+}
+
+// typeRef parses a type reference such as [ID!]! and returns it as written.
+func (p *gqlParser) typeRef() (string, error) {
+	var typ string
+	if p.skip("[") {
+		inner, err := p.typeRef()
+		if err != nil {
+			return "", err
+		}
+		if err := p.punct("]"); err != nil {
+			return "", err
+		}
+		typ = "[" + inner + "]"
+	} else {
+		name, err := p.name()
+		if err != nil {
+			return "", err
+		}
+		typ = name
+	}
+	if p.skip("!") {
+		typ += "!"
+	}
+	return typ, nil
+}
+
+// selectionSet parses a braced, non-empty list of selections.
+func (p *gqlParser) selectionSet() ([]gqlSelection, error) {
+	if err := p.punct("{"); err != nil {
+		return nil, err
+	}
+	var selections []gqlSelection
+	for !p.skip("}") {
+		selection, err := p.selection()
+		if err != nil {
+			return nil, err
+		}
+		selections = append(selections, selection)
+	}
+	if len(selections) == 0 {
+		return nil, p.unexpected()
+	}
+	return selections, nil
+}
+
+// selection parses a field, fragment spread or inline fragment.
+func (p *gqlParser) selection() (gqlSelection, error) {
+	loc := p.peek().loc
+	if p.skip("...") {
+		sel := gqlSelection{kind: selectInline, loc: loc}
+		if t := p.peek(); t.kind == tokenName && t.text != "on" {
+			sel.kind, sel.name = selectSpread, p.next().text
+		} else if t.kind == tokenName {
+			p.next()
+			name, err := p.name()
+			if err != nil {
+				return sel, err
+			}
+			sel.typeCondition = name
+		}
+		directives, err := p.directives()
+		if err != nil {
+			return sel, err
+		}
+		sel.directives = directives
+		if sel.kind == selectInline {
+			if sel.selections, err = p.selectionSet(); err != nil {
+				return sel, err
+			}
+		}
+		return sel, nil
+	}
+
+	sel := gqlSelection{kind: selectField, loc: loc}
+	name, err := p.name()
+	if err != nil {
+		return sel, err
+	}
+	sel.name = name
+	if p.skip(":") {
+		if sel.name, err = p.name(); err != nil {
+			return sel, err
+		}
+		sel.alias = name
+	}
+	if sel.arguments, err = p.arguments(false); err != nil {
+		return sel, err
+	}
+	if sel.directives, err = p.directives(); err != nil {
+		return sel, err
+	}
+	if t := p.peek(); t.kind == tokenPunct && t.text == "{" {
+		if sel.selections, err = p.selectionSet(); err != nil {
+			return sel, err
+		}
+	}
+	return sel, nil
+}
+
+// arguments parses an optional parenthesized argument list.
+func (p *gqlParser) arguments(constant bool) ([]gqlArgument, error) {
+	if !p.skip("(") {
+		return nil, nil
+	}
+	var args []gqlArgument
+	for !p.skip(")") {
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.punct(":"); err != nil {
+			return nil, err
+		}
+		value, err := p.value(constant)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, gqlArgument{name: name, value: value})
+	}
+	return args, nil
+}
+
+// directives parses any directives, such as @skip(if: $flag).
+func (p *gqlParser) directives() ([]gqlArgumentList, error) {
+	var directives []gqlArgumentList
+	for p.skip("@") {
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		args, err := p.arguments(false)
+		if err != nil {
+			return nil, err
+		}
+		directives = append(directives, gqlArgumentList{name: name, arguments: args})
+	}
+	return directives, nil
+}
+
+// value parses a value. Variables are not allowed in constant contexts
+// such as variable defaults. Numbers become float64 and enum values
+// strings, as if the value had been sent as JSON.
+func (p *gqlParser) value(constant bool) (gqlValue, error) {
+	t := p.peek()
+	switch t.kind {
+	case tokenPunct:
+		switch t.text {
+		case "$":
+			if constant {
+				return gqlValue{}, p.unexpected()
+			}
+			p.next()
+			name, err := p.name()
+			return gqlValue{variable: name}, err
+		case "[":
+			p.next()
+			v := gqlValue{isList: true, list: []gqlValue{}}
+			for !p.skip("]") {
+				item, err := p.value(constant)
+				if err != nil {
+					return v, err
+				}
+				v.list = append(v.list, item)
+			}
+			return v, nil
+		case "{":
+			p.next()
+			v := gqlValue{isObject: true}
+			for !p.skip("}") {
+				name, err := p.name()
+				if err != nil {
+					return v, err
+				}
+				if err := p.punct(":"); err != nil {
+					return v, err
+				}
+				field, err := p.value(constant)
+				if err != nil {
+					return v, err
+				}
+				v.object = append(v.object, gqlArgument{name: name, value: field})
+			}
+			return v, nil
+		}
+	case tokenNumber:
+		p.next()
+		n, _ := strconv.ParseFloat(t.text, 64)
+		return gqlValue{literal: n}, nil
+	case tokenString:
+		p.next()
+		return gqlValue{literal: t.text}, nil
+	case tokenName:
+		p.next()
+		switch t.text {
+		case "true":
+			return gqlValue{literal: true}, nil
+		case "false":
+			return gqlValue{literal: false}, nil
+		case "null":
+			return gqlValue{}, nil
+		}
+		return gqlValue{literal: t.text}, nil
+	}
+	return gqlValue{}, p.unexpected()
+}
@@ -0,0 +1,861 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Schema defines the JSON schema structure.
+type Schema struct {
+	Title      string              `json:"title"`
+	Type       string              `json:"type"`
+	Properties map[string]Property `json:"properties"`
+	Required   []string            `json:"required"`
+	// Methods restricts the HTTP methods served on the entity's routes.
+	// When empty every supported method is allowed.
+	Methods []string `json:"methods,omitempty"`
+	// IDStart and IDStep configure the auto-increment counter for this
+	// entity, overriding the -id-start and -id-step flags.
+	IDStart *int `json:"x-id-start,omitempty"`
+	IDStep  *int `json:"x-id-step,omitempty"`
+	// IDProperty names the property holding the records' ids, which is
+	// otherwise id.
+	IDProperty string `json:"x-id-property,omitempty"`
+	// IDStrategy is one of idStrategies, choosing how new records' ids are
+	// assigned and what route ids must look like.
+	IDStrategy string `json:"x-id-strategy,omitempty"`
+	// Timestamps adds createdAt and updatedAt properties, if the schema
+	// lacks them, which the store maintains as it does for declared ones.
+	Timestamps bool `json:"x-timestamps,omitempty"`
+	// OptionalFields overrides the -optional-fields flag for this entity.
+	OptionalFields string `json:"x-optional-fields,omitempty"`
+	// ListSize overrides the -list-size flag for this entity.
+	ListSize *int `json:"x-list-size,omitempty"`
+	// ResourceName overrides the collection route segment, which is
+	// otherwise the plural of the title.
+	ResourceName string `json:"x-resource-name,omitempty"`
+	// Extends names a previously uploaded schema whose properties and
+	// required fields this one inherits.
+	Extends string `json:"extends,omitempty"`
+	// Ref, Definitions and Defs hold local references and the definitions
+	// they point to; see resolveRefs.
+	Ref         string              `json:"$ref,omitempty"`
+	Definitions map[string]Property `json:"definitions,omitempty"`
+	Defs        map[string]Property `json:"$defs,omitempty"`
+	// Keywords constrain request bodies beyond their properties; allOf
+	// parts also contribute properties. See validateRecord.
+	Keywords
+	// definition marks schemas registered from another schema's
+	// definitions, which a later upload may replace.
+	definition bool
+}
+
+// Property defines each property's type. Object properties describe their
+// fields in Properties and array properties describe their elements in Items.
+// Tuple-style arrays list positional element schemas in PrefixItems; Items
+// then describes any elements past the tuple.
+type Property struct {
+	Type   string `json:"type"`
+	Format string `json:"format,omitempty"`
+	// Ref points to a definition of the schema, such as
+	// "#/definitions/address". It is inlined when the schema is uploaded.
+	Ref string `json:"$ref,omitempty"`
+	// Enum lists the only values the property may take. Generated values
+	// are picked from it.
+	Enum       []interface{}       `json:"enum,omitempty"`
+	Properties map[string]Property `json:"properties,omitempty"`
+	Items      *Property           `json:"items,omitempty"`
+	// Required lists the fields an object property must contain.
+	Required []string `json:"required,omitempty"`
+	// PrefixItems gives the schema of each element of a fixed-position
+	// tuple, in order.
+	PrefixItems []Property `json:"prefixItems,omitempty"`
+	// MinItems and MaxItems bound the length of array values.
+	MinItems *int `json:"minItems,omitempty"`
+	MaxItems *int `json:"maxItems,omitempty"`
+	// Const is the only value the property may take.
+	Const interface{} `json:"const,omitempty"`
+	// Default and Examples document the property's values, and generated
+	// values are taken from them; see generator.value.
+	Default  interface{}   `json:"default,omitempty"`
+	Examples []interface{} `json:"examples,omitempty"`
+	// Minimum and Maximum bound numeric values inclusively, the exclusive
+	// variants exclusively. MultipleOf requires values to be a whole
+	// multiple of it.
+	Minimum          *float64 `json:"minimum,omitempty"`
+	Maximum          *float64 `json:"maximum,omitempty"`
+	ExclusiveMinimum *float64 `json:"exclusiveMinimum,omitempty"`
+	ExclusiveMaximum *float64 `json:"exclusiveMaximum,omitempty"`
+	MultipleOf       *float64 `json:"multipleOf,omitempty"`
+	// MinLength and MaxLength bound the length of string values in
+	// characters. Pattern is a regular expression they must match
+	// somewhere, as in JSON Schema.
+	MinLength *int   `json:"minLength,omitempty"`
+	MaxLength *int   `json:"maxLength,omitempty"`
+	Pattern   string `json:"pattern,omitempty"`
+	// GenMode overrides the -gen-mode flag for this property.
+	GenMode string `json:"x-gen-mode,omitempty"`
+	// Localized maps language tags (and an optional "default") to the
+	// value returned for clients preferring that language.
+	Localized map[string]interface{} `json:"x-localized,omitempty"`
+	// XRef names the schema, by title, whose id this property holds. Each
+	// parent record then lists its children at /{parents}/{id}/{children}.
+	XRef string `json:"x-ref,omitempty"`
+	// Nullable, as in OpenAPI 3.0 or a type list including "null", also
+	// accepts null.
+	Nullable bool `json:"nullable,omitempty"`
+	// UniqueItems forbids equal array elements. Contains is a schema that
+	// between MinContains (default 1) and MaxContains elements must match.
+	UniqueItems bool      `json:"uniqueItems,omitempty"`
+	Contains    *Property `json:"contains,omitempty"`
+	MinContains *int      `json:"minContains,omitempty"`
+	MaxContains *int      `json:"maxContains,omitempty"`
+	// ReadOnly fields are sent in responses but dropped from request
+	// bodies, WriteOnly ones accepted in requests but left out of
+	// responses; see readwrite.go.
+	ReadOnly  bool `json:"readOnly,omitempty"`
+	WriteOnly bool `json:"writeOnly,omitempty"`
+	Keywords
+}
+
+// DefaultWelcome is the usage hint returned by the index at GET / unless
+// WithWelcome replaces it.
+const DefaultWelcome = "POST a JSON schema to /upload, then call the generated routes listed under entities."
+
+// route returns the URL path of a route, including basePath.
+func (s *Server) route(path string) string {
+	return s.basePath + path
+}
+
+// sameID reports whether an id decoded from a JSON body matches the id
+// taken from the URL.
+func sameID(bodyID, urlID interface{}) bool {
+	if n, ok := bodyID.(float64); ok {
+		if id, ok := urlID.(int); ok {
+			return n == float64(id)
+		}
+	}
+	return fmt.Sprint(bodyID) == fmt.Sprint(urlID)
+}
+
+// parseRecordID converts an id taken from the URL to the schema's id type,
+// responding 400 when the schema expects an integer id and raw is not one,
+// or raw does not have the format of the schema's ID strategy.
+func parseRecordID(w http.ResponseWriter, schema *Schema, raw string) (interface{}, bool) {
+	id, err := recordID(schema, raw)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return nil, false
+	}
+	return id, true
+}
+
+// recordID converts a raw id to the schema's id type, as parseRecordID
+// does, returning the message of the 400 it answers otherwise.
+func recordID(schema *Schema, raw string) (interface{}, error) {
+	if prop, ok := schema.Properties[schema.idKey()]; ok && prop.Type == "integer" {
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return nil, errors.New("Invalid ID format: expected integer")
+		}
+		return n, nil
+	}
+	if err := checkRecordID(schema.idStrategy(), raw); err != nil {
+		return nil, errors.New("Invalid ID format: " + strings.TrimPrefix(err.Error(), "invalid id: "))
+	}
+	return raw, nil
+}
+
+// sampleSegment is the path segment of GET /{entity}/sample.
+const sampleSegment = "sample"
+
+// maxSampleCount caps how many documents GET /{entity}/sample generates.
+const maxSampleCount = 1000
+
+// noSchemaRetryAfter is the Retry-After value, in seconds, sent alongside a
+// 503 noSchemaStatus.
+const noSchemaRetryAfter = 5
+
+// healthHandler reports that the server is up, whether or not a schema has
+// been uploaded.
+func (s *Server) healthHandler(w http.ResponseWriter, r *http.Request) {
+	schema, _ := s.activeState()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":       "ok",
+		"schemaLoaded": schema != nil,
+	})
+}
+
+// entityName returns the collection route segment for a schema: its
+// x-resource-name, or else the lower-cased plural of its title.
+func entityName(schema *Schema) string {
+	if schema.ResourceName != "" {
+		return schema.ResourceName
+	}
+	return strings.ToLower(pluralize(schema.Title))
+}
+
+// matchesEntity reports whether a route segment names the schema's entity.
+func (s *Server) matchesEntity(segment string, schema *Schema) bool {
+	if segment == entityName(schema) {
+		return true
+	}
+	return s.looseRoutes && segment == strings.ToLower(schema.Title)
+}
+
+// rootHandler serves a JSON index describing the server at exactly GET /.
+func (s *Server) rootHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w, "Only GET allowed", http.MethodGet)
+		return
+	}
+	entities := []string{}
+	for _, e := range s.registeredEntities() {
+		entities = append(entities, e.Path)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	response := map[string]interface{}{
+		"message":  s.welcomeMessage,
+		"upload":   s.route("/upload"),
+		"entities": entities,
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// entitiesHandler lists every registered entity at GET /entities.
+func (s *Server) entitiesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w, "Only GET allowed", http.MethodGet)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.registeredEntities())
+}
+
+// loadSchema decodes a single schema from r and activates it. It must
+// pass the JSON Schema meta-schema; see checkMetaSchema.
+func (s *Server) loadSchema(r io.Reader) (*Schema, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkMetaSchema(data); err != nil {
+		return nil, err
+	}
+	var schema Schema
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if err := decoder.Decode(&schema); err != nil {
+		return nil, err
+	}
+	// Decode stops after the first value, so a second pasted schema or
+	// stray bytes would otherwise be silently dropped.
+	if decoder.More() {
+		return nil, errors.New("unexpected data after the schema object")
+	}
+	if err := s.activateSchema(&schema); err != nil {
+		return nil, err
+	}
+	return &schema, nil
+}
+
+// uploadHandler handles uploading and parsing JSON schema.
+func (s *Server) uploadHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w, "Only POST allowed", http.MethodPost)
+		return
+	}
+	defer r.Body.Close()
+	schema, err := s.loadSchema(r.Body)
+	if err != nil {
+		writeSchemaError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	response := map[string]string{
+		"message": "Schema uploaded successfully",
+		"title":   schema.Title,
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// writeSchemaError answers 400 to an upload that is not a valid schema,
+// detailing each meta-schema violation with its pointer and position.
+func writeSchemaError(w http.ResponseWriter, err error) {
+	var violations schemaErrors
+	if errors.As(err, &violations) {
+		writeErrorDetails(w, http.StatusBadRequest, "invalid_schema", "Invalid JSON schema: does not match the JSON Schema meta-schema", violations)
+		return
+	}
+	writeError(w, http.StatusBadRequest, "Invalid JSON schema: "+err.Error())
+}
+
+// decodeBody parses a JSON object from the request body. An empty body
+// decodes to an empty object.
+func decodeBody(r *http.Request) (map[string]interface{}, error) {
+	body := make(map[string]interface{})
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil && err != io.EOF {
+		return nil, err
+	}
+	return body, nil
+}
+
+// writeNoSchema answers a request for an entity before any schema is
+// uploaded.
+func (s *Server) writeNoSchema(w http.ResponseWriter) {
+	if s.noSchemaStatus == http.StatusServiceUnavailable {
+		w.Header().Set("Retry-After", strconv.Itoa(noSchemaRetryAfter))
+	}
+	writeError(w, s.noSchemaStatus, "No schema uploaded. Please POST your JSON schema to /upload")
+}
+
+// listRecords returns the page of an entity's list a request asked for,
+// filtered and sorted by query, and the number of records on all pages.
+// Until the first write the list is made of generated records.
+func (s *Server) listRecords(r *http.Request, schema *Schema, records *recordStore, page pagination, query listQuery) ([]map[string]interface{}, int) {
+	list := s.visibleRecords(r, records.list())
+	total, from := len(list), 0
+	if total == 0 && records.pristine() {
+		list, total, from = s.generateList(r, schema, page, query, nil)
+	}
+	for i, obj := range list {
+		list[i] = hideWriteOnly(schema, obj)
+	}
+	if query.active() {
+		list = query.apply(list)
+		total = len(list)
+	}
+	start, end := page.bounds(total)
+	return list[start-from : end-from], total
+}
+
+// generateList returns the generated records of a list of schema that a
+// request needs, calling each on every one, the size of the whole list and
+// the position of the first record returned. Filtering and sorting need
+// every record, paging alone only those up to the page; lists past
+// lazyListSize skip to the page too.
+func (s *Server) generateList(r *http.Request, schema *Schema, page pagination, query listQuery, each func(obj map[string]interface{})) ([]map[string]interface{}, int, int) {
+	total := query.generatedSize(schema, s.listSize)
+	from, to := 0, total
+	if !query.active() {
+		to = page.want(total)
+	}
+	lazy := total > lazyListSize
+	if lazy && !query.active() {
+		from, _ = page.bounds(total)
+	}
+	list := make([]map[string]interface{}, 0, to-from)
+	gen := s.newGenerator(r)
+	for i := from; i < to; i++ {
+		var obj map[string]interface{}
+		if lazy {
+			obj = s.indexedRecord(r, schema, i)
+		} else {
+			obj = s.dummyData(schema, gen)
+		}
+		if each != nil {
+			each(obj)
+		}
+		list = append(list, obj)
+	}
+	return list, total, from
+}
+
+// indexedRecord returns the record at index i of a lazily generated list:
+// the one GET /{entity}/{id} fabricates for the (i+1)th id, so it is made
+// on its own and is the same on every page and request.
+func (s *Server) indexedRecord(r *http.Request, schema *Schema, i int) map[string]interface{} {
+	key := strconv.Itoa(i + 1)
+	if schema.idStrategy() != idIncrement {
+		return s.dummyData(schema, s.newRecordGenerator(r, schema, key))
+	}
+	return s.generatedRecord(r, schema, key, i+1)
+}
+
+// headWriter drops the body of a GET response sent in answer to HEAD.
+type headWriter struct {
+	http.ResponseWriter
+}
+
+func (h headWriter) Write(p []byte) (int, error) {
+	return len(p), nil
+}
+
+// catchAllHandler handles all other routes. HEAD is served as GET without
+// the body, as net/http does for its own handlers.
+func (s *Server) catchAllHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodHead {
+		get := r.Clone(r.Context())
+		get.Method = http.MethodGet
+		s.catchAllHandler(headWriter{w}, get)
+		return
+	}
+	if r.URL.Path == "/" {
+		s.rootHandler(w, r)
+		return
+	}
+
+	// Operations of imported OpenAPI documents come before entity routes.
+	if imported, allowed := s.matchImportedRoute(r.URL.Path, r.Method); imported != nil {
+		s.serveImported(w, r, imported)
+		return
+	} else if len(allowed) > 0 {
+		writeMethodNotAllowed(w, "Method not allowed for this route", allowed...)
+		return
+	}
+
+	path := strings.Trim(r.URL.Path, "/")
+	segments := strings.Split(path, "/")
+
+	// Work from a consistent snapshot in case a new schema is uploaded
+	// while this request is being served.
+	schema, records, onEntity := s.entityState(segments[0])
+
+	// Ensure a schema is loaded.
+	if schema == nil {
+		s.writeNoSchema(w)
+		return
+	}
+
+	entity := entityName(schema)
+	var responseObj interface{}
+
+	if onEntity && len(segments) == 3 && s.softDelete && segments[2] == restoreSegment {
+		s.serveRestore(w, r, schema, records, segments[1])
+		return
+	}
+	if onEntity && len(segments) == 3 {
+		if rel, ok := s.findRelation(schema, segments[2]); ok {
+			s.serveChildren(w, r, schema, records, segments[1], rel)
+			return
+		}
+	}
+	if onEntity && len(segments) == 2 && segments[1] == bulkSegment {
+		s.serveBulk(w, r, schema, records)
+		return
+	}
+	if onEntity && len(segments) == 1 && r.Method == http.MethodDelete && schema.allowsMethod(http.MethodDelete) {
+		if ids := bulkDeleteIDs(r, schema); len(ids) > 0 {
+			s.serveBulkDelete(w, r, schema, records, ids)
+			return
+		}
+	}
+	if onEntity && len(segments) <= 2 {
+		if allowed := routeMethods(schema, segments); !containsString(allowed, r.Method) {
+			writeMethodNotAllowed(w, "Method not allowed for this route", allowed...)
+			return
+		}
+	}
+	status := http.StatusOK
+
+	// Optimistic concurrency: writes carrying If-Match only go ahead while
+	// the record still has the ETag the client last saw.
+	if onEntity && len(segments) == 2 && r.Header.Get("If-Match") != "" &&
+		(r.Method == http.MethodPut || r.Method == http.MethodPatch || r.Method == http.MethodDelete) {
+		s.conditionalMu.Lock()
+		defer s.conditionalMu.Unlock()
+		if !s.checkIfMatch(w, r, schema, records, segments[1]) {
+			return
+		}
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		if len(segments) == 1 && onEntity {
+			// Return the stored records in the order they were created, or
+			// a list of dummy objects until the first write, filtered,
+			// sorted and one page at a time if asked
+			page, err := parsePagination(r.URL.Query())
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "Invalid pagination: "+err.Error())
+				return
+			}
+			query, err := parseListQuery(r.URL.Query(), schema)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "Invalid query: "+err.Error())
+				return
+			}
+			expansions, err := s.parseExpand(r.URL.Query(), schema)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "Invalid expand: "+err.Error())
+				return
+			}
+			fields, err := parseFields(r.URL.Query(), schema, expansions)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "Invalid fields: "+err.Error())
+				return
+			}
+			s.setUnknownParamsHeader(w, r, schema)
+			if wantsNDJSON(r) && records.pristine() {
+				count, ok, err := parseStreamCount(r.URL.Query())
+				if err == nil && ok && (page.paged || len(query.sort) > 0) {
+					err = errors.New("cannot be combined with sort or pagination")
+				}
+				if err != nil {
+					writeError(w, http.StatusBadRequest, "Invalid count: "+err.Error())
+					return
+				}
+				if ok {
+					s.streamGenerated(w, r, schema, count, query, expansions, fields)
+					return
+				}
+			}
+			list, total := s.listRecords(r, schema, records, page, query)
+			expandRecords(r, list, expansions)
+			selectListFields(list, fields)
+			page.setHeaders(w, r, s.route(r.URL.Path), total)
+			if wantsNDJSON(r) {
+				streamNDJSON(w, len(list), func(i int) interface{} { return list[i] })
+				return
+			}
+			responseObj = list
+			if s.debugMode {
+				responseObj = map[string]interface{}{
+					"data":  list,
+					"_meta": newListMeta(r.URL.Query(), schema, page, query),
+				}
+			}
+		} else if len(segments) == 2 && onEntity && (segments[1] == countSegment || segments[1] == aggregateSegment) {
+			s.serveAggregate(w, r, schema, records, segments[1])
+			return
+		} else if len(segments) == 2 && onEntity && segments[1] == searchSegment {
+			s.serveSearch(w, r, schema, records)
+			return
+		} else if len(segments) == 2 && onEntity && segments[1] == sampleSegment {
+			// Return freshly generated objects without touching the store
+			count := 1
+			if raw := r.URL.Query().Get("count"); raw != "" {
+				n, err := strconv.Atoi(raw)
+				if err != nil || n < 1 || n > maxSampleCount {
+					writeError(w, http.StatusBadRequest, fmt.Sprintf("Invalid count: expected an integer between 1 and %d", maxSampleCount))
+					return
+				}
+				count = n
+			}
+			samples := make([]map[string]interface{}, 0, count)
+			gen := s.newGenerator(r)
+			for i := 0; i < count; i++ {
+				samples = append(samples, hideWriteOnly(schema, s.dummyData(schema, gen)))
+			}
+			responseObj = samples
+		} else if len(segments) == 2 && onEntity {
+			// Return single dummy object reflecting the requested ID
+			requestedID := segments[1]
+			key := requestedID
+			if n, err := strconv.Atoi(requestedID); err == nil {
+				key = strconv.Itoa(n)
+			}
+			id, ok := parseRecordID(w, schema, requestedID)
+			if !ok {
+				return
+			}
+			requestedID = fmt.Sprint(id)
+			obj := s.generatedRecord(r, schema, key, id)
+			// Prefer a record created through POST over a fabricated one;
+			// deleted records stay gone
+			if stored, ok := records.get(requestedID); ok && (!s.softDeleted(stored) || includeDeleted(r)) {
+				obj = stored
+			} else if ok || s.strictGet || records.wasDeleted(requestedID) {
+				writeNotFound(w, r)
+				return
+			}
+			expansions, err := s.parseExpand(r.URL.Query(), schema)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "Invalid expand: "+err.Error())
+				return
+			}
+			fields, err := parseFields(r.URL.Query(), schema, expansions)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "Invalid fields: "+err.Error())
+				return
+			}
+			obj = hideWriteOnly(schema, obj)
+			expandRecords(r, []map[string]interface{}{obj}, expansions)
+			responseObj = selectFields(obj, fields)
+		} else {
+			writeNotFound(w, r)
+			return
+		}
+	case http.MethodPost:
+		// Create a dummy object overlaid with the fields from the body
+		if !onEntity {
+			writeNotFound(w, r)
+			return
+		}
+		body, err := decodeBody(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid JSON body: "+err.Error())
+			return
+		}
+		dropReadOnly(schema.Properties, body)
+		if !s.checkBody(w, schema, schema.bodyRequired(), body, true) {
+			return
+		}
+		obj := s.dummyData(schema, s.newGenerator(r))
+		delete(obj, schema.idKey()) // assigned by the store unless the body supplies one
+		for key, value := range body {
+			obj[key] = value
+		}
+
+		integerIDs := schema.integerIDs()
+		if s.asyncCreateDelay > 0 {
+			s.writeAccepted(w, s.jobs.submit(s.route("/"+entity), records, obj, integerIDs, s.asyncCreateDelay))
+			return
+		}
+		if err := records.create(obj, integerIDs); err != nil {
+			status := http.StatusBadRequest
+			if err == errDuplicateID {
+				status = http.StatusConflict
+			}
+			writeError(w, status, err.Error())
+			return
+		}
+		w.Header().Set("Location", s.route(fmt.Sprintf("/%s/%v", entity, obj[schema.idKey()])))
+		status = http.StatusCreated
+		responseObj = hideWriteOnly(schema, obj)
+	case http.MethodPut:
+		// Update the record with the body's fields, keeping the ID from the URL
+		if len(segments) == 2 && onEntity {
+			requestedID := segments[1]
+			body, err := decodeBody(r)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "Invalid JSON body: "+err.Error())
+				return
+			}
+
+			id, ok := parseRecordID(w, schema, requestedID)
+			if !ok {
+				return
+			}
+			requestedID = fmt.Sprint(id)
+			idKey := schema.idKey()
+
+			// The URL is authoritative for the id; a different id in the
+			// body is either ignored or rejected.
+			if bodyID, ok := body[idKey]; ok {
+				if !sameID(bodyID, id) && s.rejectIDMismatch {
+					writeError(w, http.StatusUnprocessableEntity, fmt.Sprintf("Body id %v does not match URL id %v", bodyID, id))
+					return
+				}
+				delete(body, idKey)
+			}
+			// A PUT replaces the record, so its body must be one whole.
+			dropReadOnly(schema.Properties, body)
+			if !s.checkBody(w, schema, schema.bodyRequired(), body, true) {
+				return
+			}
+
+			// Putting a record that is not stored, or soft-deleted, creates
+			// it, unless -strict-put is set.
+			obj := s.dummyData(schema, s.newRecordGenerator(r, schema, requestedID))
+			if stored, ok := records.get(requestedID); ok && !s.softDeleted(stored) {
+				obj = stored
+			} else if s.strictPut {
+				writeNotFound(w, r)
+				return
+			} else {
+				status = http.StatusCreated
+			}
+			for key, value := range body {
+				obj[key] = value
+			}
+
+			if prop, ok := schema.Properties[idKey]; ok && prop.Type == "integer" {
+				obj[idKey] = id
+			} else {
+				// Expecting a string ID
+				obj[stringIDKey(schema)] = requestedID
+			}
+			records.put(id, obj)
+			responseObj = hideWriteOnly(schema, obj)
+		} else {
+			writeNotFound(w, r)
+			return
+		}
+	case http.MethodPatch:
+		// Change a stored record. Plain JSON sets the fields in the body,
+		// adding to numeric fields given as {"$inc": n}; merge patches and
+		// JSON patches follow their RFCs
+		if len(segments) == 2 && onEntity {
+			requestedID := segments[1]
+			var change func(obj map[string]interface{}) error
+			var fieldErrs []fieldError
+			switch mediaType := patchMediaType(r); mediaType {
+			case "application/json":
+				body, err := decodeBody(r)
+				if err != nil {
+					writeError(w, http.StatusBadRequest, "Invalid JSON body: "+err.Error())
+					return
+				}
+				id, ok := parseRecordID(w, schema, requestedID)
+				if !ok {
+					return
+				}
+				requestedID = fmt.Sprint(id)
+				if bodyID, ok := body[schema.idKey()]; ok {
+					if !sameID(bodyID, id) && s.rejectIDMismatch {
+						writeError(w, http.StatusUnprocessableEntity, fmt.Sprintf("Body id %v does not match URL id %v", bodyID, id))
+						return
+					}
+					delete(body, schema.idKey())
+				}
+				dropReadOnly(schema.Properties, body)
+				increments, errs := splitIncrements(schema.Properties, body)
+				if len(errs) > 0 {
+					writeErrorDetails(w, http.StatusUnprocessableEntity, "invalid_increment", "Invalid "+incOperator+" operation", errs)
+					return
+				}
+				if !s.checkBody(w, schema, nil, body, false) {
+					return
+				}
+				change = func(obj map[string]interface{}) error {
+					for key, value := range body {
+						obj[key] = value
+					}
+					return applyIncrements(schema.Properties, obj, increments)
+				}
+			case mergePatchContentType, jsonPatchContentType:
+				var apply func(doc map[string]interface{}) error
+				if mediaType == mergePatchContentType {
+					body, err := decodeBody(r)
+					if err != nil {
+						writeError(w, http.StatusBadRequest, "Invalid merge patch: expected a JSON object: "+err.Error())
+						return
+					}
+					apply = func(doc map[string]interface{}) error {
+						mergePatch(doc, body)
+						return nil
+					}
+				} else {
+					ops, err := decodeJSONPatch(r.Body)
+					if err != nil {
+						writeError(w, http.StatusBadRequest, "Invalid JSON patch: "+err.Error())
+						return
+					}
+					apply = func(doc map[string]interface{}) error {
+						return applyJSONPatch(doc, ops)
+					}
+				}
+				id, ok := parseRecordID(w, schema, requestedID)
+				if !ok {
+					return
+				}
+				requestedID = fmt.Sprint(id)
+				change = func(obj map[string]interface{}) error {
+					patched := deepCopy(obj).(map[string]interface{})
+					if err := apply(patched); err != nil {
+						return err
+					}
+					// The id belongs to the URL, as with PUT.
+					if patchedID, ok := patched[schema.idKey()]; ok && !sameID(patchedID, id) && s.rejectIDMismatch {
+						return fmt.Errorf("Patched id %v does not match URL id %v", patchedID, id)
+					}
+					patched[schema.idKey()] = obj[schema.idKey()]
+					keepReadOnly(schema.Properties, patched, obj)
+					// The patch may touch any field, so check the whole
+					// record rather than the body.
+					check := asJSON(patched).(map[string]interface{})
+					fieldErrs = validateRecord(schema, schema.bodyRequired(), check, "", true)
+					if len(fieldErrs) > 0 && s.validationMode != validateWarn {
+						return errPatchInvalid
+					}
+					for key := range obj {
+						delete(obj, key)
+					}
+					for key, value := range patched {
+						obj[key] = value
+					}
+					return nil
+				}
+			default:
+				w.Header().Set("Accept-Patch", acceptPatch)
+				writeError(w, http.StatusUnsupportedMediaType, "Unsupported patch media type "+mediaType)
+				return
+			}
+
+			// Increments need the prior value, so only stored records
+			// can be patched.
+			// Soft-deleted records are gone until restored.
+			obj, found, err := records.update(requestedID, func(obj map[string]interface{}) error {
+				if s.softDeleted(obj) {
+					return errSoftDeleted
+				}
+				return change(obj)
+			})
+			if !found || err == errSoftDeleted {
+				writeNotFound(w, r)
+				return
+			}
+			if err == errPatchInvalid {
+				writeValidationErrors(w, fieldErrs)
+				return
+			}
+			if err != nil {
+				writeError(w, http.StatusUnprocessableEntity, err.Error())
+				return
+			}
+			s.reportFieldErrors(w, fieldErrs)
+			responseObj = hideWriteOnly(schema, obj)
+		} else {
+			writeNotFound(w, r)
+			return
+		}
+	case http.MethodDelete:
+		// Remove the stored record and respond with no content. Deleting
+		// a record that was never stored, or deleting it again, is a 404.
+		if len(segments) == 2 && onEntity {
+			// Validate ID format based on schema expectation
+			id, ok := parseRecordID(w, schema, segments[1])
+			if !ok {
+				return
+			}
+			requestedID := fmt.Sprint(id)
+
+			if s.softDelete {
+				if _, stored := records.get(requestedID); stored {
+					if !s.softDeleteRecord(records, requestedID) {
+						writeNotFound(w, r)
+						return
+					}
+					w.WriteHeader(http.StatusNoContent)
+					return
+				}
+			}
+			if !records.delete(requestedID) {
+				if records.wasDeleted(requestedID) {
+					writeError(w, http.StatusNotFound, "Record "+requestedID+" was already deleted")
+					return
+				}
+				writeNotFound(w, r)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		} else {
+			writeNotFound(w, r)
+			return
+		}
+	default:
+		writeNotFound(w, r)
+		return
+	}
+
+	etag := entityTag(responseObj)
+	w.Header().Set("ETag", etag)
+	if r.Method == http.MethodGet && etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(responseObj); err != nil {
+		log.Println("Error encoding response:", err)
+	}
+}
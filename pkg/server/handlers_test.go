@@ -0,0 +1,693 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Helper to create a sample schema for testing.
+func createSampleSchema() *Schema {
+	return &Schema{
+		Title: "User",
+		Type:  "object",
+		Properties: map[string]Property{
+			"id":    {Type: "integer"},
+			"name":  {Type: "string"},
+			"email": {Type: "string"},
+		},
+		Required: []string{"id", "name", "email"},
+	}
+}
+
+// Helper to perform a request and check the response.
+func performRequest(t *testing.T, handler http.HandlerFunc, method, path string, body []byte) *httptest.ResponseRecorder {
+	req, err := http.NewRequest(method, path, bytes.NewBuffer(body))
+	if err != nil {
+		t.Fatalf("Could not create request: %v", err)
+	}
+	rr := httptest.NewRecorder()
+	handler(rr, req)
+	return rr
+}
+
+func TestUploadHandler(t *testing.T) {
+	srv := NewServer()
+
+	t.Run("Successful Upload", func(t *testing.T) {
+		schema := createSampleSchema()
+		schemaJSON, _ := json.Marshal(schema)
+		rr := performRequest(t, srv.uploadHandler, http.MethodPost, "/upload", schemaJSON)
+
+		if status := rr.Code; status != http.StatusOK {
+			t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+		}
+
+		expected := `{"message":"Schema uploaded successfully","title":"User"}`
+		if strings.TrimSpace(rr.Body.String()) != expected {
+			t.Errorf("handler returned unexpected body: got %v want %v", rr.Body.String(), expected)
+		}
+		if srv.currentSchema == nil || srv.currentSchema.Title != "User" {
+			t.Errorf("currentSchema was not updated correctly")
+		}
+	})
+
+	t.Run("Invalid Method", func(t *testing.T) {
+		rr := performRequest(t, srv.uploadHandler, http.MethodGet, "/upload", nil)
+		if status := rr.Code; status != http.StatusMethodNotAllowed {
+			t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusMethodNotAllowed)
+		}
+	})
+
+	t.Run("Invalid JSON", func(t *testing.T) {
+		rr := performRequest(t, srv.uploadHandler, http.MethodPost, "/upload", []byte("{invalid json"))
+		if status := rr.Code; status != http.StatusBadRequest {
+			t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
+		}
+	})
+
+	t.Run("Trailing Data", func(t *testing.T) {
+		srv.resetState()
+		body := []byte(`{"title":"User","type":"object"}{"title":"Product","type":"object"}`)
+		rr := performRequest(t, srv.uploadHandler, http.MethodPost, "/upload", body)
+		if status := rr.Code; status != http.StatusBadRequest {
+			t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
+		}
+		if srv.currentSchema != nil {
+			t.Errorf("currentSchema was updated despite trailing data")
+		}
+	})
+
+	t.Run("Trailing Whitespace", func(t *testing.T) {
+		body := []byte("{\"title\":\"User\",\"type\":\"object\"}\n\n")
+		rr := performRequest(t, srv.uploadHandler, http.MethodPost, "/upload", body)
+		if status := rr.Code; status != http.StatusOK {
+			t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+		}
+	})
+}
+
+func TestCatchAllHandler(t *testing.T) {
+	srv := NewServer()
+
+	t.Run("No Schema Loaded", func(t *testing.T) {
+		rr := performRequest(t, srv.catchAllHandler, http.MethodGet, "/users", nil)
+		if status := rr.Code; status != http.StatusServiceUnavailable {
+			t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusServiceUnavailable)
+		}
+		if retry := rr.Header().Get("Retry-After"); retry == "" {
+			t.Errorf("handler did not set Retry-After")
+		}
+		expected := "No schema uploaded. Please POST your JSON schema to /upload"
+		if !strings.Contains(rr.Body.String(), expected) {
+			t.Errorf("handler returned unexpected body: got %v want %v", rr.Body.String(), expected)
+		}
+	})
+
+	t.Run("No Schema Loaded Custom Status", func(t *testing.T) {
+		srv.noSchemaStatus = http.StatusBadRequest
+		defer func() { srv.noSchemaStatus = http.StatusServiceUnavailable }()
+		rr := performRequest(t, srv.catchAllHandler, http.MethodGet, "/users", nil)
+		if status := rr.Code; status != http.StatusBadRequest {
+			t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
+		}
+		if retry := rr.Header().Get("Retry-After"); retry != "" {
+			t.Errorf("handler set Retry-After on a non-503 response: got %v", retry)
+		}
+	})
+
+	t.Run("Health Without Schema", func(t *testing.T) {
+		rr := performRequest(t, srv.healthHandler, http.MethodGet, "/healthz", nil)
+		if status := rr.Code; status != http.StatusOK {
+			t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+		}
+		if !strings.Contains(rr.Body.String(), `"schemaLoaded":false`) {
+			t.Errorf("handler returned unexpected body: got %v", rr.Body.String())
+		}
+	})
+
+	// Load schema for subsequent tests
+	srv.currentSchema = createSampleSchema()
+	srv.store = newRecordStore()
+	entityPlural := "users" // Based on schema title "User"
+
+	t.Run("GET List", func(t *testing.T) {
+		rr := performRequest(t, srv.catchAllHandler, http.MethodGet, "/"+entityPlural, nil)
+		if status := rr.Code; status != http.StatusOK {
+			t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+		}
+		// Check if it's a JSON array
+		if !strings.HasPrefix(rr.Body.String(), "[") {
+			t.Errorf("handler returned non-array body for list: got %v", rr.Body.String())
+		}
+	})
+
+	t.Run("GET Single", func(t *testing.T) {
+		rr := performRequest(t, srv.catchAllHandler, http.MethodGet, "/"+entityPlural+"/123", nil)
+		if status := rr.Code; status != http.StatusOK {
+			t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+		}
+		// Check if it's a JSON object and contains the ID
+		if !strings.HasPrefix(rr.Body.String(), "{") || !strings.Contains(rr.Body.String(), `"id":123`) {
+			t.Errorf("handler returned unexpected body for single item: got %v", rr.Body.String())
+		}
+	})
+
+	t.Run("GET Invalid ID", func(t *testing.T) {
+		rr := performRequest(t, srv.catchAllHandler, http.MethodGet, "/"+entityPlural+"/abc", nil)
+		if status := rr.Code; status != http.StatusBadRequest {
+			t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
+		}
+	})
+
+	t.Run("GET Non-existent Entity", func(t *testing.T) {
+		rr := performRequest(t, srv.catchAllHandler, http.MethodGet, "/products", nil)
+		if status := rr.Code; status != http.StatusNotFound {
+			t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusNotFound)
+		}
+	})
+
+	t.Run("POST", func(t *testing.T) {
+		rr := performRequest(t, srv.catchAllHandler, http.MethodPost, "/"+entityPlural, []byte(`{"name":"test","email":"test@example.com"}`))
+		if status := rr.Code; status != http.StatusCreated {
+			t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusCreated)
+		}
+		if location := rr.Header().Get("Location"); location != "/"+entityPlural+"/1" {
+			t.Errorf("handler returned wrong Location header: got %v want %v", location, "/"+entityPlural+"/1")
+		}
+		if !strings.HasPrefix(rr.Body.String(), "{") || !strings.Contains(rr.Body.String(), `"id":1`) {
+			t.Errorf("handler returned unexpected body for POST: got %v", rr.Body.String())
+		}
+	})
+
+	t.Run("PUT", func(t *testing.T) {
+		rr := performRequest(t, srv.catchAllHandler, http.MethodPut, "/"+entityPlural+"/456", []byte(`{"name":"updated","email":"u@example.com"}`))
+		if status := rr.Code; status != http.StatusCreated {
+			t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusCreated)
+		}
+		if !strings.HasPrefix(rr.Body.String(), "{") || !strings.Contains(rr.Body.String(), `"id":456`) {
+			t.Errorf("handler returned unexpected body for PUT: got %v", rr.Body.String())
+		}
+	})
+
+	t.Run("PUT Invalid ID", func(t *testing.T) {
+		rr := performRequest(t, srv.catchAllHandler, http.MethodPut, "/"+entityPlural+"/abc", nil)
+		if status := rr.Code; status != http.StatusBadRequest {
+			t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
+		}
+	})
+
+	t.Run("DELETE", func(t *testing.T) {
+		rr := performRequest(t, srv.catchAllHandler, http.MethodDelete, "/"+entityPlural+"/456", nil)
+		if status := rr.Code; status != http.StatusNoContent {
+			t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusNoContent)
+		}
+		if rr.Body.Len() != 0 {
+			t.Errorf("handler returned a body for DELETE: got %v", rr.Body.String())
+		}
+	})
+
+	t.Run("DELETE Again", func(t *testing.T) {
+		rr := performRequest(t, srv.catchAllHandler, http.MethodDelete, "/"+entityPlural+"/456", nil)
+		if status := rr.Code; status != http.StatusNotFound || !strings.Contains(rr.Body.String(), "already deleted") {
+			t.Errorf("handler returned wrong response: got %v %v", status, rr.Body.String())
+		}
+	})
+
+	t.Run("DELETE Never Created", func(t *testing.T) {
+		rr := performRequest(t, srv.catchAllHandler, http.MethodDelete, "/"+entityPlural+"/789", nil)
+		if status := rr.Code; status != http.StatusNotFound {
+			t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusNotFound)
+		}
+	})
+
+	t.Run("DELETE Invalid ID", func(t *testing.T) {
+		rr := performRequest(t, srv.catchAllHandler, http.MethodDelete, "/"+entityPlural+"/abc", nil)
+		if status := rr.Code; status != http.StatusBadRequest {
+			t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
+		}
+	})
+
+	t.Run("Unsupported Method", func(t *testing.T) {
+		rr := performRequest(t, srv.catchAllHandler, http.MethodOptions, "/"+entityPlural+"/1", nil)
+		if status := rr.Code; status != http.StatusMethodNotAllowed {
+			t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusMethodNotAllowed)
+		}
+	})
+}
+func TestListDebugMeta(t *testing.T) {
+	srv := NewServer()
+	srv.currentSchema = createSampleSchema()
+	srv.store = newRecordStore()
+
+	t.Run("Omitted When Debug Off", func(t *testing.T) {
+		srv.debugMode = false
+		rr := performRequest(t, srv.catchAllHandler, http.MethodGet, "/users?page=2", nil)
+		if strings.Contains(rr.Body.String(), "_meta") {
+			t.Errorf("handler returned _meta with debug off: got %v", rr.Body.String())
+		}
+	})
+
+	t.Run("Included When Debug On", func(t *testing.T) {
+		srv.debugMode = true
+		rr := performRequest(t, srv.catchAllHandler, http.MethodGet, "/users?page=1&nme=alice&utm=ad", nil)
+		if status := rr.Code; status != http.StatusOK {
+			t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+		}
+		var body struct {
+			Data []map[string]interface{} `json:"data"`
+			Meta listMeta                 `json:"_meta"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+			t.Fatalf("could not decode response: %v", err)
+		}
+		if len(body.Data) != 3 {
+			t.Errorf("handler returned wrong number of items: got %v want %v", len(body.Data), 3)
+		}
+		if body.Meta.Query.Get("nme") != "alice" {
+			t.Errorf("_meta did not echo query: got %v", body.Meta.Query)
+		}
+		if strings.Join(body.Meta.Ignored, ",") != "nme,utm" {
+			t.Errorf("_meta reported wrong ignored params: got %v", body.Meta.Ignored)
+		}
+		if body.Meta.Page != 1 || body.Meta.Offset != 0 || body.Meta.Limit != defaultPageLimit || len(body.Meta.Filters) != 0 || len(body.Meta.Sort) != 0 {
+			t.Errorf("_meta reported wrong interpretation: got %+v", body.Meta)
+		}
+	})
+
+	t.Run("Reports Parsed Query", func(t *testing.T) {
+		srv.debugMode = true
+		rr := performRequest(t, srv.catchAllHandler, http.MethodGet, "/users?id_gte=2&name=alice&sort=-name,id&page=3&limit=5", nil)
+		var body struct {
+			Meta listMeta `json:"_meta"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+			t.Fatalf("could not decode response: %v", err)
+		}
+		meta := body.Meta
+		if meta.Offset != 10 || meta.Limit != 5 || meta.Page != 3 || meta.Cursor != "" {
+			t.Errorf("_meta reported wrong pagination: got %+v", meta)
+		}
+		wantFilters := []metaFilter{{Field: "id", Op: "gte", Values: []interface{}{2.0}}, {Field: "name", Op: "eq", Values: []interface{}{"alice"}}}
+		if !reflect.DeepEqual(meta.Filters, wantFilters) {
+			t.Errorf("_meta reported wrong filters: got %+v want %+v", meta.Filters, wantFilters)
+		}
+		wantSort := []metaSort{{Field: "name", Order: "desc"}, {Field: "id", Order: "asc"}}
+		if !reflect.DeepEqual(meta.Sort, wantSort) {
+			t.Errorf("_meta reported wrong sort: got %+v want %+v", meta.Sort, wantSort)
+		}
+
+		rr = performRequest(t, srv.catchAllHandler, http.MethodGet, "/users?cursor="+encodeCursor(2), nil)
+		body.Meta = listMeta{}
+		json.Unmarshal(rr.Body.Bytes(), &body)
+		if body.Meta.Offset != 2 || body.Meta.Cursor != encodeCursor(2) || body.Meta.Page != 0 {
+			t.Errorf("_meta reported wrong cursor pagination: got %+v", body.Meta)
+		}
+	})
+}
+
+func TestCreateWithExplicitID(t *testing.T) {
+	srv := NewServer()
+	srv.currentSchema = createSampleSchema()
+	srv.store = newRecordStore()
+
+	rr := performRequest(t, srv.catchAllHandler, http.MethodPost, "/users", []byte(`{"id":42,"name":"imported","email":"i@example.com"}`))
+	if status := rr.Code; status != http.StatusCreated {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusCreated)
+	}
+	if !strings.Contains(rr.Body.String(), `"id":42`) {
+		t.Errorf("handler did not honor explicit id: got %v", rr.Body.String())
+	}
+
+	rr = performRequest(t, srv.catchAllHandler, http.MethodPost, "/users", []byte(`{"name":"next","email":"n@example.com"}`))
+	if !strings.Contains(rr.Body.String(), `"id":43`) {
+		t.Errorf("handler did not continue after explicit id: got %v", rr.Body.String())
+	}
+
+	rr = performRequest(t, srv.catchAllHandler, http.MethodGet, "/users/42", nil)
+	if !strings.Contains(rr.Body.String(), `"name":"imported"`) {
+		t.Errorf("handler did not return stored record: got %v", rr.Body.String())
+	}
+
+	rr = performRequest(t, srv.catchAllHandler, http.MethodPost, "/users", []byte(`{"id":42,"name":"dup","email":"d@example.com"}`))
+	if status := rr.Code; status != http.StatusConflict {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusConflict)
+	}
+
+	rr = performRequest(t, srv.catchAllHandler, http.MethodPost, "/users", []byte(`{invalid`))
+	if status := rr.Code; status != http.StatusBadRequest {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
+	}
+}
+
+func TestRootHandler(t *testing.T) {
+	srv := NewServer()
+	t.Run("No Schema", func(t *testing.T) {
+		srv.resetState()
+		rr := performRequest(t, srv.catchAllHandler, http.MethodGet, "/", nil)
+		if status := rr.Code; status != http.StatusOK {
+			t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+		}
+		if !strings.Contains(rr.Body.String(), `"entities":[]`) || !strings.Contains(rr.Body.String(), `"upload":"/upload"`) {
+			t.Errorf("handler returned unexpected body: got %v", rr.Body.String())
+		}
+	})
+
+	t.Run("Lists Entities", func(t *testing.T) {
+		schemaJSON, _ := json.Marshal(createSampleSchema())
+		performRequest(t, srv.uploadHandler, http.MethodPost, "/upload", schemaJSON)
+		rr := performRequest(t, srv.catchAllHandler, http.MethodGet, "/", nil)
+		if !strings.Contains(rr.Body.String(), `"entities":["/users"]`) {
+			t.Errorf("handler returned unexpected body: got %v", rr.Body.String())
+		}
+	})
+
+	t.Run("Invalid Method", func(t *testing.T) {
+		rr := performRequest(t, srv.catchAllHandler, http.MethodPost, "/", nil)
+		if status := rr.Code; status != http.StatusMethodNotAllowed {
+			t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+func TestUploadRejectsInvalidMethods(t *testing.T) {
+	srv := NewServer()
+	body := []byte(`{"title":"User","type":"object","methods":["GET","TRACE"]}`)
+	rr := performRequest(t, srv.uploadHandler, http.MethodPost, "/upload", body)
+	if status := rr.Code; status != http.StatusBadRequest {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
+	}
+	if srv.currentSchema != nil {
+		t.Errorf("currentSchema was updated despite invalid methods")
+	}
+}
+
+func TestMethodAllowlist(t *testing.T) {
+	srv := NewServer()
+	srv.currentSchema = createSampleSchema()
+	srv.currentSchema.Methods = []string{"GET", "POST"}
+	srv.store = newRecordStore()
+
+	rr := performRequest(t, srv.catchAllHandler, http.MethodGet, "/users/1", nil)
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	rr = performRequest(t, srv.catchAllHandler, http.MethodDelete, "/users/1", nil)
+	if status := rr.Code; status != http.StatusMethodNotAllowed {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusMethodNotAllowed)
+	}
+	if allow := rr.Header().Get("Allow"); allow != "GET, HEAD" {
+		t.Errorf("handler returned wrong Allow header: got %v want %v", allow, "GET, HEAD")
+	}
+}
+
+func TestRouteMethodNotAllowed(t *testing.T) {
+	srv := NewServer()
+	srv.currentSchema = createSampleSchema()
+	srv.store = newRecordStore()
+
+	for _, tc := range []struct {
+		method, path, allow string
+	}{
+		{http.MethodPost, "/users/1", "GET, HEAD, PUT, PATCH, DELETE"},
+		{http.MethodDelete, "/users", "GET, HEAD, POST"},
+		{http.MethodPut, "/users", "GET, HEAD, POST"},
+	} {
+		rr := performRequest(t, srv.catchAllHandler, tc.method, tc.path, nil)
+		if status := rr.Code; status != http.StatusMethodNotAllowed {
+			t.Errorf("%s %s: handler returned wrong status code: got %v want %v", tc.method, tc.path, status, http.StatusMethodNotAllowed)
+		}
+		if allow := rr.Header().Get("Allow"); allow != tc.allow {
+			t.Errorf("%s %s: handler returned wrong Allow header: got %v want %v", tc.method, tc.path, allow, tc.allow)
+		}
+	}
+
+	rr := performRequest(t, srv.uploadHandler, http.MethodGet, "/upload", nil)
+	if allow := rr.Header().Get("Allow"); rr.Code != http.StatusMethodNotAllowed || allow != http.MethodPost {
+		t.Errorf("upload returned %v with Allow %q, want %v with %q", rr.Code, allow, http.StatusMethodNotAllowed, http.MethodPost)
+	}
+}
+
+func TestHeadRequests(t *testing.T) {
+	srv := NewServer()
+	srv.currentSchema = createSampleSchema()
+	srv.store = newRecordStore()
+
+	for _, path := range []string{"/users", "/users/1", "/users/count"} {
+		rr := performRequest(t, srv.catchAllHandler, http.MethodHead, path, nil)
+		if status := rr.Code; status != http.StatusOK {
+			t.Errorf("HEAD %s: handler returned wrong status code: got %v want %v", path, status, http.StatusOK)
+		}
+		if rr.Body.Len() != 0 || rr.Header().Get("Content-Type") != "application/json" {
+			t.Errorf("HEAD %s: handler returned unexpected response: got %q with Content-Type %q", path, rr.Body.String(), rr.Header().Get("Content-Type"))
+		}
+	}
+	if rr := performRequest(t, srv.catchAllHandler, http.MethodHead, "/users/abc", nil); rr.Code != http.StatusBadRequest || rr.Body.Len() != 0 {
+		t.Errorf("HEAD of an invalid id returned %v %q", rr.Code, rr.Body.String())
+	}
+	if srv.store.len() != 0 {
+		t.Errorf("HEAD changed the store")
+	}
+}
+
+func TestSampleEndpoint(t *testing.T) {
+	srv := NewServer()
+	srv.currentSchema = createSampleSchema()
+	srv.store = newRecordStore()
+
+	rr := performRequest(t, srv.catchAllHandler, http.MethodGet, "/users/sample?count=5", nil)
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+	var samples []map[string]interface{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &samples); err != nil {
+		t.Fatalf("could not decode response: %v", err)
+	}
+	if len(samples) != 5 {
+		t.Errorf("handler returned wrong number of samples: got %v want %v", len(samples), 5)
+	}
+	if srv.store.len() != 0 {
+		t.Errorf("sample endpoint stored records: got %v", srv.store.len())
+	}
+
+	rr = performRequest(t, srv.catchAllHandler, http.MethodGet, "/users/sample", nil)
+	if err := json.Unmarshal(rr.Body.Bytes(), &samples); err != nil || len(samples) != 1 {
+		t.Errorf("handler returned wrong default sample count: got %v", rr.Body.String())
+	}
+
+	for _, count := range []string{"0", "abc", "100000"} {
+		rr = performRequest(t, srv.catchAllHandler, http.MethodGet, "/users/sample?count="+count, nil)
+		if status := rr.Code; status != http.StatusBadRequest {
+			t.Errorf("count=%v: handler returned wrong status code: got %v want %v", count, status, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestPutKeepsURLID(t *testing.T) {
+	srv := NewServer()
+	srv.currentSchema = createSampleSchema()
+	srv.store = newRecordStore()
+
+	t.Run("Body ID Ignored", func(t *testing.T) {
+		rr := performRequest(t, srv.catchAllHandler, http.MethodPut, "/users/5", []byte(`{"id":9,"name":"renamed","email":"r@example.com"}`))
+		if status := rr.Code; status != http.StatusCreated {
+			t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusCreated)
+		}
+		if !strings.Contains(rr.Body.String(), `"id":5`) || !strings.Contains(rr.Body.String(), `"name":"renamed"`) {
+			t.Errorf("handler returned unexpected body: got %v", rr.Body.String())
+		}
+		if _, ok := srv.store.get("9"); ok {
+			t.Errorf("record was stored under the body id")
+		}
+		rr = performRequest(t, srv.catchAllHandler, http.MethodGet, "/users/5", nil)
+		if !strings.Contains(rr.Body.String(), `"name":"renamed"`) {
+			t.Errorf("update was not stored: got %v", rr.Body.String())
+		}
+	})
+
+	t.Run("Body ID Mismatch Rejected", func(t *testing.T) {
+		srv.rejectIDMismatch = true
+		defer func() { srv.rejectIDMismatch = false }()
+		rr := performRequest(t, srv.catchAllHandler, http.MethodPut, "/users/5", []byte(`{"id":9,"name":"other","email":"o@example.com"}`))
+		if status := rr.Code; status != http.StatusUnprocessableEntity {
+			t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusUnprocessableEntity)
+		}
+		rr = performRequest(t, srv.catchAllHandler, http.MethodPut, "/users/5", []byte(`{"id":5,"name":"same","email":"s@example.com"}`))
+		if status := rr.Code; status != http.StatusOK {
+			t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+		}
+	})
+}
+
+func TestStrictGet(t *testing.T) {
+	srv := NewServer()
+	srv.currentSchema = createSampleSchema()
+	srv.store = newRecordStore()
+	srv.strictGet = true
+
+	rr := performRequest(t, srv.catchAllHandler, http.MethodGet, "/users/999", nil)
+	if status := rr.Code; status != http.StatusNotFound {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusNotFound)
+	}
+
+	performRequest(t, srv.catchAllHandler, http.MethodPost, "/users", []byte(`{"id":999,"name":"n","email":"e"}`))
+	rr = performRequest(t, srv.catchAllHandler, http.MethodGet, "/users/999", nil)
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	rr = performRequest(t, srv.catchAllHandler, http.MethodDelete, "/users/998", nil)
+	if status := rr.Code; status != http.StatusNotFound {
+		t.Errorf("DELETE of an unstored record returned wrong status code: got %v want %v", status, http.StatusNotFound)
+	}
+}
+
+func TestStrictPut(t *testing.T) {
+	srv := NewServer()
+	srv.currentSchema = createSampleSchema()
+	srv.store = newRecordStore()
+	srv.strictPut = true
+
+	rr := performRequest(t, srv.catchAllHandler, http.MethodPut, "/users/999", []byte(`{"name":"n","email":"n@example.com"}`))
+	if status := rr.Code; status != http.StatusNotFound {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusNotFound)
+	}
+	if _, stored := srv.store.get("999"); stored {
+		t.Errorf("PUT of an unstored record created it")
+	}
+
+	performRequest(t, srv.catchAllHandler, http.MethodPost, "/users", []byte(`{"id":999,"name":"n","email":"e"}`))
+	rr = performRequest(t, srv.catchAllHandler, http.MethodPut, "/users/999", []byte(`{"name":"m","email":"m@example.com"}`))
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+}
+
+func TestUploadExtends(t *testing.T) {
+	srv := NewServer()
+	srv.schemas = make(map[string]*Schema)
+	base, _ := json.Marshal(createSampleSchema())
+	performRequest(t, srv.uploadHandler, http.MethodPost, "/upload", base)
+
+	rr := performRequest(t, srv.uploadHandler, http.MethodPost, "/upload", []byte(`{"title":"Admin","extends":"user","properties":{"level":{"type":"integer"}}}`))
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+	if _, ok := srv.currentSchema.Properties["email"]; !ok {
+		t.Errorf("uploaded schema did not inherit base properties: got %v", srv.currentSchema.Properties)
+	}
+
+	rr = performRequest(t, srv.uploadHandler, http.MethodPost, "/upload", []byte(`{"title":"Orphan","extends":"nothing"}`))
+	if status := rr.Code; status != http.StatusBadRequest {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
+	}
+	srv.resetState()
+}
+
+func TestLooseRoutes(t *testing.T) {
+	srv := NewServer()
+	srv.currentSchema = createSampleSchema()
+	srv.store = newRecordStore()
+
+	rr := performRequest(t, srv.catchAllHandler, http.MethodGet, "/user/1", nil)
+	if status := rr.Code; status != http.StatusNotFound {
+		t.Errorf("singular route matched without -loose-routes: got %v", status)
+	}
+
+	srv.looseRoutes = true
+	performRequest(t, srv.catchAllHandler, http.MethodPost, "/user", []byte(`{"id":7,"name":"single","email":"s@example.com"}`))
+	for _, path := range []string{"/user/7", "/users/7"} {
+		rr = performRequest(t, srv.catchAllHandler, http.MethodGet, path, nil)
+		if status := rr.Code; status != http.StatusOK || !strings.Contains(rr.Body.String(), `"name":"single"`) {
+			t.Errorf("GET %v: got %v %v", path, status, rr.Body.String())
+		}
+	}
+}
+
+func TestListReturnsStoredRecordsInOrder(t *testing.T) {
+	srv := NewServer()
+	srv.currentSchema = createSampleSchema()
+	srv.store = newRecordStore()
+
+	for _, id := range []string{"7", "3", "5"} {
+		rr := performRequest(t, srv.catchAllHandler, http.MethodPost, "/users", []byte(`{"id":`+id+`,"name":"n","email":"e"}`))
+		if status := rr.Code; status != http.StatusCreated {
+			t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusCreated)
+		}
+	}
+	rr := performRequest(t, srv.catchAllHandler, http.MethodGet, "/users", nil)
+	var list []map[string]interface{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &list); err != nil {
+		t.Fatalf("could not decode response: %v", err)
+	}
+	var ids []string
+	for _, obj := range list {
+		ids = append(ids, fmt.Sprint(obj["id"]))
+	}
+	if strings.Join(ids, ",") != "7,3,5" {
+		t.Errorf("handler listed records in wrong order: got %v want %v", ids, "7,3,5")
+	}
+}
+
+func TestNestedObjectResponse(t *testing.T) {
+	srv := NewServer()
+	schemaJSON, err := os.ReadFile("../../user_schema.json")
+	if err != nil {
+		t.Fatalf("could not read sample schema: %v", err)
+	}
+	rr := performRequest(t, srv.uploadHandler, http.MethodPost, "/upload", schemaJSON)
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	rr = performRequest(t, srv.catchAllHandler, http.MethodGet, "/users/1", nil)
+	var user struct {
+		Address map[string]interface{} `json:"address"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &user); err != nil {
+		t.Fatalf("could not decode response: %v", err)
+	}
+	if user.Address["street"] != "example" || user.Address["city"] != "example" {
+		t.Errorf("handler returned incomplete nested object: got %v", rr.Body.String())
+	}
+}
+
+func TestCRUDLifecycle(t *testing.T) {
+	srv := NewServer()
+	srv.currentSchema = createSampleSchema()
+	srv.store = newRecordStore()
+
+	rr := performRequest(t, srv.catchAllHandler, http.MethodPost, "/users", []byte(`{"name":"alice","email":"a@example.com"}`))
+	var created map[string]interface{}
+	json.Unmarshal(rr.Body.Bytes(), &created)
+	path := fmt.Sprintf("/users/%v", created["id"])
+
+	performRequest(t, srv.catchAllHandler, http.MethodPut, path, []byte(`{"name":"alicia","email":"a@example.com"}`))
+	rr = performRequest(t, srv.catchAllHandler, http.MethodGet, path, nil)
+	if !strings.Contains(rr.Body.String(), `"name":"alicia"`) {
+		t.Errorf("update is not reflected: got %v", rr.Body.String())
+	}
+
+	rr = performRequest(t, srv.catchAllHandler, http.MethodDelete, path, nil)
+	if status := rr.Code; status != http.StatusNoContent {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusNoContent)
+	}
+	for _, method := range []string{http.MethodGet, http.MethodDelete} {
+		rr = performRequest(t, srv.catchAllHandler, method, path, nil)
+		if status := rr.Code; status != http.StatusNotFound {
+			t.Errorf("%s after delete returned wrong status code: got %v want %v", method, status, http.StatusNotFound)
+		}
+	}
+
+	rr = performRequest(t, srv.catchAllHandler, http.MethodGet, "/users", nil)
+	if body := strings.TrimSpace(rr.Body.String()); body != "[]" {
+		t.Errorf("list after deleting every record is not empty: got %v", body)
+	}
+}
@@ -0,0 +1,168 @@
+package server
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// harFile is the part of an HTTP Archive (HAR 1.2), as browsers export
+// it, that stubs are built from.
+type harFile struct {
+	Log struct {
+		Entries []harEntry `json:"entries"`
+	} `json:"log"`
+}
+
+// harEntry is one captured request and its response.
+type harEntry struct {
+	Request struct {
+		Method string `json:"method"`
+		URL    string `json:"url"`
+	} `json:"request"`
+	Response struct {
+		Status  int `json:"status"`
+		Headers []struct {
+			Name  string `json:"name"`
+			Value string `json:"value"`
+		} `json:"headers"`
+		Content struct {
+			MimeType string `json:"mimeType"`
+			Text     string `json:"text"`
+			Encoding string `json:"encoding"`
+		} `json:"content"`
+	} `json:"response"`
+}
+
+// harSkippedHeaders are response headers that described the captured
+// transfer rather than the response, and are not replayed.
+var harSkippedHeaders = map[string]bool{
+	"Connection":        true,
+	"Content-Encoding":  true,
+	"Content-Length":    true,
+	"Date":              true,
+	"Keep-Alive":        true,
+	"Transfer-Encoding": true,
+}
+
+// harResponse turns a captured response into a scripted one. JSON bodies
+// are kept as values; others, decoded from base64 when the capture
+// encoded them, are sent as captured.
+func harResponse(e harEntry) (overrideResponse, error) {
+	resp := overrideResponse{Status: e.Response.Status, Headers: make(map[string]string)}
+	for _, h := range e.Response.Headers {
+		name := http.CanonicalHeaderKey(h.Name)
+		if strings.HasPrefix(name, ":") || harSkippedHeaders[name] {
+			continue
+		}
+		resp.Headers[name] = h.Value
+	}
+	content := e.Response.Content
+	if content.MimeType != "" && resp.Headers["Content-Type"] == "" {
+		resp.Headers["Content-Type"] = content.MimeType
+	}
+	text := content.Text
+	if content.Encoding == "base64" {
+		raw, err := base64.StdEncoding.DecodeString(text)
+		if err != nil {
+			return resp, fmt.Errorf("%s %s: invalid base64 body: %v", e.Request.Method, e.Request.URL, err)
+		}
+		text = string(raw)
+	}
+	if text == "" {
+		return resp, nil
+	}
+	var value interface{}
+	if strings.Contains(resp.Headers["Content-Type"], "json") && json.Unmarshal([]byte(text), &value) == nil {
+		resp.Body = value
+	} else {
+		resp.Body = text
+	}
+	return resp, nil
+}
+
+// parseHAR builds an override for every method and path captured in a
+// HAR document, in the order they were first requested. Repeated requests
+// are answered with their captured responses in turn, the last one
+// repeating, so a list that changed during the session changes again on
+// replay. Query strings are ignored, and requests that got no response,
+// such as blocked ones, or were not made over HTTP are skipped.
+func (s *Server) parseHAR(data []byte) ([]responseOverride, error) {
+	var file harFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+	var overrides []*responseOverride
+	byRoute := make(map[string]*responseOverride)
+	for _, e := range file.Log.Entries {
+		u, err := url.Parse(e.Request.URL)
+		if err != nil {
+			return nil, fmt.Errorf("%s %s: %v", e.Request.Method, e.Request.URL, err)
+		}
+		if (u.Scheme != "http" && u.Scheme != "https") || e.Response.Status == 0 {
+			continue
+		}
+		resp, err := harResponse(e)
+		if err != nil {
+			return nil, err
+		}
+		path := u.Path
+		if path == "" {
+			path = "/"
+		}
+		key := strings.ToUpper(e.Request.Method) + " " + path
+		o, ok := byRoute[key]
+		if !ok {
+			o = &responseOverride{Method: e.Request.Method, Path: path}
+			byRoute[key] = o
+			overrides = append(overrides, o)
+		}
+		if n := len(o.Responses); n > 0 {
+			o.Responses[n-1].Times = 1
+		}
+		o.Responses = append(o.Responses, resp)
+	}
+	checked := make([]responseOverride, len(overrides))
+	for i, o := range overrides {
+		var err error
+		if checked[i], err = s.checkOverride(*o); err != nil {
+			return nil, err
+		}
+	}
+	return checked, nil
+}
+
+// adminImport serves POST /__admin/import/har, which adds the stubs
+// parseHAR builds from an exported HAR document as response overrides and
+// answers with them, so a browsing session against a real site becomes a
+// mock of it.
+func (s *Server) adminImport(w http.ResponseWriter, r *http.Request, format string) {
+	if format != "har" {
+		writeNotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w, "Only POST allowed", http.MethodPost)
+		return
+	}
+	data, err := io.ReadAll(r.Body)
+	var overrides []responseOverride
+	if err == nil {
+		overrides, err = s.parseHAR(data)
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid HAR file: "+err.Error())
+		return
+	}
+	added := make([]responseOverride, len(overrides))
+	for i, o := range overrides {
+		added[i] = s.responseOverrides.add(o)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(added)
+}
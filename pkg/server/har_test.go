@@ -0,0 +1,71 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestImportHAR(t *testing.T) {
+	srv := NewServer()
+	handler := srv.Handler()
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rr
+	}
+
+	har := `{"log": {"version": "1.2", "entries": [
+		{"request": {"method": "GET", "url": "https://shop.example.com/api/cart?session=1"},
+		 "response": {"status": 200, "headers": [{"name": "content-type", "value": "application/json"}, {"name": "Content-Length", "value": "12"}, {"name": "X-Request-Id", "value": "abc"}],
+		  "content": {"mimeType": "application/json", "text": "{\"items\": []}"}}},
+		{"request": {"method": "POST", "url": "https://shop.example.com/api/cart"},
+		 "response": {"status": 201, "headers": [], "content": {"mimeType": "application/json", "text": "eyJpZCI6IDF9", "encoding": "base64"}}},
+		{"request": {"method": "GET", "url": "https://shop.example.com/api/cart"},
+		 "response": {"status": 200, "headers": [], "content": {"mimeType": "application/json", "text": "{\"items\": [1]}"}}},
+		{"request": {"method": "GET", "url": "https://ads.example.com/pixel"},
+		 "response": {"status": 0, "headers": [], "content": {}}},
+		{"request": {"method": "GET", "url": "https://shop.example.com/"},
+		 "response": {"status": 200, "headers": [], "content": {"mimeType": "text/html", "text": "<h1>Shop</h1>"}}}
+	]}}`
+	rr := serve(http.MethodPost, "/__admin/import/har", har)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("handler returned wrong status code: got %v want %v: %s", rr.Code, http.StatusCreated, rr.Body.String())
+	}
+	if n := len(srv.responseOverrides.list()); n != 3 {
+		t.Errorf("wrong number of stubs registered: got %d want 3", n)
+	}
+
+	tests := []struct {
+		method, path, body string
+		wantStatus         int
+		wantBody           string
+	}{
+		{http.MethodGet, "/api/cart", "", http.StatusOK, `{"items":[]}`},
+		{http.MethodPost, "/api/cart", "{}", http.StatusCreated, `{"id":1}`},
+		{http.MethodGet, "/api/cart", "", http.StatusOK, `{"items":[1]}`},
+		{http.MethodGet, "/api/cart", "", http.StatusOK, `{"items":[1]}`},
+		{http.MethodGet, "/", "", http.StatusOK, `<h1>Shop</h1>`},
+	}
+	for i, tt := range tests {
+		rr := serve(tt.method, tt.path, tt.body)
+		if i == 0 && (rr.Header().Get("X-Request-Id") != "abc" || rr.Header().Get("Content-Length") == "12") {
+			t.Errorf("captured headers were not replayed as expected: %v", rr.Header())
+		}
+		if rr.Code != tt.wantStatus {
+			t.Errorf("%s %s: handler returned wrong status code: got %v want %v", tt.method, tt.path, rr.Code, tt.wantStatus)
+		}
+		if got := strings.TrimSpace(rr.Body.String()); got != tt.wantBody {
+			t.Errorf("%s %s: got body %s want %s", tt.method, tt.path, got, tt.wantBody)
+		}
+	}
+
+	if rr := serve(http.MethodPost, "/__admin/import/har", `{"log": `); rr.Code != http.StatusBadRequest {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusBadRequest)
+	}
+	if rr := serve(http.MethodGet, "/__admin/import/har", ""); rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,134 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ID strategies control how the store assigns the ids of new records and
+// what route ids look like.
+const (
+	// idIncrement numbers records from x-id-start by x-id-step.
+	idIncrement = "increment"
+	// idUUID assigns random version 4 UUIDs.
+	idUUID = "uuid"
+	// idULID assigns ULIDs, which sort by creation time.
+	idULID = "ulid"
+	// idNanoID assigns 21-character nanoids.
+	idNanoID = "nanoid"
+)
+
+// idStrategies lists the valid ID strategies.
+var idStrategies = []string{idIncrement, idUUID, idULID, idNanoID}
+
+// idKey returns the name of the property holding the records' ids:
+// x-id-property, or id.
+func (s *Schema) idKey() string {
+	if s.IDProperty != "" {
+		return s.IDProperty
+	}
+	return "id"
+}
+
+// idStrategy returns the schema's x-id-strategy, or idIncrement.
+func (s *Schema) idStrategy() string {
+	if s.IDStrategy != "" {
+		return s.IDStrategy
+	}
+	return idIncrement
+}
+
+// integerIDs reports whether the store assigns the schema's records integer
+// ids: auto-incremented ones, unless the id property is declared a string.
+func (s *Schema) integerIDs() bool {
+	prop, declared := s.Properties[s.idKey()]
+	return s.idStrategy() == idIncrement && (!declared || prop.Type != "string")
+}
+
+// idPropertyPattern matches the names x-id-property may give the id.
+var idPropertyPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+
+// validateIDStrategy checks the schema's x-id-property and x-id-strategy:
+// random ids need an id property that is a string or left undeclared.
+func validateIDStrategy(schema *Schema) error {
+	if schema.IDProperty != "" && !idPropertyPattern.MatchString(schema.IDProperty) {
+		return fmt.Errorf("x-id-property must be a top-level property name of letters, digits, '_' or '-', got %q", schema.IDProperty)
+	}
+	strategy := schema.idStrategy()
+	if !containsString(idStrategies, strategy) {
+		return fmt.Errorf("unknown x-id-strategy %q, expected one of %s", strategy, strings.Join(idStrategies, ", "))
+	}
+	if prop, declared := schema.Properties[schema.idKey()]; declared && strategy != idIncrement && prop.Type != "string" {
+		return fmt.Errorf("x-id-strategy %s needs %s to be a string, got %s", strategy, schema.idKey(), prop.Type)
+	}
+	return nil
+}
+
+// newRecordID returns a new id of a random strategy, drawing from rng. ULIDs
+// carry t as their timestamp.
+func newRecordID(strategy string, rng *rand.Rand, t time.Time) string {
+	switch strategy {
+	case idUUID:
+		var b [16]byte
+		rng.Read(b[:])
+		b[6] = b[6]&0x0f | 0x40 // version 4
+		b[8] = b[8]&0x3f | 0x80 // RFC 4122 variant
+		return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+	case idULID:
+		// 48 bits of milliseconds, then 80 random bits, in Crockford's
+		// base 32.
+		const alphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+		ms := uint64(t.UnixMilli())
+		id := make([]byte, 26)
+		for i := 9; i >= 0; i-- {
+			id[i] = alphabet[ms&31]
+			ms >>= 5
+		}
+		for i := 10; i < 26; i++ {
+			id[i] = alphabet[rng.Intn(32)]
+		}
+		return string(id)
+	case idNanoID:
+		const alphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789_-"
+		id := make([]byte, 21)
+		for i := range id {
+			id[i] = alphabet[rng.Intn(len(alphabet))]
+		}
+		return string(id)
+	}
+	return ""
+}
+
+// idPatterns match the ids of each random strategy.
+var idPatterns = map[string]*regexp.Regexp{
+	idUUID:   regexp.MustCompile(`^(?i)[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`),
+	idULID:   regexp.MustCompile(`^(?i)[0-7][0-9a-hjkmnp-tv-z]{25}$`),
+	idNanoID: regexp.MustCompile(`^[A-Za-z0-9_-]{21}$`),
+}
+
+// idDescriptions name the ids of each random strategy in error messages.
+var idDescriptions = map[string]string{
+	idUUID:   "a UUID",
+	idULID:   "a ULID",
+	idNanoID: "a 21-character nanoid",
+}
+
+// reservedIDs are the string ids /{entity}/{id} could never reach, because
+// a collection route of the same name answers there instead.
+var reservedIDs = []string{sampleSegment, countSegment, aggregateSegment, searchSegment, bulkSegment}
+
+// checkRecordID reports whether raw is an id of the given strategy, and not
+// one of the reservedIDs.
+func checkRecordID(strategy, raw string) error {
+	if pattern, ok := idPatterns[strategy]; ok && !pattern.MatchString(raw) {
+		return errors.New("invalid id: expected " + idDescriptions[strategy])
+	}
+	if containsString(reservedIDs, raw) {
+		return fmt.Errorf("invalid id: %q is reserved for the /{entity}/%s route", raw, raw)
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package server
+
+import (
+	"encoding/json"
+	"math/rand"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewRecordID(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, strategy := range []string{idUUID, idULID, idNanoID} {
+		id := newRecordID(strategy, rng, formatEpoch)
+		if err := checkRecordID(strategy, id); err != nil {
+			t.Errorf("newRecordID(%s) = %q: %v", strategy, id, err)
+		}
+	}
+	// ULIDs sort by time.
+	if a, b := newRecordID(idULID, rng, formatEpoch), newRecordID(idULID, rng, formatEpoch.Add(time.Millisecond)); a[:10] >= b[:10] {
+		t.Errorf("ULID timestamps out of order: %q, %q", a, b)
+	}
+	if err := checkRecordID(idUUID, "12"); err == nil {
+		t.Errorf("checkRecordID accepted 12 as a UUID")
+	}
+}
+
+func TestIDStrategy(t *testing.T) {
+	srv := NewServer()
+	if _, err := srv.loadSchema(strings.NewReader(`{"title": "Product", "type": "object", "x-id-property": "sku", "x-id-strategy": "uuid", "properties": {"sku": {"type": "string"}, "name": {"type": "string"}}}`)); err != nil {
+		t.Fatalf("could not load schema: %v", err)
+	}
+
+	rr := performRequest(t, srv.catchAllHandler, http.MethodGet, "/products", nil)
+	var list []map[string]interface{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &list); err != nil || len(list) == 0 {
+		t.Fatalf("could not decode response: %v", err)
+	}
+	if sku, _ := list[0]["sku"].(string); checkRecordID(idUUID, sku) != nil || list[0]["id"] != nil {
+		t.Errorf("generated record has no UUID sku: got %v", list[0])
+	}
+
+	rr = performRequest(t, srv.catchAllHandler, http.MethodPost, "/products", []byte(`{"name": "lamp"}`))
+	var created map[string]interface{}
+	json.Unmarshal(rr.Body.Bytes(), &created)
+	sku, _ := created["sku"].(string)
+	if rr.Code != http.StatusCreated || checkRecordID(idUUID, sku) != nil || rr.Header().Get("Location") != "/products/"+sku {
+		t.Fatalf("handler did not assign a UUID: got %v %v", rr.Header(), rr.Body.String())
+	}
+	if rr := performRequest(t, srv.catchAllHandler, http.MethodGet, "/products/"+sku, nil); !strings.Contains(rr.Body.String(), `"name":"lamp"`) {
+		t.Errorf("handler did not find the record by sku: got %v", rr.Body.String())
+	}
+	if rr := performRequest(t, srv.catchAllHandler, http.MethodGet, "/products/1", nil); rr.Code != http.StatusBadRequest {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusBadRequest)
+	}
+	if rr := performRequest(t, srv.catchAllHandler, http.MethodPost, "/products", []byte(`{"sku": "abc"}`)); rr.Code != http.StatusBadRequest {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusBadRequest)
+	}
+
+	if _, err := srv.loadSchema(strings.NewReader(`{"title": "Bad", "type": "object", "x-id-strategy": "ulid", "properties": {"id": {"type": "integer"}}}`)); err == nil {
+		t.Errorf("loadSchema accepted a ULID strategy for an integer id")
+	}
+	if _, err := srv.loadSchema(strings.NewReader(`{"title": "Bad", "type": "object", "x-id-strategy": "snowflake"}`)); err == nil {
+		t.Errorf("loadSchema accepted an unknown strategy")
+	}
+}
+
+func TestReservedIDs(t *testing.T) {
+	srv := NewServer()
+	if _, err := srv.loadSchema(strings.NewReader(`{"title": "Tag", "type": "object", "properties": {"id": {"type": "string"}, "label": {"type": "string"}}}`)); err != nil {
+		t.Fatalf("could not load schema: %v", err)
+	}
+
+	for _, id := range reservedIDs {
+		if rr := performRequest(t, srv.catchAllHandler, http.MethodPost, "/tags", []byte(`{"id": "`+id+`"}`)); rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "reserved") {
+			t.Errorf("POST with id %q: got %v %v", id, rr.Code, rr.Body.String())
+		}
+		if rr := performRequest(t, srv.catchAllHandler, http.MethodPut, "/tags/"+id, []byte(`{"label": "x"}`)); rr.Code < 400 {
+			t.Errorf("PUT to id %q stored a record: got %v %v", id, rr.Code, rr.Body.String())
+		}
+	}
+	if rr := performRequest(t, srv.catchAllHandler, http.MethodPost, "/tags", []byte(`{"id": "counted"}`)); rr.Code != http.StatusCreated {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusCreated)
+	}
+	if rr := performRequest(t, srv.catchAllHandler, http.MethodGet, "/tags/count", nil); !strings.Contains(rr.Body.String(), `"count":1`) {
+		t.Errorf("GET /tags/count did not count: got %v", rr.Body.String())
+	}
+}
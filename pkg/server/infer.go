@@ -0,0 +1,214 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// inferredFormats are the string formats inference recognizes, in the
+// order they are preferred when a value has several.
+var inferredFormats = []string{"uuid", "date-time", "date", "email", "ipv4", "uri"}
+
+// inference accumulates the values seen at one place of the sample
+// documents, from which inferSchema describes it.
+type inference struct {
+	types map[string]bool
+	null  bool
+	// objects counts the objects seen, and present how many of them had
+	// each property, so properties missing from some are not required.
+	objects    int
+	present    map[string]int
+	properties map[string]*inference
+	items      *inference
+	// formats are the inferredFormats every string seen so far has.
+	formats []string
+}
+
+// newInference returns an inference that has seen no values.
+func newInference() *inference {
+	return &inference{types: make(map[string]bool), present: make(map[string]int), properties: make(map[string]*inference)}
+}
+
+// add takes a value decoded with json.Decoder.UseNumber into account.
+func (in *inference) add(value interface{}) {
+	switch v := value.(type) {
+	case nil:
+		in.null = true
+	case bool:
+		in.types["boolean"] = true
+	case json.Number:
+		if strings.ContainsAny(v.String(), ".eE") {
+			in.types["number"] = true
+		} else {
+			in.types["integer"] = true
+		}
+	case string:
+		if !in.types["string"] {
+			in.formats = inferredFormats
+		}
+		in.types["string"] = true
+		var kept []string
+		for _, format := range in.formats {
+			if stringHasFormat(format, v) {
+				kept = append(kept, format)
+			}
+		}
+		in.formats = kept
+	case []interface{}:
+		in.types["array"] = true
+		if in.items == nil {
+			in.items = newInference()
+		}
+		for _, item := range v {
+			in.items.add(item)
+		}
+	case map[string]interface{}:
+		in.types["object"] = true
+		in.objects++
+		for key, item := range v {
+			if in.properties[key] == nil {
+				in.properties[key] = newInference()
+			}
+			in.present[key]++
+			in.properties[key].add(item)
+		}
+	}
+}
+
+// stringHasFormat reports whether s is written in format. Unlike
+// checkStringFormat, which validates values declared to have a format,
+// it only takes web addresses for URIs.
+func stringHasFormat(format, s string) bool {
+	if format == "uri" {
+		return (strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://")) && checkStringFormat(format, s) == nil
+	}
+	return checkStringFormat(format, s) == nil
+}
+
+// schema describes the values seen as a JSON Schema. Integers seen with
+// other numbers make numbers, and values of several types a type list.
+// Nothing but nulls, or an array that was always empty, is taken for
+// strings, as the sample cannot tell.
+func (in *inference) schema() map[string]interface{} {
+	if in.types["number"] {
+		delete(in.types, "integer")
+	}
+	types := make([]string, 0, len(in.types))
+	for t := range in.types {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	if len(types) == 0 {
+		types = []string{"string"}
+	}
+	schema := make(map[string]interface{})
+	switch {
+	case in.null:
+		schema["type"] = append(types, "null")
+	case len(types) == 1:
+		schema["type"] = types[0]
+	default:
+		schema["type"] = types
+	}
+	if in.types["object"] {
+		properties := make(map[string]interface{})
+		var required []string
+		for key, property := range in.properties {
+			properties[key] = property.schema()
+			if in.present[key] == in.objects && key != "id" {
+				required = append(required, key)
+			}
+		}
+		sort.Strings(required)
+		schema["properties"] = properties
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+	}
+	if in.types["array"] {
+		items := map[string]interface{}{"type": "string"}
+		if in.items != nil && (len(in.items.types) > 0 || in.items.null) {
+			items = in.items.schema()
+		}
+		schema["items"] = items
+	}
+	if in.types["string"] && len(types) == 1 && len(in.formats) > 0 {
+		schema["format"] = in.formats[0]
+	}
+	return schema
+}
+
+// inferSchema builds a schema titled title from a sample record, or an
+// array of them, decoded with json.Decoder.UseNumber. A property is
+// required when every record has it, and nullable when any has it null.
+func inferSchema(sample interface{}, title string) (map[string]interface{}, error) {
+	records, isList := sample.([]interface{})
+	if !isList {
+		records = []interface{}{sample}
+	}
+	if len(records) == 0 {
+		return nil, errors.New("expected a JSON object or a non-empty array of objects")
+	}
+	in := newInference()
+	for _, record := range records {
+		if _, ok := record.(map[string]interface{}); !ok {
+			return nil, errors.New("expected a JSON object or a non-empty array of objects")
+		}
+		in.add(record)
+	}
+	schema := in.schema()
+	schema["title"] = title
+	schema["type"] = "object"
+	return schema, nil
+}
+
+// exampleHandler infers a schema from a sample record, or an array of
+// them, POSTed to /upload/example and uploads it as if it had been POSTed
+// to /upload, answering with the inferred schema so it can be refined and
+// uploaded again. The title query parameter names the entity, Item unless
+// it is given.
+func (s *Server) exampleHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w, "Only POST allowed", http.MethodPost)
+		return
+	}
+	defer r.Body.Close()
+	title := strings.TrimSpace(r.URL.Query().Get("title"))
+	if title == "" {
+		title = "Item"
+	}
+	var sample interface{}
+	decoder := json.NewDecoder(r.Body)
+	decoder.UseNumber()
+	err := decoder.Decode(&sample)
+	if err == nil && decoder.More() {
+		err = errors.New("unexpected data after the document")
+	} else if err == io.EOF {
+		err = errors.New("empty body")
+	}
+	var inferred map[string]interface{}
+	if err == nil {
+		inferred, err = inferSchema(sample, title)
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid example: "+err.Error())
+		return
+	}
+	data, _ := json.Marshal(inferred)
+	schema, err := s.loadSchema(bytes.NewReader(data))
+	if err != nil {
+		writeSchemaError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Schema inferred and uploaded successfully",
+		"title":   schema.Title,
+		"schema":  inferred,
+	})
+}
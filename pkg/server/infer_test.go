@@ -0,0 +1,71 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestInferSchema(t *testing.T) {
+	srv := NewServer()
+
+	sample := `[
+		{"id": 1, "name": "Ada", "email": "ada@example.com", "score": 3, "joined": "2024-01-02T10:00:00Z",
+		 "address": {"city": "London", "zip": null}, "tags": ["admin"], "notes": [], "nickname": null},
+		{"id": 2, "name": "Grace", "email": "grace@example.com", "score": 4.5, "joined": "2024-02-03T11:00:00Z",
+		 "address": {"city": "Arlington", "zip": "22201"}, "tags": [], "notes": [], "website": "https://grace.example.com"}
+	]`
+	rr := performRequest(t, srv.exampleHandler, http.MethodPost, "/upload/example?title=Member", []byte(sample))
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v (%v)", status, http.StatusOK, rr.Body.String())
+	}
+	var uploaded struct {
+		Title  string                 `json:"title"`
+		Schema map[string]interface{} `json:"schema"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &uploaded); err != nil {
+		t.Fatalf("could not decode response: %v", err)
+	}
+	if uploaded.Title != "Member" {
+		t.Errorf("handler returned wrong title: got %v want Member", uploaded.Title)
+	}
+	properties := uploaded.Schema["properties"].(map[string]interface{})
+	want := map[string]string{
+		"id":       `{"type":"integer"}`,
+		"name":     `{"type":"string"}`,
+		"email":    `{"format":"email","type":"string"}`,
+		"score":    `{"type":"number"}`,
+		"joined":   `{"format":"date-time","type":"string"}`,
+		"address":  `{"properties":{"city":{"type":"string"},"zip":{"type":["string","null"]}},"required":["city","zip"],"type":"object"}`,
+		"tags":     `{"items":{"type":"string"},"type":"array"}`,
+		"notes":    `{"items":{"type":"string"},"type":"array"}`,
+		"nickname": `{"type":["string","null"]}`,
+		"website":  `{"format":"uri","type":"string"}`,
+	}
+	for name, schema := range want {
+		got, _ := json.Marshal(properties[name])
+		if string(got) != schema {
+			t.Errorf("property %s: got %s want %s", name, got, schema)
+		}
+	}
+	wantRequired := []interface{}{"address", "email", "joined", "name", "notes", "score", "tags"}
+	if !reflect.DeepEqual(uploaded.Schema["required"], wantRequired) {
+		t.Errorf("wrong required properties: got %v want %v", uploaded.Schema["required"], wantRequired)
+	}
+
+	// The inferred entity is served like an uploaded one.
+	rr = performRequest(t, srv.catchAllHandler, http.MethodPost, "/members", []byte(`{"name": "Edsger", "email": "edsger@example.com", "score": 5,
+		"joined": "2024-03-04T12:00:00Z", "address": {"city": "Austin", "zip": null}, "tags": [], "notes": []}`))
+	if status := rr.Code; status != http.StatusCreated {
+		t.Errorf("handler returned wrong status code: got %v want %v (%v)", status, http.StatusCreated, rr.Body.String())
+	}
+
+	for _, body := range []string{`"text"`, `[]`, `[1, 2]`, `{"a": 1} {"b": 2}`, ``} {
+		rr := performRequest(t, srv.exampleHandler, http.MethodPost, "/upload/example", []byte(body))
+		if status := rr.Code; status != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "Invalid example") {
+			t.Errorf("%q: handler returned %v %s, want %v", body, status, rr.Body.String(), http.StatusBadRequest)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package server
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// irregularPlurals maps lower-cased singular words to plurals the suffix
+// rules of pluralWord would get wrong.
+var irregularPlurals = map[string]string{
+	"person": "people", "man": "men", "woman": "women", "child": "children",
+	"tooth": "teeth", "foot": "feet", "mouse": "mice", "goose": "geese", "ox": "oxen",
+	"datum": "data", "medium": "media", "criterion": "criteria", "phenomenon": "phenomena",
+	"cactus": "cacti", "fungus": "fungi", "nucleus": "nuclei", "alumnus": "alumni",
+	"index": "indices", "appendix": "appendices", "matrix": "matrices", "vertex": "vertices",
+	"quiz": "quizzes", "knife": "knives", "life": "lives", "wife": "wives",
+	"leaf": "leaves", "loaf": "loaves", "half": "halves", "wolf": "wolves",
+	"shelf": "shelves", "calf": "calves", "thief": "thieves", "elf": "elves",
+	"hero": "heroes", "potato": "potatoes", "tomato": "tomatoes", "echo": "echoes", "veto": "vetoes",
+}
+
+// uncountableWords are lower-cased words whose plural is the word itself.
+var uncountableWords = map[string]bool{
+	"equipment": true, "information": true, "rice": true, "money": true,
+	"species": true, "series": true, "fish": true, "sheep": true, "deer": true,
+	"news": true, "data": true, "metadata": true, "feedback": true,
+	"software": true, "hardware": true, "police": true, "staff": true,
+}
+
+// pluralize returns name with its last word made plural, so "Person"
+// becomes "People" and "OrderCategory" "OrderCategories". Words are
+// delimited by case changes, spaces, hyphens and underscores; a
+// capitalized last word stays capitalized.
+func pluralize(name string) string {
+	start := lastWordStart(name)
+	prefix, word := name[:start], name[start:]
+	if word == "" {
+		return name
+	}
+	plural := pluralWord(strings.ToLower(word))
+	switch first, _ := utf8.DecodeRuneInString(word); {
+	case len(word) > 1 && word == strings.ToUpper(word):
+		plural = strings.ToUpper(plural)
+	case unicode.IsUpper(first):
+		plural = strings.ToUpper(plural[:1]) + plural[1:]
+	}
+	return prefix + plural
+}
+
+// lastWordStart returns the byte offset at which the last word of name
+// begins.
+func lastWordStart(name string) int {
+	runes := []rune(name)
+	start := len(runes)
+	for i := len(runes) - 1; i >= 0; i-- {
+		r := runes[i]
+		if r == ' ' || r == '-' || r == '_' {
+			break
+		}
+		start = i
+		if i > 0 && unicode.IsUpper(r) {
+			prev := runes[i-1]
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) ||
+				(unicode.IsUpper(prev) && i+1 < len(runes) && unicode.IsLower(runes[i+1])) {
+				break
+			}
+		}
+	}
+	return len(string(runes[:start]))
+}
+
+// pluralWord returns the plural of a single lower-cased English word.
+func pluralWord(word string) string {
+	if uncountableWords[word] {
+		return word
+	}
+	if plural, ok := irregularPlurals[word]; ok {
+		return plural
+	}
+	switch {
+	case strings.HasSuffix(word, "sis"):
+		return strings.TrimSuffix(word, "is") + "es" // analysis, basis
+	case strings.HasSuffix(word, "s"), strings.HasSuffix(word, "x"), strings.HasSuffix(word, "z"),
+		strings.HasSuffix(word, "ch"), strings.HasSuffix(word, "sh"):
+		return word + "es" // status, box, batch, wish
+	case len(word) > 1 && strings.HasSuffix(word, "y") && !strings.ContainsRune("aeiou", rune(word[len(word)-2])):
+		return strings.TrimSuffix(word, "y") + "ies" // category, but not key
+	}
+	return word + "s"
+}
+
+// singularize returns name with its last word made singular, undoing
+// pluralize, so "people" becomes "person" and "order_items" "order_item".
+// Names pluralize would not have produced are returned as they are.
+func singularize(name string) string {
+	start := lastWordStart(name)
+	prefix, word := name[:start], name[start:]
+	lower := strings.ToLower(word)
+	singular := singularWord(lower)
+	if singular == lower {
+		return name
+	}
+	switch first, _ := utf8.DecodeRuneInString(word); {
+	case len(word) > 1 && word == strings.ToUpper(word):
+		singular = strings.ToUpper(singular)
+	case unicode.IsUpper(first):
+		singular = strings.ToUpper(singular[:1]) + singular[1:]
+	}
+	return prefix + singular
+}
+
+// singularWord returns the singular of a single lower-cased English word,
+// the first candidate that pluralWord turns back into it, or the word
+// itself when there is none.
+func singularWord(word string) string {
+	if uncountableWords[word] {
+		return word
+	}
+	for singular, plural := range irregularPlurals {
+		if plural == word {
+			return singular
+		}
+	}
+	var candidates []string
+	switch {
+	case strings.HasSuffix(word, "yses"):
+		candidates = append(candidates, strings.TrimSuffix(word, "es")+"is") // analyses
+	case strings.HasSuffix(word, "ies"):
+		candidates = append(candidates, strings.TrimSuffix(word, "ies")+"y") // categories
+	}
+	if strings.HasSuffix(word, "es") {
+		candidates = append(candidates, strings.TrimSuffix(word, "es")) // statuses, boxes
+	}
+	if strings.HasSuffix(word, "s") {
+		candidates = append(candidates, strings.TrimSuffix(word, "s"))
+	}
+	for _, candidate := range candidates {
+		if candidate != "" && pluralWord(candidate) == word {
+			return candidate
+		}
+	}
+	return word
+}
@@ -0,0 +1,91 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestPluralize(t *testing.T) {
+	cases := map[string]string{
+		"User":          "Users",
+		"Person":        "People",
+		"SalesPerson":   "SalesPeople",
+		"Human":         "Humans",
+		"Category":      "Categories",
+		"Key":           "Keys",
+		"Status":        "Statuses",
+		"Child":         "Children",
+		"Box":           "Boxes",
+		"Analysis":      "Analyses",
+		"Equipment":     "Equipment",
+		"HTTPStatus":    "HTTPStatuses",
+		"order_item":    "order_items",
+		"SMS":           "SMSES",
+		"orderCategory": "orderCategories",
+	}
+	for singular, want := range cases {
+		if got := pluralize(singular); got != want {
+			t.Errorf("pluralize(%q) = %q, want %q", singular, got, want)
+		}
+	}
+}
+
+func TestSingularize(t *testing.T) {
+	cases := map[string]string{
+		"users":       "user",
+		"People":      "Person",
+		"categories":  "category",
+		"keys":        "key",
+		"statuses":    "status",
+		"boxes":       "box",
+		"analyses":    "analysis",
+		"equipment":   "equipment",
+		"order_items": "order_item",
+		"ORDERS":      "ORDER",
+		"address":     "address",
+		"staff":       "staff",
+	}
+	for plural, want := range cases {
+		if got := singularize(plural); got != want {
+			t.Errorf("singularize(%q) = %q, want %q", plural, got, want)
+		}
+	}
+}
+
+func TestResourceNameRoutes(t *testing.T) {
+	srv := NewServer()
+
+	for _, schema := range []string{
+		`{"title": "Person", "properties": {"name": {"type": "string"}}}`,
+		`{"title": "Category", "x-resource-name": "topics", "properties": {"label": {"type": "string"}}}`,
+	} {
+		if rr := performRequest(t, srv.uploadHandler, http.MethodPost, "/upload", []byte(schema)); rr.Code != http.StatusOK {
+			t.Fatalf("upload failed: %v", rr.Body.String())
+		}
+	}
+	for path, status := range map[string]int{
+		"/people/1":     http.StatusOK,
+		"/persons/1":    http.StatusNotFound,
+		"/topics/1":     http.StatusOK,
+		"/categories/1": http.StatusNotFound,
+	} {
+		if rr := performRequest(t, srv.catchAllHandler, http.MethodGet, path, nil); rr.Code != status {
+			t.Errorf("GET %s: handler returned wrong status code: got %v want %v", path, rr.Code, status)
+		}
+	}
+
+	rr := performRequest(t, srv.uploadHandler, http.MethodPost, "/upload", []byte(`{"title": "Bad", "x-resource-name": "a/b", "properties": {}}`))
+	if status := rr.Code; status != http.StatusBadRequest {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
+	}
+	for _, schema := range []string{
+		`{"title": "Job", "properties": {}}`,
+		`{"title": "Api", "x-resource-name": "graphql", "properties": {}}`,
+	} {
+		rr := performRequest(t, srv.uploadHandler, http.MethodPost, "/upload", []byte(schema))
+		if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "route") {
+			t.Errorf("reserved name %s: handler returned %v: %s", schema, rr.Code, rr.Body.String())
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Job states reported by GET /jobs/{id}.
+const (
+	jobPending   = "pending"
+	jobCompleted = "completed"
+	jobFailed    = "failed"
+)
+
+// jobRetention is how long a finished job's status resource is kept
+// before GET /jobs/{id} answers 404.
+const jobRetention = 10 * time.Minute
+
+// job is a simulated long-running create operation.
+type job struct {
+	id      int
+	readyAt time.Time
+	// collection is the routed path of the entity, e.g. /api/v1/users.
+	collection string
+	records    *recordStore
+	obj        map[string]interface{}
+	integerIDs bool
+
+	// Set once the job has run.
+	status     string
+	err        error
+	finishedAt time.Time
+}
+
+// jobQueue tracks the async create operations that have been accepted.
+type jobQueue struct {
+	mu     sync.Mutex
+	lastID int
+	jobs   map[int]*job
+	// retention is how long finished jobs are kept; see jobRetention.
+	retention time.Duration
+}
+
+// submit accepts a create operation that stores its record after delay.
+func (q *jobQueue) submit(collection string, records *recordStore, obj map[string]interface{}, integerIDs bool, delay time.Duration) *job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.expire()
+	q.lastID++
+	j := &job{
+		id:         q.lastID,
+		readyAt:    time.Now().Add(delay),
+		collection: collection,
+		records:    records,
+		obj:        obj,
+		integerIDs: integerIDs,
+		status:     jobPending,
+	}
+	q.jobs[j.id] = j
+	time.AfterFunc(delay, func() {
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		q.finish(j)
+	})
+	return j
+}
+
+// finish creates the job's record once it is ready, unless that already
+// happened. q.mu must be held.
+func (q *jobQueue) finish(j *job) {
+	if j.status != jobPending || time.Now().Before(j.readyAt) {
+		return
+	}
+	if j.err = j.records.create(j.obj, j.integerIDs); j.err != nil {
+		j.status = jobFailed
+	} else {
+		j.status = jobCompleted
+	}
+	j.finishedAt = time.Now()
+}
+
+// expire forgets the jobs that finished more than q.retention ago. q.mu
+// must be held.
+func (q *jobQueue) expire() {
+	for id, j := range q.jobs {
+		if !j.finishedAt.IsZero() && time.Since(j.finishedAt) > q.retention {
+			delete(q.jobs, id)
+		}
+	}
+}
+
+// state reports the job's current status. Jobs that have expired are
+// reported as unknown.
+func (q *jobQueue) state(id int) (map[string]interface{}, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.expire()
+	j, ok := q.jobs[id]
+	if !ok {
+		return nil, false
+	}
+	// The timer may not have run yet when the job is polled right as it
+	// becomes ready.
+	q.finish(j)
+
+	state := map[string]interface{}{
+		"id":     j.id,
+		"status": j.status,
+	}
+	switch j.status {
+	case jobCompleted:
+		state["resourceId"] = j.obj[j.records.idKey]
+		state["location"] = j.collection + "/" + fmt.Sprint(j.obj[j.records.idKey])
+	case jobFailed:
+		state["error"] = j.err.Error()
+	}
+	return state, true
+}
+
+// writeAccepted responds 202 with a Location pointing at the job's status
+// resource.
+func (s *Server) writeAccepted(w http.ResponseWriter, j *job) {
+	location := s.route("/jobs/" + strconv.Itoa(j.id))
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", location)
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":       j.id,
+		"status":   jobPending,
+		"location": location,
+	})
+}
+
+// jobsHandler serves GET /jobs/{id}, the status resource of an async create.
+func (s *Server) jobsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w, "Only GET allowed", http.MethodGet)
+		return
+	}
+	id, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/jobs/"))
+	if err != nil {
+		writeNotFound(w, r)
+		return
+	}
+	state, ok := s.jobs.state(id)
+	if !ok {
+		writeNotFound(w, r)
+		return
+	}
+	if state["status"] == jobPending {
+		w.Header().Set("Retry-After", "1")
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(state)
+}
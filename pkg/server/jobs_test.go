@@ -0,0 +1,87 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAsyncCreate(t *testing.T) {
+	srv := NewServer()
+	srv.currentSchema = createSampleSchema()
+	srv.store = newRecordStore()
+	srv.asyncCreateDelay = 30 * time.Millisecond
+
+	rr := performRequest(t, srv.catchAllHandler, http.MethodPost, "/users", []byte(`{"name":"later","email":"later@example.com"}`))
+	if status := rr.Code; status != http.StatusAccepted {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusAccepted)
+	}
+	location := rr.Header().Get("Location")
+	if location == "" {
+		t.Fatalf("handler did not set Location")
+	}
+	if srv.store.len() != 0 {
+		t.Errorf("record was created before the job completed")
+	}
+
+	var state map[string]interface{}
+	rr = performRequest(t, srv.jobsHandler, http.MethodGet, location, nil)
+	json.Unmarshal(rr.Body.Bytes(), &state)
+	if state["status"] != jobPending || rr.Header().Get("Retry-After") == "" {
+		t.Errorf("job was not pending: got %v", rr.Body.String())
+	}
+
+	time.Sleep(40 * time.Millisecond)
+	if srv.store.len() != 1 {
+		t.Errorf("record was not created when the job completed")
+	}
+	rr = performRequest(t, srv.jobsHandler, http.MethodGet, location, nil)
+	state = nil
+	json.Unmarshal(rr.Body.Bytes(), &state)
+	if state["status"] != jobCompleted || state["resourceId"] != 1.0 || state["location"] != "/users/1" {
+		t.Errorf("job did not complete: got %v", rr.Body.String())
+	}
+
+	rr = performRequest(t, srv.catchAllHandler, http.MethodGet, "/users/1", nil)
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("created record is not retrievable: got %v", status)
+	}
+
+	rr = performRequest(t, srv.jobsHandler, http.MethodGet, "/jobs/999", nil)
+	if status := rr.Code; status != http.StatusNotFound {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusNotFound)
+	}
+}
+
+func TestAsyncCreateBasePath(t *testing.T) {
+	srv := NewServer(WithBasePath("/api/v1"), WithAsyncCreate(10*time.Millisecond))
+	srv.currentSchema = createSampleSchema()
+	srv.store = newRecordStore()
+	srv.jobs.retention = 20 * time.Millisecond
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		rr := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rr, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rr
+	}
+
+	rr := serve(http.MethodPost, "/api/v1/users", `{"name":"later","email":"later@example.com"}`)
+	location := rr.Header().Get("Location")
+	if rr.Code != http.StatusAccepted || location != "/api/v1/jobs/1" {
+		t.Fatalf("handler returned unexpected response: got %v %v", rr.Code, location)
+	}
+	time.Sleep(15 * time.Millisecond)
+	var state map[string]interface{}
+	json.Unmarshal(serve(http.MethodGet, location, "").Body.Bytes(), &state)
+	if state["status"] != jobCompleted || state["location"] != "/api/v1/users/1" {
+		t.Errorf("job location ignores the base path: got %v", state)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if rr := serve(http.MethodGet, location, ""); rr.Code != http.StatusNotFound {
+		t.Errorf("finished job did not expire: got %v", rr.Code)
+	}
+}
@@ -0,0 +1,308 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxJournalEntries bounds the request journal; the oldest requests are
+// dropped once it is full.
+const maxJournalEntries = 10000
+
+// journalEntry is one request received by the server, as listed at
+// GET /__admin/requests.
+type journalEntry struct {
+	ID      int               `json:"id"`
+	Time    time.Time         `json:"time"`
+	Method  string            `json:"method"`
+	Path    string            `json:"path"`
+	Query   map[string]string `json:"query,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    interface{}       `json:"body,omitempty"`
+	Status  int               `json:"status"`
+	// raw is the request body as received, for bodyPattern.
+	raw []byte
+}
+
+// requestJournal keeps every request the server received, except those to
+// the admin API, so tests can verify how the mock was called. It is safe
+// for concurrent use.
+type requestJournal struct {
+	mu      sync.Mutex
+	entries []journalEntry
+	lastID  int
+}
+
+// reset forgets every recorded request.
+func (j *requestJournal) reset() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.entries = nil
+	j.lastID = 0
+}
+
+// add records entry, assigning its id.
+func (j *requestJournal) add(entry journalEntry) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.lastID++
+	entry.ID = j.lastID
+	if len(j.entries) == maxJournalEntries {
+		j.entries = j.entries[1:]
+	}
+	j.entries = append(j.entries, entry)
+}
+
+// find returns the recorded requests matching p, oldest first.
+func (j *requestJournal) find(p *requestPattern) []journalEntry {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	found := []journalEntry{}
+	for _, entry := range j.entries {
+		if p.matches(entry) {
+			found = append(found, entry)
+		}
+	}
+	return found
+}
+
+// withJournal records each request handled by next, apart from those to
+// the admin API.
+func (s *Server) withJournal(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.isAdminPath(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		var body []byte
+		if r.Body != nil {
+			body, _ = io.ReadAll(r.Body)
+			r.Body.Close()
+			r.Body = io.NopCloser(bytes.NewReader(body))
+		}
+		entry := journalEntry{
+			Time:    time.Now().UTC(),
+			Method:  r.Method,
+			Path:    r.URL.Path,
+			Query:   flatten(r.URL.Query()),
+			Headers: flatten(r.Header),
+			Body:    recordedBody(body),
+			raw:     body,
+		}
+
+		capture := &captureWriter{ResponseWriter: w, status: http.StatusOK, statusOnly: true}
+		next.ServeHTTP(capture, r)
+		entry.Status = capture.status
+		s.journal.add(entry)
+	})
+}
+
+// flatten joins the values of each key with ", ", as repeated headers are
+// combined.
+func flatten(values map[string][]string) map[string]string {
+	if len(values) == 0 {
+		return nil
+	}
+	flat := make(map[string]string, len(values))
+	for key, list := range values {
+		flat[key] = strings.Join(list, ", ")
+	}
+	return flat
+}
+
+// requestPattern selects journal entries. Every field that is set must
+// match: Method (case-insensitive, or "ANY"), Path exactly or PathPattern
+// as a regular expression matching the whole path, each Query parameter
+// and Header exactly, Body as a JSON document the request body contains
+// (objects may have extra fields) and BodyPattern as a regular expression
+// found in the raw body.
+//
+// Count, AtLeast and AtMost state how many requests POST
+// /__admin/requests/verify expects to match.
+type requestPattern struct {
+	Method      string            `json:"method"`
+	Path        string            `json:"path"`
+	PathPattern string            `json:"pathPattern"`
+	Query       map[string]string `json:"query"`
+	Headers     map[string]string `json:"headers"`
+	Body        interface{}       `json:"body"`
+	BodyPattern string            `json:"bodyPattern"`
+	Count       *int              `json:"count"`
+	AtLeast     *int              `json:"atLeast"`
+	AtMost      *int              `json:"atMost"`
+
+	pathRE *regexp.Regexp
+	bodyRE *regexp.Regexp
+}
+
+// decodeRequestPattern reads a pattern from a request body and compiles its
+// regular expressions. An empty body matches every request.
+func decodeRequestPattern(r io.Reader) (*requestPattern, error) {
+	p := &requestPattern{}
+	if err := json.NewDecoder(r).Decode(p); err != nil && err != io.EOF {
+		return nil, err
+	}
+	var err error
+	if p.PathPattern != "" {
+		if p.pathRE, err = regexp.Compile("^(?:" + p.PathPattern + ")$"); err != nil {
+			return nil, fmt.Errorf("pathPattern: %v", err)
+		}
+	}
+	if p.BodyPattern != "" {
+		if p.bodyRE, err = regexp.Compile(p.BodyPattern); err != nil {
+			return nil, fmt.Errorf("bodyPattern: %v", err)
+		}
+	}
+	return p, nil
+}
+
+// matches reports whether entry satisfies every field of the pattern.
+func (p *requestPattern) matches(entry journalEntry) bool {
+	if p.Method != "" && !strings.EqualFold(p.Method, "ANY") && !strings.EqualFold(p.Method, entry.Method) {
+		return false
+	}
+	if p.Path != "" && p.Path != entry.Path {
+		return false
+	}
+	if p.pathRE != nil && !p.pathRE.MatchString(entry.Path) {
+		return false
+	}
+	for key, want := range p.Query {
+		if got, ok := entry.Query[key]; !ok || got != want {
+			return false
+		}
+	}
+	for key, want := range p.Headers {
+		if got, ok := entry.Headers[http.CanonicalHeaderKey(key)]; !ok || got != want {
+			return false
+		}
+	}
+	if p.Body != nil {
+		var body interface{}
+		if err := json.Unmarshal(entry.raw, &body); err != nil || !jsonContains(body, p.Body) {
+			return false
+		}
+	}
+	return p.bodyRE == nil || p.bodyRE.Match(entry.raw)
+}
+
+// expectation describes the count a verification expects, or "" when the
+// pattern states none and any number of matches above zero will do.
+func (p *requestPattern) expectation() string {
+	var parts []string
+	if p.Count != nil {
+		parts = append(parts, fmt.Sprintf("exactly %d", *p.Count))
+	}
+	if p.AtLeast != nil {
+		parts = append(parts, fmt.Sprintf("at least %d", *p.AtLeast))
+	}
+	if p.AtMost != nil {
+		parts = append(parts, fmt.Sprintf("at most %d", *p.AtMost))
+	}
+	return strings.Join(parts, " and ")
+}
+
+// satisfied reports whether n matching requests meet the expected count.
+func (p *requestPattern) satisfied(n int) bool {
+	if p.Count == nil && p.AtLeast == nil && p.AtMost == nil {
+		return n > 0
+	}
+	return (p.Count == nil || n == *p.Count) &&
+		(p.AtLeast == nil || n >= *p.AtLeast) &&
+		(p.AtMost == nil || n <= *p.AtMost)
+}
+
+// jsonContains reports whether the decoded JSON value got contains want:
+// objects must have every field of want, with matching values, and may
+// have others; arrays and scalars must be equal.
+func jsonContains(got, want interface{}) bool {
+	switch want := want.(type) {
+	case map[string]interface{}:
+		obj, ok := got.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		for key, value := range want {
+			field, ok := obj[key]
+			if !ok || !jsonContains(field, value) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		list, ok := got.([]interface{})
+		if !ok || len(list) != len(want) {
+			return false
+		}
+		for i := range want {
+			if !jsonContains(list[i], want[i]) {
+				return false
+			}
+		}
+		return true
+	default:
+		return got == want
+	}
+}
+
+// adminRequests serves the request journal:
+//
+//	GET    /__admin/requests         every recorded request, oldest first
+//	DELETE /__admin/requests         clear the journal
+//	POST   /__admin/requests/find    the requests matching a pattern
+//	POST   /__admin/requests/count   how many requests match a pattern
+//	POST   /__admin/requests/verify  200 if the matching requests meet the
+//	                                 pattern's count, 417 otherwise
+func (s *Server) adminRequests(w http.ResponseWriter, r *http.Request, action string) {
+	if action == "" {
+		switch r.Method {
+		case http.MethodGet:
+			writeAdminJSON(w, s.journal.find(&requestPattern{}))
+		case http.MethodDelete:
+			s.journal.reset()
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			writeMethodNotAllowed(w, "Only GET and DELETE allowed", http.MethodGet, http.MethodDelete)
+		}
+		return
+	}
+	if action != "find" && action != "count" && action != "verify" {
+		writeNotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w, "Only POST allowed", http.MethodPost)
+		return
+	}
+	pattern, err := decodeRequestPattern(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request pattern: "+err.Error())
+		return
+	}
+	found := s.journal.find(pattern)
+	switch action {
+	case "find":
+		writeAdminJSON(w, map[string]interface{}{"requests": found})
+	case "count":
+		writeAdminJSON(w, map[string]int{"count": len(found)})
+	case "verify":
+		if pattern.satisfied(len(found)) {
+			writeAdminJSON(w, map[string]interface{}{"verified": true, "count": len(found)})
+			return
+		}
+		expected := pattern.expectation()
+		if expected == "" {
+			expected = "at least 1"
+		}
+		writeErrorDetails(w, http.StatusExpectationFailed, "verification_failed",
+			fmt.Sprintf("Expected %s matching requests, got %d", expected, len(found)),
+			map[string]interface{}{"count": len(found), "requests": found})
+	}
+}
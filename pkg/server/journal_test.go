@@ -0,0 +1,93 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequestJournal(t *testing.T) {
+	srv := NewServer()
+	handler := srv.Handler()
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("X-Tenant", "acme")
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+	serve(http.MethodPost, "/upload", `{"title": "User", "properties": {"id": {"type": "integer"}, "name": {"type": "string"}, "age": {"type": "integer"}}}`)
+	serve(http.MethodPost, "/users", `{"name": "alice", "age": 30}`)
+	serve(http.MethodPost, "/users", `{"name": "alice", "age": 31}`)
+	serve(http.MethodPost, "/users", `{"name": "bob"}`)
+	serve(http.MethodGet, "/users/2?fields=name", "")
+
+	rr := serve(http.MethodGet, "/__admin/requests", "")
+	var entries []journalEntry
+	if err := json.Unmarshal(rr.Body.Bytes(), &entries); err != nil {
+		t.Fatalf("could not decode response: %v", err)
+	}
+	if len(entries) != 5 || entries[1].Status != http.StatusCreated || entries[4].Query["fields"] != "name" || entries[4].Headers["X-Tenant"] != "acme" {
+		t.Errorf("handler returned unexpected journal: got %v", rr.Body.String())
+	}
+
+	cases := []struct {
+		name    string
+		pattern string
+		status  int
+		count   int
+	}{
+		{"Body Subset", `{"method": "post", "path": "/users", "body": {"name": "alice"}, "count": 2}`, http.StatusOK, 2},
+		{"Path Pattern", `{"method": "GET", "pathPattern": "/users/[0-9]+", "query": {"fields": "name"}}`, http.StatusOK, 1},
+		{"Body Pattern", `{"bodyPattern": "\"age\": 3[01]", "atLeast": 1, "atMost": 2}`, http.StatusOK, 2},
+		{"Header", `{"method": "ANY", "headers": {"x-tenant": "acme"}}`, http.StatusOK, 5},
+		{"Wrong Count", `{"method": "POST", "path": "/users", "count": 2}`, http.StatusExpectationFailed, 3},
+		{"No Match", `{"path": "/users/9"}`, http.StatusExpectationFailed, 0},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rr := serve(http.MethodPost, "/__admin/requests/verify", tc.pattern)
+			if rr.Code != tc.status {
+				t.Errorf("handler returned wrong status code: got %v want %v (%v)", rr.Code, tc.status, rr.Body.String())
+			}
+			rr = serve(http.MethodPost, "/__admin/requests/count", tc.pattern)
+			if got := strings.TrimSpace(rr.Body.String()); got != fmt.Sprintf(`{"count":%d}`, tc.count) {
+				t.Errorf("handler returned unexpected count: got %v want %v", got, tc.count)
+			}
+		})
+	}
+
+	if rr := serve(http.MethodPost, "/__admin/requests/find", `{"pathPattern": "("}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusBadRequest)
+	}
+	serve(http.MethodDelete, "/__admin/requests", "")
+	if found := srv.journal.find(&requestPattern{}); len(found) != 0 {
+		t.Errorf("journal was not cleared: got %v", found)
+	}
+}
+
+func TestJSONContains(t *testing.T) {
+	var got interface{}
+	json.Unmarshal([]byte(`{"name": "alice", "tags": ["a", "b"], "address": {"city": "Oslo", "zip": "0150"}}`), &got)
+	cases := []struct {
+		want  string
+		match bool
+	}{
+		{`{"address": {"city": "Oslo"}}`, true},
+		{`{"tags": ["a", "b"]}`, true},
+		{`{"tags": ["a"]}`, false},
+		{`{"name": "bob"}`, false},
+		{`{"missing": null}`, false},
+	}
+	for _, tc := range cases {
+		var want interface{}
+		json.Unmarshal([]byte(tc.want), &want)
+		if jsonContains(got, want) != tc.match {
+			t.Errorf("jsonContains(%s) = %v, want %v", tc.want, !tc.match, tc.match)
+		}
+	}
+}
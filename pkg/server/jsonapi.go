@@ -0,0 +1,342 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// jsonAPIContentType is the media type of JSON:API documents.
+const jsonAPIContentType = "application/vnd.api+json"
+
+// wantsJSONAPI reports whether an Accept header asks for JSON:API
+// documents.
+func wantsJSONAPI(accept string) bool {
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, _, _ := strings.Cut(part, ";")
+		if strings.EqualFold(strings.TrimSpace(mediaType), jsonAPIContentType) {
+			return true
+		}
+	}
+	return false
+}
+
+// withJSONAPI serves entity routes as JSON:API: request bodies sent as
+// application/vnd.api+json are unwrapped from their resource object, and
+// JSON responses are wrapped in a document with data (plus included for
+// ?expand= references, meta and links) or errors. It applies to every
+// request when always is set and otherwise to those accepting JSON:API.
+func (s *Server) withJSONAPI(always bool, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !always && !wantsJSONAPI(r.Header.Get("Accept")) || wantsNDJSON(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		segments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+		schema, _, onEntity := s.entityState(segments[0])
+		if !onEntity {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(segments) == 3 {
+			if rel, ok := s.findRelation(schema, segments[2]); ok {
+				schema = rel.child
+			}
+		}
+
+		jw := &heldWriter{ResponseWriter: w}
+		if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == jsonAPIContentType {
+			if err := unwrapJSONAPIBody(r, schema); err != nil {
+				status := http.StatusBadRequest
+				if err == errJSONAPIType {
+					status = http.StatusConflict
+				}
+				writeError(jw, status, "Invalid JSON:API document: "+err.Error())
+			}
+		}
+		if jw.status == 0 {
+			next.ServeHTTP(jw, r)
+		}
+		s.finishJSONAPI(jw, r, schema)
+	})
+}
+
+// errJSONAPIType is returned for a resource object of another entity's
+// type, which JSON:API answers with 409 Conflict.
+var errJSONAPIType = errors.New("resource type does not match the collection")
+
+// jsonAPIResourceObject is a resource object in a request document.
+type jsonAPIResourceObject struct {
+	Type          string                            `json:"type"`
+	ID            string                            `json:"id"`
+	Attributes    map[string]interface{}            `json:"attributes"`
+	Relationships map[string]jsonAPIRelationshipDoc `json:"relationships"`
+}
+
+// jsonAPIRelationshipDoc is the relationship object of a to-one
+// relationship.
+type jsonAPIRelationshipDoc struct {
+	Data *struct {
+		Type string `json:"type"`
+		ID   string `json:"id"`
+	} `json:"data"`
+}
+
+// unwrapJSONAPIBody replaces the JSON:API document in r's body with the
+// plain record it describes: its attributes, its id and the ids of its
+// relationships under their x-ref properties.
+func unwrapJSONAPIBody(r *http.Request, schema *Schema) error {
+	var doc struct {
+		Data *jsonAPIResourceObject `json:"data"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&doc); err != nil {
+		return err
+	}
+	if doc.Data == nil {
+		return errors.New("missing data")
+	}
+	if doc.Data.Type != entityName(schema) {
+		return errJSONAPIType
+	}
+	obj := make(map[string]interface{}, len(doc.Data.Attributes)+1)
+	for name, value := range doc.Data.Attributes {
+		obj[name] = value
+	}
+	if doc.Data.ID != "" {
+		id, err := jsonAPIID(schema.Properties[schema.idKey()], doc.Data.ID)
+		if err != nil {
+			return fmt.Errorf("id: %v", err)
+		}
+		obj[schema.idKey()] = id
+	}
+	for name, rel := range doc.Data.Relationships {
+		key := ""
+		for property, prop := range schema.Properties {
+			if prop.XRef != "" && (name == property || name == expandName(property)) {
+				key = property
+			}
+		}
+		if key == "" {
+			return fmt.Errorf("%s has no relationship named %q", schema.Title, name)
+		}
+		if rel.Data == nil {
+			obj[key] = nil
+			continue
+		}
+		id, err := jsonAPIID(schema.Properties[key], rel.Data.ID)
+		if err != nil {
+			return fmt.Errorf("relationships.%s: %v", name, err)
+		}
+		obj[key] = id
+	}
+
+	body, _ := json.Marshal(obj)
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.ContentLength = int64(len(body))
+	r.Header.Set("Content-Type", "application/json")
+	return nil
+}
+
+// jsonAPIID converts a JSON:API id, always a string, to the type of prop.
+func jsonAPIID(prop Property, raw string) (interface{}, error) {
+	if prop.Type == "integer" {
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return nil, fmt.Errorf("expected an integer, got %q", raw)
+		}
+		return n, nil
+	}
+	return raw, nil
+}
+
+// finishJSONAPI writes the response held in j, as a JSON:API document if
+// it was JSON.
+func (s *Server) finishJSONAPI(j *heldWriter, r *http.Request, schema *Schema) {
+	header := j.Header()
+	if j.status == 0 {
+		j.status = http.StatusOK
+	}
+	mediaType, _, _ := mime.ParseMediaType(header.Get("Content-Type"))
+	var decoded interface{}
+	if mediaType != "application/json" || json.Unmarshal(j.body.Bytes(), &decoded) != nil {
+		j.ResponseWriter.WriteHeader(j.status)
+		j.ResponseWriter.Write(j.body.Bytes())
+		return
+	}
+
+	var doc map[string]interface{}
+	if j.status >= 400 {
+		doc = map[string]interface{}{"errors": jsonAPIErrors(j.status, j.body.Bytes())}
+	} else {
+		doc = s.newJSONAPIDocument(schema, decoded)
+		links := map[string]interface{}{"self": s.route(r.URL.RequestURI())}
+		for rel, target := range parseLinks(header.Get("Link")) {
+			links[rel] = target
+		}
+		doc["links"] = links
+		if total, err := strconv.Atoi(header.Get("X-Total-Count")); err == nil {
+			meta, _ := doc["meta"].(map[string]interface{})
+			if meta == nil {
+				meta = make(map[string]interface{})
+			}
+			meta["total"] = total
+			doc["meta"] = meta
+		}
+	}
+	header.Del("Content-Length")
+	header.Set("Content-Type", jsonAPIContentType)
+	j.ResponseWriter.WriteHeader(j.status)
+	json.NewEncoder(j.ResponseWriter).Encode(doc)
+}
+
+// jsonAPIDocument collects the primary data of a response and the records
+// it includes.
+type jsonAPIDocument struct {
+	server   *Server
+	included []interface{}
+	seen     map[string]bool
+}
+
+// newJSONAPIDocument wraps a decoded response body, a record or a list of
+// them, as the primary data of a JSON:API document.
+func (s *Server) newJSONAPIDocument(schema *Schema, decoded interface{}) map[string]interface{} {
+	d := &jsonAPIDocument{server: s, seen: make(map[string]bool)}
+	// Debug lists carry their records under data already.
+	var meta interface{}
+	if wrapped, ok := decoded.(map[string]interface{}); ok && wrapped["_meta"] != nil {
+		decoded, meta = wrapped["data"], wrapped["_meta"]
+	}
+	var data interface{} = decoded
+	switch v := decoded.(type) {
+	case map[string]interface{}:
+		data = d.resource(schema, v)
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i, item := range v {
+			list[i] = item
+			if obj, ok := item.(map[string]interface{}); ok {
+				list[i] = d.resource(schema, obj)
+			}
+		}
+		data = list
+	}
+	doc := map[string]interface{}{"data": data}
+	if len(d.included) > 0 {
+		doc["included"] = d.included
+	}
+	if meta != nil {
+		doc["meta"] = map[string]interface{}{"debug": meta}
+	}
+	return doc
+}
+
+// resource converts a record of schema to a resource object. Its x-ref
+// properties become relationships, and references embedded by ?expand=
+// move to the document's included records.
+func (d *jsonAPIDocument) resource(schema *Schema, obj map[string]interface{}) map[string]interface{} {
+	attributes := make(map[string]interface{}, len(obj))
+	for name, value := range obj {
+		if name != schema.idKey() {
+			attributes[name] = value
+		}
+	}
+	relationships := make(map[string]interface{})
+	for property, prop := range schema.Properties {
+		if prop.XRef == "" {
+			continue
+		}
+		name := expandName(property)
+		target := d.server.jsonAPITarget(prop.XRef)
+		ref := obj[property]
+		if embedded, ok := obj[name].(map[string]interface{}); ok && target != nil {
+			if name == property {
+				ref = embedded[target.idKey()]
+			}
+			d.include(target, embedded)
+			delete(attributes, name)
+		}
+		delete(attributes, property)
+		if ref == nil {
+			relationships[name] = map[string]interface{}{"data": nil}
+			continue
+		}
+		typ := strings.ToLower(pluralize(prop.XRef))
+		if target != nil {
+			typ = entityName(target)
+		}
+		relationships[name] = map[string]interface{}{
+			"data": map[string]interface{}{"type": typ, "id": fmt.Sprint(ref)},
+		}
+	}
+
+	res := map[string]interface{}{
+		"type":       entityName(schema),
+		"attributes": attributes,
+	}
+	if id, ok := obj[schema.idKey()]; ok && id != nil {
+		res["id"] = fmt.Sprint(id)
+	}
+	if len(relationships) > 0 {
+		res["relationships"] = relationships
+	}
+	return res
+}
+
+// include adds an embedded record to the included records, once per type
+// and id.
+func (d *jsonAPIDocument) include(schema *Schema, obj map[string]interface{}) {
+	key := entityName(schema) + "/" + fmt.Sprint(obj[schema.idKey()])
+	if d.seen[key] {
+		return
+	}
+	d.seen[key] = true
+	d.included = append(d.included, d.resource(schema, obj))
+}
+
+// jsonAPITarget returns the registered schema an x-ref names, or nil.
+func (s *Server) jsonAPITarget(title string) *Schema {
+	s.stateMu.RLock()
+	defer s.stateMu.RUnlock()
+	schema, _ := s.lookupSchema(title)
+	return schema
+}
+
+// jsonAPIErrors converts an Error body to JSON:API error objects. Field
+// validation errors become one object each, pointing into the attributes.
+func jsonAPIErrors(status int, body []byte) []interface{} {
+	var e struct {
+		Code    string          `json:"code"`
+		Message string          `json:"message"`
+		Details json.RawMessage `json:"details"`
+	}
+	json.Unmarshal(body, &e)
+	if e.Code == "" {
+		e.Code = errorCode(status)
+	}
+	object := func(detail string) map[string]interface{} {
+		return map[string]interface{}{
+			"status": strconv.Itoa(status),
+			"code":   e.Code,
+			"title":  http.StatusText(status),
+			"detail": detail,
+		}
+	}
+
+	var fields []fieldError
+	if json.Unmarshal(e.Details, &fields) == nil && len(fields) > 0 && fields[0].Pointer != "" {
+		errs := make([]interface{}, len(fields))
+		for i, field := range fields {
+			obj := object(field.Message)
+			obj["source"] = map[string]interface{}{"pointer": "/data/attributes" + field.Pointer}
+			errs[i] = obj
+		}
+		return errs
+	}
+	return []interface{}{object(e.Message)}
+}
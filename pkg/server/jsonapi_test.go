@@ -0,0 +1,72 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestJSONAPI(t *testing.T) {
+	s := NewServer()
+	for _, schema := range []string{
+		`{"title": "User", "type": "object", "properties": {"id": {"type": "integer"}, "name": {"type": "string"}}, "required": ["name"]}`,
+		`{"title": "Order", "type": "object", "properties": {"id": {"type": "integer"}, "userId": {"type": "integer", "x-ref": "User"}, "total": {"type": "number"}}}`,
+	} {
+		if _, err := s.loadSchema(strings.NewReader(schema)); err != nil {
+			t.Fatalf("could not load schema: %v", err)
+		}
+	}
+	serve := func(method, path, body string) (*httptest.ResponseRecorder, map[string]interface{}) {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Accept", jsonAPIContentType)
+		if body != "" {
+			req.Header.Set("Content-Type", jsonAPIContentType)
+		}
+		rr := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rr, req)
+		var doc map[string]interface{}
+		json.Unmarshal(rr.Body.Bytes(), &doc)
+		return rr, doc
+	}
+
+	rr, doc := serve(http.MethodPost, "/users", `{"data": {"type": "users", "id": "3", "attributes": {"name": "alice"}}}`)
+	if rr.Code != http.StatusCreated || rr.Header().Get("Content-Type") != jsonAPIContentType {
+		t.Fatalf("handler returned unexpected response: got %v %v", rr.Code, rr.Body.String())
+	}
+	serve(http.MethodPost, "/orders", `{"data": {"type": "orders", "attributes": {"total": 9}, "relationships": {"user": {"data": {"type": "users", "id": "3"}}}}}`)
+
+	rr, doc = serve(http.MethodGet, "/orders/1?expand=user", "")
+	data, _ := doc["data"].(map[string]interface{})
+	if data["type"] != "orders" || data["id"] != "1" || data["attributes"].(map[string]interface{})["userId"] != nil {
+		t.Errorf("handler returned unexpected resource: got %v", rr.Body.String())
+	}
+	if !strings.Contains(rr.Body.String(), `"relationships":{"user":{"data":{"id":"3","type":"users"}}}`) ||
+		!strings.Contains(rr.Body.String(), `"included":[{"attributes":{"name":"alice"},"id":"3","type":"users"}]`) {
+		t.Errorf("handler returned unexpected relationships: got %v", rr.Body.String())
+	}
+
+	rr, doc = serve(http.MethodGet, "/users", "")
+	if list, _ := doc["data"].([]interface{}); len(list) != 1 || doc["meta"].(map[string]interface{})["total"] != float64(1) {
+		t.Errorf("handler returned unexpected list: got %v", rr.Body.String())
+	}
+
+	rr, doc = serve(http.MethodPost, "/users", `{"data": {"type": "users", "attributes": {}}}`)
+	errs, _ := doc["errors"].([]interface{})
+	if rr.Code != http.StatusBadRequest || len(errs) != 1 || !strings.Contains(rr.Body.String(), `"pointer":"/data/attributes/name"`) {
+		t.Errorf("handler returned unexpected errors: got %v %v", rr.Code, rr.Body.String())
+	}
+	if rr, _ := serve(http.MethodPost, "/users", `{"data": {"type": "orders", "attributes": {"name": "bob"}}}`); rr.Code != http.StatusConflict {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusConflict)
+	}
+
+	// Plain JSON clients are unaffected.
+	req := httptest.NewRequest(http.MethodGet, "/users/3", nil)
+	rr = httptest.NewRecorder()
+	s.Handler().ServeHTTP(rr, req)
+	if !strings.HasPrefix(rr.Body.String(), `{"id":3`) {
+		t.Errorf("handler returned unexpected response: got %v", rr.Body.String())
+	}
+}
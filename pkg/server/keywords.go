@@ -0,0 +1,432 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+// Keywords are the JSON Schema keywords that both schemas and properties
+// take beyond type, properties and required: object constraints, and the
+// subschemas a value is combined with.
+type Keywords struct {
+	// AdditionalProperties is the schema of object fields that neither
+	// Properties nor PatternProperties cover; false forbids them.
+	AdditionalProperties *Property `json:"additionalProperties,omitempty"`
+	// PatternProperties gives the schema of fields whose names match each
+	// regular expression.
+	PatternProperties map[string]Property `json:"patternProperties,omitempty"`
+	// DependentRequired lists, by field, the fields that must be present
+	// when it is; DependentSchemas the schema the object must then
+	// match.
+	DependentRequired map[string][]string `json:"dependentRequired,omitempty"`
+	DependentSchemas  map[string]Property `json:"dependentSchemas,omitempty"`
+	// MinProperties and MaxProperties bound the number of fields.
+	MinProperties *int `json:"minProperties,omitempty"`
+	MaxProperties *int `json:"maxProperties,omitempty"`
+	// AllOf, AnyOf and OneOf list schemas the value must match all, at
+	// least one or exactly one of, and Not one it must not match. A value
+	// matching If must match Then, and otherwise Else.
+	AllOf []Property `json:"allOf,omitempty"`
+	AnyOf []Property `json:"anyOf,omitempty"`
+	OneOf []Property `json:"oneOf,omitempty"`
+	Not   *Property  `json:"not,omitempty"`
+	If    *Property  `json:"if,omitempty"`
+	Then  *Property  `json:"then,omitempty"`
+	Else  *Property  `json:"else,omitempty"`
+}
+
+// UnmarshalJSON decodes a property, accepting the boolean schemas true
+// (anything) and false (nothing), and type lists: "null" in one sets
+// Nullable, and several other types become an anyOf of them.
+func (p *Property) UnmarshalJSON(data []byte) error {
+	var accept bool
+	if err := json.Unmarshal(data, &accept); err == nil {
+		*p = Property{}
+		if !accept {
+			p.Not = &Property{}
+		}
+		return nil
+	}
+	type plain Property
+	var raw struct {
+		plain
+		Type json.RawMessage `json:"type"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*p = Property(raw.plain)
+	if len(raw.Type) == 0 {
+		return nil
+	}
+	var types []string
+	if err := json.Unmarshal(raw.Type, &p.Type); err == nil {
+		return nil
+	}
+	if err := json.Unmarshal(raw.Type, &types); err != nil {
+		return fmt.Errorf("type must be a string or an array of strings")
+	}
+	var others []string
+	for _, t := range types {
+		if t == "null" {
+			p.Nullable = true
+		} else {
+			others = append(others, t)
+		}
+	}
+	switch len(others) {
+	case 0:
+	case 1:
+		p.Type = others[0]
+	default:
+		for _, t := range others {
+			p.AnyOf = append(p.AnyOf, Property{Type: t})
+		}
+	}
+	return nil
+}
+
+// rejectsAll reports whether p is the false schema, which no value
+// matches.
+func (p Property) rejectsAll() bool {
+	return p.Not != nil && reflect.DeepEqual(*p.Not, Property{})
+}
+
+// subschemas calls visit with a copy of each subschema the keywords hold,
+// named by its keyword path, and stores the copies back, so references
+// can be inlined in and upload checks run on all of them without touching
+// the schemas they were copied from.
+func (k *Keywords) subschemas(visit func(path string, sub *Property) error) error {
+	for _, single := range []struct {
+		name string
+		sub  **Property
+	}{{"additionalProperties", &k.AdditionalProperties}, {"not", &k.Not}, {"if", &k.If}, {"then", &k.Then}, {"else", &k.Else}} {
+		if *single.sub == nil {
+			continue
+		}
+		sub := **single.sub
+		if err := visit(single.name, &sub); err != nil {
+			return err
+		}
+		*single.sub = &sub
+	}
+	for _, list := range []struct {
+		name string
+		subs *[]Property
+	}{{"allOf", &k.AllOf}, {"anyOf", &k.AnyOf}, {"oneOf", &k.OneOf}} {
+		if len(*list.subs) == 0 {
+			continue
+		}
+		subs := append([]Property(nil), *list.subs...)
+		for i := range subs {
+			if err := visit(fmt.Sprintf("%s[%d]", list.name, i), &subs[i]); err != nil {
+				return err
+			}
+		}
+		*list.subs = subs
+	}
+	for _, named := range []struct {
+		name string
+		subs *map[string]Property
+	}{{"patternProperties", &k.PatternProperties}, {"dependentSchemas", &k.DependentSchemas}} {
+		if len(*named.subs) == 0 {
+			continue
+		}
+		subs := make(map[string]Property, len(*named.subs))
+		for _, key := range sortedKeys(*named.subs) {
+			sub := (*named.subs)[key]
+			if err := visit(named.name+"."+key, &sub); err != nil {
+				return err
+			}
+			subs[key] = sub
+		}
+		*named.subs = subs
+	}
+	return nil
+}
+
+// mergeProperty returns base with the keywords of sub it lacks, as allOf
+// combines them for generation: properties are merged, one declared by
+// both merged the same way, and required lists joined.
+func mergeProperty(base, sub Property) Property {
+	merged := base
+	out, in := reflect.ValueOf(&merged).Elem(), reflect.ValueOf(sub)
+	var fill func(out, in reflect.Value)
+	fill = func(out, in reflect.Value) {
+		for i := 0; i < out.NumField(); i++ {
+			field := out.Field(i)
+			if out.Type().Field(i).Anonymous {
+				fill(field, in.Field(i))
+			} else if field.IsZero() {
+				field.Set(in.Field(i))
+			}
+		}
+	}
+	fill(out, in)
+	if len(base.Properties) > 0 && len(sub.Properties) > 0 {
+		merged.Properties = make(map[string]Property, len(base.Properties)+len(sub.Properties))
+		for name, prop := range sub.Properties {
+			merged.Properties[name] = prop
+		}
+		for name, prop := range base.Properties {
+			if other, ok := sub.Properties[name]; ok {
+				prop = mergeProperty(prop, other)
+			}
+			merged.Properties[name] = prop
+		}
+	}
+	for _, name := range sub.Required {
+		if !containsString(merged.Required, name) {
+			merged.Required = append(merged.Required, name)
+		}
+	}
+	return merged
+}
+
+// generationProperty resolves the combinators of prop into one property
+// that generation can fill: allOf is merged in and the first anyOf or
+// oneOf alternative that is not the false schema used. Untyped properties
+// declaring fields are objects.
+func generationProperty(prop Property) Property {
+	for len(prop.AllOf) > 0 || len(prop.AnyOf) > 0 || len(prop.OneOf) > 0 {
+		parts := prop.AllOf
+		for _, alternatives := range [][]Property{prop.AnyOf, prop.OneOf} {
+			for _, alternative := range alternatives {
+				if !alternative.rejectsAll() {
+					parts = append(parts, alternative)
+					break
+				}
+			}
+		}
+		prop.AllOf, prop.AnyOf, prop.OneOf = nil, nil, nil
+		for _, part := range parts {
+			prop = mergeProperty(prop, part)
+		}
+	}
+	if prop.Type == "" && len(prop.Properties) > 0 {
+		prop.Type = "object"
+	}
+	return prop
+}
+
+// mergeAllOf merges the properties and required fields of the parts of a
+// schema's top-level allOf into the schema, so its routes, filters and
+// generated records cover them. The allOf itself stays for validation.
+func mergeAllOf(schema *Schema) {
+	for _, part := range schema.AllOf {
+		part = generationProperty(part)
+		if len(part.Properties) > 0 && schema.Properties == nil {
+			schema.Properties = make(map[string]Property, len(part.Properties))
+		}
+		for name, prop := range part.Properties {
+			if declared, ok := schema.Properties[name]; ok {
+				prop = mergeProperty(declared, prop)
+			}
+			schema.Properties[name] = prop
+		}
+		for _, name := range part.Required {
+			if !containsString(schema.Required, name) {
+				schema.Required = append(schema.Required, name)
+			}
+		}
+	}
+}
+
+// validateObjectValue checks an object against the object keywords of prop:
+// required fields, declared properties, pattern and additional properties,
+// dependencies and the field count.
+func validateObjectValue(field string, prop Property, obj map[string]interface{}, pointer string) []fieldError {
+	failure := func(format string, args ...interface{}) fieldError {
+		return fieldError{Field: field, Pointer: pointer, Message: fmt.Sprintf(format, args...)}
+	}
+	// readOnly fields are dropped from request bodies, so they cannot be
+	// required in them.
+	var required []string
+	for _, name := range prop.Required {
+		if !prop.Properties[name].ReadOnly {
+			required = append(required, name)
+		}
+	}
+	errs := append(validateRequired(required, obj, pointer), validateObject(prop.Properties, obj, pointer)...)
+	if prop.MinProperties != nil && len(obj) < *prop.MinProperties {
+		errs = append(errs, failure("expected at least %d properties, got %d", *prop.MinProperties, len(obj)))
+	}
+	if prop.MaxProperties != nil && len(obj) > *prop.MaxProperties {
+		errs = append(errs, failure("expected at most %d properties, got %d", *prop.MaxProperties, len(obj)))
+	}
+	for _, key := range sortedKeys(obj) {
+		keyPointer := pointer + "/" + escapePointerToken(key)
+		_, covered := prop.Properties[key]
+		for _, pattern := range sortedKeys(prop.PatternProperties) {
+			// Patterns are compiled when the schema is uploaded.
+			if re, err := compilePattern(pattern); err == nil && re.MatchString(key) {
+				covered = true
+				errs = append(errs, validateValue(key, prop.PatternProperties[pattern], obj[key], keyPointer)...)
+			}
+		}
+		if covered || prop.AdditionalProperties == nil {
+			continue
+		}
+		if prop.AdditionalProperties.rejectsAll() {
+			errs = append(errs, fieldError{Field: key, Pointer: keyPointer, Message: "additional property is not allowed"})
+			continue
+		}
+		errs = append(errs, validateValue(key, *prop.AdditionalProperties, obj[key], keyPointer)...)
+	}
+	for _, key := range sortedKeys(prop.DependentRequired) {
+		if _, present := obj[key]; present {
+			for _, err := range validateRequired(prop.DependentRequired[key], obj, pointer) {
+				err.Message = fmt.Sprintf("required when %s is present", key)
+				errs = append(errs, err)
+			}
+		}
+	}
+	for _, key := range sortedKeys(prop.DependentSchemas) {
+		if _, present := obj[key]; present {
+			errs = append(errs, validateValue(field, prop.DependentSchemas[key], obj, pointer)...)
+		}
+	}
+	return errs
+}
+
+// validateArrayKeywords checks uniqueItems and contains on an array.
+func validateArrayKeywords(field string, prop Property, list []interface{}, pointer string) []fieldError {
+	var errs []fieldError
+	if prop.UniqueItems {
+		for i := range list {
+			for j := 0; j < i; j++ {
+				if reflect.DeepEqual(list[i], list[j]) {
+					errs = append(errs, fieldError{Field: field, Pointer: pointer + "/" + strconv.Itoa(i), Message: fmt.Sprintf("duplicates item %d", j)})
+					break
+				}
+			}
+		}
+	}
+	if prop.Contains != nil {
+		matches := 0
+		for _, item := range list {
+			if len(validateValue(field, *prop.Contains, item, "")) == 0 {
+				matches++
+			}
+		}
+		least, most := 1, -1
+		if prop.MinContains != nil {
+			least = *prop.MinContains
+		}
+		if prop.MaxContains != nil {
+			most = *prop.MaxContains
+		}
+		if matches < least || most >= 0 && matches > most {
+			limits := fmt.Sprintf("at least %d", least)
+			if most >= 0 {
+				limits += fmt.Sprintf(" and at most %d", most)
+			}
+			errs = append(errs, fieldError{Field: field, Pointer: pointer, Message: fmt.Sprintf("expected %s items matching contains, got %d", limits, matches)})
+		}
+	}
+	return errs
+}
+
+// validateCombinators checks a value against the allOf, anyOf, oneOf, not
+// and if/then/else of prop. Errors inside allOf and the chosen if branch
+// are reported as they are; the other combinators report one error of
+// their own.
+func validateCombinators(field string, prop Property, value interface{}, pointer string) []fieldError {
+	failure := func(format string, args ...interface{}) fieldError {
+		return fieldError{Field: field, Pointer: pointer, Message: fmt.Sprintf(format, args...)}
+	}
+	matches := func(sub Property) bool {
+		return len(validateValue(field, sub, value, pointer)) == 0
+	}
+	var errs []fieldError
+	for _, sub := range prop.AllOf {
+		errs = append(errs, validateValue(field, sub, value, pointer)...)
+	}
+	if len(prop.AnyOf) > 0 {
+		matched := false
+		for _, sub := range prop.AnyOf {
+			if matched = matches(sub); matched {
+				break
+			}
+		}
+		if !matched {
+			errs = append(errs, failure("value must match at least one schema of anyOf"))
+		}
+	}
+	if len(prop.OneOf) > 0 {
+		count := 0
+		for _, sub := range prop.OneOf {
+			if matches(sub) {
+				count++
+			}
+		}
+		if count != 1 {
+			errs = append(errs, failure("value must match exactly one schema of oneOf, matched %d", count))
+		}
+	}
+	if prop.Not != nil {
+		if prop.rejectsAll() {
+			errs = append(errs, failure("no value is allowed"))
+		} else if matches(*prop.Not) {
+			errs = append(errs, failure("value must not match the schema of not"))
+		}
+	}
+	if prop.If != nil {
+		branch := prop.Else
+		if matches(*prop.If) {
+			branch = prop.Then
+		}
+		if branch != nil {
+			errs = append(errs, validateValue(field, *branch, value, pointer)...)
+		}
+	}
+	return errs
+}
+
+// recordProperty returns the schema as the property a request body must
+// match. Bodies that are not whole records, such as plain PATCH bodies,
+// are only checked field by field: the combinators, dependencies and
+// field counts that need the whole record are left out.
+func (s *Schema) recordProperty(required []string, whole bool) Property {
+	prop := Property{Type: "object", Properties: s.Properties, Required: required, Keywords: s.Keywords}
+	if !whole {
+		prop.Keywords = Keywords{AdditionalProperties: s.AdditionalProperties, PatternProperties: s.PatternProperties}
+	}
+	return prop
+}
+
+// validateRecord checks a request body against the schema; see
+// recordProperty.
+func validateRecord(schema *Schema, required []string, body map[string]interface{}, pointer string, whole bool) []fieldError {
+	prop := schema.recordProperty(required, whole)
+	return append(validateObjectValue("", prop, body, pointer), validateCombinators("", prop, body, pointer)...)
+}
+
+// validateKeywords checks the pattern properties and subschemas of a schema
+// or, when path names one, a property.
+func (s *Server) validateKeywords(path string, k Keywords) error {
+	prefix := ""
+	if path != "" {
+		prefix = "property " + path + ": "
+	}
+	for _, pattern := range sortedKeys(k.PatternProperties) {
+		if _, err := compilePattern(pattern); err != nil {
+			return fmt.Errorf("%spatternProperties: invalid pattern %q: %v", prefix, pattern, err)
+		}
+	}
+	return k.subschemas(func(name string, sub *Property) error {
+		if path != "" {
+			name = path + "." + name
+		}
+		return s.validateSubschema(name, *sub)
+	})
+}
+
+// validateSubschema checks a subschema like a property. The fields it
+// requires may be declared next to it, so they are not checked.
+func (s *Server) validateSubschema(path string, sub Property) error {
+	sub.Required = nil
+	return s.validateProperty(path, sub)
+}
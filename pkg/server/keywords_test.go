@@ -0,0 +1,122 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestKeywordValidation(t *testing.T) {
+	srv := NewServer()
+	schema := []byte(`{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "title": "Item",
+  "type": "object",
+  "properties": {
+    "id": {"type": "integer"},
+    "kind": {"enum": ["book", "film"]},
+    "isbn": {"type": "string"},
+    "minutes": {"type": "integer"},
+    "note": {"type": ["string", "null"]},
+    "tags": {"type": "array", "items": {"type": "string"}, "uniqueItems": true},
+    "meta": {
+      "type": "object",
+      "patternProperties": {"^x-": {"type": "string"}},
+      "additionalProperties": false
+    },
+    "size": {"oneOf": [{"type": "integer", "maximum": 10}, {"type": "integer", "minimum": 5}]},
+    "code": {"anyOf": [{"type": "string"}, {"type": "integer"}], "not": {"const": "none"}}
+  },
+  "required": ["kind"],
+  "dependentRequired": {"minutes": ["note"]},
+  "if": {"properties": {"kind": {"const": "book"}}},
+  "then": {"required": ["isbn"]},
+  "else": {"required": ["minutes"]}
+}`)
+	if rr := performRequest(t, srv.uploadHandler, http.MethodPost, "/upload", schema); rr.Code != http.StatusOK {
+		t.Fatalf("upload failed: %v", rr.Body.String())
+	}
+
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"Then Branch", `{"kind": "book", "isbn": "978-3"}`, http.StatusCreated},
+		{"Then Branch Missing", `{"kind": "book"}`, http.StatusBadRequest},
+		{"Else Branch", `{"kind": "film", "minutes": 90, "note": null}`, http.StatusCreated},
+		{"Dependent Required", `{"kind": "film", "minutes": 90}`, http.StatusBadRequest},
+		{"Null Rejected", `{"kind": "book", "isbn": null}`, http.StatusBadRequest},
+		{"Duplicate Items", `{"kind": "book", "isbn": "1", "tags": ["a", "a"]}`, http.StatusBadRequest},
+		{"Pattern Properties", `{"kind": "book", "isbn": "1", "meta": {"x-a": "b"}}`, http.StatusCreated},
+		{"Pattern Property Type", `{"kind": "book", "isbn": "1", "meta": {"x-a": 1}}`, http.StatusBadRequest},
+		{"Additional Property", `{"kind": "book", "isbn": "1", "meta": {"other": "b"}}`, http.StatusBadRequest},
+		{"One Of", `{"kind": "book", "isbn": "1", "size": 12}`, http.StatusCreated},
+		{"One Of Both", `{"kind": "book", "isbn": "1", "size": 7}`, http.StatusBadRequest},
+		{"Any Of", `{"kind": "book", "isbn": "1", "code": 3}`, http.StatusCreated},
+		{"Any Of None", `{"kind": "book", "isbn": "1", "code": true}`, http.StatusBadRequest},
+		{"Not", `{"kind": "book", "isbn": "1", "code": "none"}`, http.StatusBadRequest},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rr := performRequest(t, srv.catchAllHandler, http.MethodPost, "/items", []byte(tc.body))
+			if status := rr.Code; status != tc.want {
+				t.Errorf("handler returned wrong status code: got %v want %v: %s", status, tc.want, rr.Body.String())
+			}
+		})
+	}
+
+	// A PATCH body is not a whole record, so the conditional is left out.
+	if rr := performRequest(t, srv.catchAllHandler, http.MethodPatch, "/items/1", []byte(`{"tags": ["b"]}`)); rr.Code != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v: %s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+}
+
+func TestKeywordGeneration(t *testing.T) {
+	srv := NewServer()
+	schema := []byte(`{
+  "title": "Order",
+  "allOf": [
+    {"properties": {"id": {"type": "integer"}, "status": {"type": "string", "const": "open"}}, "required": ["status"]}
+  ],
+  "properties": {
+    "total": {"allOf": [{"type": "number"}, {"minimum": 5}]},
+    "contact": {"oneOf": [false, {"type": "string", "format": "email"}, {"type": "integer"}]},
+    "codes": {"type": "array", "items": {"type": "string"}, "uniqueItems": true},
+    "shipping": {
+      "type": "object",
+      "properties": {"method": {"enum": ["pickup", "post"]}},
+      "if": {"properties": {"method": {"const": "pickup"}}},
+      "then": {"properties": {"store": {"type": "string", "const": "main"}}}
+    }
+  }
+}`)
+	if rr := performRequest(t, srv.uploadHandler, http.MethodPost, "/upload", schema); rr.Code != http.StatusOK {
+		t.Fatalf("upload failed: %v", rr.Body.String())
+	}
+
+	rr := performRequest(t, srv.catchAllHandler, http.MethodGet, "/orders/1", nil)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	var order map[string]interface{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &order); err != nil {
+		t.Fatal(err)
+	}
+	if order["status"] != "open" {
+		t.Errorf("allOf property was not generated: got %v", order["status"])
+	}
+	if total, _ := order["total"].(float64); total < 5 {
+		t.Errorf("allOf constraints were not merged: got total %v", order["total"])
+	}
+	if order["contact"] != "user1@example.com" {
+		t.Errorf("oneOf did not use the first satisfiable alternative: got %v", order["contact"])
+	}
+	if codes, _ := order["codes"].([]interface{}); len(codes) != 2 || codes[0] == codes[1] {
+		t.Errorf("uniqueItems array has duplicates: got %v", order["codes"])
+	}
+	shipping, _ := order["shipping"].(map[string]interface{})
+	if shipping["method"] != "pickup" || shipping["store"] != "main" {
+		t.Errorf("then branch was not generated: got %v", shipping)
+	}
+}
@@ -0,0 +1,347 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Drafts of JSON Schema an upload may declare in $schema. Schemas that do
+// not declare one are checked against what both drafts accept.
+const (
+	draft07   = "draft-07"
+	draft2020 = "2020-12"
+)
+
+// schemaDrafts maps the $schema URIs of the supported drafts, without any
+// trailing #, to the draft.
+var schemaDrafts = map[string]string{
+	"http://json-schema.org/draft-07/schema":       draft07,
+	"https://json-schema.org/draft-07/schema":      draft07,
+	"https://json-schema.org/draft/2020-12/schema": draft2020,
+	"http://json-schema.org/draft/2020-12/schema":  draft2020,
+}
+
+// simpleTypes are the values the type keyword may take.
+var simpleTypes = []string{"array", "boolean", "integer", "null", "number", "object", "string"}
+
+// schemaError is one place an uploaded schema breaks the JSON Schema
+// meta-schema: its JSON Pointer, where in the upload it starts and what is
+// wrong with it.
+type schemaError struct {
+	Pointer string `json:"pointer"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Message string `json:"message"`
+}
+
+// schemaErrors are all the meta-schema violations of an upload, in
+// document order.
+type schemaErrors []schemaError
+
+func (errs schemaErrors) Error() string {
+	messages := make([]string, len(errs))
+	for i, err := range errs {
+		pointer := err.Pointer
+		if pointer == "" {
+			pointer = "/"
+		}
+		messages[i] = fmt.Sprintf("%s (line %d, column %d): %s", pointer, err.Line, err.Column, err.Message)
+	}
+	return strings.Join(messages, "; ")
+}
+
+// metaSchemaChecker walks a decoded upload, collecting the places it breaks
+// the meta-schema of its draft.
+type metaSchemaChecker struct {
+	data    []byte
+	offsets map[string]int64
+	draft   string
+	errs    schemaErrors
+}
+
+// checkMetaSchema validates an uploaded schema document against the
+// meta-schema of the draft its $schema names, draft-07 or 2020-12. Keywords
+// the meta-schema does not know, such as this server's x- extensions, are
+// left alone, as the meta-schema allows. A document that is not JSON is
+// left for the decoder to report.
+func checkMetaSchema(data []byte) error {
+	c := &metaSchemaChecker{data: data, offsets: make(map[string]int64)}
+	decoder := json.NewDecoder(strings.NewReader(string(data)))
+	decoder.UseNumber()
+	doc, err := c.decode(decoder, "")
+	if err != nil {
+		return nil
+	}
+	obj, ok := doc.(map[string]interface{})
+	if !ok {
+		c.fail("", "expected a schema object, got %s", metaTypeName(doc))
+		return c.errs
+	}
+	if raw, ok := obj["$schema"]; ok {
+		uri, _ := raw.(string)
+		draft, known := schemaDrafts[strings.TrimSuffix(uri, "#")]
+		if !known {
+			c.fail("/$schema", "unsupported $schema %s, expected the draft-07 or 2020-12 meta-schema URI", compactJSON(raw))
+			return c.errs
+		}
+		c.draft = draft
+	}
+	c.schema("", doc)
+	sort.SliceStable(c.errs, func(i, j int) bool { return c.offsets[c.errs[i].Pointer] < c.offsets[c.errs[j].Pointer] })
+	if len(c.errs) > 0 {
+		return c.errs
+	}
+	return nil
+}
+
+// decode reads the next JSON value, noting where each value under pointer
+// starts.
+func (c *metaSchemaChecker) decode(decoder *json.Decoder, pointer string) (interface{}, error) {
+	offset := decoder.InputOffset()
+	for offset < int64(len(c.data)) && strings.IndexByte(" \t\r\n:,", c.data[offset]) >= 0 {
+		offset++
+	}
+	c.offsets[pointer] = offset
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch token {
+	case json.Delim('{'):
+		obj := make(map[string]interface{})
+		for decoder.More() {
+			key, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+			name, _ := key.(string)
+			value, err := c.decode(decoder, pointer+"/"+escapePointerToken(name))
+			if err != nil {
+				return nil, err
+			}
+			obj[name] = value
+		}
+		_, err := decoder.Token()
+		return obj, err
+	case json.Delim('['):
+		list := []interface{}{}
+		for decoder.More() {
+			value, err := c.decode(decoder, pointer+"/"+strconv.Itoa(len(list)))
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, value)
+		}
+		_, err := decoder.Token()
+		return list, err
+	}
+	return token, nil
+}
+
+// fail records a violation at pointer.
+func (c *metaSchemaChecker) fail(pointer, format string, args ...interface{}) {
+	offset := int(c.offsets[pointer])
+	line := 1 + strings.Count(string(c.data[:offset]), "\n")
+	column := offset - strings.LastIndexByte(string(c.data[:offset]), '\n')
+	c.errs = append(c.errs, schemaError{Pointer: pointer, Line: line, Column: column, Message: fmt.Sprintf(format, args...)})
+}
+
+// schema checks a subschema: an object of keywords, or a boolean.
+func (c *metaSchemaChecker) schema(pointer string, value interface{}) {
+	if _, ok := value.(bool); ok {
+		return
+	}
+	obj, ok := value.(map[string]interface{})
+	if !ok {
+		c.fail(pointer, "expected a schema (an object or boolean), got %s", metaTypeName(value))
+		return
+	}
+	keys := make([]string, 0, len(obj))
+	for key := range obj {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		c.keyword(pointer+"/"+escapePointerToken(key), key, obj[key])
+	}
+}
+
+// keyword checks the value of one keyword of a subschema.
+func (c *metaSchemaChecker) keyword(pointer, key string, value interface{}) {
+	switch key {
+	case "$id", "$ref", "$comment", "$anchor", "$dynamicRef", "$dynamicAnchor", "title", "description", "format",
+		"pattern", "contentEncoding", "contentMediaType":
+		if _, ok := value.(string); !ok {
+			c.fail(pointer, "%s must be a string, got %s", key, metaTypeName(value))
+		}
+	case "type":
+		c.typeKeyword(pointer, value)
+	case "enum", "examples":
+		if _, ok := value.([]interface{}); !ok {
+			c.fail(pointer, "%s must be an array, got %s", key, metaTypeName(value))
+		}
+	case "required":
+		c.stringArray(pointer, key, value)
+	case "properties", "patternProperties", "definitions", "$defs", "dependentSchemas":
+		c.schemaMap(pointer, key, value, func(entry string, v interface{}) { c.schema(entry, v) })
+	case "dependentRequired":
+		c.schemaMap(pointer, key, value, func(entry string, v interface{}) { c.stringArray(entry, key+" entry", v) })
+	case "dependencies":
+		c.schemaMap(pointer, key, value, func(entry string, v interface{}) {
+			if _, ok := v.([]interface{}); ok {
+				c.stringArray(entry, key+" entry", v)
+			} else {
+				c.schema(entry, v)
+			}
+		})
+	case "items":
+		if list, ok := value.([]interface{}); ok && c.draft != draft2020 {
+			c.schemaArray(pointer, key, list, false)
+		} else {
+			c.schema(pointer, value)
+		}
+	case "prefixItems":
+		if c.draft == draft07 {
+			return
+		}
+		list, ok := value.([]interface{})
+		if !ok {
+			c.fail(pointer, "%s must be an array of schemas, got %s", key, metaTypeName(value))
+			return
+		}
+		c.schemaArray(pointer, key, list, true)
+	case "allOf", "anyOf", "oneOf":
+		list, ok := value.([]interface{})
+		if !ok {
+			c.fail(pointer, "%s must be an array of schemas, got %s", key, metaTypeName(value))
+			return
+		}
+		c.schemaArray(pointer, key, list, true)
+	case "additionalItems":
+		if c.draft != draft2020 {
+			c.schema(pointer, value)
+		}
+	case "additionalProperties", "contains", "propertyNames", "if", "then", "else", "not":
+		c.schema(pointer, value)
+	case "unevaluatedItems", "unevaluatedProperties", "contentSchema":
+		if c.draft != draft07 {
+			c.schema(pointer, value)
+		}
+	case "minLength", "maxLength", "minItems", "maxItems", "minProperties", "maxProperties", "minContains", "maxContains":
+		if n, ok := metaNumber(value); !ok || n < 0 || n != math.Trunc(n) {
+			c.fail(pointer, "%s must be a non-negative integer, got %s", key, compactJSON(value))
+		}
+	case "minimum", "maximum", "exclusiveMinimum", "exclusiveMaximum":
+		if _, ok := metaNumber(value); !ok {
+			c.fail(pointer, "%s must be a number, got %s", key, metaTypeName(value))
+		}
+	case "multipleOf":
+		if n, ok := metaNumber(value); !ok || n <= 0 {
+			c.fail(pointer, "%s must be a number greater than 0, got %s", key, compactJSON(value))
+		}
+	case "uniqueItems", "readOnly", "writeOnly", "deprecated":
+		if _, ok := value.(bool); !ok {
+			c.fail(pointer, "%s must be a boolean, got %s", key, metaTypeName(value))
+		}
+	}
+}
+
+// typeKeyword checks type: one of simpleTypes, or a non-empty array of
+// distinct ones.
+func (c *metaSchemaChecker) typeKeyword(pointer string, value interface{}) {
+	if name, ok := value.(string); ok {
+		if !containsString(simpleTypes, name) {
+			c.fail(pointer, "unknown type %q, expected one of %s", name, strings.Join(simpleTypes, ", "))
+		}
+		return
+	}
+	list, ok := value.([]interface{})
+	if !ok || len(list) == 0 {
+		c.fail(pointer, "type must be a type name or a non-empty array of them, got %s", compactJSON(value))
+		return
+	}
+	var seen []string
+	for i, item := range list {
+		entry := pointer + "/" + strconv.Itoa(i)
+		name, ok := item.(string)
+		switch {
+		case !ok || !containsString(simpleTypes, name):
+			c.fail(entry, "unknown type %s, expected one of %s", compactJSON(item), strings.Join(simpleTypes, ", "))
+		case containsString(seen, name):
+			c.fail(entry, "type lists %q more than once", name)
+		}
+		seen = append(seen, name)
+	}
+}
+
+// stringArray checks an array of distinct strings, such as required.
+func (c *metaSchemaChecker) stringArray(pointer, key string, value interface{}) {
+	list, ok := value.([]interface{})
+	if !ok {
+		c.fail(pointer, "%s must be an array of strings, got %s", key, metaTypeName(value))
+		return
+	}
+	var seen []string
+	for i, item := range list {
+		entry := pointer + "/" + strconv.Itoa(i)
+		name, ok := item.(string)
+		switch {
+		case !ok:
+			c.fail(entry, "%s must be an array of strings, got %s", key, compactJSON(item))
+		case containsString(seen, name):
+			c.fail(entry, "%s lists %q more than once", key, name)
+		}
+		seen = append(seen, name)
+	}
+}
+
+// schemaMap checks an object keyword, calling check on each entry.
+func (c *metaSchemaChecker) schemaMap(pointer, key string, value interface{}, check func(entry string, v interface{})) {
+	obj, ok := value.(map[string]interface{})
+	if !ok {
+		c.fail(pointer, "%s must be an object, got %s", key, metaTypeName(value))
+		return
+	}
+	for name, v := range obj {
+		check(pointer+"/"+escapePointerToken(name), v)
+	}
+}
+
+// schemaArray checks an array of schemas, which the meta-schema wants
+// non-empty for some keywords.
+func (c *metaSchemaChecker) schemaArray(pointer, key string, list []interface{}, nonEmpty bool) {
+	if nonEmpty && len(list) == 0 {
+		c.fail(pointer, "%s must not be empty", key)
+	}
+	for i, item := range list {
+		c.schema(pointer+"/"+strconv.Itoa(i), item)
+	}
+}
+
+// metaNumber returns a number decoded by checkMetaSchema as a float64.
+func metaNumber(value interface{}) (float64, bool) {
+	n, ok := value.(json.Number)
+	if !ok {
+		return 0, false
+	}
+	f, err := n.Float64()
+	return f, err == nil
+}
+
+// metaTypeName names the JSON type of a value decoded by checkMetaSchema.
+func metaTypeName(value interface{}) string {
+	if _, ok := value.(json.Number); ok {
+		return "number"
+	}
+	return jsonTypeName(value)
+}
+
+// compactJSON renders a decoded value for an error message.
+func compactJSON(value interface{}) string {
+	data, _ := json.Marshal(value)
+	return string(data)
+}
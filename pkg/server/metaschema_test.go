@@ -0,0 +1,67 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCheckMetaSchema(t *testing.T) {
+	upload := `{
+  "title": "User",
+  "type": "object",
+  "properties": {
+    "age": {"type": "integer", "minimum": "18"},
+    "name": {"type": "text", "maxLength": -1},
+    "tags": {"type": "array", "items": [{"type": "string"}]}
+  },
+  "required": ["name", "name"]
+}`
+	want := schemaErrors{
+		{Pointer: "/properties/age/minimum", Line: 5, Column: 43, Message: "minimum must be a number, got string"},
+		{Pointer: "/properties/name/type", Line: 6, Column: 22, Message: `unknown type "text", expected one of array, boolean, integer, null, number, object, string`},
+		{Pointer: "/properties/name/maxLength", Line: 6, Column: 43, Message: "maxLength must be a non-negative integer, got -1"},
+		{Pointer: "/required/1", Line: 9, Column: 24, Message: `required lists "name" more than once`},
+	}
+	err := checkMetaSchema([]byte(upload))
+	if !reflect.DeepEqual(err, want) {
+		t.Errorf("checkMetaSchema returned wrong errors:\ngot  %v\nwant %v", err, want)
+	}
+
+	// 2020-12 only takes a single schema for items, draft-07 also an array.
+	draft := func(uri string) string {
+		return `{"$schema": "` + uri + `", ` + upload[1:]
+	}
+	if errs, _ := checkMetaSchema([]byte(draft("https://json-schema.org/draft/2020-12/schema"))).(schemaErrors); len(errs) != len(want)+1 {
+		t.Errorf("2020-12 accepted an items array: got %v", errs)
+	}
+	if errs, _ := checkMetaSchema([]byte(draft("http://json-schema.org/draft-07/schema#"))).(schemaErrors); len(errs) != len(want) {
+		t.Errorf("draft-07 rejected an items array: got %v", errs)
+	}
+	if err := checkMetaSchema([]byte(draft("http://json-schema.org/draft-04/schema#"))); err == nil || !strings.Contains(err.Error(), "unsupported $schema") {
+		t.Errorf("unsupported draft was accepted: got %v", err)
+	}
+	if err := checkMetaSchema([]byte(`{"title": "Ok", "x-id-strategy": "uuid", "properties": {"a": true}}`)); err != nil {
+		t.Errorf("valid schema was rejected: %v", err)
+	}
+}
+
+func TestUploadMetaSchemaErrors(t *testing.T) {
+	srv := NewServer()
+	rr := performRequest(t, srv.uploadHandler, http.MethodPost, "/upload", []byte(`{"title": "User", "type": "object", "required": "id"}`))
+	if status := rr.Code; status != http.StatusBadRequest {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
+	}
+	var body struct {
+		Code    string        `json:"code"`
+		Details []schemaError `json:"details"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("could not decode response: %v", err)
+	}
+	if body.Code != "invalid_schema" || len(body.Details) != 1 || body.Details[0].Pointer != "/required" || body.Details[0].Column != 49 {
+		t.Errorf("upload returned wrong error: got %s", rr.Body.String())
+	}
+}
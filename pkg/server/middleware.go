@@ -0,0 +1,351 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"html/template"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// acceptsJSON reports whether an Accept header value permits a JSON
+// response. A missing header accepts anything.
+func acceptsJSON(accept string) bool {
+	if strings.TrimSpace(accept) == "" {
+		return true
+	}
+	for _, part := range strings.Split(accept, ",") {
+		params := strings.Split(part, ";")
+		mediaType := strings.ToLower(strings.TrimSpace(params[0]))
+		// Media ranges with q=0 are explicitly not acceptable.
+		rejected := false
+		for _, param := range params[1:] {
+			name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if strings.EqualFold(name, "q") {
+				if q, err := strconv.ParseFloat(value, 64); err == nil && q == 0 {
+					rejected = true
+				}
+			}
+		}
+		if rejected {
+			continue
+		}
+		switch {
+		case mediaType == "*/*", mediaType == "application/*", mediaType == "application/json", mediaType == ndjsonContentType:
+			return true
+		case strings.HasPrefix(mediaType, "application/") && strings.HasSuffix(mediaType, "+json"):
+			return true
+		}
+	}
+	return false
+}
+
+// documentPaths are the routes serving the API's documentation and specs,
+// which have media types of their own and are served whatever the Accept
+// header says.
+var documentPaths = []string{"/docs", "/openapi.json", "/openapi.yaml", "/postman.json", "/schema.graphql"}
+
+// requireJSONAccept responds with 406 Not Acceptable to requests that
+// accept neither JSON nor one of the formats withRenderings produces.
+func (s *Server) requireJSONAccept(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, _ := strings.CutPrefix(r.URL.Path, s.basePath)
+		if accept := r.Header.Get("Accept"); !containsString(documentPaths, path) && !acceptsJSON(accept) && preferredFormat(accept) == "" {
+			writeError(w, http.StatusNotAcceptable, "Not Acceptable: this server produces application/json, application/xml, application/yaml and text/csv")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// responseDelay picks the artificial delay for one request: base plus a
+// uniformly random offset in [-jitter, +jitter], never negative.
+func responseDelay(base, jitter time.Duration) time.Duration {
+	delay := base
+	if jitter > 0 {
+		delay += time.Duration(rand.Int63n(int64(2*jitter)+1)) - jitter
+	}
+	if delay < 0 {
+		return 0
+	}
+	return delay
+}
+
+// withLatency delays every response by responseDelay(base, jitter). The
+// wait is abandoned if the client goes away.
+func withLatency(base, jitter time.Duration, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if delay := responseDelay(base, jitter); delay > 0 {
+			timer := time.NewTimer(delay)
+			select {
+			case <-timer.C:
+			case <-r.Context().Done():
+				timer.Stop()
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// withBasePath serves next under prefix, which is stripped from the path
+// before next sees it. Paths outside prefix are not found.
+func withBasePath(prefix string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rest, ok := strings.CutPrefix(r.URL.Path, prefix)
+		if !ok || (rest != "" && rest[0] != '/') {
+			writeNotFound(w, r)
+			return
+		}
+		if rest == "" {
+			rest = "/"
+		}
+		stripped := new(http.Request)
+		*stripped = *r
+		stripped.URL = new(url.URL)
+		*stripped.URL = *r.URL
+		stripped.URL.Path = rest
+		stripped.URL.RawPath = ""
+		next.ServeHTTP(w, stripped)
+	})
+}
+
+// withTimeout answers 503 with an Error body when next has not started its
+// response within timeout. next's request context is cancelled at the
+// deadline, which also cuts short any -latency wait. Writes pass straight
+// through, so streamed lists are flushed as they go; a response already
+// under way at the deadline is left to finish.
+func withTimeout(timeout time.Duration, next http.Handler) http.Handler {
+	body, _ := json.Marshal(Error{
+		Code:    "request_timeout",
+		Message: "Request exceeded the " + timeout.String() + " timeout",
+	})
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		tw := &timeoutWriter{w: w, header: make(http.Header)}
+		done := make(chan struct{})
+		panicked := make(chan interface{}, 1)
+		go func() {
+			defer func() {
+				if p := recover(); p != nil {
+					panicked <- p
+				}
+			}()
+			next.ServeHTTP(tw, r.WithContext(ctx))
+			close(done)
+		}()
+		select {
+		case <-done:
+			return
+		case p := <-panicked:
+			panic(p)
+		case <-ctx.Done():
+		}
+		tw.mu.Lock()
+		started := tw.started
+		if !started {
+			tw.timedOut = true
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write(append(body, '\n'))
+		}
+		tw.mu.Unlock()
+		if started {
+			select {
+			case <-done:
+			case p := <-panicked:
+				panic(p)
+			}
+		}
+	})
+}
+
+// timeoutWriter passes a response through to w once it starts. Until then
+// headers are kept apart from w's, so a handler still running after
+// withTimeout has answered cannot touch them; its writes are discarded.
+type timeoutWriter struct {
+	w        http.ResponseWriter
+	header   http.Header
+	mu       sync.Mutex
+	started  bool
+	timedOut bool
+}
+
+func (tw *timeoutWriter) Header() http.Header { return tw.header }
+
+func (tw *timeoutWriter) WriteHeader(status int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	tw.start(status)
+}
+
+func (tw *timeoutWriter) Write(p []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	tw.start(http.StatusOK)
+	return tw.w.Write(p)
+}
+
+func (tw *timeoutWriter) Flush() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return
+	}
+	tw.start(http.StatusOK)
+	if f, ok := tw.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// start copies the headers to w and writes status, unless the response
+// has started or timed out. tw.mu must be held.
+func (tw *timeoutWriter) start(status int) {
+	if tw.started || tw.timedOut {
+		return
+	}
+	tw.started = true
+	for key, values := range tw.header {
+		tw.w.Header()[key] = values
+	}
+	tw.w.WriteHeader(status)
+}
+
+// wantsHTML reports whether an Accept header comes from a browser, i.e.
+// explicitly accepts text/html.
+func wantsHTML(accept string) bool {
+	for _, part := range strings.Split(accept, ",") {
+		params := strings.Split(part, ";")
+		if !strings.EqualFold(strings.TrimSpace(params[0]), "text/html") {
+			continue
+		}
+		for _, param := range params[1:] {
+			name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if strings.EqualFold(name, "q") {
+				if q, err := strconv.ParseFloat(value, 64); err == nil && q == 0 {
+					return false
+				}
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// errorPage renders a minimal HTML description of an error response.
+var errorPage = template.Must(template.New("error").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>{{.Status}} {{.StatusText}}</title></head>
+<body>
+<h1>{{.Status}} {{.StatusText}}</h1>
+<p>{{.Message}}</p>
+<p><a href="/">Back to the API index</a></p>
+</body>
+</html>
+`))
+
+// htmlErrorPages replaces error responses with an HTML page for browser
+// clients. Successful responses and clients that do not ask for HTML are
+// passed through untouched.
+func htmlErrorPages(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !wantsHTML(r.Header.Get("Accept")) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ew := &errorPageWriter{ResponseWriter: w}
+		next.ServeHTTP(ew, r)
+		if ew.status < 400 {
+			return
+		}
+
+		message := strings.TrimSpace(ew.body.String())
+		var decoded struct {
+			Message string `json:"message"`
+			Error   string `json:"error"`
+		}
+		if json.Unmarshal(ew.body.Bytes(), &decoded) == nil {
+			if decoded.Message != "" {
+				message = decoded.Message
+			} else if decoded.Error != "" {
+				message = decoded.Error
+			}
+		}
+
+		header := w.Header()
+		header.Del("Content-Length")
+		header.Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(ew.status)
+		errorPage.Execute(w, map[string]interface{}{
+			"Status":     ew.status,
+			"StatusText": http.StatusText(ew.status),
+			"Message":    message,
+		})
+	})
+}
+
+// errorPageWriter holds back the body of error responses so htmlErrorPages
+// can replace it.
+type errorPageWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (e *errorPageWriter) WriteHeader(status int) {
+	if e.status != 0 {
+		return
+	}
+	e.status = status
+	if status < 400 {
+		e.ResponseWriter.WriteHeader(status)
+	}
+}
+
+func (e *errorPageWriter) Write(p []byte) (int, error) {
+	if e.status == 0 {
+		e.WriteHeader(http.StatusOK)
+	}
+	if e.status >= 400 {
+		return e.body.Write(p)
+	}
+	return e.ResponseWriter.Write(p)
+}
+
+// Flush lets streaming handlers flush successful responses.
+func (e *errorPageWriter) Flush() {
+	if f, ok := e.ResponseWriter.(http.Flusher); ok && e.status < 400 {
+		f.Flush()
+	}
+}
+
+// heldWriter holds back a whole response so a middleware can rewrite it
+// before sending it on.
+type heldWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (h *heldWriter) WriteHeader(status int) {
+	if h.status == 0 {
+		h.status = status
+	}
+}
+
+func (h *heldWriter) Write(p []byte) (int, error) {
+	if h.status == 0 {
+		h.status = http.StatusOK
+	}
+	return h.body.Write(p)
+}
@@ -0,0 +1,213 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAcceptsJSON(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   bool
+	}{
+		{"", true},
+		{"application/json", true},
+		{"*/*", true},
+		{"application/*", true},
+		{"application/problem+json", true},
+		{"application/x-ndjson", true},
+		{"text/html", false},
+		{"text/html, application/xhtml+xml", false},
+		{"text/html, */*;q=0.8", true},
+		{"application/json;q=0, text/html", false},
+		{"TEXT/HTML, Application/JSON", true},
+	}
+	for _, tt := range tests {
+		if got := acceptsJSON(tt.accept); got != tt.want {
+			t.Errorf("acceptsJSON(%q) = %v, want %v", tt.accept, got, tt.want)
+		}
+	}
+}
+
+func TestRequireJSONAccept(t *testing.T) {
+	srv := NewServer()
+	handler := srv.requireJSONAccept(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	t.Run("Rejects HTML", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/users", nil)
+		req.Header.Set("Accept", "text/html")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if status := rr.Code; status != http.StatusNotAcceptable {
+			t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusNotAcceptable)
+		}
+	})
+
+	t.Run("Serves Docs", func(t *testing.T) {
+		for _, path := range []string{"/docs", "/schema.graphql"} {
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			req.Header.Set("Accept", "text/html")
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+			if status := rr.Code; status != http.StatusOK {
+				t.Errorf("%s: handler returned wrong status code: got %v want %v", path, status, http.StatusOK)
+			}
+		}
+	})
+
+	t.Run("Allows JSON", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/users", nil)
+		req.Header.Set("Accept", "application/json")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if status := rr.Code; status != http.StatusOK {
+			t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+		}
+	})
+}
+
+func TestResponseDelay(t *testing.T) {
+	if got := responseDelay(200*time.Millisecond, 0); got != 200*time.Millisecond {
+		t.Errorf("responseDelay without jitter = %v, want %v", got, 200*time.Millisecond)
+	}
+
+	base, jitter := 200*time.Millisecond, 100*time.Millisecond
+	varied := false
+	first := responseDelay(base, jitter)
+	for i := 0; i < 1000; i++ {
+		got := responseDelay(base, jitter)
+		if got < base-jitter || got > base+jitter {
+			t.Fatalf("responseDelay = %v, want within %v±%v", got, base, jitter)
+		}
+		if got != first {
+			varied = true
+		}
+	}
+	if !varied {
+		t.Errorf("responseDelay never varied with jitter set")
+	}
+
+	for i := 0; i < 100; i++ {
+		if got := responseDelay(0, 50*time.Millisecond); got < 0 {
+			t.Fatalf("responseDelay returned negative delay %v", got)
+		}
+	}
+}
+
+func TestWithLatency(t *testing.T) {
+	handler := withLatency(20*time.Millisecond, 0, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	start := time.Now()
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/users", nil))
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("response was not delayed: took %v", elapsed)
+	}
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+}
+
+func TestWithTimeout(t *testing.T) {
+	srv := NewServer()
+	slow := withLatency(time.Second, 0, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	handler := withTimeout(20*time.Millisecond, slow)
+
+	t.Run("Slow request times out", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/users", nil))
+		if status := rr.Code; status != http.StatusServiceUnavailable {
+			t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusServiceUnavailable)
+		}
+		var body Error
+		if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil || body.Code != "request_timeout" {
+			t.Errorf("timeout body is not an Error: %v (%v)", rr.Body.String(), err)
+		}
+		if ct := rr.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("timeout response has wrong Content-Type: %v", ct)
+		}
+	})
+
+	t.Run("Stream is flushed through", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		stream := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", ndjsonContentType)
+			w.Write([]byte("{\"id\":1}\n"))
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+			w.Write([]byte("{\"id\":2}\n"))
+		})
+		withTimeout(20*time.Millisecond, stream).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/users", nil))
+		if rr.Code != http.StatusOK || !rr.Flushed || rr.Body.String() != "{\"id\":1}\n{\"id\":2}\n" {
+			t.Errorf("stream was not passed through: %v %v %q", rr.Code, rr.Flushed, rr.Body.String())
+		}
+		if ct := rr.Header().Get("Content-Type"); ct != ndjsonContentType {
+			t.Errorf("stream has wrong Content-Type: %v", ct)
+		}
+	})
+
+	t.Run("Fast request completes", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		withTimeout(time.Second, http.HandlerFunc(srv.rootHandler)).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+		if status := rr.Code; status != http.StatusOK {
+			t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+		}
+	})
+}
+
+func TestHTMLErrorPages(t *testing.T) {
+	handler := htmlErrorPages(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/ok" {
+			w.Write([]byte(`{"ok":true}`))
+			return
+		}
+		http.Error(w, "No <such> thing", http.StatusNotFound)
+	}))
+	browserAccept := "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8"
+
+	t.Run("Browser Gets HTML", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/missing", nil)
+		req.Header.Set("Accept", browserAccept)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if status := rr.Code; status != http.StatusNotFound {
+			t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusNotFound)
+		}
+		if ct := rr.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+			t.Errorf("handler returned wrong content type: got %v", ct)
+		}
+		body := rr.Body.String()
+		if !strings.Contains(body, "404 Not Found") || !strings.Contains(body, "No &lt;such&gt; thing") {
+			t.Errorf("handler returned unexpected page: got %v", body)
+		}
+	})
+
+	t.Run("JSON Client Unaffected", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/missing", nil)
+		req.Header.Set("Accept", "application/json")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if strings.Contains(rr.Body.String(), "<html>") {
+			t.Errorf("JSON client received an HTML page: got %v", rr.Body.String())
+		}
+	})
+
+	t.Run("Success Unaffected", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/ok", nil)
+		req.Header.Set("Accept", browserAccept)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if got := rr.Body.String(); got != `{"ok":true}` {
+			t.Errorf("successful response was altered: got %v", got)
+		}
+	})
+}
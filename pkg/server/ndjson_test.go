@@ -0,0 +1,87 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNDJSONList(t *testing.T) {
+	srv := NewServer()
+	srv.currentSchema = createSampleSchema()
+	srv.store = newRecordStore()
+
+	req := httptest.NewRequest(http.MethodGet, "/users", nil)
+	req.Header.Set("Accept", "application/x-ndjson")
+	rr := httptest.NewRecorder()
+	srv.catchAllHandler(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != ndjsonContentType {
+		t.Errorf("handler returned wrong content type: got %v want %v", ct, ndjsonContentType)
+	}
+	if !rr.Flushed {
+		t.Errorf("handler did not flush the stream")
+	}
+	lines := strings.Split(strings.TrimSpace(rr.Body.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("handler returned wrong number of lines: got %v want %v", len(lines), 3)
+	}
+	for i, line := range lines {
+		var obj map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &obj); err != nil {
+			t.Errorf("line %d is not a JSON object: %v", i, line)
+		}
+	}
+}
+
+func TestWantsNDJSON(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   bool
+	}{
+		{"application/x-ndjson", true},
+		{"application/json, application/ndjson;q=0.9", true},
+		{"application/json", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/users", nil)
+		req.Header.Set("Accept", tt.accept)
+		if got := wantsNDJSON(req); got != tt.want {
+			t.Errorf("wantsNDJSON(%q) = %v, want %v", tt.accept, got, tt.want)
+		}
+	}
+}
+
+func TestNDJSONStreamCount(t *testing.T) {
+	srv := NewServer()
+	srv.currentSchema = createSampleSchema()
+	srv.store = newRecordStore()
+
+	rr := performRequest(t, srv.catchAllHandler, http.MethodGet, "/users?format=ndjson&count=5000&fields=id", nil)
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+	lines := strings.Split(strings.TrimSpace(rr.Body.String()), "\n")
+	if len(lines) != 5000 || lines[4999] != `{"id":5000}` || rr.Header().Get("X-Total-Count") != "5000" {
+		t.Errorf("handler streamed unexpected records: got %d lines ending %v", len(lines), lines[len(lines)-1])
+	}
+
+	for _, path := range []string{"/users?format=ndjson&count=-1", "/users?format=ndjson&count=10&sort=name", "/users?format=ndjson&count=10&page=2"} {
+		if rr := performRequest(t, srv.catchAllHandler, http.MethodGet, path, nil); rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: handler returned wrong status code: got %v want %v", path, rr.Code, http.StatusBadRequest)
+		}
+	}
+
+	// Stored records stream as they are, whatever the count.
+	srv.store.create(map[string]interface{}{"name": "alice", "email": "a@example.com"}, true)
+	rr = performRequest(t, srv.catchAllHandler, http.MethodGet, "/users?format=ndjson&count=100", nil)
+	if lines := strings.Split(strings.TrimSpace(rr.Body.String()), "\n"); len(lines) != 1 {
+		t.Errorf("handler returned wrong number of lines: got %v want %v", len(lines), 1)
+	}
+}
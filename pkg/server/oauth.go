@@ -0,0 +1,343 @@
+package server
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Routes of the mock identity provider.
+const (
+	tokenPath     = "/oauth/token"
+	jwksPath      = "/.well-known/jwks.json"
+	discoveryPath = "/.well-known/openid-configuration"
+)
+
+// tokenLifetime is how long issued tokens are valid.
+const tokenLifetime = time.Hour
+
+// tokenAudience is the aud claim of access tokens.
+const tokenAudience = "schema2api"
+
+// Values of the typ header: access tokens are typed per RFC 9068, so an ID
+// token cannot be presented in their place.
+const (
+	accessTokenType = "at+jwt"
+	idTokenType     = "JWT"
+)
+
+// newSigningKey generates the RSA key the Server signs tokens with. It
+// lives as long as the Server, so tokens outlast a reset.
+func (s *Server) newSigningKey() error {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return fmt.Errorf("could not generate the token signing key: %v", err)
+	}
+	sum := sha256.Sum256(key.N.Bytes())
+	s.signingKey, s.signingKeyID = key, base64.RawURLEncoding.EncodeToString(sum[:8])
+	return nil
+}
+
+// isIdentityPath reports whether path is served by the identity provider,
+// which clients reach before they hold a token.
+func (s *Server) isIdentityPath(path string) bool {
+	return path == s.route(tokenPath) || path == s.route(jwksPath) || path == s.route(discoveryPath)
+}
+
+// signJWT returns claims as an RS256-signed JWT of type typ.
+func (s *Server) signJWT(typ string, claims map[string]interface{}) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": typ, "kid": s.signingKeyID})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, s.signingKey, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// verifyJWT returns the claims of an access token this server issued for
+// r's issuer, or an error if the signature does not match, the token was
+// issued by or for someone else, is not an access token or has expired.
+func (s *Server) verifyJWT(r *http.Request, token string) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("the token is not a JWT")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Typ string `json:"typ"`
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err == nil {
+		err = json.Unmarshal(raw, &header)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("the token header is malformed")
+	}
+	if header.Alg != "RS256" {
+		return nil, fmt.Errorf("the token is signed with %q, expected RS256", header.Alg)
+	}
+	if header.Typ != accessTokenType {
+		return nil, fmt.Errorf("the token is not an access token")
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("the token signature is malformed")
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if rsa.VerifyPKCS1v15(&s.signingKey.PublicKey, crypto.SHA256, digest[:], signature) != nil {
+		return nil, fmt.Errorf("the token signature is invalid")
+	}
+	var claims map[string]interface{}
+	raw, err = base64.RawURLEncoding.DecodeString(parts[1])
+	if err == nil {
+		err = json.Unmarshal(raw, &claims)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("the token claims are malformed")
+	}
+	if iss := s.issuer(r); claims["iss"] != iss {
+		return nil, fmt.Errorf("the token was not issued by %s", iss)
+	}
+	if claims["aud"] != tokenAudience {
+		return nil, fmt.Errorf("the token is not for audience %s", tokenAudience)
+	}
+	if exp, ok := claims["exp"].(float64); !ok || time.Now().Unix() >= int64(exp) {
+		return nil, fmt.Errorf("the token has expired")
+	}
+	return claims, nil
+}
+
+// tokenAccess returns the access level a verified token's scope grants.
+func tokenAccess(claims map[string]interface{}) string {
+	scope, _ := claims["scope"].(string)
+	for _, s := range strings.Fields(scope) {
+		if s == accessWrite {
+			return accessWrite
+		}
+	}
+	return accessRead
+}
+
+// tokenRoles returns the roles a verified token grants, from its roles
+// claim or a single role claim.
+func tokenRoles(claims map[string]interface{}) []string {
+	var roles []string
+	switch v := claims["roles"].(type) {
+	case []interface{}:
+		for _, role := range v {
+			if role, ok := role.(string); ok {
+				roles = append(roles, role)
+			}
+		}
+	case string:
+		roles = strings.Fields(v)
+	}
+	if role, ok := claims["role"].(string); ok {
+		roles = append(roles, role)
+	}
+	return roles
+}
+
+// issuer names this server in the tokens it issues, as the request
+// reached it.
+func (s *Server) issuer(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + s.basePath
+}
+
+// writeOAuthError answers a token request with an RFC 6749 error body.
+func writeOAuthError(w http.ResponseWriter, status int, code, description string) {
+	if status == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", `Basic realm="schema2api"`)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": code, "error_description": description})
+}
+
+// tokenHandler serves POST /oauth/token for the client_credentials and
+// password grants. The client secret or password must be one of the API
+// keys, whose access becomes the token's scope and whose roles its roles
+// claim; while there are none, any credentials get a write token with the
+// roles of the roles parameter. A scope of read narrows a write key, and
+// openid adds an ID token.
+func (s *Server) tokenHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w, "Only POST allowed", http.MethodPost)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "The body must be application/x-www-form-urlencoded")
+		return
+	}
+	clientID, clientSecret, basic := r.BasicAuth()
+	if !basic {
+		clientID, clientSecret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+
+	var subject, secret string
+	grant := r.PostForm.Get("grant_type")
+	switch grant {
+	case "client_credentials":
+		if clientID == "" || clientSecret == "" {
+			writeOAuthError(w, http.StatusUnauthorized, "invalid_client", "client_id and client_secret are required")
+			return
+		}
+		subject, secret = clientID, clientSecret
+	case "password":
+		subject, secret = r.PostForm.Get("username"), r.PostForm.Get("password")
+		if subject == "" || secret == "" {
+			writeOAuthError(w, http.StatusBadRequest, "invalid_request", "username and password are required")
+			return
+		}
+	case "":
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "grant_type is required")
+		return
+	default:
+		writeOAuthError(w, http.StatusBadRequest, "unsupported_grant_type", fmt.Sprintf("grant_type %q is not supported; use client_credentials or password", grant))
+		return
+	}
+
+	client := apiKey{Access: accessWrite, Roles: splitRoles(r.PostForm.Get("roles"), " ")}
+	if !s.apiKeys.empty() {
+		var ok bool
+		if client, ok = s.apiKeys.get(secret); !ok {
+			if grant == "password" {
+				writeOAuthError(w, http.StatusBadRequest, "invalid_grant", "The username or password is wrong")
+			} else {
+				writeOAuthError(w, http.StatusUnauthorized, "invalid_client", "The client credentials are wrong")
+			}
+			return
+		}
+	}
+	access := client.Access
+	openID := false
+	for _, requested := range strings.Fields(r.PostForm.Get("scope")) {
+		switch requested {
+		case accessRead:
+			access = accessRead
+		case "openid":
+			openID = true
+		case accessWrite:
+		default:
+			writeOAuthError(w, http.StatusBadRequest, "invalid_scope", fmt.Sprintf("scope %q is not supported; use read, write or openid", requested))
+			return
+		}
+	}
+	scope := access
+	if openID {
+		scope = "openid " + scope
+	}
+
+	now := time.Now()
+	claims := map[string]interface{}{
+		"iss":   s.issuer(r),
+		"sub":   subject,
+		"aud":   tokenAudience,
+		"iat":   now.Unix(),
+		"exp":   now.Add(tokenLifetime).Unix(),
+		"scope": scope,
+	}
+	if clientID != "" {
+		claims["client_id"] = clientID
+	}
+	if len(client.Roles) > 0 {
+		claims["roles"] = client.Roles
+	}
+	token, err := s.signJWT(accessTokenType, claims)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Could not sign the token: "+err.Error())
+		return
+	}
+	response := map[string]interface{}{
+		"access_token": token,
+		"token_type":   "Bearer",
+		"expires_in":   int(tokenLifetime.Seconds()),
+		"scope":        scope,
+	}
+	if openID {
+		audience := clientID
+		if audience == "" {
+			audience = tokenAudience
+		}
+		idToken, err := s.signJWT(idTokenType, map[string]interface{}{
+			"iss": claims["iss"],
+			"sub": subject,
+			"aud": audience,
+			"iat": claims["iat"],
+			"exp": claims["exp"],
+		})
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "Could not sign the token: "+err.Error())
+			return
+		}
+		response["id_token"] = idToken
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(response)
+}
+
+// jwksHandler serves the public key tokens are signed with as a JSON Web
+// Key Set.
+func (s *Server) jwksHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeMethodNotAllowed(w, "Only GET allowed", http.MethodGet, http.MethodHead)
+		return
+	}
+	key, kid := s.signingKey, s.signingKeyID
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"keys": []interface{}{map[string]string{
+			"kty": "RSA",
+			"use": "sig",
+			"alg": "RS256",
+			"kid": kid,
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}},
+	})
+}
+
+// discoveryHandler serves the OpenID Connect discovery document, so
+// clients can locate the token endpoint and keys from the issuer.
+func (s *Server) discoveryHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeMethodNotAllowed(w, "Only GET allowed", http.MethodGet, http.MethodHead)
+		return
+	}
+	iss := s.issuer(r)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"issuer":                                iss,
+		"token_endpoint":                        iss + tokenPath,
+		"jwks_uri":                              iss + jwksPath,
+		"grant_types_supported":                 []string{"client_credentials", "password"},
+		"token_endpoint_auth_methods_supported": []string{"client_secret_basic", "client_secret_post"},
+		"scopes_supported":                      []string{"openid", accessRead, accessWrite},
+		"response_types_supported":              []string{"token"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+	})
+}
@@ -0,0 +1,151 @@
+package server
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOAuthToken(t *testing.T) {
+	srv := NewServer(WithAuth("secret", "viewer:read"))
+	handler := srv.Handler()
+	srv.currentSchema = createSampleSchema()
+	srv.store = newRecordStore()
+	srv.registerSchema(srv.currentSchema)
+	srv.stores["user"] = srv.store
+
+	requestToken := func(form url.Values) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/oauth/token", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+	token := func(form url.Values) string {
+		t.Helper()
+		rr := requestToken(form)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("handler returned wrong status code: got %v want %v: %s", rr.Code, http.StatusOK, rr.Body.String())
+		}
+		var body struct {
+			AccessToken string `json:"access_token"`
+			TokenType   string `json:"token_type"`
+			ExpiresIn   int    `json:"expires_in"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil || body.TokenType != "Bearer" || body.ExpiresIn != 3600 {
+			t.Fatalf("wrong token response: %s", rr.Body.String())
+		}
+		return body.AccessToken
+	}
+	call := func(method, token string) int {
+		t.Helper()
+		req := httptest.NewRequest(method, "/users", strings.NewReader(`{"name": "Ada", "email": "ada@example.com"}`))
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	write := token(url.Values{"grant_type": {"client_credentials"}, "client_id": {"app"}, "client_secret": {"secret"}})
+	if status := call(http.MethodPost, write); status != http.StatusCreated {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusCreated)
+	}
+	read := token(url.Values{"grant_type": {"password"}, "username": {"ada"}, "password": {"viewer"}})
+	if status := call(http.MethodGet, read); status != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+	if status := call(http.MethodPost, read); status != http.StatusForbidden {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusForbidden)
+	}
+	narrowed := token(url.Values{"grant_type": {"client_credentials"}, "client_id": {"app"}, "client_secret": {"secret"}, "scope": {"read"}})
+	if status := call(http.MethodPost, narrowed); status != http.StatusForbidden {
+		t.Errorf("read scope: handler returned wrong status code: got %v want %v", status, http.StatusForbidden)
+	}
+
+	parts := strings.Split(write, ".")
+	if status := call(http.MethodGet, parts[0]+"."+parts[1]+"x."+parts[2]); status != http.StatusUnauthorized {
+		t.Errorf("tampered token: handler returned wrong status code: got %v want %v", status, http.StatusUnauthorized)
+	}
+	expired, err := srv.signJWT(accessTokenType, map[string]interface{}{"iss": "http://example.com", "aud": tokenAudience, "sub": "app", "scope": accessWrite, "exp": time.Now().Add(-time.Minute).Unix()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status := call(http.MethodGet, expired); status != http.StatusUnauthorized {
+		t.Errorf("expired token: handler returned wrong status code: got %v want %v", status, http.StatusUnauthorized)
+	}
+	rr := requestToken(url.Values{"grant_type": {"password"}, "username": {"ada"}, "password": {"secret"}, "scope": {"openid"}})
+	var openID struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &openID); err != nil || openID.IDToken == "" {
+		t.Fatalf("no ID token issued: %s", rr.Body.String())
+	}
+	if status := call(http.MethodGet, openID.IDToken); status != http.StatusUnauthorized {
+		t.Errorf("ID token: handler returned wrong status code: got %v want %v", status, http.StatusUnauthorized)
+	}
+
+	// Another Server signs with its own key.
+	other := NewServer(WithAuth("secret"))
+	req := httptest.NewRequest(http.MethodPost, "/oauth/token", strings.NewReader(url.Values{"grant_type": {"client_credentials"}, "client_id": {"app"}, "client_secret": {"secret"}}.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr = httptest.NewRecorder()
+	other.Handler().ServeHTTP(rr, req)
+	var foreign struct {
+		AccessToken string `json:"access_token"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &foreign)
+	if status := call(http.MethodGet, foreign.AccessToken); foreign.AccessToken == "" || status != http.StatusUnauthorized {
+		t.Errorf("token of another server: handler returned wrong status code: got %v want %v", status, http.StatusUnauthorized)
+	}
+
+	failures := []struct {
+		name   string
+		form   url.Values
+		status int
+		code   string
+	}{
+		{"Wrong Secret", url.Values{"grant_type": {"client_credentials"}, "client_id": {"app"}, "client_secret": {"guess"}}, http.StatusUnauthorized, "invalid_client"},
+		{"Wrong Password", url.Values{"grant_type": {"password"}, "username": {"ada"}, "password": {"guess"}}, http.StatusBadRequest, "invalid_grant"},
+		{"Unsupported Grant", url.Values{"grant_type": {"authorization_code"}}, http.StatusBadRequest, "unsupported_grant_type"},
+		{"Unknown Scope", url.Values{"grant_type": {"password"}, "username": {"ada"}, "password": {"secret"}, "scope": {"admin"}}, http.StatusBadRequest, "invalid_scope"},
+	}
+	for _, tc := range failures {
+		t.Run(tc.name, func(t *testing.T) {
+			rr := requestToken(tc.form)
+			var body map[string]string
+			json.Unmarshal(rr.Body.Bytes(), &body)
+			if rr.Code != tc.status || body["error"] != tc.code {
+				t.Errorf("got %v %s, want %v %s", rr.Code, rr.Body.String(), tc.status, tc.code)
+			}
+		})
+	}
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil))
+	var jwks struct {
+		Keys []map[string]string `json:"keys"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &jwks); err != nil || len(jwks.Keys) != 1 {
+		t.Fatalf("wrong key set: %s", rr.Body.String())
+	}
+	key, kid := srv.signingKey, srv.signingKeyID
+	n, _ := base64.RawURLEncoding.DecodeString(jwks.Keys[0]["n"])
+	if jwks.Keys[0]["kid"] != kid || new(big.Int).SetBytes(n).Cmp(key.N) != 0 {
+		t.Errorf("key set does not hold the signing key: %v", jwks.Keys[0])
+	}
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/.well-known/openid-configuration", nil))
+	var discovery map[string]interface{}
+	json.Unmarshal(rr.Body.Bytes(), &discovery)
+	if discovery["token_endpoint"] != "http://example.com/oauth/token" {
+		t.Errorf("wrong discovery document: %s", rr.Body.String())
+	}
+}
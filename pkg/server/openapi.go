@@ -0,0 +1,481 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// yamlContentType is the media type of /openapi.yaml.
+const yamlContentType = "application/yaml"
+
+// openAPISpec builds an OpenAPI 3.1 document describing the generated
+// routes of every uploaded schema. It is a plain map so the same document
+// can be serialized as JSON or YAML.
+func (s *Server) openAPISpec() map[string]interface{} {
+	s.stateMu.RLock()
+	defer s.stateMu.RUnlock()
+
+	paths := make(map[string]interface{})
+	components := map[string]interface{}{
+		"Error": problemComponent(),
+	}
+	if s.legacyErrors {
+		components["Error"] = errorComponent()
+	}
+	for _, key := range s.sortedSchemaKeys() {
+		schema := s.schemas[key]
+		components[schema.Title] = schemaObject(schema)
+		for path, item := range s.entityPaths(schema) {
+			paths[path] = item
+		}
+		for path, item := range s.childPaths(schema) {
+			paths[path] = item
+		}
+	}
+
+	spec := map[string]interface{}{
+		"openapi": "3.1.0",
+		"info": map[string]interface{}{
+			"title":       "schema2api",
+			"version":     "1.0.0",
+			"description": "Mock API generated from the uploaded JSON schemas.",
+		},
+		"servers":    []interface{}{map[string]interface{}{"url": s.route("/")}},
+		"paths":      paths,
+		"components": map[string]interface{}{"schemas": components},
+	}
+	if s.authRequired {
+		tokenScopes := map[string]interface{}{
+			accessRead:  "GET and HEAD requests",
+			accessWrite: "every request",
+		}
+		spec["components"].(map[string]interface{})["securitySchemes"] = map[string]interface{}{
+			"apiKey":     map[string]interface{}{"type": "apiKey", "in": "header", "name": apiKeyHeader},
+			"bearerAuth": map[string]interface{}{"type": "http", "scheme": "bearer", "bearerFormat": "JWT"},
+			"oauth2": map[string]interface{}{
+				"type": "oauth2",
+				"flows": map[string]interface{}{
+					"clientCredentials": map[string]interface{}{"tokenUrl": s.route(tokenPath), "scopes": tokenScopes},
+					"password":          map[string]interface{}{"tokenUrl": s.route(tokenPath), "scopes": tokenScopes},
+				},
+			},
+		}
+		spec["security"] = []interface{}{
+			map[string]interface{}{"apiKey": []interface{}{}},
+			map[string]interface{}{"bearerAuth": []interface{}{}},
+			map[string]interface{}{"oauth2": []interface{}{}},
+		}
+	}
+	return spec
+}
+
+// entityPaths returns the path items of one schema's routes, leaving out
+// operations its methods do not allow.
+func (s *Server) entityPaths(schema *Schema) map[string]interface{} {
+	collection := "/" + entityName(schema)
+	ref := schemaRef(schema.Title)
+	name := schema.Title
+
+	idType := "integer"
+	if !schema.integerIDs() {
+		idType = "string"
+	}
+
+	list := map[string]interface{}{}
+	if schema.allowsMethod(http.MethodGet) {
+		get := s.operation("list"+pluralize(name), "List "+entityName(schema), nil,
+			map[string]interface{}{"type": "array", "items": ref}, 400)
+		get["parameters"] = s.listParameters()
+		list["get"] = get
+	}
+	if schema.allowsMethod(http.MethodPost) {
+		post := s.operation("create"+name, "Create a "+strings.ToLower(name), ref, ref, 400, 409)
+		responses := post["responses"].(map[string]interface{})
+		responses["201"] = responses["200"]
+		delete(responses, "200")
+		list["post"] = post
+	}
+
+	item := map[string]interface{}{
+		"parameters": []interface{}{map[string]interface{}{
+			"name":     "id",
+			"in":       "path",
+			"required": true,
+			"schema":   map[string]interface{}{"type": idType},
+		}},
+	}
+	if schema.allowsMethod(http.MethodGet) {
+		item["get"] = s.operation("get"+name, "Get a "+strings.ToLower(name), nil, ref, 400, 404)
+	}
+	if schema.allowsMethod(http.MethodPut) {
+		statuses := []int{400, 412, 422}
+		if s.strictPut {
+			statuses = append(statuses, 404)
+		}
+		item["put"] = s.operation("update"+name, "Update a "+strings.ToLower(name), ref, ref, statuses...)
+	}
+	if schema.allowsMethod(http.MethodPatch) {
+		patch := s.operation("patch"+name, "Change some fields of a "+strings.ToLower(name), map[string]interface{}{"type": "object"},
+			ref, 400, 404, 412, 415, 422)
+		content := patch["requestBody"].(map[string]interface{})["content"].(map[string]interface{})
+		content[mergePatchContentType] = map[string]interface{}{"schema": map[string]interface{}{"type": "object"}}
+		content[jsonPatchContentType] = map[string]interface{}{"schema": map[string]interface{}{
+			"type": "array",
+			"items": map[string]interface{}{
+				"type":     "object",
+				"required": []string{"op", "path"},
+				"properties": map[string]interface{}{
+					"op":    map[string]interface{}{"enum": []string{"add", "remove", "replace", "move", "copy", "test"}},
+					"path":  map[string]interface{}{"type": "string"},
+					"from":  map[string]interface{}{"type": "string"},
+					"value": map[string]interface{}{},
+				},
+			},
+		}}
+		item["patch"] = patch
+	}
+	if schema.allowsMethod(http.MethodDelete) {
+		del := s.operation("delete"+name, "Delete a "+strings.ToLower(name), nil, nil, 400, 404, 412)
+		responses := del["responses"].(map[string]interface{})
+		responses["204"] = map[string]interface{}{"description": http.StatusText(http.StatusNoContent)}
+		delete(responses, "200")
+		item["delete"] = del
+	}
+
+	paths := make(map[string]interface{})
+	if len(list) > 0 {
+		paths[collection] = list
+	}
+	if len(item) > 1 {
+		paths[collection+"/{id}"] = item
+	}
+	if schema.allowsMethod(http.MethodGet) {
+		hit := map[string]interface{}{
+			"type":     "object",
+			"required": []string{"score", "matches", "record"},
+			"properties": map[string]interface{}{
+				"score": map[string]interface{}{"type": "integer"},
+				"matches": map[string]interface{}{"type": "array", "items": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"term":   map[string]interface{}{"type": "string"},
+						"field":  map[string]interface{}{"type": "string"},
+						"value":  map[string]interface{}{"type": "string"},
+						"offset": map[string]interface{}{"type": "integer"},
+					},
+				}},
+				"record": ref,
+			},
+		}
+		search := s.operation("search"+pluralize(name), "Search "+entityName(schema), nil,
+			map[string]interface{}{"type": "array", "items": hit}, 400)
+		search["parameters"] = s.listParameters()
+		paths[collection+"/"+searchSegment] = map[string]interface{}{"get": search}
+
+		count := s.operation("count"+pluralize(name), "Count "+entityName(schema), nil, map[string]interface{}{
+			"type":       "object",
+			"properties": map[string]interface{}{"count": map[string]interface{}{"type": "integer"}},
+		}, 400)
+		paths[collection+"/"+countSegment] = map[string]interface{}{"get": count}
+		agg := s.operation("aggregate"+pluralize(name), "Compute metrics over groups of "+entityName(schema), nil, map[string]interface{}{
+			"type": "array",
+			"items": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"group":   map[string]interface{}{"type": "object"},
+					"metrics": map[string]interface{}{"type": "object"},
+				},
+			},
+		}, 400)
+		agg["parameters"] = []interface{}{
+			map[string]interface{}{"name": groupByParam, "in": "query", "description": "Comma-separated properties to group by", "schema": map[string]interface{}{"type": "string"}},
+			map[string]interface{}{"name": metricParam, "in": "query", "description": "Comma-separated metrics: count, sum(field), avg(field), min(field) or max(field)", "schema": map[string]interface{}{"type": "string", "default": "count"}},
+		}
+		paths[collection+"/"+aggregateSegment] = map[string]interface{}{"get": agg}
+	}
+	bulk := map[string]interface{}{}
+	items := map[string]interface{}{"type": "array", "items": ref}
+	if schema.allowsMethod(http.MethodPost) {
+		bulk["post"] = s.bulkOperation("bulkCreate"+pluralize(name), "Create many "+entityName(schema), items, http.StatusCreated)
+	}
+	if schema.allowsMethod(http.MethodPatch) {
+		bulk["patch"] = s.bulkOperation("bulkPatch"+pluralize(name), "Change some fields of many "+entityName(schema), items, http.StatusOK)
+	}
+	if len(bulk) > 0 {
+		paths[collection+"/"+bulkSegment] = bulk
+	}
+	if schema.allowsMethod(http.MethodDelete) {
+		del := s.bulkOperation("bulkDelete"+pluralize(name), "Delete many "+entityName(schema), nil, http.StatusOK)
+		del["parameters"] = []interface{}{map[string]interface{}{
+			"name":        schema.idKey(),
+			"in":          "query",
+			"required":    true,
+			"description": "Comma-separated ids of the records to delete",
+			"schema":      map[string]interface{}{"type": "string"},
+		}}
+		if paths[collection] == nil {
+			paths[collection] = map[string]interface{}{}
+		}
+		paths[collection].(map[string]interface{})["delete"] = del
+	}
+	if s.softDelete && schema.allowsMethod(http.MethodDelete) {
+		paths[collection+"/{id}/"+restoreSegment] = map[string]interface{}{
+			"parameters": item["parameters"],
+			"post":       s.operation("restore"+name, "Restore a deleted "+strings.ToLower(name), nil, ref, 400, 404, 409),
+		}
+	}
+	return paths
+}
+
+// bulkOperation builds a bulk operation object, answering success when
+// every item succeeds and 207 with the per-item results when some fail.
+func (s *Server) bulkOperation(id, summary string, body interface{}, success int) map[string]interface{} {
+	result := map[string]interface{}{
+		"type":     "object",
+		"required": []string{"succeeded", "failed", "results"},
+		"properties": map[string]interface{}{
+			"succeeded": map[string]interface{}{"type": "integer"},
+			"failed":    map[string]interface{}{"type": "integer"},
+			"results": map[string]interface{}{"type": "array", "items": map[string]interface{}{
+				"type":     "object",
+				"required": []string{"index", "status"},
+				"properties": map[string]interface{}{
+					"index":  map[string]interface{}{"type": "integer"},
+					"id":     map[string]interface{}{},
+					"status": map[string]interface{}{"type": "integer"},
+					"record": map[string]interface{}{"type": "object"},
+					"error":  map[string]interface{}{"type": "object"},
+				},
+			}},
+		},
+	}
+	op := s.operation(id, summary, body, result, 400, 413)
+	responses := op["responses"].(map[string]interface{})
+	responses[strconv.Itoa(success)] = responses["200"]
+	responses["207"] = map[string]interface{}{"description": http.StatusText(http.StatusMultiStatus), "content": jsonContent(result)}
+	if success != http.StatusOK {
+		delete(responses, "200")
+	}
+	return op
+}
+
+// listParameters describes the sort, search, pagination and list size
+// query parameters of list routes, and includeDeleted under soft delete.
+// Filters are left out, as every property is one.
+func (s *Server) listParameters() []interface{} {
+	param := func(name, description string, schema map[string]interface{}) map[string]interface{} {
+		return map[string]interface{}{"name": name, "in": "query", "description": description, "schema": schema}
+	}
+	params := []interface{}{
+		param("page", "Page number, from 1", map[string]interface{}{"type": "integer", "minimum": 1}),
+		param("limit", "Records per page", map[string]interface{}{"type": "integer", "minimum": 1, "maximum": maxPageLimit, "default": defaultPageLimit}),
+		param("cursor", "Position from a Link header, instead of page", map[string]interface{}{"type": "string"}),
+		param("sort", "Comma-separated properties to sort by, each prefixed with - for descending", map[string]interface{}{"type": "string"}),
+		param(searchParam, "Words every listed record contains in one of its string fields, case-insensitively; ranks the list best match first", map[string]interface{}{"type": "string"}),
+		param(listSizeParam, "Number of generated records the list holds until the first write", map[string]interface{}{"type": "integer", "minimum": 0, "maximum": maxListSize}),
+	}
+	if s.softDelete {
+		params = append(params, param(includeDeletedParam, "List soft-deleted records too", map[string]interface{}{"type": "boolean"}))
+	}
+	return params
+}
+
+// operation builds an operation object whose 200 response is described by
+// result (callers answering another success status move it) and whose errorStatuses respond with an error body. body, if not nil,
+// is the JSON request body schema.
+func (s *Server) operation(id, summary string, body, result interface{}, errorStatuses ...int) map[string]interface{} {
+	op := map[string]interface{}{
+		"operationId": id,
+		"summary":     summary,
+	}
+	if body != nil {
+		op["requestBody"] = map[string]interface{}{"required": true, "content": jsonContent(body)}
+	}
+	responses := map[string]interface{}{
+		"200": map[string]interface{}{"description": http.StatusText(http.StatusOK), "content": jsonContent(result)},
+	}
+	for _, status := range errorStatuses {
+		responses[strconv.Itoa(status)] = map[string]interface{}{
+			"description": http.StatusText(status),
+			"content":     s.errorContent(),
+		}
+	}
+	op["responses"] = responses
+	return op
+}
+
+// jsonContent wraps a schema in an application/json content map.
+func jsonContent(schema interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"application/json": map[string]interface{}{"schema": schema},
+	}
+}
+
+// schemaRef references a component schema.
+func schemaRef(name string) map[string]interface{} {
+	return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+}
+
+// schemaObject converts an uploaded schema into a JSON Schema object.
+func schemaObject(schema *Schema) map[string]interface{} {
+	prop := Property{Type: schema.Type, Properties: schema.Properties, Required: schema.Required, Keywords: schema.Keywords}
+	if prop.Type == "" {
+		prop.Type = "object"
+	}
+	obj := propertySchema(prop)
+	obj["title"] = schema.Title
+	return obj
+}
+
+// propertySchema converts a property into a JSON Schema object, keeping the
+// standard keywords and dropping the generator's x- extensions.
+func propertySchema(prop Property) map[string]interface{} {
+	obj := make(map[string]interface{})
+	if prop.Type != "" {
+		obj["type"] = prop.Type
+	}
+	if prop.Format != "" {
+		obj["format"] = prop.Format
+	}
+	if len(prop.Enum) > 0 {
+		obj["enum"] = prop.Enum
+	}
+	if len(prop.Properties) > 0 {
+		properties := make(map[string]interface{}, len(prop.Properties))
+		for name, child := range prop.Properties {
+			properties[name] = propertySchema(child)
+		}
+		obj["properties"] = properties
+	}
+	if len(prop.Required) > 0 {
+		obj["required"] = prop.Required
+	}
+	if prop.Items != nil {
+		obj["items"] = propertySchema(*prop.Items)
+	}
+	if len(prop.PrefixItems) > 0 {
+		prefix := make([]interface{}, len(prop.PrefixItems))
+		for i, item := range prop.PrefixItems {
+			prefix[i] = propertySchema(item)
+		}
+		obj["prefixItems"] = prefix
+	}
+	if prop.MinItems != nil {
+		obj["minItems"] = *prop.MinItems
+	}
+	if prop.MaxItems != nil {
+		obj["maxItems"] = *prop.MaxItems
+	}
+	if prop.Const != nil {
+		obj["const"] = prop.Const
+	}
+	if prop.Default != nil {
+		obj["default"] = prop.Default
+	}
+	if len(prop.Examples) > 0 {
+		obj["examples"] = prop.Examples
+	}
+	for keyword, bound := range map[string]*float64{
+		"minimum":          prop.Minimum,
+		"maximum":          prop.Maximum,
+		"exclusiveMinimum": prop.ExclusiveMinimum,
+		"exclusiveMaximum": prop.ExclusiveMaximum,
+		"multipleOf":       prop.MultipleOf,
+	} {
+		if bound != nil {
+			obj[keyword] = *bound
+		}
+	}
+	if prop.MinLength != nil {
+		obj["minLength"] = *prop.MinLength
+	}
+	if prop.MaxLength != nil {
+		obj["maxLength"] = *prop.MaxLength
+	}
+	if prop.Pattern != "" {
+		obj["pattern"] = prop.Pattern
+	}
+	if prop.Nullable && prop.Type != "" {
+		obj["type"] = []string{prop.Type, "null"}
+	}
+	if prop.UniqueItems {
+		obj["uniqueItems"] = true
+	}
+	if prop.ReadOnly {
+		obj["readOnly"] = true
+	}
+	if prop.WriteOnly {
+		obj["writeOnly"] = true
+	}
+	for keyword, count := range map[string]*int{
+		"minContains":   prop.MinContains,
+		"maxContains":   prop.MaxContains,
+		"minProperties": prop.MinProperties,
+		"maxProperties": prop.MaxProperties,
+	} {
+		if count != nil {
+			obj[keyword] = *count
+		}
+	}
+	for keyword, sub := range map[string]*Property{
+		"contains":             prop.Contains,
+		"additionalProperties": prop.AdditionalProperties,
+		"not":                  prop.Not,
+		"if":                   prop.If,
+		"then":                 prop.Then,
+		"else":                 prop.Else,
+	} {
+		if sub != nil {
+			obj[keyword] = propertySchema(*sub)
+		}
+	}
+	for keyword, subs := range map[string][]Property{"allOf": prop.AllOf, "anyOf": prop.AnyOf, "oneOf": prop.OneOf} {
+		if len(subs) > 0 {
+			list := make([]interface{}, len(subs))
+			for i, sub := range subs {
+				list[i] = propertySchema(sub)
+			}
+			obj[keyword] = list
+		}
+	}
+	for keyword, subs := range map[string]map[string]Property{"patternProperties": prop.PatternProperties, "dependentSchemas": prop.DependentSchemas} {
+		if len(subs) > 0 {
+			named := make(map[string]interface{}, len(subs))
+			for name, sub := range subs {
+				named[name] = propertySchema(sub)
+			}
+			obj[keyword] = named
+		}
+	}
+	if len(prop.DependentRequired) > 0 {
+		obj["dependentRequired"] = prop.DependentRequired
+	}
+	return obj
+}
+
+// openAPIHandler serves the OpenAPI document at GET /openapi.json and, in
+// YAML, at GET /openapi.yaml.
+func (s *Server) openAPIHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w, "Only GET allowed", http.MethodGet)
+		return
+	}
+	spec := s.openAPISpec()
+	if r.URL.Path == "/openapi.yaml" {
+		out, err := marshalYAML(spec)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "Could not render the OpenAPI document: "+err.Error())
+			return
+		}
+		w.Header().Set("Content-Type", yamlContentType)
+		w.Write(out)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(spec); err != nil {
+		log.Println("Error encoding response:", err)
+	}
+}
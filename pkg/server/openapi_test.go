@@ -0,0 +1,70 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestOpenAPISpec(t *testing.T) {
+	srv := NewServer()
+	schema := createSampleSchema()
+	schema.Methods = []string{"GET", "POST"}
+	schema.Properties["tags"] = Property{Type: "array", Items: &Property{Type: "string"}, GenMode: genSequential}
+	schemaJSON, _ := json.Marshal(schema)
+	if rr := performRequest(t, srv.uploadHandler, http.MethodPost, "/upload", schemaJSON); rr.Code != http.StatusOK {
+		t.Fatalf("upload failed: %v", rr.Body.String())
+	}
+
+	rr := performRequest(t, srv.openAPIHandler, http.MethodGet, "/openapi.json", nil)
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+	var spec struct {
+		OpenAPI    string                                       `json:"openapi"`
+		Paths      map[string]map[string]json.RawMessage        `json:"paths"`
+		Components map[string]map[string]map[string]interface{} `json:"components"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &spec); err != nil {
+		t.Fatalf("could not decode spec: %v", err)
+	}
+	if spec.OpenAPI != "3.1.0" {
+		t.Errorf("spec has wrong version: got %v", spec.OpenAPI)
+	}
+	if _, ok := spec.Paths["/users"]["post"]; !ok {
+		t.Errorf("spec is missing POST /users: got %v", spec.Paths)
+	}
+	if _, ok := spec.Paths["/users/{id}"]["delete"]; ok {
+		t.Errorf("spec describes DELETE although the schema does not allow it")
+	}
+	user := spec.Components["schemas"]["User"]
+	if user["type"] != "object" || !strings.Contains(rr.Body.String(), `"#/components/schemas/Error"`) {
+		t.Errorf("spec has unexpected components: got %v", spec.Components)
+	}
+	if strings.Contains(rr.Body.String(), "x-gen-mode") {
+		t.Errorf("spec leaks generator extensions")
+	}
+
+	rr = performRequest(t, srv.openAPIHandler, http.MethodGet, "/openapi.yaml", nil)
+	if ct := rr.Header().Get("Content-Type"); ct != yamlContentType {
+		t.Errorf("handler returned wrong content type: got %v want %v", ct, yamlContentType)
+	}
+	if !strings.Contains(rr.Body.String(), "openapi: 3.1.0\n") || !strings.Contains(rr.Body.String(), "  /users/{id}:\n") {
+		t.Errorf("YAML spec is missing expected content: got %v", rr.Body.String())
+	}
+}
+
+func TestDocsHandler(t *testing.T) {
+	srv := NewServer()
+	rr := performRequest(t, srv.docsHandler, http.MethodGet, "/docs", nil)
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+	if ct := rr.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+		t.Errorf("handler returned wrong content type: got %v", ct)
+	}
+	if !strings.Contains(rr.Body.String(), `url: "/openapi.json"`) {
+		t.Errorf("docs page does not load the spec: got %v", rr.Body.String())
+	}
+}
@@ -0,0 +1,339 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// overrideResponse is one scripted response of an override.
+type overrideResponse struct {
+	Status  int               `json:"status,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    interface{}       `json:"body,omitempty"`
+	// Times is how many requests get this response before the next one
+	// takes over; 0 repeats it for good.
+	Times int `json:"times,omitempty"`
+}
+
+// responseOverride answers requests of Method to Path with scripted
+// responses instead of the generated ones, e.g. GET /users/13 with a 404.
+// Path segments written {name} match any segment. Its own Status,
+// Headers and Body are shorthand for a single response repeated for good;
+// once every response in Responses is used up, requests are served as
+// usual again, so "422 once, then a real create" is
+// [{"status": 422, "times": 1}].
+//
+// An override in a Scenario only matches while the scenario is in its
+// RequiredState, if it names one, and moves the scenario to NewState when
+// it matches. One that only moves the scenario, without any response, lets
+// the request be served as usual.
+type responseOverride struct {
+	ID     int    `json:"id"`
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	overrideResponse
+	Responses     []overrideResponse `json:"responses,omitempty"`
+	Scenario      string             `json:"scenario,omitempty"`
+	RequiredState string             `json:"requiredState,omitempty"`
+	NewState      string             `json:"newState,omitempty"`
+	// Hits counts the requests the override answered.
+	Hits int `json:"hits"`
+}
+
+// overridesFile is the document -overrides files hold, as JSON or YAML.
+type overridesFile struct {
+	Overrides []responseOverride `json:"overrides"`
+}
+
+// overrideSet holds the response overrides. It is safe for concurrent use.
+type overrideSet struct {
+	mu        sync.Mutex
+	overrides []*responseOverride
+	nextID    int
+	// states holds the state of every scenario that left scenarioStarted.
+	states map[string]string
+}
+
+// checkOverride normalizes an override, reporting an error if it cannot
+// be served.
+func (s *Server) checkOverride(o responseOverride) (responseOverride, error) {
+	o.Method = strings.ToUpper(strings.TrimSpace(o.Method))
+	if o.Method == "" {
+		o.Method = "*"
+	}
+	if !strings.HasPrefix(o.Path, "/") {
+		return o, fmt.Errorf("override path must start with /, got %q", o.Path)
+	}
+	if s.isAdminPath(s.route(o.Path)) {
+		return o, fmt.Errorf("override path %s is in the admin API", o.Path)
+	}
+	if o.Scenario == "" && (o.RequiredState != "" || o.NewState != "") {
+		return o, fmt.Errorf("override %s %s gives a state but no scenario", o.Method, o.Path)
+	}
+	single := o.overrideResponse
+	if len(o.Responses) == 0 {
+		passThrough := single.Status == 0 && single.Body == nil && len(single.Headers) == 0
+		if passThrough && o.NewState == "" {
+			return o, fmt.Errorf("override %s %s must give a status, headers, a body, responses or a newState", o.Method, o.Path)
+		}
+		if !passThrough {
+			o.Responses = []overrideResponse{single}
+		}
+	} else if single.Status != 0 || single.Body != nil || len(single.Headers) > 0 || single.Times != 0 {
+		return o, fmt.Errorf("override %s %s gives both responses and a single response", o.Method, o.Path)
+	}
+	o.overrideResponse = overrideResponse{}
+	for i, resp := range o.Responses {
+		if resp.Status == 0 {
+			o.Responses[i].Status = http.StatusOK
+		} else if resp.Status < 100 || resp.Status > 599 {
+			return o, fmt.Errorf("override %s %s: status must be between 100 and 599, got %d", o.Method, o.Path, resp.Status)
+		}
+		if resp.Times < 0 {
+			return o, fmt.Errorf("override %s %s: times must not be negative, got %d", o.Method, o.Path, resp.Times)
+		}
+	}
+	o.Hits = 0
+	return o, nil
+}
+
+// parseOverrides reads an overrides document, as JSON or, when yaml is
+// set, YAML.
+func (s *Server) parseOverrides(data []byte, yaml bool) ([]responseOverride, error) {
+	if yaml {
+		doc, err := unmarshalYAML(data)
+		if err != nil {
+			return nil, err
+		}
+		if data, err = json.Marshal(doc); err != nil {
+			return nil, err
+		}
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	var file overridesFile
+	if err := decoder.Decode(&file); err != nil {
+		return nil, err
+	}
+	overrides := make([]responseOverride, len(file.Overrides))
+	for i, o := range file.Overrides {
+		var err error
+		if overrides[i], err = s.checkOverride(o); err != nil {
+			return nil, err
+		}
+	}
+	return overrides, nil
+}
+
+// loadOverridesFile adds the overrides in the named file, read as YAML
+// when its extension is .yaml or .yml, or when it is not JSON.
+func (s *Server) loadOverridesFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	ext := strings.ToLower(filepath.Ext(path))
+	yaml := ext == ".yaml" || ext == ".yml" || (ext != ".json" && !json.Valid(data))
+	overrides, err := s.parseOverrides(data, yaml)
+	if err != nil {
+		return fmt.Errorf("overrides %s: %w", path, err)
+	}
+	for _, o := range overrides {
+		s.responseOverrides.add(o)
+	}
+	return nil
+}
+
+// reset forgets every override.
+func (s *overrideSet) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.overrides, s.nextID = nil, 1
+	s.states = make(map[string]string)
+}
+
+// add stores o under the next id and returns it.
+func (s *overrideSet) add(o responseOverride) responseOverride {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o.ID = s.nextID
+	s.nextID++
+	s.overrides = append(s.overrides, &o)
+	return o
+}
+
+// remove deletes the override with id, reporting whether there was one.
+func (s *overrideSet) remove(id int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, o := range s.overrides {
+		if o.ID == id {
+			s.overrides = append(s.overrides[:i], s.overrides[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// list returns copies of the overrides in the order they were added.
+func (s *overrideSet) list() []responseOverride {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := make([]responseOverride, len(s.overrides))
+	for i, o := range s.overrides {
+		list[i] = *o
+	}
+	return list
+}
+
+// next returns the scripted response for r from the first override
+// matching it that has any left, counting the hit and moving its scenario
+// on. A matching override without responses reports false, so the request
+// is served as usual.
+func (s *overrideSet) next(r *http.Request) (overrideResponse, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o, i := s.match(r)
+	if o == nil {
+		return overrideResponse{}, false
+	}
+	o.Hits++
+	s.transition(o)
+	if i < 0 {
+		return overrideResponse{}, false
+	}
+	return o.Responses[i], true
+}
+
+// scripted reports whether next would answer r with a scripted response.
+func (s *overrideSet) scripted(r *http.Request) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o, i := s.match(r)
+	return o != nil && i >= 0
+}
+
+// match returns the first override matching r that has any responses
+// left, with the index of the one due, or -1 for an override without
+// responses. The caller must hold s.mu.
+func (s *overrideSet) match(r *http.Request) (*responseOverride, int) {
+	for _, o := range s.overrides {
+		if (o.Method != "*" && o.Method != r.Method) || !matchOverridePath(o.Path, r.URL.Path) {
+			continue
+		}
+		if o.RequiredState != "" && s.state(o.Scenario) != o.RequiredState {
+			continue
+		}
+		if len(o.Responses) == 0 {
+			return o, -1
+		}
+		served := o.Hits
+		for i, resp := range o.Responses {
+			if resp.Times == 0 || served < resp.Times {
+				return o, i
+			}
+			served -= resp.Times
+		}
+	}
+	return nil, 0
+}
+
+// matchOverridePath reports whether a request path matches an override's
+// path, whose {name} segments match any one segment.
+func matchOverridePath(pattern, path string) bool {
+	want := strings.Split(strings.Trim(pattern, "/"), "/")
+	got := strings.Split(strings.Trim(path, "/"), "/")
+	if len(want) != len(got) {
+		return false
+	}
+	for i, segment := range want {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			if got[i] == "" {
+				return false
+			}
+			continue
+		}
+		if segment != got[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// withOverrides answers requests matching a response override with its
+// scripted response, so a scenario can take precedence over the
+// generated data. Bodies are sent as JSON unless the override's headers
+// give a string body another Content-Type; like any error, a {"code",
+// "message"} body with an error status becomes problem details.
+func (s *Server) withOverrides(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp, ok := s.responseOverrides.next(r)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		header := w.Header()
+		for name, value := range resp.Headers {
+			header.Set(name, value)
+		}
+		if resp.Body == nil {
+			w.WriteHeader(resp.Status)
+			return
+		}
+		if raw, isString := resp.Body.(string); isString && header.Get("Content-Type") != "" && !strings.Contains(header.Get("Content-Type"), "json") {
+			w.WriteHeader(resp.Status)
+			w.Write([]byte(raw))
+			return
+		}
+		if header.Get("Content-Type") == "" {
+			header.Set("Content-Type", "application/json")
+		}
+		w.WriteHeader(resp.Status)
+		json.NewEncoder(w).Encode(resp.Body)
+	})
+}
+
+// adminOverrides serves /__admin/overrides: GET lists the response
+// overrides with their hits, POST adds one from a JSON body, and DELETE
+// removes every override, or the one named by /__admin/overrides/{id}.
+func (s *Server) adminOverrides(w http.ResponseWriter, r *http.Request, id string) {
+	switch {
+	case r.Method == http.MethodGet && id == "":
+		writeAdminJSON(w, s.responseOverrides.list())
+	case r.Method == http.MethodPost && id == "":
+		decoder := json.NewDecoder(r.Body)
+		decoder.DisallowUnknownFields()
+		var body responseOverride
+		if err := decoder.Decode(&body); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid JSON body: "+err.Error())
+			return
+		}
+		o, err := s.checkOverride(body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(s.responseOverrides.add(o))
+	case r.Method == http.MethodDelete:
+		if id == "" {
+			s.responseOverrides.reset()
+		} else if n, err := strconv.Atoi(id); err != nil || !s.responseOverrides.remove(n) {
+			writeError(w, http.StatusNotFound, "No such override")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case id == "":
+		writeMethodNotAllowed(w, "Only GET, POST and DELETE allowed", http.MethodGet, http.MethodPost, http.MethodDelete)
+	default:
+		writeMethodNotAllowed(w, "Only DELETE allowed", http.MethodDelete)
+	}
+}
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResponseOverrides(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "overrides.yaml")
+	config := `overrides:
+  - method: GET
+    path: /users/13
+    status: 404
+    body: {code: not_found, message: no user 13}
+  - method: POST
+    path: /users
+    responses:
+      - status: 422
+        body: {error: invalid}
+        times: 1
+  - path: /users/{id}/avatar
+    headers: {Content-Type: text/plain}
+    body: |
+      no avatar
+`
+	if err := os.WriteFile(path, []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	srv := NewServer(WithOverridesFile(path), WithEnvelope("data", "meta"))
+	handler := srv.Handler()
+	srv.currentSchema = createSampleSchema()
+	srv.store = newRecordStore()
+	srv.registerSchema(srv.currentSchema)
+	srv.stores["user"] = srv.store
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rr
+	}
+
+	rr := serve(http.MethodGet, "/users/13", "")
+	if rr.Code != http.StatusNotFound || !strings.Contains(rr.Body.String(), "no user 13") {
+		t.Errorf("GET /users/13 was not overridden: got %v %s", rr.Code, rr.Body.String())
+	}
+	if rr := serve(http.MethodGet, "/users/12", ""); rr.Code != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+
+	user := `{"name": "Ada", "email": "ada@example.com"}`
+	if rr := serve(http.MethodPost, "/users", user); rr.Code != http.StatusUnprocessableEntity || strings.TrimSpace(rr.Body.String()) != `{"error":"invalid"}` {
+		t.Errorf("first POST was not overridden: got %v %s", rr.Code, rr.Body.String())
+	}
+	if rr := serve(http.MethodPost, "/users", user); rr.Code != http.StatusCreated {
+		t.Errorf("second POST was not served as usual: got %v %s", rr.Code, rr.Body.String())
+	}
+
+	rr = serve(http.MethodGet, "/users/7/avatar", "")
+	if rr.Header().Get("Content-Type") != "text/plain" || rr.Body.String() != "no avatar\n" {
+		t.Errorf("raw body was not sent as written: got %q %q", rr.Header().Get("Content-Type"), rr.Body.String())
+	}
+
+	rr = serve(http.MethodPost, "/__admin/overrides", `{"method": "DELETE", "path": "/users/{id}", "status": 409}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("handler returned wrong status code: got %v want %v: %s", rr.Code, http.StatusCreated, rr.Body.String())
+	}
+	if rr := serve(http.MethodDelete, "/users/1", ""); rr.Code != http.StatusConflict {
+		t.Errorf("added override: handler returned wrong status code: got %v want %v", rr.Code, http.StatusConflict)
+	}
+	rr = serve(http.MethodGet, "/__admin/overrides", "")
+	if !strings.Contains(rr.Body.String(), `"hits":1`) || strings.Count(rr.Body.String(), `"id"`) != 4 {
+		t.Errorf("wrong overrides listed: %s", rr.Body.String())
+	}
+	if rr := serve(http.MethodDelete, "/__admin/overrides/4", ""); rr.Code != http.StatusNoContent {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusNoContent)
+	}
+	if rr := serve(http.MethodDelete, "/users/1", ""); rr.Code != http.StatusNoContent {
+		t.Errorf("removed override: handler returned wrong status code: got %v want %v", rr.Code, http.StatusNoContent)
+	}
+
+	for _, bad := range []string{
+		`{"overrides": [{"path": "users", "status": 404}]}`,
+		`{"overrides": [{"path": "/users"}]}`,
+		`{"overrides": [{"path": "/users", "status": 404, "responses": [{"status": 500}]}]}`,
+		`{"overrides": [{"path": "/users", "statuscode": 404}]}`,
+	} {
+		if _, err := srv.parseOverrides([]byte(bad), false); err == nil {
+			t.Errorf("parseOverrides(%s) returned no error", bad)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package server
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// listSizeParam overrides the list size for one request, as in
+// ?_count=50000.
+const listSizeParam = "_count"
+
+const (
+	// maxListSize bounds the list size, however it is set.
+	maxListSize = 100000
+	// lazyListSize is the list size above which generated records are
+	// made one at a time, only for the page asked for; see indexedRecord.
+	lazyListSize = 1000
+)
+
+const (
+	// defaultPageLimit is the page size when ?page or ?cursor is given
+	// without ?limit.
+	defaultPageLimit = 20
+	// maxPageLimit bounds ?limit so one request cannot ask for everything.
+	maxPageLimit = 1000
+)
+
+// paginationParams are the query parameters list pagination acts on.
+var paginationParams = []string{"page", "limit", "cursor"}
+
+// pagination is the slice of a list a request asked for. A request without
+// pagination parameters gets the whole list.
+type pagination struct {
+	paged  bool
+	cursor bool
+	offset int
+	limit  int
+}
+
+// parsePagination reads ?page=&limit= or ?cursor=&limit= from a list
+// request. Pages are numbered from 1; cursors are the opaque tokens handed
+// out in the Link header. Either must start within maxListSize records, so
+// offsets cannot overflow.
+func parsePagination(query url.Values) (pagination, error) {
+	p := pagination{limit: defaultPageLimit}
+	if raw := query.Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxPageLimit {
+			return p, fmt.Errorf("limit must be an integer between 1 and %d", maxPageLimit)
+		}
+		p.paged, p.limit = true, n
+	}
+	page, cursor := query.Get("page"), query.Get("cursor")
+	switch {
+	case page != "" && cursor != "":
+		return p, errors.New("use either page or cursor, not both")
+	case page != "":
+		n, err := strconv.Atoi(page)
+		if err != nil || n < 1 || n-1 > maxListSize/p.limit {
+			return p, fmt.Errorf("page must be an integer between 1 and %d", maxListSize/p.limit+1)
+		}
+		p.paged, p.offset = true, (n-1)*p.limit
+	case cursor != "":
+		offset, err := decodeCursor(cursor)
+		if err != nil {
+			return p, errors.New("cursor is not one this server issued")
+		}
+		p.paged, p.cursor, p.offset = true, true, offset
+	}
+	return p, nil
+}
+
+// encodeCursor returns the cursor for the list position offset.
+func encodeCursor(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte("offset:" + strconv.Itoa(offset)))
+}
+
+// decodeCursor returns the list position a cursor stands for.
+func decodeCursor(cursor string) (int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, err
+	}
+	digits, ok := strings.CutPrefix(string(raw), "offset:")
+	if !ok {
+		return 0, errors.New("malformed cursor")
+	}
+	offset, err := strconv.Atoi(digits)
+	if err != nil || offset < 0 || offset > maxListSize {
+		return 0, errors.New("malformed cursor")
+	}
+	return offset, nil
+}
+
+// want returns how many leading records of a list of total are needed to
+// serve the page, so generated lists need not build the rest.
+func (p pagination) want(total int) int {
+	if !p.paged {
+		return total
+	}
+	return min(total, p.offset+p.limit)
+}
+
+// bounds returns the start and end of the page within a list of total.
+func (p pagination) bounds(total int) (int, int) {
+	if !p.paged {
+		return 0, total
+	}
+	start := min(p.offset, total)
+	return start, min(start+p.limit, total)
+}
+
+// setHeaders describes the page of a list of total records: X-Total-Count
+// always, and for paged requests a Link header with the next and prev
+// pages (plus first and last when paging by number) of the list at path.
+func (p pagination) setHeaders(w http.ResponseWriter, r *http.Request, path string, total int) {
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	if !p.paged {
+		return
+	}
+
+	link := func(rel string, offset int) string {
+		query := r.URL.Query()
+		query.Set("limit", strconv.Itoa(p.limit))
+		if p.cursor {
+			query.Set("cursor", encodeCursor(offset))
+		} else {
+			query.Set("page", strconv.Itoa(offset/p.limit+1))
+		}
+		return fmt.Sprintf(`<%s?%s>; rel="%s"`, path, query.Encode(), rel)
+	}
+	var links []string
+	if !p.cursor {
+		links = append(links, link("first", 0))
+	}
+	if p.offset > 0 {
+		links = append(links, link("prev", max(p.offset-p.limit, 0)))
+	}
+	if p.offset+p.limit < total {
+		links = append(links, link("next", p.offset+p.limit))
+	}
+	if !p.cursor && total > 0 {
+		links = append(links, link("last", (total-1)/p.limit*p.limit))
+	}
+	w.Header().Set("Link", strings.Join(links, ", "))
+}
+
+// linkPattern extracts the target and relation of each Link header entry.
+var linkPattern = regexp.MustCompile(`<([^>]*)>;\s*rel="([^"]+)"`)
+
+// parseLinks maps each relation in a Link header to its target.
+func parseLinks(header string) map[string]string {
+	links := make(map[string]string)
+	for _, match := range linkPattern.FindAllStringSubmatch(header, -1) {
+		links[match[2]] = match[1]
+	}
+	return links
+}
@@ -0,0 +1,167 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// listIDs decodes a list response and returns its record ids.
+func listIDs(t *testing.T, body []byte) []int {
+	t.Helper()
+	var list []map[string]interface{}
+	if err := json.Unmarshal(body, &list); err != nil {
+		t.Fatalf("could not decode response: %v", err)
+	}
+	ids := make([]int, len(list))
+	for i, obj := range list {
+		ids[i] = int(obj["id"].(float64))
+	}
+	return ids
+}
+
+func TestListPagination(t *testing.T) {
+	srv := NewServer()
+	srv.currentSchema = createSampleSchema()
+	srv.store = newRecordStore()
+	for i := 1; i <= 5; i++ {
+		body := fmt.Sprintf(`{"name":"user%d","email":"u%d@example.com"}`, i, i)
+		if rr := performRequest(t, srv.catchAllHandler, http.MethodPost, "/users", []byte(body)); rr.Code != http.StatusCreated {
+			t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusCreated)
+		}
+	}
+
+	t.Run("Unpaged", func(t *testing.T) {
+		rr := performRequest(t, srv.catchAllHandler, http.MethodGet, "/users", nil)
+		if ids := listIDs(t, rr.Body.Bytes()); len(ids) != 5 {
+			t.Errorf("handler returned wrong number of items: got %v want %v", len(ids), 5)
+		}
+		if total := rr.Header().Get("X-Total-Count"); total != "5" {
+			t.Errorf("wrong X-Total-Count: got %q want %q", total, "5")
+		}
+		if link := rr.Header().Get("Link"); link != "" {
+			t.Errorf("unpaged list has a Link header: %q", link)
+		}
+	})
+
+	t.Run("Page And Limit", func(t *testing.T) {
+		rr := performRequest(t, srv.catchAllHandler, http.MethodGet, "/users?page=2&limit=2", nil)
+		if ids := listIDs(t, rr.Body.Bytes()); fmt.Sprint(ids) != "[3 4]" {
+			t.Errorf("handler returned wrong page: got %v want [3 4]", ids)
+		}
+		links := parseLinks(rr.Header().Get("Link"))
+		want := map[string]string{
+			"first": "/users?limit=2&page=1",
+			"prev":  "/users?limit=2&page=1",
+			"next":  "/users?limit=2&page=3",
+			"last":  "/users?limit=2&page=3",
+		}
+		if fmt.Sprint(links) != fmt.Sprint(want) {
+			t.Errorf("wrong Link header: got %v want %v", links, want)
+		}
+	})
+
+	t.Run("Page Past End", func(t *testing.T) {
+		rr := performRequest(t, srv.catchAllHandler, http.MethodGet, "/users?page=9&limit=2", nil)
+		if ids := listIDs(t, rr.Body.Bytes()); len(ids) != 0 {
+			t.Errorf("handler returned items past the end: got %v", ids)
+		}
+	})
+
+	t.Run("Cursor", func(t *testing.T) {
+		var seen []int
+		next := "/users?limit=2&cursor=" + encodeCursor(0)
+		for pages := 0; next != ""; pages++ {
+			if pages > 5 {
+				t.Fatalf("cursor did not reach the end: %v", seen)
+			}
+			rr := performRequest(t, srv.catchAllHandler, http.MethodGet, next, nil)
+			if status := rr.Code; status != http.StatusOK {
+				t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+			}
+			seen = append(seen, listIDs(t, rr.Body.Bytes())...)
+			links := parseLinks(rr.Header().Get("Link"))
+			if _, ok := links["last"]; ok {
+				t.Errorf("cursor page has a last link: %v", links)
+			}
+			next = links["next"]
+		}
+		if fmt.Sprint(seen) != "[1 2 3 4 5]" {
+			t.Errorf("cursor pages returned wrong records: got %v", seen)
+		}
+	})
+
+	t.Run("Invalid Parameters", func(t *testing.T) {
+		for _, query := range []string{"page=0", "page=4611686018427387905&limit=2", "page=50002&limit=2", "limit=abc", "limit=5000", "cursor=bogus", "cursor=" + encodeCursor(maxListSize+1), "page=1&cursor=" + encodeCursor(2)} {
+			rr := performRequest(t, srv.catchAllHandler, http.MethodGet, "/users?"+query, nil)
+			if status := rr.Code; status != http.StatusBadRequest {
+				t.Errorf("%s: handler returned wrong status code: got %v want %v", query, status, http.StatusBadRequest)
+			}
+		}
+	})
+}
+
+func TestGeneratedListPagination(t *testing.T) {
+	srv := NewServer()
+	srv.currentSchema = createSampleSchema()
+	srv.store = newRecordStore()
+	srv.listSize = 45
+
+	rr := performRequest(t, srv.catchAllHandler, http.MethodGet, "/users?page=3", nil)
+	if ids := listIDs(t, rr.Body.Bytes()); len(ids) != 5 || ids[0] != 41 {
+		t.Errorf("handler returned wrong generated page: got %v", ids)
+	}
+	if total := rr.Header().Get("X-Total-Count"); total != "45" {
+		t.Errorf("wrong X-Total-Count: got %q want %q", total, "45")
+	}
+	links := parseLinks(rr.Header().Get("Link"))
+	if _, ok := links["next"]; ok || !strings.HasSuffix(links["prev"], "page=2") {
+		t.Errorf("wrong Link header on the last page: %v", links)
+	}
+
+	// Pages whose offset would overflow are rejected rather than built.
+	rr = performRequest(t, srv.catchAllHandler, http.MethodGet, "/users?page=4611686018427387905&limit=2", nil)
+	if status := rr.Code; status != http.StatusBadRequest {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
+	}
+}
+
+func TestGeneratedListSize(t *testing.T) {
+	srv := NewServer()
+	srv.currentSchema = createSampleSchema()
+	srv.store = newRecordStore()
+
+	size := 7
+	srv.currentSchema.ListSize = &size
+	rr := performRequest(t, srv.catchAllHandler, http.MethodGet, "/users", nil)
+	if ids := listIDs(t, rr.Body.Bytes()); len(ids) != 7 {
+		t.Errorf("x-list-size was not used: got %d records", len(ids))
+	}
+	rr = performRequest(t, srv.catchAllHandler, http.MethodGet, "/users?_count=2", nil)
+	if ids := listIDs(t, rr.Body.Bytes()); len(ids) != 2 {
+		t.Errorf("?_count= did not override x-list-size: got %d records", len(ids))
+	}
+
+	// Past lazyListSize each page is generated on its own, from the
+	// records GET /users/{id} returns.
+	rr = performRequest(t, srv.catchAllHandler, http.MethodGet, "/users?_count=50000&page=2500&limit=20", nil)
+	if ids := listIDs(t, rr.Body.Bytes()); len(ids) != 20 || ids[0] != 49981 || ids[19] != 50000 {
+		t.Errorf("handler returned wrong lazy page: got %v", ids)
+	}
+	if total := rr.Header().Get("X-Total-Count"); total != "50000" {
+		t.Errorf("wrong X-Total-Count: got %q want %q", total, "50000")
+	}
+	page := performRequest(t, srv.catchAllHandler, http.MethodGet, "/users?_count=5000&page=3&limit=1", nil)
+	one := performRequest(t, srv.catchAllHandler, http.MethodGet, "/users/3", nil)
+	if got, want := strings.TrimSpace(page.Body.String()), "["+strings.TrimSpace(one.Body.String())+"]"; got != want {
+		t.Errorf("lazy record differs from GET /users/3: got %s want %s", got, want)
+	}
+
+	for _, raw := range []string{"-1", "100001", "many"} {
+		if rr := performRequest(t, srv.catchAllHandler, http.MethodGet, "/users?_count="+raw, nil); rr.Code != http.StatusBadRequest {
+			t.Errorf("?_count=%s: handler returned wrong status code: got %v want %v", raw, rr.Code, http.StatusBadRequest)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+)
+
+// withPassthrough proxies requests to routes the mock does not serve to
+// upstream, so only part of a real API needs mocking. Proxied requests
+// skip the rest of the chain and reach the upstream as sent, with their
+// full path, and its responses reach the client untouched; they are still
+// kept in the request journal.
+func (s *Server) withPassthrough(upstream *url.URL, mux *http.ServeMux, next http.Handler) http.Handler {
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(upstream)
+			pr.SetXForwarded()
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			writeError(w, http.StatusBadGateway, "Upstream "+upstream.Host+" could not be reached: "+err.Error())
+		},
+	}
+	proxied := s.withJournal(proxy)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.servesRoute(mux, r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		proxied.ServeHTTP(w, r)
+	})
+}
+
+// servesRoute reports whether the mock serves a request's route: one of
+// its own resources, a scripted response, a registered entity's routes or
+// an imported operation. Paths outside the base path are not served.
+func (s *Server) servesRoute(mux *http.ServeMux, r *http.Request) bool {
+	path, ok := strings.CutPrefix(r.URL.Path, s.basePath)
+	if !ok || (path != "" && path[0] != '/') {
+		return false
+	}
+	if path == "" || path == "/" {
+		return true
+	}
+	stripped := &http.Request{Method: r.Method, Host: r.Host, URL: &url.URL{Path: path}}
+	if _, pattern := mux.Handler(stripped); pattern != "/" || s.responseOverrides.scripted(stripped) {
+		return true
+	}
+	if imported, allowed := s.matchImportedRoute(path, r.Method); imported != nil || len(allowed) > 0 {
+		return true
+	}
+	segment, _, _ := strings.Cut(strings.TrimPrefix(path, "/"), "/")
+	_, _, onEntity := s.entityState(segment)
+	return onEntity
+}
@@ -0,0 +1,58 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPassthrough(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusTeapot)
+		w.Write([]byte(`{"code": "upstream", "path": "` + r.URL.Path + `", "forwarded": "` + r.Header.Get("X-Forwarded-Host") + `"}`))
+	}))
+	defer upstream.Close()
+
+	srv := NewServer(WithPassthrough(upstream.URL + "/real"))
+	handler := srv.Handler()
+	srv.currentSchema = createSampleSchema()
+	srv.store = newRecordStore()
+	srv.registerSchema(srv.currentSchema)
+	srv.stores["user"] = srv.store
+	serve := func(path string) *httptest.ResponseRecorder {
+		t.Helper()
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+		return rr
+	}
+
+	rr := serve("/orders/7?x=1")
+	if rr.Code != http.StatusTeapot || strings.TrimSpace(rr.Body.String()) != `{"code": "upstream", "path": "/real/orders/7", "forwarded": "example.com"}` {
+		t.Errorf("unmocked route was not proxied as sent: got %v %s", rr.Code, rr.Body.String())
+	}
+	for _, path := range []string{"/users", "/users/1", "/healthz", "/__admin/config", "/"} {
+		if rr := serve(path); rr.Code != http.StatusOK {
+			t.Errorf("%s: handler returned wrong status code: got %v want %v", path, rr.Code, http.StatusOK)
+		}
+	}
+
+	srv.responseOverrides.add(responseOverride{Method: "*", Path: "/orders/1", Responses: []overrideResponse{{Status: http.StatusGone}}})
+	if rr := serve("/orders/1"); rr.Code != http.StatusGone {
+		t.Errorf("override did not take precedence: got %v", rr.Code)
+	}
+
+	if entries := srv.journal.find(&requestPattern{Path: "/orders/7"}); len(entries) != 1 {
+		t.Errorf("proxied request was not journaled: %v", entries)
+	}
+
+	upstream.Close()
+	if rr := serve("/orders/7"); rr.Code != http.StatusBadGateway {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusBadGateway)
+	}
+
+	if _, err := New(WithPassthrough("api.example.com")); err == nil {
+		t.Error("upstream without a scheme was accepted")
+	}
+}
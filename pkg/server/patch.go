@@ -0,0 +1,399 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// incOperator marks a PATCH field value as an increment rather than a
+// replacement: {"views": {"$inc": 1}} adds 1 to the stored views.
+const incOperator = "$inc"
+
+// splitIncrements removes the {"$inc": n} entries from a PATCH body and
+// returns them by field name. Each must target a numeric property and
+// carry a number, whole for integer properties; violations are reported
+// as field errors and the entry is left out.
+func splitIncrements(properties map[string]Property, body map[string]interface{}) (map[string]float64, []fieldError) {
+	increments := make(map[string]float64)
+	var errs []fieldError
+	keys := make([]string, 0, len(body))
+	for key := range body {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		op, ok := body[key].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		amount, isInc := op[incOperator]
+		if !isInc || len(op) != 1 {
+			continue
+		}
+		delete(body, key)
+
+		fail := func(format string, args ...interface{}) {
+			errs = append(errs, fieldError{Field: key, Pointer: "/" + escapePointerToken(key), Message: fmt.Sprintf(format, args...)})
+		}
+		prop, declared := properties[key]
+		n, isNumber := amount.(float64)
+		switch {
+		case !declared || (prop.Type != "integer" && prop.Type != "number"):
+			fail("%s can only be applied to integer or number properties", incOperator)
+		case !isNumber:
+			fail("%s amount must be a number, got %s", incOperator, jsonTypeName(amount))
+		case prop.Type == "integer" && n != math.Trunc(n):
+			fail("%s amount must be a whole number for an integer property", incOperator)
+		default:
+			increments[key] = n
+		}
+	}
+	return increments, errs
+}
+
+// applyIncrements adds each increment to the record's current value, which
+// counts as 0 when unset. Integer properties stay integers.
+func applyIncrements(properties map[string]Property, obj map[string]interface{}, increments map[string]float64) error {
+	for key, amount := range increments {
+		var current float64
+		switch value := obj[key].(type) {
+		case nil:
+		case int:
+			current = float64(value)
+		case float64:
+			current = value
+		default:
+			return fmt.Errorf("cannot apply %s to %s: stored value is %s", incOperator, key, jsonTypeName(value))
+		}
+		// Counters stay within the schema's bounds, like written values.
+		if problems := checkNumber(properties[key], current+amount); len(problems) > 0 {
+			return fmt.Errorf("cannot apply %s to %s: %s", incOperator, key, problems[0])
+		}
+		if properties[key].Type == "integer" {
+			obj[key] = int(current + amount)
+		} else {
+			obj[key] = current + amount
+		}
+	}
+	return nil
+}
+
+// Media types a PATCH body may use. Plain JSON sets the given top-level
+// fields and understands $inc; the others follow their RFCs.
+const (
+	// mergePatchContentType is a JSON Merge Patch (RFC 7386): objects merge
+	// recursively and null removes a field.
+	mergePatchContentType = "application/merge-patch+json"
+	// jsonPatchContentType is a JSON Patch (RFC 6902): a list of
+	// operations on JSON Pointer paths.
+	jsonPatchContentType = "application/json-patch+json"
+)
+
+// errPatchInvalid reports that a merge or JSON patch produced a record
+// that fails validation; the field errors are reported separately.
+var errPatchInvalid = errors.New("patched record is invalid")
+
+// acceptPatch lists the PATCH media types, for the Accept-Patch header.
+var acceptPatch = strings.Join([]string{"application/json", mergePatchContentType, jsonPatchContentType}, ", ")
+
+// patchMediaType returns the media type of a PATCH request, treating a
+// missing Content-Type as plain JSON.
+func patchMediaType(r *http.Request) string {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return "application/json"
+	}
+	return mediaType
+}
+
+// mergePatch applies a JSON Merge Patch to target and returns the result.
+// target is changed in place where it is an object.
+func mergePatch(target, patch interface{}) interface{} {
+	fields, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	obj, ok := target.(map[string]interface{})
+	if !ok {
+		obj = make(map[string]interface{})
+	}
+	for key, value := range fields {
+		if value == nil {
+			delete(obj, key)
+			continue
+		}
+		obj[key] = mergePatch(obj[key], value)
+	}
+	return obj
+}
+
+// patchOperation is one operation of a JSON Patch. Value is kept raw so a
+// missing value can be told apart from null.
+type patchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from"`
+	Value json.RawMessage `json:"value"`
+}
+
+// decodeJSONPatch parses a JSON Patch document.
+func decodeJSONPatch(r io.Reader) ([]patchOperation, error) {
+	var ops []patchOperation
+	if err := json.NewDecoder(r).Decode(&ops); err != nil {
+		return nil, err
+	}
+	for i, op := range ops {
+		switch op.Op {
+		case "add", "replace", "test":
+			if op.Value == nil {
+				return nil, fmt.Errorf("operation %d (%s): value is missing", i, op.Op)
+			}
+		case "remove", "move", "copy":
+		default:
+			return nil, fmt.Errorf("operation %d: unknown op %q", i, op.Op)
+		}
+	}
+	return ops, nil
+}
+
+// applyJSONPatch applies ops to doc in order. doc is changed in place, so
+// callers pass a copy they can discard if an operation fails.
+func applyJSONPatch(doc map[string]interface{}, ops []patchOperation) error {
+	for i, op := range ops {
+		if err := applyPatchOperation(doc, op); err != nil {
+			return fmt.Errorf("operation %d (%s %s): %v", i, op.Op, op.Path, err)
+		}
+	}
+	return nil
+}
+
+// applyPatchOperation applies a single JSON Patch operation to doc.
+func applyPatchOperation(doc map[string]interface{}, op patchOperation) error {
+	path, err := parsePointer(op.Path)
+	if err != nil {
+		return err
+	}
+	if len(path) == 0 && op.Op != "test" {
+		return errors.New("the record itself cannot be replaced, only its fields")
+	}
+	var value interface{}
+	if op.Value != nil {
+		json.Unmarshal(op.Value, &value)
+	}
+
+	switch op.Op {
+	case "add":
+		return changeAt(doc, path, func(container interface{}, token string) (interface{}, error) {
+			return addValue(container, token, value)
+		})
+	case "remove":
+		_, err := removeAt(doc, path)
+		return err
+	case "replace":
+		return changeAt(doc, path, func(container interface{}, token string) (interface{}, error) {
+			if _, err := valueAt(container, []string{token}); err != nil {
+				return nil, err
+			}
+			return setValue(container, token, value)
+		})
+	case "move", "copy":
+		from, err := parsePointer(op.From)
+		if err != nil {
+			return fmt.Errorf("from: %v", err)
+		}
+		if op.Op == "move" && strings.HasPrefix(op.Path, op.From+"/") {
+			return errors.New("cannot move a value into itself")
+		}
+		var moved interface{}
+		if op.Op == "move" {
+			moved, err = removeAt(doc, from)
+		} else {
+			moved, err = valueAt(doc, from)
+			moved = deepCopy(moved)
+		}
+		if err != nil {
+			return fmt.Errorf("from: %v", err)
+		}
+		return changeAt(doc, path, func(container interface{}, token string) (interface{}, error) {
+			return addValue(container, token, moved)
+		})
+	case "test":
+		current, err := valueAt(doc, path)
+		if err != nil {
+			return err
+		}
+		if !jsonEqual(current, value) {
+			return errors.New("value differs")
+		}
+	}
+	return nil
+}
+
+// parsePointer splits an RFC 6901 JSON Pointer into its unescaped tokens.
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("path %q is not a JSON Pointer", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	unescape := strings.NewReplacer("~1", "/", "~0", "~")
+	for i, token := range tokens {
+		tokens[i] = unescape.Replace(token)
+	}
+	return tokens, nil
+}
+
+// arrayIndex parses an array index token for an array of length n. "-"
+// and n itself address the end, which only adding allows.
+func arrayIndex(token string, n int, adding bool) (int, error) {
+	if token == "-" && adding {
+		return n, nil
+	}
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || (token != "0" && strings.HasPrefix(token, "0")) {
+		return 0, fmt.Errorf("%q is not an array index", token)
+	}
+	if i > n || (i == n && !adding) {
+		return 0, fmt.Errorf("index %d is out of range", i)
+	}
+	return i, nil
+}
+
+// valueAt returns the value at path within node.
+func valueAt(node interface{}, path []string) (interface{}, error) {
+	for _, token := range path {
+		switch container := node.(type) {
+		case map[string]interface{}:
+			value, ok := container[token]
+			if !ok {
+				return nil, fmt.Errorf("%q does not exist", token)
+			}
+			node = value
+		case []interface{}:
+			i, err := arrayIndex(token, len(container), false)
+			if err != nil {
+				return nil, err
+			}
+			node = container[i]
+		default:
+			return nil, fmt.Errorf("%q is inside a %s", token, jsonTypeName(node))
+		}
+	}
+	return node, nil
+}
+
+// changeAt calls change with the container of the last token of path and
+// stores the container it returns, which differs from the one passed in
+// when an array grows or shrinks.
+func changeAt(doc map[string]interface{}, path []string, change func(container interface{}, token string) (interface{}, error)) error {
+	var walk func(node interface{}, path []string) (interface{}, error)
+	walk = func(node interface{}, path []string) (interface{}, error) {
+		if len(path) == 1 {
+			return change(node, path[0])
+		}
+		child, err := valueAt(node, path[:1])
+		if err != nil {
+			return nil, err
+		}
+		updated, err := walk(child, path[1:])
+		if err != nil {
+			return nil, err
+		}
+		return setValue(node, path[0], updated)
+	}
+	_, err := walk(doc, path)
+	return err
+}
+
+// addValue adds value to container at token: it sets an object member or
+// inserts into an array.
+func addValue(container interface{}, token string, value interface{}) (interface{}, error) {
+	list, ok := container.([]interface{})
+	if !ok {
+		return setValue(container, token, value)
+	}
+	i, err := arrayIndex(token, len(list), true)
+	if err != nil {
+		return nil, err
+	}
+	list = append(list, nil)
+	copy(list[i+1:], list[i:])
+	list[i] = value
+	return list, nil
+}
+
+// setValue sets an existing array element or any object member.
+func setValue(container interface{}, token string, value interface{}) (interface{}, error) {
+	switch c := container.(type) {
+	case map[string]interface{}:
+		c[token] = value
+		return c, nil
+	case []interface{}:
+		i, err := arrayIndex(token, len(c), false)
+		if err != nil {
+			return nil, err
+		}
+		c[i] = value
+		return c, nil
+	}
+	return nil, fmt.Errorf("%q is inside a %s", token, jsonTypeName(container))
+}
+
+// removeAt removes and returns the value at path.
+func removeAt(doc map[string]interface{}, path []string) (interface{}, error) {
+	var removed interface{}
+	err := changeAt(doc, path, func(container interface{}, token string) (interface{}, error) {
+		value, err := valueAt(container, []string{token})
+		if err != nil {
+			return nil, err
+		}
+		removed = value
+		switch c := container.(type) {
+		case map[string]interface{}:
+			delete(c, token)
+			return c, nil
+		case []interface{}:
+			i, _ := arrayIndex(token, len(c), false)
+			return append(c[:i:i], c[i+1:]...), nil
+		}
+		return container, nil
+	})
+	return removed, err
+}
+
+// deepCopy copies the objects and arrays of a decoded JSON value, so a
+// patch can change nested values without touching the stored record.
+func deepCopy(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		dup := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			dup[key] = deepCopy(item)
+		}
+		return dup
+	case []interface{}:
+		dup := make([]interface{}, len(v))
+		for i, item := range v {
+			dup[i] = deepCopy(item)
+		}
+		return dup
+	}
+	return value
+}
+
+// jsonEqual reports whether two values are equal as JSON, so a stored int
+// equals the float64 a patch decodes to.
+func jsonEqual(a, b interface{}) bool {
+	return reflect.DeepEqual(asJSON(a), asJSON(b))
+}
@@ -0,0 +1,209 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPatchIncrement(t *testing.T) {
+	srv := NewServer()
+	srv.currentSchema = createSampleSchema()
+	srv.currentSchema.Properties["views"] = Property{Type: "integer"}
+	srv.currentSchema.Properties["rating"] = Property{Type: "number"}
+	zero := 0.0
+	srv.currentSchema.Properties["stock"] = Property{Type: "integer", Minimum: &zero}
+	srv.store = newRecordStore()
+
+	rr := performRequest(t, srv.catchAllHandler, http.MethodPost, "/users", []byte(`{"id":1,"name":"alice","email":"a@example.com","views":10}`))
+	if status := rr.Code; status != http.StatusCreated {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusCreated)
+	}
+
+	t.Run("Increments Stored Value", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			rr := performRequest(t, srv.catchAllHandler, http.MethodPatch, "/users/1", []byte(`{"views":{"$inc":5},"rating":{"$inc":0.5},"name":"bob"}`))
+			if status := rr.Code; status != http.StatusOK {
+				t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+			}
+		}
+		stored, _ := srv.store.get("1")
+		if stored["views"] != 20 || stored["rating"] != 1.0 || stored["name"] != "bob" || stored["email"] == nil {
+			t.Errorf("patch was not applied: got %v", stored)
+		}
+	})
+
+	t.Run("Non-numeric Target Rejected", func(t *testing.T) {
+		rr := performRequest(t, srv.catchAllHandler, http.MethodPatch, "/users/1", []byte(`{"name":{"$inc":1}}`))
+		if status := rr.Code; status != http.StatusUnprocessableEntity {
+			t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusUnprocessableEntity)
+		}
+		var body Error
+		json.Unmarshal(rr.Body.Bytes(), &body)
+		if body.Code != "invalid_increment" || !strings.Contains(rr.Body.String(), `"pointer":"/name"`) {
+			t.Errorf("handler returned unexpected body: got %v", rr.Body.String())
+		}
+	})
+
+	t.Run("Fractional Integer Increment Rejected", func(t *testing.T) {
+		rr := performRequest(t, srv.catchAllHandler, http.MethodPatch, "/users/1", []byte(`{"views":{"$inc":1.5}}`))
+		if status := rr.Code; status != http.StatusUnprocessableEntity {
+			t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusUnprocessableEntity)
+		}
+	})
+
+	t.Run("Increment Below Minimum Rejected", func(t *testing.T) {
+		rr := performRequest(t, srv.catchAllHandler, http.MethodPatch, "/users/1", []byte(`{"stock":{"$inc":-5}}`))
+		if status := rr.Code; status != http.StatusUnprocessableEntity {
+			t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusUnprocessableEntity)
+		}
+		if stored, _ := srv.store.get("1"); stored["stock"] != 1 {
+			t.Errorf("rejected increment was stored: got %v", stored["stock"])
+		}
+	})
+
+	t.Run("Missing Record", func(t *testing.T) {
+		rr := performRequest(t, srv.catchAllHandler, http.MethodPatch, "/users/99", []byte(`{"views":{"$inc":1}}`))
+		if status := rr.Code; status != http.StatusNotFound {
+			t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusNotFound)
+		}
+	})
+}
+
+// performPatch sends a PATCH with the given Content-Type.
+func performPatch(t *testing.T, srv *Server, path, contentType, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPatch, path, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("Could not create request: %v", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	rr := httptest.NewRecorder()
+	srv.catchAllHandler(rr, req)
+	return rr
+}
+
+func TestPatchMediaTypes(t *testing.T) {
+	srv := NewServer()
+	srv.currentSchema = createSampleSchema()
+	srv.currentSchema.Properties["tags"] = Property{Type: "array", Items: &Property{Type: "string"}}
+	srv.currentSchema.Properties["address"] = Property{Type: "object", Properties: map[string]Property{
+		"street": {Type: "string"},
+		"city":   {Type: "string"},
+	}}
+	srv.store = newRecordStore()
+
+	reset := func(t *testing.T) {
+		t.Helper()
+		srv.store = newRecordStore()
+		rr := performRequest(t, srv.catchAllHandler, http.MethodPost, "/users", []byte(`{"id":1,"name":"alice","email":"a@example.com","tags":["a","b"],"address":{"street":"Main St","city":"Lisbon"}}`))
+		if status := rr.Code; status != http.StatusCreated {
+			t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusCreated)
+		}
+	}
+	stored := func() string {
+		obj, _ := srv.store.get("1")
+		encoded, _ := json.Marshal(obj)
+		return string(encoded)
+	}
+
+	t.Run("Merge Patch", func(t *testing.T) {
+		reset(t)
+		rr := performPatch(t, srv, "/users/1", "application/merge-patch+json", `{"address":{"city":"Porto"},"tags":null,"name":"bob"}`)
+		if status := rr.Code; status != http.StatusOK {
+			t.Fatalf("handler returned wrong status code: got %v want %v: %s", status, http.StatusOK, rr.Body.String())
+		}
+		want := `{"address":{"city":"Porto","street":"Main St"},"email":"a@example.com","id":1,"name":"bob"}`
+		if got := stored(); got != want {
+			t.Errorf("merge patch was not applied: got %v want %v", got, want)
+		}
+		if !strings.Contains(rr.Body.String(), `"city":"Porto"`) {
+			t.Errorf("response is not the merged record: got %v", rr.Body.String())
+		}
+	})
+
+	t.Run("Merge Patch Cannot Drop Required Field", func(t *testing.T) {
+		reset(t)
+		rr := performPatch(t, srv, "/users/1", "application/merge-patch+json", `{"email":null}`)
+		if status := rr.Code; status != http.StatusBadRequest {
+			t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
+		}
+		if got := stored(); !strings.Contains(got, `"email"`) {
+			t.Errorf("invalid patch was stored: got %v", got)
+		}
+	})
+
+	t.Run("JSON Patch", func(t *testing.T) {
+		reset(t)
+		rr := performPatch(t, srv, "/users/1", "application/json-patch+json", `[
+			{"op":"test","path":"/name","value":"alice"},
+			{"op":"replace","path":"/name","value":"carol"},
+			{"op":"add","path":"/tags/-","value":"c"},
+			{"op":"remove","path":"/tags/0"},
+			{"op":"copy","from":"/address/city","path":"/tags/0"},
+			{"op":"move","from":"/address/street","path":"/address/line1"},
+			{"op":"test","path":"/id","value":1}
+		]`)
+		if status := rr.Code; status != http.StatusOK {
+			t.Fatalf("handler returned wrong status code: got %v want %v: %s", status, http.StatusOK, rr.Body.String())
+		}
+		want := `{"address":{"city":"Lisbon","line1":"Main St"},"email":"a@example.com","id":1,"name":"carol","tags":["Lisbon","b","c"]}`
+		if got := stored(); got != want {
+			t.Errorf("JSON patch was not applied: got %v want %v", got, want)
+		}
+	})
+
+	t.Run("JSON Patch Is Atomic", func(t *testing.T) {
+		reset(t)
+		before := stored()
+		for _, body := range []string{
+			`[{"op":"replace","path":"/name","value":"dave"},{"op":"test","path":"/name","value":"alice"}]`,
+			`[{"op":"add","path":"/address/city","value":"Rome"},{"op":"remove","path":"/missing"}]`,
+			`[{"op":"add","path":"/tags/5","value":"x"}]`,
+			`[{"op":"move","from":"/address","path":"/address/inner"}]`,
+		} {
+			rr := performPatch(t, srv, "/users/1", "application/json-patch+json", body)
+			if status := rr.Code; status != http.StatusUnprocessableEntity {
+				t.Errorf("%s: handler returned wrong status code: got %v want %v", body, status, http.StatusUnprocessableEntity)
+			}
+			if got := stored(); got != before {
+				t.Errorf("%s: failed patch changed the record: got %v", body, got)
+			}
+		}
+	})
+
+	t.Run("JSON Patch Keeps URL ID", func(t *testing.T) {
+		reset(t)
+		performPatch(t, srv, "/users/1", "application/json-patch+json", `[{"op":"replace","path":"/id","value":7}]`)
+		if got := stored(); !strings.Contains(got, `"id":1`) {
+			t.Errorf("patch changed the id: got %v", got)
+		}
+	})
+
+	t.Run("Malformed Patches", func(t *testing.T) {
+		reset(t)
+		for _, tt := range []struct{ contentType, body string }{
+			{"application/json-patch+json", `{"op":"add"}`},
+			{"application/json-patch+json", `[{"op":"frobnicate","path":"/name"}]`},
+			{"application/json-patch+json", `[{"op":"add","path":"/name"}]`},
+			{"application/merge-patch+json", `["not","an","object"]`},
+		} {
+			rr := performPatch(t, srv, "/users/1", tt.contentType, tt.body)
+			if status := rr.Code; status != http.StatusBadRequest {
+				t.Errorf("%s: handler returned wrong status code: got %v want %v", tt.body, status, http.StatusBadRequest)
+			}
+		}
+	})
+
+	t.Run("Unsupported Media Type", func(t *testing.T) {
+		rr := performPatch(t, srv, "/users/1", "text/plain", `name=bob`)
+		if status := rr.Code; status != http.StatusUnsupportedMediaType {
+			t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusUnsupportedMediaType)
+		}
+		if got := rr.Header().Get("Accept-Patch"); !strings.Contains(got, "application/json-patch+json") {
+			t.Errorf("wrong Accept-Patch header: got %q", got)
+		}
+	})
+}
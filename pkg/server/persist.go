@@ -0,0 +1,175 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// snapshotFile is the name of the snapshot written to the data directory.
+const snapshotFile = "snapshot.json"
+
+// snapshot is the on-disk form of every uploaded schema and its records.
+// Imported OpenAPI documents are not part of it; load them with
+// WithOpenAPIFile on every start instead.
+type snapshot struct {
+	// Current is the key of the most recently uploaded schema.
+	Current string             `json:"current,omitempty"`
+	Schemas map[string]*Schema `json:"schemas"`
+	// Definitions lists the keys of schemas registered from another
+	// schema's definitions.
+	Definitions []string                 `json:"definitions,omitempty"`
+	Stores      map[string]storeSnapshot `json:"stores"`
+}
+
+// storeSnapshot is the on-disk form of a recordStore.
+type storeSnapshot struct {
+	Records []storedRecord `json:"records"`
+	Deleted []string       `json:"deleted,omitempty"`
+	NextID  int            `json:"nextId"`
+	Step    int            `json:"step"`
+}
+
+// storedRecord is a record together with the key it is stored under, which
+// is not always the string form of its id field.
+type storedRecord struct {
+	Key    string                 `json:"key"`
+	Record map[string]interface{} `json:"record"`
+}
+
+// snapshot returns the store's records in insertion order together with its
+// counter, for persisting.
+func (s *recordStore) snapshot() storeSnapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	snap := storeSnapshot{Records: make([]storedRecord, 0, len(s.order)), NextID: s.nextID, Step: s.step}
+	for _, key := range s.order {
+		snap.Records = append(snap.Records, storedRecord{Key: key, Record: s.records[key]})
+	}
+	for key := range s.deleted {
+		snap.Deleted = append(snap.Deleted, key)
+	}
+	return snap
+}
+
+// restoreStore rebuilds the store of schema from its snapshot. Integer ids
+// decode as float64, so they are turned back into ints when the schema
+// numbers its records.
+func (s *Server) restoreStore(snap storeSnapshot, schema *Schema) *recordStore {
+	store := s.newStoreForSchema(schema)
+	store.nextID, store.step = snap.NextID, snap.Step
+	for _, rec := range snap.Records {
+		if n, ok := rec.Record[store.idKey].(float64); ok && schema.integerIDs() && n == math.Trunc(n) {
+			rec.Record[store.idKey] = int(n)
+		}
+		store.insert(rec.Key, rec.Record)
+	}
+	for _, key := range snap.Deleted {
+		store.deleted[key] = true
+	}
+	return store
+}
+
+// saveSnapshot writes every uploaded schema and its records to the data
+// directory. The file is replaced atomically, so a crash mid-write leaves
+// the previous snapshot in place.
+func (s *Server) saveSnapshot(dir string) error {
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+
+	s.stateMu.RLock()
+	snap := snapshot{Schemas: make(map[string]*Schema, len(s.schemas)), Stores: make(map[string]storeSnapshot, len(s.stores))}
+	for key, schema := range s.schemas {
+		snap.Schemas[key] = schema
+		if schema == s.currentSchema {
+			snap.Current = key
+		}
+		if schema.definition {
+			snap.Definitions = append(snap.Definitions, key)
+		}
+	}
+	for key, records := range s.stores {
+		snap.Stores[key] = records.snapshot()
+	}
+	data, err := json.Marshal(snap)
+	s.stateMu.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, snapshotFile+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(dir, snapshotFile))
+}
+
+// restoreSnapshot replaces the uploaded schemas and records with those in
+// the data directory's snapshot. It reports false when there is no
+// snapshot yet.
+func (s *Server) restoreSnapshot(dir string) (bool, error) {
+	data, err := os.ReadFile(filepath.Join(dir, snapshotFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	var snap snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return false, fmt.Errorf("snapshot %s: %v", filepath.Join(dir, snapshotFile), err)
+	}
+
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+	for _, key := range snap.Definitions {
+		if schema, ok := snap.Schemas[key]; ok {
+			schema.definition = true
+		}
+	}
+	for key, schema := range snap.Schemas {
+		if schema == nil {
+			return false, fmt.Errorf("snapshot %s: schema %q is empty", filepath.Join(dir, snapshotFile), key)
+		}
+		records := s.newStoreForSchema(schema)
+		if stored, ok := snap.Stores[key]; ok {
+			records = s.restoreStore(stored, schema)
+		}
+		s.schemas[key] = schema
+		s.stores[key] = records
+	}
+	if schema, ok := snap.Schemas[snap.Current]; ok {
+		s.currentSchema = schema
+		s.store = s.stores[snap.Current]
+	}
+	return true, nil
+}
+
+// persistChanges saves a snapshot after every request that may have
+// changed the schemas or records. Failures are logged rather than
+// reported to the client, whose change has already been applied.
+func (s *Server) persistChanges(dir string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r)
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			return
+		}
+		if err := s.saveSnapshot(dir); err != nil {
+			log.Println("Error saving snapshot:", err)
+		}
+	})
+}
@@ -0,0 +1,69 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWithDataDir(t *testing.T) {
+	dir := t.TempDir()
+	serve := func(s *Server, method, path, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		rr := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rr, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rr
+	}
+
+	s := NewServer(WithDataDir(dir))
+	serve(s, http.MethodPost, "/upload", `{"title": "User", "properties": {"id": {"type": "integer"}, "name": {"type": "string"}}}`)
+	serve(s, http.MethodPost, "/upload", `{"title": "Tag", "properties": {"id": {"type": "string"}, "label": {"type": "string"}}}`)
+	for _, body := range []string{`{"name": "alice"}`, `{"name": "bob"}`} {
+		if rr := serve(s, http.MethodPost, "/users", body); rr.Code != http.StatusCreated {
+			t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusCreated)
+		}
+	}
+	serve(s, http.MethodPost, "/tags", `{"id": "go", "label": "Go"}`)
+	serve(s, http.MethodDelete, "/users/1", "")
+	s.Close()
+
+	// Schema files are ignored once a snapshot exists.
+	schemaPath := filepath.Join(t.TempDir(), "user.json")
+	if err := os.WriteFile(schemaPath, []byte(`{"title": "User", "properties": {"nickname": {"type": "string"}}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s, err := New(WithDataDir(dir), WithSchemaFile(schemaPath), WithStrictGet(true))
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	cases := []struct {
+		method, path, body string
+		status             int
+		want               string
+	}{
+		{http.MethodGet, "/users/2", "", http.StatusOK, `"name":"bob"`},
+		{http.MethodGet, "/users/1", "", http.StatusNotFound, ""},
+		{http.MethodGet, "/tags/go", "", http.StatusOK, `"label":"Go"`},
+		{http.MethodPost, "/users", `{"name": "carol"}`, http.StatusCreated, `"id":3`},
+		{http.MethodPost, "/users", `{"id": 2}`, http.StatusConflict, ""},
+	}
+	for _, tc := range cases {
+		rr := serve(s, tc.method, tc.path, tc.body)
+		if rr.Code != tc.status || !strings.Contains(rr.Body.String(), tc.want) {
+			t.Errorf("%s %s: got %v %v want %v containing %s", tc.method, tc.path, rr.Code, rr.Body.String(), tc.status, tc.want)
+		}
+	}
+	if s.currentSchema == nil || s.currentSchema.Title != "Tag" {
+		t.Errorf("the most recent upload was not restored as the current schema: got %+v", s.currentSchema)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, snapshotFile), []byte("not json"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := New(WithDataDir(dir)); err == nil {
+		t.Errorf("New accepted a corrupt snapshot")
+	}
+}
@@ -0,0 +1,414 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// postmanSchema is the format /postman.json exports.
+const postmanSchema = "https://schema.getpostman.com/json/collection/v2.1.0/collection.json"
+
+// postmanItem is a request or, when it has items of its own, a folder of a
+// Postman collection (format 2.0 or 2.1). The request may also be a bare
+// URL string.
+type postmanItem struct {
+	Name     string            `json:"name"`
+	Item     []postmanItem     `json:"item,omitempty"`
+	Request  json.RawMessage   `json:"request,omitempty"`
+	Response []postmanResponse `json:"response,omitempty"`
+}
+
+// postmanRequest is the request of a collection item.
+type postmanRequest struct {
+	Method string          `json:"method"`
+	URL    json.RawMessage `json:"url"`
+}
+
+// postmanURL is the structured form of a request URL. Path may be a list
+// of segments or a string.
+type postmanURL struct {
+	Raw  string          `json:"raw"`
+	Path json.RawMessage `json:"path"`
+}
+
+// postmanResponse is a saved example response.
+type postmanResponse struct {
+	Name   string          `json:"name,omitempty"`
+	Code   int             `json:"code"`
+	Status string          `json:"status,omitempty"`
+	Header []postmanHeader `json:"header"`
+	Body   string          `json:"body"`
+}
+
+// postmanHeader is a request or response header.
+type postmanHeader struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// importPostman turns the requests of a Postman collection, in folders or
+// not, into routes answering with each request's first saved 2xx example,
+// else its first one. Path variables written :name or {{name}} match any
+// segment, and the host, usually a {{baseUrl}} variable, is dropped.
+// Requests without examples are still routed, answering 200 without a
+// body, and reported as warnings.
+func importPostman(raw []byte) ([]*importedRoute, []string, error) {
+	var collection struct {
+		Info struct {
+			Schema string `json:"schema"`
+		} `json:"info"`
+		Item []postmanItem `json:"item"`
+	}
+	if err := json.Unmarshal(raw, &collection); err != nil {
+		return nil, nil, err
+	}
+	if !strings.Contains(collection.Info.Schema, "schema.getpostman.com") {
+		return nil, nil, errors.New(`expected a Postman collection ("info": {"schema": "https://schema.getpostman.com/json/collection/v2.1.0/collection.json"})`)
+	}
+	var routes []*importedRoute
+	var warnings []string
+	var walk func(items []postmanItem) error
+	walk = func(items []postmanItem) error {
+		for _, item := range items {
+			if len(item.Request) == 0 {
+				if err := walk(item.Item); err != nil {
+					return err
+				}
+				continue
+			}
+			method, template, err := postmanRoute(item.Request)
+			if err != nil {
+				return fmt.Errorf("request %q: %v", item.Name, err)
+			}
+			name := method + " " + template
+			response := importedResponse{status: http.StatusOK}
+			if example, ok := postmanExample(item.Response); ok {
+				response = example
+			} else {
+				warnings = append(warnings, name+" has no saved response; it answers 200 without a body")
+			}
+			routes = append(routes, &importedRoute{
+				method:   method,
+				template: template,
+				segments: strings.Split(strings.Trim(template, "/"), "/"),
+				response: response,
+			})
+		}
+		return nil
+	}
+	if err := walk(collection.Item); err != nil {
+		return nil, nil, err
+	}
+	if len(routes) == 0 {
+		return nil, nil, errors.New("the collection has no requests")
+	}
+	return routes, warnings, nil
+}
+
+// postmanRoute returns the method and path template of a collection
+// request.
+func postmanRoute(raw json.RawMessage) (string, string, error) {
+	var rawURL string
+	request := postmanRequest{Method: http.MethodGet}
+	if json.Unmarshal(raw, &rawURL) != nil {
+		if err := json.Unmarshal(raw, &request); err != nil {
+			return "", "", err
+		}
+	}
+	var segments []string
+	if rawURL == "" {
+		var structured postmanURL
+		if json.Unmarshal(request.URL, &rawURL) != nil {
+			if err := json.Unmarshal(request.URL, &structured); err != nil {
+				return "", "", fmt.Errorf("invalid url: %v", err)
+			}
+			var path string
+			if len(structured.Path) > 0 && json.Unmarshal(structured.Path, &segments) != nil && json.Unmarshal(structured.Path, &path) == nil {
+				segments = strings.Split(strings.Trim(path, "/"), "/")
+			}
+			rawURL = structured.Raw
+		}
+	}
+	if segments == nil {
+		// Variables would not survive URL parsing, and a {{baseUrl}} host
+		// has no scheme.
+		rest := rawURL
+		if _, after, ok := strings.Cut(rest, "://"); ok {
+			rest = after
+		}
+		if strings.HasPrefix(rest, "{{") {
+			if _, after, ok := strings.Cut(rest, "}}"); ok {
+				rest = after
+			}
+		}
+		rest, _, _ = strings.Cut(rest, "?")
+		rest, _, _ = strings.Cut(rest, "#")
+		if !strings.HasPrefix(rest, "/") {
+			_, rest, _ = strings.Cut(rest, "/")
+		}
+		segments = strings.Split(strings.Trim(rest, "/"), "/")
+	}
+	for i, segment := range segments {
+		switch {
+		case strings.HasPrefix(segment, ":") && len(segment) > 1:
+			segments[i] = "{" + segment[1:] + "}"
+		case strings.HasPrefix(segment, "{{") && strings.HasSuffix(segment, "}}") && len(segment) > 4:
+			segments[i] = "{" + segment[2:len(segment)-2] + "}"
+		}
+	}
+	template := "/" + strings.Join(segments, "/")
+	if template != "/" {
+		template = strings.TrimSuffix(template, "/")
+	}
+	method := strings.ToUpper(strings.TrimSpace(request.Method))
+	if method == "" {
+		method = http.MethodGet
+	}
+	return method, template, nil
+}
+
+// postmanExample picks the saved example a request is mocked with: its
+// first 2xx one, else its first.
+func postmanExample(examples []postmanResponse) (importedResponse, bool) {
+	if len(examples) == 0 {
+		return importedResponse{}, false
+	}
+	example := examples[0]
+	for _, e := range examples {
+		if e.Code >= 200 && e.Code < 300 {
+			example = e
+			break
+		}
+	}
+	response := importedResponse{status: example.Code}
+	if response.status == 0 {
+		response.status = http.StatusOK
+	}
+	for _, h := range example.Header {
+		if strings.EqualFold(h.Key, "Content-Type") {
+			response.contentType = h.Value
+		}
+	}
+	if example.Body == "" {
+		return response, true
+	}
+	response.hasBody, response.hasExample = true, true
+	var value interface{}
+	isJSON := json.Unmarshal([]byte(example.Body), &value) == nil
+	if response.contentType == "" {
+		response.contentType = "text/plain"
+		if isJSON {
+			response.contentType = "application/json"
+		}
+	}
+	if isJSON && strings.Contains(response.contentType, "json") {
+		response.example = value
+	} else {
+		response.example = example.Body
+	}
+	return response, true
+}
+
+// loadPostman imports the Postman collection in r, returning the
+// operations it registered and any warnings.
+func (s *Server) loadPostman(r io.Reader) ([]string, []string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	routes, warnings, err := importPostman(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	s.registerImportedRoutes(routes)
+	operations := make([]string, len(routes))
+	for i, imported := range routes {
+		operations[i] = imported.method + " " + s.route(imported.template)
+	}
+	return operations, warnings, nil
+}
+
+// loadPostmanFile imports the Postman collection in the named file.
+func (s *Server) loadPostmanFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, warnings, err := s.loadPostman(file)
+	if err != nil {
+		return fmt.Errorf("Postman collection %s: %w", path, err)
+	}
+	for _, warning := range warnings {
+		log.Printf("Postman collection %s: %s", path, warning)
+	}
+	return nil
+}
+
+// postmanImportHandler imports a Postman collection POSTed to
+// /upload/postman and mocks each of its requests.
+func (s *Server) postmanImportHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w, "Only POST allowed", http.MethodPost)
+		return
+	}
+	defer r.Body.Close()
+	operations, warnings, err := s.loadPostman(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid Postman collection: "+err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	response := map[string]interface{}{
+		"message":    "Postman collection imported successfully",
+		"operations": operations,
+	}
+	if len(warnings) > 0 {
+		response["warnings"] = warnings
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// postmanCollection builds a Postman collection with a folder of requests
+// per uploaded schema, each with an example body and response generated
+// like the records the routes serve. Requests address {{baseUrl}}, which
+// the collection sets to baseURL.
+func (s *Server) postmanCollection(baseURL string) map[string]interface{} {
+	s.stateMu.RLock()
+	defer s.stateMu.RUnlock()
+
+	folders := []interface{}{}
+	for _, key := range s.sortedSchemaKeys() {
+		schema := s.schemas[key]
+		example := s.dummyData(schema, s.newGenerator(nil))
+		collection := s.route("/" + entityName(schema))
+		item := collection + "/:id"
+		id := fmt.Sprint(example[schema.idKey()])
+		name := strings.ToLower(schema.Title)
+
+		var requests []interface{}
+		add := func(summary, method, path string, body interface{}, status int, result interface{}) {
+			request := map[string]interface{}{
+				"method": method,
+				"header": []interface{}{},
+				"url":    postmanRequestURL(path, id),
+			}
+			if body != nil {
+				text, _ := json.MarshalIndent(body, "", "  ")
+				request["header"] = []interface{}{postmanHeader{Key: "Content-Type", Value: "application/json"}}
+				request["body"] = map[string]interface{}{
+					"mode":    "raw",
+					"raw":     string(text),
+					"options": map[string]interface{}{"raw": map[string]interface{}{"language": "json"}},
+				}
+			}
+			response := postmanResponse{Name: summary, Code: status, Status: http.StatusText(status), Header: []postmanHeader{}}
+			if result != nil {
+				text, _ := json.MarshalIndent(result, "", "  ")
+				response.Header = []postmanHeader{{Key: "Content-Type", Value: "application/json"}}
+				response.Body = string(text)
+			}
+			requests = append(requests, map[string]interface{}{
+				"name":     summary,
+				"request":  request,
+				"response": []interface{}{response},
+			})
+		}
+		if schema.allowsMethod(http.MethodGet) {
+			add("List "+entityName(schema), http.MethodGet, collection, nil, http.StatusOK, []interface{}{example})
+		}
+		if schema.allowsMethod(http.MethodPost) {
+			add("Create a "+name, http.MethodPost, collection, example, http.StatusCreated, example)
+		}
+		if schema.allowsMethod(http.MethodGet) {
+			add("Get a "+name, http.MethodGet, item, nil, http.StatusOK, example)
+		}
+		if schema.allowsMethod(http.MethodPut) {
+			add("Update a "+name, http.MethodPut, item, example, http.StatusOK, example)
+		}
+		if schema.allowsMethod(http.MethodPatch) {
+			add("Change some fields of a "+name, http.MethodPatch, item, map[string]interface{}{}, http.StatusOK, example)
+		}
+		if schema.allowsMethod(http.MethodDelete) {
+			add("Delete a "+name, http.MethodDelete, item, nil, http.StatusNoContent, nil)
+		}
+		if len(requests) > 0 {
+			folders = append(folders, map[string]interface{}{"name": entityName(schema), "item": requests})
+		}
+	}
+
+	doc := map[string]interface{}{
+		"info": map[string]interface{}{
+			"name":        "schema2api",
+			"description": "Mock API generated from the uploaded JSON schemas.",
+			"schema":      postmanSchema,
+		},
+		"item":     folders,
+		"variable": []interface{}{map[string]interface{}{"key": "baseUrl", "value": baseURL}},
+	}
+	if s.authRequired {
+		doc["auth"] = map[string]interface{}{
+			"type": "apikey",
+			"apikey": []interface{}{
+				map[string]interface{}{"key": "key", "value": apiKeyHeader},
+				map[string]interface{}{"key": "value", "value": "{{apiKey}}"},
+				map[string]interface{}{"key": "in", "value": "header"},
+			},
+		}
+		doc["variable"] = append(doc["variable"].([]interface{}), map[string]interface{}{"key": "apiKey", "value": ""})
+	}
+	return doc
+}
+
+// postmanRequestURL is the URL object of a request to path under
+// {{baseUrl}}, whose :id variable, if it has one, is set to id.
+func postmanRequestURL(path, id string) map[string]interface{} {
+	u := map[string]interface{}{
+		"raw":  "{{baseUrl}}" + path,
+		"host": []string{"{{baseUrl}}"},
+		"path": strings.Split(strings.Trim(path, "/"), "/"),
+	}
+	if strings.HasSuffix(path, "/:id") {
+		u["variable"] = []interface{}{map[string]interface{}{"key": "id", "value": id}}
+	}
+	return u
+}
+
+// postmanHandler serves the Postman collection of the generated routes at
+// GET /postman.json, addressing the host the request was sent to.
+func (s *Server) postmanHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w, "Only GET allowed", http.MethodGet)
+		return
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	base := &url.URL{Scheme: scheme, Host: r.Host}
+	if base.Host == "" {
+		base.Host = "localhost"
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(s.postmanCollection(base.String())); err != nil {
+		log.Println("Error encoding response:", err)
+	}
+}
+
+// ExportPostman writes the Postman collection served at /postman.json to
+// w, addressing baseURL, so it can be shared without running the server.
+func (s *Server) ExportPostman(w io.Writer, baseURL string) error {
+	out, err := json.MarshalIndent(s.postmanCollection(strings.TrimSuffix(baseURL, "/")), "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(out, '\n'))
+	return err
+}
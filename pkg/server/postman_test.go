@@ -0,0 +1,126 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+const shopCollection = `{
+	"info": {"name": "Shop", "schema": "https://schema.getpostman.com/json/collection/v2.1.0/collection.json"},
+	"item": [
+		{"name": "Orders", "item": [
+			{"name": "List orders", "request": {"method": "GET", "url": {"raw": "{{baseUrl}}/orders?page=1", "host": ["{{baseUrl}}"], "path": ["orders"]}},
+			 "response": [
+				{"name": "Failure", "code": 500, "header": [], "body": ""},
+				{"name": "Success", "code": 200, "header": [{"key": "Content-Type", "value": "application/json"}], "body": "[{\"id\": 1}]"}
+			 ]},
+			{"name": "Get order", "request": {"method": "GET", "url": "{{baseUrl}}/orders/:orderId"},
+			 "response": [{"name": "Found", "code": 200, "header": [], "body": "{\"id\": 7, \"total\": 20}"}]},
+			{"name": "Delete order", "request": {"method": "DELETE", "url": "https://shop.example.com/orders/{{orderId}}"}}
+		]},
+		{"name": "Ping", "request": "https://shop.example.com/ping",
+		 "response": [{"code": 200, "header": [{"key": "content-type", "value": "text/plain"}], "body": "pong"}]}
+	]
+}`
+
+func TestImportPostman(t *testing.T) {
+	srv := NewServer()
+
+	rr := performRequest(t, srv.postmanImportHandler, http.MethodPost, "/upload/postman", []byte(shopCollection))
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v (%v)", status, http.StatusOK, rr.Body.String())
+	}
+	var imported struct {
+		Operations []string `json:"operations"`
+		Warnings   []string `json:"warnings"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &imported); err != nil {
+		t.Fatalf("could not decode response: %v", err)
+	}
+	want := []string{"GET /orders", "GET /orders/{orderId}", "DELETE /orders/{orderId}", "GET /ping"}
+	if strings.Join(imported.Operations, ",") != strings.Join(want, ",") {
+		t.Errorf("handler returned unexpected operations: got %v want %v", imported.Operations, want)
+	}
+	if len(imported.Warnings) != 1 || !strings.Contains(imported.Warnings[0], "DELETE /orders/{orderId}") {
+		t.Errorf("handler returned unexpected warnings: got %v", imported.Warnings)
+	}
+
+	cases := []struct {
+		method, path string
+		status       int
+		body         string
+	}{
+		{http.MethodGet, "/orders", http.StatusOK, `[{"id":1}]`},
+		{http.MethodGet, "/orders/7", http.StatusOK, `{"id":7,"total":20}`},
+		{http.MethodDelete, "/orders/7", http.StatusOK, ``},
+		{http.MethodGet, "/ping", http.StatusOK, `pong`},
+	}
+	for _, tc := range cases {
+		rr := performRequest(t, srv.catchAllHandler, tc.method, tc.path, nil)
+		if rr.Code != tc.status || strings.TrimSpace(rr.Body.String()) != tc.body {
+			t.Errorf("%s %s: got %v %v want %v %v", tc.method, tc.path, rr.Code, rr.Body.String(), tc.status, tc.body)
+		}
+	}
+
+	rr = performRequest(t, srv.postmanImportHandler, http.MethodPost, "/upload/postman", []byte(petstoreSpec))
+	if status := rr.Code; status != http.StatusBadRequest {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
+	}
+}
+
+func TestPostmanExport(t *testing.T) {
+	srv := NewServer()
+	srv.currentSchema = createSampleSchema()
+	srv.store = newRecordStore()
+	srv.registerSchema(srv.currentSchema)
+	srv.stores["user"] = srv.store
+
+	rr := performRequest(t, srv.postmanHandler, http.MethodGet, "/postman.json", nil)
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+	var doc struct {
+		Info     map[string]string   `json:"info"`
+		Variable []map[string]string `json:"variable"`
+		Item     []struct {
+			Name string        `json:"name"`
+			Item []postmanItem `json:"item"`
+		} `json:"item"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("could not decode response: %v", err)
+	}
+	if doc.Info["schema"] != postmanSchema || len(doc.Variable) != 1 || doc.Variable[0]["value"] != "http://localhost" {
+		t.Errorf("unexpected collection info: %v %v", doc.Info, doc.Variable)
+	}
+	if len(doc.Item) != 1 || doc.Item[0].Name != "users" {
+		t.Fatalf("unexpected folders: %v", doc.Item)
+	}
+	var create *postmanItem
+	for i, item := range doc.Item[0].Item {
+		if strings.HasPrefix(item.Name, "Create") {
+			create = &doc.Item[0].Item[i]
+		}
+	}
+	if create == nil || len(create.Response) != 1 || create.Response[0].Code != http.StatusCreated || !strings.Contains(create.Response[0].Body, `"name"`) {
+		t.Fatalf("create request has no example response: %+v", create)
+	}
+	var request struct {
+		URL  postmanURL `json:"url"`
+		Body struct {
+			Raw string `json:"raw"`
+		} `json:"body"`
+	}
+	json.Unmarshal(create.Request, &request)
+	if request.URL.Raw != "{{baseUrl}}/users" || !strings.Contains(request.Body.Raw, `"email"`) {
+		t.Errorf("create request has no example body: %+v", request)
+	}
+
+	// An exported collection imports back into the same routes.
+	routes, _, err := importPostman(rr.Body.Bytes())
+	if err != nil || len(routes) != len(doc.Item[0].Item) || routes[0].template != "/users" || routes[len(routes)-1].template != "/users/{id}" {
+		t.Errorf("exported collection did not import: %v %v", routes, err)
+	}
+}
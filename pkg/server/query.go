@@ -0,0 +1,438 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// listMeta describes how the server interpreted a list request's query
+// string. It is only included in responses when debugMode is enabled.
+type listMeta struct {
+	Query url.Values `json:"query"`
+	// Offset and Limit are the slice of the list that was served; Limit
+	// is left out when the request was not paginated.
+	Offset int    `json:"offset"`
+	Limit  int    `json:"limit,omitempty"`
+	Page   int    `json:"page,omitempty"`
+	Cursor string `json:"cursor,omitempty"`
+	// Filters and Sort are the parsed filters, with values converted to
+	// their property's type, and sort keys, in the order applied.
+	Filters []metaFilter `json:"filters"`
+	Sort    []metaSort   `json:"sort"`
+	Search  []string     `json:"search,omitempty"`
+	Ignored []string     `json:"ignored"`
+}
+
+// metaFilter reports one listFilter in listMeta.
+type metaFilter struct {
+	Field  string        `json:"field"`
+	Op     string        `json:"op"`
+	Values []interface{} `json:"values"`
+}
+
+// metaSort reports one sortKey in listMeta.
+type metaSort struct {
+	Field string `json:"field"`
+	Order string `json:"order"`
+}
+
+// newListMeta builds the debug metadata for a list request from the
+// pagination and query parsed out of it. Parameters that the server did
+// not act on are reported in Ignored.
+func newListMeta(query url.Values, schema *Schema, page pagination, q listQuery) listMeta {
+	meta := listMeta{
+		Query:   query,
+		Offset:  page.offset,
+		Filters: []metaFilter{},
+		Sort:    []metaSort{},
+		Search:  q.search,
+		Ignored: unknownQueryParams(query, schema),
+	}
+	if page.paged {
+		meta.Limit = page.limit
+		if page.cursor {
+			meta.Cursor = query.Get("cursor")
+		} else {
+			meta.Page = page.offset/page.limit + 1
+		}
+	}
+	for _, f := range q.filters {
+		meta.Filters = append(meta.Filters, metaFilter{Field: f.field, Op: f.op, Values: f.values})
+	}
+	for _, key := range q.sort {
+		order := "asc"
+		if key.desc {
+			order = "desc"
+		}
+		meta.Sort = append(meta.Sort, metaSort{Field: key.field, Order: order})
+	}
+	if meta.Ignored == nil {
+		meta.Ignored = []string{}
+	}
+	return meta
+}
+
+// listParam reports whether a list acts on the query parameter key: it
+// filters by a property, sorts, paginates, expands references, selects
+// fields, picks the format, sizes a stream, includes soft-deleted
+// records or searches.
+func listParam(schema *Schema, key string) bool {
+	if key == sortParam || key == searchParam || key == expandParam || key == fieldsParam || key == formatParam || key == countParam || key == listSizeParam || key == includeDeletedParam || containsString(paginationParams, key) {
+		return true
+	}
+	_, _, ok := filterField(schema, key)
+	return ok
+}
+
+// unknownQueryParams returns, sorted, the query parameters that a list
+// does not act on.
+func unknownQueryParams(query url.Values, schema *Schema) []string {
+	var unknown []string
+	for key := range query {
+		if !listParam(schema, key) {
+			unknown = append(unknown, key)
+		}
+	}
+	sort.Strings(unknown)
+	return unknown
+}
+
+// setUnknownParamsHeader reports unknown query parameters on a list
+// response when warnUnknownParams is enabled.
+func (s *Server) setUnknownParamsHeader(w http.ResponseWriter, r *http.Request, schema *Schema) {
+	if !s.warnUnknownParams {
+		return
+	}
+	if unknown := unknownQueryParams(r.URL.Query(), schema); len(unknown) > 0 {
+		w.Header().Set("X-Unknown-Params", strings.Join(unknown, ","))
+	}
+}
+
+// filterOperators maps the suffixes of filter parameters, as in ?age_gt=30,
+// to the comparison they make. A parameter without a suffix tests equality.
+var filterOperators = map[string]string{
+	"_ne":  "ne",
+	"_gt":  "gt",
+	"_gte": "gte",
+	"_lt":  "lt",
+	"_lte": "lte",
+}
+
+// sortParam is the query parameter naming the fields lists are sorted by.
+const sortParam = "sort"
+
+// listFilter keeps the records whose field compares to the values as op
+// says. field is a property name, or a dotted path into nested objects.
+type listFilter struct {
+	field  string
+	op     string
+	values []interface{}
+}
+
+// sortKey orders records by field, descending when desc is set.
+type sortKey struct {
+	field string
+	desc  bool
+}
+
+// listQuery is the filtering, searching and sorting a list request asked
+// for.
+type listQuery struct {
+	filters []listFilter
+	search  []string
+	sort    []sortKey
+	// size is the ?_count= the request gave, or -1.
+	size int
+}
+
+// active reports whether the query changes the list at all.
+func (q listQuery) active() bool {
+	return len(q.filters) > 0 || len(q.search) > 0 || len(q.sort) > 0
+}
+
+// generatedSize returns how many generated records a list of schema holds
+// before its first write: the request's ?_count=, or else the schema's
+// x-list-size, or else listSize.
+func (q listQuery) generatedSize(schema *Schema, listSize int) int {
+	if q.size >= 0 {
+		return q.size
+	}
+	if schema.ListSize != nil {
+		return *schema.ListSize
+	}
+	return listSize
+}
+
+// lookupField resolves a dotted field path to its property. Records always
+// carry an id, so it resolves even when the schema does not declare it.
+func lookupField(schema *Schema, field string) (Property, bool) {
+	properties := schema.Properties
+	names := strings.Split(field, ".")
+	for i, name := range names {
+		prop, ok := properties[name]
+		if !ok {
+			if i == 0 && field == schema.idKey() {
+				if !schema.integerIDs() {
+					return Property{Type: "string"}, true
+				}
+				return Property{Type: "integer"}, true
+			}
+			return Property{}, false
+		}
+		// writeOnly fields are never sent back, so they cannot be
+		// queried either.
+		if prop.WriteOnly {
+			return Property{}, false
+		}
+		if i == len(names)-1 {
+			return prop, true
+		}
+		properties = prop.Properties
+	}
+	return Property{}, false
+}
+
+// filterField splits a query parameter into the field it filters and the
+// operator, or reports false when it names no property. A property whose
+// own name ends like an operator, e.g. last_gt, is matched as-is.
+func filterField(schema *Schema, key string) (string, string, bool) {
+	if _, ok := lookupField(schema, key); ok {
+		return key, "eq", true
+	}
+	for suffix, op := range filterOperators {
+		if field, found := strings.CutSuffix(key, suffix); found {
+			if _, ok := lookupField(schema, field); ok {
+				return field, op, true
+			}
+		}
+	}
+	return "", "", false
+}
+
+// parseListQuery reads the filters and sort order of a list request.
+// Parameters that name no property are left alone, as they always were;
+// values that cannot be compared with their property are rejected.
+func parseListQuery(query url.Values, schema *Schema) (listQuery, error) {
+	q := listQuery{size: -1}
+	if raw := query.Get(listSizeParam); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 || n > maxListSize {
+			return q, fmt.Errorf("%s must be an integer between 0 and %d", listSizeParam, maxListSize)
+		}
+		q.size = n
+	}
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if key == sortParam || key == expandParam || key == fieldsParam || key == listSizeParam || containsString(paginationParams, key) {
+			continue
+		}
+		// ?q= searches, even on a schema with a q property.
+		if key == searchParam {
+			q.search = searchTerms(query[key])
+			continue
+		}
+		// ?format=csv and ?format=ndjson pick the format rather than
+		// filtering.
+		if value := query.Get(key); key == formatParam && (value == formatCSV || value == formatNDJSON) {
+			continue
+		}
+		field, op, ok := filterField(schema, key)
+		if !ok {
+			continue
+		}
+		prop, _ := lookupField(schema, field)
+		filter := listFilter{field: field, op: op}
+		for _, raw := range query[key] {
+			value, err := parseFilterValue(prop, raw)
+			if err != nil {
+				return q, fmt.Errorf("%s: %v", key, err)
+			}
+			filter.values = append(filter.values, value)
+		}
+		q.filters = append(q.filters, filter)
+	}
+
+	for _, raw := range query[sortParam] {
+		for _, field := range strings.Split(raw, ",") {
+			key := sortKey{field: strings.TrimSpace(field)}
+			if rest, found := strings.CutPrefix(key.field, "-"); found {
+				key.field, key.desc = rest, true
+			}
+			if _, ok := lookupField(schema, key.field); !ok {
+				return q, fmt.Errorf("sort: unknown property %q", key.field)
+			}
+			q.sort = append(q.sort, key)
+		}
+	}
+	return q, nil
+}
+
+// parseFilterValue converts a query value to the type of prop, so numbers
+// compare as numbers.
+func parseFilterValue(prop Property, raw string) (interface{}, error) {
+	switch prop.Type {
+	case "integer", "number":
+		n, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return nil, fmt.Errorf("expected a number, got %q", raw)
+		}
+		return n, nil
+	case "boolean":
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("expected true or false, got %q", raw)
+		}
+		return b, nil
+	}
+	return raw, nil
+}
+
+// apply returns the records of list that pass every filter and match the
+// search, in the requested order. Searches rank the records best match
+// first; sorting is stable, so ties keep that rank or their creation
+// order.
+func (q listQuery) apply(list []map[string]interface{}) []map[string]interface{} {
+	if len(q.search) > 0 {
+		hits := searchRecords(list, q.search)
+		list = make([]map[string]interface{}, len(hits))
+		for i, hit := range hits {
+			list[i] = hit.Record
+		}
+	}
+	kept := make([]map[string]interface{}, 0, len(list))
+	for _, obj := range list {
+		if q.matches(obj) {
+			kept = append(kept, obj)
+		}
+	}
+	if len(q.sort) > 0 {
+		sort.SliceStable(kept, func(i, j int) bool {
+			for _, key := range q.sort {
+				c := compareFields(fieldValue(kept[i], key.field), fieldValue(kept[j], key.field))
+				if c != 0 {
+					return (c < 0) != key.desc
+				}
+			}
+			return false
+		})
+	}
+	return kept
+}
+
+// matches reports whether obj passes every filter. Repeating an equality
+// filter, as in ?role=admin&role=owner, accepts any of its values; other
+// operators must hold for all of them.
+func (q listQuery) matches(obj map[string]interface{}) bool {
+	for _, filter := range q.filters {
+		value := fieldValue(obj, filter.field)
+		if value == nil {
+			return false
+		}
+		if filter.op == "eq" {
+			found := false
+			for _, want := range filter.values {
+				found = found || compareFields(value, want) == 0
+			}
+			if !found {
+				return false
+			}
+			continue
+		}
+		for _, want := range filter.values {
+			c := compareFields(value, want)
+			ok := false
+			switch filter.op {
+			case "ne":
+				ok = c != 0
+			case "gt":
+				ok = c > 0
+			case "gte":
+				ok = c >= 0
+			case "lt":
+				ok = c < 0
+			case "lte":
+				ok = c <= 0
+			}
+			if !ok {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// fieldValue returns the value at a dotted field path in obj, or nil.
+func fieldValue(obj map[string]interface{}, field string) interface{} {
+	var value interface{} = obj
+	for _, name := range strings.Split(field, ".") {
+		nested, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = nested[name]
+	}
+	return value
+}
+
+// compareFields orders two record values: numbers numerically, strings
+// lexically (which suits RFC 3339 timestamps) and false before true.
+// Missing values and values of different types sort after the rest.
+func compareFields(a, b interface{}) int {
+	if x, ok := toFloat(a); ok {
+		if y, ok := toFloat(b); ok {
+			switch {
+			case x < y:
+				return -1
+			case x > y:
+				return 1
+			}
+			return 0
+		}
+	}
+	switch x := a.(type) {
+	case string:
+		if y, ok := b.(string); ok {
+			return strings.Compare(x, y)
+		}
+	case bool:
+		if y, ok := b.(bool); ok {
+			switch {
+			case x == y:
+				return 0
+			case !x:
+				return -1
+			}
+			return 1
+		}
+	}
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return 1
+	case b == nil:
+		return -1
+	}
+	// Mismatched types: order by type name so sorting stays consistent.
+	return strings.Compare(jsonTypeName(a), jsonTypeName(b))
+}
+
+// toFloat returns a numeric record value as a float64. Stored values are
+// float64 after JSON decoding, while generated ones may be ints.
+func toFloat(value interface{}) (float64, bool) {
+	switch n := value.(type) {
+	case int:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
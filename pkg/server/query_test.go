@@ -0,0 +1,94 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestUnknownParamsHeader(t *testing.T) {
+	srv := NewServer()
+	srv.currentSchema = createSampleSchema()
+	srv.store = newRecordStore()
+
+	t.Run("Disabled By Default", func(t *testing.T) {
+		rr := performRequest(t, srv.catchAllHandler, http.MethodGet, "/users?nme=alice", nil)
+		if got := rr.Header().Get("X-Unknown-Params"); got != "" {
+			t.Errorf("handler set X-Unknown-Params while disabled: got %v", got)
+		}
+	})
+
+	srv.warnUnknownParams = true
+
+	t.Run("Lists Unknown Params", func(t *testing.T) {
+		rr := performRequest(t, srv.catchAllHandler, http.MethodGet, "/users?nme=alice&name=bob&zz=1", nil)
+		if got := rr.Header().Get("X-Unknown-Params"); got != "nme,zz" {
+			t.Errorf("handler returned wrong X-Unknown-Params: got %v want %v", got, "nme,zz")
+		}
+	})
+
+	t.Run("Omitted When All Known", func(t *testing.T) {
+		rr := performRequest(t, srv.catchAllHandler, http.MethodGet, "/users?name=bob", nil)
+		if got, ok := rr.Header()["X-Unknown-Params"]; ok {
+			t.Errorf("handler set X-Unknown-Params with only known params: got %v", got)
+		}
+	})
+}
+
+func TestListFilterSort(t *testing.T) {
+	srv := NewServer()
+	srv.currentSchema = createSampleSchema()
+	srv.currentSchema.Properties["age"] = Property{Type: "integer"}
+	srv.currentSchema.Properties["active"] = Property{Type: "boolean"}
+	srv.currentSchema.Properties["address"] = Property{Type: "object", Properties: map[string]Property{"city": {Type: "string"}}}
+	srv.store = newRecordStore()
+	for _, body := range []string{
+		`{"name":"Alice","email":"a@example.com","age":34,"active":true,"address":{"city":"Lisbon"}}`,
+		`{"name":"Bob","email":"b@example.com","age":28,"active":false,"address":{"city":"Osaka"}}`,
+		`{"name":"Chloe","email":"c@example.com","age":41,"active":true,"address":{"city":"Lisbon"}}`,
+		`{"name":"Dmitri","email":"d@example.com","age":28,"active":true,"address":{"city":"Austin"}}`,
+	} {
+		if rr := performRequest(t, srv.catchAllHandler, http.MethodPost, "/users", []byte(body)); rr.Code != http.StatusCreated {
+			t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusCreated)
+		}
+	}
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"name=Alice", "[1]"},
+		{"name=Alice&name=Bob", "[1 2]"},
+		{"age_gt=30", "[1 3]"},
+		{"age_gte=28&age_lt=34", "[2 4]"},
+		{"name_ne=Bob&active=true", "[1 3 4]"},
+		{"address.city=Lisbon", "[1 3]"},
+		{"sort=-age", "[3 1 2 4]"},
+		{"sort=age,-name", "[4 2 1 3]"},
+		{"sort=address.city&address.city_ne=Osaka", "[4 1 3]"},
+		{"age_gt=30&sort=-id&limit=1", "[3]"},
+		{"nme=Alice", "[1 2 3 4]"},
+	}
+	for _, tt := range tests {
+		rr := performRequest(t, srv.catchAllHandler, http.MethodGet, "/users?"+tt.query, nil)
+		if status := rr.Code; status != http.StatusOK {
+			t.Errorf("%s: handler returned wrong status code: got %v want %v", tt.query, status, http.StatusOK)
+			continue
+		}
+		if ids := fmt.Sprint(listIDs(t, rr.Body.Bytes())); ids != tt.want {
+			t.Errorf("%s: got ids %v want %v", tt.query, ids, tt.want)
+		}
+	}
+
+	rr := performRequest(t, srv.catchAllHandler, http.MethodGet, "/users?age_gt=30&limit=1", nil)
+	if total := rr.Header().Get("X-Total-Count"); total != "2" {
+		t.Errorf("X-Total-Count does not count filtered records: got %q want %q", total, "2")
+	}
+
+	for _, query := range []string{"age_gt=old", "active=maybe", "sort=nme"} {
+		rr := performRequest(t, srv.catchAllHandler, http.MethodGet, "/users?"+query, nil)
+		if status := rr.Code; status != http.StatusBadRequest {
+			t.Errorf("%s: handler returned wrong status code: got %v want %v", query, status, http.StatusBadRequest)
+		}
+	}
+}
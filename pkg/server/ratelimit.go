@@ -0,0 +1,117 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Rate limit headers sent on every limited response.
+const (
+	rateLimitHeader     = "X-RateLimit-Limit"
+	rateRemainingHeader = "X-RateLimit-Remaining"
+	rateResetHeader     = "X-RateLimit-Reset"
+)
+
+// rateLimitSweep is how many clients the limiter tracks before it forgets
+// those whose window has ended.
+const rateLimitSweep = 1024
+
+// rateLimitConfig limits how many requests each client may make.
+type rateLimitConfig struct {
+	// limit is the number of requests allowed per window; 0 disables
+	// rate limiting.
+	limit  int
+	window time.Duration
+	// by is how clients are told apart: "key" by the API key or token
+	// they send, falling back to their address, or "ip" by address only.
+	by string
+}
+
+// defaultRateLimit counts requests per minute by key, once a limit is set.
+var defaultRateLimit = rateLimitConfig{window: time.Minute, by: "key"}
+
+// rateWindow counts one client's requests in the current fixed window.
+type rateWindow struct {
+	start time.Time
+	count int
+}
+
+// rateLimiter tracks the windows of every client. It is safe for
+// concurrent use.
+type rateLimiter struct {
+	cfg     rateLimitConfig
+	mu      sync.Mutex
+	clients map[string]*rateWindow
+	now     func() time.Time
+}
+
+// take counts a request by client, returning how many it has left in the
+// window, when the window ends, and false if the limit was already spent.
+func (l *rateLimiter) take(client string) (int, time.Time, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	if len(l.clients) >= rateLimitSweep {
+		for name, w := range l.clients {
+			if now.Sub(w.start) >= l.cfg.window {
+				delete(l.clients, name)
+			}
+		}
+	}
+	w, ok := l.clients[client]
+	if !ok || now.Sub(w.start) >= l.cfg.window {
+		w = &rateWindow{start: now}
+		l.clients[client] = w
+	}
+	reset := w.start.Add(l.cfg.window)
+	if w.count >= l.cfg.limit {
+		return 0, reset, false
+	}
+	w.count++
+	return l.cfg.limit - w.count, reset, true
+}
+
+// rateLimitClient names the client a request counts against.
+func rateLimitClient(r *http.Request, by string) string {
+	if by == "key" {
+		if key, sent, err := requestKey(r); sent && err == nil {
+			return "key:" + key
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// withRateLimit answers clients that made more than cfg.limit requests in
+// the current window 429, with Retry-After, so clients' backoff can be
+// tested. Every response carries the X-RateLimit-Limit, -Remaining and
+// -Reset (Unix seconds) headers. The admin API and CORS preflights are
+// not counted.
+func (s *Server) withRateLimit(cfg rateLimitConfig, next http.Handler) http.Handler {
+	limiter := &rateLimiter{cfg: cfg, clients: make(map[string]*rateWindow), now: time.Now}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.isAdminPath(r.URL.Path) || r.Method == http.MethodOptions {
+			next.ServeHTTP(w, r)
+			return
+		}
+		remaining, reset, ok := limiter.take(rateLimitClient(r, cfg.by))
+		header := w.Header()
+		header.Set(rateLimitHeader, strconv.Itoa(cfg.limit))
+		header.Set(rateRemainingHeader, strconv.Itoa(remaining))
+		header.Set(rateResetHeader, strconv.FormatInt(reset.Unix(), 10))
+		if !ok {
+			wait := reset.Sub(limiter.now())
+			header.Set("Retry-After", strconv.Itoa(int((wait+time.Second-1)/time.Second)))
+			writeError(w, http.StatusTooManyRequests, fmt.Sprintf("Rate limit of %d requests per %v exceeded", cfg.limit, cfg.window))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
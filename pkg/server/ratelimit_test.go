@@ -0,0 +1,76 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Unix(1000, 0)
+	limiter := &rateLimiter{
+		cfg:     rateLimitConfig{limit: 2, window: time.Minute},
+		clients: make(map[string]*rateWindow),
+		now:     func() time.Time { return now },
+	}
+	for i, want := range []int{1, 0} {
+		if remaining, _, ok := limiter.take("a"); !ok || remaining != want {
+			t.Errorf("request %d: got %v remaining (%v), want %v", i+1, remaining, ok, want)
+		}
+	}
+	if _, reset, ok := limiter.take("a"); ok || !reset.Equal(now.Add(time.Minute)) {
+		t.Errorf("limit was not enforced: got %v, reset %v", ok, reset)
+	}
+	if _, _, ok := limiter.take("b"); !ok {
+		t.Error("another client shared the limit")
+	}
+	now = now.Add(time.Minute)
+	if remaining, _, ok := limiter.take("a"); !ok || remaining != 1 {
+		t.Errorf("window did not restart: got %v remaining (%v)", remaining, ok)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	handler := NewServer(WithRateLimit(2, time.Hour)).Handler()
+	serve := func(path, key, addr string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = addr
+		if key != "" {
+			req.Header.Set("X-API-Key", key)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	for i := 0; i < 2; i++ {
+		rr := serve("/healthz", "", "192.0.2.1:1234")
+		if rr.Code != http.StatusOK || rr.Header().Get(rateLimitHeader) != "2" || rr.Header().Get(rateRemainingHeader) != strconv.Itoa(1-i) {
+			t.Errorf("request %d: got %v with headers %v", i+1, rr.Code, rr.Header())
+		}
+	}
+	rr := serve("/healthz", "", "192.0.2.1:5678")
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusTooManyRequests)
+	}
+	if retry, _ := strconv.Atoi(rr.Header().Get("Retry-After")); retry < 3599 || retry > 3600 {
+		t.Errorf("wrong Retry-After: got %q", rr.Header().Get("Retry-After"))
+	}
+	if reset, _ := strconv.ParseInt(rr.Header().Get(rateResetHeader), 10, 64); reset <= time.Now().Unix() {
+		t.Errorf("wrong %s: got %q", rateResetHeader, rr.Header().Get(rateResetHeader))
+	}
+
+	if rr := serve("/healthz", "app", "192.0.2.1:1234"); rr.Code != http.StatusOK {
+		t.Errorf("a key was counted against its address: got %v", rr.Code)
+	}
+	if rr := serve("/__admin/config", "", "192.0.2.1:1234"); rr.Code != http.StatusOK {
+		t.Errorf("admin API was rate limited: got %v", rr.Code)
+	}
+
+	if _, err := New(WithRateLimitBy("user")); err == nil {
+		t.Error("unknown client mode was accepted")
+	}
+}
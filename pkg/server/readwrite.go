@@ -0,0 +1,122 @@
+package server
+
+// dropReadOnly removes the readOnly fields of properties from a request
+// body, and from the objects nested in it. Clients cannot set them: a
+// stored record keeps its own values, and a new one is given generated
+// ones.
+func dropReadOnly(properties map[string]Property, body map[string]interface{}) {
+	for name, prop := range properties {
+		value, ok := body[name]
+		if !ok {
+			continue
+		}
+		if prop.ReadOnly {
+			delete(body, name)
+			continue
+		}
+		dropReadOnlyValue(prop, value)
+	}
+}
+
+// dropReadOnlyValue applies dropReadOnly to the objects in a field of a
+// request body.
+func dropReadOnlyValue(prop Property, value interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		dropReadOnly(prop.Properties, v)
+	case []interface{}:
+		if prop.Items != nil {
+			for _, item := range v {
+				dropReadOnlyValue(*prop.Items, item)
+			}
+		}
+	}
+}
+
+// keepReadOnly puts the readOnly fields of original back on the record a
+// merge or JSON patch made of it, so patches cannot change them either.
+func keepReadOnly(properties map[string]Property, patched, original map[string]interface{}) {
+	for name, prop := range properties {
+		if prop.ReadOnly {
+			if value, ok := original[name]; ok {
+				patched[name] = value
+			} else {
+				delete(patched, name)
+			}
+			continue
+		}
+		child, ok := patched[name].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if was, ok := original[name].(map[string]interface{}); ok {
+			keepReadOnly(prop.Properties, child, was)
+		} else {
+			dropReadOnly(prop.Properties, child)
+		}
+	}
+}
+
+// hideWriteOnly returns a record without the writeOnly fields of its
+// schema, such as a password, which are accepted in requests but never
+// sent back. The record is copied where fields are left out, so stored
+// records keep them; without writeOnly fields it is returned as it is.
+func hideWriteOnly(schema *Schema, obj map[string]interface{}) map[string]interface{} {
+	hidden, _ := hideFields(schema.Properties, obj)
+	return hidden
+}
+
+// hideFields returns obj without the writeOnly fields of properties,
+// reporting whether any were left out.
+func hideFields(properties map[string]Property, obj map[string]interface{}) (map[string]interface{}, bool) {
+	var hidden map[string]interface{}
+	for name, prop := range properties {
+		value, ok := obj[name]
+		if !ok {
+			continue
+		}
+		if prop.WriteOnly {
+			if hidden == nil {
+				hidden = copyRecord(obj)
+			}
+			delete(hidden, name)
+			continue
+		}
+		if value, changed := hideValue(prop, value); changed {
+			if hidden == nil {
+				hidden = copyRecord(obj)
+			}
+			hidden[name] = value
+		}
+	}
+	if hidden == nil {
+		return obj, false
+	}
+	return hidden, true
+}
+
+// hideValue applies hideFields to the objects in a field of a record.
+func hideValue(prop Property, value interface{}) (interface{}, bool) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return hideFields(prop.Properties, v)
+	case []interface{}:
+		if prop.Items == nil {
+			return v, false
+		}
+		var list []interface{}
+		for i, item := range v {
+			if item, changed := hideValue(*prop.Items, item); changed {
+				if list == nil {
+					list = append([]interface{}(nil), v...)
+				}
+				list[i] = item
+			}
+		}
+		if list == nil {
+			return v, false
+		}
+		return list, true
+	}
+	return value, false
+}
@@ -0,0 +1,79 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestReadOnlyWriteOnly(t *testing.T) {
+	srv := NewServer()
+	schema := []byte(`{
+  "title": "Account",
+  "type": "object",
+  "properties": {
+    "id": {"type": "integer", "readOnly": true},
+    "username": {"type": "string"},
+    "password": {"type": "string", "writeOnly": true},
+    "createdBy": {"type": "string", "readOnly": true, "default": "system"}
+  },
+  "required": ["id", "username", "password", "createdBy"]
+}`)
+	if rr := performRequest(t, srv.uploadHandler, http.MethodPost, "/upload", schema); rr.Code != http.StatusOK {
+		t.Fatalf("upload failed: %v", rr.Body.String())
+	}
+	decode := func(body []byte) map[string]interface{} {
+		t.Helper()
+		var obj map[string]interface{}
+		if err := json.Unmarshal(body, &obj); err != nil {
+			t.Fatalf("could not decode %s: %v", body, err)
+		}
+		return obj
+	}
+
+	rr := performRequest(t, srv.catchAllHandler, http.MethodPost, "/accounts", []byte(`{"id": 7, "username": "ada", "password": "s3cret", "createdBy": "ada"}`))
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("handler returned wrong status code: got %v want %v: %s", rr.Code, http.StatusCreated, rr.Body.String())
+	}
+	created := decode(rr.Body.Bytes())
+	if _, ok := created["password"]; ok {
+		t.Errorf("writeOnly field was sent back: %v", created)
+	}
+	if created["id"] != 1.0 || created["createdBy"] != "system" {
+		t.Errorf("readOnly fields were taken from the body: %v", created)
+	}
+	if stored, _ := srv.store.get("1"); stored["password"] != "s3cret" {
+		t.Errorf("writeOnly field was not stored: %v", stored)
+	}
+
+	rr = performRequest(t, srv.catchAllHandler, http.MethodGet, "/accounts/1", nil)
+	if _, ok := decode(rr.Body.Bytes())["password"]; ok {
+		t.Errorf("GET sent back the writeOnly field: %s", rr.Body.String())
+	}
+	rr = performRequest(t, srv.catchAllHandler, http.MethodGet, "/accounts?q=s3cret", nil)
+	if strings.TrimSpace(rr.Body.String()) != "[]" {
+		t.Errorf("search matched the writeOnly field: %s", rr.Body.String())
+	}
+
+	rr = performPatch(t, srv, "/accounts/1", mergePatchContentType, `{"createdBy": "eve", "username": "ada2"}`)
+	patched := decode(rr.Body.Bytes())
+	if patched["createdBy"] != "system" || patched["username"] != "ada2" {
+		t.Errorf("merge patch changed a readOnly field: %v", patched)
+	}
+
+	rr = performRequest(t, srv.graphQLSchemaHandler, http.MethodGet, "/schema.graphql", nil)
+	sdl := rr.Body.String()
+	for _, want := range []string{
+		"type Account {\n  createdBy: String\n  id: ID!\n  username: String\n}",
+		"input AccountInput {\n  password: String\n  username: String\n}",
+	} {
+		if !strings.Contains(sdl, want) {
+			t.Errorf("schema lacks %q: got\n%v", want, sdl)
+		}
+	}
+
+	if rr := performRequest(t, srv.uploadHandler, http.MethodPost, "/upload", []byte(`{"title": "Bad", "properties": {"a": {"type": "string", "readOnly": true, "writeOnly": true}}}`)); rr.Code != http.StatusBadRequest {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,127 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"time"
+)
+
+// recordedExchange is one request/response pair written to the session
+// recording.
+type recordedExchange struct {
+	Time         time.Time   `json:"time"`
+	RequestID    string      `json:"requestId,omitempty"`
+	Method       string      `json:"method"`
+	Path         string      `json:"path"`
+	RequestBody  interface{} `json:"requestBody,omitempty"`
+	Status       int         `json:"status"`
+	ResponseBody interface{} `json:"responseBody,omitempty"`
+}
+
+// sessionRecorder appends every exchange to a JSONL stream. Entries are
+// written by a background goroutine so recording stays off the request's
+// critical path.
+type sessionRecorder struct {
+	entries chan recordedExchange
+	done    chan struct{}
+}
+
+// newSessionRecorder starts a recorder writing one JSON object per line to out.
+func newSessionRecorder(out io.Writer) *sessionRecorder {
+	rec := &sessionRecorder{
+		entries: make(chan recordedExchange, 1024),
+		done:    make(chan struct{}),
+	}
+	go func() {
+		defer close(rec.done)
+		encoder := json.NewEncoder(out)
+		for entry := range rec.entries {
+			if err := encoder.Encode(entry); err != nil {
+				log.Println("Error recording exchange:", err)
+			}
+		}
+	}()
+	return rec
+}
+
+// Close flushes the pending entries and stops the recorder.
+func (rec *sessionRecorder) Close() {
+	close(rec.entries)
+	<-rec.done
+}
+
+// middleware records each request handled by next.
+func (rec *sessionRecorder) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var requestBody []byte
+		if r.Body != nil {
+			requestBody, _ = io.ReadAll(r.Body)
+			r.Body.Close()
+			r.Body = io.NopCloser(bytes.NewReader(requestBody))
+		}
+
+		capture := &captureWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(capture, r)
+
+		rec.entries <- recordedExchange{
+			Time:         time.Now().UTC(),
+			RequestID:    r.Header.Get("X-Request-Id"),
+			Method:       r.Method,
+			Path:         r.URL.RequestURI(),
+			RequestBody:  recordedBody(requestBody),
+			Status:       capture.status,
+			ResponseBody: recordedBody(capture.body.Bytes()),
+		}
+	})
+}
+
+// recordedBody embeds JSON bodies as-is so recordings are easy to diff, and
+// falls back to a string for anything else.
+func recordedBody(body []byte) interface{} {
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) == 0 {
+		return nil
+	}
+	if json.Valid(trimmed) {
+		return json.RawMessage(trimmed)
+	}
+	return string(body)
+}
+
+// captureWriter passes a response through while keeping a copy of its
+// status and body.
+type captureWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+	// statusOnly skips the copy of the body, for callers that only need
+	// the status and would otherwise hold whole streamed responses.
+	statusOnly bool
+}
+
+func (c *captureWriter) WriteHeader(status int) {
+	if !c.wroteHeader {
+		c.status = status
+		c.wroteHeader = true
+	}
+	c.ResponseWriter.WriteHeader(status)
+}
+
+func (c *captureWriter) Write(p []byte) (int, error) {
+	c.wroteHeader = true
+	if !c.statusOnly {
+		c.body.Write(p)
+	}
+	return c.ResponseWriter.Write(p)
+}
+
+// Flush lets streaming handlers flush through the capture.
+func (c *captureWriter) Flush() {
+	if f, ok := c.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,53 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSessionRecorder(t *testing.T) {
+	var out bytes.Buffer
+	rec := newSessionRecorder(&out)
+	handler := rec.middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"echo":` + string(body) + `}`))
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/users?x=1", strings.NewReader(`{"name":"a"}`))
+	req.Header.Set("X-Request-Id", "req-1")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	rec.Close()
+
+	if got := rr.Body.String(); got != `{"echo":{"name":"a"}}` {
+		t.Errorf("middleware altered the response or request body: got %v", got)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("recorder wrote wrong number of lines: got %v want %v", len(lines), 1)
+	}
+	var entry struct {
+		RequestID    string                 `json:"requestId"`
+		Method       string                 `json:"method"`
+		Path         string                 `json:"path"`
+		RequestBody  map[string]interface{} `json:"requestBody"`
+		Status       int                    `json:"status"`
+		ResponseBody map[string]interface{} `json:"responseBody"`
+	}
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatalf("could not decode recorded line: %v", err)
+	}
+	if entry.RequestID != "req-1" || entry.Method != http.MethodPost || entry.Path != "/users?x=1" {
+		t.Errorf("recorded wrong request: got %+v", entry)
+	}
+	if entry.Status != http.StatusCreated || entry.RequestBody["name"] != "a" || entry.ResponseBody["echo"] == nil {
+		t.Errorf("recorded wrong exchange: got %+v", entry)
+	}
+}
@@ -0,0 +1,212 @@
+package server
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// maxRefDepth is how many times a recursive $ref is expanded within itself.
+// Deeper occurrences become an object (or whatever type the definition has)
+// without properties, so generated data stays finite.
+const maxRefDepth = 3
+
+// refResolver inlines the local references of one schema.
+type refResolver struct {
+	schema *Schema
+	// depth counts how many times each reference is being expanded on
+	// the current path.
+	depth map[string]int
+}
+
+// resolveRefs replaces every $ref in a schema with the definition it points
+// to: "#" for the schema itself, or "#/definitions/{name}" and
+// "#/$defs/{name}". Chains of references that never reach a definition are
+// rejected, and recursive ones are cut off after maxRefDepth expansions.
+// Definitions are resolved too, so they can be registered as entities, and
+// the parts of a top-level allOf are merged in; see mergeAllOf.
+func resolveRefs(schema *Schema) error {
+	r := &refResolver{schema: schema, depth: make(map[string]int)}
+	if schema.Ref != "" {
+		target, err := r.property("$ref", Property{Ref: schema.Ref})
+		if err != nil {
+			return err
+		}
+		if schema.Type == "" {
+			schema.Type = target.Type
+		}
+		if schema.Properties == nil {
+			schema.Properties = target.Properties
+		}
+		if schema.Required == nil {
+			schema.Required = target.Required
+		}
+		schema.Ref = ""
+	}
+
+	// Resolve against the unresolved schema and definitions, then store the
+	// results, so "#" and definition lookups see the original structure.
+	properties, err := r.properties("", schema.Properties)
+	if err != nil {
+		return err
+	}
+	resolved := make(map[string]map[string]Property, 2)
+	for keyword, defs := range map[string]map[string]Property{"definitions": schema.Definitions, "$defs": schema.Defs} {
+		resolved[keyword], err = r.properties(keyword+".", defs)
+		if err != nil {
+			return err
+		}
+	}
+	keywords := schema.Keywords
+	if err := keywords.subschemas(func(path string, sub *Property) error {
+		resolvedSub, err := r.property(path, *sub)
+		*sub = resolvedSub
+		return err
+	}); err != nil {
+		return err
+	}
+	schema.Properties = properties
+	schema.Keywords = keywords
+	schema.Definitions, schema.Defs = resolved["definitions"], resolved["$defs"]
+	mergeAllOf(schema)
+	return nil
+}
+
+// properties resolves each property of a map, returning a new map. prefix
+// is prepended to property names in errors.
+func (r *refResolver) properties(prefix string, properties map[string]Property) (map[string]Property, error) {
+	if properties == nil {
+		return nil, nil
+	}
+	resolved := make(map[string]Property, len(properties))
+	for name, prop := range properties {
+		p, err := r.property(prefix+name, prop)
+		if err != nil {
+			return nil, err
+		}
+		resolved[name] = p
+	}
+	return resolved, nil
+}
+
+// property returns a copy of prop with its references inlined. path names
+// the property in errors.
+func (r *refResolver) property(path string, prop Property) (Property, error) {
+	var refs []string
+	for prop.Ref != "" {
+		if containsString(refs, prop.Ref) {
+			return prop, fmt.Errorf("property %s: circular $ref %s", path, strings.Join(append(refs, prop.Ref), " -> "))
+		}
+		refs = append(refs, prop.Ref)
+		target, err := r.lookup(prop.Ref)
+		if err != nil {
+			return prop, fmt.Errorf("property %s: %v", path, err)
+		}
+		prop = target
+	}
+	for _, ref := range refs {
+		if r.depth[ref] >= maxRefDepth {
+			typ := prop.Type
+			if typ == "" {
+				typ = "object"
+			}
+			return Property{Type: typ}, nil
+		}
+	}
+	for _, ref := range refs {
+		r.depth[ref]++
+		defer func(ref string) { r.depth[ref]-- }(ref)
+	}
+
+	properties, err := r.properties(path+".", prop.Properties)
+	if err != nil {
+		return prop, err
+	}
+	prop.Properties = properties
+	if prop.Items != nil {
+		items, err := r.property(path+"[]", *prop.Items)
+		if err != nil {
+			return prop, err
+		}
+		prop.Items = &items
+	}
+	if len(prop.PrefixItems) > 0 {
+		prefix := make([]Property, len(prop.PrefixItems))
+		for i, item := range prop.PrefixItems {
+			if prefix[i], err = r.property(fmt.Sprintf("%s[%d]", path, i), item); err != nil {
+				return prop, err
+			}
+		}
+		prop.PrefixItems = prefix
+	}
+	if prop.Contains != nil {
+		contains, err := r.property(path+".contains", *prop.Contains)
+		if err != nil {
+			return prop, err
+		}
+		prop.Contains = &contains
+	}
+	err = prop.Keywords.subschemas(func(name string, sub *Property) error {
+		resolved, err := r.property(path+"."+name, *sub)
+		*sub = resolved
+		return err
+	})
+	return prop, err
+}
+
+// lookup returns the unresolved target of a local reference.
+func (r *refResolver) lookup(ref string) (Property, error) {
+	if ref == "#" {
+		return Property{Type: "object", Properties: r.schema.Properties, Required: r.schema.Required}, nil
+	}
+	pointer, ok := strings.CutPrefix(ref, "#/")
+	if !ok {
+		return Property{}, fmt.Errorf("unsupported $ref %q: only local references (#/definitions/... or #/$defs/...) are resolved", ref)
+	}
+	tokens, err := parsePointer("/" + pointer)
+	if err != nil || len(tokens) != 2 {
+		return Property{}, fmt.Errorf("unsupported $ref %q: expected #/definitions/{name} or #/$defs/{name}", ref)
+	}
+	var defs map[string]Property
+	switch tokens[0] {
+	case "definitions":
+		defs = r.schema.Definitions
+	case "$defs":
+		defs = r.schema.Defs
+	default:
+		return Property{}, fmt.Errorf("unsupported $ref %q: expected #/definitions/{name} or #/$defs/{name}", ref)
+	}
+	target, ok := defs[tokens[1]]
+	if !ok {
+		return Property{}, fmt.Errorf("unresolved $ref %q", ref)
+	}
+	return target, nil
+}
+
+// definitionSchemas returns the object definitions of a resolved schema as
+// schemas of their own, titled by their names, so they can be served as
+// entities too.
+func definitionSchemas(schema *Schema) []*Schema {
+	var defined []*Schema
+	for _, defs := range []map[string]Property{schema.Definitions, schema.Defs} {
+		names := make([]string, 0, len(defs))
+		for name := range defs {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			def := defs[name]
+			if def.Type != "object" || len(def.Properties) == 0 || strings.EqualFold(name, schema.Title) {
+				continue
+			}
+			defined = append(defined, &Schema{
+				Title:      name,
+				Type:       "object",
+				Properties: def.Properties,
+				Required:   def.Required,
+				definition: true,
+			})
+		}
+	}
+	return defined
+}
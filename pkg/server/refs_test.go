@@ -0,0 +1,128 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// parseSchema decodes a schema written as JSON.
+func parseSchema(t *testing.T, definition string) *Schema {
+	t.Helper()
+	var schema Schema
+	if err := json.Unmarshal([]byte(definition), &schema); err != nil {
+		t.Fatalf("could not decode schema: %v", err)
+	}
+	return &schema
+}
+
+func TestResolveRefs(t *testing.T) {
+	schema := parseSchema(t, `{
+		"title": "Owner",
+		"properties": {
+			"id": {"type": "integer"},
+			"home": {"$ref": "#/definitions/address"},
+			"tags": {"type": "array", "items": {"$ref": "#/$defs/tag"}},
+			"work": {"$ref": "#/definitions/office"}
+		},
+		"definitions": {
+			"address": {"type": "object", "properties": {"city": {"type": "string"}}},
+			"office": {"$ref": "#/definitions/address"}
+		},
+		"$defs": {"tag": {"type": "string", "enum": ["a", "b"]}}
+	}`)
+	if err := resolveRefs(schema); err != nil {
+		t.Fatalf("resolveRefs returned error: %v", err)
+	}
+	if home := schema.Properties["home"]; home.Ref != "" || home.Properties["city"].Type != "string" {
+		t.Errorf("resolveRefs did not inline a definition: got %+v", home)
+	}
+	if work := schema.Properties["work"]; work.Properties["city"].Type != "string" {
+		t.Errorf("resolveRefs did not follow a chain of references: got %+v", work)
+	}
+	if items := schema.Properties["tags"].Items; items.Ref != "" || len(items.Enum) != 2 {
+		t.Errorf("resolveRefs did not inline array items: got %+v", items)
+	}
+}
+
+func TestResolveRecursiveRefs(t *testing.T) {
+	srv := NewServer()
+	schema := parseSchema(t, `{
+		"title": "Node",
+		"properties": {
+			"name": {"type": "string"},
+			"children": {"type": "array", "items": {"$ref": "#"}}
+		}
+	}`)
+	if err := resolveRefs(schema); err != nil {
+		t.Fatalf("resolveRefs returned error: %v", err)
+	}
+	depth := 0
+	for prop := schema.Properties["children"]; prop.Items != nil; prop = prop.Items.Properties["children"] {
+		depth++
+		if depth > maxRefDepth+1 {
+			t.Fatalf("resolveRefs expanded a recursive reference without limit")
+		}
+	}
+	if depth != maxRefDepth+1 {
+		t.Errorf("recursive reference expanded to the wrong depth: got %v want %v", depth, maxRefDepth+1)
+	}
+	if data := srv.dummyData(schema, srv.newGenerator(nil)); data["children"] == nil {
+		t.Errorf("recursive schema generated no children: got %v", data)
+	}
+}
+
+func TestResolveRefsErrors(t *testing.T) {
+	for _, tc := range []struct {
+		ref, want string
+	}{
+		{"#/definitions/missing", "unresolved"},
+		{"other.json#/definitions/a", "only local references"},
+		{"#/definitions/loop", "circular"},
+		{"#/properties/name", "unsupported"},
+	} {
+		schema := parseSchema(t, `{"title": "Broken", "properties": {"field": {"$ref": "`+tc.ref+`"}},
+			"definitions": {"loop": {"$ref": "#/definitions/pool"}, "pool": {"$ref": "#/definitions/loop"}}}`)
+		if err := resolveRefs(schema); err == nil || !strings.Contains(err.Error(), tc.want) || !strings.Contains(err.Error(), "field") {
+			t.Errorf("%s: resolveRefs returned wrong error: %v", tc.ref, err)
+		}
+	}
+}
+
+func TestUploadRegistersDefinitions(t *testing.T) {
+	srv := NewServer()
+
+	upload := func(body string) {
+		t.Helper()
+		if rr := performRequest(t, srv.uploadHandler, http.MethodPost, "/upload", []byte(body)); rr.Code != http.StatusOK {
+			t.Fatalf("upload failed: %v", rr.Body.String())
+		}
+	}
+	upload(`{"title": "Toy", "properties": {"id": {"type": "integer"}, "label": {"type": "string"}}}`)
+	upload(`{
+		"title": "Owner",
+		"properties": {"pet": {"$ref": "#/definitions/Pet"}, "toy": {"$ref": "#/definitions/Toy"}},
+		"definitions": {
+			"Pet": {"type": "object", "properties": {"species": {"type": "string"}}},
+			"Toy": {"type": "object", "properties": {"squeaks": {"type": "boolean"}}},
+			"Color": {"type": "string"}
+		}
+	}`)
+
+	rr := performRequest(t, srv.catchAllHandler, http.MethodGet, "/owners/1", nil)
+	if !strings.Contains(rr.Body.String(), `"species"`) {
+		t.Errorf("referenced definition was not used for generation: got %v", rr.Body.String())
+	}
+	rr = performRequest(t, srv.catchAllHandler, http.MethodGet, "/pets/1", nil)
+	if status := rr.Code; status != http.StatusOK || !strings.Contains(rr.Body.String(), `"species"`) {
+		t.Errorf("definition was not served as an entity: got %v %v", status, rr.Body.String())
+	}
+	rr = performRequest(t, srv.catchAllHandler, http.MethodGet, "/toys/1", nil)
+	if !strings.Contains(rr.Body.String(), `"label"`) {
+		t.Errorf("definition replaced an uploaded schema: got %v", rr.Body.String())
+	}
+	if _, ok := srv.schemas["color"]; ok {
+		t.Errorf("a non-object definition was registered as an entity")
+	}
+}
@@ -0,0 +1,304 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// relation links a child schema to a parent one through a property of the
+// child, declared with x-ref, that holds parent ids.
+type relation struct {
+	server  *Server
+	child   *Schema
+	records *recordStore
+	key     string
+}
+
+// findRelation returns the relation through which the entity segment
+// names refers to parent, if it does.
+func (s *Server) findRelation(parent *Schema, segment string) (relation, bool) {
+	s.stateMu.RLock()
+	defer s.stateMu.RUnlock()
+	for _, key := range s.sortedSchemaKeys() {
+		child := s.schemas[key]
+		if !s.matchesEntity(segment, child) {
+			continue
+		}
+		if name := refProperty(child, parent); name != "" {
+			return relation{server: s, child: child, records: s.stores[key], key: name}, true
+		}
+	}
+	return relation{}, false
+}
+
+// childRelations returns every relation whose parent is schema, ordered by
+// child title. The caller must hold stateMu.
+func (s *Server) childRelations(parent *Schema) []relation {
+	var relations []relation
+	for _, key := range s.sortedSchemaKeys() {
+		if name := refProperty(s.schemas[key], parent); name != "" {
+			relations = append(relations, relation{server: s, child: s.schemas[key], records: s.stores[key], key: name})
+		}
+	}
+	return relations
+}
+
+// refProperty returns the first property of child, by name, whose x-ref
+// names parent, or "" when there is none.
+func refProperty(child, parent *Schema) string {
+	names := make([]string, 0, len(child.Properties))
+	for name, prop := range child.Properties {
+		if strings.EqualFold(prop.XRef, parent.Title) {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return ""
+	}
+	sort.Strings(names)
+	return names[0]
+}
+
+// foreignKey converts a parent id to the type of the relation's key
+// property.
+func (rel relation) foreignKey(parentID interface{}) interface{} {
+	if rel.child.Properties[rel.key].Type == "string" {
+		return fmt.Sprint(parentID)
+	}
+	return parentID
+}
+
+// list returns the page of the children of parentID a request asked for,
+// filtered and sorted by query, and the number of them on all pages. Until
+// the first write to the child store the children are generated records
+// pointing at the parent, as for listRecords.
+func (rel relation) list(r *http.Request, parentID interface{}, page pagination, query listQuery) ([]map[string]interface{}, int) {
+	want := fmt.Sprint(parentID)
+	var list []map[string]interface{}
+	for _, obj := range rel.server.visibleRecords(r, rel.records.list()) {
+		if obj[rel.key] != nil && fmt.Sprint(obj[rel.key]) == want {
+			list = append(list, obj)
+		}
+	}
+	total, from := len(list), 0
+	if total == 0 && rel.records.pristine() {
+		fk := rel.foreignKey(parentID)
+		list, total, from = rel.server.generateList(r, rel.child, page, query, func(obj map[string]interface{}) { obj[rel.key] = fk })
+	}
+	for i, obj := range list {
+		list[i] = hideWriteOnly(rel.child, obj)
+	}
+	if query.active() {
+		list = query.apply(list)
+		total = len(list)
+	}
+	start, end := page.bounds(total)
+	return list[start-from : end-from], total
+}
+
+// serveChildren answers GET /{parents}/{id}/{children} with the records
+// of rel that refer to the parent. A deleted parent, or one never stored
+// when -strict-get is set, has no children to list.
+func (s *Server) serveChildren(w http.ResponseWriter, r *http.Request, parent *Schema, records *recordStore, rawID string, rel relation) {
+	if !rel.child.allowsMethod(http.MethodGet) {
+		writeNotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w, "Method not allowed for this route", http.MethodGet)
+		return
+	}
+	parentID, ok := parseRecordID(w, parent, rawID)
+	if !ok {
+		return
+	}
+	key := fmt.Sprint(parentID)
+	if _, stored := records.get(key); !stored && (s.strictGet || records.wasDeleted(key)) {
+		writeNotFound(w, r)
+		return
+	}
+
+	page, err := parsePagination(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid pagination: "+err.Error())
+		return
+	}
+	query, err := parseListQuery(r.URL.Query(), rel.child)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid query: "+err.Error())
+		return
+	}
+	expansions, err := s.parseExpand(r.URL.Query(), rel.child)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid expand: "+err.Error())
+		return
+	}
+	fields, err := parseFields(r.URL.Query(), rel.child, expansions)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid fields: "+err.Error())
+		return
+	}
+	s.setUnknownParamsHeader(w, r, rel.child)
+	list, total := rel.list(r, parentID, page, query)
+	expandRecords(r, list, expansions)
+	selectListFields(list, fields)
+	page.setHeaders(w, r, s.route(r.URL.Path), total)
+	if wantsNDJSON(r) {
+		streamNDJSON(w, len(list), func(i int) interface{} { return list[i] })
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(list); err != nil {
+		log.Println("Error encoding response:", err)
+	}
+}
+
+// childPaths returns the path items of the nested routes listing the
+// children of schema. The caller must hold stateMu.
+func (s *Server) childPaths(schema *Schema) map[string]interface{} {
+	idType := "integer"
+	if !schema.integerIDs() {
+		idType = "string"
+	}
+	paths := make(map[string]interface{})
+	for _, rel := range s.childRelations(schema) {
+		if !rel.child.allowsMethod(http.MethodGet) {
+			continue
+		}
+		children := entityName(rel.child)
+		get := s.operation("list"+pluralize(rel.child.Title)+"Of"+schema.Title,
+			fmt.Sprintf("List the %s of a %s", children, strings.ToLower(schema.Title)), nil,
+			map[string]interface{}{"type": "array", "items": schemaRef(rel.child.Title)}, 400, 404)
+		get["parameters"] = s.listParameters()
+		paths["/"+entityName(schema)+"/{id}/"+children] = map[string]interface{}{
+			"parameters": []interface{}{map[string]interface{}{
+				"name":     "id",
+				"in":       "path",
+				"required": true,
+				"schema":   map[string]interface{}{"type": idType},
+			}},
+			"get": get,
+		}
+	}
+	return paths
+}
+
+// expandParam is the query parameter naming the references to embed, as in
+// ?expand=user,warehouse.
+const expandParam = "expand"
+
+// expansion embeds the record an x-ref property refers to.
+type expansion struct {
+	server *Server
+	// name is the key the record is embedded under.
+	name string
+	// key is the property holding the record's id.
+	key     string
+	target  *Schema
+	records *recordStore
+}
+
+// expandName returns the name an x-ref property is expanded under: the
+// property without an Id or _id suffix, so userId embeds user and owner
+// replaces its id with the record.
+func expandName(property string) string {
+	for _, suffix := range []string{"_id", "Id", "ID"} {
+		if name, ok := strings.CutSuffix(property, suffix); ok && name != "" {
+			return name
+		}
+	}
+	return property
+}
+
+// parseExpand resolves the names in the expand parameter against the x-ref
+// properties of schema. Each name may be the property itself or its
+// expandName.
+func (s *Server) parseExpand(query url.Values, schema *Schema) ([]expansion, error) {
+	var names []string
+	for _, raw := range query[expandParam] {
+		for _, name := range strings.Split(raw, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, name)
+			}
+		}
+	}
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	s.stateMu.RLock()
+	defer s.stateMu.RUnlock()
+	var expansions []expansion
+	for _, name := range names {
+		key := ""
+		if prop, ok := schema.Properties[name]; ok && prop.XRef != "" {
+			key = name
+		}
+		for property, prop := range schema.Properties {
+			if key == "" && prop.XRef != "" && name == expandName(property) {
+				key = property
+			}
+		}
+		if key == "" {
+			return nil, fmt.Errorf("%s has no reference named %q", schema.Title, name)
+		}
+		target, ok := s.lookupSchema(schema.Properties[key].XRef)
+		if !ok {
+			return nil, fmt.Errorf("%s refers to %s, which is not registered", key, schema.Properties[key].XRef)
+		}
+		expansions = append(expansions, expansion{
+			server:  s,
+			name:    expandName(key),
+			key:     key,
+			target:  target,
+			records: s.stores[strings.ToLower(target.Title)],
+		})
+	}
+	return expansions, nil
+}
+
+// expandRecords embeds into each record of list the records its
+// references point at: the stored one, or else one generated for the id as
+// GET /{entity}/{id} would. References to deleted records, or to records
+// never stored when -strict-get is set, embed null.
+func expandRecords(r *http.Request, list []map[string]interface{}, expansions []expansion) {
+	for _, obj := range list {
+		for _, e := range expansions {
+			obj[e.name] = e.resolve(r, obj[e.key])
+		}
+	}
+}
+
+// resolve returns the record of the expansion's target whose id is ref.
+func (e expansion) resolve(r *http.Request, ref interface{}) interface{} {
+	if ref == nil {
+		return nil
+	}
+	key := fmt.Sprint(ref)
+	if stored, ok := e.records.get(key); ok {
+		if e.server.softDeleted(stored) {
+			return nil
+		}
+		return hideWriteOnly(e.target, stored)
+	}
+	if e.server.strictGet || e.records.wasDeleted(key) {
+		return nil
+	}
+	var id interface{} = key
+	if prop, ok := e.target.Properties[e.target.idKey()]; ok && prop.Type == "integer" {
+		n, err := strconv.Atoi(key)
+		if err != nil {
+			return nil
+		}
+		id = n
+	}
+	obj := e.server.dummyData(e.target, e.server.newRecordGenerator(r, e.target, key))
+	obj[e.target.idKey()] = id
+	return hideWriteOnly(e.target, obj)
+}
@@ -0,0 +1,115 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestChildRoutes(t *testing.T) {
+	srv := NewServer()
+	for _, schema := range []string{
+		`{"title": "Order", "type": "object", "properties": {"id": {"type": "integer"}, "userId": {"type": "integer", "x-ref": "User"}, "total": {"type": "number"}}}`,
+		`{"title": "User", "type": "object", "properties": {"id": {"type": "integer"}, "name": {"type": "string"}}}`,
+	} {
+		if _, err := srv.loadSchema(strings.NewReader(schema)); err != nil {
+			t.Fatalf("could not load schema: %v", err)
+		}
+	}
+	decode := func(body []byte) []map[string]interface{} {
+		t.Helper()
+		var list []map[string]interface{}
+		if err := json.Unmarshal(body, &list); err != nil {
+			t.Fatalf("could not decode response: %v", err)
+		}
+		return list
+	}
+
+	// Until the first write, generated children point at the parent.
+	rr := performRequest(t, srv.catchAllHandler, http.MethodGet, "/users/7/orders", nil)
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+	list := decode(rr.Body.Bytes())
+	if len(list) != srv.listSize || list[0]["userId"] != float64(7) {
+		t.Errorf("handler returned unexpected children: got %v", rr.Body.String())
+	}
+
+	for _, body := range []string{`{"userId": 1, "total": 5}`, `{"userId": 2, "total": 6}`, `{"userId": 1, "total": 7}`} {
+		performRequest(t, srv.catchAllHandler, http.MethodPost, "/orders", []byte(body))
+	}
+	rr = performRequest(t, srv.catchAllHandler, http.MethodGet, "/users/1/orders?sort=-total", nil)
+	list = decode(rr.Body.Bytes())
+	if len(list) != 2 || list[0]["total"] != float64(7) || rr.Header().Get("X-Total-Count") != "2" {
+		t.Errorf("handler returned unexpected children: got %v", rr.Body.String())
+	}
+
+	cases := []struct {
+		name, method, path string
+		status             int
+	}{
+		{"Wrong Method", http.MethodPost, "/users/1/orders", http.StatusMethodNotAllowed},
+		{"Invalid ID", http.MethodGet, "/users/abc/orders", http.StatusBadRequest},
+		{"No Relation", http.MethodGet, "/orders/1/users", http.StatusNotFound},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rr := performRequest(t, srv.catchAllHandler, tc.method, tc.path, nil)
+			if status := rr.Code; status != tc.status {
+				t.Errorf("handler returned wrong status code: got %v want %v", status, tc.status)
+			}
+		})
+	}
+
+	performRequest(t, srv.catchAllHandler, http.MethodPost, "/users", []byte(`{"id": 1, "name": "alice"}`))
+	performRequest(t, srv.catchAllHandler, http.MethodDelete, "/users/1", nil)
+	if rr := performRequest(t, srv.catchAllHandler, http.MethodGet, "/users/1/orders", nil); rr.Code != http.StatusNotFound {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusNotFound)
+	}
+
+	if _, ok := srv.openAPISpec()["paths"].(map[string]interface{})["/users/{id}/orders"]; !ok {
+		t.Errorf("OpenAPI document does not describe the nested route")
+	}
+	if _, err := srv.loadSchema(strings.NewReader(`{"title": "Bad", "type": "object", "properties": {"owner": {"type": "object", "x-ref": "User"}}}`)); err == nil {
+		t.Errorf("loadSchema accepted x-ref on an object property")
+	}
+}
+
+func TestExpand(t *testing.T) {
+	srv := NewServer()
+	for _, schema := range []string{
+		`{"title": "User", "type": "object", "properties": {"id": {"type": "integer"}, "name": {"type": "string"}}}`,
+		`{"title": "Order", "type": "object", "properties": {"id": {"type": "integer"}, "userId": {"type": "integer", "x-ref": "User"}, "total": {"type": "number"}}}`,
+	} {
+		if _, err := srv.loadSchema(strings.NewReader(schema)); err != nil {
+			t.Fatalf("could not load schema: %v", err)
+		}
+	}
+	performRequest(t, srv.catchAllHandler, http.MethodPost, "/users", []byte(`{"id": 3, "name": "alice"}`))
+	performRequest(t, srv.catchAllHandler, http.MethodPost, "/orders", []byte(`{"id": 5, "userId": 3, "total": 9}`))
+
+	rr := performRequest(t, srv.catchAllHandler, http.MethodGet, "/orders/5?expand=user", nil)
+	var order map[string]interface{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &order); err != nil {
+		t.Fatalf("could not decode response: %v", err)
+	}
+	user, _ := order["user"].(map[string]interface{})
+	if user["name"] != "alice" || order["userId"] != float64(3) {
+		t.Errorf("handler did not embed the user: got %v", rr.Body.String())
+	}
+
+	// Records never stored are generated for the referenced id.
+	rr = performRequest(t, srv.catchAllHandler, http.MethodGet, "/orders/8?expand=userId", nil)
+	if status := rr.Code; status != http.StatusOK || !strings.Contains(rr.Body.String(), `"user":{"id":1`) {
+		t.Errorf("handler returned unexpected response: got %v %v", status, rr.Body.String())
+	}
+
+	rr = performRequest(t, srv.catchAllHandler, http.MethodGet, "/orders?expand=user", nil)
+	if !strings.Contains(rr.Body.String(), `"name":"alice"`) {
+		t.Errorf("handler did not expand the list: got %v", rr.Body.String())
+	}
+	if rr := performRequest(t, srv.catchAllHandler, http.MethodGet, "/orders?expand=warehouse", nil); rr.Code != http.StatusBadRequest {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusBadRequest)
+	}
+}
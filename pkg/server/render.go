@@ -0,0 +1,262 @@
+package server
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Formats responses can be rendered in.
+const (
+	formatJSON = "json"
+	formatXML  = "xml"
+	formatYAML = "yaml"
+	formatCSV  = "csv"
+	// formatNDJSON is only asked for with ?format=ndjson; the Accept
+	// header's NDJSON counts as formatJSON, which the handlers stream.
+	formatNDJSON = "ndjson"
+)
+
+// csvContentType is the media type of CSV list exports.
+const csvContentType = "text/csv"
+
+// formatParam is the query parameter that asks for a list as CSV or
+// NDJSON, with ?format=csv or ?format=ndjson, for clients that cannot set
+// an Accept header, such as a download link.
+const formatParam = "format"
+
+// mediaTypeFormat returns the format a media range of an Accept header
+// asks for, whether it is a wildcard, and false if it is none this server
+// produces.
+func mediaTypeFormat(mediaType string) (format string, wildcard, ok bool) {
+	switch {
+	case mediaType == "*/*", mediaType == "application/*":
+		return formatJSON, true, true
+	case mediaType == "application/json", mediaType == ndjsonContentType,
+		strings.HasPrefix(mediaType, "application/") && strings.HasSuffix(mediaType, "+json"):
+		return formatJSON, false, true
+	case mediaType == xmlContentType, mediaType == "text/xml",
+		strings.HasPrefix(mediaType, "application/") && strings.HasSuffix(mediaType, "+xml"):
+		return formatXML, false, true
+	case mediaType == yamlContentType, mediaType == "application/x-yaml", mediaType == "text/yaml":
+		return formatYAML, false, true
+	case mediaType == csvContentType:
+		return formatCSV, false, true
+	}
+	return "", false, false
+}
+
+// preferredFormat picks the format an Accept header prefers: the one with
+// the highest q, a media type beating a wildcard of the same q and earlier
+// entries beating later ones. It returns formatJSON for an empty header and
+// "" when no format is acceptable.
+func preferredFormat(accept string) string {
+	if strings.TrimSpace(accept) == "" {
+		return formatJSON
+	}
+	best, bestQ, bestWildcard := "", 0.0, true
+	for _, part := range strings.Split(accept, ",") {
+		params := strings.Split(part, ";")
+		format, wildcard, ok := mediaTypeFormat(strings.ToLower(strings.TrimSpace(params[0])))
+		if !ok {
+			continue
+		}
+		q := 1.0
+		for _, param := range params[1:] {
+			name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if strings.EqualFold(name, "q") {
+				if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		if q <= 0 {
+			continue
+		}
+		if q > bestQ || (q == bestQ && bestWildcard && !wildcard) {
+			best, bestQ, bestWildcard = format, q, wildcard
+		}
+	}
+	return best
+}
+
+// withRenderings renders the JSON responses of clients preferring XML or
+// YAML in that format instead. Lists of an entity become a collection
+// element of singular ones in XML, such as <users><user>...</user></users>.
+// Lists asked for as CSV become one row per record; other responses to
+// CSV clients stay JSON. envelope is the key lists are wrapped under by
+// withEnvelope, if any.
+func (s *Server) withRenderings(envelope string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept")
+		format := preferredFormat(r.Header.Get("Accept"))
+		if r.URL.Query().Get(formatParam) == formatCSV {
+			format = formatCSV
+		}
+		if format != formatXML && format != formatYAML && format != formatCSV {
+			next.ServeHTTP(w, r)
+			return
+		}
+		hw := &heldWriter{ResponseWriter: w}
+		next.ServeHTTP(hw, r)
+		if hw.status == 0 {
+			hw.status = http.StatusOK
+		}
+
+		header := w.Header()
+		mediaType, _, _ := mime.ParseMediaType(header.Get("Content-Type"))
+		schema, list := s.responseEntity(r)
+		if mediaType != "application/json" && mediaType != problemContentType ||
+			format == formatCSV && (hw.status >= 300 || !list) {
+			w.WriteHeader(hw.status)
+			w.Write(hw.body.Bytes())
+			return
+		}
+		var body []byte
+		var err error
+		var contentType string
+		switch format {
+		case formatCSV:
+			body, err = marshalCSV(hw.body.Bytes(), schema, r.URL.Query(), envelope)
+			contentType = csvContentType + "; charset=utf-8"
+			header.Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.csv"`, entityName(schema)))
+		case formatYAML:
+			body, err = marshalYAML(json.RawMessage(hw.body.Bytes()))
+			contentType = yamlContentType
+		default:
+			root, item := "error", "item"
+			if hw.status < 400 {
+				root, item = xmlRoot(schema, list)
+			}
+			contentType = xmlContentType
+			if mediaType == problemContentType {
+				root, contentType = "problem", "application/problem+xml"
+			}
+			body, err = marshalXML(hw.body.Bytes(), root, item)
+		}
+		if err != nil {
+			// Not a single JSON document, such as a streamed list.
+			header.Del("Content-Disposition")
+			w.WriteHeader(hw.status)
+			w.Write(hw.body.Bytes())
+			return
+		}
+		header.Del("Content-Length")
+		header.Set("Content-Type", contentType)
+		w.WriteHeader(hw.status)
+		w.Write(body)
+	})
+}
+
+// responseEntity returns the schema whose records the path serves, or nil,
+// and whether it serves a list of them: /users and /users/1/orders do,
+// /users/1 does not.
+func (s *Server) responseEntity(r *http.Request) (*Schema, bool) {
+	path := strings.TrimPrefix(r.URL.Path, s.basePath)
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	schema, _, onEntity := s.entityState(segments[0])
+	if !onEntity {
+		return nil, false
+	}
+	if len(segments) == 3 {
+		if rel, ok := s.findRelation(schema, segments[2]); ok {
+			return rel.child, true
+		}
+	}
+	return schema, len(segments) == 1 || len(segments) == 2 && segments[1] == sampleSegment
+}
+
+// xmlRoot names the root element of an XML response and the elements of a
+// top-level array, after the entity it serves: users and user for /users,
+// or user for /users/1.
+func xmlRoot(schema *Schema, list bool) (root, item string) {
+	if schema == nil {
+		return "response", "item"
+	}
+	single := strings.ToLower(schema.Title)
+	if !list {
+		return single, "item"
+	}
+	return entityName(schema), single
+}
+
+// marshalCSV renders a JSON list response as CSV: a header row, then one
+// row per record. The columns are id and the schema's other properties in
+// name order, leaving out writeOnly ones, or the ?fields= asked for. Objects and arrays are written as
+// JSON, null as an empty cell.
+func marshalCSV(data []byte, schema *Schema, query url.Values, envelope string) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var generic interface{}
+	if err := decoder.Decode(&generic); err != nil {
+		return nil, err
+	}
+	// Debug and enveloped lists carry their records under a key.
+	if wrapped, ok := generic.(map[string]interface{}); ok {
+		if _, debug := wrapped["_meta"]; debug {
+			generic = wrapped["data"]
+		} else if envelope != "" {
+			generic = wrapped[envelope]
+		}
+	}
+	records, ok := generic.([]interface{})
+	if !ok {
+		return nil, errors.New("not a list")
+	}
+
+	var columns []string
+	for _, raw := range query[fieldsParam] {
+		for _, field := range strings.Split(raw, ",") {
+			if field = strings.TrimSpace(field); field != "" {
+				columns = append(columns, field)
+			}
+		}
+	}
+	if len(columns) == 0 {
+		for name, prop := range schema.Properties {
+			if name != schema.idKey() && !prop.WriteOnly {
+				columns = append(columns, name)
+			}
+		}
+		sort.Strings(columns)
+		columns = append([]string{schema.idKey()}, columns...)
+	}
+
+	var buf bytes.Buffer
+	out := csv.NewWriter(&buf)
+	out.Write(columns)
+	row := make([]string, len(columns))
+	for _, record := range records {
+		obj, _ := record.(map[string]interface{})
+		for i, column := range columns {
+			row[i] = csvCell(fieldValue(obj, column))
+		}
+		out.Write(row)
+	}
+	out.Flush()
+	return buf.Bytes(), out.Error()
+}
+
+// csvCell renders one value of a record as a CSV cell.
+func csvCell(v interface{}) string {
+	switch value := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case json.Number:
+		return value.String()
+	case bool:
+		return strconv.FormatBool(value)
+	}
+	encoded, _ := json.Marshal(v)
+	return string(encoded)
+}
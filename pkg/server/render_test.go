@@ -0,0 +1,104 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPreferredFormat(t *testing.T) {
+	tests := map[string]string{
+		"":                      formatJSON,
+		"application/json":      formatJSON,
+		"application/xml":       formatXML,
+		"text/yaml":             formatYAML,
+		"application/xml, */*":  formatXML,
+		"*/*, application/yaml": formatYAML,
+		"application/xml;q=0.5, application/json": formatJSON,
+		"application/yaml;q=0, application/xml":   formatXML,
+		"text/html":                               "",
+	}
+	for accept, want := range tests {
+		if got := preferredFormat(accept); got != want {
+			t.Errorf("preferredFormat(%q) = %q, want %q", accept, got, want)
+		}
+	}
+}
+
+func TestRenderings(t *testing.T) {
+	s := NewServer()
+	s.currentSchema = createSampleSchema()
+	s.store = newRecordStore()
+	s.registerSchema(s.currentSchema)
+	s.stores["user"] = s.store
+	s.store.create(map[string]interface{}{"id": float64(1), "name": "Ada & Bob", "email": "a@example.com"}, true)
+
+	serve := func(path, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Accept", accept)
+		rr := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := serve("/users", "application/xml")
+	want := `<users><user><email>a@example.com</email><id>1</id><name>Ada &amp; Bob</name></user></users>`
+	if rr.Header().Get("Content-Type") != xmlContentType || !strings.Contains(rr.Body.String(), want) {
+		t.Errorf("handler returned unexpected XML: got %v %v", rr.Header().Get("Content-Type"), rr.Body.String())
+	}
+	rr = serve("/users/1", "application/yaml")
+	if rr.Header().Get("Content-Type") != yamlContentType || rr.Body.String() != "email: a@example.com\nid: 1\nname: Ada & Bob\n" {
+		t.Errorf("handler returned unexpected YAML: got %v %q", rr.Header().Get("Content-Type"), rr.Body.String())
+	}
+	rr = serve("/users/abc", "text/xml")
+	if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "<problem><code>bad_request</code>") {
+		t.Errorf("handler returned unexpected XML error: got %v %v", rr.Code, rr.Body.String())
+	}
+}
+
+func TestXMLName(t *testing.T) {
+	tests := map[string]string{"name": "name", "first name": "first_name", "2fa": "_2fa", "xmlns": "_xmlns", "": "_"}
+	for key, want := range tests {
+		if got := xmlName(key); got != want {
+			t.Errorf("xmlName(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestCSVExport(t *testing.T) {
+	s := NewServer()
+	s.currentSchema = &Schema{Title: "User", Type: "object", Properties: map[string]Property{
+		"id": {Type: "integer"}, "name": {Type: "string"}, "tags": {Type: "array", Items: &Property{Type: "string"}},
+		"password": {Type: "string", WriteOnly: true},
+	}}
+	s.store = newRecordStore()
+	s.registerSchema(s.currentSchema)
+	s.stores["user"] = s.store
+	s.store.create(map[string]interface{}{"name": "Ada, Countess", "tags": []interface{}{"a", "b"}, "password": "secret"}, true)
+	s.store.create(map[string]interface{}{"name": "Bob"}, true)
+
+	req := httptest.NewRequest(http.MethodGet, "/users", nil)
+	req.Header.Set("Accept", "text/csv")
+	rr := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rr, req)
+	// The writeOnly password gets no column.
+	want := "id,name,tags\n1,\"Ada, Countess\",\"[\"\"a\"\",\"\"b\"\"]\"\n2,Bob,\n"
+	if rr.Body.String() != want || !strings.HasPrefix(rr.Header().Get("Content-Type"), csvContentType) ||
+		rr.Header().Get("Content-Disposition") != `attachment; filename="users.csv"` {
+		t.Errorf("handler returned unexpected CSV: got %v %q", rr.Header(), rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	s.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/users?format=csv&fields=name&sort=-name", nil))
+	if rr.Body.String() != "name\nBob\n\"Ada, Countess\"\n" {
+		t.Errorf("handler returned unexpected CSV: got %q", rr.Body.String())
+	}
+
+	// Single records stay JSON.
+	rr = httptest.NewRecorder()
+	s.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/users/1?format=csv", nil))
+	if rr.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("single record was not sent as JSON: got %v", rr.Header().Get("Content-Type"))
+	}
+}
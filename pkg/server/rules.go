@@ -0,0 +1,204 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// accessRule requires one of Roles for requests of Method to an entity's
+// routes, e.g. DELETE /users for admin. An empty or * method matches any,
+// as does an entity of *.
+type accessRule struct {
+	ID     int      `json:"id"`
+	Method string   `json:"method"`
+	Entity string   `json:"entity"`
+	Roles  []string `json:"roles"`
+}
+
+// String describes the requests the rule covers, e.g. DELETE /users.
+func (rule accessRule) String() string {
+	method := rule.Method
+	if method == "" {
+		method = "*"
+	}
+	return method + " /" + rule.Entity
+}
+
+// requirement describes the rule as a 403 response gives it, e.g. DELETE
+// /users requires role admin.
+func (rule accessRule) requirement() string {
+	return fmt.Sprintf("%s requires role %s", rule, strings.Join(rule.Roles, " or "))
+}
+
+// coversMethod reports whether the rule applies to requests of method. A
+// rule for GET also covers HEAD, which reads the same resource.
+func (rule accessRule) coversMethod(method string) bool {
+	switch rule.Method {
+	case "", "*", method:
+		return true
+	}
+	return rule.Method == http.MethodGet && method == http.MethodHead
+}
+
+// ruleSet holds the access rules. It is safe for concurrent use.
+type ruleSet struct {
+	mu     sync.Mutex
+	rules  []accessRule
+	nextID int
+}
+
+// splitRoles returns the non-empty roles in raw, separated by sep.
+func splitRoles(raw, sep string) []string {
+	var roles []string
+	for _, role := range strings.Split(raw, sep) {
+		if role = strings.TrimSpace(role); role != "" {
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
+
+// parseAccessRule reads a rule as the -access-rules flag gives it: the
+// method and route, then = and the roles separated by |, e.g.
+// "DELETE /users=admin".
+func parseAccessRule(raw string) (accessRule, error) {
+	target, roles, found := strings.Cut(raw, "=")
+	fields := strings.Fields(target)
+	if !found || len(fields) != 2 {
+		return accessRule{}, fmt.Errorf("access rule %q must look like METHOD /entity=role", raw)
+	}
+	return checkAccessRule(accessRule{Method: fields[0], Entity: fields[1], Roles: splitRoles(roles, "|")})
+}
+
+// checkAccessRule normalizes a rule, reporting an error if it names no
+// entity or no roles.
+func checkAccessRule(rule accessRule) (accessRule, error) {
+	rule.Method = strings.ToUpper(strings.TrimSpace(rule.Method))
+	rule.Entity = strings.Trim(strings.TrimSpace(rule.Entity), "/")
+	if rule.Entity == "" {
+		return rule, fmt.Errorf("access rules must name an entity")
+	}
+	if len(rule.Roles) == 0 {
+		return rule, fmt.Errorf("access rule %s must name at least one role", rule)
+	}
+	return rule, nil
+}
+
+// reset forgets every rule.
+func (s *ruleSet) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rules, s.nextID = nil, 1
+}
+
+// add stores rule under the next id and returns it.
+func (s *ruleSet) add(rule accessRule) accessRule {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rule.ID = s.nextID
+	s.nextID++
+	s.rules = append(s.rules, rule)
+	return rule
+}
+
+// remove deletes the rule with id, reporting whether there was one.
+func (s *ruleSet) remove(id int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, rule := range s.rules {
+		if rule.ID == id {
+			s.rules = append(s.rules[:i], s.rules[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// list returns the rules in the order they were added.
+func (s *ruleSet) list() []accessRule {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]accessRule{}, s.rules...)
+}
+
+// deniedRule returns the first access rule covering r that none of roles
+// satisfies.
+func (s *Server) deniedRule(r *http.Request, roles []string) (accessRule, bool) {
+	path, _ := strings.CutPrefix(r.URL.Path, s.basePath)
+	segment, _, _ := strings.Cut(strings.TrimPrefix(path, "/"), "/")
+	return s.deniedAccess(r.Method, segment, roles)
+}
+
+// deniedAccess returns the first access rule covering method requests to
+// the entity routed at segment that none of roles satisfies. Handlers that
+// reach entities by other routes, such as /graphql, check it per entity.
+func (s *Server) deniedAccess(method, segment string, roles []string) (accessRule, bool) {
+	for _, rule := range s.accessRules.list() {
+		if !rule.coversMethod(method) || !s.ruleCovers(rule.Entity, segment) {
+			continue
+		}
+		allowed := false
+		for _, role := range roles {
+			if containsString(rule.Roles, role) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return rule, true
+		}
+	}
+	return accessRule{}, false
+}
+
+// ruleCovers reports whether a rule's entity, given by title or route
+// name, is the one a request path's first segment routes to.
+func (s *Server) ruleCovers(entity, segment string) bool {
+	if entity == "*" || strings.EqualFold(entity, segment) {
+		return segment != ""
+	}
+	s.stateMu.RLock()
+	defer s.stateMu.RUnlock()
+	schema, ok := s.lookupSchema(entity)
+	return ok && s.matchesEntity(segment, schema)
+}
+
+// adminRules serves /__admin/rules: GET lists the access rules, POST adds
+// one from a {"method", "entity", "roles"} body, and DELETE removes every
+// rule, or the one named by /__admin/rules/{id}.
+func (s *Server) adminRules(w http.ResponseWriter, r *http.Request, id string) {
+	switch {
+	case r.Method == http.MethodGet && id == "":
+		writeAdminJSON(w, s.accessRules.list())
+	case r.Method == http.MethodPost && id == "":
+		var body accessRule
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid JSON body: "+err.Error())
+			return
+		}
+		rule, err := checkAccessRule(body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(s.accessRules.add(rule))
+	case r.Method == http.MethodDelete:
+		if id == "" {
+			s.accessRules.reset()
+		} else if n, err := strconv.Atoi(id); err != nil || !s.accessRules.remove(n) {
+			writeError(w, http.StatusNotFound, "No such access rule")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case id == "":
+		writeMethodNotAllowed(w, "Only GET, POST and DELETE allowed", http.MethodGet, http.MethodPost, http.MethodDelete)
+	default:
+		writeMethodNotAllowed(w, "Only DELETE allowed", http.MethodDelete)
+	}
+}
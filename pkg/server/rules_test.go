@@ -0,0 +1,128 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestAccessRules(t *testing.T) {
+	srv := NewServer(
+		WithAuth("root:write:admin", "editor:write:editor|author", "plain"),
+		WithAccessRules("DELETE /users=admin", "POST /User=admin|editor"),
+	)
+	handler := srv.Handler()
+	srv.currentSchema = createSampleSchema()
+	srv.store = newRecordStore()
+	srv.registerSchema(srv.currentSchema)
+	srv.stores["user"] = srv.store
+
+	serve := func(method, path, key, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		if key != "" {
+			req.Header.Set("Authorization", "Bearer "+key)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+	user := `{"name": "Ada", "email": "ada@example.com"}`
+
+	tests := []struct {
+		name   string
+		method string
+		key    string
+		want   int
+	}{
+		{"Create By Title Rule", http.MethodPost, "plain", http.StatusForbidden},
+		{"Create Alternative Role", http.MethodPost, "editor", http.StatusCreated},
+		{"Create With Role", http.MethodPost, "root", http.StatusCreated},
+		{"Delete Without Role", http.MethodDelete, "editor", http.StatusForbidden},
+		{"Delete With Role", http.MethodDelete, "root", http.StatusNoContent},
+		{"Read Uncovered", http.MethodGet, "plain", http.StatusOK},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			path := "/users"
+			if tc.method == http.MethodDelete {
+				path = "/users/1"
+			}
+			if rr := serve(tc.method, path, tc.key, user); rr.Code != tc.want {
+				t.Errorf("handler returned wrong status code: got %v want %v: %s", rr.Code, tc.want, rr.Body.String())
+			}
+		})
+	}
+	if rr := serve(http.MethodDelete, "/users/1", "plain", ""); !strings.Contains(rr.Body.String(), "DELETE /users requires role admin") {
+		t.Errorf("403 does not name the rule: %s", rr.Body.String())
+	}
+
+	graphql := func(key, query string) string {
+		t.Helper()
+		body, _ := json.Marshal(gqlRequest{Query: query})
+		return serve(http.MethodPost, "/graphql", key, string(body)).Body.String()
+	}
+	if got := graphql("editor", `mutation { deleteUser(id: 3) }`); !strings.Contains(got, "DELETE /users requires role admin") {
+		t.Errorf("GraphQL delete without role was not denied: %s", got)
+	}
+	if got := graphql("root", `mutation { deleteUser(id: 3) }`); strings.Contains(got, "errors") {
+		t.Errorf("GraphQL delete with role was denied: %s", got)
+	}
+	if got := graphql("plain", `{ users { id } }`); strings.Contains(got, "errors") {
+		t.Errorf("uncovered GraphQL query was denied: %s", got)
+	}
+
+	form := url.Values{"grant_type": {"client_credentials"}, "client_id": {"ops"}, "client_secret": {"root"}}
+	req := httptest.NewRequest(http.MethodPost, "/oauth/token", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	var issued struct {
+		AccessToken string `json:"access_token"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &issued)
+	claims, err := srv.verifyJWT(httptest.NewRequest(http.MethodGet, "/users", nil), issued.AccessToken)
+	if err != nil || !containsString(tokenRoles(claims), "admin") {
+		t.Fatalf("token does not carry the key's roles: %v %v", claims, err)
+	}
+	if rr := serve(http.MethodDelete, "/users/2", issued.AccessToken, ""); rr.Code != http.StatusNoContent {
+		t.Errorf("token role: handler returned wrong status code: got %v want %v", rr.Code, http.StatusNoContent)
+	}
+
+	rr = serve(http.MethodPost, "/__admin/rules", "", `{"method": "GET", "entity": "users", "roles": ["auditor"]}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("handler returned wrong status code: got %v want %v: %s", rr.Code, http.StatusCreated, rr.Body.String())
+	}
+	var added accessRule
+	json.Unmarshal(rr.Body.Bytes(), &added)
+	if rr := serve(http.MethodGet, "/users", "plain", ""); rr.Code != http.StatusForbidden {
+		t.Errorf("added rule: handler returned wrong status code: got %v want %v", rr.Code, http.StatusForbidden)
+	}
+	if rr := serve(http.MethodHead, "/users", "plain", ""); rr.Code != http.StatusForbidden {
+		t.Errorf("HEAD under a GET rule: handler returned wrong status code: got %v want %v", rr.Code, http.StatusForbidden)
+	}
+	if rr := serve(http.MethodPost, "/__admin/rules", "", `{"method": "GET", "entity": "users"}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("rule without roles: handler returned wrong status code: got %v want %v", rr.Code, http.StatusBadRequest)
+	}
+
+	rr = serve(http.MethodGet, "/__admin/rules", "", "")
+	var rules []accessRule
+	if err := json.Unmarshal(rr.Body.Bytes(), &rules); err != nil || len(rules) != 3 {
+		t.Errorf("wrong rules listed: %s", rr.Body.String())
+	}
+	if rr := serve(http.MethodDelete, "/__admin/rules/"+strconv.Itoa(added.ID), "", ""); rr.Code != http.StatusNoContent {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusNoContent)
+	}
+	if rr := serve(http.MethodGet, "/users", "plain", ""); rr.Code != http.StatusOK {
+		t.Errorf("removed rule: handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+
+	if _, err := New(WithAccessRules("DELETE /users")); err == nil {
+		t.Error("rule without roles was accepted")
+	}
+}
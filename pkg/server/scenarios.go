@@ -0,0 +1,102 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+)
+
+// scenarioStarted is the state every scenario starts in.
+const scenarioStarted = "Started"
+
+// scenarioState is a scenario and its current state, as listed at
+// GET /__admin/scenarios.
+type scenarioState struct {
+	Name  string `json:"name"`
+	State string `json:"state"`
+}
+
+// state returns the current state of a scenario. The caller must hold
+// s.mu.
+func (s *overrideSet) state(scenario string) string {
+	if state, ok := s.states[scenario]; ok {
+		return state
+	}
+	return scenarioStarted
+}
+
+// transition moves the scenario of a matched override to its new state.
+// The caller must hold s.mu.
+func (s *overrideSet) transition(o *responseOverride) {
+	if o.NewState != "" {
+		s.states[o.Scenario] = o.NewState
+	}
+}
+
+// scenarios returns every scenario the overrides name or that was set
+// through the admin API, with its state, ordered by name.
+func (s *overrideSet) scenarios() []scenarioState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	names := make(map[string]bool)
+	for _, o := range s.overrides {
+		if o.Scenario != "" {
+			names[o.Scenario] = true
+		}
+	}
+	for name := range s.states {
+		names[name] = true
+	}
+	list := make([]scenarioState, 0, len(names))
+	for name := range names {
+		list = append(list, scenarioState{Name: name, State: s.state(name)})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// setState moves a scenario to state.
+func (s *overrideSet) setState(scenario, state string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.states[scenario] = state
+}
+
+// resetStates moves every scenario back to scenarioStarted.
+func (s *overrideSet) resetStates() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.states = make(map[string]string)
+}
+
+// adminScenarios serves /__admin/scenarios: GET lists each scenario with
+// its state, DELETE moves all of them back to Started, and
+// PUT /__admin/scenarios/{name} with a {"state"} body moves one, so a test
+// can start from any point of a scripted flow.
+func (s *Server) adminScenarios(w http.ResponseWriter, r *http.Request, name string) {
+	switch {
+	case r.Method == http.MethodGet && name == "":
+		writeAdminJSON(w, s.responseOverrides.scenarios())
+	case r.Method == http.MethodDelete && name == "":
+		s.responseOverrides.resetStates()
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodPut && name != "":
+		var body struct {
+			State string `json:"state"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid JSON body: "+err.Error())
+			return
+		}
+		if body.State == "" {
+			writeError(w, http.StatusBadRequest, "state must not be empty")
+			return
+		}
+		s.responseOverrides.setState(name, body.State)
+		writeAdminJSON(w, scenarioState{Name: name, State: body.State})
+	case name == "":
+		writeMethodNotAllowed(w, "Only GET and DELETE allowed", http.MethodGet, http.MethodDelete)
+	default:
+		writeMethodNotAllowed(w, "Only PUT allowed", http.MethodPut)
+	}
+}
@@ -0,0 +1,76 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestScenarios(t *testing.T) {
+	srv := NewServer()
+	handler := srv.Handler()
+	srv.currentSchema = createSampleSchema()
+	srv.store = newRecordStore()
+	srv.registerSchema(srv.currentSchema)
+	srv.stores["user"] = srv.store
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rr
+	}
+
+	for _, o := range []string{
+		`{"method": "POST", "path": "/users", "scenario": "payment", "newState": "created"}`,
+		`{"method": "GET", "path": "/users/{id}", "scenario": "payment", "requiredState": "created", "newState": "settled", "body": {"status": "pending"}}`,
+		`{"method": "GET", "path": "/users/{id}", "scenario": "payment", "requiredState": "settled", "body": {"status": "settled"}}`,
+	} {
+		if rr := serve(http.MethodPost, "/__admin/overrides", o); rr.Code != http.StatusCreated {
+			t.Fatalf("handler returned wrong status code: got %v want %v: %s", rr.Code, http.StatusCreated, rr.Body.String())
+		}
+	}
+	status := func() interface{} {
+		t.Helper()
+		var obj map[string]interface{}
+		if err := json.Unmarshal(serve(http.MethodGet, "/users/1", "").Body.Bytes(), &obj); err != nil {
+			t.Fatal(err)
+		}
+		return obj["status"]
+	}
+
+	if got := status(); got != nil {
+		t.Errorf("scenario matched before it started: got status %v", got)
+	}
+	if rr := serve(http.MethodPost, "/users", `{"name": "Ada", "email": "ada@example.com"}`); rr.Code != http.StatusCreated {
+		t.Fatalf("POST was not served as usual: got %v %s", rr.Code, rr.Body.String())
+	}
+	for _, want := range []string{"pending", "settled", "settled"} {
+		if got := status(); got != want {
+			t.Errorf("got status %v, want %v", got, want)
+		}
+	}
+
+	var states []scenarioState
+	json.Unmarshal(serve(http.MethodGet, "/__admin/scenarios", "").Body.Bytes(), &states)
+	if len(states) != 1 || states[0] != (scenarioState{Name: "payment", State: "settled"}) {
+		t.Errorf("wrong scenarios listed: %v", states)
+	}
+	if rr := serve(http.MethodPut, "/__admin/scenarios/payment", `{"state": "created"}`); rr.Code != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	if got := status(); got != "pending" {
+		t.Errorf("state set through the admin API was ignored: got status %v", got)
+	}
+	if rr := serve(http.MethodDelete, "/__admin/scenarios", ""); rr.Code != http.StatusNoContent {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusNoContent)
+	}
+	if got := status(); got != nil {
+		t.Errorf("scenario was not reset: got status %v", got)
+	}
+
+	if rr := serve(http.MethodPost, "/__admin/overrides", `{"path": "/users", "newState": "x"}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("state without scenario: handler returned wrong status code: got %v want %v", rr.Code, http.StatusBadRequest)
+	}
+}
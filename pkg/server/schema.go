@@ -0,0 +1,447 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// supportedMethods lists the HTTP methods the generated entity routes serve.
+var supportedMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE"}
+
+// resetState forgets every uploaded schema and record.
+func (s *Server) resetState() {
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+	s.currentSchema = nil
+	s.store = newRecordStore()
+	s.schemas = make(map[string]*Schema)
+	s.stores = make(map[string]*recordStore)
+	s.importedRoutes = nil
+}
+
+// activeState returns the most recently uploaded schema and its store as a
+// consistent pair.
+func (s *Server) activeState() (*Schema, *recordStore) {
+	s.stateMu.RLock()
+	defer s.stateMu.RUnlock()
+	return s.currentSchema, s.store
+}
+
+// entityState returns the schema whose routes the path segment names,
+// together with its store. When no schema matches it returns the current
+// pair and false.
+func (s *Server) entityState(segment string) (*Schema, *recordStore, bool) {
+	s.stateMu.RLock()
+	defer s.stateMu.RUnlock()
+	if s.currentSchema != nil && s.matchesEntity(segment, s.currentSchema) {
+		return s.currentSchema, s.store, true
+	}
+	for _, key := range s.sortedSchemaKeys() {
+		if schema := s.schemas[key]; s.matchesEntity(segment, schema) {
+			return schema, s.stores[key], true
+		}
+	}
+	return s.currentSchema, s.store, false
+}
+
+// sortedSchemaKeys returns the keys of schemas in order. The caller must
+// hold stateMu.
+func (s *Server) sortedSchemaKeys() []string {
+	keys := make([]string, 0, len(s.schemas))
+	for key := range s.schemas {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// entitySummary describes one registered entity in GET /entities.
+type entitySummary struct {
+	Title   string   `json:"title"`
+	Path    string   `json:"path"`
+	Methods []string `json:"methods"`
+	Records int      `json:"records"`
+}
+
+// registeredEntities summarizes every uploaded schema, ordered by title.
+func (s *Server) registeredEntities() []entitySummary {
+	s.stateMu.RLock()
+	defer s.stateMu.RUnlock()
+	list := []entitySummary{}
+	for _, key := range s.sortedSchemaKeys() {
+		schema := s.schemas[key]
+		methods := schema.Methods
+		if len(methods) == 0 {
+			methods = supportedMethods
+		}
+		records := 0
+		if store, ok := s.stores[key]; ok {
+			records = store.len()
+		}
+		list = append(list, entitySummary{
+			Title:   schema.Title,
+			Path:    s.route("/" + entityName(schema)),
+			Methods: methods,
+			Records: records,
+		})
+	}
+	return list
+}
+
+// activateSchema resolves, validates and registers an uploaded schema with
+// an empty store, and makes it the current one. Other registered schemas
+// keep their routes and records; uploading the same title again replaces
+// it.
+//
+// Object definitions are registered as entities of their own, without
+// replacing a schema that was uploaded under the same title.
+func (s *Server) activateSchema(schema *Schema) error {
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+	if err := resolveRefs(schema); err != nil {
+		return err
+	}
+	if err := s.resolveExtends(schema); err != nil {
+		return err
+	}
+	addTimestamps(schema)
+	if err := s.validateSchema(schema); err != nil {
+		return err
+	}
+	defined := definitionSchemas(schema)
+	for _, def := range defined {
+		if err := s.validateSchema(def); err != nil {
+			return fmt.Errorf("definition %s: %v", def.Title, err)
+		}
+	}
+	for _, def := range defined {
+		key := strings.ToLower(def.Title)
+		if existing, ok := s.schemas[key]; ok && !existing.definition {
+			continue
+		}
+		s.registerSchema(def)
+		s.stores[key] = s.newStoreForSchema(def)
+	}
+	s.registerSchema(schema)
+	s.currentSchema = schema
+	s.store = s.newStoreForSchema(schema)
+	s.stores[strings.ToLower(schema.Title)] = s.store
+	return nil
+}
+
+// registerSchema records an uploaded schema so later uploads can extend it.
+// The caller must hold stateMu.
+func (s *Server) registerSchema(schema *Schema) {
+	s.schemas[strings.ToLower(schema.Title)] = schema
+}
+
+// resolveExtends flattens a schema that extends a registered base: the
+// base's properties are inherited unless redefined, and its required
+// fields are merged in. The caller must hold stateMu.
+func (s *Server) resolveExtends(schema *Schema) error {
+	if schema.Extends == "" {
+		return nil
+	}
+	base, ok := s.schemas[strings.ToLower(schema.Extends)]
+	if !ok {
+		return fmt.Errorf("extends unknown schema %q", schema.Extends)
+	}
+
+	properties := make(map[string]Property, len(base.Properties)+len(schema.Properties))
+	for key, prop := range base.Properties {
+		properties[key] = prop
+	}
+	for key, prop := range schema.Properties {
+		properties[key] = prop
+	}
+	schema.Properties = properties
+
+	required := append([]string{}, base.Required...)
+	for _, name := range schema.Required {
+		if !containsString(required, name) {
+			required = append(required, name)
+		}
+	}
+	schema.Required = required
+	if schema.Type == "" {
+		schema.Type = base.Type
+	}
+	return nil
+}
+
+// addTimestamps declares the createdAt and updatedAt properties of a schema
+// setting x-timestamps, as RFC 3339 strings, unless it declares them itself.
+func addTimestamps(schema *Schema) {
+	if !schema.Timestamps {
+		return
+	}
+	if schema.Properties == nil {
+		schema.Properties = make(map[string]Property)
+	}
+	for _, name := range []string{"createdAt", "updatedAt"} {
+		if _, ok := schema.Properties[name]; !ok {
+			schema.Properties[name] = Property{Type: "string", Format: "date-time"}
+		}
+	}
+}
+
+// validateSchema checks an uploaded schema for mistakes that would make the
+// generated API unusable, normalizing fields where needed.
+func (s *Server) validateSchema(schema *Schema) error {
+	for i, method := range schema.Methods {
+		method = strings.ToUpper(strings.TrimSpace(method))
+		if !containsString(supportedMethods, method) {
+			return fmt.Errorf("unsupported method %q in methods, expected one of %s", schema.Methods[i], strings.Join(supportedMethods, ", "))
+		}
+		schema.Methods[i] = method
+	}
+
+	if schema.IDStep != nil && *schema.IDStep < 1 {
+		return fmt.Errorf("x-id-step must be at least 1, got %d", *schema.IDStep)
+	}
+
+	if schema.ListSize != nil && (*schema.ListSize < 0 || *schema.ListSize > maxListSize) {
+		return fmt.Errorf("x-list-size must be between 0 and %d, got %d", maxListSize, *schema.ListSize)
+	}
+
+	if err := validateIDStrategy(schema); err != nil {
+		return err
+	}
+
+	if schema.ResourceName != "" && !validResourceName(schema.ResourceName) {
+		return fmt.Errorf("x-resource-name must be a single path segment of letters, digits, '-', '_', '.' or '~', got %q", schema.ResourceName)
+	}
+
+	if name := entityName(schema); containsString(reservedEntityNames, name) {
+		return fmt.Errorf("entity name %q is taken by the server's own /%s route; set x-resource-name to another name", name, name)
+	}
+
+	if schema.OptionalFields != "" && !containsString(optionalFieldModes, schema.OptionalFields) {
+		return fmt.Errorf("x-optional-fields must be one of %s, got %q", strings.Join(optionalFieldModes, ", "), schema.OptionalFields)
+	}
+	if err := s.validateProperties("", schema.Properties); err != nil {
+		return err
+	}
+	if err := s.validateKeywords("", schema.Keywords); err != nil {
+		return err
+	}
+
+	return checkRequired("required", schema.Required, schema.Properties)
+}
+
+// reservedEntityNames are the route segments the server's own handlers
+// take, under which an entity's routes could never be reached.
+var reservedEntityNames = []string{
+	"upload", "schemas", "jobs", "healthz", "oauth", ".well-known",
+	"openapi.json", "openapi.yaml", "postman.json", "docs", "graphql", "schema.graphql",
+	strings.TrimPrefix(adminPrefix, "/"), "entities",
+}
+
+// validResourceName reports whether name can be used unescaped as a route
+// segment.
+func validResourceName(name string) bool {
+	if name == "." || name == ".." {
+		return false
+	}
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_.~", r)) {
+			return false
+		}
+	}
+	return true
+}
+
+// checkRequired reports required entries that name no declared property.
+// field is how the offending list is named in the error.
+func checkRequired(field string, required []string, properties map[string]Property) error {
+	var undeclared []string
+	for _, name := range required {
+		if _, ok := properties[name]; !ok {
+			undeclared = append(undeclared, name)
+		}
+	}
+	if len(undeclared) > 0 {
+		return fmt.Errorf("%s lists undeclared properties: %s", field, strings.Join(undeclared, ", "))
+	}
+	return nil
+}
+
+// validateProperties checks property-level extensions, recursing into
+// nested objects and array items.
+func (s *Server) validateProperties(prefix string, properties map[string]Property) error {
+	for name, prop := range properties {
+		if err := s.validateProperty(prefix+name, prop); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateProperty checks a single property definition.
+func (s *Server) validateProperty(path string, prop Property) error {
+	if prop.GenMode != "" && !containsString(genModes, prop.GenMode) {
+		return fmt.Errorf("property %s: x-gen-mode must be one of %s, got %q", path, strings.Join(genModes, ", "), prop.GenMode)
+	}
+	if (prop.MinItems != nil && *prop.MinItems < 0) || (prop.MaxItems != nil && *prop.MaxItems < 0) {
+		return fmt.Errorf("property %s: minItems and maxItems must not be negative", path)
+	}
+	if prop.XRef != "" && strings.ContainsAny(path, ".[") {
+		return fmt.Errorf("property %s: x-ref is only supported on top-level properties", path)
+	}
+	if prop.XRef != "" && prop.Type != "integer" && prop.Type != "string" {
+		return fmt.Errorf("property %s: x-ref needs an integer or string property, got %q", path, prop.Type)
+	}
+	if prop.ReadOnly && prop.WriteOnly {
+		return fmt.Errorf("property %s: readOnly and writeOnly exclude each other", path)
+	}
+	if prop.MinItems != nil && prop.MaxItems != nil && *prop.MinItems > *prop.MaxItems {
+		return fmt.Errorf("property %s: minItems %d exceeds maxItems %d", path, *prop.MinItems, *prop.MaxItems)
+	}
+	if err := validateConstraints(path, prop); err != nil {
+		return err
+	}
+	// Generation picks enum, const, default and example values as-is, so
+	// each must itself be valid.
+	check := prop
+	check.Enum, check.Const = nil, nil
+	for _, value := range prop.Enum {
+		if errs := validateValue(path, check, value, ""); len(errs) > 0 {
+			return fmt.Errorf("property %s: enum value %v is invalid: %s", path, value, errs[0].Message)
+		}
+	}
+	if prop.Const != nil {
+		if errs := validateValue(path, check, prop.Const, ""); len(errs) > 0 {
+			return fmt.Errorf("property %s: const value %v is invalid: %s", path, prop.Const, errs[0].Message)
+		}
+	}
+	check.Const, check.Enum = prop.Const, prop.Enum
+	if prop.Default != nil {
+		if errs := validateValue(path, check, prop.Default, ""); len(errs) > 0 {
+			return fmt.Errorf("property %s: default value %v is invalid: %s", path, prop.Default, errs[0].Message)
+		}
+	}
+	for _, example := range prop.Examples {
+		if errs := validateValue(path, check, example, ""); len(errs) > 0 {
+			return fmt.Errorf("property %s: example value %v is invalid: %s", path, example, errs[0].Message)
+		}
+	}
+	// Generated values must pass the same checks as request bodies, which
+	// catches constraints that no value can satisfy. Other modes fall back
+	// to the constant value when their own choice does not fit.
+	if (hasNumberConstraints(prop) || hasStringConstraints(prop)) && prop.Const == nil && len(prop.Enum) == 0 {
+		sample := prop
+		sample.GenMode = genConstant
+		if errs := validateValue(path, prop, asJSON(s.newGenerator(nil).value(path, sample)), ""); len(errs) > 0 {
+			return fmt.Errorf("property %s: no value satisfies its constraints: %s", path, errs[0].Message)
+		}
+	}
+	if err := s.validateProperties(path+".", prop.Properties); err != nil {
+		return err
+	}
+	// Required fields may come from a combinator or match a pattern.
+	if len(prop.PatternProperties) == 0 {
+		if err := checkRequired("property "+path+": required", prop.Required, generationProperty(prop).Properties); err != nil {
+			return err
+		}
+	}
+	if err := s.validateKeywords(path, prop.Keywords); err != nil {
+		return err
+	}
+	if prop.Contains != nil {
+		if err := s.validateSubschema(path+".contains", *prop.Contains); err != nil {
+			return err
+		}
+	}
+	for i, item := range prop.PrefixItems {
+		if err := s.validateProperty(path+"["+strconv.Itoa(i)+"]", item); err != nil {
+			return err
+		}
+	}
+	if prop.Items != nil {
+		return s.validateProperty(path+"[]", *prop.Items)
+	}
+	return nil
+}
+
+// validateConstraints checks the numeric and string constraints of a
+// property for values no schema could mean.
+func validateConstraints(path string, prop Property) error {
+	if prop.MultipleOf != nil {
+		if *prop.MultipleOf <= 0 {
+			return fmt.Errorf("property %s: multipleOf must be greater than 0", path)
+		}
+		if prop.Type == "integer" && *prop.MultipleOf != math.Trunc(*prop.MultipleOf) {
+			return fmt.Errorf("property %s: multipleOf must be a whole number for integer properties", path)
+		}
+	}
+	if lo, ok := lowerBound(prop); ok {
+		if hi, ok := upperBound(prop); ok && lo > hi {
+			return fmt.Errorf("property %s: the minimum exceeds the maximum", path)
+		}
+	}
+	if (prop.MinLength != nil && *prop.MinLength < 0) || (prop.MaxLength != nil && *prop.MaxLength < 0) {
+		return fmt.Errorf("property %s: minLength and maxLength must not be negative", path)
+	}
+	if prop.MinLength != nil && prop.MaxLength != nil && *prop.MinLength > *prop.MaxLength {
+		return fmt.Errorf("property %s: minLength %d exceeds maxLength %d", path, *prop.MinLength, *prop.MaxLength)
+	}
+	if prop.Pattern != "" {
+		if _, err := compilePattern(prop.Pattern); err != nil {
+			return fmt.Errorf("property %s: invalid pattern: %v", path, err)
+		}
+	}
+	return nil
+}
+
+// bodyRequired returns the required fields a POST body must carry. The id
+// property is left out because the store assigns it. PUT and PATCH merge onto the
+// existing record, so their bodies may leave fields out.
+func (s *Schema) bodyRequired() []string {
+	var required []string
+	for _, name := range s.Required {
+		if name != s.idKey() {
+			required = append(required, name)
+		}
+	}
+	return required
+}
+
+// allowsMethod reports whether the schema's routes serve the given method.
+func (s *Schema) allowsMethod(method string) bool {
+	return len(s.Methods) == 0 || containsString(s.Methods, method)
+}
+
+// routeMethods lists the methods served on a generated route given by its
+// path segments: a collection is listed and created in, a record read and
+// changed. Methods the schema does not allow are left out; HEAD is served
+// wherever GET is.
+func routeMethods(schema *Schema, segments []string) []string {
+	methods := []string{http.MethodGet, http.MethodPut, http.MethodPatch, http.MethodDelete}
+	if len(segments) == 1 {
+		methods = []string{http.MethodGet, http.MethodPost}
+	}
+	var allowed []string
+	for _, method := range methods {
+		if schema.allowsMethod(method) {
+			allowed = append(allowed, method)
+			if method == http.MethodGet {
+				allowed = append(allowed, http.MethodHead)
+			}
+		}
+	}
+	return allowed
+}
+
+// containsString reports whether list contains value.
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
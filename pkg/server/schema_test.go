@@ -0,0 +1,207 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestValidateSchemaMethods(t *testing.T) {
+	srv := NewServer()
+	t.Run("Normalizes Case", func(t *testing.T) {
+		schema := &Schema{Title: "User", Methods: []string{"get", " Post "}}
+		if err := srv.validateSchema(schema); err != nil {
+			t.Fatalf("validateSchema returned error: %v", err)
+		}
+		if schema.Methods[0] != "GET" || schema.Methods[1] != "POST" {
+			t.Errorf("methods were not normalized: got %v", schema.Methods)
+		}
+	})
+
+	t.Run("Rejects Unknown Method", func(t *testing.T) {
+		schema := &Schema{Title: "User", Methods: []string{"GET", "FETCH"}}
+		if err := srv.validateSchema(schema); err == nil {
+			t.Errorf("validateSchema accepted unknown method")
+		}
+	})
+}
+
+func TestResolveExtends(t *testing.T) {
+	srv := NewServer()
+	srv.schemas = make(map[string]*Schema)
+	srv.registerSchema(createSampleSchema())
+
+	schema := &Schema{
+		Title:   "Admin",
+		Extends: "user",
+		Properties: map[string]Property{
+			"email": {Type: "string", Format: "email"},
+			"level": {Type: "integer"},
+		},
+		Required: []string{"level", "email"},
+	}
+	if err := srv.resolveExtends(schema); err != nil {
+		t.Fatalf("resolveExtends returned error: %v", err)
+	}
+	if len(schema.Properties) != 4 {
+		t.Errorf("resolveExtends produced wrong properties: got %v", schema.Properties)
+	}
+	if schema.Properties["email"].Format != "email" {
+		t.Errorf("resolveExtends did not let the child override a property")
+	}
+	if got := strings.Join(schema.Required, ","); got != "id,name,email,level" {
+		t.Errorf("resolveExtends produced wrong required list: got %v", got)
+	}
+	if schema.Type != "object" {
+		t.Errorf("resolveExtends did not inherit type: got %v", schema.Type)
+	}
+	if _, leaked := srv.schemas["user"].Properties["level"]; leaked {
+		t.Errorf("resolveExtends modified the base schema")
+	}
+
+	if err := srv.resolveExtends(&Schema{Title: "Ghost", Extends: "missing"}); err == nil {
+		t.Errorf("resolveExtends accepted an unknown base")
+	}
+}
+
+func TestValidateSchemaRequired(t *testing.T) {
+	srv := NewServer()
+	if err := srv.validateSchema(createSampleSchema()); err != nil {
+		t.Errorf("validateSchema rejected a valid schema: %v", err)
+	}
+
+	schema := createSampleSchema()
+	schema.Required = append(schema.Required, "emial", "phone")
+	err := srv.validateSchema(schema)
+	if err == nil {
+		t.Fatalf("validateSchema accepted undeclared required properties")
+	}
+	if !strings.Contains(err.Error(), "emial, phone") {
+		t.Errorf("validateSchema error does not name the offending entries: %v", err)
+	}
+}
+
+func TestValidateSchemaIDStep(t *testing.T) {
+	srv := NewServer()
+	step := 0
+	if err := srv.validateSchema(&Schema{Title: "User", IDStep: &step}); err == nil {
+		t.Errorf("validateSchema accepted x-id-step of 0")
+	}
+}
+
+func TestValidateSchemaGenMode(t *testing.T) {
+	srv := NewServer()
+	schema := &Schema{Title: "User", Properties: map[string]Property{
+		"address": {Type: "object", Properties: map[string]Property{
+			"city": {Type: "string", GenMode: "shuffled"},
+		}},
+	}}
+	err := srv.validateSchema(schema)
+	if err == nil || !strings.Contains(err.Error(), "address.city") {
+		t.Errorf("validateSchema did not reject invalid nested x-gen-mode: %v", err)
+	}
+}
+
+func TestValidateSchemaEnum(t *testing.T) {
+	srv := NewServer()
+	schema := &Schema{Title: "User", Properties: map[string]Property{
+		"contact": {Type: "string", Format: "email", Enum: []interface{}{"admin@example.com", "nobody"}},
+	}}
+	if err := srv.validateSchema(schema); err == nil || !strings.Contains(err.Error(), "nobody") {
+		t.Errorf("validateSchema accepted an enum value violating the format: %v", err)
+	}
+
+	schema.Properties["contact"] = Property{Type: "integer", Enum: []interface{}{1.0, 2.0}}
+	if err := srv.validateSchema(schema); err != nil {
+		t.Errorf("validateSchema rejected a valid integer enum: %v", err)
+	}
+}
+
+func TestValidateSchemaArrayBounds(t *testing.T) {
+	srv := NewServer()
+	three, one := 3, 1
+	schema := createSampleSchema()
+	schema.Properties["tags"] = Property{Type: "array", Items: &Property{Type: "string"}, MinItems: &three, MaxItems: &one}
+	if err := srv.validateSchema(schema); err == nil || !strings.Contains(err.Error(), "tags") {
+		t.Errorf("validateSchema accepted minItems above maxItems: %v", err)
+	}
+}
+
+func TestValidateSchemaConstraints(t *testing.T) {
+	srv := NewServer()
+	for _, definition := range []string{
+		`{"type": "integer", "minimum": 10, "maximum": 5}`,
+		`{"type": "integer", "minimum": 1, "maximum": 4, "multipleOf": 5}`,
+		`{"type": "integer", "exclusiveMinimum": 3, "exclusiveMaximum": 4}`,
+		`{"type": "integer", "multipleOf": 0.5}`,
+		`{"type": "number", "multipleOf": 0}`,
+		`{"type": "string", "minLength": 5, "maxLength": 2}`,
+		`{"type": "string", "pattern": "(?=lookahead)"}`,
+		`{"type": "string", "pattern": "^[a-z]{5}$", "maxLength": 3}`,
+		`{"type": "string", "format": "uuid", "maxLength": 10}`,
+		`{"type": "string", "const": "abc", "maxLength": 2}`,
+		`{"type": "integer", "enum": [1, 50], "maximum": 10}`,
+	} {
+		schema := createSampleSchema()
+		schema.Properties["field"] = parseProperty(t, definition)
+		if err := srv.validateSchema(schema); err == nil || !strings.Contains(err.Error(), "field") {
+			t.Errorf("validateSchema accepted %s: %v", definition, err)
+		}
+	}
+}
+
+func TestMultipleEntities(t *testing.T) {
+	srv := NewServer()
+
+	for _, body := range []string{
+		`{"title":"User","type":"object","properties":{"id":{"type":"integer"},"name":{"type":"string"}}}`,
+		`{"title":"Product","type":"object","properties":{"id":{"type":"integer"},"price":{"type":"number"}},"methods":["GET"]}`,
+	} {
+		if rr := performRequest(t, srv.uploadHandler, http.MethodPost, "/upload", []byte(body)); rr.Code != http.StatusOK {
+			t.Fatalf("upload failed: %v", rr.Body.String())
+		}
+	}
+
+	rr := performRequest(t, srv.catchAllHandler, http.MethodPost, "/users", []byte(`{"name":"kept"}`))
+	if status := rr.Code; status != http.StatusCreated {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusCreated)
+	}
+	rr = performRequest(t, srv.catchAllHandler, http.MethodGet, "/users/1", nil)
+	if !strings.Contains(rr.Body.String(), `"name":"kept"`) {
+		t.Errorf("earlier entity lost its records: got %v", rr.Body.String())
+	}
+	rr = performRequest(t, srv.catchAllHandler, http.MethodGet, "/products/1", nil)
+	if !strings.Contains(rr.Body.String(), `"price":0`) {
+		t.Errorf("handler returned unexpected product: got %v", rr.Body.String())
+	}
+	rr = performRequest(t, srv.catchAllHandler, http.MethodPost, "/products", nil)
+	if status := rr.Code; status != http.StatusMethodNotAllowed {
+		t.Errorf("handler applied the wrong entity's methods: got %v want %v", status, http.StatusMethodNotAllowed)
+	}
+	rr = performRequest(t, srv.catchAllHandler, http.MethodPost, "/orders", nil)
+	if status := rr.Code; status != http.StatusNotFound {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusNotFound)
+	}
+
+	rr = performRequest(t, srv.entitiesHandler, http.MethodGet, "/entities", nil)
+	var list []entitySummary
+	if err := json.Unmarshal(rr.Body.Bytes(), &list); err != nil {
+		t.Fatalf("could not decode response: %v", err)
+	}
+	if len(list) != 2 || list[0].Path != "/products" || list[1].Path != "/users" || list[1].Records != 1 {
+		t.Errorf("entities returned unexpected list: got %+v", list)
+	}
+}
+
+func TestValidateSchemaNestedRequired(t *testing.T) {
+	srv := NewServer()
+	schema := createSampleSchema()
+	schema.Properties["address"] = Property{Type: "object", Required: []string{"zip"}, Properties: map[string]Property{
+		"city": {Type: "string"},
+	}}
+	err := srv.validateSchema(schema)
+	if err == nil || !strings.Contains(err.Error(), "address") || !strings.Contains(err.Error(), "zip") {
+		t.Errorf("validateSchema accepted undeclared nested required property: %v", err)
+	}
+}
@@ -0,0 +1,166 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// searchParam is the query parameter holding search terms, as in
+// ?q=alice on a list or on GET /{entity}/search.
+const searchParam = "q"
+
+// searchSegment is the last path segment of the search route, as in
+// GET /users/search?q=ali.
+const searchSegment = "search"
+
+// Search scores: a term equal to a whole field value counts most, one
+// starting a word of it less, one found anywhere else least.
+const (
+	scoreExact     = 3
+	scoreWordStart = 2
+	scoreSubstring = 1
+)
+
+// searchMatch is where a search term was found in a record: the dotted
+// path of the string field, its value and the rune offset of the term.
+type searchMatch struct {
+	Term   string `json:"term"`
+	Field  string `json:"field"`
+	Value  string `json:"value"`
+	Offset int    `json:"offset"`
+}
+
+// searchHit is a record that matched every search term, with its score and
+// every match, as GET /{entity}/search lists it.
+type searchHit struct {
+	Score   int                    `json:"score"`
+	Matches []searchMatch          `json:"matches"`
+	Record  map[string]interface{} `json:"record"`
+}
+
+// searchTerms splits the values of ?q= into lower-cased terms, each listed
+// once.
+func searchTerms(values []string) []string {
+	var terms []string
+	for _, value := range values {
+		for _, term := range strings.Fields(strings.ToLower(value)) {
+			if !containsString(terms, term) {
+				terms = append(terms, term)
+			}
+		}
+	}
+	return terms
+}
+
+// stringFields calls visit with the dotted path and value of each string
+// in v, looking into nested objects and arrays; strings in an array are
+// reported at the array's path.
+func stringFields(path string, v interface{}, visit func(field, value string)) {
+	switch value := v.(type) {
+	case string:
+		visit(path, value)
+	case map[string]interface{}:
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			field := key
+			if path != "" {
+				field = path + "." + key
+			}
+			stringFields(field, value[key], visit)
+		}
+	case []interface{}:
+		for _, item := range value {
+			stringFields(path, item, visit)
+		}
+	}
+}
+
+// searchRecord matches obj against terms, case-insensitively, reporting
+// whether every term was found in one of its string fields.
+func searchRecord(obj map[string]interface{}, terms []string) (searchHit, bool) {
+	hit := searchHit{Matches: []searchMatch{}, Record: obj}
+	found := make(map[string]bool, len(terms))
+	stringFields("", obj, func(field, value string) {
+		lower := strings.ToLower(value)
+		for _, term := range terms {
+			i := strings.Index(lower, term)
+			if i < 0 {
+				continue
+			}
+			found[term] = true
+			switch {
+			case lower == term:
+				hit.Score += scoreExact
+			case wordStart(lower, i):
+				hit.Score += scoreWordStart
+			default:
+				hit.Score += scoreSubstring
+			}
+			hit.Matches = append(hit.Matches, searchMatch{Term: term, Field: field, Value: value, Offset: utf8.RuneCountInString(lower[:i])})
+		}
+	})
+	return hit, len(found) == len(terms)
+}
+
+// wordStart reports whether offset i of s begins a word.
+func wordStart(s string, i int) bool {
+	if i == 0 {
+		return true
+	}
+	prev, _ := utf8.DecodeLastRuneInString(s[:i])
+	return !unicode.IsLetter(prev) && !unicode.IsDigit(prev)
+}
+
+// searchRecords returns the hits of list for terms, best first; equal
+// scores keep list order.
+func searchRecords(list []map[string]interface{}, terms []string) []searchHit {
+	var hits []searchHit
+	for _, obj := range list {
+		if hit, ok := searchRecord(obj, terms); ok {
+			hits = append(hits, hit)
+		}
+	}
+	sort.SliceStable(hits, func(i, j int) bool { return hits[i].Score > hits[j].Score })
+	return hits
+}
+
+// serveSearch answers GET /{entity}/search?q=, listing the records that
+// match every term as ranked hits. Filters, sort and pagination apply as
+// on the list, sort overriding the ranking.
+func (s *Server) serveSearch(w http.ResponseWriter, r *http.Request, schema *Schema, records *recordStore) {
+	page, err := parsePagination(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid pagination: "+err.Error())
+		return
+	}
+	query, err := parseListQuery(r.URL.Query(), schema)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid query: "+err.Error())
+		return
+	}
+	if len(query.search) == 0 {
+		writeError(w, http.StatusBadRequest, "Invalid query: "+searchParam+" is required")
+		return
+	}
+	list, _ := s.listRecords(r, schema, records, pagination{}, query)
+	hits := make([]searchHit, 0, len(list))
+	for _, obj := range list {
+		hit, _ := searchRecord(obj, query.search)
+		hits = append(hits, hit)
+	}
+	page.setHeaders(w, r, s.route(r.URL.Path), len(hits))
+	start, end := page.bounds(len(hits))
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(hits[start:end]); err != nil {
+		log.Println("Error encoding response:", err)
+	}
+}
@@ -0,0 +1,59 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestSearch(t *testing.T) {
+	srv := NewServer()
+	srv.currentSchema = createSampleSchema()
+	srv.store = newRecordStore()
+	srv.registerSchema(srv.currentSchema)
+	srv.stores["user"] = srv.store
+	for _, body := range []string{
+		`{"id": 1, "name": "Natalie", "email": "nat@example.com"}`,
+		`{"id": 2, "name": "Ali", "email": "ali@example.com"}`,
+		`{"id": 3, "name": "Bob", "email": "bob@example.com"}`,
+		`{"id": 4, "name": "Alice Smith", "email": "smith@example.com"}`,
+	} {
+		performRequest(t, srv.catchAllHandler, http.MethodPost, "/users", []byte(body))
+	}
+
+	rr := performRequest(t, srv.catchAllHandler, http.MethodGet, "/users/search?q=ALI", nil)
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+	var hits []searchHit
+	if err := json.Unmarshal(rr.Body.Bytes(), &hits); err != nil {
+		t.Fatalf("could not decode response: %v", err)
+	}
+	// Ali matches its name exactly and starts its email, Alice starts a
+	// word and Natalie only contains the term.
+	want := []float64{2, 4, 1}
+	if len(hits) != len(want) {
+		t.Fatalf("wrong number of hits: got %d want %d", len(hits), len(want))
+	}
+	for i, hit := range hits {
+		if hit.Record["id"] != want[i] {
+			t.Errorf("hit %d: got id %v want %v", i, hit.Record["id"], want[i])
+		}
+	}
+	if m := hits[2].Matches; len(m) != 1 || m[0].Field != "name" || m[0].Offset != 3 || hits[2].Score != scoreSubstring {
+		t.Errorf("wrong match metadata: got score %d, %+v", hits[2].Score, m)
+	}
+
+	var list []map[string]interface{}
+	rr = performRequest(t, srv.catchAllHandler, http.MethodGet, "/users?q=alice+smith", nil)
+	if err := json.Unmarshal(rr.Body.Bytes(), &list); err != nil {
+		t.Fatalf("could not decode response: %v", err)
+	}
+	if len(list) != 1 || list[0]["id"] != float64(4) {
+		t.Errorf("list search returned wrong records: got %v", list)
+	}
+
+	if rr := performRequest(t, srv.catchAllHandler, http.MethodGet, "/users/search", nil); rr.Code != http.StatusBadRequest {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusBadRequest)
+	}
+}
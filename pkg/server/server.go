@@ -0,0 +1,899 @@
+// Package server implements the schema2api mock API: upload a JSON schema
+// and it serves generated CRUD routes for it.
+//
+// Embed it in a Go test suite with
+//
+//	srv := server.NewServer(server.WithStrictGet(true))
+//	ts := httptest.NewServer(srv.Handler())
+//	defer ts.Close()
+//
+// Each Server keeps its own schemas, records and settings, so tests can
+// run several in parallel.
+package server
+
+import (
+	"crypto/rsa"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Server is one mock API: its settings, the schemas uploaded to it and
+// their records. Servers share nothing, so several can serve side by side.
+type Server struct {
+	settings
+
+	latency        time.Duration
+	latencyJitter  time.Duration
+	htmlErrors     bool
+	jsonAPI        bool
+	requestTimeout time.Duration
+	recorder       *sessionRecorder
+	schemaFiles    []string
+	sqlFiles       []string
+	openAPIFiles   []string
+	postmanFiles   []string
+	fixtureFiles   []string
+	dataDir        string
+	chaos          chaosConfig
+	cors           corsConfig
+	envelope       envelopeConfig
+	compression    compressionConfig
+	rateLimit      rateLimitConfig
+	upstream       *url.URL
+
+	// stateMu guards currentSchema, store, schemas, stores and
+	// importedRoutes, which uploads replace while other requests are
+	// reading them.
+	stateMu sync.RWMutex
+	// currentSchema holds the most recently uploaded JSON schema. Every
+	// uploaded schema is served; see schemas.
+	currentSchema *Schema
+	// store holds the records for currentSchema. It is replaced whenever
+	// a new schema is uploaded; see activeState.
+	store *recordStore
+	// schemas holds every uploaded schema keyed by its lower-cased title.
+	// Each is served under its own routes, and later uploads can extend
+	// them.
+	schemas map[string]*Schema
+	// stores holds the records of every uploaded schema, keyed like
+	// schemas.
+	stores map[string]*recordStore
+	// importedRoutes holds the operations of every imported OpenAPI
+	// document.
+	importedRoutes []*importedRoute
+
+	// journal holds the requests received since the Server was created or
+	// the journal was last cleared.
+	journal *requestJournal
+	// apiKeys are the keys requests are checked against under
+	// authRequired.
+	apiKeys *keyring
+	// accessRules are checked by requireAuth once a request is
+	// authenticated.
+	accessRules *ruleSet
+	// responseOverrides are checked by withOverrides before a request
+	// reaches the entity routes.
+	responseOverrides *overrideSet
+	// jobs holds every accepted async create operation.
+	jobs *jobQueue
+	// signingKey signs the tokens the identity provider issues, and
+	// signingKeyID names it in their header and the key set. Each Server
+	// has its own, so no other Server accepts its tokens.
+	signingKey   *rsa.PrivateKey
+	signingKeyID string
+	// runSeed stands in for seed when none is set, so records generated
+	// for the same id agree for as long as the Server runs.
+	runSeed int64
+
+	// conditionalMu is held by PUT, PATCH and DELETE requests carrying
+	// If-Match from checking the tag until their write is done, so of two
+	// clients writing from the same ETag exactly one succeeds.
+	conditionalMu sync.Mutex
+	// saveMu serializes snapshot writes, so an older snapshot never
+	// replaces a newer one.
+	saveMu sync.Mutex
+}
+
+// Option configures a Server. Options that take a value the server cannot
+// use make New return an error.
+type Option func(*Server) error
+
+// settings holds the settings that options change.
+type settings struct {
+	// debugMode adds a _meta block to list responses describing how the
+	// query string was interpreted.
+	debugMode bool
+	// warnUnknownParams adds an X-Unknown-Params header to list responses
+	// naming query parameters that match no schema property.
+	warnUnknownParams bool
+	// strictAccept makes the server reject requests whose Accept header
+	// does not allow a JSON response.
+	strictAccept bool
+	// legacyErrors keeps error responses as plain application/json Error
+	// bodies instead of converting them to problem details.
+	legacyErrors bool
+	// welcomeMessage is the usage hint returned by the index at GET /.
+	welcomeMessage string
+	// noSchemaStatus is the status returned by entity routes before any
+	// schema has been uploaded: the service is not ready yet rather than
+	// the request being wrong.
+	noSchemaStatus int
+	// looseRoutes lets entity routes match the singular form of the title
+	// (/user/1) as well as the plural one (/users/1).
+	looseRoutes bool
+	// strictGet makes GET on an id that is not in the store respond 404
+	// instead of fabricating an object.
+	strictGet bool
+	// strictPut makes PUT on an id that is not in the store respond 404
+	// instead of creating the record.
+	strictPut bool
+	// softDelete makes DELETE mark stored records with a deletedAt time
+	// instead of removing them, so POST /{entity}/{id}/restore can bring
+	// them back.
+	softDelete bool
+	// rejectIDMismatch makes PUT respond 422 when the body carries an id
+	// that differs from the one in the URL, instead of ignoring the body's
+	// id.
+	rejectIDMismatch bool
+	// genMode is the generation mode for properties without x-gen-mode.
+	genMode string
+	// arrayLength is the number of elements generated for array
+	// properties, clamped to each property's minItems and maxItems.
+	arrayLength int
+	// fakerMode makes string properties whose name (or email format)
+	// suggests personal, address or web data get realistic values, see
+	// fakeString.
+	fakerMode bool
+	// asyncCreateDelay makes POST respond 202 Accepted with a status
+	// resource under /jobs/ instead of creating the record immediately.
+	// The record is created once the delay has passed. Zero disables
+	// async creation.
+	asyncCreateDelay time.Duration
+	// validationMode is the mode selected by the -validate flag.
+	validationMode string
+	// optionalFields is the optional field mode for schemas without
+	// x-optional-fields.
+	optionalFields string
+	// idStart and idStep are the defaults for the auto-increment counter
+	// of schemas that do not set x-id-start or x-id-step.
+	idStart int
+	idStep  int
+	// basePath is the prefix every route is served under, e.g. /api/v1.
+	// It is empty to serve from the root.
+	basePath string
+	// listSize is the number of generated records a list returns while an
+	// entity's store is still untouched, unless the schema's x-list-size
+	// or the request's ?_count= says otherwise.
+	listSize int
+	// authRequired makes every request but those to the admin API and
+	// /healthz carry a valid key; see requireAuth.
+	authRequired bool
+	// seed fixes the random generator, so random mode yields the same data
+	// on every run. It only applies when seeded is set; otherwise every
+	// response is generated from a fresh random state.
+	seed   int64
+	seeded bool
+}
+
+// defaultSettings are the settings every Server starts from.
+var defaultSettings = settings{
+	welcomeMessage: DefaultWelcome,
+	noSchemaStatus: http.StatusServiceUnavailable,
+	genMode:        genConstant,
+	arrayLength:    2,
+	validationMode: validateReject,
+	optionalFields: optionalFill,
+	idStart:        1,
+	idStep:         1,
+	listSize:       3,
+}
+
+// New returns a Server with no schemas uploaded, configured by opts on top
+// of the defaults.
+func New(opts ...Option) (*Server, error) {
+	s := &Server{
+		settings:          defaultSettings,
+		cors:              defaultCORS,
+		envelope:          defaultEnvelope,
+		compression:       defaultCompression,
+		rateLimit:         defaultRateLimit,
+		journal:           &requestJournal{},
+		apiKeys:           &keyring{keys: make(map[string]apiKey)},
+		accessRules:       &ruleSet{nextID: 1},
+		responseOverrides: &overrideSet{nextID: 1, states: make(map[string]string)},
+		jobs:              &jobQueue{jobs: make(map[int]*job), retention: jobRetention},
+		runSeed:           time.Now().UnixNano(),
+	}
+	if err := s.newSigningKey(); err != nil {
+		return nil, err
+	}
+	s.resetState()
+	for _, opt := range opts {
+		if err := opt(s); err != nil {
+			return nil, err
+		}
+	}
+	if s.envelope.pagination != "headers" && s.envelope.data == "" {
+		return nil, fmt.Errorf("pagination meta mode %q needs an envelope", s.envelope.pagination)
+	}
+	// Schemas are loaded once every setting is in place, since their
+	// stores are numbered from the id settings. A snapshot in the data
+	// directory takes the place of the schema and fixtures files.
+	restored := false
+	if s.dataDir != "" {
+		var err error
+		if restored, err = s.restoreSnapshot(s.dataDir); err != nil {
+			return nil, err
+		}
+	}
+	if !restored {
+		for _, path := range s.schemaFiles {
+			if err := s.loadSchemaFile(path); err != nil {
+				return nil, err
+			}
+		}
+		for _, path := range s.sqlFiles {
+			if err := s.loadDDLFile(path); err != nil {
+				return nil, err
+			}
+		}
+		// Fixtures fill the stores of the schemas loaded above.
+		for _, path := range s.fixtureFiles {
+			if err := s.loadFixturesFile(path); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, path := range s.openAPIFiles {
+		if err := s.loadOpenAPIFile(path); err != nil {
+			return nil, err
+		}
+	}
+	for _, path := range s.postmanFiles {
+		if err := s.loadPostmanFile(path); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// NewServer is like New but panics if an option is invalid, which suits
+// tests that configure the server with constants.
+func NewServer(opts ...Option) *Server {
+	s, err := New(opts...)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// Handler returns the HTTP handler serving the upload endpoint, the
+// generated entity routes and the server's other resources.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	// Endpoint to upload JSON schema.
+	mux.HandleFunc("/upload", s.uploadHandler)
+	// Endpoint to infer a schema from a sample record and upload it.
+	mux.HandleFunc("/upload/example", s.exampleHandler)
+	// Endpoint to turn a CSV document into an entity and its records.
+	mux.HandleFunc("/upload/csv", s.csvHandler)
+	// Endpoint to register an entity per table of SQL DDL.
+	mux.HandleFunc("/upload/sql", s.ddlHandler)
+	// Endpoint to import an OpenAPI document and mock its operations.
+	mux.HandleFunc("/upload/openapi", s.openAPIImportHandler)
+	// Endpoint to import a Postman collection and mock its requests.
+	mux.HandleFunc("/upload/postman", s.postmanImportHandler)
+	// Endpoint to fill the stores from a fixtures document.
+	mux.HandleFunc("/upload/fixtures", s.fixturesHandler)
+	// Compare a candidate schema against a registered one.
+	mux.HandleFunc("/schemas/", s.schemaDiffHandler)
+	// Status resources for async creates.
+	mux.HandleFunc("/jobs/", s.jobsHandler)
+	// Liveness probe.
+	mux.HandleFunc("/healthz", s.healthHandler)
+	// Mock identity provider issuing the tokens -auth accepts.
+	mux.HandleFunc(tokenPath, s.tokenHandler)
+	mux.HandleFunc(jwksPath, s.jwksHandler)
+	mux.HandleFunc(discoveryPath, s.discoveryHandler)
+
+	// OpenAPI description of the generated routes, as JSON and YAML.
+	mux.HandleFunc("/openapi.json", s.openAPIHandler)
+	mux.HandleFunc("/openapi.yaml", s.openAPIHandler)
+	// Postman collection of the generated routes.
+	mux.HandleFunc("/postman.json", s.postmanHandler)
+
+	// Swagger UI for the OpenAPI document.
+	mux.HandleFunc("/docs", s.docsHandler)
+
+	// GraphQL API over the same records, and its schema.
+	mux.HandleFunc("/graphql", s.graphQLHandler)
+	mux.HandleFunc("/schema.graphql", s.graphQLSchemaHandler)
+
+	// Admin API for inspecting and resetting the server at runtime.
+	mux.HandleFunc(adminPrefix, s.adminHandler)
+	mux.HandleFunc(adminPrefix+"/", s.adminHandler)
+
+	// Endpoint listing every registered entity.
+	mux.HandleFunc("/entities", s.entitiesHandler)
+
+	// Catch-all route handler.
+	mux.HandleFunc("/", s.catchAllHandler)
+
+	// JSON:API documents wrap the entity routes' own responses.
+	var handler http.Handler = s.withJSONAPI(s.jsonAPI, mux)
+	if s.envelope.data != "" {
+		handler = s.withEnvelope(s.envelope, handler)
+	}
+	// Outside the envelope, so scripted bodies are sent as written.
+	handler = s.withOverrides(handler)
+	if s.basePath != "" {
+		handler = withBasePath(s.basePath, handler)
+	}
+	if s.authRequired {
+		handler = s.requireAuth(handler)
+	}
+	handler = s.withJournal(handler)
+	if s.strictAccept {
+		handler = s.requireJSONAccept(handler)
+	}
+	if s.htmlErrors {
+		handler = htmlErrorPages(handler)
+	}
+	if s.latency > 0 || s.latencyJitter > 0 {
+		handler = withLatency(s.latency, s.latencyJitter, handler)
+	}
+	if s.dataDir != "" {
+		handler = s.persistChanges(s.dataDir, handler)
+	}
+	if s.requestTimeout > 0 {
+		handler = withTimeout(s.requestTimeout, handler)
+	}
+	if s.rateLimit.limit > 0 {
+		handler = s.withRateLimit(s.rateLimit, handler)
+	}
+	// Outside the timeout, so a deliberately slow response is not
+	// answered as a timed-out one.
+	handler = s.withChaos(s.chaos, handler)
+	// Outside the faults and error pages, so browsers can read errors too.
+	if len(s.cors.origins) > 0 {
+		handler = withCORS(s.cors, handler)
+	}
+	// Outermost but for the recorder, so every error becomes a problem.
+	if !s.legacyErrors {
+		handler = withProblems(handler)
+	}
+	// Outside the problems, which it renders too.
+	handler = s.withRenderings(s.envelope.data, handler)
+	// Outside every rewrite, so proxied responses come back as sent.
+	if s.upstream != nil {
+		handler = s.withPassthrough(s.upstream, mux, handler)
+	}
+	if s.recorder != nil {
+		handler = s.recorder.middleware(handler)
+	}
+	// Outermost, so recordings keep readable bodies.
+	if s.compression.enabled {
+		handler = withCompression(s.compression, handler)
+	}
+	return handler
+}
+
+// Close flushes the session recording and saves a final snapshot to the
+// data directory, if either is configured. The server must not be serving
+// requests any more.
+func (s *Server) Close() {
+	if s.recorder != nil {
+		s.recorder.Close()
+		s.recorder = nil
+	}
+	if s.dataDir != "" {
+		if err := s.saveSnapshot(s.dataDir); err != nil {
+			log.Println("Error saving snapshot:", err)
+		}
+	}
+}
+
+// WithDebug wraps list responses as {"data": [...], "_meta": {...}}.
+func WithDebug(on bool) Option {
+	return func(s *Server) error { s.debugMode = on; return nil }
+}
+
+// WithWarnUnknownParams names query parameters matching no property in an
+// X-Unknown-Params header on list responses.
+func WithWarnUnknownParams(on bool) Option {
+	return func(s *Server) error { s.warnUnknownParams = on; return nil }
+}
+
+// WithStrictAccept responds 406 to requests whose Accept header does not
+// allow JSON.
+func WithStrictAccept(on bool) Option {
+	return func(s *Server) error { s.strictAccept = on; return nil }
+}
+
+// WithWelcome sets the usage hint returned by the index at GET /.
+func WithWelcome(message string) Option {
+	return func(s *Server) error { s.welcomeMessage = message; return nil }
+}
+
+// WithNoSchemaStatus sets the status entity routes return before a schema
+// is uploaded.
+func WithNoSchemaStatus(status int) Option {
+	return func(s *Server) error {
+		if status < 100 || status > 999 {
+			return fmt.Errorf("no-schema status %d is not an HTTP status code", status)
+		}
+		s.noSchemaStatus = status
+		return nil
+	}
+}
+
+// WithLooseRoutes also matches entity routes by the singular form of the
+// title, e.g. /user/1.
+func WithLooseRoutes(on bool) Option {
+	return func(s *Server) error { s.looseRoutes = on; return nil }
+}
+
+// WithStrictGet responds 404 to GET on ids that were never created instead
+// of fabricating them.
+func WithStrictGet(on bool) Option {
+	return func(s *Server) error { s.strictGet = on; return nil }
+}
+
+// WithStrictPut responds 404 to PUT on ids that are not stored instead of
+// creating them.
+func WithStrictPut(on bool) Option {
+	return func(s *Server) error { s.strictPut = on; return nil }
+}
+
+// WithSoftDelete makes DELETE set a deletedAt time on stored records
+// instead of removing them. Lists leave them out unless asked for
+// ?includeDeleted=true, and POST /{entity}/{id}/restore undeletes them.
+func WithSoftDelete(on bool) Option {
+	return func(s *Server) error { s.softDelete = on; return nil }
+}
+
+// WithRejectIDMismatch responds 422 when a PUT or PATCH body id differs
+// from the URL id instead of ignoring it.
+func WithRejectIDMismatch(on bool) Option {
+	return func(s *Server) error { s.rejectIDMismatch = on; return nil }
+}
+
+// WithGenMode sets how generated values vary across objects: constant,
+// sequential or random.
+func WithGenMode(mode string) Option {
+	return func(s *Server) error {
+		if !containsString(genModes, mode) {
+			return fmt.Errorf("gen mode must be one of %s, got %q", strings.Join(genModes, ", "), mode)
+		}
+		s.genMode = mode
+		return nil
+	}
+}
+
+// WithArrayLength sets the number of elements generated for array
+// properties, within their minItems and maxItems.
+func WithArrayLength(n int) Option {
+	return func(s *Server) error {
+		if n < 0 {
+			return fmt.Errorf("array length must not be negative, got %d", n)
+		}
+		s.arrayLength = n
+		return nil
+	}
+}
+
+// WithSeed makes generated data reproducible: random mode draws from a
+// generator seeded with n, and records generated for an id are the same
+// across restarts.
+func WithSeed(n int64) Option {
+	return func(s *Server) error { s.seed, s.seeded = n, true; return nil }
+}
+
+// WithFaker generates realistic values for string properties named like
+// name, email, phone or city.
+func WithFaker(on bool) Option {
+	return func(s *Server) error { s.fakerMode = on; return nil }
+}
+
+// WithAsyncCreate responds 202 to POST and completes the create after
+// delay. Zero creates synchronously.
+func WithAsyncCreate(delay time.Duration) Option {
+	return func(s *Server) error { s.asyncCreateDelay = delay; return nil }
+}
+
+// WithValidation sets how request bodies that do not match the schema are
+// handled: reject or warn.
+func WithValidation(mode string) Option {
+	return func(s *Server) error {
+		if !containsString(validationModes, mode) {
+			return fmt.Errorf("validation mode must be one of %s, got %q", strings.Join(validationModes, ", "), mode)
+		}
+		s.validationMode = mode
+		return nil
+	}
+}
+
+// WithOptionalFields sets how generated objects represent optional
+// properties: fill, null or omit.
+func WithOptionalFields(mode string) Option {
+	return func(s *Server) error {
+		if !containsString(optionalFieldModes, mode) {
+			return fmt.Errorf("optional fields mode must be one of %s, got %q", strings.Join(optionalFieldModes, ", "), mode)
+		}
+		s.optionalFields = mode
+		return nil
+	}
+}
+
+// WithIDSequence sets the first auto-assigned id and the increment for
+// schemas without x-id-start or x-id-step.
+func WithIDSequence(start, step int) Option {
+	return func(s *Server) error {
+		if step < 1 {
+			return fmt.Errorf("id step must be at least 1, got %d", step)
+		}
+		s.idStart, s.idStep = start, step
+		return nil
+	}
+}
+
+// WithListSize sets how many generated records a list holds before any
+// record is written, which is what pagination pages through.
+func WithListSize(n int) Option {
+	return func(s *Server) error {
+		if n < 0 || n > maxListSize {
+			return fmt.Errorf("list size must be between 0 and %d, got %d", maxListSize, n)
+		}
+		s.listSize = n
+		return nil
+	}
+}
+
+// WithAuth makes every request outside the admin API and /healthz carry
+// one of keys, in an X-API-Key or Authorization: Bearer header, or a token
+// issued at /oauth/token. A key ending in :read may only make GET and HEAD
+// requests. More keys can be added at /__admin/keys.
+func WithAuth(keys ...string) Option {
+	return func(s *Server) error {
+		for _, raw := range keys {
+			key, err := parseAPIKey(raw)
+			if err != nil {
+				return err
+			}
+			if !s.apiKeys.add(key) {
+				return fmt.Errorf("API key %q is given twice", key.Key)
+			}
+		}
+		s.authRequired = true
+		return nil
+	}
+}
+
+// WithAccessRules adds rules requiring roles for some methods of some
+// entities' routes, each given as "DELETE /users=admin", with alternative
+// roles separated by |. They are checked under WithAuth against the roles
+// of the API key or token; more can be added at /__admin/rules.
+func WithAccessRules(rules ...string) Option {
+	return func(s *Server) error {
+		for _, raw := range rules {
+			rule, err := parseAccessRule(raw)
+			if err != nil {
+				return err
+			}
+			s.accessRules.add(rule)
+		}
+		return nil
+	}
+}
+
+// WithBasePath serves every route under prefix, e.g. /api/v1.
+func WithBasePath(prefix string) Option {
+	return func(s *Server) error {
+		prefix = strings.TrimSuffix(prefix, "/")
+		if prefix != "" && !strings.HasPrefix(prefix, "/") {
+			return fmt.Errorf("base path must start with /, got %q", prefix)
+		}
+		s.basePath = prefix
+		return nil
+	}
+}
+
+// WithSchemaFile uploads the schema in the named file, as if it had been
+// POSTed to /upload. Repeat the option to preload several entities; files
+// are loaded in order.
+func WithSchemaFile(path string) Option {
+	return func(s *Server) error {
+		s.schemaFiles = append(s.schemaFiles, path)
+		return nil
+	}
+}
+
+// WithFixturesFile loads the records in the named fixtures file, as if it
+// had been POSTed to /upload/fixtures, once the WithSchemaFile schemas are
+// uploaded. Repeat the option to load several files in order.
+func WithFixturesFile(path string) Option {
+	return func(s *Server) error {
+		s.fixtureFiles = append(s.fixtureFiles, path)
+		return nil
+	}
+}
+
+// WithSQLFile registers an entity for every table the SQL DDL in the named
+// file creates, as if it had been POSTed to /upload/sql. Like schema
+// files, it is only loaded while the data directory has no snapshot.
+func WithSQLFile(path string) Option {
+	return func(s *Server) error {
+		s.sqlFiles = append(s.sqlFiles, path)
+		return nil
+	}
+}
+
+// WithOpenAPIFile imports the OpenAPI document in the named file, as if it
+// had been POSTed to /upload/openapi. Repeat the option to import several.
+func WithOpenAPIFile(path string) Option {
+	return func(s *Server) error {
+		s.openAPIFiles = append(s.openAPIFiles, path)
+		return nil
+	}
+}
+
+// WithPostmanFile imports the Postman collection in the named file, as if
+// it had been POSTed to /upload/postman. Repeat the option to import
+// several.
+func WithPostmanFile(path string) Option {
+	return func(s *Server) error {
+		s.postmanFiles = append(s.postmanFiles, path)
+		return nil
+	}
+}
+
+// WithOverridesFile adds the response overrides in the named JSON or YAML
+// file, an {"overrides": [...]} document. Repeat the option to load
+// several; more can be added at /__admin/overrides.
+func WithOverridesFile(path string) Option {
+	return func(s *Server) error {
+		return s.loadOverridesFile(path)
+	}
+}
+
+// WithDataDir keeps a snapshot of the uploaded schemas and their records in
+// dir, creating it if needed. The snapshot is saved after every write and
+// restored by New, in which case WithSchemaFile and WithFixturesFile files
+// are not loaded.
+func WithDataDir(dir string) Option {
+	return func(s *Server) error {
+		if dir == "" {
+			return fmt.Errorf("data directory must not be empty")
+		}
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("data directory: %v", err)
+		}
+		s.dataDir = dir
+		return nil
+	}
+}
+
+// loadSchemaFile activates the schema in the named file.
+func (s *Server) loadSchemaFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if _, err := s.loadSchema(file); err != nil {
+		return fmt.Errorf("schema %s: %w", path, err)
+	}
+	return nil
+}
+
+// WithLatency delays every response by base, varied randomly by up to
+// jitter in either direction.
+func WithLatency(base, jitter time.Duration) Option {
+	return func(s *Server) error {
+		s.latency, s.latencyJitter = base, jitter
+		return nil
+	}
+}
+
+// WithErrorRate answers the given share of requests, from 0 to 1, with a
+// random 500, 502, 503 or 504 instead of handling them.
+func WithErrorRate(rate float64) Option {
+	return func(s *Server) error {
+		if rate < 0 || rate > 1 {
+			return fmt.Errorf("error rate must be between 0 and 1, got %v", rate)
+		}
+		s.chaos.errorRate = rate
+		return nil
+	}
+}
+
+// WithDropRate closes the connection of the given share of requests, from
+// 0 to 1, without sending a response.
+func WithDropRate(rate float64) Option {
+	return func(s *Server) error {
+		if rate < 0 || rate > 1 {
+			return fmt.Errorf("drop rate must be between 0 and 1, got %v", rate)
+		}
+		s.chaos.dropRate = rate
+		return nil
+	}
+}
+
+// WithSlowDrip writes every response body in small chunks, waiting interval
+// between them. Zero sends responses at full speed.
+func WithSlowDrip(interval time.Duration) Option {
+	return func(s *Server) error {
+		if interval < 0 {
+			return fmt.Errorf("drip interval must not be negative, got %v", interval)
+		}
+		s.chaos.drip = interval
+		return nil
+	}
+}
+
+// WithPassthrough proxies requests to routes the mock does not serve to
+// the upstream at rawURL, e.g. https://api.example.com, so the mock can
+// stand in for part of a real API.
+func WithPassthrough(rawURL string) Option {
+	return func(s *Server) error {
+		u, err := url.Parse(rawURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("passthrough upstream must be an http or https URL, got %q", rawURL)
+		}
+		s.upstream = u
+		return nil
+	}
+}
+
+// WithRateLimit answers clients making more than limit requests per
+// window 429 Too Many Requests. Zero disables rate limiting.
+func WithRateLimit(limit int, window time.Duration) Option {
+	return func(s *Server) error {
+		if limit < 0 {
+			return fmt.Errorf("rate limit must not be negative, got %d", limit)
+		}
+		if window <= 0 {
+			return fmt.Errorf("rate limit window must be positive, got %v", window)
+		}
+		s.rateLimit.limit, s.rateLimit.window = limit, window
+		return nil
+	}
+}
+
+// WithRateLimitBy sets how WithRateLimit tells clients apart: "key" by the
+// API key or token they send, falling back to their address, or "ip" by
+// address only.
+func WithRateLimitBy(by string) Option {
+	return func(s *Server) error {
+		if by != "key" && by != "ip" {
+			return fmt.Errorf("rate limit clients must be told apart by key or ip, got %q", by)
+		}
+		s.rateLimit.by = by
+		return nil
+	}
+}
+
+// WithCORSOrigins sets the origins browsers may call the server from, such
+// as "https://app.example.com"; "*" allows any origin and
+// "https://*.example.com" any of its subdomains. Every origin is allowed by
+// default, and no origins at all disable CORS.
+func WithCORSOrigins(origins ...string) Option {
+	return func(s *Server) error {
+		for _, origin := range origins {
+			if origin != "*" && !strings.Contains(origin, "://") {
+				return fmt.Errorf("CORS origin must be * or a scheme and host such as https://example.com, got %q", origin)
+			}
+		}
+		s.cors.origins = origins
+		return nil
+	}
+}
+
+// WithCORSMethods sets the methods CORS preflights allow. By default they
+// allow every method the entity routes support.
+func WithCORSMethods(methods ...string) Option {
+	return func(s *Server) error {
+		s.cors.methods = nil
+		for _, method := range methods {
+			s.cors.methods = append(s.cors.methods, strings.ToUpper(method))
+		}
+		return nil
+	}
+}
+
+// WithCORSHeaders sets the request headers CORS preflights allow. By
+// default they allow whatever headers the browser asks for.
+func WithCORSHeaders(headers ...string) Option {
+	return func(s *Server) error { s.cors.headers = headers; return nil }
+}
+
+// WithCORSCredentials lets cross-origin requests carry cookies and HTTP
+// authentication.
+func WithCORSCredentials(on bool) Option {
+	return func(s *Server) error { s.cors.credentials = on; return nil }
+}
+
+// WithHTMLErrors renders error responses as HTML pages for browsers.
+func WithHTMLErrors(on bool) Option {
+	return func(s *Server) error { s.htmlErrors = on; return nil }
+}
+
+// WithEnvelope wraps successful entity responses as {data: ..., meta:
+// {...}} under the given keys. An empty data key sends records unwrapped.
+func WithEnvelope(data, meta string) Option {
+	return func(s *Server) error {
+		if data != "" && data == meta {
+			return fmt.Errorf("envelope data and meta keys must differ, both are %q", data)
+		}
+		s.envelope.data = data
+		if meta != "" {
+			s.envelope.meta = meta
+		}
+		return nil
+	}
+}
+
+// WithPaginationMeta chooses where list pagination metadata lives: in the
+// X-Total-Count and Link headers, in the envelope's meta object, or both.
+// The body modes need an envelope.
+func WithPaginationMeta(mode string) Option {
+	return func(s *Server) error {
+		if !containsString(paginationMetaModes, mode) {
+			return fmt.Errorf("unknown pagination meta mode %q, expected one of %s", mode, strings.Join(paginationMetaModes, ", "))
+		}
+		s.envelope.pagination = mode
+		return nil
+	}
+}
+
+// WithLegacyErrors keeps error responses as application/json Error bodies
+// instead of RFC 7807 problem details.
+func WithLegacyErrors(on bool) Option {
+	return func(s *Server) error { s.legacyErrors = on; return nil }
+}
+
+// WithJSONAPI serves every entity route as JSON:API, not only requests
+// accepting application/vnd.api+json.
+func WithJSONAPI(on bool) Option {
+	return func(s *Server) error { s.jsonAPI = on; return nil }
+}
+
+// WithCompression compresses responses with gzip or deflate when the
+// request's Accept-Encoding allows it.
+func WithCompression(on bool) Option {
+	return func(s *Server) error { s.compression.enabled = on; return nil }
+}
+
+// WithCompressionMinSize sets the body size, in bytes, from which
+// responses are compressed. Smaller bodies are sent as they are.
+func WithCompressionMinSize(n int) Option {
+	return func(s *Server) error {
+		if n < 0 {
+			return fmt.Errorf("compression minimum size must not be negative, got %d", n)
+		}
+		s.compression.minSize = n
+		return nil
+	}
+}
+
+// WithRequestTimeout responds 503 to requests that take longer than d.
+// Zero disables the timeout.
+func WithRequestTimeout(d time.Duration) Option {
+	return func(s *Server) error { s.requestTimeout = d; return nil }
+}
+
+// WithRecorder appends every request and response to w as JSON lines.
+// Close the Server to flush the recording.
+func WithRecorder(w io.Writer) Option {
+	return func(s *Server) error {
+		s.recorder = newSessionRecorder(w)
+		return nil
+	}
+}
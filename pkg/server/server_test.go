@@ -0,0 +1,147 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewServer(t *testing.T) {
+	var recording bytes.Buffer
+	srv := NewServer(WithStrictGet(true), WithGenMode(genSequential), WithRecorder(&recording))
+	ts := httptest.NewServer(srv.Handler())
+
+	resp, err := http.Post(ts.URL+"/upload", "application/json", strings.NewReader(`{"title":"User","properties":{"id":{"type":"integer"},"name":{"type":"string"}}}`))
+	if err != nil {
+		t.Fatalf("upload failed: %v", err)
+	}
+	resp.Body.Close()
+	resp, err = http.Get(ts.URL + "/users/1")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("WithStrictGet was not applied: got status %v want %v", resp.StatusCode, http.StatusNotFound)
+	}
+	ts.Close()
+	srv.Close()
+	if got := strings.Count(recording.String(), "\n"); got != 2 {
+		t.Errorf("recorder wrote %d exchanges, want 2", got)
+	}
+
+	// A new server starts from the defaults with nothing uploaded.
+	next := NewServer()
+	if next.strictGet || next.genMode != genConstant {
+		t.Errorf("settings leaked into the next server: strictGet=%v genMode=%v", next.strictGet, next.genMode)
+	}
+	if schema, _ := next.activeState(); schema != nil {
+		t.Errorf("schema leaked into the next server: %v", schema.Title)
+	}
+}
+
+func TestServersAreIndependent(t *testing.T) {
+	for _, title := range []string{"User", "Order"} {
+		t.Run(title, func(t *testing.T) {
+			t.Parallel()
+			strict := title == "User"
+			ts := httptest.NewServer(NewServer(WithStrictGet(strict)).Handler())
+			defer ts.Close()
+			post := func(path, body string) {
+				t.Helper()
+				resp, err := http.Post(ts.URL+path, "application/json", strings.NewReader(body))
+				if err != nil {
+					t.Fatalf("POST %s failed: %v", path, err)
+				}
+				resp.Body.Close()
+			}
+			entity := "/" + strings.ToLower(title) + "s"
+			post("/upload", `{"title":"`+title+`","properties":{"id":{"type":"integer"},"name":{"type":"string"}}}`)
+			for i := 0; i < 50; i++ {
+				post(entity, `{"name":"a"}`)
+			}
+
+			resp, err := http.Get(ts.URL + entity)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			resp.Body.Close()
+			if total := resp.Header.Get("X-Total-Count"); total != "50" {
+				t.Errorf("GET %s counted %s records, want 50", entity, total)
+			}
+			resp, err = http.Get(ts.URL + "/entities")
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			var entities []entitySummary
+			json.NewDecoder(resp.Body).Decode(&entities)
+			resp.Body.Close()
+			if len(entities) != 1 || entities[0].Title != title {
+				t.Errorf("server lists another server's entities: %+v", entities)
+			}
+			resp, err = http.Get(ts.URL + entity + "/51")
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			resp.Body.Close()
+			if want := map[bool]int{true: http.StatusNotFound, false: http.StatusOK}[strict]; resp.StatusCode != want {
+				t.Errorf("GET %s/51 returned %v, want %v", entity, resp.StatusCode, want)
+			}
+		})
+	}
+}
+
+func TestNewInvalidOption(t *testing.T) {
+	for _, opt := range []Option{WithGenMode("wild"), WithIDSequence(1, 0), WithValidation("maybe"), WithOptionalFields("skip"), WithArrayLength(-1), WithListSize(-1), WithBasePath("api"), WithSchemaFile("missing.json")} {
+		if _, err := New(opt); err == nil {
+			t.Errorf("New accepted an invalid option")
+		}
+	}
+	if _, err := New(WithAsyncCreate(time.Second)); err != nil {
+		t.Errorf("New rejected a valid option: %v", err)
+	}
+}
+
+func TestWithBasePath(t *testing.T) {
+	handler := NewServer(WithBasePath("/api/v1/"), WithAsyncCreate(time.Millisecond)).Handler()
+
+	rr := performRequest(t, handler.ServeHTTP, "POST", "/api/v1/upload", []byte(`{"title":"User","properties":{"id":{"type":"integer"},"name":{"type":"string"}}}`))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	rr = performRequest(t, handler.ServeHTTP, "POST", "/api/v1/users", []byte(`{"name":"Ann"}`))
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusAccepted)
+	}
+	if location := rr.Header().Get("Location"); !strings.HasPrefix(location, "/api/v1/jobs/") {
+		t.Errorf("Location is outside the base path: %q", location)
+	}
+	if rr = performRequest(t, handler.ServeHTTP, "GET", "/users", nil); rr.Code != http.StatusNotFound {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusNotFound)
+	}
+
+	rr = performRequest(t, handler.ServeHTTP, "GET", "/api/v1/", nil)
+	if !strings.Contains(rr.Body.String(), `"/api/v1/upload"`) || !strings.Contains(rr.Body.String(), `"/api/v1/users"`) {
+		t.Errorf("index does not link under the base path: %s", rr.Body.String())
+	}
+	rr = performRequest(t, handler.ServeHTTP, "GET", "/api/v1/openapi.json", nil)
+	if !strings.Contains(rr.Body.String(), `"url":"/api/v1/"`) {
+		t.Errorf("OpenAPI servers do not include the base path: %s", rr.Body.String())
+	}
+}
+
+func TestWithSchemaFile(t *testing.T) {
+	handler := NewServer(WithIDSequence(100, 1), WithSchemaFile("../../user_schema.json")).Handler()
+
+	rr := performRequest(t, handler.ServeHTTP, "POST", "/users", []byte(`{"name":"Ann","email":"ann@example.com"}`))
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusCreated)
+	}
+	if !strings.Contains(rr.Body.String(), `"id":100`) {
+		t.Errorf("preloaded schema ignored the id settings: %s", rr.Body.String())
+	}
+}
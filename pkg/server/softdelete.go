@@ -0,0 +1,107 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// softDeleteKey is the property soft-deleted records carry their deletion
+// time in, as RFC 3339.
+const softDeleteKey = "deletedAt"
+
+// includeDeletedParam is the query parameter that lists soft-deleted
+// records too, as in ?includeDeleted=true.
+const includeDeletedParam = "includeDeleted"
+
+// restoreSegment is the last path segment of the restore route.
+const restoreSegment = "restore"
+
+// errSoftDeleted is returned when a change is asked of a soft-deleted
+// record.
+var errSoftDeleted = errors.New("record is deleted")
+
+// errNotDeleted is returned when a record that is not soft-deleted is
+// restored.
+var errNotDeleted = errors.New("record is not deleted")
+
+// softDeleted reports whether obj was soft-deleted.
+func (s *Server) softDeleted(obj map[string]interface{}) bool {
+	return s.softDelete && obj[softDeleteKey] != nil
+}
+
+// includeDeleted reports whether a request asks to see soft-deleted
+// records.
+func includeDeleted(r *http.Request) bool {
+	on, _ := strconv.ParseBool(r.URL.Query().Get(includeDeletedParam))
+	return on
+}
+
+// visibleRecords drops the soft-deleted records from list, unless the
+// request asks for them.
+func (s *Server) visibleRecords(r *http.Request, list []map[string]interface{}) []map[string]interface{} {
+	if !s.softDelete || includeDeleted(r) {
+		return list
+	}
+	visible := list[:0]
+	for _, obj := range list {
+		if !s.softDeleted(obj) {
+			visible = append(visible, obj)
+		}
+	}
+	return visible
+}
+
+// softDeleteRecord marks the record stored under key as deleted now,
+// reporting whether there was a record that was not deleted yet.
+func (s *Server) softDeleteRecord(records *recordStore, key string) bool {
+	_, found, err := records.update(key, func(obj map[string]interface{}) error {
+		if s.softDeleted(obj) {
+			return errSoftDeleted
+		}
+		obj[softDeleteKey] = time.Now().UTC().Format(time.RFC3339)
+		return nil
+	})
+	return found && err == nil
+}
+
+// serveRestore answers POST /{entity}/{id}/restore, clearing the deletedAt
+// of a soft-deleted record and responding with it. Records that are not
+// deleted answer 409 and ones never stored 404.
+func (s *Server) serveRestore(w http.ResponseWriter, r *http.Request, schema *Schema, records *recordStore, rawID string) {
+	if !schema.allowsMethod(http.MethodDelete) {
+		writeNotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w, "Method not allowed for this route", http.MethodPost)
+		return
+	}
+	id, ok := parseRecordID(w, schema, rawID)
+	if !ok {
+		return
+	}
+	obj, found, err := records.update(fmt.Sprint(id), func(obj map[string]interface{}) error {
+		if !s.softDeleted(obj) {
+			return errNotDeleted
+		}
+		delete(obj, softDeleteKey)
+		return nil
+	})
+	if !found {
+		writeNotFound(w, r)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusConflict, fmt.Sprintf("%s %v is not deleted", schema.Title, id))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(hideWriteOnly(schema, obj)); err != nil {
+		log.Println("Error encoding response:", err)
+	}
+}
@@ -0,0 +1,75 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestSoftDelete(t *testing.T) {
+	srv := NewServer()
+	srv.softDelete = true
+	srv.currentSchema = createSampleSchema()
+	srv.currentSchema.Properties["password"] = Property{Type: "string", WriteOnly: true}
+	srv.store = newRecordStore()
+	srv.registerSchema(srv.currentSchema)
+	srv.stores["user"] = srv.store
+	for _, body := range []string{`{"id": 1, "name": "alice", "email": "a@example.com", "password": "secret"}`, `{"id": 2, "name": "bob", "email": "b@example.com"}`} {
+		performRequest(t, srv.catchAllHandler, http.MethodPost, "/users", []byte(body))
+	}
+	count := func(path string) int {
+		t.Helper()
+		var list []map[string]interface{}
+		rr := performRequest(t, srv.catchAllHandler, http.MethodGet, path, nil)
+		if err := json.Unmarshal(rr.Body.Bytes(), &list); err != nil {
+			t.Fatalf("could not decode response: %v", err)
+		}
+		return len(list)
+	}
+
+	if rr := performRequest(t, srv.catchAllHandler, http.MethodDelete, "/users/1", nil); rr.Code != http.StatusNoContent {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusNoContent)
+	}
+	if n := count("/users"); n != 1 {
+		t.Errorf("list kept the deleted record: got %d records", n)
+	}
+	if n := count("/users?includeDeleted=true"); n != 2 {
+		t.Errorf("list left out the deleted record: got %d records", n)
+	}
+	rr := performRequest(t, srv.catchAllHandler, http.MethodGet, "/users/1?includeDeleted=true", nil)
+	if !strings.Contains(rr.Body.String(), `"deletedAt":"`) {
+		t.Errorf("deleted record has no deletedAt: got %v", rr.Body.String())
+	}
+
+	cases := []struct {
+		name, method, path string
+		status             int
+	}{
+		{"Get Deleted", http.MethodGet, "/users/1", http.StatusNotFound},
+		{"Patch Deleted", http.MethodPatch, "/users/1", http.StatusNotFound},
+		{"Delete Again", http.MethodDelete, "/users/1", http.StatusNotFound},
+		{"Restore Live", http.MethodPost, "/users/2/restore", http.StatusConflict},
+		{"Restore Unknown", http.MethodPost, "/users/9/restore", http.StatusNotFound},
+		{"Restore Method", http.MethodGet, "/users/1/restore", http.StatusMethodNotAllowed},
+		{"Restore", http.MethodPost, "/users/1/restore", http.StatusOK},
+		{"Get Restored", http.MethodGet, "/users/1", http.StatusOK},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rr := performRequest(t, srv.catchAllHandler, tc.method, tc.path, []byte(`{}`))
+			if status := rr.Code; status != tc.status {
+				t.Errorf("handler returned wrong status code: got %v want %v", status, tc.status)
+			}
+		})
+	}
+	if rr := performRequest(t, srv.catchAllHandler, http.MethodGet, "/users/1", nil); strings.Contains(rr.Body.String(), "deletedAt") {
+		t.Errorf("restored record kept deletedAt: got %v", rr.Body.String())
+	}
+
+	performRequest(t, srv.catchAllHandler, http.MethodDelete, "/users/1", nil)
+	rr = performRequest(t, srv.catchAllHandler, http.MethodPost, "/users/1/restore", nil)
+	if rr.Code != http.StatusOK || strings.Contains(rr.Body.String(), "password") || !strings.Contains(rr.Body.String(), `"name":"alice"`) {
+		t.Errorf("restore returned unexpected record: got %v %v", rr.Code, rr.Body.String())
+	}
+}
@@ -0,0 +1,638 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// specMethods lists the operations of an OpenAPI path item that are mocked.
+var specMethods = []string{"get", "put", "post", "delete", "options", "head", "patch"}
+
+// importedResponse is what an imported operation answers: its documented
+// success status and, if it has a body, an example or a schema to
+// generate one from.
+type importedResponse struct {
+	status      int
+	contentType string
+	hasBody     bool
+	example     interface{}
+	hasExample  bool
+	schema      *Property
+}
+
+// importedRoute is one operation of an imported OpenAPI document.
+type importedRoute struct {
+	method   string
+	template string
+	segments []string
+	response importedResponse
+}
+
+// specDocument is an OpenAPI 2 or 3 document being imported.
+type specDocument struct {
+	server   *Server
+	raw      map[string]interface{}
+	swagger  bool
+	warnings []string
+}
+
+// importOpenAPI turns the operations of an OpenAPI 3 or Swagger 2.0
+// document into routes. Operations whose response cannot be mocked are
+// still routed, without a body, and reported as warnings.
+func (s *Server) importOpenAPI(raw map[string]interface{}) ([]*importedRoute, []string, error) {
+	doc := &specDocument{server: s, raw: raw}
+	switch version, _ := raw["openapi"].(string); {
+	case strings.HasPrefix(version, "3."):
+	case raw["swagger"] == "2.0":
+		doc.swagger = true
+	default:
+		return nil, nil, errors.New(`expected an OpenAPI 3 document ("openapi": "3.x") or a Swagger 2.0 one ("swagger": "2.0")`)
+	}
+	paths, ok := raw["paths"].(map[string]interface{})
+	if !ok || len(paths) == 0 {
+		return nil, nil, errors.New("the document has no paths")
+	}
+
+	base := doc.basePath()
+	templates := make([]string, 0, len(paths))
+	for template := range paths {
+		templates = append(templates, template)
+	}
+	sort.Strings(templates)
+	var routes []*importedRoute
+	for _, template := range templates {
+		if !strings.HasPrefix(template, "/") {
+			return nil, nil, fmt.Errorf("path %q does not start with /", template)
+		}
+		item, err := doc.resolve(paths[template])
+		if err != nil {
+			return nil, nil, fmt.Errorf("path %s: %v", template, err)
+		}
+		for _, method := range specMethods {
+			op, ok := item[method].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			full := base + template
+			if full != "/" {
+				full = strings.TrimSuffix(full, "/")
+			}
+			name := strings.ToUpper(method) + " " + full
+			routes = append(routes, &importedRoute{
+				method:   strings.ToUpper(method),
+				template: full,
+				segments: strings.Split(strings.Trim(full, "/"), "/"),
+				response: doc.operationResponse(name, op),
+			})
+		}
+	}
+	if len(routes) == 0 {
+		return nil, nil, errors.New("the document defines no operations")
+	}
+	return routes, doc.warnings, nil
+}
+
+// basePath returns the path prefix the document's operations are served
+// under: basePath for Swagger 2.0, the path of the first server for
+// OpenAPI 3.
+func (doc *specDocument) basePath() string {
+	var prefix string
+	if doc.swagger {
+		prefix, _ = doc.raw["basePath"].(string)
+	} else if servers, ok := doc.raw["servers"].([]interface{}); ok && len(servers) > 0 {
+		if server, ok := servers[0].(map[string]interface{}); ok {
+			raw, _ := server["url"].(string)
+			if u, err := url.Parse(raw); err == nil {
+				prefix = u.Path
+			}
+		}
+	}
+	return strings.TrimSuffix(prefix, "/")
+}
+
+// warn records a problem that does not stop the import.
+func (doc *specDocument) warn(format string, args ...interface{}) {
+	doc.warnings = append(doc.warnings, fmt.Sprintf(format, args...))
+}
+
+// resolve follows a $ref to an object, if node is one.
+func (doc *specDocument) resolve(node interface{}) (map[string]interface{}, error) {
+	for i := 0; i <= maxRefDepth; i++ {
+		obj, ok := node.(map[string]interface{})
+		if !ok {
+			return nil, errors.New("expected an object")
+		}
+		ref, ok := obj["$ref"].(string)
+		if !ok {
+			return obj, nil
+		}
+		target, err := doc.pointer(ref)
+		if err != nil {
+			return nil, err
+		}
+		node = target
+	}
+	return nil, errors.New("too many nested $ref")
+}
+
+// pointer returns the value a local $ref points to.
+func (doc *specDocument) pointer(ref string) (interface{}, error) {
+	pointer, ok := strings.CutPrefix(ref, "#")
+	if !ok {
+		return nil, fmt.Errorf("unsupported $ref %q: only local references are resolved", ref)
+	}
+	tokens, err := parsePointer(pointer)
+	if err != nil {
+		return nil, fmt.Errorf("invalid $ref %q: %v", ref, err)
+	}
+	target, err := valueAt(doc.raw, tokens)
+	if err != nil {
+		return nil, fmt.Errorf("unresolved $ref %q", ref)
+	}
+	return target, nil
+}
+
+// operationResponse picks the response an operation is mocked with: its
+// lowest 2xx response, else the default one, else the lowest documented.
+func (doc *specDocument) operationResponse(name string, op map[string]interface{}) importedResponse {
+	result := importedResponse{status: http.StatusOK}
+	responses, _ := op["responses"].(map[string]interface{})
+	code, best := "", 0
+	for key := range responses {
+		status, err := strconv.Atoi(strings.NewReplacer("X", "0", "x", "0").Replace(key))
+		if key == "default" {
+			status, err = 299, nil
+		}
+		if err != nil || status < 100 || status > 599 {
+			continue
+		}
+		// Rank 2xx first, then default, then everything else.
+		rank := status
+		if status < 200 || status > 299 {
+			rank += 1000
+		}
+		if code == "" || rank < best {
+			code, best = key, rank
+		}
+	}
+	if code == "" {
+		return result
+	}
+	if code != "default" {
+		result.status, _ = strconv.Atoi(strings.NewReplacer("X", "0", "x", "0").Replace(code))
+	}
+	response, err := doc.resolve(responses[code])
+	if err != nil {
+		doc.warn("%s: response %s: %v", name, code, err)
+		return result
+	}
+
+	var schema interface{}
+	if doc.swagger {
+		result.contentType = "application/json"
+		if examples, ok := response["examples"].(map[string]interface{}); ok {
+			for _, mediaType := range sortedKeys(examples) {
+				result.contentType, result.example, result.hasExample = mediaType, examples[mediaType], true
+				if strings.Contains(mediaType, "json") {
+					break
+				}
+			}
+		}
+		schema = response["schema"]
+	} else {
+		content, _ := response["content"].(map[string]interface{})
+		mediaTypes := sortedKeys(content)
+		if len(mediaTypes) == 0 {
+			return result
+		}
+		result.contentType = mediaTypes[0]
+		for _, mediaType := range mediaTypes {
+			if strings.Contains(mediaType, "json") {
+				result.contentType = mediaType
+				break
+			}
+		}
+		media, _ := content[result.contentType].(map[string]interface{})
+		if example, ok := media["example"]; ok {
+			result.example, result.hasExample = example, true
+		} else if examples, ok := media["examples"].(map[string]interface{}); ok && len(examples) > 0 {
+			if example, err := doc.resolve(examples[sortedKeys(examples)[0]]); err == nil {
+				result.example, result.hasExample = example["value"], true
+			}
+		}
+		schema = media["schema"]
+	}
+	if schema == nil {
+		result.hasBody = result.hasExample
+		return result
+	}
+	result.hasBody = true
+	if result.hasExample {
+		return result
+	}
+
+	inlined, err := doc.inline(schema, make(map[string]int))
+	if err != nil {
+		doc.warn("%s: response schema: %v", name, err)
+		result.hasBody = false
+		return result
+	}
+	normalized := normalizeSpecSchema(inlined)
+	if obj, ok := normalized.(map[string]interface{}); ok {
+		if example, ok := obj["example"]; ok {
+			result.example, result.hasExample = example, true
+			return result
+		}
+	}
+	prop, err := specProperty(normalized)
+	if err == nil {
+		err = doc.server.validateProperty("response", prop)
+	}
+	if err != nil {
+		doc.warn("%s: response schema: %v", name, err)
+		result.hasBody = false
+		return result
+	}
+	result.schema = &prop
+	return result
+}
+
+// sortedKeys returns the keys of a map in order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// inline returns a copy of a schema with its references replaced by their
+// targets. Recursive references are cut off after maxRefDepth expansions,
+// like those of uploaded schemas.
+func (doc *specDocument) inline(node interface{}, depth map[string]int) (interface{}, error) {
+	switch v := node.(type) {
+	case map[string]interface{}:
+		if ref, ok := v["$ref"].(string); ok {
+			target, err := doc.pointer(ref)
+			if err != nil {
+				return nil, err
+			}
+			if depth[ref] >= maxRefDepth {
+				cut := map[string]interface{}{"type": "object"}
+				if obj, ok := target.(map[string]interface{}); ok && obj["type"] != nil {
+					cut["type"] = obj["type"]
+				}
+				return cut, nil
+			}
+			depth[ref]++
+			defer func() { depth[ref]-- }()
+			return doc.inline(target, depth)
+		}
+		out := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			inlined, err := doc.inline(value, depth)
+			if err != nil {
+				return nil, err
+			}
+			out[key] = inlined
+		}
+		return out, nil
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, value := range v {
+			inlined, err := doc.inline(value, depth)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = inlined
+		}
+		return out, nil
+	}
+	return node, nil
+}
+
+// normalizeSpecSchema rewrites the OpenAPI flavours of JSON Schema into the
+// subset properties understand: allOf is merged, the first alternative of
+// oneOf and anyOf is used, nullable type lists lose their "null", and
+// boolean exclusive bounds become numeric ones.
+func normalizeSpecSchema(node interface{}) interface{} {
+	obj, ok := node.(map[string]interface{})
+	if !ok {
+		return node
+	}
+	out := make(map[string]interface{}, len(obj))
+	for _, keyword := range []string{"allOf", "oneOf", "anyOf"} {
+		parts, _ := obj[keyword].([]interface{})
+		if keyword != "allOf" && len(parts) > 1 {
+			parts = parts[:1]
+		}
+		for _, part := range parts {
+			sub, ok := normalizeSpecSchema(part).(map[string]interface{})
+			if !ok {
+				continue
+			}
+			for key, value := range sub {
+				switch key {
+				case "properties":
+					merged, _ := out[key].(map[string]interface{})
+					if merged == nil {
+						merged = make(map[string]interface{})
+					}
+					props, _ := value.(map[string]interface{})
+					for name, prop := range props {
+						merged[name] = prop
+					}
+					out[key] = merged
+				case "required":
+					existing, _ := out[key].([]interface{})
+					list, _ := value.([]interface{})
+					out[key] = append(existing, list...)
+				default:
+					if _, set := out[key]; !set {
+						out[key] = value
+					}
+				}
+			}
+		}
+	}
+	for key, value := range obj {
+		switch key {
+		case "allOf", "oneOf", "anyOf", "nullable":
+		case "properties":
+			props, ok := value.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			merged, _ := out[key].(map[string]interface{})
+			if merged == nil {
+				merged = make(map[string]interface{}, len(props))
+			}
+			for name, prop := range props {
+				merged[name] = normalizeSpecSchema(prop)
+			}
+			out[key] = merged
+		case "required":
+			existing, _ := out[key].([]interface{})
+			if list, ok := value.([]interface{}); ok {
+				out[key] = append(existing, list...)
+			}
+		case "items":
+			if list, ok := value.([]interface{}); ok {
+				// Draft 4 tuples.
+				for i := range list {
+					list[i] = normalizeSpecSchema(list[i])
+				}
+				out["prefixItems"] = list
+				continue
+			}
+			out[key] = normalizeSpecSchema(value)
+		case "prefixItems":
+			if list, ok := value.([]interface{}); ok {
+				for i := range list {
+					list[i] = normalizeSpecSchema(list[i])
+				}
+				out[key] = list
+			}
+		case "type":
+			if list, ok := value.([]interface{}); ok {
+				for _, t := range list {
+					if t != "null" {
+						out[key] = t
+						break
+					}
+				}
+				continue
+			}
+			if _, ok := value.(string); ok {
+				out[key] = value
+			}
+		case "exclusiveMinimum", "exclusiveMaximum":
+			if on, ok := value.(bool); ok {
+				bound := "minimum"
+				if key == "exclusiveMaximum" {
+					bound = "maximum"
+				}
+				if n, ok := obj[bound]; ok && on {
+					out[key] = n
+				}
+				continue
+			}
+			out[key] = value
+		case "minimum", "maximum":
+			exclusive := "exclusiveM" + key[1:]
+			if on, _ := obj[exclusive].(bool); on {
+				continue
+			}
+			out[key] = value
+		default:
+			out[key] = value
+		}
+	}
+	if _, typed := out["type"]; !typed {
+		if _, ok := out["properties"]; ok {
+			out["type"] = "object"
+		} else if _, ok := out["items"]; ok {
+			out["type"] = "array"
+		}
+	}
+	return out
+}
+
+// specProperty converts a normalized OpenAPI schema into a property.
+func specProperty(schema interface{}) (Property, error) {
+	var prop Property
+	raw, err := json.Marshal(schema)
+	if err != nil {
+		return prop, err
+	}
+	if err := json.Unmarshal(raw, &prop); err != nil {
+		return prop, err
+	}
+	return prop, nil
+}
+
+// registerImportedRoutes adds imported routes, replacing any imported
+// before for the same method and path.
+func (s *Server) registerImportedRoutes(routes []*importedRoute) {
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+	for _, route := range routes {
+		replaced := false
+		for i, existing := range s.importedRoutes {
+			if existing.method == route.method && existing.template == route.template {
+				s.importedRoutes[i], replaced = route, true
+			}
+		}
+		if !replaced {
+			s.importedRoutes = append(s.importedRoutes, route)
+		}
+	}
+}
+
+// matchImportedRoute finds the imported operation serving a request. When
+// the path matches but the method does not, it returns the methods the
+// path allows. Literal segments win over parameters, so /pets/mine is
+// preferred to /pets/{id}.
+func (s *Server) matchImportedRoute(path, method string) (*importedRoute, []string) {
+	s.stateMu.RLock()
+	defer s.stateMu.RUnlock()
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	var best []string
+	for _, route := range s.importedRoutes {
+		if matchesTemplate(route.segments, segments) && (best == nil || moreSpecific(route.segments, best)) {
+			best = route.segments
+		}
+	}
+	if best == nil {
+		return nil, nil
+	}
+	var allowed []string
+	for _, route := range s.importedRoutes {
+		if strings.Join(route.segments, "/") != strings.Join(best, "/") {
+			continue
+		}
+		if route.method == method {
+			return route, nil
+		}
+		allowed = append(allowed, route.method)
+		if route.method == http.MethodGet {
+			allowed = append(allowed, http.MethodHead)
+		}
+	}
+	return nil, allowed
+}
+
+// isTemplateParam reports whether a path template segment is a parameter.
+func isTemplateParam(segment string) bool {
+	return strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}")
+}
+
+// matchesTemplate reports whether path segments fit a path template.
+func matchesTemplate(template, segments []string) bool {
+	if len(template) != len(segments) {
+		return false
+	}
+	for i, segment := range template {
+		if isTemplateParam(segment) {
+			if segments[i] == "" {
+				return false
+			}
+		} else if segment != segments[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// moreSpecific reports whether template a has a literal segment where b
+// has a parameter before the reverse happens.
+func moreSpecific(a, b []string) bool {
+	for i := range a {
+		if pa, pb := isTemplateParam(a[i]), isTemplateParam(b[i]); pa != pb {
+			return pb
+		}
+	}
+	return false
+}
+
+// serveImported answers a request with an imported operation's response.
+func (s *Server) serveImported(w http.ResponseWriter, r *http.Request, route *importedRoute) {
+	response := route.response
+	if !response.hasBody {
+		w.WriteHeader(response.status)
+		return
+	}
+	body := response.example
+	if !response.hasExample {
+		body = s.newGenerator(r).value("", *response.schema)
+	}
+	if text, ok := body.(string); ok && !strings.Contains(response.contentType, "json") {
+		w.Header().Set("Content-Type", response.contentType)
+		w.WriteHeader(response.status)
+		io.WriteString(w, text)
+		return
+	}
+	contentType := response.contentType
+	if !strings.Contains(contentType, "json") {
+		contentType = "application/json"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(response.status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Println("Error encoding response:", err)
+	}
+}
+
+// loadOpenAPI imports the OpenAPI document in r, returning the operations
+// it registered and any warnings.
+func (s *Server) loadOpenAPI(r io.Reader) ([]string, []string, error) {
+	var raw map[string]interface{}
+	decoder := json.NewDecoder(r)
+	if err := decoder.Decode(&raw); err != nil {
+		return nil, nil, err
+	}
+	if decoder.More() {
+		return nil, nil, errors.New("unexpected data after the document")
+	}
+	routes, warnings, err := s.importOpenAPI(raw)
+	if err != nil {
+		return nil, nil, err
+	}
+	s.registerImportedRoutes(routes)
+	operations := make([]string, len(routes))
+	for i, imported := range routes {
+		operations[i] = imported.method + " " + s.route(imported.template)
+	}
+	return operations, warnings, nil
+}
+
+// loadOpenAPIFile imports the OpenAPI document in the named file.
+func (s *Server) loadOpenAPIFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, warnings, err := s.loadOpenAPI(file)
+	if err != nil {
+		return fmt.Errorf("OpenAPI document %s: %w", path, err)
+	}
+	for _, warning := range warnings {
+		log.Printf("OpenAPI document %s: %s", path, warning)
+	}
+	return nil
+}
+
+// openAPIImportHandler imports an OpenAPI document POSTed to
+// /upload/openapi and mocks each of its operations.
+func (s *Server) openAPIImportHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w, "Only POST allowed", http.MethodPost)
+		return
+	}
+	defer r.Body.Close()
+	operations, warnings, err := s.loadOpenAPI(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid OpenAPI document: "+err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	response := map[string]interface{}{
+		"message":    "OpenAPI document imported successfully",
+		"operations": operations,
+	}
+	if len(warnings) > 0 {
+		response["warnings"] = warnings
+	}
+	json.NewEncoder(w).Encode(response)
+}
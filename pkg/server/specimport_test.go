@@ -0,0 +1,152 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const petstoreSpec = `{
+	"openapi": "3.0.3",
+	"servers": [{"url": "https://api.example.com/v1"}],
+	"paths": {
+		"/pets": {
+			"get": {"responses": {
+				"200": {"description": "ok", "content": {"application/json": {"schema": {"type": "array", "items": {"$ref": "#/components/schemas/Pet"}}}}},
+				"default": {"$ref": "#/components/responses/Error"}
+			}},
+			"post": {"responses": {"201": {"description": "created", "content": {"application/json": {"example": {"id": 10, "name": "Rex"}}}}}}
+		},
+		"/pets/mine": {
+			"get": {"responses": {"200": {"description": "ok", "content": {"application/json": {"examples": {"first": {"value": {"id": 1, "name": "Mine"}}}}}}}}
+		},
+		"/pets/{petId}": {
+			"get": {"responses": {"200": {"description": "ok", "content": {"application/json": {"schema": {
+				"allOf": [{"$ref": "#/components/schemas/Pet"}, {"properties": {"age": {"type": "integer", "minimum": 1, "exclusiveMinimum": true}}}]
+			}}}}}},
+			"delete": {"responses": {"204": {"description": "gone"}}}
+		},
+		"/broken": {
+			"get": {"responses": {"200": {"description": "ok", "content": {"application/json": {"schema": {"type": "string", "pattern": "(?=x)"}}}}}}
+		}
+	},
+	"components": {
+		"schemas": {
+			"Pet": {"type": "object", "required": ["id", "name"], "properties": {
+				"id": {"type": "integer", "format": "int64"},
+				"name": {"type": "string"},
+				"tag": {"type": ["string", "null"]}
+			}}
+		},
+		"responses": {"Error": {"description": "error", "content": {"application/json": {"schema": {"type": "object"}}}}}
+	}
+}`
+
+func TestImportOpenAPI(t *testing.T) {
+	srv := NewServer()
+
+	rr := performRequest(t, srv.openAPIImportHandler, http.MethodPost, "/upload/openapi", []byte(petstoreSpec))
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v (%v)", status, http.StatusOK, rr.Body.String())
+	}
+	var imported struct {
+		Operations []string `json:"operations"`
+		Warnings   []string `json:"warnings"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &imported); err != nil {
+		t.Fatalf("could not decode response: %v", err)
+	}
+	if len(imported.Operations) != 6 || imported.Operations[0] != "GET /v1/broken" {
+		t.Errorf("handler returned unexpected operations: got %v", imported.Operations)
+	}
+	if len(imported.Warnings) != 1 || !strings.Contains(imported.Warnings[0], "GET /v1/broken") {
+		t.Errorf("handler returned unexpected warnings: got %v", imported.Warnings)
+	}
+
+	cases := []struct {
+		method, path string
+		status       int
+		body         string
+	}{
+		{http.MethodPost, "/v1/pets", http.StatusCreated, `{"id":10,"name":"Rex"}`},
+		{http.MethodGet, "/v1/pets/mine", http.StatusOK, `{"id":1,"name":"Mine"}`},
+		{http.MethodDelete, "/v1/pets/7", http.StatusNoContent, ``},
+		{http.MethodGet, "/v1/broken", http.StatusOK, ``},
+	}
+	for _, tc := range cases {
+		rr := performRequest(t, srv.catchAllHandler, tc.method, tc.path, nil)
+		if rr.Code != tc.status || strings.TrimSpace(rr.Body.String()) != tc.body {
+			t.Errorf("%s %s: got %v %v want %v %v", tc.method, tc.path, rr.Code, rr.Body.String(), tc.status, tc.body)
+		}
+	}
+
+	rr = performRequest(t, srv.catchAllHandler, http.MethodGet, "/v1/pets", nil)
+	var list []map[string]interface{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &list); err != nil {
+		t.Fatalf("could not decode response: %v", err)
+	}
+	if len(list) != srv.arrayLength || list[0]["name"] == nil {
+		t.Errorf("handler returned unexpected generated list: got %v", rr.Body.String())
+	}
+
+	rr = performRequest(t, srv.catchAllHandler, http.MethodGet, "/v1/pets/7", nil)
+	var pet map[string]interface{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &pet); err != nil {
+		t.Fatalf("could not decode response: %v", err)
+	}
+	if _, ok := pet["tag"].(string); !ok || pet["age"].(float64) <= 1 {
+		t.Errorf("handler returned unexpected generated object: got %v", rr.Body.String())
+	}
+
+	rr = performRequest(t, srv.catchAllHandler, http.MethodPut, "/v1/pets/7", nil)
+	if allow := rr.Header().Get("Allow"); rr.Code != http.StatusMethodNotAllowed || allow != "GET, HEAD, DELETE" {
+		t.Errorf("handler returned %v with Allow %q, want %v with %q", rr.Code, allow, http.StatusMethodNotAllowed, "GET, HEAD, DELETE")
+	}
+}
+
+func TestImportSwagger(t *testing.T) {
+	srv := NewServer()
+
+	spec := `{"swagger": "2.0", "basePath": "/api", "paths": {"/status": {"get": {"responses": {
+		"200": {"description": "ok", "examples": {"application/json": {"ok": true}}}
+	}}}}}`
+	if rr := performRequest(t, srv.openAPIImportHandler, http.MethodPost, "/upload/openapi", []byte(spec)); rr.Code != http.StatusOK {
+		t.Fatalf("import failed: %v", rr.Body.String())
+	}
+	rr := performRequest(t, srv.catchAllHandler, http.MethodGet, "/api/status", nil)
+	if got := strings.TrimSpace(rr.Body.String()); got != `{"ok":true}` {
+		t.Errorf("handler returned unexpected body: got %v", got)
+	}
+
+	for _, body := range []string{`{"paths": {}}`, `{"openapi": "3.1.0", "paths": {}}`, `not json`} {
+		rr := performRequest(t, srv.openAPIImportHandler, http.MethodPost, "/upload/openapi", []byte(body))
+		if status := rr.Code; status != http.StatusBadRequest {
+			t.Errorf("%s: handler returned wrong status code: got %v want %v", body, status, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestWithOpenAPIFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "petstore.json")
+	if err := os.WriteFile(path, []byte(petstoreSpec), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s, err := New(WithOpenAPIFile(path))
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+
+	rr := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v1/pets/mine", nil))
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	if _, err := New(WithOpenAPIFile(filepath.Join(t.TempDir(), "missing.json"))); err == nil {
+		t.Errorf("New accepted a missing OpenAPI document")
+	}
+}
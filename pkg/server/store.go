@@ -0,0 +1,298 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// errDuplicateID is returned when a record is created with an id that is
+// already in use.
+var errDuplicateID = errors.New("a record with this id already exists")
+
+// errInvalidID is returned when an explicit id does not match the type the
+// schema declares for it.
+var errInvalidID = errors.New("invalid id: expected integer")
+
+// recordStore holds the records created through the API for the current
+// schema, keyed by the string form of their id. It is safe for concurrent
+// use. Stored records are never modified in place: get hands out copies and
+// updates replace the whole record.
+type recordStore struct {
+	mu      sync.Mutex
+	records map[string]map[string]interface{}
+	// order lists the keys of records in insertion order, so listings are
+	// stable. Replacing a record keeps its position.
+	order []string
+	// deleted holds the keys of records removed through delete, so reads
+	// can tell a deleted record from one that was never created. Storing
+	// under the key again clears it.
+	deleted map[string]bool
+	nextID  int
+	step    int
+	// idKey names the property records keep their id in, and strategy is
+	// how create assigns ids; rng draws the random ones.
+	idKey    string
+	strategy string
+	rng      *rand.Rand
+	// createdKey and updatedKey name the properties the store keeps
+	// creation and modification times in, or are "" when it does not.
+	createdKey string
+	updatedKey string
+}
+
+// newRecordStore returns an empty store whose first auto-assigned id is 1.
+func newRecordStore() *recordStore {
+	return newSequencedStore(1, 1)
+}
+
+// newSequencedStore returns an empty store whose auto-assigned ids begin at
+// start and increase by step.
+func newSequencedStore(start, step int) *recordStore {
+	return &recordStore{
+		records:  make(map[string]map[string]interface{}),
+		deleted:  make(map[string]bool),
+		nextID:   start,
+		step:     step,
+		idKey:    "id",
+		strategy: idIncrement,
+	}
+}
+
+// newStoreForSchema returns an empty store that assigns ids as the schema's
+// x-id-property and x-id-strategy say, numbered according to its
+// x-id-start and x-id-step, falling back to idStart and idStep. Random ids
+// repeat across runs when a seed is set.
+func (s *Server) newStoreForSchema(schema *Schema) *recordStore {
+	start, step := s.idStart, s.idStep
+	if schema.IDStart != nil {
+		start = *schema.IDStart
+	}
+	if schema.IDStep != nil {
+		step = *schema.IDStep
+	}
+	store := newSequencedStore(start, step)
+	store.idKey, store.strategy = schema.idKey(), schema.idStrategy()
+	source := time.Now().UnixNano()
+	if s.seeded {
+		source = s.seed
+	}
+	store.rng = rand.New(rand.NewSource(source))
+	store.createdKey, store.updatedKey = timestampKeys(schema)
+	return store
+}
+
+// create stores obj under its explicit id if it has one, otherwise under a
+// new id: the next auto-assigned one, or a random one for the UUID, ULID
+// and nanoid strategies. When integerIDs is set, explicit ids must be whole
+// numbers; either way the counter is advanced one step past numeric
+// explicit ids so later auto-assignments never collide. Other strategies
+// check explicit ids against their format.
+func (s *recordStore) create(obj map[string]interface{}, integerIDs bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stamp(obj, nil)
+
+	explicit, ok := obj[s.idKey]
+	if !ok || explicit == nil {
+		if s.strategy != idIncrement {
+			key := newRecordID(s.strategy, s.rng, time.Now())
+			obj[s.idKey] = key
+			s.insert(key, obj)
+			return nil
+		}
+		// Records stored under keys the counter has not produced, such
+		// as ones PUT into place, are stepped over rather than replaced.
+		id := s.nextID
+		for s.records[fmt.Sprint(id)] != nil {
+			id += s.step
+		}
+		s.nextID = id + s.step
+		if integerIDs {
+			obj[s.idKey] = id
+		} else {
+			obj[s.idKey] = fmt.Sprint(id)
+		}
+		s.insert(fmt.Sprint(obj[s.idKey]), obj)
+		return nil
+	}
+
+	if integerIDs {
+		n, ok := explicit.(float64)
+		if !ok || n != math.Trunc(n) {
+			return errInvalidID
+		}
+		id := int(n)
+		if _, exists := s.records[fmt.Sprint(id)]; exists {
+			return errDuplicateID
+		}
+		obj[s.idKey] = id
+		s.insert(fmt.Sprint(id), obj)
+		if id >= s.nextID {
+			s.nextID = id + s.step
+		}
+		return nil
+	}
+
+	key := fmt.Sprint(explicit)
+	if err := checkRecordID(s.strategy, key); err != nil {
+		return err
+	}
+	if _, exists := s.records[key]; exists {
+		return errDuplicateID
+	}
+	obj[s.idKey] = key
+	s.insert(key, obj)
+	if id, err := strconv.Atoi(key); err == nil && s.strategy == idIncrement && id >= s.nextID {
+		s.nextID = id + s.step
+	}
+	return nil
+}
+
+// insert stores obj under key, appending key to the order if it is new.
+// The caller must hold s.mu.
+func (s *recordStore) insert(key string, obj map[string]interface{}) {
+	if _, exists := s.records[key]; !exists {
+		s.order = append(s.order, key)
+	}
+	s.records[key] = obj
+	delete(s.deleted, key)
+}
+
+// get returns a copy of the record stored under id, if any.
+func (s *recordStore) get(id string) (map[string]interface{}, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	obj, ok := s.records[id]
+	if !ok {
+		return nil, false
+	}
+	return copyRecord(obj), true
+}
+
+// delete removes the record stored under id, reporting whether it existed.
+func (s *recordStore) delete(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.records[id]; !ok {
+		return false
+	}
+	delete(s.records, id)
+	s.deleted[id] = true
+	for i, key := range s.order {
+		if key == id {
+			s.order = append(s.order[:i], s.order[i+1:]...)
+			break
+		}
+	}
+	return true
+}
+
+// wasDeleted reports whether the record under id was deleted and not
+// stored again since.
+func (s *recordStore) wasDeleted(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.deleted[id]
+}
+
+// pristine reports whether nothing has been stored in or deleted from the
+// store yet.
+func (s *recordStore) pristine() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.records) == 0 && len(s.deleted) == 0
+}
+
+// list returns copies of every stored record in insertion order.
+func (s *recordStore) list() []map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := make([]map[string]interface{}, 0, len(s.order))
+	for _, key := range s.order {
+		list = append(list, copyRecord(s.records[key]))
+	}
+	return list
+}
+
+// len returns the number of stored records.
+func (s *recordStore) len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.records)
+}
+
+// copyRecord returns a shallow copy of a record so callers can change its
+// fields without affecting the stored version.
+func copyRecord(obj map[string]interface{}) map[string]interface{} {
+	dup := make(map[string]interface{}, len(obj))
+	for key, value := range obj {
+		dup[key] = value
+	}
+	return dup
+}
+
+// put stores obj under id, replacing any existing record. Integer ids keep
+// the counter ahead of them, as with explicit ids on create.
+func (s *recordStore) put(id interface{}, obj map[string]interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stamp(obj, s.records[fmt.Sprint(id)])
+	s.insert(fmt.Sprint(id), obj)
+	if n, ok := id.(int); ok && n >= s.nextID {
+		s.nextID = n + s.step
+	}
+}
+
+// update applies change to a copy of the record stored under id and stores
+// the result, all under the store's lock so concurrent updates of the same
+// record cannot interleave. It reports whether the record existed; an error
+// from change leaves the stored record untouched.
+func (s *recordStore) update(id string, change func(obj map[string]interface{}) error) (map[string]interface{}, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored, ok := s.records[id]
+	if !ok {
+		return nil, false, nil
+	}
+	obj := copyRecord(stored)
+	if err := change(obj); err != nil {
+		return nil, true, err
+	}
+	s.stamp(obj, stored)
+	s.records[id] = obj
+	return copyRecord(obj), true, nil
+}
+
+// stamp sets the timestamps of obj, which replaces prior, or is new when
+// prior is nil: the creation time carries over from prior and the
+// modification time is now, both in RFC 3339. The caller must hold s.mu.
+func (s *recordStore) stamp(obj, prior map[string]interface{}) {
+	now := time.Now().UTC().Format(time.RFC3339)
+	if s.createdKey != "" {
+		obj[s.createdKey] = now
+		if created, ok := prior[s.createdKey]; ok {
+			obj[s.createdKey] = created
+		}
+	}
+	if s.updatedKey != "" {
+		obj[s.updatedKey] = now
+	}
+}
+
+// timestampKeys returns the properties of schema whose values the store
+// manages: createdAt and updatedAt, when the schema declares them as
+// strings. x-timestamps declares them; see addTimestamps.
+func timestampKeys(schema *Schema) (created, updated string) {
+	managed := func(name string) string {
+		if prop, ok := schema.Properties[name]; ok && prop.Type == "string" {
+			return name
+		}
+		return ""
+	}
+	return managed("createdAt"), managed("updatedAt")
+}
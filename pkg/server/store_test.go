@@ -0,0 +1,184 @@
+package server
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecordStoreCreate(t *testing.T) {
+	t.Run("Auto-assigned IDs", func(t *testing.T) {
+		s := newRecordStore()
+		for want := 1; want <= 3; want++ {
+			obj := map[string]interface{}{}
+			if err := s.create(obj, true); err != nil {
+				t.Fatalf("create returned error: %v", err)
+			}
+			if obj["id"] != want {
+				t.Errorf("create assigned wrong id: got %v want %v", obj["id"], want)
+			}
+		}
+	})
+
+	t.Run("Explicit ID Advances Counter", func(t *testing.T) {
+		s := newRecordStore()
+		if err := s.create(map[string]interface{}{"id": float64(10)}, true); err != nil {
+			t.Fatalf("create returned error: %v", err)
+		}
+		// A lower explicit id must not move the counter backwards.
+		if err := s.create(map[string]interface{}{"id": float64(4)}, true); err != nil {
+			t.Fatalf("create returned error: %v", err)
+		}
+		obj := map[string]interface{}{}
+		if err := s.create(obj, true); err != nil {
+			t.Fatalf("create returned error: %v", err)
+		}
+		if obj["id"] != 11 {
+			t.Errorf("create assigned wrong id after explicit ids: got %v want %v", obj["id"], 11)
+		}
+	})
+
+	t.Run("Duplicate ID", func(t *testing.T) {
+		s := newRecordStore()
+		s.create(map[string]interface{}{"id": float64(2)}, true)
+		if err := s.create(map[string]interface{}{"id": float64(2)}, true); err != errDuplicateID {
+			t.Errorf("create returned wrong error: got %v want %v", err, errDuplicateID)
+		}
+	})
+
+	t.Run("Non-integer ID", func(t *testing.T) {
+		s := newRecordStore()
+		if err := s.create(map[string]interface{}{"id": "abc"}, true); err != errInvalidID {
+			t.Errorf("create returned wrong error: got %v want %v", err, errInvalidID)
+		}
+		if err := s.create(map[string]interface{}{"id": 1.5}, true); err != errInvalidID {
+			t.Errorf("create returned wrong error: got %v want %v", err, errInvalidID)
+		}
+	})
+
+	t.Run("String IDs", func(t *testing.T) {
+		s := newRecordStore()
+		if err := s.create(map[string]interface{}{"id": "abc"}, false); err != nil {
+			t.Fatalf("create returned error: %v", err)
+		}
+		if _, ok := s.get("abc"); !ok {
+			t.Errorf("record with string id was not stored")
+		}
+	})
+
+	t.Run("String IDs Advance Counter", func(t *testing.T) {
+		s := newRecordStore()
+		if err := s.create(map[string]interface{}{"id": "1", "name": "explicit"}, false); err != nil {
+			t.Fatalf("create returned error: %v", err)
+		}
+		obj := map[string]interface{}{}
+		if err := s.create(obj, false); err != nil {
+			t.Fatalf("create returned error: %v", err)
+		}
+		if obj["id"] != "2" {
+			t.Errorf("create assigned wrong id after explicit string id: got %v want %v", obj["id"], "2")
+		}
+		if kept, _ := s.get("1"); kept["name"] != "explicit" {
+			t.Errorf("auto-assigned id overwrote record 1: got %v", kept)
+		}
+	})
+
+	t.Run("Taken IDs Are Skipped", func(t *testing.T) {
+		s := newRecordStore()
+		s.put("1", map[string]interface{}{"id": "1"})
+		obj := map[string]interface{}{}
+		if err := s.create(obj, false); err != nil {
+			t.Fatalf("create returned error: %v", err)
+		}
+		if obj["id"] != "2" {
+			t.Errorf("create reused a taken id: got %v want %v", obj["id"], "2")
+		}
+	})
+}
+
+func TestRecordStoreSequence(t *testing.T) {
+	srv := NewServer()
+	start, step := 1000, 10
+	s := srv.newStoreForSchema(&Schema{IDStart: &start, IDStep: &step})
+	for _, want := range []int{1000, 1010} {
+		obj := map[string]interface{}{}
+		s.create(obj, true)
+		if obj["id"] != want {
+			t.Errorf("create assigned wrong id: got %v want %v", obj["id"], want)
+		}
+	}
+
+	// Explicit ids move the counter one step past them.
+	s.create(map[string]interface{}{"id": float64(1500)}, true)
+	obj := map[string]interface{}{}
+	s.create(obj, true)
+	if obj["id"] != 1510 {
+		t.Errorf("create assigned wrong id after explicit id: got %v want %v", obj["id"], 1510)
+	}
+
+	// Schemas without overrides use the flag defaults.
+	srv.idStart, srv.idStep = 5, 5
+	s = srv.newStoreForSchema(&Schema{})
+	obj = map[string]interface{}{}
+	s.create(obj, true)
+	if obj["id"] != 5 || s.nextID != 10 {
+		t.Errorf("create did not use flag defaults: got id %v next %v", obj["id"], s.nextID)
+	}
+}
+
+func TestRecordStoreListOrder(t *testing.T) {
+	s := newRecordStore()
+	for _, id := range []string{"zeta", "alpha", "mid", "beta"} {
+		if err := s.create(map[string]interface{}{"id": id}, false); err != nil {
+			t.Fatalf("create returned error: %v", err)
+		}
+	}
+	s.delete("mid")
+	s.put("alpha", map[string]interface{}{"id": "alpha", "name": "replaced"})
+	s.put("omega", map[string]interface{}{"id": "omega"})
+
+	var got []string
+	for _, obj := range s.list() {
+		got = append(got, obj["id"].(string))
+	}
+	want := []string{"zeta", "alpha", "beta", "omega"}
+	if len(got) != len(want) {
+		t.Fatalf("list returned wrong records: got %v want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("list returned wrong order: got %v want %v", got, want)
+			break
+		}
+	}
+}
+
+func TestRecordStoreTimestamps(t *testing.T) {
+	srv := NewServer()
+	if _, err := srv.loadSchema(strings.NewReader(`{"title": "Note", "type": "object", "x-timestamps": true, "properties": {"id": {"type": "integer"}, "text": {"type": "string"}}}`)); err != nil {
+		t.Fatalf("could not load schema: %v", err)
+	}
+	s := srv.stores["note"]
+	obj := map[string]interface{}{"text": "a", "createdAt": "1999-01-01T00:00:00Z"}
+	if err := s.create(obj, true); err != nil {
+		t.Fatalf("create returned error: %v", err)
+	}
+	created, _ := obj["createdAt"].(string)
+	if _, err := time.Parse(time.RFC3339, created); err != nil || created == "1999-01-01T00:00:00Z" || obj["updatedAt"] != created {
+		t.Errorf("create did not stamp the record: got %v", obj)
+	}
+
+	s.put(1, map[string]interface{}{"id": 1, "text": "b"})
+	updated, _, _ := s.update("1", func(obj map[string]interface{}) error {
+		obj["createdAt"] = "changed"
+		return nil
+	})
+	if updated["createdAt"] != created || updated["updatedAt"] == nil {
+		t.Errorf("update did not keep the creation time: got %v", updated)
+	}
+
+	// Undeclared or non-string properties are left alone.
+	if created, updated := timestampKeys(&Schema{Properties: map[string]Property{"createdAt": {Type: "integer"}}}); created != "" || updated != "" {
+		t.Errorf("timestampKeys = %q, %q, want none", created, updated)
+	}
+}
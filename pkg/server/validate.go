@@ -0,0 +1,306 @@
+package server
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// fieldError describes a single validation failure. Pointer is the RFC 6901
+// JSON Pointer of the failing value within the request body and Field is
+// the name of the property it belongs to.
+type fieldError struct {
+	Field   string `json:"field"`
+	Pointer string `json:"pointer"`
+	Message string `json:"message"`
+}
+
+// escapePointerToken escapes a reference token for use in a JSON Pointer.
+func escapePointerToken(token string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(token)
+}
+
+// validateRequired reports each name in required that body lacks.
+// Missing fields are reported at the pointer they would have.
+func validateRequired(required []string, body map[string]interface{}, pointer string) []fieldError {
+	var errs []fieldError
+	for _, name := range required {
+		if _, ok := body[name]; !ok {
+			errs = append(errs, fieldError{Field: name, Pointer: pointer + "/" + escapePointerToken(name), Message: "required property is missing"})
+		}
+	}
+	return errs
+}
+
+// validateObject checks the fields of body against properties. Fields that
+// the schema does not declare are ignored.
+func validateObject(properties map[string]Property, body map[string]interface{}, pointer string) []fieldError {
+	keys := make([]string, 0, len(body))
+	for key := range body {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var errs []fieldError
+	for _, key := range keys {
+		prop, ok := properties[key]
+		if !ok {
+			continue
+		}
+		errs = append(errs, validateValue(key, prop, body[key], pointer+"/"+escapePointerToken(key))...)
+	}
+	return errs
+}
+
+// validateValue checks a single value against its property definition,
+// recursing into objects and arrays. A value must have the declared type,
+// or be null where the property is nullable; when it does, enum, const,
+// format, the numeric, string, object and array constraints and the
+// combinators are all checked, so a value is only valid if it satisfies
+// every one. Object and array keywords of an untyped property apply to
+// values of those types.
+func validateValue(field string, prop Property, value interface{}, pointer string) []fieldError {
+	if value == nil && prop.Nullable {
+		return nil
+	}
+	failure := func(format string, args ...interface{}) fieldError {
+		return fieldError{Field: field, Pointer: pointer, Message: fmt.Sprintf(format, args...)}
+	}
+	fail := func(format string, args ...interface{}) []fieldError {
+		return []fieldError{failure(format, args...)}
+	}
+
+	var errs []fieldError
+	switch prop.Type {
+	case "string":
+		s, ok := value.(string)
+		if !ok {
+			return fail("expected string, got %s", jsonTypeName(value))
+		}
+		if err := checkStringFormat(prop.Format, s); err != nil {
+			errs = append(errs, failure("%v", err))
+		}
+		for _, problem := range checkString(prop, s) {
+			errs = append(errs, failure("%s", problem))
+		}
+	case "integer":
+		n, ok := value.(float64)
+		if !ok || n != math.Trunc(n) {
+			return fail("expected integer, got %s", jsonTypeName(value))
+		}
+		if prop.Format == "int32" && (n < math.MinInt32 || n > math.MaxInt32) {
+			errs = append(errs, failure("value %s is out of range for int32", strconv.FormatFloat(n, 'f', -1, 64)))
+		}
+		if prop.Format == "int64" && (n < math.MinInt64 || n >= math.MaxInt64) {
+			errs = append(errs, failure("value %s is out of range for int64", strconv.FormatFloat(n, 'f', -1, 64)))
+		}
+		for _, problem := range checkNumber(prop, n) {
+			errs = append(errs, failure("%s", problem))
+		}
+	case "number":
+		n, ok := value.(float64)
+		if !ok {
+			return fail("expected number, got %s", jsonTypeName(value))
+		}
+		if prop.Format == "float" && math.Abs(n) > math.MaxFloat32 {
+			errs = append(errs, failure("value %s is out of range for float", strconv.FormatFloat(n, 'g', -1, 64)))
+		}
+		for _, problem := range checkNumber(prop, n) {
+			errs = append(errs, failure("%s", problem))
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return fail("expected boolean, got %s", jsonTypeName(value))
+		}
+	case "object":
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return fail("expected object, got %s", jsonTypeName(value))
+		}
+		errs = validateObjectValue(field, prop, obj, pointer)
+	case "array":
+		list, ok := value.([]interface{})
+		if !ok {
+			return fail("expected array, got %s", jsonTypeName(value))
+		}
+		if prop.MinItems != nil && len(list) < *prop.MinItems {
+			errs = append(errs, failure("expected at least %d items, got %d", *prop.MinItems, len(list)))
+		}
+		if prop.MaxItems != nil && len(list) > *prop.MaxItems {
+			errs = append(errs, failure("expected at most %d items, got %d", *prop.MaxItems, len(list)))
+		}
+		// Tuple positions are checked against their own schema and any
+		// later elements against Items. Like JSON Schema, a tuple may be
+		// shorter than its prefix.
+		for i, item := range list {
+			itemPointer := pointer + "/" + strconv.Itoa(i)
+			if i < len(prop.PrefixItems) {
+				errs = append(errs, validateValue(field, prop.PrefixItems[i], item, itemPointer)...)
+			} else if prop.Items != nil {
+				errs = append(errs, validateValue(field, *prop.Items, item, itemPointer)...)
+			}
+		}
+		errs = append(errs, validateArrayKeywords(field, prop, list, pointer)...)
+	case "":
+		switch v := value.(type) {
+		case map[string]interface{}:
+			errs = validateObjectValue(field, prop, v, pointer)
+		case []interface{}:
+			errs = validateArrayKeywords(field, prop, v, pointer)
+		}
+	}
+
+	if len(prop.Enum) > 0 && !inEnum(prop.Enum, value) {
+		enum, _ := json.Marshal(prop.Enum)
+		errs = append([]fieldError{failure("value must be one of %s", enum)}, errs...)
+	}
+	if prop.Const != nil && !reflect.DeepEqual(prop.Const, value) {
+		constant, _ := json.Marshal(prop.Const)
+		errs = append([]fieldError{failure("value must be %s", constant)}, errs...)
+	}
+	return append(errs, validateCombinators(field, prop, value, pointer)...)
+}
+
+// inEnum reports whether value equals one of the enum values.
+func inEnum(enum []interface{}, value interface{}) bool {
+	for _, allowed := range enum {
+		if reflect.DeepEqual(allowed, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// uuidPattern matches the canonical textual form of a UUID.
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// checkStringFormat checks a string against a JSON Schema format.
+// Unrecognized formats are accepted, as the specification allows.
+func checkStringFormat(format, s string) error {
+	switch format {
+	case "byte", "binary":
+		// Binary content travels as base64 in JSON.
+		if _, err := base64.StdEncoding.DecodeString(s); err != nil {
+			return fmt.Errorf("expected base64-encoded content for format %q", format)
+		}
+	case "email":
+		if addr, err := mail.ParseAddress(s); err != nil || addr.Address != s {
+			return fmt.Errorf("expected an email address")
+		}
+	case "uuid":
+		if !uuidPattern.MatchString(s) {
+			return fmt.Errorf("expected a UUID")
+		}
+	case "date-time":
+		if _, err := time.Parse(time.RFC3339, s); err != nil {
+			return fmt.Errorf("expected an RFC 3339 date-time")
+		}
+	case "date":
+		if _, err := time.Parse("2006-01-02", s); err != nil {
+			return fmt.Errorf("expected a date in YYYY-MM-DD form")
+		}
+	case "uri":
+		if u, err := url.Parse(s); err != nil || u.Scheme == "" {
+			return fmt.Errorf("expected an absolute URI")
+		}
+	case "ipv4":
+		if ip := net.ParseIP(s); ip == nil || ip.To4() == nil || strings.Contains(s, ":") {
+			return fmt.Errorf("expected an IPv4 address")
+		}
+	case "ipv6":
+		if ip := net.ParseIP(s); ip == nil || !strings.Contains(s, ":") {
+			return fmt.Errorf("expected an IPv6 address")
+		}
+	}
+	return nil
+}
+
+// asJSON returns value as encoding/json would decode it, with numbers as
+// float64, which is the form validation expects. Stored and generated
+// records may hold ints.
+func asJSON(value interface{}) interface{} {
+	encoded, _ := json.Marshal(value)
+	var decoded interface{}
+	json.Unmarshal(encoded, &decoded)
+	return decoded
+}
+
+// jsonTypeName names the JSON type of a decoded value for error messages.
+func jsonTypeName(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case float64:
+		if v == math.Trunc(v) {
+			return "integer"
+		}
+		return "number"
+	case bool:
+		return "boolean"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+// Validation modes control what happens to request bodies that do not
+// match the schema.
+const (
+	// validateReject answers 400 with the field errors.
+	validateReject = "reject"
+	// validateWarn accepts the body as-is and reports the field errors in
+	// the X-Validation-Warnings header.
+	validateWarn = "warn"
+)
+
+// validationModes lists the valid validation modes.
+var validationModes = []string{validateReject, validateWarn}
+
+// validationWarningsHeader carries the JSON array of field errors for
+// bodies accepted in warn mode.
+const validationWarningsHeader = "X-Validation-Warnings"
+
+// checkBody validates body against the schema, as a whole record or field
+// by field, and checks that it contains every field in required,
+// reporting whether the request may proceed as reportFieldErrors does.
+func (s *Server) checkBody(w http.ResponseWriter, schema *Schema, required []string, body map[string]interface{}, whole bool) bool {
+	return s.reportFieldErrors(w, validateRecord(schema, required, body, "", whole))
+}
+
+// reportFieldErrors responds to validation failures as the validation mode
+// says, reporting whether the request may go ahead: in warn mode the
+// errors go in the X-Validation-Warnings header, otherwise they are
+// written as a 400 response.
+func (s *Server) reportFieldErrors(w http.ResponseWriter, errs []fieldError) bool {
+	if len(errs) == 0 {
+		return true
+	}
+	if s.validationMode == validateWarn {
+		warnings, _ := json.Marshal(errs)
+		w.Header().Set(validationWarningsHeader, string(warnings))
+		return true
+	}
+	writeValidationErrors(w, errs)
+	return false
+}
+
+// writeValidationErrors responds with 400 and the list of field errors.
+func writeValidationErrors(w http.ResponseWriter, errs []fieldError) {
+	writeErrorDetails(w, http.StatusBadRequest, "validation_failed", "Validation failed", errs)
+}
@@ -0,0 +1,241 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestValidateObjectPointers(t *testing.T) {
+	properties := map[string]Property{
+		"name": {Type: "string"},
+		"address": {Type: "object", Properties: map[string]Property{
+			"zip": {Type: "string"},
+		}},
+		"tags":  {Type: "array", Items: &Property{Type: "string"}},
+		"a/b~c": {Type: "integer"},
+	}
+	body := map[string]interface{}{
+		"name":    "ok",
+		"address": map[string]interface{}{"zip": 12345.0},
+		"tags":    []interface{}{"x", true},
+		"a/b~c":   1.5,
+		"extra":   "ignored",
+	}
+
+	errs := validateObject(properties, body, "")
+	want := map[string]string{
+		"/address/zip": "zip",
+		"/tags/1":      "tags",
+		"/a~1b~0c":     "a/b~c",
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("validateObject returned wrong number of errors: got %v want %v", errs, len(want))
+	}
+	for _, e := range errs {
+		field, ok := want[e.Pointer]
+		if !ok {
+			t.Errorf("unexpected error pointer: %v", e.Pointer)
+			continue
+		}
+		if e.Field != field {
+			t.Errorf("error at %v has wrong field: got %v want %v", e.Pointer, e.Field, field)
+		}
+	}
+}
+
+func TestPostValidationResponse(t *testing.T) {
+	srv := NewServer()
+	srv.currentSchema = createSampleSchema()
+	srv.currentSchema.Properties["address"] = Property{Type: "object", Properties: map[string]Property{
+		"zip": {Type: "string"},
+	}}
+	srv.store = newRecordStore()
+
+	rr := performRequest(t, srv.catchAllHandler, http.MethodPost, "/users", []byte(`{"name":"x","email":"x@example.com","address":{"zip":1}}`))
+	if status := rr.Code; status != http.StatusBadRequest {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
+	}
+	var body struct {
+		Code    string       `json:"code"`
+		Details []fieldError `json:"details"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("could not decode response: %v", err)
+	}
+	if body.Code != "validation_failed" || len(body.Details) != 1 || body.Details[0].Pointer != "/address/zip" {
+		t.Errorf("handler returned unexpected errors: got %+v", body)
+	}
+}
+
+func TestValidateWarnMode(t *testing.T) {
+	srv := NewServer()
+	srv.currentSchema = createSampleSchema()
+	srv.store = newRecordStore()
+	srv.validationMode = validateWarn
+
+	rr := performRequest(t, srv.catchAllHandler, http.MethodPost, "/users", []byte(`{"id":4,"name":42,"email":"e"}`))
+	if status := rr.Code; status != http.StatusCreated {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusCreated)
+	}
+	var warnings []fieldError
+	if err := json.Unmarshal([]byte(rr.Header().Get(validationWarningsHeader)), &warnings); err != nil {
+		t.Fatalf("could not decode %s header: %v", validationWarningsHeader, err)
+	}
+	if len(warnings) != 1 || warnings[0].Pointer != "/name" {
+		t.Errorf("handler returned unexpected warnings: got %+v", warnings)
+	}
+	if stored, ok := srv.store.get("4"); !ok || stored["name"] != 42.0 {
+		t.Errorf("nonconforming body was not stored: got %v", stored)
+	}
+
+	rr = performRequest(t, srv.catchAllHandler, http.MethodPut, "/users/4", []byte(`{"name":"fixed","email":"f@example.com"}`))
+	if warning := rr.Header().Get(validationWarningsHeader); warning != "" {
+		t.Errorf("valid body produced warnings: %v", warning)
+	}
+}
+
+func TestValidateNumberFormats(t *testing.T) {
+	t.Setenv("LC_ALL", "de_DE.UTF-8")
+
+	properties := map[string]Property{
+		"count": {Type: "integer", Format: "int32"},
+		"ratio": {Type: "number", Format: "float"},
+	}
+	var body map[string]interface{}
+	if err := json.Unmarshal([]byte(`{"count":2147483647,"ratio":0.5}`), &body); err != nil {
+		t.Fatalf("could not decode body: %v", err)
+	}
+	if errs := validateObject(properties, body, ""); len(errs) != 0 {
+		t.Errorf("validateObject rejected valid numbers: %v", errs)
+	}
+
+	body = map[string]interface{}{"count": 2147483648.0, "ratio": 1e39}
+	if errs := validateObject(properties, body, ""); len(errs) != 2 {
+		t.Errorf("validateObject returned wrong number of errors: got %v want %v", errs, 2)
+	}
+
+	// A comma decimal separator is not JSON and must never be accepted.
+	if err := json.Unmarshal([]byte(`{"ratio":0,5}`), &body); err == nil {
+		t.Errorf("comma decimal separator was accepted")
+	}
+}
+
+func TestValidateBase64(t *testing.T) {
+	properties := map[string]Property{"blob": {Type: "string", Format: "byte"}}
+	if errs := validateObject(properties, map[string]interface{}{"blob": "aGVsbG8="}, ""); len(errs) != 0 {
+		t.Errorf("validateObject rejected valid base64: %v", errs)
+	}
+	if errs := validateObject(properties, map[string]interface{}{"blob": "not base64!"}, ""); len(errs) != 1 {
+		t.Errorf("validateObject accepted invalid base64")
+	}
+}
+
+func TestValidateEnumAndFormat(t *testing.T) {
+	prop := Property{Type: "string", Format: "email", Enum: []interface{}{"admin@example.com", "ops@example.com", "not-an-email"}}
+	properties := map[string]Property{"contact": prop}
+
+	tests := []struct {
+		value      interface{}
+		wantErrors int
+	}{
+		{"admin@example.com", 0},
+		{"someone@example.com", 1}, // valid email, not in enum
+		{"not-an-email", 1},        // in enum, invalid email
+		{"garbage", 2},             // neither
+		{42.0, 1},                  // wrong type is reported alone
+	}
+	for _, tt := range tests {
+		errs := validateObject(properties, map[string]interface{}{"contact": tt.value}, "")
+		if len(errs) != tt.wantErrors {
+			t.Errorf("value %v: got errors %v, want %d", tt.value, errs, tt.wantErrors)
+		}
+	}
+}
+
+func TestCheckStringFormat(t *testing.T) {
+	tests := []struct {
+		format, value string
+		valid         bool
+	}{
+		{"email", "a@b.co", true},
+		{"email", "Alice <a@b.co>", false},
+		{"uuid", "123e4567-e89b-12d3-a456-426614174000", true},
+		{"uuid", "123e4567", false},
+		{"date-time", "2024-01-02T03:04:05Z", true},
+		{"date-time", "2024-01-02", false},
+		{"date", "2024-01-02", true},
+		{"uri", "https://example.com/x", true},
+		{"uri", "example.com", false},
+		{"ipv4", "192.168.0.1", true},
+		{"ipv4", "::1", false},
+		{"ipv6", "::1", true},
+		{"unknown-format", "anything", true},
+	}
+	for _, tt := range tests {
+		if err := checkStringFormat(tt.format, tt.value); (err == nil) != tt.valid {
+			t.Errorf("checkStringFormat(%q, %q) = %v, want valid %v", tt.format, tt.value, err, tt.valid)
+		}
+	}
+}
+
+func TestValidateTuple(t *testing.T) {
+	prop := Property{Type: "array", PrefixItems: []Property{{Type: "number"}, {Type: "number"}}, Items: &Property{Type: "string"}}
+	properties := map[string]Property{"point": prop}
+
+	if errs := validateObject(properties, map[string]interface{}{"point": []interface{}{1.5, 2.0, "label"}}, ""); len(errs) != 0 {
+		t.Errorf("valid tuple was rejected: %v", errs)
+	}
+	errs := validateObject(properties, map[string]interface{}{"point": []interface{}{"north", 2.0, 3.0}}, "")
+	if len(errs) != 2 || errs[0].Pointer != "/point/0" || errs[1].Pointer != "/point/2" {
+		t.Errorf("tuple errors have wrong pointers: %v", errs)
+	}
+}
+
+func TestValidateArrayBounds(t *testing.T) {
+	one, two := 1, 2
+	properties := map[string]Property{"tags": {Type: "array", Items: &Property{Type: "string"}, MinItems: &one, MaxItems: &two}}
+	for _, tt := range []struct {
+		tags []interface{}
+		ok   bool
+	}{
+		{[]interface{}{}, false},
+		{[]interface{}{"a"}, true},
+		{[]interface{}{"a", "b", "c"}, false},
+	} {
+		errs := validateObject(properties, map[string]interface{}{"tags": tt.tags}, "")
+		if (len(errs) == 0) != tt.ok {
+			t.Errorf("validateObject(%v) returned %v", tt.tags, errs)
+		}
+	}
+}
+
+func TestValidateRequired(t *testing.T) {
+	srv := NewServer()
+	srv.currentSchema = createSampleSchema()
+	srv.currentSchema.Properties["address"] = Property{Type: "object", Required: []string{"city"}, Properties: map[string]Property{
+		"city": {Type: "string"},
+	}}
+	srv.store = newRecordStore()
+
+	rr := performRequest(t, srv.catchAllHandler, http.MethodPost, "/users", []byte(`{"email":"a@example.com","address":{}}`))
+	if status := rr.Code; status != http.StatusBadRequest {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
+	}
+	var body struct {
+		Details []fieldError `json:"details"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("could not decode response: %v", err)
+	}
+	// The id is required too, but the store assigns it.
+	if len(body.Details) != 2 || body.Details[0].Pointer != "/name" || body.Details[1].Pointer != "/address/city" {
+		t.Errorf("handler returned unexpected errors: got %+v", body.Details)
+	}
+
+	// PUT replaces the whole record; only PATCH takes a partial body.
+	rr = performRequest(t, srv.catchAllHandler, http.MethodPut, "/users/1", []byte(`{"name":"partial"}`))
+	if status := rr.Code; status != http.StatusBadRequest {
+		t.Errorf("PUT with a partial body returned wrong status code: got %v want %v", status, http.StatusBadRequest)
+	}
+}
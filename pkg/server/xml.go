@@ -0,0 +1,95 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// xmlContentType is the media type of XML renderings.
+const xmlContentType = "application/xml"
+
+// marshalXML renders a JSON document as XML under a root element. Objects
+// become elements named after their keys, in sorted order, and array
+// elements are named item, or itemName for a top-level array. Numbers keep
+// their JSON spelling and null becomes an empty element.
+func marshalXML(data []byte, root, itemName string) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var generic interface{}
+	if err := decoder.Decode(&generic); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	writeXMLElement(&buf, xmlName(root), generic, xmlName(itemName))
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+// writeXMLElement writes v as an element called name. itemName names the
+// elements of an array value.
+func writeXMLElement(buf *bytes.Buffer, name string, v interface{}, itemName string) {
+	switch value := v.(type) {
+	case nil:
+		buf.WriteString("<" + name + "/>")
+	case map[string]interface{}:
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		buf.WriteString("<" + name + ">")
+		for _, key := range keys {
+			writeXMLElement(buf, xmlName(key), value[key], "item")
+		}
+		buf.WriteString("</" + name + ">")
+	case []interface{}:
+		buf.WriteString("<" + name + ">")
+		for _, item := range value {
+			writeXMLElement(buf, itemName, item, "item")
+		}
+		buf.WriteString("</" + name + ">")
+	default:
+		buf.WriteString("<" + name + ">")
+		xml.EscapeText(buf, []byte(xmlText(value)))
+		buf.WriteString("</" + name + ">")
+	}
+}
+
+// xmlText renders a JSON scalar as element text.
+func xmlText(v interface{}) string {
+	switch value := v.(type) {
+	case bool:
+		if value {
+			return "true"
+		}
+		return "false"
+	case json.Number:
+		return value.String()
+	case string:
+		return value
+	}
+	return ""
+}
+
+// xmlName turns a JSON key into a valid element name, replacing the
+// characters XML names cannot hold with underscores.
+func xmlName(key string) string {
+	name := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-' || r == '.' {
+			return r
+		}
+		return '_'
+	}, key)
+	first, _ := utf8.DecodeRuneInString(name)
+	if !(unicode.IsLetter(first) || first == '_') || strings.HasPrefix(strings.ToLower(name), "xml") {
+		name = "_" + name
+	}
+	return name
+}
@@ -0,0 +1,142 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// marshalYAML renders v as a YAML document. v is first converted through
+// its JSON form, so json struct tags apply and the output describes exactly
+// the same data as the JSON rendering. Map keys are emitted in sorted order.
+func marshalYAML(v interface{}) ([]byte, error) {
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.UseNumber()
+	var generic interface{}
+	if err := decoder.Decode(&generic); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	switch generic.(type) {
+	case map[string]interface{}, []interface{}:
+		writeYAMLBlock(&buf, generic, 0)
+	default:
+		buf.WriteString(yamlScalar(generic))
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
+
+// writeYAMLBlock writes a mapping or sequence in block style at the given
+// indentation. Empty collections are written in flow style by the caller.
+func writeYAMLBlock(buf *bytes.Buffer, v interface{}, indent int) {
+	pad := strings.Repeat("  ", indent)
+	switch value := v.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			buf.WriteString(pad + yamlString(key) + ":")
+			writeYAMLChild(buf, value[key], indent+1)
+		}
+	case []interface{}:
+		for _, item := range value {
+			if child, ok := item.(map[string]interface{}); ok && len(child) > 0 {
+				// Sequence entries that are mappings start on the dash line.
+				var nested bytes.Buffer
+				writeYAMLBlock(&nested, child, indent+1)
+				buf.WriteString(pad + "- " + strings.TrimPrefix(nested.String(), pad+"  "))
+				continue
+			}
+			buf.WriteString(pad + "-")
+			writeYAMLChild(buf, item, indent+1)
+		}
+	}
+}
+
+// writeYAMLChild writes the value following a "key:" or "-" marker.
+func writeYAMLChild(buf *bytes.Buffer, v interface{}, indent int) {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		if len(value) == 0 {
+			buf.WriteString(" {}\n")
+			return
+		}
+		buf.WriteByte('\n')
+		writeYAMLBlock(buf, value, indent)
+	case []interface{}:
+		if len(value) == 0 {
+			buf.WriteString(" []\n")
+			return
+		}
+		buf.WriteByte('\n')
+		writeYAMLBlock(buf, value, indent)
+	default:
+		buf.WriteString(" " + yamlScalar(value) + "\n")
+	}
+}
+
+// yamlScalar renders a JSON scalar.
+func yamlScalar(v interface{}) string {
+	switch value := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return strconv.FormatBool(value)
+	case json.Number:
+		return value.String()
+	case string:
+		return yamlString(value)
+	default:
+		return yamlString(fmt.Sprint(value))
+	}
+}
+
+// yamlString renders a string plainly when that is unambiguous and as a
+// double-quoted scalar otherwise. JSON string escaping is valid inside
+// YAML double quotes.
+func yamlString(s string) string {
+	if yamlNeedsQuotes(s) {
+		quoted, _ := json.Marshal(s)
+		return string(quoted)
+	}
+	return s
+}
+
+// yamlNeedsQuotes reports whether a plain scalar would change meaning or
+// fail to parse.
+func yamlNeedsQuotes(s string) bool {
+	if s == "" || strings.TrimSpace(s) != s {
+		return true
+	}
+	switch strings.ToLower(s) {
+	case "null", "~", "true", "false", "yes", "no", "on", "off", "y", "n":
+		return true
+	}
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		return true
+	}
+	if strings.ContainsAny(s[:1], "-?:,[]{}#&*!|>'\"%@`") {
+		return true
+	}
+	if strings.Contains(s, ": ") || strings.Contains(s, " #") || strings.HasSuffix(s, ":") {
+		return true
+	}
+	for _, r := range s {
+		if r < 0x20 || r == 0x7f {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,148 @@
+package server
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMarshalYAML(t *testing.T) {
+	doc := map[string]interface{}{
+		"openapi": "3.1.0",
+		"info":    map[string]interface{}{"title": "Mock API", "version": "1.0"},
+		"paths": map[string]interface{}{
+			"/users": map[string]interface{}{
+				"get": map[string]interface{}{
+					"tags":       []string{"users"},
+					"parameters": []interface{}{map[string]interface{}{"name": "id", "in": "path", "required": true}},
+				},
+			},
+		},
+		"empty":  map[string]interface{}{},
+		"none":   []string{},
+		"nil":    nil,
+		"count":  3,
+		"ratio":  0.5,
+		"quoted": []string{"", "true", "42", "a: b", "- dash", " padded", "line\nbreak", "plain text"},
+	}
+	got, err := marshalYAML(doc)
+	if err != nil {
+		t.Fatalf("marshalYAML returned error: %v", err)
+	}
+	want := `count: 3
+empty: {}
+info:
+  title: Mock API
+  version: "1.0"
+nil: null
+none: []
+openapi: 3.1.0
+paths:
+  /users:
+    get:
+      parameters:
+        - in: path
+          name: id
+          required: true
+      tags:
+        - users
+quoted:
+  - ""
+  - "true"
+  - "42"
+  - "a: b"
+  - "- dash"
+  - " padded"
+  - "line\nbreak"
+  - plain text
+ratio: 0.5
+`
+	if string(got) != want {
+		t.Errorf("marshalYAML output mismatch:\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestMarshalYAMLScalar(t *testing.T) {
+	got, err := marshalYAML("hello")
+	if err != nil || string(got) != "hello\n" {
+		t.Errorf("marshalYAML(scalar) = %q, %v", got, err)
+	}
+}
+
+func TestUnmarshalYAML(t *testing.T) {
+	doc := `---
+# scripted responses
+overrides:
+  - method: GET
+    path: /users/13   # missing
+    status: 404
+    body: {code: not_found, ids: [13, "14"], note: 'it''s gone'}
+  - method: POST
+    path: "/users"
+    responses:
+    - status: 422
+      times: 1
+    - status: 201
+      enabled: true
+      nothing: ~
+      message: |
+        line one
+        line two
+      folded: >-
+        a
+        b
+empty: []
+"quoted key": "a # b"
+`
+	got, err := unmarshalYAML([]byte(doc))
+	if err != nil {
+		t.Fatalf("unmarshalYAML returned error: %v", err)
+	}
+	want := map[string]interface{}{
+		"overrides": []interface{}{
+			map[string]interface{}{
+				"method": "GET",
+				"path":   "/users/13",
+				"status": 404.0,
+				"body":   map[string]interface{}{"code": "not_found", "ids": []interface{}{13.0, "14"}, "note": "it's gone"},
+			},
+			map[string]interface{}{
+				"method": "POST",
+				"path":   "/users",
+				"responses": []interface{}{
+					map[string]interface{}{"status": 422.0, "times": 1.0},
+					map[string]interface{}{"status": 201.0, "enabled": true, "nothing": nil, "message": "line one\nline two\n", "folded": "a b"},
+				},
+			},
+		},
+		"empty":      []interface{}{},
+		"quoted key": "a # b",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unmarshalYAML mismatch:\ngot:  %#v\nwant: %#v", got, want)
+	}
+
+	for _, bad := range []string{"a: 1\n  b: 2", "a: [1, 2", "a: 1\na: 2", "\ta: 1"} {
+		if _, err := unmarshalYAML([]byte(bad)); err == nil {
+			t.Errorf("unmarshalYAML(%q) returned no error", bad)
+		}
+	}
+}
+
+func TestYAMLRoundTrip(t *testing.T) {
+	doc := map[string]interface{}{
+		"list":   []interface{}{map[string]interface{}{"a": 1.0, "b": []interface{}{}}, "x"},
+		"quoted": []interface{}{"", "true", "42", "a: b", "- dash", "line\nbreak"},
+		"nil":    nil,
+	}
+	encoded, err := marshalYAML(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := unmarshalYAML(encoded)
+	if err != nil {
+		t.Fatalf("unmarshalYAML returned error: %v\n%s", err, encoded)
+	}
+	if !reflect.DeepEqual(got, doc) {
+		t.Errorf("round trip mismatch:\ngot:  %#v\nwant: %#v", got, doc)
+	}
+}
@@ -0,0 +1,475 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// yamlLine is one line of a YAML document.
+type yamlLine struct {
+	num    int
+	indent int
+	// text is the line without its indentation and comment; raw is the
+	// line as written, which block scalars keep.
+	text string
+	raw  string
+}
+
+// yamlParser reads a YAML document line by line.
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+// unmarshalYAML parses a YAML document into the values encoding/json
+// decodes the same document's JSON form to: maps, slices, strings,
+// float64s, bools and nils. It reads the subset configuration files use:
+// block mappings and sequences, plain and quoted scalars, | and > block
+// scalars, single-line flow collections and comments. Anchors, tags and
+// multiple documents are not supported.
+func unmarshalYAML(data []byte) (interface{}, error) {
+	p := &yamlParser{}
+	for i, raw := range strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n") {
+		trimmed := strings.TrimLeft(raw, " ")
+		if strings.HasPrefix(trimmed, "\t") && strings.TrimSpace(trimmed) != "" {
+			return nil, fmt.Errorf("line %d: tabs must not indent YAML", i+1)
+		}
+		text := strings.TrimSpace(stripYAMLComment(trimmed))
+		p.lines = append(p.lines, yamlLine{num: i + 1, indent: len(raw) - len(trimmed), text: text, raw: raw})
+	}
+	p.skip()
+	if p.pos < len(p.lines) && p.lines[p.pos].text == "---" {
+		p.pos++
+		p.skip()
+	}
+	if p.pos == len(p.lines) {
+		return nil, nil
+	}
+	value, err := p.node()
+	if err != nil {
+		return nil, err
+	}
+	p.skip()
+	if p.pos < len(p.lines) && p.lines[p.pos].text != "..." {
+		return nil, fmt.Errorf("line %d: unexpected %q", p.lines[p.pos].num, p.lines[p.pos].text)
+	}
+	return value, nil
+}
+
+// skip moves past blank and comment lines.
+func (p *yamlParser) skip() {
+	for p.pos < len(p.lines) && p.lines[p.pos].text == "" {
+		p.pos++
+	}
+}
+
+// node parses the value starting at the current line.
+func (p *yamlParser) node() (interface{}, error) {
+	line := p.lines[p.pos]
+	if isYAMLSequenceEntry(line.text) {
+		return p.sequence(line.indent)
+	}
+	if _, _, ok, err := splitYAMLKey(line.text); err != nil {
+		return nil, fmt.Errorf("line %d: %v", line.num, err)
+	} else if ok {
+		return p.mapping(line.indent)
+	}
+	p.pos++
+	return parseYAMLValue(line.text, line.num)
+}
+
+// child parses the value on the lines indented under a "key:" or "-"
+// line, or returns nil if there are none.
+func (p *yamlParser) child(indent int) (interface{}, error) {
+	p.skip()
+	if p.pos == len(p.lines) || p.lines[p.pos].indent <= indent {
+		return nil, nil
+	}
+	return p.node()
+}
+
+// sequence parses the "- " entries at indent.
+func (p *yamlParser) sequence(indent int) (interface{}, error) {
+	list := []interface{}{}
+	for p.skip(); p.pos < len(p.lines); p.skip() {
+		line := p.lines[p.pos]
+		if line.indent != indent || !isYAMLSequenceEntry(line.text) {
+			break
+		}
+		var item interface{}
+		var err error
+		if rest := strings.TrimLeft(line.text[1:], " "); rest == "" {
+			p.pos++
+			item, err = p.child(indent)
+		} else {
+			// The entry's value starts on the dash line; parse it as if it
+			// were a line of its own, indented to where it starts, so the
+			// keys of a mapping entry line up with their siblings.
+			offset := len(line.text) - len(rest)
+			p.lines[p.pos] = yamlLine{num: line.num, indent: indent + offset, text: rest, raw: line.raw}
+			item, err = p.node()
+		}
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, item)
+	}
+	return list, nil
+}
+
+// mapping parses the "key: value" entries at indent.
+func (p *yamlParser) mapping(indent int) (interface{}, error) {
+	obj := map[string]interface{}{}
+	for p.skip(); p.pos < len(p.lines); p.skip() {
+		line := p.lines[p.pos]
+		if line.indent < indent {
+			break
+		}
+		if line.indent > indent {
+			return nil, fmt.Errorf("line %d: unexpected indentation", line.num)
+		}
+		key, rest, ok, err := splitYAMLKey(line.text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line.num, err)
+		}
+		if !ok {
+			break
+		}
+		if _, dup := obj[key]; dup {
+			return nil, fmt.Errorf("line %d: duplicate key %q", line.num, key)
+		}
+		p.pos++
+		var value interface{}
+		switch {
+		case rest == "":
+			// A sequence may sit at the key's own indentation.
+			p.skip()
+			if p.pos < len(p.lines) && p.lines[p.pos].indent == indent && isYAMLSequenceEntry(p.lines[p.pos].text) {
+				value, err = p.sequence(indent)
+			} else {
+				value, err = p.child(indent)
+			}
+		case isYAMLBlockIndicator(rest):
+			value = p.blockScalar(indent, rest)
+		default:
+			value, err = parseYAMLValue(rest, line.num)
+		}
+		if err != nil {
+			return nil, err
+		}
+		obj[key] = value
+	}
+	return obj, nil
+}
+
+// blockScalar reads the lines of a | or > scalar indented under indent.
+func (p *yamlParser) blockScalar(indent int, indicator string) string {
+	var lines []string
+	content := -1
+	for ; p.pos < len(p.lines); p.pos++ {
+		line := p.lines[p.pos]
+		blank := strings.TrimSpace(line.raw) == ""
+		if !blank && line.indent <= indent {
+			break
+		}
+		if blank {
+			lines = append(lines, "")
+			continue
+		}
+		if content < 0 {
+			content = line.indent
+		}
+		if line.indent < content {
+			break
+		}
+		lines = append(lines, line.raw[content:])
+	}
+	trailing := 0
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+		trailing++
+	}
+	var text string
+	if indicator[0] == '>' {
+		var b strings.Builder
+		for i, line := range lines {
+			switch {
+			case i == 0:
+			case line == "" || lines[i-1] == "":
+				b.WriteByte('\n')
+			default:
+				b.WriteByte(' ')
+			}
+			b.WriteString(line)
+		}
+		text = b.String()
+	} else {
+		text = strings.Join(lines, "\n")
+	}
+	switch {
+	case strings.HasSuffix(indicator, "-") || len(lines) == 0:
+		return text
+	case strings.HasSuffix(indicator, "+"):
+		return text + strings.Repeat("\n", trailing+1)
+	}
+	return text + "\n"
+}
+
+// isYAMLSequenceEntry reports whether a line starts a sequence entry.
+func isYAMLSequenceEntry(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// isYAMLBlockIndicator reports whether a mapping value starts a block
+// scalar.
+func isYAMLBlockIndicator(text string) bool {
+	switch text {
+	case "|", "|-", "|+", ">", ">-", ">+":
+		return true
+	}
+	return false
+}
+
+// splitYAMLKey splits a "key: value" line, reporting false if the line is
+// not a mapping entry.
+func splitYAMLKey(text string) (string, string, bool, error) {
+	if text == "" || strings.ContainsRune("[{", rune(text[0])) {
+		return "", "", false, nil
+	}
+	if text[0] == '"' || text[0] == '\'' {
+		end := closingQuote(text, 0)
+		if end < 0 {
+			return "", "", false, fmt.Errorf("unterminated quoted string")
+		}
+		rest := strings.TrimLeft(text[end+1:], " ")
+		if !strings.HasPrefix(rest, ":") || (len(rest) > 1 && rest[1] != ' ') {
+			return "", "", false, nil
+		}
+		key, err := parseYAMLQuoted(text[:end+1])
+		if err != nil {
+			return "", "", false, err
+		}
+		return key, strings.TrimSpace(rest[1:]), true, nil
+	}
+	i := strings.Index(text, ": ")
+	if i < 0 {
+		if !strings.HasSuffix(text, ":") {
+			return "", "", false, nil
+		}
+		i = len(text) - 1
+	}
+	return strings.TrimSpace(text[:i]), strings.TrimSpace(text[i+1:]), true, nil
+}
+
+// parseYAMLValue parses a scalar or flow collection written on one line.
+func parseYAMLValue(text string, num int) (interface{}, error) {
+	f := &yamlFlow{s: text}
+	value, err := f.value(false)
+	if err == nil {
+		f.space()
+		if f.i < len(f.s) {
+			err = fmt.Errorf("unexpected %q", f.s[f.i:])
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("line %d: %v", num, err)
+	}
+	return value, nil
+}
+
+// yamlFlow reads a flow collection such as [a, b] or {a: 1}, or a scalar.
+type yamlFlow struct {
+	s string
+	i int
+}
+
+func (f *yamlFlow) space() {
+	for f.i < len(f.s) && f.s[f.i] == ' ' {
+		f.i++
+	}
+}
+
+// value reads the next value; inside a collection, plain scalars end at
+// the next comma or closing bracket.
+func (f *yamlFlow) value(nested bool) (interface{}, error) {
+	f.space()
+	if f.i == len(f.s) {
+		return nil, nil
+	}
+	switch f.s[f.i] {
+	case '[':
+		f.i++
+		list := []interface{}{}
+		for {
+			f.space()
+			if f.i < len(f.s) && f.s[f.i] == ']' {
+				f.i++
+				return list, nil
+			}
+			item, err := f.value(true)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, item)
+			if err := f.separator(']'); err != nil {
+				return nil, err
+			}
+		}
+	case '{':
+		f.i++
+		obj := map[string]interface{}{}
+		for {
+			f.space()
+			if f.i < len(f.s) && f.s[f.i] == '}' {
+				f.i++
+				return obj, nil
+			}
+			key, err := f.key()
+			if err != nil {
+				return nil, err
+			}
+			value, err := f.value(true)
+			if err != nil {
+				return nil, err
+			}
+			obj[key] = value
+			if err := f.separator('}'); err != nil {
+				return nil, err
+			}
+		}
+	case '"', '\'':
+		end := closingQuote(f.s, f.i)
+		if end < 0 {
+			return nil, fmt.Errorf("unterminated quoted string")
+		}
+		quoted := f.s[f.i : end+1]
+		f.i = end + 1
+		return parseYAMLQuoted(quoted)
+	}
+	start := f.i
+	if nested {
+		for f.i < len(f.s) && !strings.ContainsRune(",]}", rune(f.s[f.i])) {
+			f.i++
+		}
+	} else {
+		f.i = len(f.s)
+	}
+	return parseYAMLPlain(strings.TrimSpace(f.s[start:f.i])), nil
+}
+
+// key reads a flow mapping key and the colon after it.
+func (f *yamlFlow) key() (string, error) {
+	var key string
+	if f.s[f.i] == '"' || f.s[f.i] == '\'' {
+		end := closingQuote(f.s, f.i)
+		if end < 0 {
+			return "", fmt.Errorf("unterminated quoted string")
+		}
+		var err error
+		if key, err = parseYAMLQuoted(f.s[f.i : end+1]); err != nil {
+			return "", err
+		}
+		f.i = end + 1
+	} else {
+		start := f.i
+		for f.i < len(f.s) && !strings.ContainsRune(":,}", rune(f.s[f.i])) {
+			f.i++
+		}
+		key = strings.TrimSpace(f.s[start:f.i])
+	}
+	f.space()
+	if f.i == len(f.s) || f.s[f.i] != ':' {
+		return "", fmt.Errorf("expected : after key %q", key)
+	}
+	f.i++
+	return key, nil
+}
+
+// separator reads the comma between collection entries, or stops before
+// the closing bracket.
+func (f *yamlFlow) separator(closing byte) error {
+	f.space()
+	switch {
+	case f.i < len(f.s) && f.s[f.i] == ',':
+		f.i++
+		return nil
+	case f.i < len(f.s) && f.s[f.i] == closing:
+		return nil
+	}
+	return fmt.Errorf("expected , or %c", closing)
+}
+
+// closingQuote returns the index of the quote closing the string that
+// opens at start, or -1.
+func closingQuote(s string, start int) int {
+	quote := s[start]
+	for i := start + 1; i < len(s); i++ {
+		switch {
+		case quote == '"' && s[i] == '\\':
+			i++
+		case s[i] == quote && quote == '\'' && i+1 < len(s) && s[i+1] == '\'':
+			i++
+		case s[i] == quote:
+			return i
+		}
+	}
+	return -1
+}
+
+// parseYAMLQuoted unquotes a double-quoted scalar, whose escapes are read
+// as JSON's, or a single-quoted one, in which a doubled quote stands
+// for one.
+func parseYAMLQuoted(quoted string) (string, error) {
+	if quoted[0] == '\'' {
+		return strings.ReplaceAll(quoted[1:len(quoted)-1], "''", "'"), nil
+	}
+	var s string
+	if err := json.Unmarshal([]byte(quoted), &s); err != nil {
+		return "", fmt.Errorf("invalid quoted string %s", quoted)
+	}
+	return s, nil
+}
+
+// parseYAMLPlain types a plain scalar: null, a bool, a number or a string.
+func parseYAMLPlain(s string) interface{} {
+	switch s {
+	case "", "~", "null", "Null", "NULL":
+		return nil
+	case "true", "True", "TRUE":
+		return true
+	case "false", "False", "FALSE":
+		return false
+	}
+	var n float64
+	if json.Unmarshal([]byte(s), &n) == nil {
+		return n
+	}
+	return s
+}
+
+// stripYAMLComment removes a # comment from a line, leaving # inside
+// quoted strings and words alone.
+func stripYAMLComment(s string) string {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote == '"' && c == '\\':
+			i++
+		case quote == '\'' && c == '\'' && i+1 < len(s) && s[i+1] == '\'':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			if i == 0 || strings.ContainsRune(" :[{,-", rune(s[i-1])) {
+				quote = c
+			}
+		case c == '#' && (i == 0 || s[i-1] == ' '):
+			return s[:i]
+		}
+	}
+	return s
+}